@@ -156,8 +156,38 @@ func main() {
 		log.Println("ℹ️ 未找到商品服务地址，将使用模拟数据")
 	}
 
+	// 8.1 初始化用户服务客户端（用于结算预览获取配送地址，计算运费）
+	var userClient client.UserClient
+	userServiceAddr, err := registry.SelectOneHealthyInstance(nacosClient, "user-service")
+	if err != nil || userServiceAddr == "" {
+		log.Printf("⚠️ 从 Nacos 发现用户服务失败，将尝试使用配置中的备用地址: %v", err)
+		userServiceAddr = cfg.GetServiceClientsConfig().UserServiceAddr
+	}
+	if userServiceAddr != "" {
+		userClient, err = client.NewUserClient(userServiceAddr)
+		if err != nil {
+			log.Printf("⚠️ 用户服务客户端初始化失败，结算预览将按默认运费规则计算: %v", err)
+		} else {
+			defer userClient.Close()
+			log.Printf("✅ 用户服务客户端连接成功: %s", userServiceAddr)
+		}
+	} else {
+		log.Println("ℹ️ 未找到用户服务地址，结算预览将按默认运费规则计算")
+	}
+
 	// 9. 创建购物车服务
-	cartService := service.NewCartService(cartRepo, productClient, inventoryClient)
+	cartService := service.NewCartService(cartRepo, productClient, inventoryClient, userClient)
+
+	// 启动库存事件消费者：SKU 售罄时标记购物车项失效，补货后恢复
+	if database.RabbitMQChannel != nil {
+		if err := database.InitTopicExchange(database.RabbitMQChannel, mq.StockEventExchange, mq.StockEventCartQueue, mq.StockEventRoutingKey); err != nil {
+			log.Printf("⚠️ 初始化库存事件队列失败: %v", err)
+		} else {
+			stockConsumerCtx, cancelStockConsumer := context.WithCancel(context.Background())
+			defer cancelStockConsumer()
+			go service.StartStockEventConsumer(stockConsumerCtx, cartService, database.RabbitMQChannel, mq.StockEventCartQueue)
+		}
+	}
 
 	// 10. 创建购物车 Handler
 	cartServiceHandler := handler.NewCartServiceHandler(cartService)
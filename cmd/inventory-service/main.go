@@ -9,6 +9,7 @@ import (
 	inventoryv1 "zjMall/gen/go/api/proto/inventory"
 	"zjMall/internal/common/authz"
 	"zjMall/internal/common/middleware"
+	"zjMall/internal/common/mq"
 	registry "zjMall/internal/common/register"
 	"zjMall/internal/common/server"
 	"zjMall/internal/config"
@@ -70,11 +71,37 @@ func main() {
 	// }
 	// defer database.CloseRedis()
 
+	// 4. 初始化 RabbitMQ（用于发布库存水位事件：stock.low / stock.out / stock.restocked）
+	var stockMQProducer mq.MessageProducer
+	rabbitCfg := cfg.GetRabbitMQConfig()
+	if rabbitCfg != nil && rabbitCfg.Host != "" {
+		localCfg := *rabbitCfg
+		localCfg.Queue = mq.StockEventProductQueue
+
+		ch, err := database.InitRabbitMQ(&localCfg)
+		if err != nil {
+			log.Printf("⚠️ inventory-service RabbitMQ 初始化失败，库存事件将不会发送到 MQ: %v", err)
+		} else {
+			defer database.CloseRabbitMQ()
+			// 提前声明交换机并绑定商品服务、购物车服务的队列，避免消费者未启动时消息丢失
+			for _, queue := range []string{mq.StockEventProductQueue, mq.StockEventCartQueue} {
+				if err := database.InitTopicExchange(ch, mq.StockEventExchange, queue, mq.StockEventRoutingKey); err != nil {
+					log.Printf("⚠️ 初始化库存事件队列失败 queue=%s: %v", queue, err)
+				}
+			}
+			stockMQProducer = mq.NewMessageProducer(ch, localCfg.Queue)
+			log.Printf("✅ inventory-service RabbitMQ 初始化成功，交换机=%s", mq.StockEventExchange)
+		}
+	} else {
+		log.Println("ℹ️ RabbitMQ 未配置或主机为空，库存服务将不发布库存事件")
+	}
+
 	// 6. 创建购物车仓库（Redis 主存储 + MQ 异步同步到 MySQL）
 	inventoryRepo := repository.NewStockRepository(db)
+	warehouseRepo := repository.NewWarehouseRepository(db)
 
 	// 9. 创建购物车服务
-	inventoryService := service.NewInventoryService(inventoryRepo)
+	inventoryService := service.NewInventoryService(inventoryRepo, warehouseRepo, stockMQProducer)
 
 	// 10. 创建购物车 Handler
 	inventoryServiceHandler := invHandler.NewInventoryHandler(inventoryService)
@@ -111,7 +138,7 @@ func main() {
 			Name:        "inventory",
 			FilePath:    "docs/openapi/inventory.swagger.json",
 			Title:       "库存服务 API",
-			Description: "库存服务 API 文档，包括库存查询、扣减、回滚等功能",
+			Description: "库存服务 API 文档，包括库存查询、锁定、扣减、回滚、多仓分仓、安全库存预警及后台仓库管理、入库、调整、盘点等功能",
 			Version:     "1.0.0",
 		},
 	)
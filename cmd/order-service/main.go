@@ -17,7 +17,9 @@ import (
 	"zjMall/internal/common/server"
 	"zjMall/internal/config"
 	"zjMall/internal/database"
+	"zjMall/internal/order-service/carrier"
 	"zjMall/internal/order-service/handler"
+	"zjMall/internal/order-service/model"
 	"zjMall/internal/order-service/repository"
 	"zjMall/internal/order-service/service"
 	"zjMall/pkg"
@@ -100,6 +102,43 @@ func main() {
 	}
 	defer cartClient.Close()
 	log.Printf("✅ 购物车服务客户端连接成功: %s", cartServiceAddr)
+
+	// 促销服务为可选依赖（优先通过 Nacos 发现，其次使用配置中的备用地址），不可用时下单不享受优惠
+	var promotionClient client.PromotionClient
+	promotionServiceAddr, err := registry.SelectOneHealthyInstance(nacosClient, "promotion-service")
+	if err != nil || promotionServiceAddr == "" {
+		log.Printf("⚠️ 从 Nacos 发现促销服务失败，将尝试使用配置中的备用地址: %v", err)
+		promotionServiceAddr = cfg.GetServiceClientsConfig().PromotionServiceAddr
+	}
+	if promotionServiceAddr != "" {
+		promotionClient, err = client.NewPromotionClient(promotionServiceAddr)
+		if err != nil {
+			log.Printf("⚠️ 促销服务客户端初始化失败，下单将无法使用优惠: %v", err)
+		} else {
+			defer promotionClient.Close()
+			log.Printf("✅ 促销服务客户端连接成功: %s", promotionServiceAddr)
+		}
+	} else {
+		log.Println("ℹ️ 未找到促销服务地址，下单将不计算优惠")
+	}
+	// 支付服务为可选依赖（支付服务同时依赖订单服务，启动顺序不固定），不可用时售后审核无法发起退款
+	var paymentClient client.PaymentClient
+	paymentServiceAddr, err := registry.SelectOneHealthyInstance(nacosClient, "payment-service")
+	if err != nil || paymentServiceAddr == "" {
+		log.Printf("⚠️ 从 Nacos 发现支付服务失败，将尝试使用配置中的备用地址: %v", err)
+		paymentServiceAddr = cfg.GetServiceClientsConfig().PaymentServiceAddr
+	}
+	if paymentServiceAddr != "" {
+		paymentClient, err = client.NewPaymentClient(paymentServiceAddr)
+		if err != nil {
+			log.Printf("⚠️ 支付服务客户端初始化失败，售后将无法发起退款: %v", err)
+		} else {
+			defer paymentClient.Close()
+			log.Printf("✅ 支付服务客户端连接成功: %s", paymentServiceAddr)
+		}
+	} else {
+		log.Println("ℹ️ 未找到支付服务地址，售后将无法发起退款")
+	}
 	// 初始化 JWT（如果订单需要鉴权）
 	pkg.InitJWT(cfg.GetJWTConfig())
 
@@ -126,6 +165,22 @@ func main() {
 	rand.New(rand.NewSource(time.Now().UnixNano()))
 	// 3. 创建仓储与服务
 	orderRepo := repository.NewOrderRepository(db)
+	shipmentRepo := repository.NewShipmentRepository(db)
+	afterSaleRepo := repository.NewAfterSaleRepository(db)
+	invoiceRepo := repository.NewInvoiceRepository(db)
+	reviewRepo := repository.NewReviewRepository(db)
+	seckillRepo := repository.NewSeckillRepository(db)
+
+	// 物流公司适配器：未接入真实物流公司前，配置了模拟轨迹目录时所有物流公司使用模拟适配器
+	orderCfg := cfg.GetOrderConfig()
+	carrierRegistry := carrier.NewRegistry()
+	if orderCfg.CarrierStubDir != "" {
+		carrierRegistry.Register(carrier.AnyCarrier, carrier.NewFakeCarrier(carrier.AnyCarrier, orderCfg.CarrierStubDir, orderCfg.CarrierWebhookSecret))
+		log.Printf("ℹ️ 使用模拟物流公司适配器，轨迹目录=%s", orderCfg.CarrierStubDir)
+		if orderCfg.CarrierWebhookSecret == "" {
+			log.Println("⚠️ 未配置模拟物流公司推送签名密钥（order.carrier_webhook_secret），物流推送将被拒绝")
+		}
+	}
 
 	// 3.1 初始化 RabbitMQ 延迟消息（用于订单超时）
 	var delayedProducer mq.MessageProducer
@@ -153,11 +208,47 @@ func main() {
 					delayedProducer = mq.NewMessageProducerWithConfirm(delayedCh, delayedQueue, confirmCh)
 				}
 				log.Printf("✅ order-service 延迟消息 Exchange 初始化成功: Exchange=%s, Queue=%s", delayedExchange, delayedQueue)
+
+				// 自动确认收货延迟消息与订单超时共用同一连接与生产者
+				if err := database.InitDelayedExchange(delayedCh, service.OrderAutoCompleteExchange, service.OrderAutoCompleteQueue); err != nil {
+					log.Printf("⚠️ order-service 自动确认收货延迟消息 Exchange 初始化失败: %v", err)
+				}
 			}
 		}
 	}
 
-	orderService := service.NewOrderService(orderRepo, productClient, inventoryClient, userClient, cartClient, redisClient, delayedProducer)
+	// 3.2 初始化秒杀异步下单队列（生产者开启 Publisher Confirm，消费者使用单独的 Channel）
+	var seckillProducer mq.MessageProducer
+	var seckillConsumerCh *amqp.Channel
+	if rabbitCfg != nil && rabbitCfg.Host != "" {
+		seckillCfg := *rabbitCfg
+		seckillCfg.Queue = service.SeckillOrderQueue
+		seckillCh, err := database.InitRabbitMQ(&seckillCfg)
+		if err != nil {
+			log.Printf("⚠️ order-service 秒杀队列初始化失败，秒杀不可用: %v", err)
+		} else {
+			confirmCh, err := database.EnablePublisherConfirm(seckillCh)
+			if err != nil {
+				log.Printf("⚠️ order-service 秒杀队列 Publisher Confirm 开启失败: %v，将使用普通生产者", err)
+				seckillProducer = mq.NewMessageProducer(seckillCh, seckillCfg.Queue)
+			} else {
+				seckillProducer = mq.NewMessageProducerWithConfirm(seckillCh, seckillCfg.Queue, confirmCh)
+			}
+			seckillConsumerCh, err = database.InitRabbitMQ(&seckillCfg)
+			if err != nil {
+				log.Printf("⚠️ order-service 秒杀消费者 Channel 初始化失败: %v", err)
+			}
+			log.Printf("✅ order-service 秒杀队列初始化成功: Queue=%s", seckillCfg.Queue)
+		}
+	}
+
+	autoCompleteDelay := time.Duration(orderCfg.AutoCompleteDays) * 24 * time.Hour
+	// 按订单类型的支付时限（未配置时使用默认值）
+	payTimeouts := map[string]time.Duration{
+		model.OrderTypeNormal:  time.Duration(orderCfg.PayTimeoutMinutes) * time.Minute,
+		model.OrderTypeSeckill: time.Duration(orderCfg.SeckillPayTimeoutMinutes) * time.Minute,
+	}
+	orderService := service.NewOrderService(orderRepo, productClient, inventoryClient, userClient, cartClient, promotionClient, redisClient, delayedProducer, autoCompleteDelay, payTimeouts, shipmentRepo, carrierRegistry, afterSaleRepo, paymentClient, seckillRepo, seckillProducer, invoiceRepo, reviewRepo)
 	orderHandler := handler.NewOrderServiceHandler(orderService)
 
 	// 启动订单超时消息消费者（在 orderService 创建之后）
@@ -166,10 +257,21 @@ func main() {
 		defer timeoutConsumerCancel()
 		go service.StartOrderTimeoutConsumer(timeoutConsumerCtx, orderService, delayedCh, "order.timeout.queue")
 		log.Println("✅ 订单超时消息消费者已启动")
+		go service.StartOrderAutoCompleteConsumer(timeoutConsumerCtx, orderService, delayedCh, service.OrderAutoCompleteQueue)
+		log.Println("✅ 自动确认收货消息消费者已启动")
 	} else {
 		log.Println("⚠️ 订单超时消息消费者未启动（延迟消息未初始化），将依赖补偿机制定期扫描超时订单")
 	}
 
+	// 启动秒杀异步下单消费者
+	if seckillConsumerCh != nil {
+		seckillConsumerCtx, seckillConsumerCancel := context.WithCancel(context.Background())
+		defer seckillConsumerCancel()
+		service.StartSeckillOrderConsumer(seckillConsumerCtx, orderService, seckillConsumerCh, service.SeckillOrderQueue)
+	} else {
+		log.Println("⚠️ 秒杀下单消费者未启动（秒杀队列未初始化）")
+	}
+
 	// 启动订单超时补偿机制（定期扫描超时订单，作为延迟消息的兜底方案）
 	// 注意：无论延迟消息是否启动，补偿机制都应该运行，确保即使延迟消息失败也能处理超时订单
 	compensationCtx, compensationCancel := context.WithCancel(context.Background())
@@ -177,7 +279,19 @@ func main() {
 	go service.StartOrderTimeoutCompensation(compensationCtx, orderService, 30*time.Minute) // 每30分钟扫描一次
 	log.Println("✅ 订单超时补偿机制已启动（每30分钟扫描一次）")
 
-	// 3.2 初始化 RabbitMQ 并启动支付成功事件消费者（可选）
+	// 启动自动确认收货补偿机制（延迟消息丢失时兜底）
+	go service.StartOrderAutoCompleteCompensation(compensationCtx, orderService, time.Hour)
+	log.Println("✅ 自动确认收货补偿机制已启动（每小时扫描一次）")
+
+	// 启动订单优惠归还补偿机制（归还已锁定但订单未创建的优惠券和促销配额）
+	go service.StartDiscountClaimCompensation(compensationCtx, orderService, time.Minute)
+	log.Println("✅ 订单优惠归还补偿机制已启动（每分钟扫描一次）")
+
+	// 启动秒杀活动库存对账（活动结束后按 Redis 剩余名额归还未售出库存）
+	go service.StartSeckillSettlement(compensationCtx, orderService, time.Minute)
+	log.Println("✅ 秒杀活动库存对账任务已启动（每分钟扫描一次）")
+
+	// 3.3 初始化 RabbitMQ 并启动支付成功事件消费者（可选）
 	if rabbitCfg != nil && rabbitCfg.Host != "" {
 		// 复制一份配置，使用单独的队列用于支付成功事件
 		localCfg := *rabbitCfg
@@ -191,11 +305,13 @@ func main() {
 			consumerCtx, cancel := context.WithCancel(context.Background())
 			defer cancel()
 			go service.StartPaymentEventConsumer(consumerCtx, orderService, ch, localCfg.Queue)
+			// 退款事件与支付成功事件共用同一连接，队列名与支付服务保持一致
+			go service.StartRefundEventConsumer(consumerCtx, orderService, ch, "payment.refund.notify")
 
 			// 这里原本初始化 Outbox 生产者并启动 Outbox 派发协程，现已移除
 		}
 	} else {
-		log.Println("ℹ️ RabbitMQ 未配置或主机为空，订单服务将不消费支付成功事件")
+		log.Println("ℹ️ RabbitMQ 未配置或主机为空，订单服务将不消费支付成功与退款事件")
 	}
 
 	// 4. 获取服务配置
@@ -222,6 +338,10 @@ func main() {
 	if err := srv.RegisterHTTPGateway(orderv1.RegisterOrderServiceHandlerFromEndpoint); err != nil {
 		log.Fatalf("failed to register order service gateway: %v", err)
 	}
+	// 物流公司推送（原始报文由适配器解析，不经过 gRPC 网关）
+	srv.AddRoute("/api/v1/shipments/webhook/", orderHandler.CarrierWebhookHTTP)
+	// 管理员订单导出（CSV 流式写出，不经过 gRPC 网关）
+	srv.AddRoute("/api/v1/admin/orders/export", orderHandler.ExportOrdersHTTP)
 
 	srv.RegisterSwagger(
 		server.SwaggerDoc{
@@ -15,11 +15,14 @@ import (
 	"zjMall/internal/common/lock"
 	"zjMall/internal/common/middleware"
 	"zjMall/internal/common/mq"
+	"zjMall/internal/common/outbox"
 	registry "zjMall/internal/common/register"
 	"zjMall/internal/common/server"
 	"zjMall/internal/config"
 	"zjMall/internal/database"
+	"zjMall/internal/payment-service/gateway"
 	"zjMall/internal/payment-service/handler"
+	"zjMall/internal/payment-service/model"
 	"zjMall/internal/payment-service/repository"
 	"zjMall/internal/payment-service/service"
 	"zjMall/pkg"
@@ -96,6 +99,11 @@ func main() {
 			defer database.CloseRabbitMQ()
 			paymentMQProducer = mq.NewMessageProducer(ch, localCfg.Queue)
 			log.Printf("✅ payment-service RabbitMQ 初始化成功，队列=%s", localCfg.Queue)
+
+			// 退款事件使用单独的队列，提前声明避免订单服务消费者未启动时消息丢失
+			if _, err := ch.QueueDeclare(service.RefundNotifyQueue, true, false, false, false, nil); err != nil {
+				log.Printf("⚠️ 声明退款事件队列失败: %v", err)
+			}
 		}
 	} else {
 		log.Println("ℹ️ RabbitMQ 未配置或主机为空，支付服务将不发送 MQ 事件")
@@ -133,9 +141,13 @@ func main() {
 	paymentLogRepo := repository.NewPaymentLogRepository(db)
 	paymentChannelRepo := repository.NewPaymentChannelRepository(db)
 	outboxRepo := repository.NewPaymentOutboxRepository(db)
+	refundRepo := repository.NewRefundRepository(db)
+	reconciliationRepo := repository.NewReconciliationRepository(db)
+	walletRepo := repository.NewWalletRepository(db)
 
 	// 9. 创建 PaymentService
 	var paymentTimeout = 30 * time.Minute
+	gatewayRegistry := gateway.NewRegistry(cfg.GetPaymentConfig().Environment)
 	paymentService := service.NewPaymentService(
 		paymentRepo,
 		paymentLogRepo,
@@ -144,26 +156,77 @@ func main() {
 		cacheRepo,
 		paymentTimeout,
 		lockService,
-		paymentMQProducer,
 		outboxRepo,
+		refundRepo,
+		gatewayRegistry,
+		reconciliationRepo,
+		walletRepo,
 	)
 
-	// 10. 启动 Outbox 派发协程（定期将 Outbox 事件发送到 MQ）
+	// 根据渠道配置加载支付网关（沙箱网关的异步回调直接投递给 PaymentService）
+	if channels, err := paymentChannelRepo.ListPaymentChannels(context.Background()); err != nil {
+		log.Printf("⚠️ 加载支付渠道配置失败，第三方支付暂不可用: %v", err)
+	} else {
+		gatewayRegistry.Load(channels, paymentService)
+	}
+
+	// 10. 启动 Outbox 派发协程（定期将 Outbox 事件发送到 MQ，多副本通过认领租约避免重复投递）
 	if paymentMQProducer != nil {
 		dispatchCtx, cancel := context.WithCancel(context.Background())
 		defer cancel()
+		outboxDispatcher := outbox.NewDispatcher(
+			outbox.NewStore(db, model.PaymentOutbox{}.TableName()),
+			service.NewOutboxRoutes(),
+			paymentMQProducer,
+			outbox.DispatcherConfig{Name: serviceName},
+		)
+		go outboxDispatcher.Run(dispatchCtx, 10*time.Second)
+	}
+
+	// 10.1 启动支付单主动对账协程（定期查询长时间未完成的支付单在渠道侧的状态）
+	reconcileCtx, cancelReconcile := context.WithCancel(context.Background())
+	defer cancelReconcile()
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-reconcileCtx.Done():
+				log.Println("ℹ️ 支付对账协程退出")
+				return
+			case <-ticker.C:
+				if err := paymentService.ReconcileStuckPayments(reconcileCtx, service.PaymentReconcileAfter, service.PaymentReconcileBatchSize); err != nil {
+					log.Printf("⚠️ 支付对账失败: %v", err)
+				}
+			}
+		}
+	}()
+
+	// 10.2 启动每日对账单比对协程（每小时检查一次，前一天的账单比对成功后当天不再重复执行）
+	if statementDir := cfg.GetPaymentConfig().StatementDir; statementDir != "" {
 		go func() {
-			ticker := time.NewTicker(10 * time.Second)
+			ticker := time.NewTicker(time.Hour)
 			defer ticker.Stop()
+			lastBillDate := ""
 			for {
 				select {
-				case <-dispatchCtx.Done():
-					log.Println("ℹ️ Outbox 派发协程退出")
+				case <-reconcileCtx.Done():
+					log.Println("ℹ️ 对账单比对协程退出")
 					return
 				case <-ticker.C:
-					if err := paymentService.DispatchOutboxEvents(dispatchCtx, 100); err != nil {
-						log.Printf("⚠️ Outbox 派发失败: %v", err)
+					billDate := time.Now().AddDate(0, 0, -1)
+					if billDate.Format(service.StatementBillDateLayout) == lastBillDate {
+						continue
+					}
+					files, err := paymentService.ReconcileStatementDir(reconcileCtx, statementDir, billDate)
+					if err != nil {
+						log.Printf("⚠️ 对账单比对失败: %v", err)
+						continue
+					}
+					if files == 0 {
+						continue // 账单尚未就绪，下个周期重试
 					}
+					lastBillDate = billDate.Format(service.StatementBillDateLayout)
 				}
 			}
 		}()
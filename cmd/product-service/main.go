@@ -10,6 +10,7 @@ import (
 	"zjMall/internal/common/authz"
 	"zjMall/internal/common/cache"
 	"zjMall/internal/common/middleware"
+	"zjMall/internal/common/mq"
 	registry "zjMall/internal/common/register"
 	"zjMall/internal/common/server"
 	"zjMall/internal/config"
@@ -111,6 +112,8 @@ func main() {
 	skuRepo := repository.NewSkuRepository(db)
 	attributeRepo := repository.NewAttributeRepository(db)
 	attributeValueRepo := repository.NewAttributeValueRepository(db)
+	freightRepo := repository.NewFreightTemplateRepository(db)
+	ratingRepo := repository.NewProductRatingRepository(db)
 
 	// 创建 ES 搜索仓库
 	searchRepo := repository.NewSearchRepository(elasticsearchClient.GetClient())
@@ -135,12 +138,36 @@ func main() {
 		attributeRepo,
 		attributeValueRepo,
 		skuRepo,
+		ratingRepo,
 	)
 	log.Println("✅ SearchService 创建成功")
 
+	// 初始化 RabbitMQ（可选）：消费库存事件，同步 SKU 售罄标记到 ES
+	rabbitCfg := config.GetRabbitMQConfig()
+	if rabbitCfg != nil && rabbitCfg.Host != "" {
+		localCfg := *rabbitCfg
+		localCfg.Queue = mq.StockEventProductQueue
+
+		ch, err := database.InitRabbitMQ(&localCfg)
+		if err != nil {
+			log.Printf("⚠️ product-service RabbitMQ 初始化失败，SKU 售罄标记将不会随库存更新: %v", err)
+		} else {
+			defer database.CloseRabbitMQ()
+			if err := database.InitTopicExchange(ch, mq.StockEventExchange, mq.StockEventProductQueue, mq.StockEventRoutingKey); err != nil {
+				log.Printf("⚠️ 初始化库存事件队列失败: %v", err)
+			} else {
+				consumerCtx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+				go service.StartStockEventConsumer(consumerCtx, searchService, ch, mq.StockEventProductQueue)
+			}
+		}
+	} else {
+		log.Println("ℹ️ RabbitMQ 未配置或主机为空，商品服务将不消费库存事件")
+	}
+
 	// 9. 创建Service
 	log.Println("🔧 创建 Service...")
-	productService := service.NewProductService(categoryRepo, brandRepo, productRepo, tagRepo, skuRepo, attributeRepo, attributeValueRepo, searchService)
+	productService := service.NewProductService(categoryRepo, brandRepo, productRepo, tagRepo, skuRepo, attributeRepo, attributeValueRepo, freightRepo, searchService)
 	log.Println("✅ Service 创建成功")
 
 	//7.创建Handler
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+
+	commonv1 "zjMall/gen/go/api/proto/common"
+	promotionv1 "zjMall/gen/go/api/proto/promotion"
+	"zjMall/internal/common/authz"
+	"zjMall/internal/common/middleware"
+	registry "zjMall/internal/common/register"
+	"zjMall/internal/common/server"
+	"zjMall/internal/config"
+	"zjMall/internal/database"
+	"zjMall/internal/promotion-service/handler"
+	"zjMall/internal/promotion-service/repository"
+	"zjMall/internal/promotion-service/service"
+	"zjMall/pkg"
+
+	"google.golang.org/grpc"
+)
+
+const serviceName = "promotion-service"
+const serviceIP = "127.0.0.1"
+
+func main() {
+	logFile, err := pkg.InitLog(serviceName)
+	if err != nil {
+		log.Fatalf("Error initializing log: %v", err)
+	}
+	defer logFile.Close()
+	log.Printf("==== %s starting ====", serviceName)
+
+	// 1. 加载配置
+	configPath := filepath.Join("./configs", "config.yaml")
+	cfg, err := config.LoadConfigFromNacos(configPath, "zjmall-dev.yaml", "DEFAULT_GROUP")
+	if err != nil {
+		log.Fatalf("❌ 从 Nacos 加载配置失败: %v", err)
+	}
+	// 加载完配置 cfg 之后：
+	if err := authz.InitCasbin(); err != nil {
+		log.Fatalf("❌ Casbin 初始化失败: %v", err)
+	}
+	//2.初始化Nacos
+	svcCfg, _ := cfg.GetServiceConfig(serviceName)
+	nacosConfig := cfg.GetNacosConfig()
+	nacosClient, err := registry.NewNacosNamingClient(nacosConfig)
+	if err != nil {
+		log.Fatalf("❌ Nacos 初始化失败: %v", err)
+	}
+	registry.RegisterService(nacosClient, serviceName, serviceIP, uint64(svcCfg.GRPC.Port))
+	//初始化JWT
+	pkg.InitJWT(cfg.GetJWTConfig())
+	// 3. 初始化数据库（促销活动、优惠券存储在 MySQL）
+	mysqlConfig, err := cfg.GetDatabaseConfigForService(serviceName)
+	if err != nil {
+		log.Fatalf("Error getting database config for %s: %v", serviceName, err)
+	}
+	db, err := database.InitMySQL(mysqlConfig)
+	if err != nil {
+		log.Fatalf("Error initializing MySQL: %v", err)
+	}
+	defer database.CloseMySQL()
+
+	// 4. 创建仓库
+	promotionRepo := repository.NewPromotionRepository(db)
+	couponRepo := repository.NewCouponRepository(db)
+	orderDiscountRepo := repository.NewOrderDiscountRepository(db)
+
+	// 5. 创建促销服务
+	promotionService := service.NewPromotionService(promotionRepo, couponRepo, orderDiscountRepo)
+
+	// 6. 创建促销 Handler
+	promotionHandler := handler.NewPromotionHandler(promotionService)
+
+	// 7. 获取服务配置
+	serviceCfg, err := cfg.GetServiceConfig(serviceName)
+	if err != nil {
+		log.Fatalf("Error getting service config: %v", err)
+	}
+
+	// 8. 创建服务器实例
+	srv := server.NewServer(&server.Config{
+		GRPCAddr: fmt.Sprintf(":%d", serviceCfg.GRPC.Port),
+		HTTPAddr: fmt.Sprintf(":%d", serviceCfg.HTTP.Port),
+	})
+
+	// 9. 注册 gRPC 服务
+	srv.RegisterGRPCService(func(grpcServer *grpc.Server) {
+		promotionv1.RegisterPromotionServiceServer(grpcServer, promotionHandler)
+	})
+
+	// 10. 注册 HTTP 网关处理器
+	if err := srv.RegisterHTTPGateway(commonv1.RegisterHealthServiceHandlerFromEndpoint); err != nil {
+		log.Fatalf("failed to register health service gateway: %v", err)
+	}
+
+	if err := srv.RegisterHTTPGateway(promotionv1.RegisterPromotionServiceHandlerFromEndpoint); err != nil {
+		log.Fatalf("failed to register promotion service gateway: %v", err)
+	}
+
+	// 11. 注册 Swagger 文档
+	srv.RegisterSwagger(
+		server.SwaggerDoc{
+			Name:        "promotion",
+			FilePath:    "docs/openapi/promotion.swagger.json",
+			Title:       "促销服务 API",
+			Description: "促销服务 API 文档，包括促销活动管理、优惠计算、优惠券领取与核销等功能",
+			Version:     "1.0.0",
+		},
+	)
+
+	// 12. 注册中间件
+	srv.UseMiddleware(
+		middleware.CORS(middleware.DefaultCORSConfig()), // 1. 最外层：处理跨域
+		middleware.Recovery(),                           // 2. 捕获 panic
+		middleware.Logging(),                            // 3. 记录日志
+		middleware.TraceID(),                            // 4. 生成 TraceID
+		middleware.PrometheusMetrics(),                  // 5. Prometheus 指标收集
+		middleware.Auth(),                               // 6. 认证
+		middleware.CasbinRBAC(),                         // 7. RBAC 权限控制
+	)
+
+	// 13. 启动服务器（阻塞）
+	if err := srv.Start(); err != nil {
+		log.Fatalf("failed to start server: %v", err)
+	}
+}
@@ -13,11 +13,14 @@ CREATE TABLE IF NOT EXISTS inventory_stocks (
     id VARCHAR(26) NOT NULL PRIMARY KEY COMMENT '主键ID',
     sku_id VARCHAR(26) NOT NULL COMMENT 'SKU ID（与商品服务中的 SKUID 对应）',
     available_stock INT NOT NULL DEFAULT 0 COMMENT '可用库存数量',
+    locked_stock INT NOT NULL DEFAULT 0 COMMENT '锁定库存数量（已下单未支付，支付后扣除，取消/超时关闭时转回可用库存）',
+    safety_stock INT NOT NULL DEFAULT 0 COMMENT '安全库存阈值（可用库存 <= 阈值时发布 stock.low，0 表示不预警）',
+    stock_level TINYINT NOT NULL DEFAULT 0 COMMENT '最近一次发布事件时的库存水位：0-正常，1-低库存，2-售罄',
     version BIGINT NOT NULL DEFAULT 0 COMMENT '乐观锁版本号（预留，当前未使用）',
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
     INDEX idx_sku_id_stock (sku_id, available_stock)
-) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='库存主表（SKU 维度）';
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='库存主表（SKU 维度汇总，等于各仓库存之和）';
 
 
 -- ============================================
@@ -27,9 +30,14 @@ CREATE TABLE IF NOT EXISTS inventory_stocks (
 CREATE TABLE IF NOT EXISTS inventory_logs (
     id VARCHAR(26) NOT NULL PRIMARY KEY COMMENT '日志ID',
     sku_id VARCHAR(26) NOT NULL COMMENT 'SKU ID',
+    warehouse_id VARCHAR(26) DEFAULT NULL COMMENT '仓库ID（多仓上线前的记录为空，视为默认仓）',
     change_amount INT NOT NULL COMMENT '库存变动数量：正数增加，负数减少',
-    reason VARCHAR(50) NOT NULL COMMENT '变动原因：deduct, rollback, manual_adjust 等',
-    ref_id VARCHAR(64) DEFAULT NULL COMMENT '关联单号（订单号/操作单号等）',
+    locked_change INT NOT NULL DEFAULT 0 COMMENT '锁定库存变动数量：正数增加，负数减少',
+    reason VARCHAR(50) NOT NULL COMMENT '变动原因：deduct, rollback, reserve, confirm, release, init, adjust, inbound, stocktake',
+    ref_id VARCHAR(64) DEFAULT NULL COMMENT '关联单号（订单号/入库单号/盘点单号/调整单号等）',
+    reason_code VARCHAR(32) DEFAULT NULL COMMENT '调整原因编码（reason 为 adjust 时记录）：damage, lost, found, supplier_return, correction',
+    operator_id VARCHAR(26) DEFAULT NULL COMMENT '操作人ID（后台操作时记录管理员ID）',
+    remark VARCHAR(255) DEFAULT NULL COMMENT '备注',
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
     INDEX idx_sku_time (sku_id, created_at),
     INDEX idx_ref_id (ref_id),
@@ -39,3 +47,44 @@ CREATE TABLE IF NOT EXISTS inventory_logs (
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='库存变动明细表';
 
 
+-- ============================================
+-- 3. 仓库表
+-- 对应 Go 模型：internal/inventory-service/model/warehouse.go
+-- ============================================
+CREATE TABLE IF NOT EXISTS inventory_warehouses (
+    id VARCHAR(26) NOT NULL PRIMARY KEY COMMENT '仓库ID',
+    code VARCHAR(32) NOT NULL COMMENT '仓库编码',
+    name VARCHAR(100) NOT NULL COMMENT '仓库名称',
+    province VARCHAR(50) DEFAULT NULL COMMENT '所在省份（用于就近分仓）',
+    priority INT NOT NULL DEFAULT 0 COMMENT '优先级（数值越小越优先）',
+    status TINYINT NOT NULL DEFAULT 1 COMMENT '仓库状态：1-启用，2-停用',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    UNIQUE KEY uk_code (code)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='仓库表';
+
+-- 默认仓：多仓上线前的库存，以及未指定仓库的后台操作、回补均归属此仓
+INSERT IGNORE INTO inventory_warehouses (id, code, name, province, priority, status)
+VALUES ('DEFAULT', 'DEFAULT', '默认仓', NULL, 100, 1);
+
+
+-- ============================================
+-- 4. 分仓库存表（仓库 + SKU 维度）
+-- 对应 Go 模型：internal/inventory-service/model/warehouse.go
+-- ============================================
+CREATE TABLE IF NOT EXISTS inventory_warehouse_stocks (
+    id VARCHAR(26) NOT NULL PRIMARY KEY COMMENT '主键ID',
+    warehouse_id VARCHAR(26) NOT NULL COMMENT '仓库ID',
+    sku_id VARCHAR(26) NOT NULL COMMENT 'SKU ID',
+    available_stock INT NOT NULL DEFAULT 0 COMMENT '可用库存数量',
+    locked_stock INT NOT NULL DEFAULT 0 COMMENT '锁定库存数量（已下单未支付）',
+    version BIGINT NOT NULL DEFAULT 0 COMMENT '乐观锁版本号',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    UNIQUE KEY uk_warehouse_sku (warehouse_id, sku_id),
+    INDEX idx_sku_id (sku_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='分仓库存表';
+
+-- 多仓上线前的库存全部归属默认仓
+INSERT IGNORE INTO inventory_warehouse_stocks (id, warehouse_id, sku_id, available_stock, locked_stock)
+SELECT id, 'DEFAULT', sku_id, available_stock, locked_stock FROM inventory_stocks;
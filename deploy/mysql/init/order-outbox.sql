@@ -7,12 +7,17 @@ CREATE TABLE IF NOT EXISTS order_outbox (
     event_type   VARCHAR(64)     NOT NULL COMMENT '事件类型，如 cart.items.remove, order.timeout',
     aggregate_id VARCHAR(64)     NOT NULL COMMENT '聚合ID，如 order_no',
     payload      JSON            NOT NULL COMMENT '事件载荷，JSON 格式',
-    status       TINYINT         NOT NULL DEFAULT 0 COMMENT '状态：0-待发送，1-已发送，2-发送失败',
+    status       TINYINT         NOT NULL DEFAULT 0 COMMENT '状态：0-待发送，1-已发送，2-发送失败（等待重试），3-死信',
     retry_count  INT             NOT NULL DEFAULT 0 COMMENT '重试次数',
     error_msg    VARCHAR(500)             DEFAULT NULL COMMENT '最近一次错误信息',
+    next_retry_at TIMESTAMP      NULL     DEFAULT NULL COMMENT '下次可投递时间（指数退避），为空表示立即投递',
+    claim_token  VARCHAR(26)              DEFAULT NULL COMMENT '认领批次令牌（多副本派发时防止重复投递）',
+    claimed_until TIMESTAMP      NULL     DEFAULT NULL COMMENT '认领租约到期时间',
     created_at   TIMESTAMP       NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
     updated_at   TIMESTAMP       NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
     INDEX idx_status_created_at (status, created_at),
+    INDEX idx_status_next_retry (status, next_retry_at),
+    INDEX idx_claim_token (claim_token),
     INDEX idx_aggregate_id (aggregate_id),
     INDEX idx_event_type (event_type)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='订单服务 Outbox 表';
@@ -20,6 +20,12 @@ CREATE TABLE IF NOT EXISTS orders (
     discount_amount DECIMAL(10, 2) NOT NULL DEFAULT 0 COMMENT '优惠总金额',
     shipping_amount DECIMAL(10, 2) NOT NULL DEFAULT 0 COMMENT '运费金额',
     pay_amount DECIMAL(10, 2) NOT NULL DEFAULT 0 COMMENT '应付金额',
+    coupon_id VARCHAR(26) COMMENT '使用的优惠券ID',
+
+    split_type TINYINT NOT NULL DEFAULT 0 COMMENT '拆单类型：0-未拆单，1-主订单，2-子订单',
+    parent_order_no VARCHAR(32) COMMENT '主订单号（仅子订单）',
+    merchant_id VARCHAR(26) COMMENT '商家ID（为空表示平台自营）',
+    seckill_activity_no VARCHAR(32) COMMENT '秒杀活动编号（仅秒杀订单）',
 
     receiver_name VARCHAR(50) COMMENT '收货人姓名',
     receiver_phone VARCHAR(20) COMMENT '收货人电话',
@@ -32,8 +38,12 @@ CREATE TABLE IF NOT EXISTS orders (
 
     items_snapshot JSON COMMENT '商品列表精简快照（JSON格式，包含商品基本信息，用于快速查看订单商品）',
 
+    shipping_carrier VARCHAR(32) COMMENT '物流公司',
+    tracking_no VARCHAR(64) COMMENT '物流单号',
+
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    pay_deadline TIMESTAMP NULL DEFAULT NULL COMMENT '支付截止时间（按订单类型默认时限，可由管理员延长；子订单为空）',
     paid_at TIMESTAMP NULL DEFAULT NULL COMMENT '支付时间',
     shipped_at TIMESTAMP NULL DEFAULT NULL COMMENT '发货时间',
     completed_at TIMESTAMP NULL DEFAULT NULL COMMENT '完成时间',
@@ -41,7 +51,12 @@ CREATE TABLE IF NOT EXISTS orders (
 
     INDEX idx_user_status (user_id, status),
     INDEX idx_created_at (created_at),
-    INDEX idx_order_no_user (order_no, user_id)
+    INDEX idx_order_no_user (order_no, user_id),
+    INDEX idx_parent_order_no (parent_order_no) COMMENT '按主订单查询子订单',
+    INDEX idx_seckill_activity_no (seckill_activity_no),
+    INDEX idx_status_shipped (status, shipped_at) COMMENT '用于扫描发货超时未确认收货的订单',
+    INDEX idx_status_pay_deadline (status, pay_deadline) COMMENT '用于扫描超过支付截止时间的订单',
+    INDEX idx_receiver_phone (receiver_phone) COMMENT '管理员按收货人电话搜索订单'
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='订单主表';
 
 
@@ -63,7 +78,9 @@ CREATE TABLE IF NOT EXISTS order_items (
 
     price DECIMAL(10, 2) NOT NULL DEFAULT 0 COMMENT '单价快照',
     quantity INT NOT NULL DEFAULT 1 COMMENT '购买数量',
+    discount_amount DECIMAL(10, 2) NOT NULL DEFAULT 0 COMMENT '分摊优惠金额（促销 + 优惠券）',
     subtotal DECIMAL(10, 2) NOT NULL DEFAULT 0 COMMENT '小计金额（price * quantity - 分摊优惠）',
+    warehouse_id VARCHAR(26) COMMENT '发货仓库ID（下单分仓结果）',
 
     item_snapshot JSON COMMENT '商品详细快照（JSON格式，包含商品完整信息，用于审计和对账）',
 
@@ -72,7 +89,259 @@ CREATE TABLE IF NOT EXISTS order_items (
 
     INDEX idx_order_no (order_no),
     INDEX idx_user_order (user_id, order_no),
-    INDEX idx_product_sku (product_id, sku_id)
+    INDEX idx_product_sku (product_id, sku_id),
+    INDEX idx_sku_id (sku_id) COMMENT '管理员按 SKU 搜索订单'
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='订单明细表';
 
 
+
+
+-- ============================================
+-- 3. 物流单表
+-- 订单发货时创建，一个订单对应一个物流单
+-- 对应 Go 模型：internal/order-service/model/shipment.go
+-- ============================================
+CREATE TABLE IF NOT EXISTS shipments (
+    id VARCHAR(26) NOT NULL PRIMARY KEY COMMENT '主键ID（ULID）',
+    order_no VARCHAR(32) NOT NULL COMMENT '订单号',
+    user_id VARCHAR(26) NOT NULL COMMENT '用户ID',
+
+    carrier VARCHAR(32) NOT NULL COMMENT '物流公司',
+    tracking_no VARCHAR(64) NOT NULL COMMENT '物流单号',
+    status TINYINT NOT NULL DEFAULT 1 COMMENT '物流状态：1-运输中，2-派送中，3-已签收，4-异常',
+
+    shipped_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '发货时间',
+    last_event_at TIMESTAMP NULL DEFAULT NULL COMMENT '最新轨迹时间',
+    synced_at TIMESTAMP NULL DEFAULT NULL COMMENT '最近一次主动查询物流公司的时间',
+    delivered_at TIMESTAMP NULL DEFAULT NULL COMMENT '签收时间',
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    version INT NOT NULL DEFAULT 0 COMMENT '版本号',
+
+    UNIQUE KEY uk_order_no (order_no),
+    UNIQUE KEY uk_carrier_tracking (carrier, tracking_no) COMMENT '物流推送按物流公司+物流单号定位物流单',
+    INDEX idx_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='物流单表';
+
+
+-- ============================================
+-- 4. 物流轨迹表
+-- 主动查询与物流推送的轨迹合并记录，同一时间、同一状态的轨迹只记录一次
+-- 对应 Go 模型：internal/order-service/model/shipment.go
+-- ============================================
+CREATE TABLE IF NOT EXISTS shipment_events (
+    id VARCHAR(26) NOT NULL PRIMARY KEY COMMENT '主键ID（ULID）',
+    shipment_id VARCHAR(26) NOT NULL COMMENT '物流单ID',
+    order_no VARCHAR(32) NOT NULL COMMENT '订单号',
+
+    event_time TIMESTAMP NOT NULL COMMENT '轨迹时间',
+    status TINYINT NOT NULL COMMENT '物流状态：1-运输中，2-派送中，3-已签收，4-异常',
+    location VARCHAR(128) COMMENT '所在地',
+    description VARCHAR(255) COMMENT '轨迹描述',
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+
+    UNIQUE KEY uk_shipment_event (shipment_id, event_time, status),
+    INDEX idx_order_no (order_no)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='物流轨迹表';
+
+
+-- ============================================
+-- 5. 售后单表
+-- 一个售后单对应一条订单明细，同一明细未被拒绝的售后单累计数量不超过购买数量
+-- 退款与库存回补以售后单号作为幂等键
+-- 对应 Go 模型：internal/order-service/model/after_sale.go
+-- ============================================
+CREATE TABLE IF NOT EXISTS after_sales (
+    id VARCHAR(26) NOT NULL PRIMARY KEY COMMENT '主键ID（ULID）',
+    after_sale_no VARCHAR(32) NOT NULL COMMENT '售后单号',
+    order_no VARCHAR(32) NOT NULL COMMENT '订单号',
+    order_item_id VARCHAR(26) NOT NULL COMMENT '订单明细ID',
+    user_id VARCHAR(26) NOT NULL COMMENT '用户ID',
+    sku_id VARCHAR(26) NOT NULL COMMENT 'SKU ID',
+
+    type TINYINT NOT NULL COMMENT '售后类型：1-仅退款，2-退货退款，3-换货',
+    status TINYINT NOT NULL DEFAULT 1 COMMENT '售后状态：1-已提交，2-已同意，3-买家已寄回，4-商家已收货，5-退款中，6-已退款，7-已拒绝，8-已完成',
+    quantity INT NOT NULL COMMENT '售后数量',
+    reason VARCHAR(255) NOT NULL COMMENT '申请原因',
+    evidence_images JSON COMMENT '凭证图片（JSON数组）',
+    refund_amount DECIMAL(10, 2) NOT NULL DEFAULT 0 COMMENT '申请退款金额',
+    refund_no VARCHAR(32) COMMENT '退款单号',
+
+    return_carrier VARCHAR(32) COMMENT '退货物流公司',
+    return_tracking_no VARCHAR(64) COMMENT '退货物流单号',
+
+    audit_remark VARCHAR(255) COMMENT '审核备注（拒绝原因）',
+    operator_id VARCHAR(26) COMMENT '最近一次处理的管理员ID',
+    approved_at TIMESTAMP NULL DEFAULT NULL COMMENT '审核通过时间',
+    returned_at TIMESTAMP NULL DEFAULT NULL COMMENT '买家寄回时间',
+    received_at TIMESTAMP NULL DEFAULT NULL COMMENT '商家收货时间',
+    refunded_at TIMESTAMP NULL DEFAULT NULL COMMENT '退款成功时间',
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    version INT NOT NULL DEFAULT 0 COMMENT '版本号',
+
+    UNIQUE KEY uk_after_sale_no (after_sale_no),
+    INDEX idx_order_no (order_no),
+    INDEX idx_order_item_id (order_item_id),
+    INDEX idx_user_id (user_id),
+    INDEX idx_status (status)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='售后单表';
+
+
+-- ============================================
+-- 6. 秒杀活动表
+-- 活动创建时以活动编号为幂等键从库存服务预扣活动库存，并预热到 Redis
+-- 活动结束后按 Redis 剩余名额将未售出库存归还库存服务（同样以活动编号为幂等键）
+-- 对应 Go 模型：internal/order-service/model/seckill.go
+-- ============================================
+CREATE TABLE IF NOT EXISTS seckill_activities (
+    id VARCHAR(26) NOT NULL PRIMARY KEY COMMENT '主键ID（ULID）',
+    activity_no VARCHAR(32) NOT NULL COMMENT '活动编号',
+    title VARCHAR(100) NOT NULL COMMENT '活动标题',
+    product_id VARCHAR(26) NOT NULL COMMENT '商品ID',
+    sku_id VARCHAR(26) NOT NULL COMMENT 'SKU ID',
+    seckill_price DECIMAL(10, 2) NOT NULL COMMENT '秒杀价',
+    stock INT NOT NULL COMMENT '活动库存',
+    warehouse_id VARCHAR(26) COMMENT '预扣库存的仓库ID（秒杀订单由此仓发货）',
+    sold_count INT NOT NULL DEFAULT 0 COMMENT '已售数量（对账后写入）',
+    status TINYINT NOT NULL DEFAULT 1 COMMENT '活动状态：1-已上线，2-已对账',
+
+    start_time TIMESTAMP NOT NULL COMMENT '开始时间',
+    end_time TIMESTAMP NOT NULL COMMENT '结束时间',
+    settled_at TIMESTAMP NULL DEFAULT NULL COMMENT '库存对账时间',
+    operator_id VARCHAR(26) COMMENT '创建活动的管理员ID',
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+
+    UNIQUE KEY uk_activity_no (activity_no),
+    INDEX idx_sku_id (sku_id),
+    INDEX idx_status_end (status, end_time) COMMENT '用于扫描已结束待对账的活动'
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='秒杀活动表';
+
+
+-- ============================================
+-- 7. 订单状态流转历史表
+-- 订单每次状态流转（由订单状态机触发）写入一行，与订单状态更新在同一事务中
+-- 记录触发事件、操作人、原因与 Trace ID，用于排查订单为何被取消 / 关闭 / 退款
+-- 对应 Go 模型：internal/order-service/model/order_status_history.go
+-- ============================================
+CREATE TABLE IF NOT EXISTS order_status_history (
+    id VARCHAR(26) NOT NULL PRIMARY KEY COMMENT '主键ID（ULID）',
+    order_no VARCHAR(32) NOT NULL COMMENT '订单号',
+    from_status TINYINT NOT NULL COMMENT '流转前状态',
+    to_status TINYINT NOT NULL COMMENT '流转后状态',
+    event VARCHAR(32) NOT NULL COMMENT '触发事件：pay/cancel/timeout/follow_parent/ship/complete/refund_apply/refund_succeed/refund_fail',
+    operator VARCHAR(32) NOT NULL COMMENT '操作人（用户ID或system）',
+    reason VARCHAR(255) COMMENT '流转原因',
+    trace_id VARCHAR(64) COMMENT '请求 Trace ID',
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+
+    INDEX idx_order_no (order_no)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='订单状态流转历史表';
+
+
+-- ============================================
+-- 8. 订单发票表
+-- 下单时选择发票抬头后按开票订单（拆单时为子订单）生成，抬头为下单时的快照
+-- 订单完成后可开票，由管理员登记发票号码；开票前取消 / 关闭 / 退款作废，开票后退款红冲
+-- 已开票后部分退款：原发票红冲，按剩余金额生成新的发票记录
+-- 对应 Go 模型：internal/order-service/model/invoice.go
+-- ============================================
+CREATE TABLE IF NOT EXISTS order_invoices (
+    id VARCHAR(26) NOT NULL PRIMARY KEY COMMENT '主键ID（ULID）',
+    order_no VARCHAR(32) NOT NULL COMMENT '开票订单号（拆单时为子订单号）',
+    pay_order_no VARCHAR(32) NOT NULL COMMENT '支付订单号（拆单时为主订单号）',
+    user_id VARCHAR(26) NOT NULL COMMENT '用户ID',
+
+    title_type TINYINT NOT NULL COMMENT '抬头类型：1-个人，2-企业',
+    title VARCHAR(100) NOT NULL COMMENT '抬头名称',
+    tax_no VARCHAR(20) COMMENT '纳税人识别号',
+    email VARCHAR(100) COMMENT '接收电子发票的邮箱',
+    amount DECIMAL(10, 2) NOT NULL COMMENT '开票金额',
+
+    status TINYINT NOT NULL DEFAULT 1 COMMENT '发票状态：1-待开票，2-可开票，3-已开票，4-已红冲，5-已作废',
+    invoice_number VARCHAR(32) COMMENT '发票号码',
+    operator_id VARCHAR(26) COMMENT '开票管理员ID',
+    remark VARCHAR(255) COMMENT '最近一次状态变更说明',
+    issued_at TIMESTAMP NULL DEFAULT NULL COMMENT '开票时间',
+    red_flushed_at TIMESTAMP NULL DEFAULT NULL COMMENT '红冲时间',
+    voided_at TIMESTAMP NULL DEFAULT NULL COMMENT '作废时间',
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    version INT NOT NULL DEFAULT 0 COMMENT '版本号',
+
+    INDEX idx_order_no (order_no),
+    INDEX idx_pay_order_no (pay_order_no),
+    INDEX idx_user_id (user_id),
+    INDEX idx_status (status)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='订单发票表';
+
+
+-- ============================================
+-- 9. 商品评价表
+-- 订单完成后买家按订单明细评价（每个明细一次），提交后待审核
+-- 审核通过的评价对外展示并计入商品评分，评分汇总推送到商品服务写入搜索索引
+-- 对应 Go 模型：internal/order-service/model/review.go
+-- ============================================
+CREATE TABLE IF NOT EXISTS product_reviews (
+    id VARCHAR(26) NOT NULL PRIMARY KEY COMMENT '主键ID（ULID）',
+    order_no VARCHAR(32) NOT NULL COMMENT '订单号（拆单时为子订单号）',
+    order_item_id VARCHAR(26) NOT NULL COMMENT '订单明细ID',
+    user_id VARCHAR(26) NOT NULL COMMENT '用户ID',
+    product_id VARCHAR(26) NOT NULL COMMENT '商品ID',
+    sku_id VARCHAR(26) NOT NULL COMMENT 'SKU ID',
+    sku_name VARCHAR(100) COMMENT 'SKU 名称快照',
+
+    rating TINYINT NOT NULL COMMENT '评分 1~5',
+    content VARCHAR(1000) COMMENT '评价内容',
+    images JSON COMMENT '晒图URL列表（JSON数组）',
+    is_anonymous TINYINT(1) NOT NULL DEFAULT 0 COMMENT '是否匿名',
+
+    status TINYINT NOT NULL DEFAULT 1 COMMENT '审核状态：1-待审核，2-审核通过，3-审核驳回',
+    moderation_remark VARCHAR(255) COMMENT '审核说明',
+    moderator_id VARCHAR(26) COMMENT '审核管理员ID',
+    moderated_at TIMESTAMP NULL DEFAULT NULL COMMENT '审核时间',
+
+    reply VARCHAR(1000) COMMENT '商家回复',
+    replier_id VARCHAR(26) COMMENT '回复人ID',
+    replied_at TIMESTAMP NULL DEFAULT NULL COMMENT '回复时间',
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    version INT NOT NULL DEFAULT 0 COMMENT '版本号',
+
+    UNIQUE KEY uk_order_item_id (order_item_id),
+    INDEX idx_order_no (order_no),
+    INDEX idx_user_id (user_id),
+    INDEX idx_product_status (product_id, status),
+    INDEX idx_status (status)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='商品评价表';
+
+
+-- ============================================
+-- 10. 订单优惠占用表
+-- 下单向促销服务锁定优惠（核销优惠券 / 占用促销配额）前写入待绑定记录，与订单在同一事务中绑定
+-- 长时间未绑定（订单未创建）的记录由补偿任务按订单号归还优惠
+-- 对应 Go 模型：internal/order-service/model/order_discount_claim.go
+-- ============================================
+CREATE TABLE IF NOT EXISTS order_discount_claims (
+    id VARCHAR(26) NOT NULL PRIMARY KEY COMMENT '主键ID（ULID）',
+    order_no VARCHAR(32) NOT NULL COMMENT '订单号（拆单时为主订单号）',
+    user_id VARCHAR(26) NOT NULL COMMENT '用户ID',
+    coupon_id VARCHAR(26) COMMENT '使用的优惠券ID（仅使用促销时为空）',
+    status TINYINT NOT NULL DEFAULT 1 COMMENT '状态：1-待绑定，2-已绑定，3-已归还',
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+
+    UNIQUE KEY uk_order_no (order_no),
+    INDEX idx_status_created (status, created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='订单优惠占用表';
@@ -36,6 +36,8 @@ CREATE TABLE IF NOT EXISTS payments (
     INDEX idx_status (status),
     INDEX idx_expired_at (expired_at),
     INDEX idx_status_expired (status, expired_at) COMMENT '用于定时任务扫描超时支付单',
+    INDEX idx_status_created (status, created_at) COMMENT '用于主动对账扫描长时间未完成的支付单',
+    INDEX idx_channel_paid (pay_channel, paid_at) COMMENT '用于对账单比对按渠道、支付时间查询',
     INDEX idx_created_at (created_at)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='支付单表';
 
@@ -51,7 +53,7 @@ CREATE TABLE IF NOT EXISTS payment_logs (
     order_no VARCHAR(32) NOT NULL COMMENT '订单号',
     user_id VARCHAR(26) COMMENT '用户ID',
     
-    action VARCHAR(50) NOT NULL COMMENT '操作类型：create-创建支付单，callback-支付回调，status_change-状态变更，close-关闭支付单',
+    action VARCHAR(50) NOT NULL COMMENT '操作类型：create-创建支付单，callback-支付回调，status_change-状态变更，close-关闭支付单，refund-申请退款，refund_callback-退款回调，callback_rejected-回调验签失败，reconcile-主动对账发现状态不一致，statement_mismatch-对账单比对发现差异，statement_resolve-对账差异处理',
     from_status TINYINT COMMENT '变更前状态',
     to_status TINYINT COMMENT '变更后状态',
     
@@ -89,12 +91,13 @@ CREATE TABLE IF NOT EXISTS refunds (
     payment_no VARCHAR(32) NOT NULL COMMENT '原支付单号',
     order_no VARCHAR(32) NOT NULL COMMENT '订单号',
     user_id VARCHAR(26) NOT NULL COMMENT '用户ID',
+    request_no VARCHAR(64) DEFAULT NULL COMMENT '业务请求号（幂等键，如售后单号）',
     
     refund_amount DECIMAL(10, 2) NOT NULL DEFAULT 0 COMMENT '退款金额',
     refund_reason VARCHAR(255) COMMENT '退款原因',
     refund_type TINYINT NOT NULL DEFAULT 1 COMMENT '退款类型：1-全额退款，2-部分退款',
     
-    pay_channel VARCHAR(20) NOT NULL COMMENT '原支付渠道：wechat-微信支付，alipay-支付宝',
+    pay_channel VARCHAR(20) NOT NULL COMMENT '原支付渠道：wechat-微信支付，alipay-支付宝，balance-余额支付',
     status TINYINT NOT NULL DEFAULT 1 COMMENT '退款状态：1-退款中，2-退款成功，3-退款失败，4-已取消',
     
     trade_no VARCHAR(64) COMMENT '原支付交易号',
@@ -113,6 +116,7 @@ CREATE TABLE IF NOT EXISTS refunds (
     INDEX idx_order_no (order_no),
     INDEX idx_user_id (user_id),
     INDEX idx_refund_trade_no (refund_trade_no),
+    INDEX idx_order_request (order_no, request_no),
     INDEX idx_status (status),
     INDEX idx_created_at (created_at)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='退款单表';
@@ -155,3 +159,83 @@ CREATE TABLE IF NOT EXISTS payment_channels (
     UNIQUE KEY uk_channel_env (channel_code, environment)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='支付渠道配置表';
 
+
+-- ============================================
+-- 5. 对账差异表
+-- 导入渠道对账单后与本地支付单逐笔比对，记录不一致的交易
+-- 对应 Go 模型：internal/payment-service/model/reconciliation.go
+-- ============================================
+CREATE TABLE IF NOT EXISTS reconciliation_results (
+    id VARCHAR(26) NOT NULL PRIMARY KEY COMMENT '主键ID（ULID）',
+    pay_channel VARCHAR(20) NOT NULL COMMENT '支付渠道',
+    bill_date VARCHAR(10) NOT NULL COMMENT '账单日期（YYYY-MM-DD）',
+    mismatch_type TINYINT NOT NULL COMMENT '差异类型：1-本地缺失，2-渠道缺失，3-金额不一致，4-状态不一致',
+    trade_no VARCHAR(64) NOT NULL DEFAULT '' COMMENT '第三方交易号',
+    payment_no VARCHAR(32) NOT NULL DEFAULT '' COMMENT '支付单号（本地缺失时为空）',
+    order_no VARCHAR(32) NOT NULL DEFAULT '' COMMENT '订单号',
+
+    local_amount DECIMAL(10, 2) NOT NULL DEFAULT 0 COMMENT '本地支付金额',
+    local_status TINYINT NOT NULL DEFAULT 0 COMMENT '本地支付状态（本地缺失时为0）',
+    channel_amount DECIMAL(10, 2) NOT NULL DEFAULT 0 COMMENT '渠道账单金额',
+    channel_status VARCHAR(32) NOT NULL DEFAULT '' COMMENT '渠道账单交易状态（渠道缺失时为空）',
+    channel_trade_time TIMESTAMP NULL DEFAULT NULL COMMENT '渠道账单交易时间',
+
+    status TINYINT NOT NULL DEFAULT 1 COMMENT '处理状态：1-待处理，2-已处理，3-已忽略',
+    resolve_remark VARCHAR(255) COMMENT '处理说明',
+    resolved_by VARCHAR(26) COMMENT '处理人ID',
+    resolved_at TIMESTAMP NULL DEFAULT NULL COMMENT '处理时间',
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    version INT NOT NULL DEFAULT 0 COMMENT '版本号（乐观锁）',
+
+    UNIQUE KEY uk_reconciliation_item (pay_channel, bill_date, mismatch_type, trade_no, payment_no) COMMENT '重复导入同一账单不产生重复差异',
+    INDEX idx_bill_date (bill_date),
+    INDEX idx_payment_no (payment_no),
+    INDEX idx_status (status)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='对账差异表';
+
+
+-- ============================================
+-- 6. 钱包表
+-- 余额支付渠道的用户余额，所有变动必须伴随钱包流水
+-- 对应 Go 模型：internal/payment-service/model/wallet.go
+-- ============================================
+CREATE TABLE IF NOT EXISTS wallets (
+    id VARCHAR(26) NOT NULL PRIMARY KEY COMMENT '主键ID（ULID）',
+    user_id VARCHAR(26) NOT NULL COMMENT '用户ID',
+    balance DECIMAL(12, 2) NOT NULL DEFAULT 0 COMMENT '可用余额',
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    version INT NOT NULL DEFAULT 0 COMMENT '版本号',
+
+    UNIQUE KEY uk_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='钱包表';
+
+
+-- ============================================
+-- 7. 钱包流水表（复式记账）
+-- 每笔业务产生借、贷两行金额相等的流水，同一业务单号的同一方向只允许记账一次
+-- 对应 Go 模型：internal/payment-service/model/wallet.go
+-- ============================================
+CREATE TABLE IF NOT EXISTS wallet_ledgers (
+    id VARCHAR(26) NOT NULL PRIMARY KEY COMMENT '主键ID（ULID）',
+    tx_no VARCHAR(32) NOT NULL COMMENT '记账流水号（同一笔业务的借贷两行相同）',
+    biz_type VARCHAR(20) NOT NULL COMMENT '业务类型：topup-充值，payment-余额支付，refund-退款到余额',
+    biz_no VARCHAR(64) NOT NULL COMMENT '业务单号（充值单号/支付单号/退款单号）',
+    direction TINYINT NOT NULL COMMENT '记账方向：1-借，2-贷',
+    account VARCHAR(40) NOT NULL COMMENT '记账账户：user:{用户ID}、system:topup、system:settlement',
+    user_id VARCHAR(26) NOT NULL COMMENT '用户ID',
+    amount DECIMAL(12, 2) NOT NULL COMMENT '金额',
+    balance_after DECIMAL(12, 2) NOT NULL DEFAULT 0 COMMENT '变动后余额（仅用户钱包账户）',
+    remark VARCHAR(255) COMMENT '备注',
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+
+    UNIQUE KEY uk_wallet_ledger_entry (biz_type, biz_no, direction) COMMENT '同一业务单号不重复记账',
+    INDEX idx_tx_no (tx_no),
+    INDEX idx_account (account, created_at),
+    INDEX idx_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='钱包流水表';
@@ -100,6 +100,8 @@ CREATE TABLE IF NOT EXISTS products (
     id VARCHAR(26) NOT NULL PRIMARY KEY,
     category_id VARCHAR(26) NOT NULL COMMENT '所属类目ID',
     brand_id VARCHAR(26) NOT NULL COMMENT '品牌ID',
+    merchant_id VARCHAR(26) COMMENT '所属商家ID（为空表示平台自营，下单时按商家拆单）',
+    freight_template_id VARCHAR(26) COMMENT '运费模板ID（为空时使用默认模板）',
     title VARCHAR(200) NOT NULL COMMENT '商品标题',
     subtitle VARCHAR(200) COMMENT '商品副标题/卖点',
     main_image VARCHAR(255) NOT NULL COMMENT '主图URL',
@@ -115,6 +117,8 @@ CREATE TABLE IF NOT EXISTS products (
     INDEX idx_category_status_shelf (category_id, status, on_shelf_time),
     -- 按品牌查询已上架商品
     INDEX idx_brand_status_shelf (brand_id, status, on_shelf_time),
+    -- 按商家查询商品
+    INDEX idx_merchant_id (merchant_id),
     -- 定时上架任务：查询待上架且到时间的商品
     INDEX idx_status_shelf_time (status, on_shelf_time),
     -- 后台管理：按状态和时间排序
@@ -140,6 +144,7 @@ CREATE TABLE IF NOT EXISTS skus (
     volume DECIMAL(10, 2) COMMENT '体积（单位：m³）',
     image VARCHAR(255) COMMENT 'SKU图片（如不同颜色对应不同图片）',
     status TINYINT DEFAULT 1 COMMENT '状态：1-上架，2-下架，3-禁用',
+    sold_out TINYINT(1) NOT NULL DEFAULT 0 COMMENT '是否售罄（由库存服务的库存事件维护）',
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     deleted_at TIMESTAMP NULL COMMENT '软删除时间',
@@ -233,3 +238,51 @@ CREATE TABLE IF NOT EXISTS operation_logs (
     INDEX idx_created_at (created_at)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='操作日志表';
 
+-- ============================================
+-- 13. 运费模板表
+-- ============================================
+CREATE TABLE IF NOT EXISTS freight_templates (
+    id VARCHAR(26) NOT NULL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL COMMENT '模板名称',
+    charge_type TINYINT NOT NULL DEFAULT 1 COMMENT '计费方式：1-按件，2-按重量（kg），3-按体积（m³）',
+    is_default TINYINT(1) NOT NULL DEFAULT 0 COMMENT '是否默认模板（商品未指定模板时使用）',
+    status TINYINT NOT NULL DEFAULT 1 COMMENT '状态：1-启用，2-停用',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP NULL COMMENT '软删除时间',
+    INDEX idx_default_status (is_default, status),
+    INDEX idx_deleted_at (deleted_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='运费模板表';
+
+-- ============================================
+-- 14. 运费计费规则表（按地区配置首件/续件价格和包邮门槛）
+-- ============================================
+CREATE TABLE IF NOT EXISTS freight_rules (
+    id VARCHAR(26) NOT NULL PRIMARY KEY,
+    template_id VARCHAR(26) NOT NULL COMMENT '运费模板ID',
+    regions TEXT COMMENT '适用地区（JSON数组，"省" 或 "省/市"，为空表示默认规则）',
+    first_unit DECIMAL(10, 2) NOT NULL DEFAULT 1 COMMENT '首件/首重/首体积',
+    first_fee DECIMAL(10, 2) NOT NULL DEFAULT 0 COMMENT '首费',
+    extra_unit DECIMAL(10, 2) NOT NULL DEFAULT 1 COMMENT '续件/续重/续体积',
+    extra_fee DECIMAL(10, 2) NOT NULL DEFAULT 0 COMMENT '续费',
+    free_threshold DECIMAL(10, 2) NOT NULL DEFAULT 0 COMMENT '包邮门槛（商品金额达到该值免运费，0表示不包邮）',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_template_id (template_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='运费计费规则表';
+
+-- ============================================
+-- 15. 商品评分汇总表（由订单服务按审核通过的评价计算后推送）
+-- ============================================
+CREATE TABLE IF NOT EXISTS product_ratings (
+    product_id VARCHAR(26) NOT NULL PRIMARY KEY COMMENT '商品ID',
+    rating_avg DECIMAL(3, 1) NOT NULL DEFAULT 0 COMMENT '平均评分',
+    review_count INT NOT NULL DEFAULT 0 COMMENT '评价总数',
+    star1_count INT NOT NULL DEFAULT 0 COMMENT '1星评价数',
+    star2_count INT NOT NULL DEFAULT 0 COMMENT '2星评价数',
+    star3_count INT NOT NULL DEFAULT 0 COMMENT '3星评价数',
+    star4_count INT NOT NULL DEFAULT 0 COMMENT '4星评价数',
+    star5_count INT NOT NULL DEFAULT 0 COMMENT '5星评价数',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='商品评分汇总表';
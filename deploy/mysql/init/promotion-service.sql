@@ -91,7 +91,7 @@ CREATE TABLE IF NOT EXISTS coupons (
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     INDEX idx_user_status (user_id, status),
-    INDEX idx_template_id (template_id),
+    INDEX idx_template_user (template_id, user_id),
     INDEX idx_user_valid_time (user_id, valid_start_time, valid_end_time),
     INDEX idx_status_valid_time (status, valid_end_time)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='优惠券实例表';
\ No newline at end of file
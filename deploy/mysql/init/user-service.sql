@@ -31,4 +31,18 @@ CREATE TABLE IF NOT EXISTS addresses (
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     INDEX idx_user_default (user_id, is_default)
-) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='收货地址表';
\ No newline at end of file
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='收货地址表';
+
+-- 发票抬头表
+CREATE TABLE IF NOT EXISTS invoice_titles (
+    id VARCHAR(26) NOT NULL PRIMARY KEY,
+    user_id VARCHAR(26) NOT NULL COMMENT '用户ID',
+    title_type TINYINT NOT NULL COMMENT '抬头类型：1-个人，2-企业',
+    title VARCHAR(100) NOT NULL COMMENT '抬头名称',
+    tax_no VARCHAR(20) COMMENT '纳税人识别号（企业必填）',
+    email VARCHAR(100) COMMENT '接收电子发票的邮箱',
+    is_default TINYINT(1) DEFAULT 0 COMMENT '是否默认：0-否，1-是',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_user_default (user_id, is_default)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='发票抬头表';
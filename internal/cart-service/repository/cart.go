@@ -48,6 +48,11 @@ type CartRepository interface {
 
 	// 根据用户ID和SKU ID查找购物车项（用于判断是否已存在相同SKU）
 	GetCartItemByUserAndSKU(ctx context.Context, userID string, skuID string) (*model.CartItem, error)
+
+	// 按 SKU 批量设置购物车项有效性（库存事件驱动），返回更新的购物车项数量
+	// valid 为 false 时将该 SKU 的有效购物车项标记为失效（失效原因为 reason）；
+	// valid 为 true 时只恢复失效原因为 reason 的购物车项，其他原因导致的失效保持不变
+	SetItemsValidityBySKU(ctx context.Context, skuID string, valid bool, reason string) (int64, error)
 }
 
 type cartRepository struct {
@@ -325,6 +330,66 @@ func (r *cartRepository) ItemExists(ctx context.Context, userID string, itemID s
 	return exists, nil
 }
 
+// SetItemsValidityBySKU 按 SKU 批量设置购物车项有效性
+// 以 MySQL 为准筛选购物车项并更新，再刷新 Redis 中已缓存的购物车项（只修改有效性字段，不覆盖缓存中较新的数量等数据）
+func (r *cartRepository) SetItemsValidityBySKU(ctx context.Context, skuID string, valid bool, reason string) (int64, error) {
+	query := r.db.WithContext(ctx).Model(&model.CartItem{}).Where("sku_id = ?", skuID)
+	if valid {
+		query = query.Where("is_valid = ? AND invalid_reason = ?", false, reason)
+	} else {
+		query = query.Where("is_valid = ?", true)
+	}
+
+	var items []*model.CartItem
+	if err := query.Select("id", "user_id").Find(&items).Error; err != nil {
+		log.Printf("❌ [Repository] SetItemsValidityBySKU: 查询 MySQL 失败 - sku_id=%s, error=%v", skuID, err)
+		return 0, fmt.Errorf("查询购物车项失败: %w", err)
+	}
+	if len(items) == 0 {
+		return 0, nil
+	}
+
+	invalidReason := reason
+	if valid {
+		invalidReason = ""
+	}
+	itemIDs := make([]string, 0, len(items))
+	for _, item := range items {
+		itemIDs = append(itemIDs, item.ID)
+	}
+	res := r.db.WithContext(ctx).Model(&model.CartItem{}).
+		Where("id IN ?", itemIDs).
+		Updates(map[string]interface{}{
+			"is_valid":       valid,
+			"invalid_reason": invalidReason,
+		})
+	if res.Error != nil {
+		log.Printf("❌ [Repository] SetItemsValidityBySKU: 更新 MySQL 失败 - sku_id=%s, error=%v", skuID, res.Error)
+		return 0, fmt.Errorf("更新购物车项失败: %w", res.Error)
+	}
+
+	for _, item := range items {
+		itemJSON, err := r.redisClient.Get(ctx, fmt.Sprintf(CacheKeyCartItem, item.ID)).Result()
+		if err != nil {
+			// 未缓存的购物车项下次读取时从 MySQL 加载
+			continue
+		}
+		var cached model.CartItem
+		if err := json.Unmarshal([]byte(itemJSON), &cached); err != nil {
+			continue
+		}
+		if valid && cached.InvalidReason != reason {
+			continue
+		}
+		cached.IsValid = valid
+		cached.InvalidReason = invalidReason
+		if err := r.setToCache(ctx, cached.UserID, &cached); err != nil {
+			log.Printf("⚠️ [Repository] SetItemsValidityBySKU: 刷新 Redis 失败 - item_id=%s, error=%v", item.ID, err)
+		}
+	}
+	return res.RowsAffected, nil
+}
+
 // ============================================
 // 私有辅助方法
 // ============================================
@@ -22,16 +22,18 @@ type CartService struct {
 	cartRepo        repository.CartRepository
 	productClient   client.ProductClient   // 商品服务客户端（用于查询商品信息）
 	inventoryClient client.InventoryClient // 库存服务客户端（用于库存校验）
+	userClient      client.UserClient      // 用户服务客户端（用于获取配送地址）
 	// TODO: 添加促销服务客户端（用于计算优惠）
 	// promotionClient promotionv1.PromotionServiceClient
 }
 
 // NewCartService 创建购物车服务实例
-func NewCartService(cartRepo repository.CartRepository, productClient client.ProductClient, inventoryClient client.InventoryClient) *CartService {
+func NewCartService(cartRepo repository.CartRepository, productClient client.ProductClient, inventoryClient client.InventoryClient, userClient client.UserClient) *CartService {
 	return &CartService{
 		cartRepo:        cartRepo,
 		productClient:   productClient,
 		inventoryClient: inventoryClient,
+		userClient:      userClient,
 	}
 }
 
@@ -429,8 +431,32 @@ func (s *CartService) CheckoutPreview(ctx context.Context, req *cartv1.CheckoutP
 	s.updateProductInfoForCheckout(ctx, selectedItems)
 
 	// TODO: 调用促销服务，计算促销优惠
-	// TODO: 调用用户服务，获取配送地址
-	// TODO: 计算运费
+
+	// 获取配送地址（未指定时使用默认地址），运费按收货地址计算
+	var address *cartv1.AddressInfo
+	var province, city string
+	if s.userClient != nil {
+		userAddress, err := s.userClient.GetUserAddress(ctx, req.AddressId)
+		if err != nil {
+			if req.AddressId != "" {
+				log.Printf("❌ [Service] CheckoutPreview: 获取配送地址失败 - user_id=%s, address_id=%s, error=%v", userID, req.AddressId, err)
+				return &cartv1.CheckoutPreviewResponse{
+					Code:    1,
+					Message: fmt.Sprintf("获取配送地址失败: %v", err),
+				}, nil
+			}
+			// 用户尚未设置默认地址，按默认运费规则预估
+			log.Printf("ℹ️ [Service] CheckoutPreview: 未找到默认地址，按默认运费规则预估 - user_id=%s, error=%v", userID, err)
+		} else {
+			province, city = userAddress.Province, userAddress.City
+			address = &cartv1.AddressInfo{
+				Id:            userAddress.Id,
+				ReceiverName:  userAddress.ReceiverName,
+				ReceiverPhone: userAddress.ReceiverPhone,
+				FullAddress:   fmt.Sprintf("%s%s%s%s", userAddress.Province, userAddress.City, userAddress.District, userAddress.Detail),
+			}
+		}
+	}
 
 	// 计算商品总金额（原价）
 	productTotal := s.calculateProductTotal(selectedItems)
@@ -455,19 +481,19 @@ func (s *CartService) CheckoutPreview(ctx context.Context, req *cartv1.CheckoutP
 	couponDiscount := "0.00"      // 临时值
 	var coupon *cartv1.CouponInfo // 临时值
 
-	// TODO: 计算运费（调用物流服务或根据规则计算）
-	shippingFee := "0.00" // 临时值
+	// 计算运费（与订单创建使用同一运费模板计算，保证预览运费与实际收取一致）
+	shippingFee, err := s.calculateShippingFee(ctx, selectedItems, province, city)
+	if err != nil {
+		log.Printf("❌ [Service] CheckoutPreview: 计算运费失败 - user_id=%s, error=%v", userID, err)
+		return &cartv1.CheckoutPreviewResponse{
+			Code:    1,
+			Message: fmt.Sprintf("运费计算失败: %v", err),
+		}, nil
+	}
 
 	// 计算最终实付金额
 	finalAmount := s.calculateFinalAmount(productTotal, promotionDiscount, couponDiscount, shippingFee)
 
-	// TODO: 获取配送地址信息
-	// address, err := s.getAddressInfo(ctx, userID, req.AddressId)
-	// if err != nil {
-	//     log.Printf("获取地址信息失败: %v", err)
-	// }
-	var address *cartv1.AddressInfo // 临时值
-
 	// 转换为 Proto 格式
 	protoItems := make([]*cartv1.CartItem, 0, len(selectedItems))
 	for _, item := range selectedItems {
@@ -552,6 +578,27 @@ func (s *CartService) calculateProductTotal(items []*model.CartItem) string {
 	return fmt.Sprintf("%.2f", total)
 }
 
+// calculateShippingFee 调用商品服务运费模板计算运费
+// 与订单创建共用按商家分组计算的逻辑：多商家购物车的运费为各商家（拆单后各子订单）运费之和
+func (s *CartService) calculateShippingFee(ctx context.Context, items []*model.CartItem, province, city string) (string, error) {
+	if s.productClient == nil {
+		return "0.00", nil
+	}
+	lines := make([]client.FreightLine, 0, len(items))
+	for _, item := range items {
+		lines = append(lines, client.FreightLine{
+			ProductID: item.ProductID,
+			SKUID:     item.SKUID,
+			Quantity:  item.Quantity,
+		})
+	}
+	_, fee, err := client.CalculateMerchantFreight(ctx, s.productClient, province, city, lines)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%.2f", fee), nil
+}
+
 // calculateFinalAmount 计算最终实付金额
 // 公式：最终金额 = 商品总价 - 促销优惠 - 优惠券优惠 + 运费
 func (s *CartService) calculateFinalAmount(productTotal, promotionDiscount, couponDiscount, shippingFee string) string {
@@ -0,0 +1,35 @@
+package service
+
+import (
+	"context"
+	"log"
+
+	"zjMall/internal/common/mq"
+)
+
+// CartItemInvalidReasonSoldOut 库存售罄导致的购物车项失效原因（补货后只恢复该原因失效的购物车项）
+const CartItemInvalidReasonSoldOut = "商品已售罄"
+
+// HandleStockEvent 处理库存服务发布的库存事件：售罄时将该 SKU 的购物车项标记为失效，补货后恢复
+func (s *CartService) HandleStockEvent(ctx context.Context, event *mq.StockEvent) error {
+	var valid bool
+	switch event.EventType {
+	case mq.StockEventOut:
+		valid = false
+	case mq.StockEventRestocked:
+		valid = true
+	case mq.StockEventLow:
+		// 低库存时购物车项仍可结算，结算预览会按实时库存校验数量
+		return nil
+	default:
+		log.Printf("⚠️ [Service] HandleStockEvent: 未知的库存事件类型，忽略 - event=%s, sku_id=%s", event.EventType, event.SKUID)
+		return nil
+	}
+
+	updated, err := s.cartRepo.SetItemsValidityBySKU(ctx, event.SKUID, valid, CartItemInvalidReasonSoldOut)
+	if err != nil {
+		return err
+	}
+	log.Printf("✅ [Service] HandleStockEvent: 已更新购物车项有效性 - event=%s, sku_id=%s, valid=%v, items=%d", event.EventType, event.SKUID, valid, updated)
+	return nil
+}
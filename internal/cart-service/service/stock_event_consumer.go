@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"zjMall/internal/common/mq"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// StartStockEventConsumer 启动库存事件消费者，根据 stock.out / stock.restocked 事件设置购物车项有效性
+// 队列需已通过 database.InitTopicExchange 绑定到库存事件交换机
+func StartStockEventConsumer(ctx context.Context, svc *CartService, ch *amqp.Channel, queue string) {
+	if ch == nil {
+		log.Println("⚠️ [CartStockConsumer] RabbitMQ Channel 为 nil，跳过消费者启动")
+		return
+	}
+	if svc == nil {
+		log.Println("⚠️ [CartStockConsumer] CartService 为 nil，跳过消费者启动")
+		return
+	}
+
+	// 公平分发，一次只投递一条未确认的消息给当前消费者
+	if err := ch.Qos(1, 0, false); err != nil {
+		log.Printf("⚠️ [CartStockConsumer] 设置 Qos 失败: %v", err)
+	}
+
+	msgs, err := ch.Consume(
+		queue,
+		"cart-service-stock-consumer", // consumer
+		false,                         // autoAck
+		false,                         // exclusive
+		false,                         // noLocal
+		false,                         // noWait
+		nil,                           // args
+	)
+	if err != nil {
+		log.Printf("❌ [CartStockConsumer] 启动消费者失败: %v", err)
+		return
+	}
+
+	log.Printf("✅ [CartStockConsumer] 已启动，正在消费库存事件队列: %s", queue)
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				log.Println("ℹ️ [CartStockConsumer] 上下文已取消，退出消费者循环")
+				return
+			case msg, ok := <-msgs:
+				if !ok {
+					log.Println("⚠️ [CartStockConsumer] 消息通道已关闭，退出消费者循环")
+					return
+				}
+
+				var evt mq.StockEvent
+				if err := json.Unmarshal(msg.Body, &evt); err != nil {
+					log.Printf("❌ [CartStockConsumer] 解析 StockEvent 失败，丢弃消息: %v, body=%s", err, string(msg.Body))
+					_ = msg.Nack(false, false)
+					continue
+				}
+
+				if err := svc.HandleStockEvent(ctx, &evt); err != nil {
+					log.Printf("❌ [CartStockConsumer] 处理库存事件失败，将重回队列: event=%s, sku_id=%s, err=%v", evt.EventType, evt.SKUID, err)
+					_ = msg.Nack(false, true)
+					time.Sleep(100 * time.Millisecond)
+					continue
+				}
+
+				_ = msg.Ack(false)
+				log.Printf("✅ [CartStockConsumer] 库存事件处理完成，event=%s, sku_id=%s", evt.EventType, evt.SKUID)
+			}
+		}
+	}()
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	productv1 "zjMall/gen/go/api/proto/product"
+)
+
+// FreightLine 参与运费计算的商品行
+type FreightLine struct {
+	MerchantID string // 商品所属商家（为空时按 ProductID 查询商品服务）
+	ProductID  string
+	SKUID      string
+	Quantity   int32
+}
+
+// MerchantFreight 单个商家的商品行及运费（多商家订单拆单后每个子订单对应一个商家）
+type MerchantFreight struct {
+	MerchantID  string
+	Lines       []int // 商品行在 lines 中的下标，保持原有先后顺序
+	ShippingFee float64
+}
+
+// CalculateMerchantFreight 按商家分组计算运费，返回各商家运费（按商家首次出现的顺序）及运费合计
+// 购物车结算预览与订单创建共用，保证多商家订单的报价与实收（各子订单运费之和）一致
+func CalculateMerchantFreight(ctx context.Context, productClient ProductClient, province, city string, lines []FreightLine) ([]*MerchantFreight, float64, error) {
+	merchants := make(map[string]string) // product_id -> merchant_id
+	var groups []*MerchantFreight
+	index := make(map[string]*MerchantFreight)
+	for i, line := range lines {
+		merchantID := line.MerchantID
+		if merchantID == "" {
+			var err error
+			merchantID, err = productMerchant(ctx, productClient, merchants, line.ProductID)
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+		group, ok := index[merchantID]
+		if !ok {
+			group = &MerchantFreight{MerchantID: merchantID}
+			index[merchantID] = group
+			groups = append(groups, group)
+		}
+		group.Lines = append(group.Lines, i)
+	}
+
+	var total float64
+	for _, group := range groups {
+		items := make([]*productv1.FreightItem, 0, len(group.Lines))
+		for _, i := range group.Lines {
+			items = append(items, &productv1.FreightItem{
+				SkuId:    lines[i].SKUID,
+				Quantity: lines[i].Quantity,
+			})
+		}
+		fee, err := productClient.CalculateFreight(ctx, province, city, items)
+		if err != nil {
+			return nil, 0, err
+		}
+		group.ShippingFee = fee
+		total += fee
+	}
+	return groups, total, nil
+}
+
+// productMerchant 查询商品所属商家（同一商品只查询一次）
+func productMerchant(ctx context.Context, productClient ProductClient, cache map[string]string, productID string) (string, error) {
+	if merchantID, ok := cache[productID]; ok {
+		return merchantID, nil
+	}
+	product, _, err := productClient.GetProduct(ctx, productID)
+	if err != nil {
+		return "", fmt.Errorf("查询商品所属商家失败: %w", err)
+	}
+	cache[productID] = product.MerchantId
+	return product.MerchantId, nil
+}
@@ -22,11 +22,19 @@ type InventoryClient interface {
 	// DeductStock 批量扣减库存（使用乐观锁，防止超卖，支持幂等性检查）
 	// orderID: 订单号，作为幂等键
 	// items: 需要扣减的 SKU 列表，批量操作在一个事务中完成
-	DeductStock(ctx context.Context, orderID string, items []*inventoryv1.SkuQuantity) error
-	// RollbackStock 批量回滚库存（订单取消/关闭时调用，使用乐观锁）
-	// orderID: 订单号，用于日志记录
+	// 返回分仓结果（按仓库优先级分仓）
+	DeductStock(ctx context.Context, orderID string, items []*inventoryv1.SkuQuantity) ([]*inventoryv1.StockAllocation, error)
+	// RollbackStock 批量回滚库存（秒杀名额归还、售后退货时调用）
+	// orderID: 订单号或售后单号，作为幂等键（同一单号同一 SKU 只回滚一次）
 	// items: 需要回滚的 SKU 列表，批量操作在一个事务中完成
 	RollbackStock(ctx context.Context, orderID string, items []*inventoryv1.SkuQuantity) error
+	// ReserveStock 下单锁定库存（可用库存转入锁定库存），orderID 作为幂等键
+	// receiverProvince: 收货省份，用于就近分仓（为空时按仓库优先级）；返回每个 SKU 的发货仓库
+	ReserveStock(ctx context.Context, orderID, receiverProvince string, items []*inventoryv1.SkuQuantity) ([]*inventoryv1.StockAllocation, error)
+	// ConfirmStock 支付成功后确认订单锁定的全部库存（扣除锁定库存），按订单号幂等
+	ConfirmStock(ctx context.Context, orderID string) error
+	// ReleaseStock 订单取消/超时关闭后释放锁定的全部库存（转回可用库存），按订单号幂等
+	ReleaseStock(ctx context.Context, orderID string) error
 	// Close 关闭连接
 	Close() error
 }
@@ -112,12 +120,12 @@ func (c *inventoryClient) BatchGetStock(ctx context.Context, skuIDs []string) (m
 
 // DeductStock 批量扣减库存（使用乐观锁，防止超卖，支持幂等性检查）
 // 批量操作在一个事务中完成，全部成功或全部失败
-func (c *inventoryClient) DeductStock(ctx context.Context, orderID string, items []*inventoryv1.SkuQuantity) error {
+func (c *inventoryClient) DeductStock(ctx context.Context, orderID string, items []*inventoryv1.SkuQuantity) ([]*inventoryv1.StockAllocation, error) {
 	if len(items) == 0 {
-		return fmt.Errorf("扣减项不能为空")
+		return nil, fmt.Errorf("扣减项不能为空")
 	}
 	if orderID == "" {
-		return fmt.Errorf("订单号不能为空")
+		return nil, fmt.Errorf("订单号不能为空")
 	}
 
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second) // 批量操作可能需要更长时间
@@ -128,15 +136,15 @@ func (c *inventoryClient) DeductStock(ctx context.Context, orderID string, items
 		Items:   items,
 	})
 	if err != nil {
-		return fmt.Errorf("调用库存服务扣减失败: %w", err)
+		return nil, fmt.Errorf("调用库存服务扣减失败: %w", err)
 	}
 	if resp.Code != 0 {
-		return fmt.Errorf("库存服务返回错误: code=%d, message=%s", resp.Code, resp.Message)
+		return nil, fmt.Errorf("库存服务返回错误: code=%d, message=%s", resp.Code, resp.Message)
 	}
-	return nil
+	return resp.Allocations, nil
 }
 
-// RollbackStock 批量回滚库存（订单取消/关闭时调用，使用乐观锁）
+// RollbackStock 批量回滚库存（秒杀名额归还、售后退货时调用）
 // 批量操作在一个事务中完成，部分失败会继续处理其他项
 func (c *inventoryClient) RollbackStock(ctx context.Context, orderID string, items []*inventoryv1.SkuQuantity) error {
 	if len(items) == 0 {
@@ -162,6 +170,71 @@ func (c *inventoryClient) RollbackStock(ctx context.Context, orderID string, ite
 	return nil
 }
 
+// ReserveStock 下单锁定库存
+// 批量操作在一个事务中完成，全部成功或全部失败
+func (c *inventoryClient) ReserveStock(ctx context.Context, orderID, receiverProvince string, items []*inventoryv1.SkuQuantity) ([]*inventoryv1.StockAllocation, error) {
+	if len(items) == 0 {
+		return nil, fmt.Errorf("锁定项不能为空")
+	}
+	if orderID == "" {
+		return nil, fmt.Errorf("订单号不能为空")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second) // 批量操作可能需要更长时间
+	defer cancel()
+
+	resp, err := c.client.ReserveStock(ctx, &inventoryv1.ReserveStockRequest{
+		OrderId:          orderID,
+		Items:            items,
+		ReceiverProvince: receiverProvince,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("调用库存服务锁定失败: %w", err)
+	}
+	if resp.Code != 0 {
+		return nil, fmt.Errorf("库存服务返回错误: code=%d, message=%s", resp.Code, resp.Message)
+	}
+	return resp.Allocations, nil
+}
+
+// ConfirmStock 确认订单锁定的库存
+func (c *inventoryClient) ConfirmStock(ctx context.Context, orderID string) error {
+	if orderID == "" {
+		return fmt.Errorf("订单号不能为空")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	resp, err := c.client.ConfirmStock(ctx, &inventoryv1.ConfirmStockRequest{OrderId: orderID})
+	if err != nil {
+		return fmt.Errorf("调用库存服务确认失败: %w", err)
+	}
+	if resp.Code != 0 {
+		return fmt.Errorf("库存服务返回错误: code=%d, message=%s", resp.Code, resp.Message)
+	}
+	return nil
+}
+
+// ReleaseStock 释放订单锁定的库存
+func (c *inventoryClient) ReleaseStock(ctx context.Context, orderID string) error {
+	if orderID == "" {
+		return fmt.Errorf("订单号不能为空")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	resp, err := c.client.ReleaseStock(ctx, &inventoryv1.ReleaseStockRequest{OrderId: orderID})
+	if err != nil {
+		return fmt.Errorf("调用库存服务释放失败: %w", err)
+	}
+	if resp.Code != 0 {
+		return fmt.Errorf("库存服务返回错误: code=%d, message=%s", resp.Code, resp.Message)
+	}
+	return nil
+}
+
 // Close 关闭连接
 func (c *inventoryClient) Close() error {
 	if c.conn != nil {
@@ -0,0 +1,107 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+	paymentv1 "zjMall/gen/go/api/proto/payment"
+	"zjMall/internal/common/middleware"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+)
+
+// PaymentClient 支付服务客户端接口
+type PaymentClient interface {
+	// CreateRefund 以订单所属用户的身份申请已审核的退款（服务间调用，如售后审核通过后退款）
+	// requestNo: 业务请求号，作为幂等键，同一订单同一请求号只会产生一笔有效退款
+	CreateRefund(ctx context.Context, userID, orderNo, refundAmount, refundReason, requestNo string) (*paymentv1.Refund, error)
+	// Close 关闭连接
+	Close() error
+}
+
+type paymentClient struct {
+	conn   *grpc.ClientConn
+	client paymentv1.PaymentServiceClient
+}
+
+// NewPaymentClient 创建支付服务客户端
+// addr: 支付服务 gRPC 地址，例如 "localhost:50057"
+func NewPaymentClient(addr string) (PaymentClient, error) {
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                30 * time.Second,
+			Timeout:             5 * time.Second,
+			PermitWithoutStream: false,
+		}),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := grpc.DialContext(ctx, addr, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("连接支付服务失败: %w", err)
+	}
+
+	client := paymentv1.NewPaymentServiceClient(conn)
+
+	log.Printf("✅ 支付服务客户端连接成功: %s", addr)
+
+	return &paymentClient{
+		conn:   conn,
+		client: client,
+	}, nil
+}
+
+// CreateRefund 申请退款
+func (c *paymentClient) CreateRefund(ctx context.Context, userID, orderNo, refundAmount, refundReason, requestNo string) (*paymentv1.Refund, error) {
+	if userID == "" || orderNo == "" {
+		return nil, fmt.Errorf("用户ID和订单号不能为空")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	// 服务间调用通过 user_id metadata 传递身份，并携带 internal_service 令牌标记退款已由调用方审核
+	authorization, err := middleware.InternalServiceAuthorization()
+	if err != nil {
+		return nil, err
+	}
+	md := metadata.New(map[string]string{
+		string(middleware.UserIDKey): userID,
+		"authorization":              authorization,
+	})
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	log.Printf("🔍 [PaymentClient] CreateRefund: orderNo=%s, amount=%s, requestNo=%s", orderNo, refundAmount, requestNo)
+
+	resp, err := c.client.CreateRefund(ctx, &paymentv1.CreateRefundRequest{
+		OrderNo:      orderNo,
+		RefundAmount: refundAmount,
+		RefundReason: refundReason,
+		RequestNo:    requestNo,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("调用支付服务失败: %w", err)
+	}
+	if resp.Code != 0 {
+		return nil, fmt.Errorf("支付服务返回错误: code=%d, message=%s", resp.Code, resp.Message)
+	}
+	if resp.Refund == nil {
+		return nil, fmt.Errorf("支付服务未返回退款单")
+	}
+	return resp.Refund, nil
+}
+
+// Close 关闭连接
+func (c *paymentClient) Close() error {
+	if c.conn != nil {
+		return c.conn.Close()
+	}
+	return nil
+}
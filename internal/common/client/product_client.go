@@ -6,10 +6,12 @@ import (
 	"log"
 	"time"
 	productv1 "zjMall/gen/go/api/proto/product"
+	"zjMall/internal/common/middleware"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
 )
 
 // ProductClient 商品服务客户端接口
@@ -18,6 +20,10 @@ type ProductClient interface {
 	// 返回商品信息和 SKU 列表
 	GetProduct(ctx context.Context, productID string) (*productv1.ProductInfo, []*productv1.SkuInfo, error)
 	// GetBatchProduct 批量获取商品详情（包含 SKU 列表）
+	// CalculateFreight 按收货地址计算运费（购物车结算预览与订单创建共用，保证报价与实收一致）
+	CalculateFreight(ctx context.Context, province, city string, items []*productv1.FreightItem) (float64, error)
+	// UpdateProductRating 推送商品评分汇总（ratingCounts 下标 0~4 依次为 1~5 星）
+	UpdateProductRating(ctx context.Context, productID string, ratingAvg float64, reviewCount int64, ratingCounts []int64) error
 	// Close 关闭连接
 	Close() error
 }
@@ -85,6 +91,56 @@ func (c *productClient) GetProduct(ctx context.Context, productID string) (*prod
 	return resp.Product, resp.Skus, nil
 }
 
+// CalculateFreight 计算运费
+func (c *productClient) CalculateFreight(ctx context.Context, province, city string, items []*productv1.FreightItem) (float64, error) {
+	if len(items) == 0 {
+		return 0, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	resp, err := c.client.CalculateFreight(ctx, &productv1.CalculateFreightRequest{
+		Province: province,
+		City:     city,
+		Items:    items,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("调用商品服务计算运费失败: %w", err)
+	}
+	if resp.Code != 0 {
+		return 0, fmt.Errorf("商品服务返回错误: code=%d, message=%s", resp.Code, resp.Message)
+	}
+	return resp.ShippingFee, nil
+}
+
+// UpdateProductRating 推送商品评分汇总
+func (c *productClient) UpdateProductRating(ctx context.Context, productID string, ratingAvg float64, reviewCount int64, ratingCounts []int64) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	// 评分汇总只允许服务间推送，携带 internal_service 令牌
+	authorization, err := middleware.InternalServiceAuthorization()
+	if err != nil {
+		return err
+	}
+	ctx = metadata.NewOutgoingContext(ctx, metadata.Pairs("authorization", authorization))
+
+	resp, err := c.client.UpdateProductRating(ctx, &productv1.UpdateProductRatingRequest{
+		ProductId:    productID,
+		RatingAvg:    ratingAvg,
+		ReviewCount:  reviewCount,
+		RatingCounts: ratingCounts,
+	})
+	if err != nil {
+		return fmt.Errorf("调用商品服务更新评分失败: %w", err)
+	}
+	if resp.Code != 0 {
+		return fmt.Errorf("商品服务返回错误: code=%d, message=%s", resp.Code, resp.Message)
+	}
+	return nil
+}
+
 // Close 关闭连接
 func (c *productClient) Close() error {
 	if c.conn != nil {
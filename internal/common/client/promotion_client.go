@@ -0,0 +1,183 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	promotionv1 "zjMall/gen/go/api/proto/promotion"
+	"zjMall/internal/common/middleware"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+)
+
+// PromotionClient 促销服务客户端接口
+type PromotionClient interface {
+	// CalculateDiscount 计算订单优惠（促销 + 优惠券），返回优惠明细及行级分摊
+	// promotionIDs 为空时由促销服务自动选择最优组合
+	CalculateDiscount(ctx context.Context, userID, couponID string, promotionIDs []string, items []*promotionv1.CartItem) (*promotionv1.DiscountDetail, error)
+	// ApplyOrderDiscount 锁定订单优惠（核销优惠券 + 占用促销配额），orderNo 作为幂等键
+	ApplyOrderDiscount(ctx context.Context, userID, orderNo, couponID string, orderAmount float64, promotions []*promotionv1.AppliedPromotion) error
+	// ReleaseOrderDiscount 释放订单优惠（订单取消/超时关闭时调用，幂等）
+	ReleaseOrderDiscount(ctx context.Context, userID, orderNo string) error
+	// Close 关闭连接
+	Close() error
+}
+
+type promotionClient struct {
+	conn   *grpc.ClientConn
+	client promotionv1.PromotionServiceClient
+}
+
+// NewPromotionClient 创建促销服务客户端
+// addr: 促销服务 gRPC 地址，例如 "localhost:50058"
+func NewPromotionClient(addr string) (PromotionClient, error) {
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                30 * time.Second, // 每30秒发送一次ping（降低频率）
+			Timeout:             5 * time.Second,  // ping超时时间
+			PermitWithoutStream: false,            // 只在有活跃流时发送ping
+		}),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := grpc.DialContext(ctx, addr, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("连接促销服务失败: %w", err)
+	}
+
+	client := promotionv1.NewPromotionServiceClient(conn)
+
+	log.Printf("✅ 促销服务客户端连接成功: %s", addr)
+
+	return &promotionClient{
+		conn:   conn,
+		client: client,
+	}, nil
+}
+
+// withUserMetadata 将 userID 透传到 gRPC metadata
+func (c *promotionClient) withUserMetadata(ctx context.Context, userID string) context.Context {
+	if userID == "" {
+		userID = middleware.GetUserIDFromContext(ctx)
+	}
+	if userID == "" {
+		return ctx
+	}
+	md := metadata.New(map[string]string{
+		string(middleware.UserIDKey): userID,
+	})
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// withInternalServiceMetadata 透传 userID 并携带 internal_service 令牌（锁定/释放订单优惠仅限服务间调用）
+func (c *promotionClient) withInternalServiceMetadata(ctx context.Context, userID string) (context.Context, error) {
+	authorization, err := middleware.InternalServiceAuthorization()
+	if err != nil {
+		return nil, err
+	}
+	if userID == "" {
+		userID = middleware.GetUserIDFromContext(ctx)
+	}
+	md := metadata.New(map[string]string{
+		"authorization": authorization,
+	})
+	if userID != "" {
+		md.Set(string(middleware.UserIDKey), userID)
+	}
+	return metadata.NewOutgoingContext(ctx, md), nil
+}
+
+// CalculateDiscount 计算订单优惠
+func (c *promotionClient) CalculateDiscount(ctx context.Context, userID, couponID string, promotionIDs []string, items []*promotionv1.CartItem) (*promotionv1.DiscountDetail, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	ctx = c.withUserMetadata(ctx, userID)
+
+	resp, err := c.client.CalculateDiscount(ctx, &promotionv1.CalculateDiscountRequest{
+		Items:        items,
+		UserId:       userID,
+		CouponId:     couponID,
+		PromotionIds: promotionIDs,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("调用促销服务失败: %w", err)
+	}
+	if resp.Code != 0 {
+		return nil, fmt.Errorf("促销服务返回错误: code=%d, message=%s", resp.Code, resp.Message)
+	}
+	if resp.Data == nil {
+		return nil, fmt.Errorf("促销服务返回数据为空")
+	}
+	return resp.Data, nil
+}
+
+// ApplyOrderDiscount 锁定订单优惠
+func (c *promotionClient) ApplyOrderDiscount(ctx context.Context, userID, orderNo, couponID string, orderAmount float64, promotions []*promotionv1.AppliedPromotion) error {
+	if orderNo == "" {
+		return fmt.Errorf("订单号不能为空")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	ctx, err := c.withInternalServiceMetadata(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.ApplyOrderDiscount(ctx, &promotionv1.ApplyOrderDiscountRequest{
+		OrderId:     orderNo,
+		UserId:      userID,
+		CouponId:    couponID,
+		OrderAmount: orderAmount,
+		Promotions:  promotions,
+	})
+	if err != nil {
+		return fmt.Errorf("调用促销服务锁定优惠失败: %w", err)
+	}
+	if resp.Code != 0 {
+		return fmt.Errorf("促销服务返回错误: code=%d, message=%s", resp.Code, resp.Message)
+	}
+	return nil
+}
+
+// ReleaseOrderDiscount 释放订单优惠
+func (c *promotionClient) ReleaseOrderDiscount(ctx context.Context, userID, orderNo string) error {
+	if orderNo == "" {
+		return fmt.Errorf("订单号不能为空")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	ctx, err := c.withInternalServiceMetadata(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.ReleaseOrderDiscount(ctx, &promotionv1.ReleaseOrderDiscountRequest{
+		OrderId: orderNo,
+		UserId:  userID,
+	})
+	if err != nil {
+		return fmt.Errorf("调用促销服务释放优惠失败: %w", err)
+	}
+	if resp.Code != 0 {
+		return fmt.Errorf("促销服务返回错误: code=%d, message=%s", resp.Code, resp.Message)
+	}
+	return nil
+}
+
+// Close 关闭连接
+func (c *promotionClient) Close() error {
+	if c.conn != nil {
+		return c.conn.Close()
+	}
+	return nil
+}
@@ -19,6 +19,8 @@ type UserClient interface {
 	// GetUserAddress 获取用户地址
 	// addressID 为空时返回默认地址，否则返回指定地址
 	GetUserAddress(ctx context.Context, addressID string) (*userv1.Address, error)
+	// GetInvoiceTitle 获取当前用户的发票抬头
+	GetInvoiceTitle(ctx context.Context, titleID string) (*userv1.InvoiceTitle, error)
 	// Close 关闭连接
 	Close() error
 }
@@ -93,6 +95,37 @@ func (c *userClient) GetUserAddress(ctx context.Context, addressID string) (*use
 	return resp.Data, nil
 }
 
+// GetInvoiceTitle 获取当前用户的发票抬头
+func (c *userClient) GetInvoiceTitle(ctx context.Context, titleID string) (*userv1.InvoiceTitle, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	userID := middleware.GetUserIDFromContext(ctx)
+	if userID == "" {
+		return nil, fmt.Errorf("无法获取用户ID，请确保已登录")
+	}
+	md := metadata.New(map[string]string{
+		string(middleware.UserIDKey): userID,
+	})
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	log.Printf("🔍 [UserClient] GetInvoiceTitle: userID=%s, titleID=%s", userID, titleID)
+
+	resp, err := c.client.GetInvoiceTitle(ctx, &userv1.GetInvoiceTitleRequest{
+		TitleId: titleID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("调用用户服务失败: %w", err)
+	}
+	if resp.Code != 0 {
+		return nil, fmt.Errorf("用户服务返回错误: code=%d, message=%s", resp.Code, resp.Message)
+	}
+	if resp.Data == nil {
+		return nil, fmt.Errorf("发票抬头不存在")
+	}
+	return resp.Data, nil
+}
+
 // Close 关闭连接
 func (c *userClient) Close() error {
 	if c.conn != nil {
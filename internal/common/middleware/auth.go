@@ -47,6 +47,9 @@ var publicPaths = []string{
 	"/healthz",                   // 健康检查
 	"/swagger/",                  // Swagger 文档
 	"/metrics",                   // Prometheus metrics 端点
+	"/api/v1/payments/callback",  // 支付回调（第三方平台调用，由签名校验保证安全）
+	"/api/v1/refunds/callback",   // 退款回调（第三方平台调用，由签名校验保证安全）
+	"/api/v1/shipments/webhook/", // 物流推送（物流公司调用，由签名校验保证安全）
 }
 
 // isPublicPath 检查路径是否在白名单中
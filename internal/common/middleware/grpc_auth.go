@@ -2,8 +2,10 @@ package middleware
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"strings"
+	"time"
 
 	"zjMall/pkg"
 
@@ -13,10 +15,25 @@ import (
 	"google.golang.org/grpc/status"
 )
 
+// internalServiceTokenTTL 服务间调用令牌有效期（每次调用重新签发）
+const internalServiceTokenTTL = time.Minute
+
+// InternalServiceAuthorization 签发服务间调用令牌（roles=internal_service），作为 authorization metadata 传递
+// 令牌使用各服务共享的 JWT 密钥签名，被调方通过 UnaryAuthInterceptor 校验后才认可 internal_service 角色
+func InternalServiceAuthorization() (string, error) {
+	token, _, err := pkg.GenerateJWTWithRoles(RoleInternalService, []string{RoleInternalService}, internalServiceTokenTTL)
+	if err != nil {
+		return "", fmt.Errorf("签发服务间调用令牌失败: %w", err)
+	}
+	return "Bearer " + token, nil
+}
+
 // UnaryAuthInterceptor 所有 gRPC Unary 的认证拦截器
 // 支持两种方式：
-// 1. 客户端调用：从 authorization header 获取 JWT token，验证后提取 userID
+// 1. 客户端调用：从 authorization header 获取 JWT token，验证后提取 userID 和角色
 // 2. 服务间调用：直接从 user_id metadata 获取 userID（信任内部服务）
+// 角色只从校验通过的 JWT 中获取（gRPC Gateway 会透传用户的 Authorization 头，服务间调用携带 InternalServiceAuthorization 签发的令牌），
+// 不信任调用方自行填写的 roles metadata
 func UnaryAuthInterceptor(
 	ctx context.Context,
 	req interface{},
@@ -32,43 +49,27 @@ func UnaryAuthInterceptor(
 	var userID string
 	var roles []string
 
-	// 优先检查是否有 user_id metadata（服务间调用）
+	// 从 authorization header 获取 JWT token，验证后提取 userID 和角色
+	authVals := md.Get("authorization")
+	if len(authVals) > 0 {
+		token := strings.TrimSpace(strings.TrimPrefix(authVals[0], "Bearer "))
+		if token != "" {
+			// 验证 JWT，获取 Claims（包含 userID 和 roles）
+			claims, err := pkg.VerifyJWTWithClaims(token)
+			if err != nil {
+				return nil, status.Error(codes.Unauthenticated, "Token 无效或已过期")
+			}
+			userID = claims.UserID
+			roles = claims.Roles
+			log.Printf("🔍 [gRPC Auth] 从 JWT token 验证获取: userID=%s, roles=%v", userID, roles)
+		}
+	}
+
+	// user_id metadata 优先（服务间调用代表用户发起，gRPC Gateway 也会传递已认证的 user_id）
 	userIDVals := md.Get(string(UserIDKey))
 	if len(userIDVals) > 0 && userIDVals[0] != "" {
-		// 服务间调用，直接使用 user_id
 		userID = userIDVals[0]
 		log.Printf("🔍 [gRPC Auth] 从 user_id metadata 获取: %s", userID)
-
-		// 服务间调用时，也尝试从 metadata 获取角色信息
-		rolesVals := md.Get(string(RolesKey))
-		if len(rolesVals) > 0 {
-			roles = rolesVals
-			log.Printf("🔍 [gRPC Auth] 从 roles metadata 获取: %v", roles)
-		}
-	} else {
-		// 客户端调用，从 authorization header 获取 JWT token
-		authVals := md.Get("authorization")
-		if len(authVals) == 0 || authVals[0] == "" {
-			// 没有 token，可以直接放行（由业务判断），也可以直接返回 Unauthenticated
-			// 这里建议：放行，后面业务用 GetUserIDFromContext 判空，返回"未登录"
-			return handler(ctx, req)
-		}
-
-		authHeader := authVals[0]
-		token := strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer "))
-		if token == "" {
-			return handler(ctx, req)
-		}
-
-		// 验证 JWT，获取 Claims（包含 userID 和 roles）
-		claims, err := pkg.VerifyJWTWithClaims(token)
-		if err != nil {
-			// 这里可以直接返回 401，也可以继续放行
-			return nil, status.Error(codes.Unauthenticated, "Token 无效或已过期")
-		}
-		userID = claims.UserID
-		roles = claims.Roles
-		log.Printf("🔍 [gRPC Auth] 从 JWT token 验证获取: userID=%s, roles=%v", userID, roles)
 	}
 
 	// 将 userID 和 roles 写入到 context，后续 handler 可以用 GetUserIDFromContext 和 GetRolesFromContext 获取
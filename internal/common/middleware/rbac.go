@@ -7,30 +7,23 @@ import (
 	"strings"
 	"zjMall/internal/common/authz"
 	"zjMall/pkg"
-
-	"google.golang.org/grpc/metadata"
 )
 
 // ContextKey 用于从 context 中获取角色和权限
 const RolesKey ContextKey = "roles"
 const PermissionsKey ContextKey = "permissions"
 
+// RoleInternalService 服务间调用的角色，表示调用方已完成业务审核（如售后审核通过后退款）
+// 信任前提：该角色只能来自 InternalServiceAuthorization 签发、用各服务共享的 JWT 密钥签名的令牌，
+// 持有 JWT 密钥即视为内部服务；拦截器不认可调用方自行填写的 roles metadata，用户 JWT 也不应包含该角色
+const RoleInternalService = "internal_service"
+
 // GetRolesFromContext 从 context 中获取用户角色列表
+// 角色由 HTTP 认证中间件或 gRPC 认证拦截器在校验 JWT 后写入 context，不从原始 gRPC metadata 读取（避免调用方伪造角色）
 func GetRolesFromContext(ctx context.Context) []string {
-	// 1. 优先从 HTTP context 中获取
 	if roles, ok := ctx.Value(RolesKey).([]string); ok && len(roles) > 0 {
 		return roles
 	}
-
-	// 2. 从 gRPC metadata 中获取
-	md, ok := metadata.FromIncomingContext(ctx)
-	if ok {
-		roles := md.Get(string(RolesKey))
-		if len(roles) > 0 {
-			return roles
-		}
-	}
-
 	return nil
 }
 
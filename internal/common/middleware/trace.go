@@ -5,6 +5,8 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"net/http"
+
+	"google.golang.org/grpc/metadata"
 )
 
 // TraceIDKey Context 中存储 Trace ID 的 key
@@ -35,12 +37,21 @@ func TraceID() Middleware {
 	}
 }
 
+// TraceIDMetadataKey gRPC metadata 中传递 Trace ID 的 key（由 gRPC Gateway 设置）
+const TraceIDMetadataKey = "trace_id"
+
 // GetTraceID 从 Context 中获取 Trace ID
 // 在业务代码中使用：traceID := middleware.GetTraceID(ctx)
 func GetTraceID(ctx context.Context) string {
 	if traceID, ok := ctx.Value(TraceIDKey).(string); ok {
 		return traceID
 	}
+	// gRPC 服务中从 metadata 获取（由 gRPC Gateway 传递）
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if traceIDs := md.Get(TraceIDMetadataKey); len(traceIDs) > 0 {
+			return traceIDs[0]
+		}
+	}
 	return ""
 }
 
@@ -20,6 +20,9 @@ type MessageProducer interface {
 	// SendMessage 发送普通消息
 	SendMessage(ctx context.Context, topic string, data interface{}) error
 
+	// SendMessageToExchange 发送普通消息到指定交换机（exchange 为空时使用默认交换机，routingKey 即队列名）
+	SendMessageToExchange(ctx context.Context, exchange, routingKey string, data interface{}) error
+
 	// SendOrderedMessage 发送顺序消息（按 key 分区，保证同一 key 的消息有序）
 	SendOrderedMessage(ctx context.Context, topic string, key string, data interface{}) error
 
@@ -147,6 +150,44 @@ func (m *messageProducer) SendMessage(ctx context.Context, topic string, data in
 	return nil
 }
 
+// SendMessageToExchange 发送普通消息到指定交换机
+func (m *messageProducer) SendMessageToExchange(ctx context.Context, exchange, routingKey string, data interface{}) error {
+	// 检查 channel 是否有效
+	if m.channel == nil {
+		return fmt.Errorf("发送消息失败: RabbitMQ channel 为 nil")
+	}
+
+	// 检查连接是否关闭
+	if m.channel.IsClosed() {
+		return fmt.Errorf("发送消息失败: RabbitMQ channel/connection 已关闭")
+	}
+
+	// 序列化消息体
+	body, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("序列化消息失败: %w", err)
+	}
+
+	if err := m.doWithConfirmRetry(ctx, func() error {
+		return m.channel.PublishWithContext(ctx,
+			exchange,   // exchange
+			routingKey, // routing key
+			false,      // mandatory
+			false,      // immediate
+			amqp.Publishing{
+				DeliveryMode: amqp.Persistent,
+				ContentType:  "application/json",
+				Body:         body,
+			},
+		)
+	}, "发送消息失败"); err != nil {
+		return err
+	}
+
+	log.Printf("✅ RabbitMQ 消息发送成功: Exchange=%s, RoutingKey=%s", exchange, routingKey)
+	return nil
+}
+
 // SendOrderedMessage 发送顺序消息（按 key 分区，保证同一 key 的消息有序）
 // RocketMQ 5.x 通过设置 MessageGroup 来实现顺序消息
 func (m *messageProducer) SendOrderedMessage(ctx context.Context, topic string, key string, data interface{}) error {
@@ -0,0 +1,47 @@
+package mq
+
+import (
+	"context"
+	"time"
+)
+
+// StockEvent 库存水位变化事件（库存服务发布，商品服务、购物车服务消费）
+// 只在 SKU 的库存水位发生变化时发布一次，消费方按事件类型设置状态，重复消费不影响结果
+type StockEvent struct {
+	EventType      string    `json:"event_type"`      // 事件类型：stock.low, stock.out, stock.restocked
+	SKUID          string    `json:"sku_id"`          // SKU ID
+	AvailableStock int64     `json:"available_stock"` // 当前可用库存
+	SafetyStock    int64     `json:"safety_stock"`    // 安全库存阈值（0 表示未设置）
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+// StockEventType 库存事件类型常量（同时作为 routing key）
+const (
+	StockEventLow       = "stock.low"       // 可用库存降到安全库存及以下
+	StockEventOut       = "stock.out"       // 可用库存为 0（售罄）
+	StockEventRestocked = "stock.restocked" // 库存回升（从售罄或低库存恢复）
+)
+
+// 库存事件交换机与各消费方队列
+const (
+	StockEventExchange     = "stock.events"         // topic 交换机
+	StockEventRoutingKey   = "stock.*"              // 消费方绑定的 routing key（全部库存事件）
+	StockEventProductQueue = "product.stock.events" // 商品服务：同步 ES 售罄标记
+	StockEventCartQueue    = "cart.stock.events"    // 购物车服务：标记购物车项失效
+)
+
+// SendStockEvent 发送库存事件到库存事件交换机（routing key 为事件类型）
+func SendStockEvent(ctx context.Context, producer MessageProducer, event *StockEvent) error {
+	return producer.SendMessageToExchange(ctx, StockEventExchange, event.EventType, event)
+}
+
+// NewStockEvent 创建库存事件
+func NewStockEvent(eventType, skuID string, availableStock, safetyStock int64) *StockEvent {
+	return &StockEvent{
+		EventType:      eventType,
+		SKUID:          skuID,
+		AvailableStock: availableStock,
+		SafetyStock:    safetyStock,
+		Timestamp:      time.Now(),
+	}
+}
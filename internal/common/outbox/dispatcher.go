@@ -0,0 +1,208 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"zjMall/internal/common/mq"
+)
+
+// Route 事件投递目标
+// Exchange 为空时使用默认交换机，RoutingKey 即队列名
+type Route struct {
+	Exchange   string
+	RoutingKey string
+}
+
+// Registry 事件类型到投递目标的路由表
+type Registry struct {
+	mu     sync.RWMutex
+	routes map[string]Route
+}
+
+// NewRegistry 创建路由表
+func NewRegistry() *Registry {
+	return &Registry{routes: make(map[string]Route)}
+}
+
+// Register 注册事件类型的投递目标，重复注册会覆盖
+func (r *Registry) Register(eventType string, route Route) *Registry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.routes[eventType] = route
+	return r
+}
+
+// Lookup 查询事件类型的投递目标
+func (r *Registry) Lookup(eventType string) (Route, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	route, ok := r.routes[eventType]
+	return route, ok
+}
+
+// DispatcherConfig 派发器配置，零值字段使用默认值
+type DispatcherConfig struct {
+	Name        string        // 派发器名称（用于日志，如 payment-service）
+	BatchSize   int           // 每批认领的事件数，默认 100
+	Lease       time.Duration // 认领租约时长，默认 2 分钟；副本宕机时租约到期后由其他副本重新认领
+	SendTimeout time.Duration // 单条事件发送超时，默认 5 秒
+	BaseBackoff time.Duration // 首次重试退避时间，默认 10 秒，之后按 2 的指数增长
+	MaxBackoff  time.Duration // 最大退避时间，默认 30 分钟
+	MaxRetries  int           // 最大重试次数，超过后进入死信状态，默认 10
+}
+
+func (c *DispatcherConfig) applyDefaults() {
+	if c.Name == "" {
+		c.Name = "outbox"
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 100
+	}
+	if c.Lease <= 0 {
+		c.Lease = 2 * time.Minute
+	}
+	if c.SendTimeout <= 0 {
+		c.SendTimeout = 5 * time.Second
+	}
+	if c.BaseBackoff <= 0 {
+		c.BaseBackoff = 10 * time.Second
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = 30 * time.Minute
+	}
+	if c.MaxRetries <= 0 {
+		c.MaxRetries = 10
+	}
+}
+
+// Dispatcher Outbox 派发器：认领事件 -> 按路由表发送到 MQ -> 标记结果
+// 多个服务副本可同时运行，通过 Store.Claim 保证同一事件同一时刻只由一个副本投递
+type Dispatcher struct {
+	store    Store
+	routes   *Registry
+	producer mq.MessageProducer
+	cfg      DispatcherConfig
+}
+
+// NewDispatcher 创建 Outbox 派发器
+func NewDispatcher(store Store, routes *Registry, producer mq.MessageProducer, cfg DispatcherConfig) *Dispatcher {
+	cfg.applyDefaults()
+	return &Dispatcher{
+		store:    store,
+		routes:   routes,
+		producer: producer,
+		cfg:      cfg,
+	}
+}
+
+// Run 按 interval 周期性派发事件，直到 ctx 取消
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("ℹ️ [%s] Outbox 派发协程退出", d.cfg.Name)
+			return
+		case <-ticker.C:
+			// 一批处理满时说明仍有积压，继续派发直到清空
+			for {
+				sent, err := d.Dispatch(ctx)
+				if err != nil {
+					log.Printf("⚠️ [%s] Outbox 派发失败: %v", d.cfg.Name, err)
+					break
+				}
+				if sent < d.cfg.BatchSize || ctx.Err() != nil {
+					break
+				}
+			}
+		}
+	}
+}
+
+// Dispatch 认领并派发一批事件，返回本批认领的事件数
+func (d *Dispatcher) Dispatch(ctx context.Context) (int, error) {
+	if d.producer == nil {
+		return 0, fmt.Errorf("MQ 生产者未初始化")
+	}
+
+	events, err := d.store.Claim(ctx, d.cfg.BatchSize, d.cfg.Lease)
+	if err != nil {
+		return 0, fmt.Errorf("认领 Outbox 事件失败: %w", err)
+	}
+	leaseUntil := time.Now().Add(d.cfg.Lease)
+
+	for _, evt := range events {
+		// 租约即将到期时停止处理，剩余事件到期后由其他副本（或下一轮）重新认领，避免重复投递
+		if time.Until(leaseUntil) < d.cfg.SendTimeout {
+			log.Printf("⚠️ [%s] Outbox 认领租约即将到期，剩余事件留待下一轮派发", d.cfg.Name)
+			break
+		}
+		if err := d.send(ctx, evt); err != nil {
+			d.fail(ctx, evt, err)
+			continue
+		}
+		if err := d.store.MarkSent(ctx, evt); err != nil {
+			// 这里不再回滚 MQ 消息，由消费方通过幂等保证安全
+			log.Printf("⚠️ [%s] 标记 Outbox 事件已发送失败: id=%d, err=%v", d.cfg.Name, evt.ID, err)
+		}
+	}
+	return len(events), nil
+}
+
+// send 按路由表发送单条事件
+func (d *Dispatcher) send(ctx context.Context, evt *Event) error {
+	route, ok := d.routes.Lookup(evt.EventType)
+	if !ok {
+		// 未知类型按失败重试处理：滚动发布期间新版本副本可能已注册该类型
+		return fmt.Errorf("unknown event type: %s", evt.EventType)
+	}
+
+	// payload 已经是 JSON 字符串，以 RawMessage 传给 MQ 避免被再次编码成字符串
+	payload := json.RawMessage(evt.Payload)
+	if !json.Valid(payload) {
+		return fmt.Errorf("invalid payload json")
+	}
+
+	sendCtx, cancel := context.WithTimeout(ctx, d.cfg.SendTimeout)
+	defer cancel()
+	if route.Exchange == "" {
+		return d.producer.SendMessage(sendCtx, route.RoutingKey, payload)
+	}
+	return d.producer.SendMessageToExchange(sendCtx, route.Exchange, route.RoutingKey, payload)
+}
+
+// fail 记录发送失败：未超过最大重试次数时按指数退避安排重试，否则进入死信状态
+func (d *Dispatcher) fail(ctx context.Context, evt *Event, sendErr error) {
+	attempts := evt.RetryCount + 1
+	dead := attempts >= d.cfg.MaxRetries
+	nextRetryAt := time.Now().Add(d.backoff(evt.RetryCount))
+
+	if dead {
+		log.Printf("❌ [%s] Outbox 事件超过最大重试次数，进入死信状态: id=%d, type=%s, aggregate_id=%s, attempts=%d, err=%v",
+			d.cfg.Name, evt.ID, evt.EventType, evt.AggregateID, attempts, sendErr)
+	} else {
+		log.Printf("⚠️ [%s] 发送 Outbox 事件失败，%s 后重试: id=%d, type=%s, attempts=%d, err=%v",
+			d.cfg.Name, nextRetryAt.Format(time.DateTime), evt.ID, evt.EventType, attempts, sendErr)
+	}
+	if err := d.store.MarkFailed(ctx, evt, sendErr.Error(), nextRetryAt, dead); err != nil {
+		log.Printf("⚠️ [%s] 标记 Outbox 事件失败状态出错: id=%d, err=%v", d.cfg.Name, evt.ID, err)
+	}
+}
+
+// backoff 根据已重试次数计算退避时间：BaseBackoff * 2^retryCount，不超过 MaxBackoff
+func (d *Dispatcher) backoff(retryCount int) time.Duration {
+	delay := d.cfg.BaseBackoff
+	for i := 0; i < retryCount; i++ {
+		delay *= 2
+		if delay >= d.cfg.MaxBackoff {
+			return d.cfg.MaxBackoff
+		}
+	}
+	return delay
+}
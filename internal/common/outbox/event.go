@@ -0,0 +1,34 @@
+package outbox
+
+import "time"
+
+// Outbox 事件状态
+const (
+	StatusPending = 0 // 待发送
+	StatusSent    = 1 // 已发送
+	StatusFailed  = 2 // 发送失败，等待退避后重试
+	StatusDead    = 3 // 超过最大重试次数，进入死信状态，需人工处理
+)
+
+// Event 通用 Outbox 事件
+// 各服务的 Outbox 表结构一致（如 payment_outbox、order_outbox），通过 Store 指定表名：
+//
+//	id BIGINT UNSIGNED AUTO_INCREMENT, event_type, aggregate_id, payload JSON,
+//	status TINYINT, retry_count INT, error_msg, next_retry_at, claim_token, claimed_until,
+//	created_at, updated_at
+//
+// 业务侧在本地事务中写入 status=0 的事件，由 Dispatcher 异步投递到 MQ
+type Event struct {
+	ID           uint64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	EventType    string     `gorm:"column:event_type;size:64;not null" json:"event_type"`
+	AggregateID  string     `gorm:"column:aggregate_id;size:64;not null" json:"aggregate_id"`
+	Payload      string     `gorm:"column:payload;type:json;not null" json:"payload"`
+	Status       int8       `gorm:"column:status;not null;default:0" json:"status"`
+	RetryCount   int        `gorm:"column:retry_count;not null;default:0" json:"retry_count"`
+	ErrorMsg     string     `gorm:"column:error_msg;size:500" json:"error_msg"`
+	NextRetryAt  *time.Time `gorm:"column:next_retry_at" json:"next_retry_at"`     // 下次可投递时间，为空表示立即投递
+	ClaimToken   string     `gorm:"column:claim_token;size:26" json:"claim_token"` // 认领批次令牌，防止多副本重复投递
+	ClaimedUntil *time.Time `gorm:"column:claimed_until" json:"claimed_until"`     // 认领租约到期时间，到期后其他副本可重新认领
+	CreatedAt    time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time  `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
@@ -0,0 +1,108 @@
+package outbox
+
+import (
+	"context"
+	"time"
+	"zjMall/pkg"
+
+	"gorm.io/gorm"
+)
+
+// Store Outbox 事件存储接口
+type Store interface {
+	// Add 写入一条待发送事件（在业务事务中调用时使用 WithDB 绑定事务）
+	Add(ctx context.Context, event *Event) error
+	// Claim 认领一批可投递的事件（待发送或已到重试时间的失败事件），认领期间其他副本不会取到这些事件
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]*Event, error)
+	// MarkSent 标记为已发送（仅当认领令牌仍然有效时生效）
+	MarkSent(ctx context.Context, event *Event) error
+	// MarkFailed 标记为发送失败并增加重试次数；dead 为 true 时进入死信状态，否则在 nextRetryAt 后重试
+	MarkFailed(ctx context.Context, event *Event, errMsg string, nextRetryAt time.Time, dead bool) error
+	// WithDB 返回绑定到指定连接（通常是事务）的存储
+	WithDB(db *gorm.DB) Store
+}
+
+type store struct {
+	db    *gorm.DB
+	table string
+}
+
+// NewStore 创建 Outbox 事件存储，table 为服务自己的 Outbox 表名
+func NewStore(db *gorm.DB, table string) Store {
+	return &store{db: db, table: table}
+}
+
+func (s *store) WithDB(db *gorm.DB) Store {
+	return &store{db: db, table: s.table}
+}
+
+func (s *store) Add(ctx context.Context, event *Event) error {
+	event.Status = StatusPending
+	return s.db.WithContext(ctx).Table(s.table).Create(event).Error
+}
+
+func (s *store) Claim(ctx context.Context, limit int, lease time.Duration) ([]*Event, error) {
+	now := time.Now()
+	token := pkg.GenerateULID()
+
+	// 单条 UPDATE ... ORDER BY ... LIMIT 原子地认领事件，多个副本并发认领时不会取到同一行
+	tx := s.db.WithContext(ctx).
+		Table(s.table).
+		Where("status IN ?", []int{StatusPending, StatusFailed}).
+		Where("next_retry_at IS NULL OR next_retry_at <= ?", now).
+		Where("claimed_until IS NULL OR claimed_until < ?", now).
+		Order("id ASC").
+		Limit(limit).
+		Updates(map[string]interface{}{
+			"claim_token":   token,
+			"claimed_until": now.Add(lease),
+		})
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	var events []*Event
+	if err := s.db.WithContext(ctx).
+		Table(s.table).
+		Where("claim_token = ?", token).
+		Order("id ASC").
+		Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (s *store) MarkSent(ctx context.Context, event *Event) error {
+	return s.db.WithContext(ctx).
+		Table(s.table).
+		Where("id = ? AND claim_token = ?", event.ID, event.ClaimToken).
+		Updates(map[string]interface{}{
+			"status":        StatusSent,
+			"claimed_until": nil,
+			"updated_at":    time.Now(),
+		}).Error
+}
+
+func (s *store) MarkFailed(ctx context.Context, event *Event, errMsg string, nextRetryAt time.Time, dead bool) error {
+	status := StatusFailed
+	if dead {
+		status = StatusDead
+	}
+	if runes := []rune(errMsg); len(runes) > 500 {
+		errMsg = string(runes[:500])
+	}
+	return s.db.WithContext(ctx).
+		Table(s.table).
+		Where("id = ? AND claim_token = ?", event.ID, event.ClaimToken).
+		Updates(map[string]interface{}{
+			"status":        status,
+			"retry_count":   gorm.Expr("retry_count + 1"),
+			"error_msg":     errMsg,
+			"next_retry_at": nextRetryAt,
+			"claimed_until": nil,
+			"updated_at":    time.Now(),
+		}).Error
+}
@@ -55,6 +55,12 @@ func NewServer(cfg *Config) *Server {
 					log.Printf("gRPC Gateway: 传递 user_id 到 metadata: %s", userIDStr)
 				}
 			}
+			// 用户的 Authorization 头由 gRPC Gateway 原样透传为 authorization metadata，
+			// gRPC 拦截器校验 JWT 后从中获取角色，这里不单独传递 roles，避免出现未经校验的角色来源
+			// 传递 Trace ID（由 TraceID 中间件设置），便于 gRPC 服务记录到业务日志
+			if traceID := middleware.GetTraceID(ctx); traceID != "" {
+				md.Set(middleware.TraceIDMetadataKey, traceID)
+			}
 			return md
 		}),
 	)
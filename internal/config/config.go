@@ -89,6 +89,24 @@ type ServiceClientConfig struct {
 	InventoryServiceAddr string `yaml:"inventory_service_addr"` // 库存服务 gRPC 地址，例如 "localhost:50055"
 	UserServiceAddr      string `yaml:"user_service_addr"`      // 用户服务 gRPC 地址，例如 "localhost:50052"
 	CartServiceAddr      string `yaml:"cart_service_addr"`      // 购物车服务 gRPC 地址，例如 "localhost:50054"
+	PromotionServiceAddr string `yaml:"promotion_service_addr"` // 促销服务 gRPC 地址，例如 "localhost:50058"
+	PaymentServiceAddr   string `yaml:"payment_service_addr"`   // 支付服务 gRPC 地址，例如 "localhost:50057"
+}
+
+// PaymentConfig 支付服务配置
+type PaymentConfig struct {
+	Environment  string `yaml:"environment"`   // 支付渠道环境：sandbox-沙箱，production-生产（为空时默认 sandbox）
+	StatementDir string `yaml:"statement_dir"` // 渠道对账单目录，文件名为 {渠道}_{YYYYMMDD}.csv（为空时不执行每日对账）
+}
+
+// OrderConfig 订单服务配置
+type OrderConfig struct {
+	AutoCompleteDays     int    `yaml:"auto_complete_days"`     // 发货后自动确认收货天数（为空时默认 7 天）
+	CarrierStubDir       string `yaml:"carrier_stub_dir"`       // 模拟物流公司的轨迹文件目录（{物流单号}.json），配置后所有物流公司使用模拟适配器
+	CarrierWebhookSecret string `yaml:"carrier_webhook_secret"` // 模拟物流公司推送签名密钥（为空时拒绝所有物流推送）
+
+	PayTimeoutMinutes        int `yaml:"pay_timeout_minutes"`         // 普通订单支付时限（分钟，为空时默认 30 分钟）
+	SeckillPayTimeoutMinutes int `yaml:"seckill_pay_timeout_minutes"` // 秒杀订单支付时限（分钟，为空时默认 10 分钟）
 }
 
 type NacosConfig struct {
@@ -110,6 +128,8 @@ type Config struct {
 	ServiceClients   ServiceClientConfig      `yaml:"service_clients"` // 服务客户端配置
 	Nacos            NacosConfig              `yaml:"nacos"`
 	RabbitMQ         RabbitMQConfig           `yaml:"rabbitmq"`
+	Payment          PaymentConfig            `yaml:"payment"` // 支付服务配置
+	Order            OrderConfig              `yaml:"order"`   // 订单服务配置
 }
 
 // globalConfig 持有当前生效的配置，用于 ListenConfig 动态更新。
@@ -275,3 +295,10 @@ func (c *Config) GetNacosConfig() *NacosConfig {
 func (c *Config) GetRabbitMQConfig() *RabbitMQConfig {
 	return &c.RabbitMQ
 }
+func (c *Config) GetPaymentConfig() *PaymentConfig {
+	return &c.Payment
+}
+
+func (c *Config) GetOrderConfig() *OrderConfig {
+	return &c.Order
+}
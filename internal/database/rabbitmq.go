@@ -135,3 +135,45 @@ func InitDelayedExchange(ch *amqp.Channel, exchangeName, queueName string) error
 	log.Printf("✅ 延迟消息 Exchange 初始化成功: Exchange=%s, Queue=%s", exchangeName, queueName)
 	return nil
 }
+
+// InitTopicExchange 初始化 topic 类型的事件 Exchange，并将队列按 routingKeys 绑定到该 Exchange
+// 生产方与消费方都可调用（声明是幂等的），消费方未启动前发布的消息会留在已绑定的队列中
+func InitTopicExchange(ch *amqp.Channel, exchangeName, queueName string, routingKeys ...string) error {
+	if ch == nil {
+		return fmt.Errorf("RabbitMQ Channel 不能为空")
+	}
+
+	err := ch.ExchangeDeclare(
+		exchangeName, // name
+		"topic",      // type
+		true,         // durable
+		false,        // autoDelete
+		false,        // internal
+		false,        // noWait
+		nil,          // args
+	)
+	if err != nil {
+		return fmt.Errorf("声明 Exchange 失败: %w", err)
+	}
+
+	_, err = ch.QueueDeclare(
+		queueName, // name
+		true,      // durable
+		false,     // autoDelete
+		false,     // exclusive
+		false,     // noWait
+		nil,       // args
+	)
+	if err != nil {
+		return fmt.Errorf("声明队列失败: %w", err)
+	}
+
+	for _, routingKey := range routingKeys {
+		if err := ch.QueueBind(queueName, routingKey, exchangeName, false, nil); err != nil {
+			return fmt.Errorf("绑定队列到 Exchange 失败: routingKey=%s, err=%w", routingKey, err)
+		}
+	}
+
+	log.Printf("✅ Topic Exchange 初始化成功: Exchange=%s, Queue=%s, RoutingKeys=%v", exchangeName, queueName, routingKeys)
+	return nil
+}
@@ -6,6 +6,8 @@ import (
 	"log"
 
 	inventoryv1 "zjMall/gen/go/api/proto/inventory"
+	"zjMall/internal/inventory-service/model"
+	"zjMall/internal/inventory-service/repository"
 	"zjMall/internal/inventory-service/service"
 
 	"google.golang.org/protobuf/types/known/timestamppb"
@@ -17,6 +19,13 @@ type InventoryHandler struct {
 	svc *service.InventoryService
 }
 
+// allocationStrategies 分仓策略枚举 -> 服务层策略（未指定时由服务层按收货省份选择）
+var allocationStrategies = map[inventoryv1.AllocationStrategy]string{
+	inventoryv1.AllocationStrategy_ALLOCATION_STRATEGY_NEAREST:       service.AllocationStrategyNearest,
+	inventoryv1.AllocationStrategy_ALLOCATION_STRATEGY_FEWEST_SPLITS: service.AllocationStrategyFewestSplits,
+	inventoryv1.AllocationStrategy_ALLOCATION_STRATEGY_PRIORITY:      service.AllocationStrategyPriority,
+}
+
 // NewInventoryHandler 创建库存 Handler
 func NewInventoryHandler(svc *service.InventoryService) *InventoryHandler {
 	return &InventoryHandler{svc: svc}
@@ -48,17 +57,24 @@ func (h *InventoryHandler) GetStock(ctx context.Context, req *inventoryv1.GetSto
 		}, nil
 	}
 
+	data := convertStockToProto(stock)
+	warehouseStocks, err := h.svc.GetWarehouseStocks(ctx, req.SkuId)
+	if err != nil {
+		// 分仓库存仅用于展示，查询失败不影响主库存返回
+		log.Printf("⚠️ [InventoryHandler] GetStock: 查询分仓库存失败 sku_id=%s, err=%v", req.SkuId, err)
+	}
+	for _, ws := range warehouseStocks {
+		data.WarehouseStocks = append(data.WarehouseStocks, &inventoryv1.WarehouseStock{
+			WarehouseId:    ws.WarehouseID,
+			AvailableStock: ws.AvailableStock,
+			LockedStock:    ws.LockedStock,
+		})
+	}
+
 	return &inventoryv1.GetStockResponse{
 		Code:    0,
 		Message: "success",
-		Data: &inventoryv1.Stock{
-			Id:             stock.ID,
-			SkuId:          stock.SKUID,
-			AvailableStock: stock.AvailableStock,
-			Version:        stock.Version,
-			CreatedAt:      timestamppb.New(stock.CreatedAt),
-			UpdatedAt:      timestamppb.New(stock.UpdatedAt),
-		},
+		Data:    data,
 	}, nil
 }
 
@@ -86,14 +102,7 @@ func (h *InventoryHandler) BatchGetStock(ctx context.Context, req *inventoryv1.B
 		if s == nil {
 			continue
 		}
-		result[skuID] = &inventoryv1.Stock{
-			Id:             s.ID,
-			SkuId:          s.SKUID,
-			AvailableStock: s.AvailableStock,
-			Version:        s.Version,
-			CreatedAt:      timestamppb.New(s.CreatedAt),
-			UpdatedAt:      timestamppb.New(s.UpdatedAt),
-		}
+		result[skuID] = convertStockToProto(s)
 	}
 
 	return &inventoryv1.BatchGetStockResponse{
@@ -126,7 +135,8 @@ func (h *InventoryHandler) DeductStock(ctx context.Context, req *inventoryv1.Ded
 		})
 	}
 
-	if err := h.svc.TryDeductStocks(ctx, req.OrderId, items); err != nil {
+	allocations, err := h.svc.TryDeductStocks(ctx, req.OrderId, items, req.ReceiverProvince, allocationStrategies[req.Strategy])
+	if err != nil {
 		log.Printf("❌ [InventoryHandler] DeductStock: 扣减失败 order_id=%s, err=%v", req.OrderId, err)
 		return &inventoryv1.DeductStockResponse{
 			Code:    1,
@@ -135,12 +145,13 @@ func (h *InventoryHandler) DeductStock(ctx context.Context, req *inventoryv1.Ded
 	}
 
 	return &inventoryv1.DeductStockResponse{
-		Code:    0,
-		Message: "success",
+		Code:        0,
+		Message:     "success",
+		Allocations: convertAllocationsToProto(allocations),
 	}, nil
 }
 
-// RollbackStock 回滚库存（订单取消/关闭时调用）
+// RollbackStock 回滚库存（秒杀名额归还、售后退货回补时调用）
 func (h *InventoryHandler) RollbackStock(ctx context.Context, req *inventoryv1.RollbackStockRequest) (*inventoryv1.RollbackStockResponse, error) {
 	if len(req.Items) == 0 {
 		return &inventoryv1.RollbackStockResponse{
@@ -155,8 +166,9 @@ func (h *InventoryHandler) RollbackStock(ctx context.Context, req *inventoryv1.R
 			continue
 		}
 		items = append(items, service.ItemQuantity{
-			SKUID:    it.SkuId,
-			Quantity: it.Quantity,
+			SKUID:       it.SkuId,
+			Quantity:    it.Quantity,
+			WarehouseID: it.WarehouseId,
 		})
 	}
 
@@ -173,3 +185,115 @@ func (h *InventoryHandler) RollbackStock(ctx context.Context, req *inventoryv1.R
 		Message: "success",
 	}, nil
 }
+
+// ReserveStock 锁定库存（下单时调用）
+func (h *InventoryHandler) ReserveStock(ctx context.Context, req *inventoryv1.ReserveStockRequest) (*inventoryv1.ReserveStockResponse, error) {
+	if req.OrderId == "" || len(req.Items) == 0 {
+		return &inventoryv1.ReserveStockResponse{
+			Code:    1,
+			Message: "order_id 和 items 不能为空",
+		}, nil
+	}
+
+	items := make([]service.ItemQuantity, 0, len(req.Items))
+	for _, it := range req.Items {
+		if it.SkuId == "" || it.Quantity <= 0 {
+			return &inventoryv1.ReserveStockResponse{
+				Code:    1,
+				Message: "sku_id 不能为空且 quantity 必须大于 0",
+			}, nil
+		}
+		items = append(items, service.ItemQuantity{
+			SKUID:    it.SkuId,
+			Quantity: it.Quantity,
+		})
+	}
+
+	allocations, err := h.svc.ReserveStocks(ctx, req.OrderId, items, req.ReceiverProvince, allocationStrategies[req.Strategy])
+	if err != nil {
+		log.Printf("❌ [InventoryHandler] ReserveStock: 锁定失败 order_id=%s, err=%v", req.OrderId, err)
+		return &inventoryv1.ReserveStockResponse{
+			Code:    1,
+			Message: fmt.Sprintf("锁定库存失败: %v", err),
+		}, nil
+	}
+
+	return &inventoryv1.ReserveStockResponse{
+		Code:        0,
+		Message:     "success",
+		Allocations: convertAllocationsToProto(allocations),
+	}, nil
+}
+
+// ConfirmStock 确认库存（支付成功时调用）
+func (h *InventoryHandler) ConfirmStock(ctx context.Context, req *inventoryv1.ConfirmStockRequest) (*inventoryv1.ConfirmStockResponse, error) {
+	if req.OrderId == "" {
+		return &inventoryv1.ConfirmStockResponse{
+			Code:    1,
+			Message: "order_id 不能为空",
+		}, nil
+	}
+
+	if err := h.svc.ConfirmStocks(ctx, req.OrderId); err != nil {
+		log.Printf("❌ [InventoryHandler] ConfirmStock: 确认失败 order_id=%s, err=%v", req.OrderId, err)
+		return &inventoryv1.ConfirmStockResponse{
+			Code:    1,
+			Message: fmt.Sprintf("确认库存失败: %v", err),
+		}, nil
+	}
+
+	return &inventoryv1.ConfirmStockResponse{
+		Code:    0,
+		Message: "success",
+	}, nil
+}
+
+// ReleaseStock 释放库存（订单取消/超时关闭时调用）
+func (h *InventoryHandler) ReleaseStock(ctx context.Context, req *inventoryv1.ReleaseStockRequest) (*inventoryv1.ReleaseStockResponse, error) {
+	if req.OrderId == "" {
+		return &inventoryv1.ReleaseStockResponse{
+			Code:    1,
+			Message: "order_id 不能为空",
+		}, nil
+	}
+
+	if err := h.svc.ReleaseStocks(ctx, req.OrderId); err != nil {
+		log.Printf("❌ [InventoryHandler] ReleaseStock: 释放失败 order_id=%s, err=%v", req.OrderId, err)
+		return &inventoryv1.ReleaseStockResponse{
+			Code:    1,
+			Message: fmt.Sprintf("释放库存失败: %v", err),
+		}, nil
+	}
+
+	return &inventoryv1.ReleaseStockResponse{
+		Code:    0,
+		Message: "success",
+	}, nil
+}
+
+// convertStockToProto 转换库存记录
+func convertStockToProto(stock *model.Stock) *inventoryv1.Stock {
+	return &inventoryv1.Stock{
+		Id:             stock.ID,
+		SkuId:          stock.SKUID,
+		AvailableStock: stock.AvailableStock,
+		LockedStock:    stock.LockedStock,
+		SafetyStock:    stock.SafetyStock,
+		Version:        stock.Version,
+		CreatedAt:      timestamppb.New(stock.CreatedAt),
+		UpdatedAt:      timestamppb.New(stock.UpdatedAt),
+	}
+}
+
+// convertAllocationsToProto 转换分仓结果
+func convertAllocationsToProto(allocations []repository.DeductItem) []*inventoryv1.StockAllocation {
+	result := make([]*inventoryv1.StockAllocation, 0, len(allocations))
+	for _, a := range allocations {
+		result = append(result, &inventoryv1.StockAllocation{
+			SkuId:       a.SKUID,
+			WarehouseId: a.WarehouseID,
+			Quantity:    a.Quantity,
+		})
+	}
+	return result
+}
@@ -0,0 +1,308 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"unicode/utf8"
+
+	inventoryv1 "zjMall/gen/go/api/proto/inventory"
+	"zjMall/internal/common/middleware"
+	"zjMall/internal/inventory-service/model"
+	"zjMall/internal/inventory-service/repository"
+	"zjMall/internal/inventory-service/service"
+)
+
+// 后台库存操作单号与备注的长度上限（与 inventory_logs 字段长度一致）
+const (
+	maxStockRefIDLength  = 64
+	maxStockRemarkLength = 255
+)
+
+// adjustReasonCodes 调整原因枚举 -> 库存日志中的原因编码
+var adjustReasonCodes = map[inventoryv1.StockAdjustReason]string{
+	inventoryv1.StockAdjustReason_STOCK_ADJUST_REASON_DAMAGE:          model.StockAdjustReasonDamage,
+	inventoryv1.StockAdjustReason_STOCK_ADJUST_REASON_LOST:            model.StockAdjustReasonLost,
+	inventoryv1.StockAdjustReason_STOCK_ADJUST_REASON_FOUND:           model.StockAdjustReasonFound,
+	inventoryv1.StockAdjustReason_STOCK_ADJUST_REASON_SUPPLIER_RETURN: model.StockAdjustReasonSupplierReturn,
+	inventoryv1.StockAdjustReason_STOCK_ADJUST_REASON_CORRECTION:      model.StockAdjustReasonCorrection,
+}
+
+// InitStock 初始化 SKU 库存（管理员）
+func (h *InventoryHandler) InitStock(ctx context.Context, req *inventoryv1.InitStockRequest) (*inventoryv1.InitStockResponse, error) {
+	if !middleware.CheckRole(ctx, "admin") {
+		return &inventoryv1.InitStockResponse{
+			Code:    403,
+			Message: "权限不足：需要管理员权限",
+		}, nil
+	}
+	if req.SkuId == "" || req.Quantity < 0 {
+		return &inventoryv1.InitStockResponse{
+			Code:    1,
+			Message: "sku_id 不能为空且 quantity 不能小于 0",
+		}, nil
+	}
+	if msg := validateStockOperation("", req.Remark); msg != "" {
+		return &inventoryv1.InitStockResponse{
+			Code:    1,
+			Message: msg,
+		}, nil
+	}
+
+	operatorID := middleware.GetUserIDFromContext(ctx)
+	stock, err := h.svc.InitStock(ctx, operatorID, req.WarehouseId, req.SkuId, req.Quantity, req.Remark)
+	if err != nil {
+		if errors.Is(err, repository.ErrStockExists) {
+			return &inventoryv1.InitStockResponse{
+				Code:    1,
+				Message: fmt.Sprintf("SKU %s 在该仓库的库存已存在，请使用调整或入库", req.SkuId),
+			}, nil
+		}
+		if errors.Is(err, repository.ErrWarehouseNotFound) {
+			return &inventoryv1.InitStockResponse{
+				Code:    1,
+				Message: fmt.Sprintf("仓库 %s 不存在", req.WarehouseId),
+			}, nil
+		}
+		log.Printf("❌ [InventoryHandler] InitStock: 初始化失败 sku_id=%s, err=%v", req.SkuId, err)
+		return &inventoryv1.InitStockResponse{
+			Code:    1,
+			Message: fmt.Sprintf("初始化库存失败: %v", err),
+		}, nil
+	}
+
+	log.Printf("✅ [InventoryHandler] InitStock: 初始化成功 sku_id=%s, warehouse_id=%s, quantity=%d, operator=%s", req.SkuId, req.WarehouseId, req.Quantity, operatorID)
+	return &inventoryv1.InitStockResponse{
+		Code:    0,
+		Message: "success",
+		Data:    convertStockToProto(stock),
+	}, nil
+}
+
+// AdjustStock 调整库存（管理员）
+func (h *InventoryHandler) AdjustStock(ctx context.Context, req *inventoryv1.AdjustStockRequest) (*inventoryv1.AdjustStockResponse, error) {
+	if !middleware.CheckRole(ctx, "admin") {
+		return &inventoryv1.AdjustStockResponse{
+			Code:    403,
+			Message: "权限不足：需要管理员权限",
+		}, nil
+	}
+	if req.SkuId == "" || req.Delta == 0 {
+		return &inventoryv1.AdjustStockResponse{
+			Code:    1,
+			Message: "sku_id 不能为空且 delta 不能为 0",
+		}, nil
+	}
+	reasonCode, ok := adjustReasonCodes[req.Reason]
+	if !ok {
+		return &inventoryv1.AdjustStockResponse{
+			Code:    1,
+			Message: "请选择调整原因",
+		}, nil
+	}
+	if msg := validateStockOperation(req.RequestNo, req.Remark); msg != "" {
+		return &inventoryv1.AdjustStockResponse{
+			Code:    1,
+			Message: msg,
+		}, nil
+	}
+
+	operatorID := middleware.GetUserIDFromContext(ctx)
+	stock, err := h.svc.AdjustStock(ctx, operatorID, req.WarehouseId, req.SkuId, req.Delta, reasonCode, req.RequestNo, req.Remark)
+	if err != nil {
+		if errors.Is(err, repository.ErrStockNotFound) {
+			return &inventoryv1.AdjustStockResponse{
+				Code:    1,
+				Message: fmt.Sprintf("SKU %s 在该仓库的库存不存在，请先初始化库存", req.SkuId),
+			}, nil
+		}
+		if errors.Is(err, repository.ErrWarehouseNotFound) {
+			return &inventoryv1.AdjustStockResponse{
+				Code:    1,
+				Message: fmt.Sprintf("仓库 %s 不存在", req.WarehouseId),
+			}, nil
+		}
+		log.Printf("❌ [InventoryHandler] AdjustStock: 调整失败 sku_id=%s, delta=%d, err=%v", req.SkuId, req.Delta, err)
+		return &inventoryv1.AdjustStockResponse{
+			Code:    1,
+			Message: fmt.Sprintf("调整库存失败: %v", err),
+		}, nil
+	}
+
+	log.Printf("✅ [InventoryHandler] AdjustStock: 调整成功 sku_id=%s, warehouse_id=%s, delta=%d, reason=%s, operator=%s", req.SkuId, req.WarehouseId, req.Delta, reasonCode, operatorID)
+	return &inventoryv1.AdjustStockResponse{
+		Code:    0,
+		Message: "success",
+		Data:    convertStockToProto(stock),
+	}, nil
+}
+
+// InboundStock 批量入库（管理员）
+func (h *InventoryHandler) InboundStock(ctx context.Context, req *inventoryv1.InboundStockRequest) (*inventoryv1.InboundStockResponse, error) {
+	if !middleware.CheckRole(ctx, "admin") {
+		return &inventoryv1.InboundStockResponse{
+			Code:    403,
+			Message: "权限不足：需要管理员权限",
+		}, nil
+	}
+	if req.ReceiptNo == "" || len(req.Items) == 0 {
+		return &inventoryv1.InboundStockResponse{
+			Code:    1,
+			Message: "receipt_no 和 items 不能为空",
+		}, nil
+	}
+	if msg := validateStockOperation(req.ReceiptNo, req.Remark); msg != "" {
+		return &inventoryv1.InboundStockResponse{
+			Code:    1,
+			Message: msg,
+		}, nil
+	}
+
+	items := make([]service.ItemQuantity, 0, len(req.Items))
+	for _, it := range req.Items {
+		if it.SkuId == "" || it.Quantity <= 0 {
+			return &inventoryv1.InboundStockResponse{
+				Code:    1,
+				Message: "sku_id 不能为空且 quantity 必须大于 0",
+			}, nil
+		}
+		items = append(items, service.ItemQuantity{
+			SKUID:    it.SkuId,
+			Quantity: it.Quantity,
+		})
+	}
+
+	operatorID := middleware.GetUserIDFromContext(ctx)
+	if err := h.svc.InboundStocks(ctx, operatorID, req.WarehouseId, req.ReceiptNo, items, req.Remark); err != nil {
+		log.Printf("❌ [InventoryHandler] InboundStock: 入库失败 receipt_no=%s, err=%v", req.ReceiptNo, err)
+		return &inventoryv1.InboundStockResponse{
+			Code:    1,
+			Message: fmt.Sprintf("入库失败: %v", err),
+		}, nil
+	}
+
+	log.Printf("✅ [InventoryHandler] InboundStock: 入库成功 receipt_no=%s, warehouse_id=%s, items=%d, operator=%s", req.ReceiptNo, req.WarehouseId, len(items), operatorID)
+	return &inventoryv1.InboundStockResponse{
+		Code:    0,
+		Message: "success",
+	}, nil
+}
+
+// StockTake 库存盘点（管理员）
+func (h *InventoryHandler) StockTake(ctx context.Context, req *inventoryv1.StockTakeRequest) (*inventoryv1.StockTakeResponse, error) {
+	if !middleware.CheckRole(ctx, "admin") {
+		return &inventoryv1.StockTakeResponse{
+			Code:    403,
+			Message: "权限不足：需要管理员权限",
+		}, nil
+	}
+	if req.TakeNo == "" || len(req.Items) == 0 {
+		return &inventoryv1.StockTakeResponse{
+			Code:    1,
+			Message: "take_no 和 items 不能为空",
+		}, nil
+	}
+	if msg := validateStockOperation(req.TakeNo, req.Remark); msg != "" {
+		return &inventoryv1.StockTakeResponse{
+			Code:    1,
+			Message: msg,
+		}, nil
+	}
+
+	counts := make([]service.StockTakeCount, 0, len(req.Items))
+	for _, it := range req.Items {
+		if it.SkuId == "" || it.CountedQuantity < 0 {
+			return &inventoryv1.StockTakeResponse{
+				Code:    1,
+				Message: "sku_id 不能为空且 counted_quantity 不能小于 0",
+			}, nil
+		}
+		counts = append(counts, service.StockTakeCount{
+			SKUID:           it.SkuId,
+			CountedQuantity: it.CountedQuantity,
+		})
+	}
+
+	operatorID := middleware.GetUserIDFromContext(ctx)
+	results, err := h.svc.StockTake(ctx, operatorID, req.WarehouseId, req.TakeNo, counts, req.Remark)
+	if err != nil {
+		if errors.Is(err, repository.ErrStockTakeSubmitted) {
+			return &inventoryv1.StockTakeResponse{
+				Code:    1,
+				Message: fmt.Sprintf("盘点单 %s 已提交，不能重复盘点", req.TakeNo),
+			}, nil
+		}
+		log.Printf("❌ [InventoryHandler] StockTake: 盘点失败 take_no=%s, err=%v", req.TakeNo, err)
+		return &inventoryv1.StockTakeResponse{
+			Code:    1,
+			Message: fmt.Sprintf("盘点失败: %v", err),
+		}, nil
+	}
+
+	data := make([]*inventoryv1.StockTakeResult, 0, len(results))
+	for _, r := range results {
+		data = append(data, &inventoryv1.StockTakeResult{
+			SkuId:           r.SKUID,
+			BookQuantity:    r.BookQuantity,
+			CountedQuantity: r.CountedQuantity,
+			Difference:      r.Difference,
+		})
+	}
+
+	log.Printf("✅ [InventoryHandler] StockTake: 盘点完成 take_no=%s, warehouse_id=%s, items=%d, operator=%s", req.TakeNo, req.WarehouseId, len(results), operatorID)
+	return &inventoryv1.StockTakeResponse{
+		Code:    0,
+		Message: "success",
+		Data:    data,
+	}, nil
+}
+
+// SetSafetyStock 设置安全库存（管理员）
+func (h *InventoryHandler) SetSafetyStock(ctx context.Context, req *inventoryv1.SetSafetyStockRequest) (*inventoryv1.SetSafetyStockResponse, error) {
+	if !middleware.CheckRole(ctx, "admin") {
+		return &inventoryv1.SetSafetyStockResponse{
+			Code:    403,
+			Message: "权限不足：需要管理员权限",
+		}, nil
+	}
+	if req.SkuId == "" || req.SafetyStock < 0 {
+		return &inventoryv1.SetSafetyStockResponse{
+			Code:    1,
+			Message: "sku_id 不能为空且 safety_stock 不能小于 0",
+		}, nil
+	}
+
+	stock, err := h.svc.SetSafetyStock(ctx, req.SkuId, req.SafetyStock)
+	if err != nil {
+		if errors.Is(err, repository.ErrStockNotFound) {
+			return &inventoryv1.SetSafetyStockResponse{
+				Code:    1,
+				Message: fmt.Sprintf("SKU %s 的库存不存在，请先初始化库存", req.SkuId),
+			}, nil
+		}
+		log.Printf("❌ [InventoryHandler] SetSafetyStock: 设置失败 sku_id=%s, safety_stock=%d, err=%v", req.SkuId, req.SafetyStock, err)
+		return &inventoryv1.SetSafetyStockResponse{
+			Code:    1,
+			Message: fmt.Sprintf("设置安全库存失败: %v", err),
+		}, nil
+	}
+
+	log.Printf("✅ [InventoryHandler] SetSafetyStock: 设置成功 sku_id=%s, safety_stock=%d, operator=%s", req.SkuId, req.SafetyStock, middleware.GetUserIDFromContext(ctx))
+	return &inventoryv1.SetSafetyStockResponse{
+		Code:    0,
+		Message: "success",
+		Data:    convertStockToProto(stock),
+	}, nil
+}
+
+// validateStockOperation 校验后台操作单号与备注长度，返回空字符串表示校验通过
+func validateStockOperation(refID, remark string) string {
+	if len(refID) > maxStockRefIDLength {
+		return fmt.Sprintf("单号不能超过%d个字符", maxStockRefIDLength)
+	}
+	if utf8.RuneCountInString(remark) > maxStockRemarkLength {
+		return fmt.Sprintf("备注不能超过%d个字符", maxStockRemarkLength)
+	}
+	return ""
+}
@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	inventoryv1 "zjMall/gen/go/api/proto/inventory"
+	"zjMall/internal/common/middleware"
+	"zjMall/internal/inventory-service/model"
+	"zjMall/internal/inventory-service/repository"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ListStockLogs 查询库存变动明细（管理员）
+func (h *InventoryHandler) ListStockLogs(ctx context.Context, req *inventoryv1.ListStockLogsRequest) (*inventoryv1.ListStockLogsResponse, error) {
+	if !middleware.CheckRole(ctx, "admin") {
+		return &inventoryv1.ListStockLogsResponse{
+			Code:    403,
+			Message: "权限不足：需要管理员权限",
+		}, nil
+	}
+
+	filter := repository.StockLogFilter{
+		SKUID:       req.SkuId,
+		WarehouseID: req.WarehouseId,
+		Reason:      req.Reason,
+		RefID:       req.RefId,
+	}
+	if req.CreatedFrom != nil {
+		from := req.CreatedFrom.AsTime()
+		filter.CreatedFrom = &from
+	}
+	if req.CreatedTo != nil {
+		to := req.CreatedTo.AsTime()
+		filter.CreatedTo = &to
+	}
+	if filter.CreatedFrom != nil && filter.CreatedTo != nil && !filter.CreatedFrom.Before(*filter.CreatedTo) {
+		return &inventoryv1.ListStockLogsResponse{
+			Code:    1,
+			Message: "created_from 必须早于 created_to",
+		}, nil
+	}
+
+	logs, nextCursor, err := h.svc.ListStockLogs(ctx, filter, req.Cursor, int(req.PageSize))
+	if err != nil {
+		log.Printf("❌ [InventoryHandler] ListStockLogs: 查询失败 filter=%+v, err=%v", filter, err)
+		return &inventoryv1.ListStockLogsResponse{
+			Code:    1,
+			Message: fmt.Sprintf("查询库存变动明细失败: %v", err),
+		}, nil
+	}
+
+	data := make([]*inventoryv1.StockLog, 0, len(logs))
+	for _, l := range logs {
+		data = append(data, convertStockLogToProto(l))
+	}
+	return &inventoryv1.ListStockLogsResponse{
+		Code:       0,
+		Message:    "success",
+		Logs:       data,
+		NextCursor: nextCursor,
+	}, nil
+}
+
+// GetStockAt 查询 SKU 在指定时间点的库存（管理员）
+func (h *InventoryHandler) GetStockAt(ctx context.Context, req *inventoryv1.GetStockAtRequest) (*inventoryv1.GetStockAtResponse, error) {
+	if !middleware.CheckRole(ctx, "admin") {
+		return &inventoryv1.GetStockAtResponse{
+			Code:    403,
+			Message: "权限不足：需要管理员权限",
+		}, nil
+	}
+	if req.SkuId == "" {
+		return &inventoryv1.GetStockAtResponse{
+			Code:    1,
+			Message: "sku_id 不能为空",
+		}, nil
+	}
+	at := time.Now()
+	if req.At != nil {
+		at = req.At.AsTime()
+	}
+
+	snapshot, err := h.svc.GetStockAt(ctx, req.SkuId, at)
+	if err != nil {
+		log.Printf("❌ [InventoryHandler] GetStockAt: 查询失败 sku_id=%s, at=%v, err=%v", req.SkuId, at, err)
+		return &inventoryv1.GetStockAtResponse{
+			Code:    1,
+			Message: fmt.Sprintf("查询时间点库存失败: %v", err),
+		}, nil
+	}
+	if snapshot == nil {
+		return &inventoryv1.GetStockAtResponse{
+			Code:    1,
+			Message: fmt.Sprintf("SKU %s 的库存不存在", req.SkuId),
+		}, nil
+	}
+
+	return &inventoryv1.GetStockAtResponse{
+		Code:    0,
+		Message: "success",
+		Data: &inventoryv1.StockSnapshot{
+			SkuId:          snapshot.SKUID,
+			At:             timestamppb.New(snapshot.At),
+			AvailableStock: snapshot.AvailableStock,
+			LockedStock:    snapshot.LockedStock,
+			ReplayedLogs:   snapshot.ReplayedLogs,
+		},
+	}, nil
+}
+
+// CheckStockConsistency 库存一致性检查（管理员）
+func (h *InventoryHandler) CheckStockConsistency(ctx context.Context, req *inventoryv1.CheckStockConsistencyRequest) (*inventoryv1.CheckStockConsistencyResponse, error) {
+	if !middleware.CheckRole(ctx, "admin") {
+		return &inventoryv1.CheckStockConsistencyResponse{
+			Code:    403,
+			Message: "权限不足：需要管理员权限",
+		}, nil
+	}
+
+	checked, drifts, err := h.svc.CheckStockConsistency(ctx, req.SkuIds)
+	if err != nil {
+		log.Printf("❌ [InventoryHandler] CheckStockConsistency: 检查失败 sku_ids=%v, err=%v", req.SkuIds, err)
+		return &inventoryv1.CheckStockConsistencyResponse{
+			Code:    1,
+			Message: fmt.Sprintf("库存一致性检查失败: %v", err),
+		}, nil
+	}
+
+	data := make([]*inventoryv1.StockDrift, 0, len(drifts))
+	for _, d := range drifts {
+		data = append(data, &inventoryv1.StockDrift{
+			SkuId:           d.SKUID,
+			AvailableStock:  d.AvailableStock,
+			LedgerAvailable: d.LedgerAvailable,
+			AvailableDrift:  d.AvailableStock - d.LedgerAvailable,
+			LockedStock:     d.LockedStock,
+			LedgerLocked:    d.LedgerLocked,
+			LockedDrift:     d.LockedStock - d.LedgerLocked,
+		})
+	}
+	return &inventoryv1.CheckStockConsistencyResponse{
+		Code:         0,
+		Message:      "success",
+		CheckedCount: checked,
+		Drifts:       data,
+	}, nil
+}
+
+// convertStockLogToProto 转换库存变动明细
+func convertStockLogToProto(l *model.StockLog) *inventoryv1.StockLog {
+	return &inventoryv1.StockLog{
+		Id:           l.ID,
+		SkuId:        l.SKUID,
+		WarehouseId:  l.Warehouse(),
+		ChangeAmount: l.ChangeAmount,
+		LockedChange: l.LockedChange,
+		Reason:       l.Reason,
+		RefId:        l.RefID,
+		ReasonCode:   l.ReasonCode,
+		OperatorId:   l.OperatorID,
+		Remark:       l.Remark,
+		CreatedAt:    timestamppb.New(l.CreatedAt),
+	}
+}
@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"unicode/utf8"
+
+	inventoryv1 "zjMall/gen/go/api/proto/inventory"
+	"zjMall/internal/common/middleware"
+	"zjMall/internal/inventory-service/model"
+	"zjMall/internal/inventory-service/repository"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// 仓库字段长度上限（与 inventory_warehouses 字段长度一致）
+const (
+	maxWarehouseCodeLength     = 32
+	maxWarehouseNameLength     = 100
+	maxWarehouseProvinceLength = 50
+)
+
+// CreateWarehouse 创建仓库（管理员）
+func (h *InventoryHandler) CreateWarehouse(ctx context.Context, req *inventoryv1.CreateWarehouseRequest) (*inventoryv1.CreateWarehouseResponse, error) {
+	if !middleware.CheckRole(ctx, "admin") {
+		return &inventoryv1.CreateWarehouseResponse{
+			Code:    403,
+			Message: "权限不足：需要管理员权限",
+		}, nil
+	}
+	if req.Code == "" {
+		return &inventoryv1.CreateWarehouseResponse{
+			Code:    1,
+			Message: "code 不能为空",
+		}, nil
+	}
+	if len(req.Code) > maxWarehouseCodeLength {
+		return &inventoryv1.CreateWarehouseResponse{
+			Code:    1,
+			Message: fmt.Sprintf("仓库编码不能超过%d个字符", maxWarehouseCodeLength),
+		}, nil
+	}
+	if msg := validateWarehouse(req.Name, req.Province, req.Priority); msg != "" {
+		return &inventoryv1.CreateWarehouseResponse{
+			Code:    1,
+			Message: msg,
+		}, nil
+	}
+
+	warehouse, err := h.svc.CreateWarehouse(ctx, req.Code, req.Name, req.Province, req.Priority)
+	if err != nil {
+		if errors.Is(err, repository.ErrWarehouseExists) {
+			return &inventoryv1.CreateWarehouseResponse{
+				Code:    1,
+				Message: fmt.Sprintf("仓库编码 %s 已存在", req.Code),
+			}, nil
+		}
+		log.Printf("❌ [InventoryHandler] CreateWarehouse: 创建失败 code=%s, err=%v", req.Code, err)
+		return &inventoryv1.CreateWarehouseResponse{
+			Code:    1,
+			Message: fmt.Sprintf("创建仓库失败: %v", err),
+		}, nil
+	}
+
+	log.Printf("✅ [InventoryHandler] CreateWarehouse: 创建成功 id=%s, code=%s", warehouse.ID, warehouse.Code)
+	return &inventoryv1.CreateWarehouseResponse{
+		Code:    0,
+		Message: "success",
+		Data:    convertWarehouseToProto(warehouse),
+	}, nil
+}
+
+// ListWarehouses 查询仓库列表（管理员）
+func (h *InventoryHandler) ListWarehouses(ctx context.Context, req *inventoryv1.ListWarehousesRequest) (*inventoryv1.ListWarehousesResponse, error) {
+	if !middleware.CheckRole(ctx, "admin") {
+		return &inventoryv1.ListWarehousesResponse{
+			Code:    403,
+			Message: "权限不足：需要管理员权限",
+		}, nil
+	}
+
+	warehouses, err := h.svc.ListWarehouses(ctx, int8(req.Status))
+	if err != nil {
+		log.Printf("❌ [InventoryHandler] ListWarehouses: 查询失败 err=%v", err)
+		return &inventoryv1.ListWarehousesResponse{
+			Code:    1,
+			Message: fmt.Sprintf("查询仓库列表失败: %v", err),
+		}, nil
+	}
+
+	data := make([]*inventoryv1.Warehouse, 0, len(warehouses))
+	for _, w := range warehouses {
+		data = append(data, convertWarehouseToProto(w))
+	}
+	return &inventoryv1.ListWarehousesResponse{
+		Code:    0,
+		Message: "success",
+		Data:    data,
+	}, nil
+}
+
+// UpdateWarehouse 更新仓库（管理员）
+func (h *InventoryHandler) UpdateWarehouse(ctx context.Context, req *inventoryv1.UpdateWarehouseRequest) (*inventoryv1.UpdateWarehouseResponse, error) {
+	if !middleware.CheckRole(ctx, "admin") {
+		return &inventoryv1.UpdateWarehouseResponse{
+			Code:    403,
+			Message: "权限不足：需要管理员权限",
+		}, nil
+	}
+	if req.WarehouseId == "" {
+		return &inventoryv1.UpdateWarehouseResponse{
+			Code:    1,
+			Message: "warehouse_id 不能为空",
+		}, nil
+	}
+	if msg := validateWarehouse(req.Name, req.Province, req.Priority); msg != "" {
+		return &inventoryv1.UpdateWarehouseResponse{
+			Code:    1,
+			Message: msg,
+		}, nil
+	}
+	if req.Status != inventoryv1.WarehouseStatus_WAREHOUSE_STATUS_ENABLED && req.Status != inventoryv1.WarehouseStatus_WAREHOUSE_STATUS_DISABLED {
+		return &inventoryv1.UpdateWarehouseResponse{
+			Code:    1,
+			Message: "请选择仓库状态",
+		}, nil
+	}
+
+	warehouse, err := h.svc.UpdateWarehouse(ctx, req.WarehouseId, req.Name, req.Province, req.Priority, int8(req.Status))
+	if err != nil {
+		if errors.Is(err, repository.ErrWarehouseNotFound) {
+			return &inventoryv1.UpdateWarehouseResponse{
+				Code:    1,
+				Message: fmt.Sprintf("仓库 %s 不存在", req.WarehouseId),
+			}, nil
+		}
+		log.Printf("❌ [InventoryHandler] UpdateWarehouse: 更新失败 id=%s, err=%v", req.WarehouseId, err)
+		return &inventoryv1.UpdateWarehouseResponse{
+			Code:    1,
+			Message: fmt.Sprintf("更新仓库失败: %v", err),
+		}, nil
+	}
+
+	log.Printf("✅ [InventoryHandler] UpdateWarehouse: 更新成功 id=%s, priority=%d, status=%d", req.WarehouseId, req.Priority, req.Status)
+	return &inventoryv1.UpdateWarehouseResponse{
+		Code:    0,
+		Message: "success",
+		Data:    convertWarehouseToProto(warehouse),
+	}, nil
+}
+
+// validateWarehouse 校验仓库名称、省份与优先级，返回空字符串表示校验通过
+func validateWarehouse(name, province string, priority int32) string {
+	if name == "" {
+		return "name 不能为空"
+	}
+	if utf8.RuneCountInString(name) > maxWarehouseNameLength {
+		return fmt.Sprintf("仓库名称不能超过%d个字符", maxWarehouseNameLength)
+	}
+	if utf8.RuneCountInString(province) > maxWarehouseProvinceLength {
+		return fmt.Sprintf("省份不能超过%d个字符", maxWarehouseProvinceLength)
+	}
+	if priority < 0 {
+		return "priority 不能小于 0"
+	}
+	return ""
+}
+
+// convertWarehouseToProto 转换仓库
+func convertWarehouseToProto(w *model.Warehouse) *inventoryv1.Warehouse {
+	if w == nil {
+		return nil
+	}
+	return &inventoryv1.Warehouse{
+		Id:        w.ID,
+		Code:      w.Code,
+		Name:      w.Name,
+		Province:  w.Province,
+		Priority:  w.Priority,
+		Status:    inventoryv1.WarehouseStatus(w.Status),
+		CreatedAt: timestamppb.New(w.CreatedAt),
+		UpdatedAt: timestamppb.New(w.UpdatedAt),
+	}
+}
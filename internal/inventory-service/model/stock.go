@@ -2,14 +2,29 @@ package model
 
 import (
 	"time"
+
+	"zjMall/pkg"
+
+	"gorm.io/gorm"
+)
+
+// 库存水位常量（按可用库存与安全库存计算，变化时发布库存事件）
+const (
+	StockLevelNormal = int8(0) // 正常
+	StockLevelLow    = int8(1) // 低库存：可用库存 <= 安全库存
+	StockLevelOut    = int8(2) // 售罄：可用库存为 0
 )
 
-// Stock 库存主表模型
+// Stock 库存主表模型（SKU 维度汇总，等于各仓库存之和，分仓库存见 WarehouseStock）
 // 建议对应表名：inventory_stocks
+// 下单时可用库存转入锁定库存，支付后从锁定库存中扣除（售出），取消或超时关闭时锁定库存转回可用库存
 type Stock struct {
 	ID             string    `gorm:"type:varchar(26);primaryKey;comment:主键ID"`
 	SKUID          string    `gorm:"column:sku_id;type:varchar(26);uniqueIndex;not null;comment:SKU ID" json:"sku_id"`
 	AvailableStock int64     `gorm:"type:int;not null;default:0;comment:可用库存" json:"available_stock"`
+	LockedStock    int64     `gorm:"type:int;not null;default:0;comment:锁定库存（已下单未支付）" json:"locked_stock"`
+	SafetyStock    int64     `gorm:"type:int;not null;default:0;comment:安全库存阈值（0 表示不做低库存预警）" json:"safety_stock"`
+	StockLevel     int8      `gorm:"type:tinyint;not null;default:0;comment:最近一次发布事件时的库存水位：0-正常，1-低库存，2-售罄" json:"stock_level"`
 	Version        int64     `gorm:"type:bigint;not null;default:0;comment:乐观锁版本号" json:"version"`
 	CreatedAt      time.Time `gorm:"comment:创建时间" json:"created_at"`
 	UpdatedAt      time.Time `gorm:"comment:更新时间" json:"updated_at"`
@@ -19,3 +34,22 @@ type Stock struct {
 func (Stock) TableName() string {
 	return "inventory_stocks"
 }
+
+// BeforeCreate GORM 钩子，在插入前自动生成主键 ID
+func (s *Stock) BeforeCreate(tx *gorm.DB) (err error) {
+	if s.ID == "" {
+		s.ID = pkg.GenerateULID()
+	}
+	return nil
+}
+
+// Level 按当前可用库存计算库存水位
+func (s *Stock) Level() int8 {
+	if s.AvailableStock <= 0 {
+		return StockLevelOut
+	}
+	if s.SafetyStock > 0 && s.AvailableStock <= s.SafetyStock {
+		return StockLevelLow
+	}
+	return StockLevelNormal
+}
@@ -8,17 +8,53 @@ import (
 	"gorm.io/gorm"
 )
 
+// 库存变动原因，同一单号同一 SKU 同一原因只记录一次（唯一索引保证幂等）
+const (
+	StockLogReasonDeduct   = "deduct"   // 直接扣减（秒杀活动预扣）
+	StockLogReasonRollback = "rollback" // 回滚 / 回补（秒杀名额归还、售后退货）
+	StockLogReasonReserve  = "reserve"  // 下单锁定：可用 -> 锁定
+	StockLogReasonConfirm  = "confirm"  // 支付确认：扣除锁定库存（售出）
+	StockLogReasonRelease  = "release"  // 取消 / 超时释放：锁定 -> 可用
+
+	StockLogReasonInit      = "init"      // 后台初始化库存
+	StockLogReasonAdjust    = "adjust"    // 后台调整库存（具体原因见 reason_code）
+	StockLogReasonInbound   = "inbound"   // 采购入库
+	StockLogReasonStockTake = "stocktake" // 盘点对账（盘盈 / 盘亏）
+)
+
+// 库存调整原因编码（reason 为 adjust 时记录）
+const (
+	StockAdjustReasonDamage         = "damage"          // 报损
+	StockAdjustReasonLost           = "lost"            // 丢失
+	StockAdjustReasonFound          = "found"           // 找回
+	StockAdjustReasonSupplierReturn = "supplier_return" // 退回供应商
+	StockAdjustReasonCorrection     = "correction"      // 录入纠错
+)
+
 // StockLog 库存变动明细
 // 对应表：inventory_logs
 type StockLog struct {
 	ID           string    `gorm:"type:varchar(26);primaryKey;comment:日志ID"`
 	SKUID        string    `gorm:"column:sku_id;type:varchar(26);index;not null;comment:SKU ID" json:"sku_id"`
+	WarehouseID  string    `gorm:"type:varchar(26);comment:仓库ID（多仓上线前的记录为空，视为默认仓）" json:"warehouse_id"`
 	ChangeAmount int64     `gorm:"type:int;not null;comment:库存变动数量：正数增加，负数减少" json:"change_amount"`
+	LockedChange int64     `gorm:"type:int;not null;default:0;comment:锁定库存变动数量：正数增加，负数减少" json:"locked_change"`
 	Reason       string    `gorm:"type:varchar(50);not null;comment:变动原因" json:"reason"`
 	RefID        string    `gorm:"type:varchar(64);comment:关联单号（订单号/操作单号等）" json:"ref_id"`
+	ReasonCode   string    `gorm:"type:varchar(32);comment:调整原因编码（reason 为 adjust 时记录）" json:"reason_code"`
+	OperatorID   string    `gorm:"type:varchar(26);comment:操作人ID（后台操作时记录管理员ID）" json:"operator_id"`
+	Remark       string    `gorm:"type:varchar(255);comment:备注" json:"remark"`
 	CreatedAt    time.Time `gorm:"comment:创建时间" json:"created_at"`
 }
 
+// Warehouse 库存变动所在仓库（多仓上线前的记录视为默认仓）
+func (s *StockLog) Warehouse() string {
+	if s.WarehouseID == "" {
+		return DefaultWarehouseID
+	}
+	return s.WarehouseID
+}
+
 func (StockLog) TableName() string {
 	return "inventory_logs"
 }
@@ -0,0 +1,68 @@
+package model
+
+import (
+	"time"
+
+	"zjMall/pkg"
+
+	"gorm.io/gorm"
+)
+
+// 仓库状态常量
+const (
+	WarehouseStatusEnabled  = int8(1) // 启用（参与分仓）
+	WarehouseStatusDisabled = int8(2) // 停用（不参与分仓，仍可后台入库、调整、盘点）
+)
+
+// DefaultWarehouseID 默认仓：多仓上线前的库存，以及未指定仓库的后台操作、回补均归属此仓
+const DefaultWarehouseID = "DEFAULT"
+
+// Warehouse 仓库表
+// 对应表：inventory_warehouses
+type Warehouse struct {
+	ID        string    `gorm:"type:varchar(26);primaryKey;comment:仓库ID"`
+	Code      string    `gorm:"type:varchar(32);uniqueIndex;not null;comment:仓库编码" json:"code"`
+	Name      string    `gorm:"type:varchar(100);not null;comment:仓库名称" json:"name"`
+	Province  string    `gorm:"type:varchar(50);comment:所在省份（用于就近分仓）" json:"province"`
+	Priority  int32     `gorm:"type:int;not null;default:0;comment:优先级（数值越小越优先）" json:"priority"`
+	Status    int8      `gorm:"type:tinyint;not null;default:1;comment:仓库状态：1-启用，2-停用" json:"status"`
+	CreatedAt time.Time `gorm:"comment:创建时间" json:"created_at"`
+	UpdatedAt time.Time `gorm:"comment:更新时间" json:"updated_at"`
+}
+
+func (Warehouse) TableName() string {
+	return "inventory_warehouses"
+}
+
+// BeforeCreate GORM 钩子，在插入前自动生成主键 ID
+func (w *Warehouse) BeforeCreate(tx *gorm.DB) (err error) {
+	if w.ID == "" {
+		w.ID = pkg.GenerateULID()
+	}
+	return nil
+}
+
+// WarehouseStock 分仓库存（仓库 + SKU 维度），各仓库存之和等于库存主表
+// 对应表：inventory_warehouse_stocks
+type WarehouseStock struct {
+	ID             string    `gorm:"type:varchar(26);primaryKey;comment:主键ID"`
+	WarehouseID    string    `gorm:"type:varchar(26);uniqueIndex:uk_warehouse_sku,priority:1;not null;comment:仓库ID" json:"warehouse_id"`
+	SKUID          string    `gorm:"column:sku_id;type:varchar(26);uniqueIndex:uk_warehouse_sku,priority:2;index;not null;comment:SKU ID" json:"sku_id"`
+	AvailableStock int64     `gorm:"type:int;not null;default:0;comment:可用库存" json:"available_stock"`
+	LockedStock    int64     `gorm:"type:int;not null;default:0;comment:锁定库存（已下单未支付）" json:"locked_stock"`
+	Version        int64     `gorm:"type:bigint;not null;default:0;comment:乐观锁版本号" json:"version"`
+	CreatedAt      time.Time `gorm:"comment:创建时间" json:"created_at"`
+	UpdatedAt      time.Time `gorm:"comment:更新时间" json:"updated_at"`
+}
+
+func (WarehouseStock) TableName() string {
+	return "inventory_warehouse_stocks"
+}
+
+// BeforeCreate GORM 钩子，在插入前自动生成主键 ID
+func (s *WarehouseStock) BeforeCreate(tx *gorm.DB) (err error) {
+	if s.ID == "" {
+		s.ID = pkg.GenerateULID()
+	}
+	return nil
+}
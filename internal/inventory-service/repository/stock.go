@@ -5,17 +5,21 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
+	"time"
 
 	"zjMall/internal/inventory-service/model"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-// DeductItem 表示单个 SKU 的扣减请求
+// DeductItem 表示单个 SKU 的扣减请求（WarehouseID 为分仓结果，为空表示默认仓）
 type DeductItem struct {
-	SKUID    string
-	Quantity int64
+	SKUID       string
+	Quantity    int64
+	WarehouseID string
 }
 
 // StockRepository 库存仓储接口
@@ -26,12 +30,43 @@ type StockRepository interface {
 	BatchGetBySKUID(ctx context.Context, skuIDs []string) (map[string]*model.Stock, error)
 	// TryDeductStocks 批量尝试扣减库存（使用乐观锁，防止超卖，支持幂等性检查）
 	// orderNo: 订单号，用于日志记录和幂等性检查
-	// items: 需要扣减的SKU列表
-	TryDeductStocks(ctx context.Context, orderNo string, items []DeductItem) error
+	// items: 需要扣减的SKU列表（已分仓），返回实际生效的分仓结果（幂等跳过的 SKU 以首次扣减为准）
+	TryDeductStocks(ctx context.Context, orderNo string, items []DeductItem) ([]DeductItem, error)
 	// RollbackStocks 批量回滚库存（加回）
-	// orderNo: 订单号，用于日志记录
-	// items: 需要回滚的SKU列表
+	// orderNo: 订单号或售后单号，用于日志记录和幂等性检查（同一单号同一 SKU 只回滚一次）
+	// items: 需要回滚的SKU列表（未指定仓库时回补到同一单号扣减 / 锁定时的仓库，否则回补到默认仓）
 	RollbackStocks(ctx context.Context, orderNo string, items []DeductItem) error
+	// ReserveStocks 下单锁定库存：可用库存转入锁定库存（乐观锁防超卖，按订单号幂等），返回实际生效的分仓结果
+	ReserveStocks(ctx context.Context, orderNo string, items []DeductItem) ([]DeductItem, error)
+	// ListAllocations 查询单号已生效的分仓结果（reason 为 deduct 或 reserve），用于重复请求时直接返回；也用于查询 release / rollback 日志涉及的 SKU
+	ListAllocations(ctx context.Context, orderNo string, reason string) ([]DeductItem, error)
+	// ConfirmStocks 支付确认：扣除订单锁定的全部库存（按订单号幂等，数量以锁定记录为准）
+	ConfirmStocks(ctx context.Context, orderNo string) error
+	// ReleaseStocks 取消 / 超时释放：订单锁定的全部库存转回可用库存（按订单号幂等，数量以锁定记录为准）
+	ReleaseStocks(ctx context.Context, orderNo string) error
+
+	// InitStock 初始化 SKU 在 op.WarehouseID 仓库的库存并记录初始化日志，该仓库已有库存时返回 ErrStockExists
+	InitStock(ctx context.Context, stock *model.Stock, op StockOperation) error
+	// AdjustStock 按调整单号调整 op.WarehouseID 仓库的可用库存（delta 可为负数，调整后不能小于 0），同一调整单号只生效一次
+	AdjustStock(ctx context.Context, skuID string, delta int64, reasonCode string, op StockOperation) (*model.Stock, error)
+	// InboundStocks 批量入库：增加 op.WarehouseID 仓库的可用库存（按入库单号幂等）
+	InboundStocks(ctx context.Context, items []DeductItem, op StockOperation) error
+	// StockTake 按 op.WarehouseID 仓库的实盘数量对账（counts 的 Quantity 为实盘数量）：差异计入可用库存并记录盘点日志，盘点单已提交时返回 ErrStockTakeSubmitted
+	StockTake(ctx context.Context, counts []DeductItem, op StockOperation) ([]*StockTakeResult, error)
+
+	// ListStockLogs 按 ID 倒序游标分页查询库存日志（afterID 为上一页最后一条日志的 ID，为空表示第一页）
+	ListStockLogs(ctx context.Context, filter StockLogFilter, afterID string, limit int) ([]*model.StockLog, error)
+	// GetStockAt 以当前库存为基准倒推 at 之后的变动，得到 at 时间点的库存（库存不存在时返回 nil, nil）
+	GetStockAt(ctx context.Context, skuID string, at time.Time) (*StockSnapshot, error)
+	// ListStockSKUIDs 按 SKU ID 升序分页列出已建立库存的 SKU（afterSKUID 为空表示第一页）
+	ListStockSKUIDs(ctx context.Context, afterSKUID string, limit int) ([]string, error)
+	// GetLedgerBalances 在同一快照内读取库存与库存日志汇总，用于一致性检查（不存在的 SKU 不返回）
+	GetLedgerBalances(ctx context.Context, skuIDs []string) ([]*StockLedgerBalance, error)
+
+	// SetSafetyStock 设置 SKU 的安全库存阈值，库存不存在时返回 ErrStockNotFound
+	SetSafetyStock(ctx context.Context, skuID string, safetyStock int64) (*model.Stock, error)
+	// CompareAndSetStockLevel 当库存水位仍为 from 时更新为 to，返回是否更新成功（用于保证同一次水位变化只发布一次事件）
+	CompareAndSetStockLevel(ctx context.Context, skuID string, from, to int8) (bool, error)
 }
 
 type stockRepository struct {
@@ -79,24 +114,25 @@ func (r *stockRepository) BatchGetBySKUID(ctx context.Context, skuIDs []string)
 }
 
 // TryDeductStocks 批量尝试扣减库存（使用乐观锁，防止超卖，支持幂等性检查）
-func (r *stockRepository) TryDeductStocks(ctx context.Context, orderNo string, items []DeductItem) error {
+func (r *stockRepository) TryDeductStocks(ctx context.Context, orderNo string, items []DeductItem) ([]DeductItem, error) {
 	if orderNo == "" {
-		return fmt.Errorf("订单号不能为空")
+		return nil, fmt.Errorf("订单号不能为空")
 	}
+	items = mergeDeductItems(items)
 	if len(items) == 0 {
-		return fmt.Errorf("扣减项不能为空")
+		return nil, fmt.Errorf("扣减项不能为空")
 	}
 
 	// 参数校验
 	for _, item := range items {
 		if item.SKUID == "" || item.Quantity <= 0 {
-			return fmt.Errorf("非法库存扣减请求: sku_id=%s, quantity=%d", item.SKUID, item.Quantity)
+			return nil, fmt.Errorf("非法库存扣减请求: sku_id=%s, quantity=%d", item.SKUID, item.Quantity)
 		}
 	}
 
 	tx := r.db.WithContext(ctx).Begin()
 	if tx.Error != nil {
-		return tx.Error
+		return nil, tx.Error
 	}
 	defer func() {
 		if r := recover(); r != nil {
@@ -114,7 +150,7 @@ func (r *stockRepository) TryDeductStocks(ctx context.Context, orderNo string, i
 	var stocks []model.Stock
 	if err := tx.Where("sku_id IN ?", skuIDs).Find(&stocks).Error; err != nil {
 		tx.Rollback()
-		return fmt.Errorf("查询库存失败: %w", err)
+		return nil, fmt.Errorf("查询库存失败: %w", err)
 	}
 
 	// 构建 SKU ID 到库存记录的映射
@@ -130,13 +166,13 @@ func (r *stockRepository) TryDeductStocks(ctx context.Context, orderNo string, i
 		stock, exists := stockMap[item.SKUID]
 		if !exists {
 			tx.Rollback()
-			return fmt.Errorf("SKU %s 的库存记录不存在", item.SKUID)
+			return nil, fmt.Errorf("SKU %s 的库存记录不存在", item.SKUID)
 		}
 
 		// 检查库存是否充足
 		if stock.AvailableStock < item.Quantity {
 			tx.Rollback()
-			return fmt.Errorf("SKU %s 库存不足: 当前库存=%d, 需要扣减=%d", item.SKUID, stock.AvailableStock, item.Quantity)
+			return nil, fmt.Errorf("SKU %s 库存不足: 当前库存=%d, 需要扣减=%d", item.SKUID, stock.AvailableStock, item.Quantity)
 		}
 
 		toDeduct = append(toDeduct, item)
@@ -145,7 +181,7 @@ func (r *stockRepository) TryDeductStocks(ctx context.Context, orderNo string, i
 	// 如果没有需要扣减的项，直接提交事务
 	if len(toDeduct) == 0 {
 		tx.Commit()
-		return nil
+		return nil, nil
 	}
 
 	// 批量更新库存（使用乐观锁）
@@ -153,28 +189,34 @@ func (r *stockRepository) TryDeductStocks(ctx context.Context, orderNo string, i
 	// UPDATE inventory_stocks
 	// SET available_stock = available_stock - ?, version = version + 1
 	// WHERE sku_id = ? AND available_stock >= ? AND version = ?
+	allocations := make([]DeductItem, 0, len(toDeduct))
 	for _, item := range toDeduct {
 		stock := stockMap[item.SKUID]
+		item.WarehouseID = warehouseOrDefault(item.WarehouseID)
 
 		// 先尝试插入 log（幂等性检查：如果已存在，说明已经扣减过）
 		logEntry := &model.StockLog{
 			SKUID:        item.SKUID,
+			WarehouseID:  item.WarehouseID,
 			ChangeAmount: -item.Quantity,
-			Reason:       "deduct",
+			Reason:       model.StockLogReasonDeduct,
 			RefID:        orderNo,
 		}
 		if err := tx.Create(logEntry).Error; err != nil {
 			// 检查是否是唯一索引冲突（幂等性：同一个订单号重复扣减）
-			if errors.Is(err, gorm.ErrDuplicatedKey) ||
-				strings.Contains(err.Error(), "Duplicate entry") ||
-				strings.Contains(err.Error(), "UNIQUE constraint") ||
-				strings.Contains(err.Error(), "duplicate key") {
-				// 幂等：已经扣减过，跳过
+			if isDuplicateKeyError(err) {
+				// 幂等：已经扣减过，跳过，分仓结果以首次扣减为准
 				log.Printf("ℹ️ [StockRepository] TryDeductStocks: 订单 %s 已扣减过 SKU %s 的库存，幂等跳过", orderNo, item.SKUID)
+				existing, err := getStockLog(tx, item.SKUID, orderNo, model.StockLogReasonDeduct)
+				if err != nil {
+					tx.Rollback()
+					return nil, err
+				}
+				allocations = append(allocations, allocationOf(existing))
 				continue
 			}
 			tx.Rollback()
-			return fmt.Errorf("写入库存日志失败 sku_id=%s: %w", item.SKUID, err)
+			return nil, fmt.Errorf("写入库存日志失败 sku_id=%s: %w", item.SKUID, err)
 		}
 
 		// log 插入成功，执行库存扣减
@@ -187,7 +229,7 @@ func (r *stockRepository) TryDeductStocks(ctx context.Context, orderNo string, i
 
 		if res.Error != nil {
 			tx.Rollback()
-			return fmt.Errorf("扣减库存失败 sku_id=%s: %w", item.SKUID, res.Error)
+			return nil, fmt.Errorf("扣减库存失败 sku_id=%s: %w", item.SKUID, res.Error)
 		}
 		if res.RowsAffected == 0 {
 			tx.Rollback()
@@ -195,11 +237,21 @@ func (r *stockRepository) TryDeductStocks(ctx context.Context, orderNo string, i
 			// 1. version 不匹配（乐观锁冲突，被其他请求修改）
 			// 2. available_stock < quantity（库存不足）
 			// 3. sku_id 不存在（但前面已经检查过，理论上不会发生）
-			return fmt.Errorf("SKU %s 库存扣减失败: 可能被其他请求并发修改（乐观锁冲突）或库存不足（当前库存可能已不足 %d）", item.SKUID, item.Quantity)
+			return nil, fmt.Errorf("SKU %s 库存扣减失败: 可能被其他请求并发修改（乐观锁冲突）或库存不足（当前库存可能已不足 %d）", item.SKUID, item.Quantity)
 		}
+
+		// 同步扣减分仓库存
+		if err := updateWarehouseStock(tx, item.WarehouseID, item.SKUID, -item.Quantity, 0); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		allocations = append(allocations, item)
 	}
 
-	return tx.Commit().Error
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return allocations, nil
 }
 
 // RollbackStocks 批量回滚库存（加回）
@@ -247,51 +299,412 @@ func (r *stockRepository) RollbackStocks(ctx context.Context, orderNo string, it
 		stockMap[stocks[i].SKUID] = &stocks[i]
 	}
 
-	// 批量更新库存（使用乐观锁）
+	// 未指定仓库时回补到同一单号扣减 / 锁定时的仓库
+	var sourceLogs []*model.StockLog
+	if err := tx.Where("ref_id = ? AND sku_id IN ? AND reason IN ?", orderNo, skuIDs,
+		[]string{model.StockLogReasonDeduct, model.StockLogReasonReserve}).
+		Find(&sourceLogs).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("查询库存日志失败: %w", err)
+	}
+	sourceWarehouses := make(map[string]string, len(sourceLogs))
+	for _, l := range sourceLogs {
+		sourceWarehouses[l.SKUID] = l.Warehouse()
+	}
+
+	// 批量回滚库存
+	// 先插入 log 做幂等检查（与扣减保持一致），已回滚过的 SKU 不再重复加回库存；
+	// 加回库存是累加操作，不依赖读取到的旧值，因此不需要版本号条件
 	for _, item := range items {
-		stock, exists := stockMap[item.SKUID]
-		if !exists {
+		if _, exists := stockMap[item.SKUID]; !exists {
 			log.Printf("⚠️ RollbackStocks: 未找到库存记录 sku_id=%s，跳过回滚", item.SKUID)
 			continue
 		}
+		warehouseID := item.WarehouseID
+		if warehouseID == "" {
+			warehouseID = warehouseOrDefault(sourceWarehouses[item.SKUID])
+		}
+
+		logEntry := &model.StockLog{
+			SKUID:        item.SKUID,
+			WarehouseID:  warehouseID,
+			ChangeAmount: +item.Quantity,
+			Reason:       model.StockLogReasonRollback,
+			RefID:        orderNo,
+		}
+		if err := tx.Create(logEntry).Error; err != nil {
+			// 检查是否是唯一索引冲突（幂等性：同一个单号重复回滚）
+			if isDuplicateKeyError(err) {
+				// 幂等：已经回滚过，跳过
+				log.Printf("ℹ️ [StockRepository] RollbackStocks: 单号 %s 已回滚过 SKU %s 的库存，幂等跳过", orderNo, item.SKUID)
+				continue
+			}
+			tx.Rollback()
+			return fmt.Errorf("写入库存日志失败 sku_id=%s: %w", item.SKUID, err)
+		}
 
 		res := tx.Model(&model.Stock{}).
-			Where("sku_id = ? AND version = ?", item.SKUID, stock.Version).
+			Where("sku_id = ?", item.SKUID).
 			Updates(map[string]interface{}{
 				"available_stock": gorm.Expr("available_stock + ?", item.Quantity),
 				"version":         gorm.Expr("version + 1"),
 			})
-
 		if res.Error != nil {
 			tx.Rollback()
 			return fmt.Errorf("回滚库存失败 sku_id=%s: %w", item.SKUID, res.Error)
 		}
-		if res.RowsAffected == 0 {
-			log.Printf("⚠️ RollbackStocks: SKU %s 回滚失败: 可能被其他请求并发修改（乐观锁冲突），跳过", item.SKUID)
-			continue
+		if err := updateWarehouseStock(tx, warehouseID, item.SKUID, item.Quantity, 0); err != nil {
+			tx.Rollback()
+			return err
 		}
+	}
 
-		// 先尝试插入 log（幂等性检查：如果已存在，说明已经回滚过）
-		logEntry := &model.StockLog{
-			SKUID:        item.SKUID,
-			ChangeAmount: +item.Quantity,
-			Reason:       "rollback",
-			RefID:        orderNo,
+	return tx.Commit().Error
+}
+
+// ReserveStocks 下单锁定库存：available_stock -> locked_stock
+func (r *stockRepository) ReserveStocks(ctx context.Context, orderNo string, items []DeductItem) ([]DeductItem, error) {
+	if orderNo == "" {
+		return nil, fmt.Errorf("订单号不能为空")
+	}
+	items = mergeDeductItems(items)
+	if len(items) == 0 {
+		return nil, fmt.Errorf("锁定项不能为空")
+	}
+	for _, item := range items {
+		if item.SKUID == "" || item.Quantity <= 0 {
+			return nil, fmt.Errorf("非法库存锁定请求: sku_id=%s, quantity=%d", item.SKUID, item.Quantity)
 		}
-		if err := tx.Create(logEntry).Error; err != nil {
-			// 检查是否是唯一索引冲突（幂等性：同一个订单号重复回滚）
-			if errors.Is(err, gorm.ErrDuplicatedKey) ||
-				strings.Contains(err.Error(), "Duplicate entry") ||
-				strings.Contains(err.Error(), "UNIQUE constraint") ||
-				strings.Contains(err.Error(), "duplicate key") {
-				// 幂等：已经回滚过，跳过
-				log.Printf("ℹ️ [StockRepository] RollbackStocks: 订单 %s 已回滚过 SKU %s 的库存，幂等跳过", orderNo, item.SKUID)
+	}
+
+	allocations := make([]DeductItem, 0, len(items))
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		skuIDs := make([]string, 0, len(items))
+		for _, item := range items {
+			skuIDs = append(skuIDs, item.SKUID)
+		}
+		var stocks []model.Stock
+		if err := tx.Where("sku_id IN ?", skuIDs).Find(&stocks).Error; err != nil {
+			return fmt.Errorf("查询库存失败: %w", err)
+		}
+		stockMap := make(map[string]*model.Stock, len(stocks))
+		for i := range stocks {
+			stockMap[stocks[i].SKUID] = &stocks[i]
+		}
+
+		for _, item := range items {
+			stock, exists := stockMap[item.SKUID]
+			if !exists {
+				return fmt.Errorf("SKU %s 的库存记录不存在", item.SKUID)
+			}
+			item.WarehouseID = warehouseOrDefault(item.WarehouseID)
+
+			// 先写日志做幂等检查：同一订单号已锁定过的 SKU 直接跳过，分仓结果以首次锁定为准
+			logEntry := &model.StockLog{
+				SKUID:        item.SKUID,
+				WarehouseID:  item.WarehouseID,
+				ChangeAmount: -item.Quantity,
+				LockedChange: item.Quantity,
+				Reason:       model.StockLogReasonReserve,
+				RefID:        orderNo,
+			}
+			if err := tx.Create(logEntry).Error; err != nil {
+				if isDuplicateKeyError(err) {
+					log.Printf("ℹ️ [StockRepository] ReserveStocks: 订单 %s 已锁定过 SKU %s 的库存，幂等跳过", orderNo, item.SKUID)
+					existing, err := getStockLog(tx, item.SKUID, orderNo, model.StockLogReasonReserve)
+					if err != nil {
+						return err
+					}
+					allocations = append(allocations, allocationOf(existing))
+					continue
+				}
+				return fmt.Errorf("写入库存日志失败 sku_id=%s: %w", item.SKUID, err)
+			}
+
+			if stock.AvailableStock < item.Quantity {
+				return fmt.Errorf("SKU %s 库存不足: 当前库存=%d, 需要锁定=%d", item.SKUID, stock.AvailableStock, item.Quantity)
+			}
+			res := tx.Model(&model.Stock{}).
+				Where("sku_id = ? AND available_stock >= ? AND version = ?", item.SKUID, item.Quantity, stock.Version).
+				Updates(map[string]interface{}{
+					"available_stock": gorm.Expr("available_stock - ?", item.Quantity),
+					"locked_stock":    gorm.Expr("locked_stock + ?", item.Quantity),
+					"version":         gorm.Expr("version + 1"),
+				})
+			if res.Error != nil {
+				return fmt.Errorf("锁定库存失败 sku_id=%s: %w", item.SKUID, res.Error)
+			}
+			if res.RowsAffected == 0 {
+				return fmt.Errorf("SKU %s 库存锁定失败: 可能被其他请求并发修改（乐观锁冲突）或库存不足（当前库存可能已不足 %d）", item.SKUID, item.Quantity)
+			}
+			if err := updateWarehouseStock(tx, item.WarehouseID, item.SKUID, -item.Quantity, item.Quantity); err != nil {
+				return err
+			}
+			allocations = append(allocations, item)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return allocations, nil
+}
+
+// ConfirmStocks 支付确认：扣除订单锁定的库存（locked_stock 减少，available_stock 不变）
+// 订单没有锁定记录时（两阶段库存上线前按旧流程直接扣减的订单）无需处理
+func (r *stockRepository) ConfirmStocks(ctx context.Context, orderNo string) error {
+	if orderNo == "" {
+		return fmt.Errorf("订单号不能为空")
+	}
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		logs, err := lockOrderStockLogs(tx, orderNo)
+		if err != nil {
+			return err
+		}
+		reserves := logs[model.StockLogReasonReserve]
+		if len(reserves) == 0 {
+			log.Printf("ℹ️ [StockRepository] ConfirmStocks: 订单 %s 没有锁定记录，跳过", orderNo)
+			return nil
+		}
+
+		for _, skuID := range sortedSKUIDs(reserves) {
+			reserve := reserves[skuID]
+			if _, released := logs[model.StockLogReasonRelease][skuID]; released {
+				return fmt.Errorf("订单 %s 的 SKU %s 锁定库存已释放，不能确认", orderNo, skuID)
+			}
+			if _, confirmed := logs[model.StockLogReasonConfirm][skuID]; confirmed {
 				continue
 			}
-			tx.Rollback()
-			return fmt.Errorf("写入库存日志失败 sku_id=%s: %w", item.SKUID, err)
+
+			quantity := reserve.LockedChange
+			if err := tx.Create(&model.StockLog{
+				SKUID:        skuID,
+				WarehouseID:  reserve.Warehouse(),
+				ChangeAmount: 0,
+				LockedChange: -quantity,
+				Reason:       model.StockLogReasonConfirm,
+				RefID:        orderNo,
+			}).Error; err != nil {
+				if isDuplicateKeyError(err) {
+					continue
+				}
+				return fmt.Errorf("写入库存日志失败 sku_id=%s: %w", skuID, err)
+			}
+
+			res := tx.Model(&model.Stock{}).
+				Where("sku_id = ? AND locked_stock >= ?", skuID, quantity).
+				Updates(map[string]interface{}{
+					"locked_stock": gorm.Expr("locked_stock - ?", quantity),
+					"version":      gorm.Expr("version + 1"),
+				})
+			if res.Error != nil {
+				return fmt.Errorf("确认库存失败 sku_id=%s: %w", skuID, res.Error)
+			}
+			if res.RowsAffected == 0 {
+				return fmt.Errorf("SKU %s 锁定库存不足 %d，无法确认", skuID, quantity)
+			}
+			if err := updateWarehouseStock(tx, reserve.Warehouse(), skuID, 0, -quantity); err != nil {
+				return err
+			}
 		}
+		return nil
+	})
+}
+
+// ReleaseStocks 取消 / 超时释放：订单锁定的库存转回可用库存
+// 订单没有锁定记录但有直接扣减记录时（两阶段库存上线前创建的订单），按回滚处理加回可用库存
+func (r *stockRepository) ReleaseStocks(ctx context.Context, orderNo string) error {
+	if orderNo == "" {
+		return fmt.Errorf("订单号不能为空")
 	}
 
-	return tx.Commit().Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		logs, err := lockOrderStockLogs(tx, orderNo)
+		if err != nil {
+			return err
+		}
+		reserves := logs[model.StockLogReasonReserve]
+		if len(reserves) == 0 {
+			return rollbackLegacyDeducts(tx, orderNo, logs)
+		}
+
+		for _, skuID := range sortedSKUIDs(reserves) {
+			reserve := reserves[skuID]
+			if _, confirmed := logs[model.StockLogReasonConfirm][skuID]; confirmed {
+				return fmt.Errorf("订单 %s 的 SKU %s 锁定库存已确认售出，不能释放", orderNo, skuID)
+			}
+			if _, released := logs[model.StockLogReasonRelease][skuID]; released {
+				continue
+			}
+
+			quantity := reserve.LockedChange
+			if err := tx.Create(&model.StockLog{
+				SKUID:        skuID,
+				WarehouseID:  reserve.Warehouse(),
+				ChangeAmount: quantity,
+				LockedChange: -quantity,
+				Reason:       model.StockLogReasonRelease,
+				RefID:        orderNo,
+			}).Error; err != nil {
+				if isDuplicateKeyError(err) {
+					continue
+				}
+				return fmt.Errorf("写入库存日志失败 sku_id=%s: %w", skuID, err)
+			}
+
+			res := tx.Model(&model.Stock{}).
+				Where("sku_id = ? AND locked_stock >= ?", skuID, quantity).
+				Updates(map[string]interface{}{
+					"available_stock": gorm.Expr("available_stock + ?", quantity),
+					"locked_stock":    gorm.Expr("locked_stock - ?", quantity),
+					"version":         gorm.Expr("version + 1"),
+				})
+			if res.Error != nil {
+				return fmt.Errorf("释放库存失败 sku_id=%s: %w", skuID, res.Error)
+			}
+			if res.RowsAffected == 0 {
+				return fmt.Errorf("SKU %s 锁定库存不足 %d，无法释放", skuID, quantity)
+			}
+			if err := updateWarehouseStock(tx, reserve.Warehouse(), skuID, quantity, -quantity); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListAllocations 查询单号已生效的分仓结果
+func (r *stockRepository) ListAllocations(ctx context.Context, orderNo string, reason string) ([]DeductItem, error) {
+	var logs []*model.StockLog
+	if err := r.db.WithContext(ctx).
+		Where("ref_id = ? AND reason = ?", orderNo, reason).
+		Order("sku_id ASC").
+		Find(&logs).Error; err != nil {
+		return nil, fmt.Errorf("查询库存日志失败: %w", err)
+	}
+	allocations := make([]DeductItem, 0, len(logs))
+	for _, l := range logs {
+		allocations = append(allocations, allocationOf(l))
+	}
+	return allocations, nil
+}
+
+// getStockLog 查询单号下某个 SKU 指定原因的库存日志
+func getStockLog(tx *gorm.DB, skuID, refID, reason string) (*model.StockLog, error) {
+	var l model.StockLog
+	if err := tx.Where("sku_id = ? AND ref_id = ? AND reason = ?", skuID, refID, reason).First(&l).Error; err != nil {
+		return nil, fmt.Errorf("查询库存日志失败 sku_id=%s: %w", skuID, err)
+	}
+	return &l, nil
+}
+
+// allocationOf 由扣减 / 锁定日志还原分仓结果
+func allocationOf(l *model.StockLog) DeductItem {
+	quantity := -l.ChangeAmount
+	if l.Reason == model.StockLogReasonReserve {
+		quantity = l.LockedChange
+	}
+	return DeductItem{
+		SKUID:       l.SKUID,
+		Quantity:    quantity,
+		WarehouseID: l.Warehouse(),
+	}
+}
+
+// warehouseOrDefault 未指定仓库时使用默认仓
+func warehouseOrDefault(warehouseID string) string {
+	if warehouseID == "" {
+		return model.DefaultWarehouseID
+	}
+	return warehouseID
+}
+
+// lockOrderStockLogs 加锁读取单号下的库存日志，按 原因 -> SKU 分组
+// 加锁读保证同一订单的确认与释放串行执行，并能读到对方已提交的日志
+func lockOrderStockLogs(tx *gorm.DB, orderNo string) (map[string]map[string]*model.StockLog, error) {
+	var logs []*model.StockLog
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("ref_id = ?", orderNo).
+		Find(&logs).Error; err != nil {
+		return nil, fmt.Errorf("查询库存日志失败: %w", err)
+	}
+	grouped := make(map[string]map[string]*model.StockLog)
+	for _, l := range logs {
+		if grouped[l.Reason] == nil {
+			grouped[l.Reason] = make(map[string]*model.StockLog)
+		}
+		grouped[l.Reason][l.SKUID] = l
+	}
+	return grouped, nil
+}
+
+// rollbackLegacyDeducts 回滚旧流程直接扣减且尚未回滚的库存
+func rollbackLegacyDeducts(tx *gorm.DB, orderNo string, logs map[string]map[string]*model.StockLog) error {
+	deducts := logs[model.StockLogReasonDeduct]
+	for _, skuID := range sortedSKUIDs(deducts) {
+		deduct := deducts[skuID]
+		if _, rolledBack := logs[model.StockLogReasonRollback][skuID]; rolledBack {
+			continue
+		}
+		quantity := -deduct.ChangeAmount
+		if err := tx.Create(&model.StockLog{
+			SKUID:        skuID,
+			WarehouseID:  deduct.Warehouse(),
+			ChangeAmount: quantity,
+			Reason:       model.StockLogReasonRollback,
+			RefID:        orderNo,
+		}).Error; err != nil {
+			if isDuplicateKeyError(err) {
+				continue
+			}
+			return fmt.Errorf("写入库存日志失败 sku_id=%s: %w", skuID, err)
+		}
+		if err := tx.Model(&model.Stock{}).
+			Where("sku_id = ?", skuID).
+			Updates(map[string]interface{}{
+				"available_stock": gorm.Expr("available_stock + ?", quantity),
+				"version":         gorm.Expr("version + 1"),
+			}).Error; err != nil {
+			return fmt.Errorf("回滚库存失败 sku_id=%s: %w", skuID, err)
+		}
+		if err := updateWarehouseStock(tx, deduct.Warehouse(), skuID, quantity, 0); err != nil {
+			return err
+		}
+		log.Printf("ℹ️ [StockRepository] ReleaseStocks: 订单 %s 为直接扣减的旧订单，已回滚 SKU %s 的库存 %d", orderNo, skuID, quantity)
+	}
+	return nil
+}
+
+// sortedSKUIDs 按 SKU ID 排序，保证并发事务以相同顺序更新库存行，避免死锁
+func sortedSKUIDs(logs map[string]*model.StockLog) []string {
+	skuIDs := make([]string, 0, len(logs))
+	for skuID := range logs {
+		skuIDs = append(skuIDs, skuID)
+	}
+	sort.Strings(skuIDs)
+	return skuIDs
+}
+
+// mergeDeductItems 合并同一 SKU 的多行数量（同一单号同一 SKU 只能记录一条日志）
+func mergeDeductItems(items []DeductItem) []DeductItem {
+	merged := make([]DeductItem, 0, len(items))
+	index := make(map[string]int, len(items))
+	for _, item := range items {
+		if i, ok := index[item.SKUID]; ok {
+			merged[i].Quantity += item.Quantity
+			continue
+		}
+		index[item.SKUID] = len(merged)
+		merged = append(merged, item)
+	}
+	return merged
+}
+
+// isDuplicateKeyError 是否为唯一索引冲突（库存日志按 sku_id + ref_id + reason 唯一，用于幂等）
+func isDuplicateKeyError(err error) bool {
+	return errors.Is(err, gorm.ErrDuplicatedKey) ||
+		strings.Contains(err.Error(), "Duplicate entry") ||
+		strings.Contains(err.Error(), "UNIQUE constraint") ||
+		strings.Contains(err.Error(), "duplicate key")
 }
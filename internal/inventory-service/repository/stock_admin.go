@@ -0,0 +1,293 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"zjMall/internal/inventory-service/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrStockExists SKU 在该仓库的库存已初始化
+	ErrStockExists = errors.New("库存已存在")
+	// ErrStockNotFound SKU 在该仓库的库存未初始化
+	ErrStockNotFound = errors.New("库存不存在")
+	// ErrStockTakeSubmitted 盘点单已提交
+	ErrStockTakeSubmitted = errors.New("盘点单已提交")
+)
+
+// StockOperation 后台库存操作的公共信息（写入库存日志）
+type StockOperation struct {
+	WarehouseID string // 操作仓库ID（为空表示默认仓）
+	RefID       string // 操作单号：入库单号 / 盘点单号 / 调整单号，用于幂等和审计
+	OperatorID  string // 操作人ID
+	Remark      string // 备注
+}
+
+// StockTakeResult 单个 SKU 的盘点结果
+type StockTakeResult struct {
+	SKUID           string
+	BookQuantity    int64 // 账面数量（该仓库的可用 + 锁定）
+	CountedQuantity int64 // 实盘数量
+	Difference      int64 // 差异：正数为盘盈，负数为盘亏
+}
+
+// newOperationLog 构建后台操作的库存日志
+func newOperationLog(skuID string, change int64, reason string, op StockOperation) *model.StockLog {
+	return &model.StockLog{
+		SKUID:        skuID,
+		WarehouseID:  warehouseOrDefault(op.WarehouseID),
+		ChangeAmount: change,
+		Reason:       reason,
+		RefID:        op.RefID,
+		OperatorID:   op.OperatorID,
+		Remark:       op.Remark,
+	}
+}
+
+// InitStock 初始化 SKU 在指定仓库的库存：创建分仓库存，并累加到库存主表（主表不存在时创建）
+func (r *stockRepository) InitStock(ctx context.Context, stock *model.Stock, op StockOperation) error {
+	op.WarehouseID = warehouseOrDefault(op.WarehouseID)
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		row := &model.WarehouseStock{
+			WarehouseID:    op.WarehouseID,
+			SKUID:          stock.SKUID,
+			AvailableStock: stock.AvailableStock,
+		}
+		if err := tx.Create(row).Error; err != nil {
+			if isDuplicateKeyError(err) {
+				return ErrStockExists
+			}
+			return fmt.Errorf("创建分仓库存失败 sku_id=%s: %w", stock.SKUID, err)
+		}
+
+		if err := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "sku_id"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"available_stock": gorm.Expr("available_stock + ?", stock.AvailableStock),
+				"version":         gorm.Expr("version + 1"),
+			}),
+		}).Create(stock).Error; err != nil {
+			return fmt.Errorf("创建库存失败 sku_id=%s: %w", stock.SKUID, err)
+		}
+		// 主表已存在时 stock 中的ID为新生成的值，需按 sku_id 重新读取
+		var current model.Stock
+		if err := tx.Where("sku_id = ?", stock.SKUID).First(&current).Error; err != nil {
+			return fmt.Errorf("查询库存失败 sku_id=%s: %w", stock.SKUID, err)
+		}
+		*stock = current
+
+		if op.RefID == "" {
+			op.RefID = row.ID
+		}
+		if err := tx.Create(newOperationLog(stock.SKUID, row.AvailableStock, model.StockLogReasonInit, op)).Error; err != nil {
+			return fmt.Errorf("写入库存日志失败 sku_id=%s: %w", stock.SKUID, err)
+		}
+		return nil
+	})
+}
+
+// AdjustStock 调整指定仓库的可用库存（加锁读取分仓库存行，保证调整后不为负数），返回调整后的库存主表
+func (r *stockRepository) AdjustStock(ctx context.Context, skuID string, delta int64, reasonCode string, op StockOperation) (*model.Stock, error) {
+	if skuID == "" || delta == 0 {
+		return nil, fmt.Errorf("非法库存调整请求: sku_id=%s, delta=%d", skuID, delta)
+	}
+	op.WarehouseID = warehouseOrDefault(op.WarehouseID)
+
+	var stock model.Stock
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var row model.WarehouseStock
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("warehouse_id = ? AND sku_id = ?", op.WarehouseID, skuID).
+			First(&row).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrStockNotFound
+			}
+			return fmt.Errorf("查询分仓库存失败: %w", err)
+		}
+
+		logEntry := newOperationLog(skuID, delta, model.StockLogReasonAdjust, op)
+		logEntry.ReasonCode = reasonCode
+		if err := tx.Create(logEntry).Error; err != nil {
+			if !isDuplicateKeyError(err) {
+				return fmt.Errorf("写入库存日志失败 sku_id=%s: %w", skuID, err)
+			}
+			log.Printf("ℹ️ [StockRepository] AdjustStock: 调整单 %s 已调整过 SKU %s 的库存，幂等跳过", op.RefID, skuID)
+		} else {
+			if row.AvailableStock+delta < 0 {
+				return fmt.Errorf("SKU %s 在仓库 %s 的可用库存不足: 当前库存=%d, 调整数量=%d", skuID, op.WarehouseID, row.AvailableStock, delta)
+			}
+			if err := tx.Model(&model.WarehouseStock{}).
+				Where("id = ?", row.ID).
+				Updates(map[string]interface{}{
+					"available_stock": gorm.Expr("available_stock + ?", delta),
+					"version":         gorm.Expr("version + 1"),
+				}).Error; err != nil {
+				return fmt.Errorf("调整分仓库存失败 sku_id=%s: %w", skuID, err)
+			}
+			if err := tx.Model(&model.Stock{}).
+				Where("sku_id = ?", skuID).
+				Updates(map[string]interface{}{
+					"available_stock": gorm.Expr("available_stock + ?", delta),
+					"version":         gorm.Expr("version + 1"),
+				}).Error; err != nil {
+				return fmt.Errorf("调整库存失败 sku_id=%s: %w", skuID, err)
+			}
+		}
+
+		if err := tx.Where("sku_id = ?", skuID).First(&stock).Error; err != nil {
+			return fmt.Errorf("查询库存失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &stock, nil
+}
+
+// InboundStocks 批量入库到指定仓库（全部 SKU 须已初始化库存，仓库中没有该 SKU 时自动创建分仓库存；任一失败则整单回滚）
+func (r *stockRepository) InboundStocks(ctx context.Context, items []DeductItem, op StockOperation) error {
+	if op.RefID == "" {
+		return fmt.Errorf("入库单号不能为空")
+	}
+	items = mergeDeductItems(items)
+	if len(items) == 0 {
+		return fmt.Errorf("入库项不能为空")
+	}
+	for _, item := range items {
+		if item.SKUID == "" || item.Quantity <= 0 {
+			return fmt.Errorf("非法入库请求: sku_id=%s, quantity=%d", item.SKUID, item.Quantity)
+		}
+	}
+	// 按 SKU ID 排序，保证并发事务以相同顺序更新库存行，避免死锁
+	sort.Slice(items, func(i, j int) bool { return items[i].SKUID < items[j].SKUID })
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, item := range items {
+			if err := tx.Create(newOperationLog(item.SKUID, item.Quantity, model.StockLogReasonInbound, op)).Error; err != nil {
+				if isDuplicateKeyError(err) {
+					log.Printf("ℹ️ [StockRepository] InboundStocks: 入库单 %s 已入库过 SKU %s，幂等跳过", op.RefID, item.SKUID)
+					continue
+				}
+				return fmt.Errorf("写入库存日志失败 sku_id=%s: %w", item.SKUID, err)
+			}
+
+			res := tx.Model(&model.Stock{}).
+				Where("sku_id = ?", item.SKUID).
+				Updates(map[string]interface{}{
+					"available_stock": gorm.Expr("available_stock + ?", item.Quantity),
+					"version":         gorm.Expr("version + 1"),
+				})
+			if res.Error != nil {
+				return fmt.Errorf("入库失败 sku_id=%s: %w", item.SKUID, res.Error)
+			}
+			if res.RowsAffected == 0 {
+				return fmt.Errorf("SKU %s 的库存记录不存在，请先初始化库存", item.SKUID)
+			}
+			if err := updateWarehouseStock(tx, warehouseOrDefault(op.WarehouseID), item.SKUID, item.Quantity, 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// StockTake 按仓库盘点对账：实盘数量包含已锁定未发货的库存，差异 = 实盘 - 该仓库(可用 + 锁定)，计入可用库存
+// 无差异的 SKU 同样记录一条变动为 0 的盘点日志，便于审计
+func (r *stockRepository) StockTake(ctx context.Context, counts []DeductItem, op StockOperation) ([]*StockTakeResult, error) {
+	if op.RefID == "" {
+		return nil, fmt.Errorf("盘点单号不能为空")
+	}
+	if len(counts) == 0 {
+		return nil, fmt.Errorf("盘点项不能为空")
+	}
+	skuIDs := make([]string, 0, len(counts))
+	seen := make(map[string]bool, len(counts))
+	for _, item := range counts {
+		if item.SKUID == "" || item.Quantity < 0 {
+			return nil, fmt.Errorf("非法盘点请求: sku_id=%s, counted=%d", item.SKUID, item.Quantity)
+		}
+		if seen[item.SKUID] {
+			return nil, fmt.Errorf("SKU %s 重复盘点", item.SKUID)
+		}
+		seen[item.SKUID] = true
+		skuIDs = append(skuIDs, item.SKUID)
+	}
+
+	results := make([]*StockTakeResult, 0, len(counts))
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var submitted int64
+		if err := tx.Model(&model.StockLog{}).
+			Where("ref_id = ? AND reason = ?", op.RefID, model.StockLogReasonStockTake).
+			Count(&submitted).Error; err != nil {
+			return fmt.Errorf("查询盘点记录失败: %w", err)
+		}
+		if submitted > 0 {
+			return ErrStockTakeSubmitted
+		}
+
+		// 加锁读取并按 SKU ID 排序，盘点期间分仓库存行不会被下单 / 支付修改
+		warehouseID := warehouseOrDefault(op.WarehouseID)
+		var stocks []model.WarehouseStock
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("warehouse_id = ? AND sku_id IN ?", warehouseID, skuIDs).
+			Order("sku_id ASC").
+			Find(&stocks).Error; err != nil {
+			return fmt.Errorf("查询分仓库存失败: %w", err)
+		}
+		stockMap := make(map[string]*model.WarehouseStock, len(stocks))
+		for i := range stocks {
+			stockMap[stocks[i].SKUID] = &stocks[i]
+		}
+
+		for _, item := range counts {
+			stock, exists := stockMap[item.SKUID]
+			if !exists {
+				return fmt.Errorf("SKU %s 在仓库 %s 没有库存记录，请先初始化库存", item.SKUID, warehouseID)
+			}
+			if item.Quantity < stock.LockedStock {
+				return fmt.Errorf("SKU %s 实盘数量 %d 小于锁定库存 %d，请先处理待支付订单", item.SKUID, item.Quantity, stock.LockedStock)
+			}
+
+			result := &StockTakeResult{
+				SKUID:           item.SKUID,
+				BookQuantity:    stock.AvailableStock + stock.LockedStock,
+				CountedQuantity: item.Quantity,
+			}
+			result.Difference = result.CountedQuantity - result.BookQuantity
+			results = append(results, result)
+
+			if err := tx.Create(newOperationLog(item.SKUID, result.Difference, model.StockLogReasonStockTake, op)).Error; err != nil {
+				if isDuplicateKeyError(err) {
+					return ErrStockTakeSubmitted
+				}
+				return fmt.Errorf("写入库存日志失败 sku_id=%s: %w", item.SKUID, err)
+			}
+			if result.Difference == 0 {
+				continue
+			}
+			updates := map[string]interface{}{
+				"available_stock": gorm.Expr("available_stock + ?", result.Difference),
+				"version":         gorm.Expr("version + 1"),
+			}
+			if err := tx.Model(&model.WarehouseStock{}).Where("id = ?", stock.ID).Updates(updates).Error; err != nil {
+				return fmt.Errorf("盘点调整分仓库存失败 sku_id=%s: %w", item.SKUID, err)
+			}
+			if err := tx.Model(&model.Stock{}).Where("sku_id = ?", item.SKUID).Updates(updates).Error; err != nil {
+				return fmt.Errorf("盘点调整库存失败 sku_id=%s: %w", item.SKUID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
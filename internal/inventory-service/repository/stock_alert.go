@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"zjMall/internal/inventory-service/model"
+)
+
+// SetSafetyStock 设置 SKU 的安全库存阈值，返回更新后的库存
+func (r *stockRepository) SetSafetyStock(ctx context.Context, skuID string, safetyStock int64) (*model.Stock, error) {
+	if skuID == "" || safetyStock < 0 {
+		return nil, fmt.Errorf("非法安全库存设置: sku_id=%s, safety_stock=%d", skuID, safetyStock)
+	}
+
+	if err := r.db.WithContext(ctx).Model(&model.Stock{}).
+		Where("sku_id = ?", skuID).
+		Update("safety_stock", safetyStock).Error; err != nil {
+		return nil, fmt.Errorf("设置安全库存失败 sku_id=%s: %w", skuID, err)
+	}
+
+	// 阈值未变化时 MySQL 也返回 0 行，按查询结果判断库存是否存在
+	stock, err := r.GetBySKUID(ctx, skuID)
+	if err != nil {
+		return nil, err
+	}
+	if stock == nil {
+		return nil, ErrStockNotFound
+	}
+	return stock, nil
+}
+
+// CompareAndSetStockLevel 以当前水位为条件更新库存水位（并发的库存变动只有一个能完成同一次水位切换）
+func (r *stockRepository) CompareAndSetStockLevel(ctx context.Context, skuID string, from, to int8) (bool, error) {
+	res := r.db.WithContext(ctx).Model(&model.Stock{}).
+		Where("sku_id = ? AND stock_level = ?", skuID, from).
+		Update("stock_level", to)
+	if res.Error != nil {
+		return false, fmt.Errorf("更新库存水位失败 sku_id=%s: %w", skuID, res.Error)
+	}
+	return res.RowsAffected > 0, nil
+}
@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"zjMall/internal/inventory-service/model"
+
+	"gorm.io/gorm"
+)
+
+// StockLogFilter 库存日志查询条件（零值表示不过滤）
+type StockLogFilter struct {
+	SKUID       string
+	WarehouseID string
+	Reason      string
+	RefID       string
+	CreatedFrom *time.Time // 变动时间起（含）
+	CreatedTo   *time.Time // 变动时间止（不含）
+}
+
+// StockSnapshot 时间点库存快照
+type StockSnapshot struct {
+	SKUID          string
+	At             time.Time
+	AvailableStock int64
+	LockedStock    int64
+	ReplayedLogs   int64 // 倒推的库存日志条数（at 之后的变动）
+}
+
+// StockLedgerBalance 当前库存与库存日志汇总
+type StockLedgerBalance struct {
+	SKUID           string
+	AvailableStock  int64
+	LockedStock     int64
+	LedgerAvailable int64 // SUM(change_amount)
+	LedgerLocked    int64 // SUM(locked_change)
+}
+
+// HasDrift 库存与日志汇总是否存在差异
+func (b *StockLedgerBalance) HasDrift() bool {
+	return b.AvailableStock != b.LedgerAvailable || b.LockedStock != b.LedgerLocked
+}
+
+// ledgerSum 库存日志汇总查询结果
+type ledgerSum struct {
+	SKUID           string `gorm:"column:sku_id"`
+	LedgerAvailable int64  `gorm:"column:ledger_available"`
+	LedgerLocked    int64  `gorm:"column:ledger_locked"`
+	LogCount        int64  `gorm:"column:log_count"`
+}
+
+const ledgerSumColumns = "sku_id, COALESCE(SUM(change_amount), 0) AS ledger_available, COALESCE(SUM(locked_change), 0) AS ledger_locked, COUNT(*) AS log_count"
+
+// ListStockLogs 查询库存日志（ULID 按时间递增，ID 倒序即时间倒序）
+func (r *stockRepository) ListStockLogs(ctx context.Context, filter StockLogFilter, afterID string, limit int) ([]*model.StockLog, error) {
+	query := r.db.WithContext(ctx).Model(&model.StockLog{})
+	if filter.SKUID != "" {
+		query = query.Where("sku_id = ?", filter.SKUID)
+	}
+	if filter.WarehouseID == model.DefaultWarehouseID {
+		// 多仓上线前的日志没有仓库ID，归属默认仓
+		query = query.Where("warehouse_id IN ?", []string{model.DefaultWarehouseID, ""})
+	} else if filter.WarehouseID != "" {
+		query = query.Where("warehouse_id = ?", filter.WarehouseID)
+	}
+	if filter.Reason != "" {
+		query = query.Where("reason = ?", filter.Reason)
+	}
+	if filter.RefID != "" {
+		query = query.Where("ref_id = ?", filter.RefID)
+	}
+	if filter.CreatedFrom != nil {
+		query = query.Where("created_at >= ?", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		query = query.Where("created_at < ?", *filter.CreatedTo)
+	}
+	if afterID != "" {
+		query = query.Where("id < ?", afterID)
+	}
+
+	var logs []*model.StockLog
+	if err := query.Order("id DESC").Limit(limit).Find(&logs).Error; err != nil {
+		return nil, fmt.Errorf("查询库存日志失败: %w", err)
+	}
+	return logs, nil
+}
+
+// GetStockAt 查询时间点库存
+// 从当前库存倒推而不是从零正向累加：库存日志上线前已存在的库存没有初始化记录，倒推只依赖 at 之后的日志完整
+func (r *stockRepository) GetStockAt(ctx context.Context, skuID string, at time.Time) (*StockSnapshot, error) {
+	var snapshot *StockSnapshot
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var stock model.Stock
+		if err := tx.Where("sku_id = ?", skuID).First(&stock).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return fmt.Errorf("查询库存失败: %w", err)
+		}
+
+		var sum ledgerSum
+		if err := tx.Model(&model.StockLog{}).
+			Select(ledgerSumColumns).
+			Where("sku_id = ? AND created_at > ?", skuID, at).
+			Group("sku_id").
+			Scan(&sum).Error; err != nil {
+			return fmt.Errorf("汇总库存日志失败: %w", err)
+		}
+
+		snapshot = &StockSnapshot{
+			SKUID:          skuID,
+			At:             at,
+			AvailableStock: stock.AvailableStock - sum.LedgerAvailable,
+			LockedStock:    stock.LockedStock - sum.LedgerLocked,
+			ReplayedLogs:   sum.LogCount,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// ListStockSKUIDs 分页列出 SKU ID
+func (r *stockRepository) ListStockSKUIDs(ctx context.Context, afterSKUID string, limit int) ([]string, error) {
+	query := r.db.WithContext(ctx).Model(&model.Stock{})
+	if afterSKUID != "" {
+		query = query.Where("sku_id > ?", afterSKUID)
+	}
+
+	var skuIDs []string
+	if err := query.Order("sku_id ASC").Limit(limit).Pluck("sku_id", &skuIDs).Error; err != nil {
+		return nil, fmt.Errorf("查询库存列表失败: %w", err)
+	}
+	return skuIDs, nil
+}
+
+// GetLedgerBalances 读取库存与日志汇总（同一事务内的一致性读，避免并发变动造成误报）
+func (r *stockRepository) GetLedgerBalances(ctx context.Context, skuIDs []string) ([]*StockLedgerBalance, error) {
+	if len(skuIDs) == 0 {
+		return nil, nil
+	}
+
+	var balances []*StockLedgerBalance
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var stocks []model.Stock
+		if err := tx.Where("sku_id IN ?", skuIDs).Order("sku_id ASC").Find(&stocks).Error; err != nil {
+			return fmt.Errorf("查询库存失败: %w", err)
+		}
+
+		var sums []ledgerSum
+		if err := tx.Model(&model.StockLog{}).
+			Select(ledgerSumColumns).
+			Where("sku_id IN ?", skuIDs).
+			Group("sku_id").
+			Scan(&sums).Error; err != nil {
+			return fmt.Errorf("汇总库存日志失败: %w", err)
+		}
+		sumMap := make(map[string]ledgerSum, len(sums))
+		for _, sum := range sums {
+			sumMap[sum.SKUID] = sum
+		}
+
+		balances = make([]*StockLedgerBalance, 0, len(stocks))
+		for _, stock := range stocks {
+			sum := sumMap[stock.SKUID]
+			balances = append(balances, &StockLedgerBalance{
+				SKUID:           stock.SKUID,
+				AvailableStock:  stock.AvailableStock,
+				LockedStock:     stock.LockedStock,
+				LedgerAvailable: sum.LedgerAvailable,
+				LedgerLocked:    sum.LedgerLocked,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return balances, nil
+}
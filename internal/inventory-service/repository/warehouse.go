@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"zjMall/internal/inventory-service/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrWarehouseExists 仓库编码已存在
+	ErrWarehouseExists = errors.New("仓库编码已存在")
+	// ErrWarehouseNotFound 仓库不存在
+	ErrWarehouseNotFound = errors.New("仓库不存在")
+	// ErrStockConflict 分仓库存不足或被并发修改（分仓结果已过期，可重新分仓后重试）
+	ErrStockConflict = errors.New("分仓库存不足或已被并发修改")
+)
+
+// WarehouseRepository 仓库仓储接口
+type WarehouseRepository interface {
+	// CreateWarehouse 创建仓库，编码重复时返回 ErrWarehouseExists
+	CreateWarehouse(ctx context.Context, warehouse *model.Warehouse) error
+	// GetWarehouse 根据ID查询仓库（不存在时返回 nil, nil）
+	GetWarehouse(ctx context.Context, id string) (*model.Warehouse, error)
+	// ListWarehouses 查询仓库列表（status 为 0 表示全部），按优先级升序
+	ListWarehouses(ctx context.Context, status int8) ([]*model.Warehouse, error)
+	// UpdateWarehouse 更新仓库，不存在时返回 ErrWarehouseNotFound
+	UpdateWarehouse(ctx context.Context, id string, fields map[string]interface{}) error
+	// ListWarehouseStocks 查询 SKU 的分仓库存
+	ListWarehouseStocks(ctx context.Context, skuIDs []string) ([]*model.WarehouseStock, error)
+}
+
+type warehouseRepository struct {
+	db *gorm.DB
+}
+
+// NewWarehouseRepository 创建仓库仓储
+func NewWarehouseRepository(db *gorm.DB) WarehouseRepository {
+	return &warehouseRepository{db: db}
+}
+
+func (r *warehouseRepository) CreateWarehouse(ctx context.Context, warehouse *model.Warehouse) error {
+	if err := r.db.WithContext(ctx).Create(warehouse).Error; err != nil {
+		if isDuplicateKeyError(err) {
+			return ErrWarehouseExists
+		}
+		return fmt.Errorf("创建仓库失败: %w", err)
+	}
+	return nil
+}
+
+func (r *warehouseRepository) GetWarehouse(ctx context.Context, id string) (*model.Warehouse, error) {
+	var warehouse model.Warehouse
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&warehouse).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("查询仓库失败: %w", err)
+	}
+	return &warehouse, nil
+}
+
+func (r *warehouseRepository) ListWarehouses(ctx context.Context, status int8) ([]*model.Warehouse, error) {
+	query := r.db.WithContext(ctx).Model(&model.Warehouse{})
+	if status != 0 {
+		query = query.Where("status = ?", status)
+	}
+
+	var warehouses []*model.Warehouse
+	if err := query.Order("priority ASC, id ASC").Find(&warehouses).Error; err != nil {
+		return nil, fmt.Errorf("查询仓库列表失败: %w", err)
+	}
+	return warehouses, nil
+}
+
+func (r *warehouseRepository) UpdateWarehouse(ctx context.Context, id string, fields map[string]interface{}) error {
+	res := r.db.WithContext(ctx).Model(&model.Warehouse{}).Where("id = ?", id).Updates(fields)
+	if res.Error != nil {
+		return fmt.Errorf("更新仓库失败: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		// 字段未变化时 MySQL 也返回 0，需再确认仓库是否存在
+		var count int64
+		if err := r.db.WithContext(ctx).Model(&model.Warehouse{}).Where("id = ?", id).Count(&count).Error; err != nil {
+			return fmt.Errorf("查询仓库失败: %w", err)
+		}
+		if count == 0 {
+			return ErrWarehouseNotFound
+		}
+	}
+	return nil
+}
+
+func (r *warehouseRepository) ListWarehouseStocks(ctx context.Context, skuIDs []string) ([]*model.WarehouseStock, error) {
+	var stocks []*model.WarehouseStock
+	if len(skuIDs) == 0 {
+		return stocks, nil
+	}
+	if err := r.db.WithContext(ctx).
+		Where("sku_id IN ?", skuIDs).
+		Order("sku_id ASC, warehouse_id ASC").
+		Find(&stocks).Error; err != nil {
+		return nil, fmt.Errorf("查询分仓库存失败: %w", err)
+	}
+	return stocks, nil
+}
+
+// updateWarehouseStock 在库存事务内同步更新分仓库存
+// 减少库存时以数量为条件防止分仓超卖（不满足时返回 ErrStockConflict）；只增加库存时分仓记录不存在则自动创建
+func updateWarehouseStock(tx *gorm.DB, warehouseID, skuID string, availableDelta, lockedDelta int64) error {
+	if availableDelta >= 0 && lockedDelta >= 0 {
+		row := &model.WarehouseStock{
+			WarehouseID:    warehouseID,
+			SKUID:          skuID,
+			AvailableStock: availableDelta,
+			LockedStock:    lockedDelta,
+		}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "warehouse_id"}, {Name: "sku_id"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"available_stock": gorm.Expr("available_stock + ?", availableDelta),
+				"locked_stock":    gorm.Expr("locked_stock + ?", lockedDelta),
+				"version":         gorm.Expr("version + 1"),
+			}),
+		}).Create(row).Error; err != nil {
+			return fmt.Errorf("更新分仓库存失败 warehouse_id=%s, sku_id=%s: %w", warehouseID, skuID, err)
+		}
+		return nil
+	}
+
+	query := tx.Model(&model.WarehouseStock{}).Where("warehouse_id = ? AND sku_id = ?", warehouseID, skuID)
+	if availableDelta < 0 {
+		query = query.Where("available_stock >= ?", -availableDelta)
+	}
+	if lockedDelta < 0 {
+		query = query.Where("locked_stock >= ?", -lockedDelta)
+	}
+	res := query.Updates(map[string]interface{}{
+		"available_stock": gorm.Expr("available_stock + ?", availableDelta),
+		"locked_stock":    gorm.Expr("locked_stock + ?", lockedDelta),
+		"version":         gorm.Expr("version + 1"),
+	})
+	if res.Error != nil {
+		return fmt.Errorf("更新分仓库存失败 warehouse_id=%s, sku_id=%s: %w", warehouseID, skuID, res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("%w: 仓库 %s 的 SKU %s 库存不足", ErrStockConflict, warehouseID, skuID)
+	}
+	return nil
+}
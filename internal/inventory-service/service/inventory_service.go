@@ -2,27 +2,39 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"sort"
 
+	"zjMall/internal/common/mq"
 	"zjMall/internal/inventory-service/model"
 	"zjMall/internal/inventory-service/repository"
 )
 
+// allocationMaxAttempts 分仓后扣减 / 锁定因分仓库存被并发修改而失败时的最大尝试次数
+const allocationMaxAttempts = 3
+
 // ItemQuantity 表示单个 SKU 的数量请求
 type ItemQuantity struct {
-	SKUID    string
-	Quantity int64
+	SKUID       string
+	Quantity    int64
+	WarehouseID string // 仓库ID（仅回滚时使用，为空表示回补到原扣减仓库）
 }
 
 // InventoryService 库存领域服务
 type InventoryService struct {
-	stockRepo repository.StockRepository
+	stockRepo     repository.StockRepository
+	warehouseRepo repository.WarehouseRepository
+	producer      mq.MessageProducer // 库存事件生产者（为 nil 时不发布库存水位事件）
 }
 
 // NewInventoryService 创建库存服务
-func NewInventoryService(stockRepo repository.StockRepository) *InventoryService {
+func NewInventoryService(stockRepo repository.StockRepository, warehouseRepo repository.WarehouseRepository, producer mq.MessageProducer) *InventoryService {
 	return &InventoryService{
-		stockRepo: stockRepo,
+		stockRepo:     stockRepo,
+		warehouseRepo: warehouseRepo,
+		producer:      producer,
 	}
 }
 
@@ -34,6 +46,14 @@ func (s *InventoryService) GetStock(ctx context.Context, skuID string) (*model.S
 	return s.stockRepo.GetBySKUID(ctx, skuID)
 }
 
+// GetWarehouseStocks 查询单个 SKU 的分仓库存
+func (s *InventoryService) GetWarehouseStocks(ctx context.Context, skuID string) ([]*model.WarehouseStock, error) {
+	if skuID == "" {
+		return nil, fmt.Errorf("skuID 不能为空")
+	}
+	return s.warehouseRepo.ListWarehouseStocks(ctx, []string{skuID})
+}
+
 // BatchGetStock 批量查询库存
 func (s *InventoryService) BatchGetStock(ctx context.Context, skuIDs []string) (map[string]*model.Stock, error) {
 	if len(skuIDs) == 0 {
@@ -42,29 +62,23 @@ func (s *InventoryService) BatchGetStock(ctx context.Context, skuIDs []string) (
 	return s.stockRepo.BatchGetBySKUID(ctx, skuIDs)
 }
 
-// TryDeductStocks 尝试为订单扣减多个 SKU 的库存（批量操作，使用乐观锁）
-// orderNo: 订单号，用于日志记录和幂等性检查
-func (s *InventoryService) TryDeductStocks(ctx context.Context, orderNo string, items []ItemQuantity) error {
+// TryDeductStocks 尝试为订单扣减多个 SKU 的库存（批量操作，使用乐观锁），返回分仓结果
+// orderNo: 订单号，用于日志记录和幂等性检查（重复请求返回首次扣减的分仓结果）
+// receiverProvince / strategy: 收货省份与分仓策略，为空时按 resolveAllocationStrategy 选择
+func (s *InventoryService) TryDeductStocks(ctx context.Context, orderNo string, items []ItemQuantity, receiverProvince, strategy string) ([]repository.DeductItem, error) {
 	if orderNo == "" {
-		return fmt.Errorf("订单号不能为空")
+		return nil, fmt.Errorf("订单号不能为空")
 	}
 	if len(items) == 0 {
-		return fmt.Errorf("扣减项不能为空")
+		return nil, fmt.Errorf("扣减项不能为空")
 	}
-
-	// 转换为 repository 层的 DeductItem
-	deductItems := make([]repository.DeductItem, 0, len(items))
 	for _, item := range items {
 		if item.SKUID == "" || item.Quantity <= 0 {
-			return fmt.Errorf("非法库存扣减请求: sku_id=%s, quantity=%d", item.SKUID, item.Quantity)
+			return nil, fmt.Errorf("非法库存扣减请求: sku_id=%s, quantity=%d", item.SKUID, item.Quantity)
 		}
-		deductItems = append(deductItems, repository.DeductItem{
-			SKUID:    item.SKUID,
-			Quantity: item.Quantity,
-		})
 	}
 
-	return s.stockRepo.TryDeductStocks(ctx, orderNo, deductItems)
+	return s.allocateAndApply(ctx, orderNo, model.StockLogReasonDeduct, items, receiverProvince, strategy, s.stockRepo.TryDeductStocks)
 }
 
 // RollbackStocks 为多个 SKU 回滚库存（批量操作，使用乐观锁）
@@ -84,8 +98,9 @@ func (s *InventoryService) RollbackStocks(ctx context.Context, orderNo string, i
 			continue // 跳过无效项
 		}
 		deductItems = append(deductItems, repository.DeductItem{
-			SKUID:    item.SKUID,
-			Quantity: item.Quantity,
+			SKUID:       item.SKUID,
+			Quantity:    item.Quantity,
+			WarehouseID: item.WarehouseID,
 		})
 	}
 
@@ -93,5 +108,125 @@ func (s *InventoryService) RollbackStocks(ctx context.Context, orderNo string, i
 		return nil
 	}
 
-	return s.stockRepo.RollbackStocks(ctx, orderNo, deductItems)
+	if err := s.stockRepo.RollbackStocks(ctx, orderNo, deductItems); err != nil {
+		return err
+	}
+	s.notifyStockLevels(ctx, deductItemSKUIDs(deductItems))
+	return nil
+}
+
+// ReserveStocks 下单锁定库存（可用 -> 锁定），orderNo 作为幂等键，返回分仓结果（重复请求返回首次锁定的分仓结果）
+func (s *InventoryService) ReserveStocks(ctx context.Context, orderNo string, items []ItemQuantity, receiverProvince, strategy string) ([]repository.DeductItem, error) {
+	if orderNo == "" {
+		return nil, fmt.Errorf("订单号不能为空")
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("锁定项不能为空")
+	}
+	for _, item := range items {
+		if item.SKUID == "" || item.Quantity <= 0 {
+			return nil, fmt.Errorf("非法库存锁定请求: sku_id=%s, quantity=%d", item.SKUID, item.Quantity)
+		}
+	}
+
+	return s.allocateAndApply(ctx, orderNo, model.StockLogReasonReserve, items, receiverProvince, strategy, s.stockRepo.ReserveStocks)
+}
+
+// ConfirmStocks 支付成功后确认订单锁定的库存（扣除锁定库存）
+func (s *InventoryService) ConfirmStocks(ctx context.Context, orderNo string) error {
+	if orderNo == "" {
+		return fmt.Errorf("订单号不能为空")
+	}
+	return s.stockRepo.ConfirmStocks(ctx, orderNo)
+}
+
+// ReleaseStocks 订单取消或超时关闭后释放锁定的库存（锁定 -> 可用）
+func (s *InventoryService) ReleaseStocks(ctx context.Context, orderNo string) error {
+	if orderNo == "" {
+		return fmt.Errorf("订单号不能为空")
+	}
+	if err := s.stockRepo.ReleaseStocks(ctx, orderNo); err != nil {
+		return err
+	}
+
+	// 按实际回补的 SKU 检查库存水位：锁定订单写入释放日志，直接扣减的旧订单写入回滚日志
+	var released []repository.DeductItem
+	for _, reason := range []string{model.StockLogReasonRelease, model.StockLogReasonRollback} {
+		items, err := s.stockRepo.ListAllocations(ctx, orderNo, reason)
+		if err != nil {
+			log.Printf("⚠️ [InventoryService] ReleaseStocks: 查询订单 %s 的回补记录失败: %v", orderNo, err)
+			return nil
+		}
+		released = append(released, items...)
+	}
+	s.notifyStockLevels(ctx, deductItemSKUIDs(released))
+	return nil
+}
+
+// allocateAndApply 分仓后执行扣减 / 锁定
+// 分仓读取的分仓库存不在扣减事务内，被并发下单抢先扣减时仓储返回 ErrStockConflict，此时重新分仓重试
+func (s *InventoryService) allocateAndApply(
+	ctx context.Context,
+	orderNo, reason string,
+	items []ItemQuantity,
+	receiverProvince, strategy string,
+	apply func(ctx context.Context, orderNo string, items []repository.DeductItem) ([]repository.DeductItem, error),
+) ([]repository.DeductItem, error) {
+	// 重复请求：直接返回首次生效的分仓结果，避免重新分仓到不同仓库
+	existing, err := s.stockRepo.ListAllocations(ctx, orderNo, reason)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) > 0 {
+		log.Printf("ℹ️ [InventoryService] 订单 %s 已处理过 (reason=%s)，返回首次分仓结果", orderNo, reason)
+		return existing, nil
+	}
+
+	items = mergeItemQuantities(items)
+	for attempt := 1; ; attempt++ {
+		allocations, err := s.allocate(ctx, items, receiverProvince, strategy)
+		if err != nil {
+			return nil, err
+		}
+		applied, err := apply(ctx, orderNo, allocations)
+		if err == nil {
+			s.notifyStockLevels(ctx, deductItemSKUIDs(applied))
+			return applied, nil
+		}
+		if !errors.Is(err, repository.ErrStockConflict) || attempt >= allocationMaxAttempts {
+			return nil, err
+		}
+		log.Printf("⚠️ [InventoryService] 订单 %s 分仓库存已变化，重新分仓 (attempt=%d): %v", orderNo, attempt, err)
+	}
+}
+
+// allocate 读取启用仓库与分仓库存，按策略为每个 SKU 选择发货仓库
+func (s *InventoryService) allocate(ctx context.Context, items []ItemQuantity, receiverProvince, strategy string) ([]repository.DeductItem, error) {
+	warehouses, err := s.warehouseRepo.ListWarehouses(ctx, model.WarehouseStatusEnabled)
+	if err != nil {
+		return nil, err
+	}
+	skuIDs := make([]string, 0, len(items))
+	for _, item := range items {
+		skuIDs = append(skuIDs, item.SKUID)
+	}
+	stocks, err := s.warehouseRepo.ListWarehouseStocks(ctx, skuIDs)
+	if err != nil {
+		return nil, err
+	}
+	return allocateWarehouses(items, warehouses, stocks, receiverProvince, strategy)
+}
+
+// mergeItemQuantities 合并同一 SKU 的数量并按 SKU ID 排序
+func mergeItemQuantities(items []ItemQuantity) []ItemQuantity {
+	quantities := make(map[string]int64, len(items))
+	for _, item := range items {
+		quantities[item.SKUID] += item.Quantity
+	}
+	merged := make([]ItemQuantity, 0, len(quantities))
+	for skuID, quantity := range quantities {
+		merged = append(merged, ItemQuantity{SKUID: skuID, Quantity: quantity})
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].SKUID < merged[j].SKUID })
+	return merged
 }
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"zjMall/internal/inventory-service/model"
+	"zjMall/internal/inventory-service/repository"
+	"zjMall/pkg"
+)
+
+// =============== 后台库存管理（管理员） ===============
+
+// StockTakeCount 单个 SKU 的实盘数量
+type StockTakeCount struct {
+	SKUID           string
+	CountedQuantity int64
+}
+
+// InitStock 初始化 SKU 在指定仓库的库存（新 SKU 上架或新仓库铺货时调用），该仓库已有库存时返回 repository.ErrStockExists
+func (s *InventoryService) InitStock(ctx context.Context, operatorID, warehouseID, skuID string, quantity int64, remark string) (*model.Stock, error) {
+	if skuID == "" {
+		return nil, fmt.Errorf("skuID 不能为空")
+	}
+	if quantity < 0 {
+		return nil, fmt.Errorf("初始库存不能小于 0")
+	}
+	warehouseID, err := s.resolveWarehouseID(ctx, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+
+	stock := &model.Stock{
+		SKUID:          skuID,
+		AvailableStock: quantity,
+	}
+	op := repository.StockOperation{
+		WarehouseID: warehouseID,
+		OperatorID:  operatorID,
+		Remark:      remark,
+	}
+	if err := s.stockRepo.InitStock(ctx, stock, op); err != nil {
+		return nil, err
+	}
+	s.notifyStockLevels(ctx, []string{skuID})
+	return stock, nil
+}
+
+// AdjustStock 调整指定仓库的可用库存，requestNo 为空时自动生成（此时不具备幂等性）
+func (s *InventoryService) AdjustStock(ctx context.Context, operatorID, warehouseID, skuID string, delta int64, reasonCode, requestNo, remark string) (*model.Stock, error) {
+	if skuID == "" {
+		return nil, fmt.Errorf("skuID 不能为空")
+	}
+	if delta == 0 {
+		return nil, fmt.Errorf("调整数量不能为 0")
+	}
+	if reasonCode == "" {
+		return nil, fmt.Errorf("调整原因不能为空")
+	}
+	if requestNo == "" {
+		requestNo = pkg.GenerateULID()
+	}
+	warehouseID, err := s.resolveWarehouseID(ctx, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+
+	op := repository.StockOperation{
+		WarehouseID: warehouseID,
+		RefID:       requestNo,
+		OperatorID:  operatorID,
+		Remark:      remark,
+	}
+	stock, err := s.stockRepo.AdjustStock(ctx, skuID, delta, reasonCode, op)
+	if err != nil {
+		return nil, err
+	}
+	s.notifyStockLevels(ctx, []string{skuID})
+	return stock, nil
+}
+
+// InboundStocks 批量入库到指定仓库，receiptNo 作为幂等键
+func (s *InventoryService) InboundStocks(ctx context.Context, operatorID, warehouseID, receiptNo string, items []ItemQuantity, remark string) error {
+	if receiptNo == "" {
+		return fmt.Errorf("入库单号不能为空")
+	}
+	if len(items) == 0 {
+		return fmt.Errorf("入库项不能为空")
+	}
+
+	inboundItems := make([]repository.DeductItem, 0, len(items))
+	for _, item := range items {
+		if item.SKUID == "" || item.Quantity <= 0 {
+			return fmt.Errorf("非法入库请求: sku_id=%s, quantity=%d", item.SKUID, item.Quantity)
+		}
+		inboundItems = append(inboundItems, repository.DeductItem{
+			SKUID:    item.SKUID,
+			Quantity: item.Quantity,
+		})
+	}
+	warehouseID, err := s.resolveWarehouseID(ctx, warehouseID)
+	if err != nil {
+		return err
+	}
+
+	op := repository.StockOperation{
+		WarehouseID: warehouseID,
+		RefID:       receiptNo,
+		OperatorID:  operatorID,
+		Remark:      remark,
+	}
+	if err := s.stockRepo.InboundStocks(ctx, inboundItems, op); err != nil {
+		return err
+	}
+	s.notifyStockLevels(ctx, deductItemSKUIDs(inboundItems))
+	return nil
+}
+
+// StockTake 按仓库盘点，takeNo 作为幂等键，同一盘点单只能提交一次
+func (s *InventoryService) StockTake(ctx context.Context, operatorID, warehouseID, takeNo string, counts []StockTakeCount, remark string) ([]*repository.StockTakeResult, error) {
+	if takeNo == "" {
+		return nil, fmt.Errorf("盘点单号不能为空")
+	}
+	if len(counts) == 0 {
+		return nil, fmt.Errorf("盘点项不能为空")
+	}
+
+	items := make([]repository.DeductItem, 0, len(counts))
+	for _, c := range counts {
+		if c.SKUID == "" || c.CountedQuantity < 0 {
+			return nil, fmt.Errorf("非法盘点请求: sku_id=%s, counted=%d", c.SKUID, c.CountedQuantity)
+		}
+		items = append(items, repository.DeductItem{
+			SKUID:    c.SKUID,
+			Quantity: c.CountedQuantity,
+		})
+	}
+	warehouseID, err := s.resolveWarehouseID(ctx, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+
+	op := repository.StockOperation{
+		WarehouseID: warehouseID,
+		RefID:       takeNo,
+		OperatorID:  operatorID,
+		Remark:      remark,
+	}
+	results, err := s.stockRepo.StockTake(ctx, items, op)
+	if err != nil {
+		return nil, err
+	}
+	s.notifyStockLevels(ctx, deductItemSKUIDs(items))
+	return results, nil
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"zjMall/internal/common/mq"
+	"zjMall/internal/inventory-service/model"
+	"zjMall/internal/inventory-service/repository"
+)
+
+// =============== 库存水位预警 ===============
+
+// SetSafetyStock 设置 SKU 的安全库存阈值（0 表示不做低库存预警），库存不存在时返回 repository.ErrStockNotFound
+func (s *InventoryService) SetSafetyStock(ctx context.Context, skuID string, safetyStock int64) (*model.Stock, error) {
+	if skuID == "" {
+		return nil, fmt.Errorf("skuID 不能为空")
+	}
+	if safetyStock < 0 {
+		return nil, fmt.Errorf("安全库存不能小于 0")
+	}
+
+	stock, err := s.stockRepo.SetSafetyStock(ctx, skuID, safetyStock)
+	if err != nil {
+		return nil, err
+	}
+	// 调整阈值本身也可能让 SKU 进入或离开低库存
+	s.notifyStockLevels(ctx, []string{skuID})
+	return stock, nil
+}
+
+// notifyStockLevels 库存变动后重新计算 SKU 的库存水位，水位变化时发布库存事件
+// 先以 CAS 更新 stock_level 再发布，并发变动时同一次水位变化只发布一次；发布失败时恢复水位，下次库存变动时重新发布
+// 库存变动已经生效，这里的失败只记录日志，不影响调用方
+func (s *InventoryService) notifyStockLevels(ctx context.Context, skuIDs []string) {
+	if s.producer == nil || len(skuIDs) == 0 {
+		return
+	}
+
+	stocks, err := s.stockRepo.BatchGetBySKUID(ctx, skuIDs)
+	if err != nil {
+		log.Printf("⚠️ [InventoryService] notifyStockLevels: 查询库存失败 sku_ids=%v: %v", skuIDs, err)
+		return
+	}
+
+	for _, stock := range stocks {
+		level := stock.Level()
+		if level == stock.StockLevel {
+			continue
+		}
+		eventType := stockEventType(stock.StockLevel, level)
+
+		ok, err := s.stockRepo.CompareAndSetStockLevel(ctx, stock.SKUID, stock.StockLevel, level)
+		if err != nil {
+			log.Printf("⚠️ [InventoryService] notifyStockLevels: %v", err)
+			continue
+		}
+		if !ok {
+			// 并发的库存变动已处理本次水位变化
+			continue
+		}
+
+		event := mq.NewStockEvent(eventType, stock.SKUID, stock.AvailableStock, stock.SafetyStock)
+		if err := mq.SendStockEvent(ctx, s.producer, event); err != nil {
+			log.Printf("⚠️ [InventoryService] 发布库存事件失败 sku_id=%s, event=%s: %v", stock.SKUID, eventType, err)
+			if _, err := s.stockRepo.CompareAndSetStockLevel(ctx, stock.SKUID, level, stock.StockLevel); err != nil {
+				log.Printf("⚠️ [InventoryService] 恢复库存水位失败 sku_id=%s: %v", stock.SKUID, err)
+			}
+			continue
+		}
+		log.Printf("✅ [InventoryService] 已发布库存事件 sku_id=%s, event=%s, available=%d, safety=%d",
+			stock.SKUID, eventType, stock.AvailableStock, stock.SafetyStock)
+	}
+}
+
+// stockEventType 根据水位变化确定事件类型：售罄 -> stock.out，正常降为低库存 -> stock.low，水位回升 -> stock.restocked
+func stockEventType(from, to int8) string {
+	switch {
+	case to == model.StockLevelOut:
+		return mq.StockEventOut
+	case to == model.StockLevelLow && from == model.StockLevelNormal:
+		return mq.StockEventLow
+	case to < from:
+		return mq.StockEventRestocked
+	}
+	return ""
+}
+
+// deductItemSKUIDs 提取分仓结果中的 SKU ID
+func deductItemSKUIDs(items []repository.DeductItem) []string {
+	skuIDs := make([]string, 0, len(items))
+	for _, item := range items {
+		skuIDs = append(skuIDs, item.SKUID)
+	}
+	return skuIDs
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"zjMall/internal/inventory-service/model"
+	"zjMall/internal/inventory-service/repository"
+)
+
+const (
+	stockLogDefaultPageSize = 20
+	stockLogMaxPageSize     = 100
+
+	// consistencyCheckBatchSize 全量一致性检查时每批读取的 SKU 数量
+	consistencyCheckBatchSize = 500
+	// consistencyCheckMaxSKUs 指定 SKU 检查时的数量上限
+	consistencyCheckMaxSKUs = 500
+)
+
+// =============== 库存变动明细查询与对账 ===============
+
+// ListStockLogs 游标分页查询库存日志，返回本页日志与下一页游标（为空表示没有更多数据）
+func (s *InventoryService) ListStockLogs(ctx context.Context, filter repository.StockLogFilter, cursor string, pageSize int) ([]*model.StockLog, string, error) {
+	if pageSize <= 0 {
+		pageSize = stockLogDefaultPageSize
+	}
+	if pageSize > stockLogMaxPageSize {
+		pageSize = stockLogMaxPageSize
+	}
+
+	// 多查一条用于判断是否还有下一页
+	logs, err := s.stockRepo.ListStockLogs(ctx, filter, cursor, pageSize+1)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var nextCursor string
+	if len(logs) > pageSize {
+		logs = logs[:pageSize]
+		nextCursor = logs[pageSize-1].ID
+	}
+	return logs, nextCursor, nil
+}
+
+// GetStockAt 查询 SKU 在指定时间点的库存（SKU 没有库存记录时返回 nil, nil）
+func (s *InventoryService) GetStockAt(ctx context.Context, skuID string, at time.Time) (*repository.StockSnapshot, error) {
+	if skuID == "" {
+		return nil, fmt.Errorf("skuID 不能为空")
+	}
+	if at.After(time.Now()) {
+		return nil, fmt.Errorf("时间点不能晚于当前时间")
+	}
+	return s.stockRepo.GetStockAt(ctx, skuID, at)
+}
+
+// CheckStockConsistency 比对库存日志汇总与当前库存，返回检查的 SKU 数量与存在差异的 SKU
+// skuIDs 为空时按批次检查全部 SKU；差异通常来自绕过库存服务直接修改库存表，或日志上线前已存在的库存
+func (s *InventoryService) CheckStockConsistency(ctx context.Context, skuIDs []string) (int64, []*repository.StockLedgerBalance, error) {
+	if len(skuIDs) > consistencyCheckMaxSKUs {
+		return 0, nil, fmt.Errorf("一次最多检查 %d 个 SKU", consistencyCheckMaxSKUs)
+	}
+
+	var checked int64
+	var drifts []*repository.StockLedgerBalance
+	check := func(batch []string) error {
+		balances, err := s.stockRepo.GetLedgerBalances(ctx, batch)
+		if err != nil {
+			return err
+		}
+		checked += int64(len(balances))
+		for _, b := range balances {
+			if !b.HasDrift() {
+				continue
+			}
+			log.Printf("⚠️ [InventoryService] CheckStockConsistency: 库存与日志不一致 sku_id=%s, available=%d, ledger_available=%d, locked=%d, ledger_locked=%d",
+				b.SKUID, b.AvailableStock, b.LedgerAvailable, b.LockedStock, b.LedgerLocked)
+			drifts = append(drifts, b)
+		}
+		return nil
+	}
+
+	if len(skuIDs) > 0 {
+		if err := check(skuIDs); err != nil {
+			return 0, nil, err
+		}
+		return checked, drifts, nil
+	}
+
+	afterSKUID := ""
+	for {
+		batch, err := s.stockRepo.ListStockSKUIDs(ctx, afterSKUID, consistencyCheckBatchSize)
+		if err != nil {
+			return 0, nil, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+		if err := check(batch); err != nil {
+			return 0, nil, err
+		}
+		if len(batch) < consistencyCheckBatchSize {
+			break
+		}
+		afterSKUID = batch[len(batch)-1]
+	}
+
+	log.Printf("ℹ️ [InventoryService] CheckStockConsistency: 检查完成 checked=%d, drifts=%d", checked, len(drifts))
+	return checked, drifts, nil
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"zjMall/internal/inventory-service/model"
+	"zjMall/internal/inventory-service/repository"
+)
+
+// =============== 仓库管理（管理员） ===============
+
+// CreateWarehouse 创建仓库（默认启用），编码重复时返回 repository.ErrWarehouseExists
+func (s *InventoryService) CreateWarehouse(ctx context.Context, code, name, province string, priority int32) (*model.Warehouse, error) {
+	code = strings.TrimSpace(code)
+	name = strings.TrimSpace(name)
+	if code == "" || name == "" {
+		return nil, fmt.Errorf("仓库编码和名称不能为空")
+	}
+	if priority < 0 {
+		return nil, fmt.Errorf("优先级不能小于 0")
+	}
+
+	warehouse := &model.Warehouse{
+		Code:     code,
+		Name:     name,
+		Province: strings.TrimSpace(province),
+		Priority: priority,
+		Status:   model.WarehouseStatusEnabled,
+	}
+	if err := s.warehouseRepo.CreateWarehouse(ctx, warehouse); err != nil {
+		return nil, err
+	}
+	return warehouse, nil
+}
+
+// ListWarehouses 查询仓库列表（status 为 0 表示全部）
+func (s *InventoryService) ListWarehouses(ctx context.Context, status int8) ([]*model.Warehouse, error) {
+	return s.warehouseRepo.ListWarehouses(ctx, status)
+}
+
+// UpdateWarehouse 更新仓库名称、所在省份、优先级与状态，仓库不存在时返回 repository.ErrWarehouseNotFound
+// 停用仓库只是不再参与分仓，已锁定的库存仍按原仓库确认 / 释放
+func (s *InventoryService) UpdateWarehouse(ctx context.Context, id, name, province string, priority int32, status int8) (*model.Warehouse, error) {
+	name = strings.TrimSpace(name)
+	if id == "" || name == "" {
+		return nil, fmt.Errorf("仓库ID和名称不能为空")
+	}
+	if priority < 0 {
+		return nil, fmt.Errorf("优先级不能小于 0")
+	}
+	if status != model.WarehouseStatusEnabled && status != model.WarehouseStatusDisabled {
+		return nil, fmt.Errorf("非法仓库状态: %d", status)
+	}
+
+	fields := map[string]interface{}{
+		"name":     name,
+		"province": strings.TrimSpace(province),
+		"priority": priority,
+		"status":   status,
+	}
+	if err := s.warehouseRepo.UpdateWarehouse(ctx, id, fields); err != nil {
+		return nil, err
+	}
+	return s.warehouseRepo.GetWarehouse(ctx, id)
+}
+
+// resolveWarehouseID 后台库存操作的仓库：为空时使用默认仓，否则校验仓库存在
+func (s *InventoryService) resolveWarehouseID(ctx context.Context, warehouseID string) (string, error) {
+	if warehouseID == "" || warehouseID == model.DefaultWarehouseID {
+		return model.DefaultWarehouseID, nil
+	}
+	warehouse, err := s.warehouseRepo.GetWarehouse(ctx, warehouseID)
+	if err != nil {
+		return "", err
+	}
+	if warehouse == nil {
+		return "", repository.ErrWarehouseNotFound
+	}
+	return warehouse.ID, nil
+}
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"zjMall/internal/inventory-service/model"
+	"zjMall/internal/inventory-service/repository"
+)
+
+// 分仓策略
+const (
+	AllocationStrategyNearest      = "nearest"       // 就近：同省 > 同大区 > 其他，距离相同时按优先级
+	AllocationStrategyFewestSplits = "fewest_splits" // 最少拆单：尽量由最少的仓库发出全部商品
+	AllocationStrategyPriority     = "priority"      // 固定优先级：按仓库优先级选择
+)
+
+// 省份与收货地的距离等级（数值越小越近）
+const (
+	distanceSameProvince = 0
+	distanceSameRegion   = 1
+	distanceOtherRegion  = 2
+	distanceUnknown      = 3 // 仓库未设置省份
+)
+
+// provinceSuffixes 省份名称中需要去掉的后缀（长后缀在前）
+var provinceSuffixes = []string{"特别行政区", "壮族自治区", "回族自治区", "维吾尔自治区", "自治区", "省", "市"}
+
+// provinceRegions 省份 -> 地理大区
+var provinceRegions = map[string]string{
+	"北京": "华北", "天津": "华北", "河北": "华北", "山西": "华北", "内蒙古": "华北",
+	"辽宁": "东北", "吉林": "东北", "黑龙江": "东北",
+	"上海": "华东", "江苏": "华东", "浙江": "华东", "安徽": "华东", "福建": "华东", "江西": "华东", "山东": "华东",
+	"河南": "华中", "湖北": "华中", "湖南": "华中",
+	"广东": "华南", "广西": "华南", "海南": "华南",
+	"重庆": "西南", "四川": "西南", "贵州": "西南", "云南": "西南", "西藏": "西南",
+	"陕西": "西北", "甘肃": "西北", "青海": "西北", "宁夏": "西北", "新疆": "西北",
+	"香港": "港澳台", "澳门": "港澳台", "台湾": "港澳台",
+}
+
+// normalizeProvince 统一省份写法（"广东省" / "广东" -> "广东"，"广西壮族自治区" -> "广西"）
+func normalizeProvince(province string) string {
+	province = strings.TrimSpace(province)
+	for _, suffix := range provinceSuffixes {
+		if trimmed := strings.TrimSuffix(province, suffix); trimmed != province && trimmed != "" {
+			return trimmed
+		}
+	}
+	return province
+}
+
+// provinceDistance 仓库所在省份与收货省份的距离等级（收货省份为空时所有仓库视为等距）
+func provinceDistance(warehouseProvince, receiverProvince string) int {
+	receiver := normalizeProvince(receiverProvince)
+	if receiver == "" {
+		return distanceSameProvince
+	}
+	warehouse := normalizeProvince(warehouseProvince)
+	if warehouse == "" {
+		return distanceUnknown
+	}
+	if warehouse == receiver {
+		return distanceSameProvince
+	}
+	if region, ok := provinceRegions[warehouse]; ok && region == provinceRegions[receiver] {
+		return distanceSameRegion
+	}
+	return distanceOtherRegion
+}
+
+// allocationCandidate 可供分仓的仓库
+type allocationCandidate struct {
+	warehouse *model.Warehouse
+	distance  int
+	available map[string]int64 // sku_id -> 分仓可用库存
+}
+
+// canServe 仓库能否独立满足该 SKU 的数量
+func (c *allocationCandidate) canServe(item ItemQuantity) bool {
+	return c.available[item.SKUID] >= item.Quantity
+}
+
+// nearer 按 距离 -> 优先级 -> 仓库ID 比较
+func (c *allocationCandidate) nearer(o *allocationCandidate) bool {
+	if c.distance != o.distance {
+		return c.distance < o.distance
+	}
+	return c.higherPriority(o)
+}
+
+// higherPriority 按 优先级 -> 仓库ID 比较
+func (c *allocationCandidate) higherPriority(o *allocationCandidate) bool {
+	if c.warehouse.Priority != o.warehouse.Priority {
+		return c.warehouse.Priority < o.warehouse.Priority
+	}
+	return c.warehouse.ID < o.warehouse.ID
+}
+
+// resolveAllocationStrategy 未指定策略时：有收货省份就近分配，否则按优先级
+func resolveAllocationStrategy(strategy, receiverProvince string) string {
+	switch strategy {
+	case AllocationStrategyNearest, AllocationStrategyFewestSplits, AllocationStrategyPriority:
+		return strategy
+	}
+	if strings.TrimSpace(receiverProvince) != "" {
+		return AllocationStrategyNearest
+	}
+	return AllocationStrategyPriority
+}
+
+// allocateWarehouses 为每个 SKU 选择一个发货仓库（单个 SKU 不跨仓拆分）
+// items 须已按 SKU 合并；只有启用且可用库存足够的仓库参与分仓，任一 SKU 无仓可发时返回错误
+func allocateWarehouses(items []ItemQuantity, warehouses []*model.Warehouse, stocks []*model.WarehouseStock, receiverProvince, strategy string) ([]repository.DeductItem, error) {
+	candidates := make([]*allocationCandidate, 0, len(warehouses))
+	candidateMap := make(map[string]*allocationCandidate, len(warehouses))
+	for _, w := range warehouses {
+		if w.Status != model.WarehouseStatusEnabled {
+			continue
+		}
+		c := &allocationCandidate{
+			warehouse: w,
+			distance:  provinceDistance(w.Province, receiverProvince),
+			available: make(map[string]int64),
+		}
+		candidates = append(candidates, c)
+		candidateMap[w.ID] = c
+	}
+	for _, stock := range stocks {
+		if c, ok := candidateMap[stock.WarehouseID]; ok {
+			c.available[stock.SKUID] = stock.AvailableStock
+		}
+	}
+
+	// 先确认每个 SKU 至少有一个仓库能发货
+	for _, item := range items {
+		servable := false
+		for _, c := range candidates {
+			if c.canServe(item) {
+				servable = true
+				break
+			}
+		}
+		if !servable {
+			return nil, fmt.Errorf("SKU %s 库存不足，没有可以独立发货的仓库: 需要数量=%d", item.SKUID, item.Quantity)
+		}
+	}
+
+	strategy = resolveAllocationStrategy(strategy, receiverProvince)
+	if strategy == AllocationStrategyFewestSplits {
+		return allocateFewestSplits(items, candidates), nil
+	}
+
+	less := (*allocationCandidate).nearer
+	if strategy == AllocationStrategyPriority {
+		less = (*allocationCandidate).higherPriority
+	}
+	sort.Slice(candidates, func(i, j int) bool { return less(candidates[i], candidates[j]) })
+
+	allocations := make([]repository.DeductItem, 0, len(items))
+	for _, item := range items {
+		for _, c := range candidates {
+			if c.canServe(item) {
+				allocations = append(allocations, repository.DeductItem{
+					SKUID:       item.SKUID,
+					Quantity:    item.Quantity,
+					WarehouseID: c.warehouse.ID,
+				})
+				break
+			}
+		}
+	}
+	return allocations, nil
+}
+
+// allocateFewestSplits 贪心集合覆盖：每轮选择能发出剩余 SKU 最多的仓库（数量相同时按距离、优先级）
+func allocateFewestSplits(items []ItemQuantity, candidates []*allocationCandidate) []repository.DeductItem {
+	remaining := make([]ItemQuantity, len(items))
+	copy(remaining, items)
+
+	allocations := make([]repository.DeductItem, 0, len(items))
+	for len(remaining) > 0 {
+		var best *allocationCandidate
+		bestCovered := 0
+		for _, c := range candidates {
+			covered := 0
+			for _, item := range remaining {
+				if c.canServe(item) {
+					covered++
+				}
+			}
+			if covered == 0 {
+				continue
+			}
+			if best == nil || covered > bestCovered || (covered == bestCovered && c.nearer(best)) {
+				best, bestCovered = c, covered
+			}
+		}
+		if best == nil {
+			// 调用前已校验每个 SKU 都有仓可发，不会出现
+			break
+		}
+
+		next := remaining[:0]
+		for _, item := range remaining {
+			if best.canServe(item) {
+				allocations = append(allocations, repository.DeductItem{
+					SKUID:       item.SKUID,
+					Quantity:    item.Quantity,
+					WarehouseID: best.warehouse.ID,
+				})
+				continue
+			}
+			next = append(next, item)
+		}
+		remaining = next
+	}
+	return allocations
+}
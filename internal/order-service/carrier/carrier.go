@@ -0,0 +1,77 @@
+package carrier
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// AnyCarrier 注册为默认适配器时使用的物流公司代码（未单独注册的物流公司都使用该适配器）
+const AnyCarrier = "*"
+
+var (
+	// ErrTrackingNotFound 物流公司侧查不到该物流单（可能尚未揽收）
+	ErrTrackingNotFound = errors.New("物流单不存在")
+	// ErrInvalidSignature 物流推送签名校验失败
+	ErrInvalidSignature = errors.New("物流推送签名校验失败")
+)
+
+// TrackingEvent 物流轨迹
+type TrackingEvent struct {
+	Time        time.Time `json:"time"`
+	Status      int8      `json:"status"` // 物流状态，取值见 model.ShipmentStatus*
+	Location    string    `json:"location"`
+	Description string    `json:"description"`
+}
+
+// TrackingInfo 物流公司返回（或推送）的物流信息
+type TrackingInfo struct {
+	TrackingNo string          `json:"tracking_no"`
+	Status     int8            `json:"status"` // 当前物流状态
+	Events     []TrackingEvent `json:"events"`
+}
+
+// Carrier 物流公司适配器接口
+// 各物流公司的查询接口与推送格式不同，由适配器统一转换为 TrackingInfo
+type Carrier interface {
+	// Code 物流公司代码
+	Code() string
+	// Track 主动查询物流轨迹
+	Track(ctx context.Context, trackingNo string) (*TrackingInfo, error)
+	// ParseWebhook 校验并解析物流公司推送，签名错误时返回 ErrInvalidSignature
+	ParseWebhook(header http.Header, body []byte) (*TrackingInfo, error)
+}
+
+// Registry 物流公司适配器注册表
+type Registry struct {
+	mu       sync.RWMutex
+	carriers map[string]Carrier
+}
+
+// NewRegistry 创建物流公司适配器注册表
+func NewRegistry() *Registry {
+	return &Registry{carriers: make(map[string]Carrier)}
+}
+
+// Register 按 code 注册适配器，code 为 AnyCarrier 时作为默认适配器
+func (r *Registry) Register(code string, c Carrier) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.carriers[code] = c
+}
+
+// Get 查询物流公司适配器，未单独注册时返回默认适配器
+func (r *Registry) Get(code string) (Carrier, bool) {
+	if r == nil {
+		return nil, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if c, ok := r.carriers[code]; ok {
+		return c, true
+	}
+	c, ok := r.carriers[AnyCarrier]
+	return c, ok
+}
@@ -0,0 +1,98 @@
+package carrier
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// FakeSignatureHeader 模拟物流公司推送的签名请求头：hex(HMAC-SHA256(secret, body))
+const FakeSignatureHeader = "X-Carrier-Signature"
+
+// FakeCarrier 基于本地文件的模拟物流公司，用于联调与测试
+// 查询时读取 {dir}/{tracking_no}.json，内容为 TrackingInfo 的 JSON；推送格式同样为 TrackingInfo 的 JSON
+type FakeCarrier struct {
+	code   string
+	dir    string
+	secret string
+}
+
+// NewFakeCarrier 创建模拟物流公司，secret 为空时只支持轨迹查询，拒绝所有推送
+func NewFakeCarrier(code, dir, secret string) *FakeCarrier {
+	return &FakeCarrier{code: code, dir: dir, secret: secret}
+}
+
+func (c *FakeCarrier) Code() string {
+	return c.code
+}
+
+func (c *FakeCarrier) Track(ctx context.Context, trackingNo string) (*TrackingInfo, error) {
+	if c.dir == "" {
+		return nil, ErrTrackingNotFound
+	}
+	// 物流单号只允许作为文件名使用，防止路径穿越
+	if trackingNo == "" || filepath.Base(trackingNo) != trackingNo {
+		return nil, fmt.Errorf("非法的物流单号: %s", trackingNo)
+	}
+
+	data, err := os.ReadFile(filepath.Join(c.dir, trackingNo+".json"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrTrackingNotFound
+		}
+		return nil, fmt.Errorf("读取模拟物流数据失败: %w", err)
+	}
+	info, err := parseTrackingInfo(data)
+	if err != nil {
+		return nil, err
+	}
+	if info.TrackingNo == "" {
+		info.TrackingNo = trackingNo
+	}
+	return info, nil
+}
+
+func (c *FakeCarrier) ParseWebhook(header http.Header, body []byte) (*TrackingInfo, error) {
+	// 推送接口不经过登录鉴权，未配置密钥时无法校验来源，直接拒绝
+	if c.secret == "" {
+		return nil, fmt.Errorf("%w: 未配置推送签名密钥", ErrInvalidSignature)
+	}
+	mac := hmac.New(sha256.New, []byte(c.secret))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(header.Get(FakeSignatureHeader))) {
+		return nil, ErrInvalidSignature
+	}
+	info, err := parseTrackingInfo(body)
+	if err != nil {
+		return nil, err
+	}
+	if info.TrackingNo == "" {
+		return nil, fmt.Errorf("物流推送缺少 tracking_no")
+	}
+	return info, nil
+}
+
+// parseTrackingInfo 解析 TrackingInfo JSON，轨迹按时间升序排列
+func parseTrackingInfo(data []byte) (*TrackingInfo, error) {
+	var info TrackingInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("解析物流数据失败: %w", err)
+	}
+	sort.SliceStable(info.Events, func(i, j int) bool {
+		return info.Events[i].Time.Before(info.Events[j].Time)
+	})
+	// 未显式给出当前状态时取最新一条轨迹的状态
+	if info.Status == 0 && len(info.Events) > 0 {
+		info.Status = info.Events[len(info.Events)-1].Status
+	}
+	return &info, nil
+}
@@ -0,0 +1,92 @@
+package carrier
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// signFakeWebhook 按模拟物流公司的签名规则生成推送签名
+func signFakeWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestFakeCarrierParseWebhook(t *testing.T) {
+	const secret = "carrier-secret"
+	body := []byte(`{"tracking_no":"SF0001","events":[{"time":"2026-01-02T10:00:00Z","status":3,"description":"已签收"},{"time":"2026-01-01T10:00:00Z","status":1,"description":"已揽收"}]}`)
+
+	tests := []struct {
+		name      string
+		secret    string
+		signature string
+		wantErr   bool
+	}{
+		{name: "未配置密钥拒绝推送", secret: "", signature: signFakeWebhook("", body), wantErr: true},
+		{name: "签名错误", secret: secret, signature: signFakeWebhook("other-secret", body), wantErr: true},
+		{name: "缺少签名", secret: secret, signature: "", wantErr: true},
+		{name: "签名正确", secret: secret, signature: signFakeWebhook(secret, body)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewFakeCarrier(AnyCarrier, "", tt.secret)
+			header := http.Header{}
+			header.Set(FakeSignatureHeader, tt.signature)
+
+			info, err := c.ParseWebhook(header, body)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidSignature) {
+					t.Fatalf("期望签名校验失败，实际 err=%v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("签名正确的推送被拒绝: %v", err)
+			}
+			if info.TrackingNo != "SF0001" {
+				t.Errorf("tracking_no = %s, 期望 SF0001", info.TrackingNo)
+			}
+			// 轨迹按时间升序，未给出当前状态时取最新一条轨迹的状态
+			if len(info.Events) != 2 || info.Events[0].Status != 1 || info.Status != 3 {
+				t.Errorf("解析结果不正确: %+v", info)
+			}
+		})
+	}
+}
+
+func TestFakeCarrierTrack(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "SF0001.json"), []byte(`{"events":[{"time":"2026-01-01T10:00:00Z","status":1}]}`), 0o644); err != nil {
+		t.Fatalf("写入模拟轨迹失败: %v", err)
+	}
+	// 物流单号目录之外的文件不应被读取
+	if err := os.WriteFile(filepath.Join(filepath.Dir(dir), "secret.json"), []byte(`{"tracking_no":"leaked"}`), 0o644); err != nil {
+		t.Fatalf("写入目录外文件失败: %v", err)
+	}
+	c := NewFakeCarrier(AnyCarrier, dir, "")
+
+	info, err := c.Track(context.Background(), "SF0001")
+	if err != nil {
+		t.Fatalf("查询轨迹失败: %v", err)
+	}
+	if info.TrackingNo != "SF0001" || info.Status != 1 {
+		t.Errorf("查询结果不正确: %+v", info)
+	}
+
+	if _, err := c.Track(context.Background(), "SF9999"); !errors.Is(err, ErrTrackingNotFound) {
+		t.Errorf("不存在的物流单应返回 ErrTrackingNotFound，实际 err=%v", err)
+	}
+
+	for _, trackingNo := range []string{"../secret", "a/../../secret", "/etc/passwd", ""} {
+		if info, err := c.Track(context.Background(), trackingNo); err == nil || errors.Is(err, ErrTrackingNotFound) {
+			t.Errorf("非法物流单号 %q 应被拒绝，实际 info=%+v, err=%v", trackingNo, info, err)
+		}
+	}
+}
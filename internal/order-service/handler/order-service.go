@@ -2,8 +2,26 @@ package handler
 
 import (
 	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 	orderv1 "zjMall/gen/go/api/proto/order"
+	"zjMall/internal/common/middleware"
+	"zjMall/internal/order-service/carrier"
+	"zjMall/internal/order-service/model"
 	"zjMall/internal/order-service/service"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type OrderServiceHandler struct {
@@ -89,3 +107,587 @@ func (h *OrderServiceHandler) MarkOrderPaid(ctx context.Context, req *orderv1.Ma
 func (h *OrderServiceHandler) GenerateOrderToken(ctx context.Context, req *orderv1.GenerateOrderTokenRequest) (*orderv1.GenerateOrderTokenResponse, error) {
 	return h.orderService.GenerateOrderToken(ctx, req)
 }
+
+// 订单发货（管理员）
+func (h *OrderServiceHandler) ShipOrder(ctx context.Context, req *orderv1.ShipOrderRequest) (*orderv1.ShipOrderResponse, error) {
+	if !middleware.CheckRole(ctx, "admin") {
+		return &orderv1.ShipOrderResponse{
+			Code:    403,
+			Message: "权限不足：需要管理员权限",
+		}, nil
+	}
+	if req.OrderNo == "" {
+		return &orderv1.ShipOrderResponse{
+			Code:    1,
+			Message: "订单号不能为空",
+		}, nil
+	}
+	if req.Carrier == "" {
+		return &orderv1.ShipOrderResponse{
+			Code:    1,
+			Message: "物流公司不能为空",
+		}, nil
+	}
+	if req.TrackingNo == "" {
+		return &orderv1.ShipOrderResponse{
+			Code:    1,
+			Message: "物流单号不能为空",
+		}, nil
+	}
+	return h.orderService.ShipOrder(ctx, req)
+}
+
+// 确认收货
+func (h *OrderServiceHandler) ConfirmReceipt(ctx context.Context, req *orderv1.ConfirmReceiptRequest) (*orderv1.ConfirmReceiptResponse, error) {
+	if req.OrderNo == "" {
+		return &orderv1.ConfirmReceiptResponse{
+			Code:    1,
+			Message: "订单号不能为空",
+		}, nil
+	}
+	return h.orderService.ConfirmReceipt(ctx, req)
+}
+
+// 查询订单物流轨迹
+func (h *OrderServiceHandler) GetShipmentTracking(ctx context.Context, req *orderv1.GetShipmentTrackingRequest) (*orderv1.GetShipmentTrackingResponse, error) {
+	if req.OrderNo == "" {
+		return &orderv1.GetShipmentTrackingResponse{
+			Code:    1,
+			Message: "订单号不能为空",
+		}, nil
+	}
+	return h.orderService.GetShipmentTracking(ctx, req)
+}
+
+// 申请售后（买家）
+func (h *OrderServiceHandler) CreateAfterSale(ctx context.Context, req *orderv1.CreateAfterSaleRequest) (*orderv1.CreateAfterSaleResponse, error) {
+	if req.OrderNo == "" || req.OrderItemId == "" {
+		return &orderv1.CreateAfterSaleResponse{
+			Code:    1,
+			Message: "订单号和订单明细ID不能为空",
+		}, nil
+	}
+	switch req.Type {
+	case orderv1.AfterSaleType_AFTER_SALE_TYPE_REFUND_ONLY, orderv1.AfterSaleType_AFTER_SALE_TYPE_RETURN_REFUND:
+	case orderv1.AfterSaleType_AFTER_SALE_TYPE_EXCHANGE:
+		if req.RefundAmount != "" {
+			return &orderv1.CreateAfterSaleResponse{
+				Code:    1,
+				Message: "换货不支持退款金额",
+			}, nil
+		}
+	default:
+		return &orderv1.CreateAfterSaleResponse{
+			Code:    1,
+			Message: "售后类型不正确",
+		}, nil
+	}
+	if req.Quantity <= 0 {
+		return &orderv1.CreateAfterSaleResponse{
+			Code:    1,
+			Message: "售后数量必须大于0",
+		}, nil
+	}
+	if req.Reason == "" || utf8.RuneCountInString(req.Reason) > 200 {
+		return &orderv1.CreateAfterSaleResponse{
+			Code:    1,
+			Message: "申请原因不能为空且不超过200个字符",
+		}, nil
+	}
+	if len(req.EvidenceImages) > 9 {
+		return &orderv1.CreateAfterSaleResponse{
+			Code:    1,
+			Message: "凭证图片最多9张",
+		}, nil
+	}
+	return h.orderService.CreateAfterSale(ctx, req)
+}
+
+// 查询售后单列表
+func (h *OrderServiceHandler) ListAfterSales(ctx context.Context, req *orderv1.ListAfterSalesRequest) (*orderv1.ListAfterSalesResponse, error) {
+	return h.orderService.ListAfterSales(ctx, req)
+}
+
+// 查询售后单详情
+func (h *OrderServiceHandler) GetAfterSale(ctx context.Context, req *orderv1.GetAfterSaleRequest) (*orderv1.GetAfterSaleResponse, error) {
+	if req.AfterSaleNo == "" {
+		return &orderv1.GetAfterSaleResponse{
+			Code:    1,
+			Message: "售后单号不能为空",
+		}, nil
+	}
+	return h.orderService.GetAfterSale(ctx, req)
+}
+
+// 填写退货物流（买家）
+func (h *OrderServiceHandler) SubmitAfterSaleReturn(ctx context.Context, req *orderv1.SubmitAfterSaleReturnRequest) (*orderv1.SubmitAfterSaleReturnResponse, error) {
+	if req.AfterSaleNo == "" {
+		return &orderv1.SubmitAfterSaleReturnResponse{
+			Code:    1,
+			Message: "售后单号不能为空",
+		}, nil
+	}
+	if req.Carrier == "" || req.TrackingNo == "" {
+		return &orderv1.SubmitAfterSaleReturnResponse{
+			Code:    1,
+			Message: "物流公司和物流单号不能为空",
+		}, nil
+	}
+	return h.orderService.SubmitAfterSaleReturn(ctx, req)
+}
+
+// 同意售后（管理员）
+func (h *OrderServiceHandler) ApproveAfterSale(ctx context.Context, req *orderv1.ApproveAfterSaleRequest) (*orderv1.ApproveAfterSaleResponse, error) {
+	if !middleware.CheckRole(ctx, "admin") {
+		return &orderv1.ApproveAfterSaleResponse{
+			Code:    403,
+			Message: "权限不足：需要管理员权限",
+		}, nil
+	}
+	if req.AfterSaleNo == "" {
+		return &orderv1.ApproveAfterSaleResponse{
+			Code:    1,
+			Message: "售后单号不能为空",
+		}, nil
+	}
+	return h.orderService.ApproveAfterSale(ctx, req)
+}
+
+// 拒绝售后（管理员）
+func (h *OrderServiceHandler) RejectAfterSale(ctx context.Context, req *orderv1.RejectAfterSaleRequest) (*orderv1.RejectAfterSaleResponse, error) {
+	if !middleware.CheckRole(ctx, "admin") {
+		return &orderv1.RejectAfterSaleResponse{
+			Code:    403,
+			Message: "权限不足：需要管理员权限",
+		}, nil
+	}
+	if req.AfterSaleNo == "" {
+		return &orderv1.RejectAfterSaleResponse{
+			Code:    1,
+			Message: "售后单号不能为空",
+		}, nil
+	}
+	if req.Reason == "" {
+		return &orderv1.RejectAfterSaleResponse{
+			Code:    1,
+			Message: "拒绝原因不能为空",
+		}, nil
+	}
+	return h.orderService.RejectAfterSale(ctx, req)
+}
+
+// 确认收到退货（管理员）
+func (h *OrderServiceHandler) ConfirmAfterSaleReceived(ctx context.Context, req *orderv1.ConfirmAfterSaleReceivedRequest) (*orderv1.ConfirmAfterSaleReceivedResponse, error) {
+	if !middleware.CheckRole(ctx, "admin") {
+		return &orderv1.ConfirmAfterSaleReceivedResponse{
+			Code:    403,
+			Message: "权限不足：需要管理员权限",
+		}, nil
+	}
+	if req.AfterSaleNo == "" {
+		return &orderv1.ConfirmAfterSaleReceivedResponse{
+			Code:    1,
+			Message: "售后单号不能为空",
+		}, nil
+	}
+	return h.orderService.ConfirmAfterSaleReceived(ctx, req)
+}
+
+// 创建秒杀活动（管理员）
+func (h *OrderServiceHandler) CreateSeckillActivity(ctx context.Context, req *orderv1.CreateSeckillActivityRequest) (*orderv1.CreateSeckillActivityResponse, error) {
+	if !middleware.CheckRole(ctx, "admin") {
+		return &orderv1.CreateSeckillActivityResponse{
+			Code:    403,
+			Message: "权限不足：需要管理员权限",
+		}, nil
+	}
+	if req.Title == "" || utf8.RuneCountInString(req.Title) > 100 {
+		return &orderv1.CreateSeckillActivityResponse{
+			Code:    1,
+			Message: "活动标题不能为空且不超过100个字符",
+		}, nil
+	}
+	if req.ProductId == "" || req.SkuId == "" {
+		return &orderv1.CreateSeckillActivityResponse{
+			Code:    1,
+			Message: "商品ID和SKU ID不能为空",
+		}, nil
+	}
+	if req.SeckillPrice == "" {
+		return &orderv1.CreateSeckillActivityResponse{
+			Code:    1,
+			Message: "秒杀价不能为空",
+		}, nil
+	}
+	if req.Stock <= 0 {
+		return &orderv1.CreateSeckillActivityResponse{
+			Code:    1,
+			Message: "活动库存必须大于0",
+		}, nil
+	}
+	if req.StartTime == nil || req.EndTime == nil {
+		return &orderv1.CreateSeckillActivityResponse{
+			Code:    1,
+			Message: "活动开始时间和结束时间不能为空",
+		}, nil
+	}
+	return h.orderService.CreateSeckillActivity(ctx, req)
+}
+
+// 查询秒杀活动
+func (h *OrderServiceHandler) GetSeckillActivity(ctx context.Context, req *orderv1.GetSeckillActivityRequest) (*orderv1.GetSeckillActivityResponse, error) {
+	if req.ActivityNo == "" {
+		return &orderv1.GetSeckillActivityResponse{
+			Code:    1,
+			Message: "活动编号不能为空",
+		}, nil
+	}
+	return h.orderService.GetSeckillActivity(ctx, req)
+}
+
+// 参与秒杀
+func (h *OrderServiceHandler) Seckill(ctx context.Context, req *orderv1.SeckillRequest) (*orderv1.SeckillResponse, error) {
+	if req.ActivityNo == "" {
+		return &orderv1.SeckillResponse{
+			Code:    1,
+			Message: "活动编号不能为空",
+		}, nil
+	}
+	if req.AddressId == "" {
+		return &orderv1.SeckillResponse{
+			Code:    1,
+			Message: "收货地址不能为空",
+		}, nil
+	}
+	if utf8.RuneCountInString(req.BuyerRemark) > 200 {
+		return &orderv1.SeckillResponse{
+			Code:    1,
+			Message: "买家留言不超过200个字符",
+		}, nil
+	}
+	return h.orderService.Seckill(ctx, req)
+}
+
+// 查询秒杀结果
+func (h *OrderServiceHandler) GetSeckillResult(ctx context.Context, req *orderv1.GetSeckillResultRequest) (*orderv1.GetSeckillResultResponse, error) {
+	if req.ActivityNo == "" {
+		return &orderv1.GetSeckillResultResponse{
+			Code:    1,
+			Message: "活动编号不能为空",
+		}, nil
+	}
+	return h.orderService.GetSeckillResult(ctx, req)
+}
+
+// 管理员搜索订单
+func (h *OrderServiceHandler) AdminSearchOrders(ctx context.Context, req *orderv1.AdminSearchOrdersRequest) (*orderv1.AdminSearchOrdersResponse, error) {
+	if !middleware.CheckRole(ctx, "admin") {
+		return &orderv1.AdminSearchOrdersResponse{
+			Code:    403,
+			Message: "权限不足：需要管理员权限",
+		}, nil
+	}
+	return h.orderService.AdminSearchOrders(ctx, req)
+}
+
+// 管理员延长订单支付截止时间
+func (h *OrderServiceHandler) ExtendPayDeadline(ctx context.Context, req *orderv1.ExtendPayDeadlineRequest) (*orderv1.ExtendPayDeadlineResponse, error) {
+	if !middleware.CheckRole(ctx, "admin") {
+		return &orderv1.ExtendPayDeadlineResponse{
+			Code:    403,
+			Message: "权限不足：需要管理员权限",
+		}, nil
+	}
+	if req.OrderNo == "" {
+		return &orderv1.ExtendPayDeadlineResponse{
+			Code:    1,
+			Message: "订单号不能为空",
+		}, nil
+	}
+	if req.ExtendMinutes <= 0 || req.ExtendMinutes > 1440 {
+		return &orderv1.ExtendPayDeadlineResponse{
+			Code:    1,
+			Message: "延长时间必须在1~1440分钟之间",
+		}, nil
+	}
+	if utf8.RuneCountInString(req.Reason) > 200 {
+		return &orderv1.ExtendPayDeadlineResponse{
+			Code:    1,
+			Message: "延长原因不能超过200个字符",
+		}, nil
+	}
+	return h.orderService.ExtendPayDeadline(ctx, req)
+}
+
+// 管理员查询发票
+func (h *OrderServiceHandler) AdminListInvoices(ctx context.Context, req *orderv1.AdminListInvoicesRequest) (*orderv1.AdminListInvoicesResponse, error) {
+	if !middleware.CheckRole(ctx, "admin") {
+		return &orderv1.AdminListInvoicesResponse{
+			Code:    403,
+			Message: "权限不足：需要管理员权限",
+		}, nil
+	}
+	return h.orderService.AdminListInvoices(ctx, req)
+}
+
+// invoiceNumberPattern 发票号码：8位（纸质发票号码）~20位（数电发票号码）数字
+var invoiceNumberPattern = regexp.MustCompile(`^\d{8,20}$`)
+
+// 管理员开具发票（登记发票号码）
+func (h *OrderServiceHandler) IssueInvoice(ctx context.Context, req *orderv1.IssueInvoiceRequest) (*orderv1.IssueInvoiceResponse, error) {
+	if !middleware.CheckRole(ctx, "admin") {
+		return &orderv1.IssueInvoiceResponse{
+			Code:    403,
+			Message: "权限不足：需要管理员权限",
+		}, nil
+	}
+	if req.InvoiceId == "" {
+		return &orderv1.IssueInvoiceResponse{
+			Code:    1,
+			Message: "发票ID不能为空",
+		}, nil
+	}
+	if !invoiceNumberPattern.MatchString(req.InvoiceNumber) {
+		return &orderv1.IssueInvoiceResponse{
+			Code:    1,
+			Message: "发票号码格式错误，应为8~20位数字",
+		}, nil
+	}
+	return h.orderService.IssueInvoice(ctx, req)
+}
+
+// 评价订单商品
+func (h *OrderServiceHandler) CreateReview(ctx context.Context, req *orderv1.CreateReviewRequest) (*orderv1.CreateReviewResponse, error) {
+	if req.OrderNo == "" || req.OrderItemId == "" {
+		return &orderv1.CreateReviewResponse{
+			Code:    1,
+			Message: "订单号和订单商品不能为空",
+		}, nil
+	}
+	if req.Rating < 1 || req.Rating > 5 {
+		return &orderv1.CreateReviewResponse{
+			Code:    1,
+			Message: "评分必须为1~5星",
+		}, nil
+	}
+	if utf8.RuneCountInString(req.Content) > 500 {
+		return &orderv1.CreateReviewResponse{
+			Code:    1,
+			Message: "评价内容不能超过500字",
+		}, nil
+	}
+	if len(req.Images) > 9 {
+		return &orderv1.CreateReviewResponse{
+			Code:    1,
+			Message: "晒图最多9张",
+		}, nil
+	}
+	return h.orderService.CreateReview(ctx, req)
+}
+
+// 查询商品评价
+func (h *OrderServiceHandler) ListProductReviews(ctx context.Context, req *orderv1.ListProductReviewsRequest) (*orderv1.ListProductReviewsResponse, error) {
+	if req.ProductId == "" {
+		return &orderv1.ListProductReviewsResponse{
+			Code:    1,
+			Message: "商品ID不能为空",
+		}, nil
+	}
+	if req.Rating < 0 || req.Rating > 5 {
+		return &orderv1.ListProductReviewsResponse{
+			Code:    1,
+			Message: "星级筛选范围为1~5",
+		}, nil
+	}
+	return h.orderService.ListProductReviews(ctx, req)
+}
+
+// 管理员查询评价
+func (h *OrderServiceHandler) AdminListReviews(ctx context.Context, req *orderv1.AdminListReviewsRequest) (*orderv1.AdminListReviewsResponse, error) {
+	if !middleware.CheckRole(ctx, "admin") {
+		return &orderv1.AdminListReviewsResponse{
+			Code:    403,
+			Message: "权限不足：需要管理员权限",
+		}, nil
+	}
+	return h.orderService.AdminListReviews(ctx, req)
+}
+
+// 管理员审核评价
+func (h *OrderServiceHandler) ModerateReview(ctx context.Context, req *orderv1.ModerateReviewRequest) (*orderv1.ModerateReviewResponse, error) {
+	if !middleware.CheckRole(ctx, "admin") {
+		return &orderv1.ModerateReviewResponse{
+			Code:    403,
+			Message: "权限不足：需要管理员权限",
+		}, nil
+	}
+	if req.ReviewId == "" {
+		return &orderv1.ModerateReviewResponse{
+			Code:    1,
+			Message: "评价ID不能为空",
+		}, nil
+	}
+	if !req.Approve && req.Remark == "" {
+		return &orderv1.ModerateReviewResponse{
+			Code:    1,
+			Message: "驳回评价需填写审核说明",
+		}, nil
+	}
+	if utf8.RuneCountInString(req.Remark) > 200 {
+		return &orderv1.ModerateReviewResponse{
+			Code:    1,
+			Message: "审核说明不能超过200字",
+		}, nil
+	}
+	return h.orderService.ModerateReview(ctx, req)
+}
+
+// 管理员以商家身份回复评价
+func (h *OrderServiceHandler) ReplyReview(ctx context.Context, req *orderv1.ReplyReviewRequest) (*orderv1.ReplyReviewResponse, error) {
+	if !middleware.CheckRole(ctx, "admin") {
+		return &orderv1.ReplyReviewResponse{
+			Code:    403,
+			Message: "权限不足：需要管理员权限",
+		}, nil
+	}
+	if req.ReviewId == "" {
+		return &orderv1.ReplyReviewResponse{
+			Code:    1,
+			Message: "评价ID不能为空",
+		}, nil
+	}
+	if req.Content == "" || utf8.RuneCountInString(req.Content) > 500 {
+		return &orderv1.ReplyReviewResponse{
+			Code:    1,
+			Message: "回复内容不能为空且不能超过500字",
+		}, nil
+	}
+	return h.orderService.ReplyReview(ctx, req)
+}
+
+// ExportOrdersHTTP 管理员导出订单 CSV：GET /api/v1/admin/orders/export
+// 查询参数与 AdminSearchOrders 相同（时间为 RFC3339 格式），按批查询并流式写出，不受订单数量限制
+func (h *OrderServiceHandler) ExportOrdersHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !middleware.CheckRole(r.Context(), "admin") {
+		http.Error(w, `{"code":403,"message":"权限不足：需要管理员权限"}`, http.StatusForbidden)
+		return
+	}
+
+	req, err := parseAdminSearchOrdersQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"code":1,"message":%q}`, err.Error()), http.StatusBadRequest)
+		return
+	}
+	filter, err := service.NewOrderSearchFilter(req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"code":1,"message":%q}`, err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	filename := fmt.Sprintf("orders_%s.csv", time.Now().Format("20060102150405"))
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	// UTF-8 BOM，避免 Excel 打开中文乱码
+	io.WriteString(w, "\uFEFF")
+
+	rc := http.NewResponseController(w)
+	writer := csv.NewWriter(w)
+	writer.Write(service.OrderCSVHeader)
+	err = h.orderService.ExportOrders(r.Context(), filter, func(orders []*model.Order) error {
+		// 导出耗时可能超过服务器写超时，每批写出前延长写超时
+		rc.SetWriteDeadline(time.Now().Add(orderExportWriteTimeout))
+		for _, o := range orders {
+			if err := writer.Write(service.OrderCSVRecord(o)); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return err
+		}
+		return rc.Flush()
+	})
+	if err != nil {
+		// 响应头已写出，只能中断导出
+		log.Printf("❌ [OrderHandler] ExportOrdersHTTP: 导出订单失败: err=%v", err)
+		return
+	}
+	writer.Flush()
+}
+
+// orderExportWriteTimeout 订单导出每批数据的写超时
+const orderExportWriteTimeout = 30 * time.Second
+
+// parseAdminSearchOrdersQuery 解析订单导出的查询参数
+func parseAdminSearchOrdersQuery(query url.Values) (*orderv1.AdminSearchOrdersRequest, error) {
+	req := &orderv1.AdminSearchOrdersRequest{
+		OrderNo:       query.Get("order_no"),
+		UserId:        query.Get("user_id"),
+		MinPayAmount:  query.Get("min_pay_amount"),
+		MaxPayAmount:  query.Get("max_pay_amount"),
+		ReceiverPhone: query.Get("receiver_phone"),
+		SkuId:         query.Get("sku_id"),
+	}
+	if v := query.Get("status"); v != "" {
+		status, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("订单状态格式错误")
+		}
+		req.Status = orderv1.OrderStatus(status)
+	}
+	if v := query.Get("created_from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, fmt.Errorf("下单开始时间格式错误，应为 RFC3339 格式")
+		}
+		req.CreatedFrom = timestamppb.New(t)
+	}
+	if v := query.Get("created_to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, fmt.Errorf("下单结束时间格式错误，应为 RFC3339 格式")
+		}
+		req.CreatedTo = timestamppb.New(t)
+	}
+	return req, nil
+}
+
+// CarrierWebhookHTTP 物流公司推送回调：POST /api/v1/shipments/webhook/{carrier}
+// 推送格式由各物流公司适配器解析，由签名校验保证安全（不经过用户认证）
+func (h *OrderServiceHandler) CarrierWebhookHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	carrierCode := strings.TrimPrefix(r.URL.Path, "/api/v1/shipments/webhook/")
+	if carrierCode == "" || strings.Contains(carrierCode, "/") {
+		http.Error(w, `{"code":1,"message":"物流公司代码不能为空"}`, http.StatusBadRequest)
+		return
+	}
+
+	// 限制推送内容大小（1MB）
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, `{"code":1,"message":"读取请求失败"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.orderService.HandleCarrierWebhook(r.Context(), carrierCode, r.Header, body); err != nil {
+		log.Printf("❌ [OrderHandler] CarrierWebhookHTTP: 处理物流推送失败: carrier=%s, err=%v", carrierCode, err)
+		if errors.Is(err, carrier.ErrInvalidSignature) {
+			http.Error(w, `{"code":1,"message":"签名校验失败"}`, http.StatusUnauthorized)
+			return
+		}
+		// 返回非 2xx 让物流公司重推
+		http.Error(w, `{"code":1,"message":"处理失败"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "message": "success"})
+}
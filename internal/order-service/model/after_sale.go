@@ -0,0 +1,62 @@
+package model
+
+import (
+	"time"
+	"zjMall/pkg"
+)
+
+// 售后单号前缀（区别于普通订单 01、秒杀订单 02）
+const AfterSaleNoPrefix = "30"
+
+// 售后类型常量
+const (
+	AfterSaleTypeRefundOnly   = int8(1) // 仅退款
+	AfterSaleTypeReturnRefund = int8(2) // 退货退款
+	AfterSaleTypeExchange     = int8(3) // 换货
+)
+
+// 售后状态常量
+const (
+	AfterSaleStatusSubmitted = int8(1) // 已提交，待审核
+	AfterSaleStatusApproved  = int8(2) // 已同意（退货/换货待买家寄回）
+	AfterSaleStatusReturning = int8(3) // 买家已寄回
+	AfterSaleStatusReceived  = int8(4) // 商家已收货
+	AfterSaleStatusRefunding = int8(5) // 退款中
+	AfterSaleStatusRefunded  = int8(6) // 已退款
+	AfterSaleStatusRejected  = int8(7) // 已拒绝
+	AfterSaleStatusCompleted = int8(8) // 已完成（换货）
+)
+
+// AfterSale 售后单表（一个售后单对应一条订单明细，同一明细可分多次申请，累计数量不超过购买数量）
+type AfterSale struct {
+	pkg.BaseModel
+
+	AfterSaleNo string `gorm:"type:varchar(32);uniqueIndex;not null;comment:售后单号" json:"after_sale_no"`
+	OrderNo     string `gorm:"type:varchar(32);index;not null;comment:订单号" json:"order_no"`
+	OrderItemID string `gorm:"type:varchar(26);index;not null;comment:订单明细ID" json:"order_item_id"`
+	UserID      string `gorm:"type:varchar(26);index;not null;comment:用户ID" json:"user_id"`
+	SKUID       string `gorm:"column:sku_id;type:varchar(26);not null;comment:SKU ID" json:"sku_id"`
+
+	Type           int8    `gorm:"type:tinyint;not null;comment:售后类型：1-仅退款，2-退货退款，3-换货" json:"type"`
+	Status         int8    `gorm:"type:tinyint;index;not null;default:1;comment:售后状态：1-已提交，2-已同意，3-买家已寄回，4-商家已收货，5-退款中，6-已退款，7-已拒绝，8-已完成" json:"status"`
+	Quantity       int32   `gorm:"type:int;not null;comment:售后数量" json:"quantity"`
+	Reason         string  `gorm:"type:varchar(255);not null;comment:申请原因" json:"reason"`
+	EvidenceImages string  `gorm:"type:json;comment:凭证图片（JSON数组）" json:"evidence_images"`
+	RefundAmount   float64 `gorm:"type:decimal(10,2);not null;default:0;comment:申请退款金额" json:"refund_amount"`
+	RefundNo       string  `gorm:"type:varchar(32);comment:退款单号" json:"refund_no"`
+
+	ReturnCarrier    string `gorm:"type:varchar(32);comment:退货物流公司" json:"return_carrier"`
+	ReturnTrackingNo string `gorm:"type:varchar(64);comment:退货物流单号" json:"return_tracking_no"`
+
+	AuditRemark string     `gorm:"type:varchar(255);comment:审核备注（拒绝原因）" json:"audit_remark"`
+	OperatorID  string     `gorm:"type:varchar(26);comment:最近一次处理的管理员ID" json:"operator_id"`
+	ApprovedAt  *time.Time `gorm:"type:timestamp;null;default:null;comment:审核通过时间" json:"approved_at"`
+	ReturnedAt  *time.Time `gorm:"type:timestamp;null;default:null;comment:买家寄回时间" json:"returned_at"`
+	ReceivedAt  *time.Time `gorm:"type:timestamp;null;default:null;comment:商家收货时间" json:"received_at"`
+	RefundedAt  *time.Time `gorm:"type:timestamp;null;default:null;comment:退款成功时间" json:"refunded_at"`
+	Version     int        `gorm:"type:int;not null;default:0;comment:版本号" json:"version"`
+}
+
+func (AfterSale) TableName() string {
+	return "after_sales"
+}
@@ -0,0 +1,44 @@
+package model
+
+import (
+	"time"
+	"zjMall/pkg"
+)
+
+// 发票状态常量
+const (
+	InvoiceStatusPending    = int8(1) // 待开票（订单完成前不可开具）
+	InvoiceStatusIssuable   = int8(2) // 可开票（订单已完成，等待开具）
+	InvoiceStatusIssued     = int8(3) // 已开票
+	InvoiceStatusRedFlushed = int8(4) // 已红冲（开票后退款）
+	InvoiceStatusVoided     = int8(5) // 已作废（开票前订单取消、关闭或退款）
+)
+
+// OrderInvoice 订单发票表（抬头为下单时的快照；拆单时按子订单分别开具）
+// 已开票后发生部分退款时，原发票红冲并按剩余金额生成新的发票记录
+type OrderInvoice struct {
+	pkg.BaseModel
+
+	OrderNo    string `gorm:"type:varchar(32);index;not null;comment:开票订单号（拆单时为子订单号）" json:"order_no"`
+	PayOrderNo string `gorm:"type:varchar(32);index;not null;comment:支付订单号（拆单时为主订单号）" json:"pay_order_no"`
+	UserID     string `gorm:"type:varchar(26);index;not null;comment:用户ID" json:"user_id"`
+
+	TitleType int8    `gorm:"type:tinyint;not null;comment:抬头类型：1-个人，2-企业" json:"title_type"`
+	Title     string  `gorm:"type:varchar(100);not null;comment:抬头名称" json:"title"`
+	TaxNo     string  `gorm:"type:varchar(20);comment:纳税人识别号" json:"tax_no"`
+	Email     string  `gorm:"type:varchar(100);comment:接收电子发票的邮箱" json:"email"`
+	Amount    float64 `gorm:"type:decimal(10,2);not null;comment:开票金额" json:"amount"`
+
+	Status        int8       `gorm:"type:tinyint;index;not null;default:1;comment:发票状态：1-待开票，2-可开票，3-已开票，4-已红冲，5-已作废" json:"status"`
+	InvoiceNumber string     `gorm:"type:varchar(32);comment:发票号码" json:"invoice_number"`
+	OperatorID    string     `gorm:"type:varchar(26);comment:开票管理员ID" json:"operator_id"`
+	Remark        string     `gorm:"type:varchar(255);comment:最近一次状态变更说明" json:"remark"`
+	IssuedAt      *time.Time `gorm:"type:timestamp;null;default:null;comment:开票时间" json:"issued_at"`
+	RedFlushedAt  *time.Time `gorm:"type:timestamp;null;default:null;comment:红冲时间" json:"red_flushed_at"`
+	VoidedAt      *time.Time `gorm:"type:timestamp;null;default:null;comment:作废时间" json:"voided_at"`
+	Version       int        `gorm:"type:int;not null;default:0;comment:版本号" json:"version"`
+}
+
+func (OrderInvoice) TableName() string {
+	return "order_invoices"
+}
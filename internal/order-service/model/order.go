@@ -12,6 +12,13 @@ const (
 	OrderTypeSeckill       = "seckill"
 )
 
+// 拆单类型：一次结算包含多个商家的商品时，生成一个主订单用于支付，按商家拆分子订单分别履约
+const (
+	OrderSplitTypeNone   = int8(0) // 未拆单
+	OrderSplitTypeParent = int8(1) // 主订单（仅用于支付，不含明细，不发货）
+	OrderSplitTypeChild  = int8(2) // 子订单（独立运费、状态与物流，随主订单支付）
+)
+
 // Order 订单主表
 type Order struct {
 	pkg.BaseModel
@@ -23,9 +30,16 @@ type Order struct {
 	DiscountAmount float64 `gorm:"type:decimal(10,2);not null;default:0;comment:优惠总金额" json:"discount_amount"`
 	ShippingAmount float64 `gorm:"type:decimal(10,2);not null;default:0;comment:运费金额" json:"shipping_amount"`
 	PayAmount      float64 `gorm:"type:decimal(10,2);not null;default:0;comment:应付金额" json:"pay_amount"`
+	CouponID       string  `gorm:"type:varchar(26);comment:使用的优惠券ID" json:"coupon_id"`
+
+	SplitType     int8   `gorm:"type:tinyint;not null;default:0;comment:拆单类型：0-未拆单，1-主订单，2-子订单" json:"split_type"`
+	ParentOrderNo string `gorm:"type:varchar(32);index;comment:主订单号（仅子订单）" json:"parent_order_no,omitempty"`
+	MerchantID    string `gorm:"type:varchar(26);comment:商家ID（为空表示平台自营）" json:"merchant_id,omitempty"`
+
+	SeckillActivityNo string `gorm:"type:varchar(32);index;comment:秒杀活动编号（仅秒杀订单）" json:"seckill_activity_no,omitempty"`
 
 	ReceiverName    string `gorm:"type:varchar(50);comment:收货人姓名" json:"receiver_name"`
-	ReceiverPhone   string `gorm:"type:varchar(20);comment:收货人电话" json:"receiver_phone"`
+	ReceiverPhone   string `gorm:"type:varchar(20);index;comment:收货人电话" json:"receiver_phone"`
 	ReceiverAddress string `gorm:"type:varchar(255);comment:收货地址" json:"receiver_address"`
 
 	BuyerRemark string `gorm:"type:varchar(255);comment:买家留言" json:"buyer_remark"`
@@ -34,7 +48,11 @@ type Order struct {
 	PayTradeNo    string `gorm:"type:varchar(64);comment:支付流水号" json:"pay_trade_no"`
 	ItemsSnapshot string `gorm:"column:items_snapshot;type:json;comment:商品列表精简快照（JSON格式）" json:"items_snapshot"`
 
+	ShippingCarrier string `gorm:"type:varchar(32);comment:物流公司" json:"shipping_carrier"`
+	TrackingNo      string `gorm:"type:varchar(64);comment:物流单号" json:"tracking_no"`
+
 	CreatedAt   time.Time  `json:"created_at"`
+	PayDeadline *time.Time `gorm:"type:timestamp;null;default:null;comment:支付截止时间（子订单为空）" json:"pay_deadline"`
 	PaidAt      *time.Time `gorm:"type:timestamp;null;default:null;comment:支付时间" json:"paid_at"`
 	ShippedAt   *time.Time `gorm:"type:timestamp;null;default:null;comment:发货时间" json:"shipped_at"`
 	CompletedAt *time.Time `gorm:"type:timestamp;null;default:null;comment:完成时间" json:"completed_at"`
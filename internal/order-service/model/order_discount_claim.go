@@ -0,0 +1,26 @@
+package model
+
+import "zjMall/pkg"
+
+// 订单优惠占用状态
+const (
+	DiscountClaimStatusPending  = int8(1) // 待绑定：即将 / 已经向促销服务锁定优惠，订单尚未创建
+	DiscountClaimStatusBound    = int8(2) // 已绑定：与订单在同一事务中绑定，之后随订单取消 / 关闭释放
+	DiscountClaimStatusReleased = int8(3) // 已归还：订单未创建，优惠已归还给促销服务
+)
+
+// OrderDiscountClaim 订单优惠占用记录（优惠券 / 促销配额）
+// 下单时先写入待绑定记录，再向促销服务锁定优惠，创建订单的事务内将其绑定；
+// 进程崩溃或释放失败导致长时间未绑定的记录由补偿任务归还优惠，保证优惠券与订单同生共死
+type OrderDiscountClaim struct {
+	pkg.BaseModel
+
+	OrderNo  string `gorm:"type:varchar(32);uniqueIndex;not null;comment:订单号（拆单时为主订单号）" json:"order_no"`
+	UserID   string `gorm:"type:varchar(26);not null;comment:用户ID" json:"user_id"`
+	CouponID string `gorm:"type:varchar(26);comment:使用的优惠券ID（仅使用促销时为空）" json:"coupon_id"`
+	Status   int8   `gorm:"type:tinyint;not null;default:1;comment:状态：1-待绑定，2-已绑定，3-已归还" json:"status"`
+}
+
+func (OrderDiscountClaim) TableName() string {
+	return "order_discount_claims"
+}
@@ -0,0 +1,23 @@
+package model
+
+import "zjMall/pkg"
+
+// OperatorSystem 系统触发的状态流转（超时关闭、支付/退款回调、自动确认收货等）的操作人
+const OperatorSystem = "system"
+
+// OrderStatusHistory 订单状态流转历史表（每次状态流转与订单状态更新在同一事务中写入）
+type OrderStatusHistory struct {
+	pkg.BaseModel
+
+	OrderNo    string `gorm:"type:varchar(32);index;not null;comment:订单号" json:"order_no"`
+	FromStatus int8   `gorm:"type:tinyint;not null;comment:流转前状态" json:"from_status"`
+	ToStatus   int8   `gorm:"type:tinyint;not null;comment:流转后状态" json:"to_status"`
+	Event      string `gorm:"type:varchar(32);not null;comment:触发事件" json:"event"`
+	Operator   string `gorm:"type:varchar(32);not null;comment:操作人（用户ID或system）" json:"operator"`
+	Reason     string `gorm:"type:varchar(255);comment:流转原因" json:"reason"`
+	TraceID    string `gorm:"type:varchar(64);comment:请求 Trace ID" json:"trace_id"`
+}
+
+func (OrderStatusHistory) TableName() string {
+	return "order_status_history"
+}
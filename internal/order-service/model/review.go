@@ -0,0 +1,45 @@
+package model
+
+import (
+	"time"
+	"zjMall/pkg"
+)
+
+// 评价审核状态常量
+const (
+	ReviewStatusPending  = int8(1) // 待审核
+	ReviewStatusApproved = int8(2) // 审核通过（对外展示并计入商品评分）
+	ReviewStatusRejected = int8(3) // 审核驳回
+)
+
+// ProductReview 商品评价表（订单完成后按订单明细评价，每个明细只能评价一次）
+type ProductReview struct {
+	pkg.BaseModel
+
+	OrderNo     string `gorm:"type:varchar(32);index;not null;comment:订单号（拆单时为子订单号）" json:"order_no"`
+	OrderItemID string `gorm:"type:varchar(26);uniqueIndex;not null;comment:订单明细ID" json:"order_item_id"`
+	UserID      string `gorm:"type:varchar(26);index;not null;comment:用户ID" json:"user_id"`
+	ProductID   string `gorm:"type:varchar(26);index:idx_product_status;not null;comment:商品ID" json:"product_id"`
+	SKUID       string `gorm:"column:sku_id;type:varchar(26);not null;comment:SKU ID" json:"sku_id"`
+	SKUName     string `gorm:"type:varchar(100);comment:SKU 名称快照" json:"sku_name"`
+
+	Rating      int8   `gorm:"type:tinyint;not null;comment:评分 1~5" json:"rating"`
+	Content     string `gorm:"type:varchar(1000);comment:评价内容" json:"content"`
+	Images      string `gorm:"type:json;comment:晒图URL列表（JSON数组）" json:"images"`
+	IsAnonymous bool   `gorm:"type:tinyint(1);not null;default:0;comment:是否匿名" json:"is_anonymous"`
+
+	Status           int8       `gorm:"type:tinyint;index;index:idx_product_status;not null;default:1;comment:审核状态：1-待审核，2-审核通过，3-审核驳回" json:"status"`
+	ModerationRemark string     `gorm:"type:varchar(255);comment:审核说明" json:"moderation_remark"`
+	ModeratorID      string     `gorm:"type:varchar(26);comment:审核管理员ID" json:"moderator_id"`
+	ModeratedAt      *time.Time `gorm:"type:timestamp;null;default:null;comment:审核时间" json:"moderated_at"`
+
+	Reply     string     `gorm:"type:varchar(1000);comment:商家回复" json:"reply"`
+	ReplierID string     `gorm:"type:varchar(26);comment:回复人ID" json:"replier_id"`
+	RepliedAt *time.Time `gorm:"type:timestamp;null;default:null;comment:回复时间" json:"replied_at"`
+
+	Version int `gorm:"type:int;not null;default:0;comment:版本号" json:"version"`
+}
+
+func (ProductReview) TableName() string {
+	return "product_reviews"
+}
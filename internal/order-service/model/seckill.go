@@ -0,0 +1,40 @@
+package model
+
+import (
+	"time"
+	"zjMall/pkg"
+)
+
+// 秒杀活动编号前缀
+const SeckillActivityNoPrefix = "40"
+
+// 秒杀活动状态常量
+const (
+	SeckillActivityStatusOnline  = int8(1) // 已上线（未开始/进行中/已结束待对账）
+	SeckillActivityStatusSettled = int8(2) // 已结束并完成库存对账
+)
+
+// SeckillActivity 秒杀活动表（单 SKU，每人限购 1 件）
+// 活动创建时从 MySQL 库存预扣活动库存并预热到 Redis，活动结束后按 Redis 剩余名额将未售出库存归还 MySQL
+type SeckillActivity struct {
+	pkg.BaseModel
+
+	ActivityNo   string  `gorm:"type:varchar(32);uniqueIndex;not null;comment:活动编号" json:"activity_no"`
+	Title        string  `gorm:"type:varchar(100);not null;comment:活动标题" json:"title"`
+	ProductID    string  `gorm:"type:varchar(26);not null;comment:商品ID" json:"product_id"`
+	SKUID        string  `gorm:"column:sku_id;type:varchar(26);index;not null;comment:SKU ID" json:"sku_id"`
+	SeckillPrice float64 `gorm:"type:decimal(10,2);not null;comment:秒杀价" json:"seckill_price"`
+	Stock        int32   `gorm:"type:int;not null;comment:活动库存" json:"stock"`
+	WarehouseID  string  `gorm:"type:varchar(26);comment:预扣库存的仓库ID（秒杀订单由此仓发货）" json:"warehouse_id"`
+	SoldCount    int32   `gorm:"type:int;not null;default:0;comment:已售数量（对账后写入）" json:"sold_count"`
+	Status       int8    `gorm:"type:tinyint;not null;default:1;index:idx_status_end,priority:1;comment:活动状态：1-已上线，2-已对账" json:"status"`
+
+	StartTime  time.Time  `gorm:"type:timestamp;not null;comment:开始时间" json:"start_time"`
+	EndTime    time.Time  `gorm:"type:timestamp;not null;index:idx_status_end,priority:2;comment:结束时间" json:"end_time"`
+	SettledAt  *time.Time `gorm:"type:timestamp;null;default:null;comment:库存对账时间" json:"settled_at"`
+	OperatorID string     `gorm:"type:varchar(26);comment:创建活动的管理员ID" json:"operator_id"`
+}
+
+func (SeckillActivity) TableName() string {
+	return "seckill_activities"
+}
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"time"
+	"zjMall/pkg"
+)
+
+// 物流状态常量
+const (
+	ShipmentStatusInTransit  = int8(1) // 运输中
+	ShipmentStatusDelivering = int8(2) // 派送中
+	ShipmentStatusDelivered  = int8(3) // 已签收
+	ShipmentStatusException  = int8(4) // 异常（拒收、丢件等）
+)
+
+// Shipment 物流单表（订单发货时创建，一个订单对应一个物流单）
+type Shipment struct {
+	pkg.BaseModel
+
+	OrderNo     string     `gorm:"type:varchar(32);uniqueIndex;not null;comment:订单号" json:"order_no"`
+	UserID      string     `gorm:"type:varchar(26);index;not null;comment:用户ID" json:"user_id"`
+	Carrier     string     `gorm:"type:varchar(32);not null;uniqueIndex:uk_carrier_tracking,priority:1;comment:物流公司" json:"carrier"`
+	TrackingNo  string     `gorm:"type:varchar(64);not null;uniqueIndex:uk_carrier_tracking,priority:2;comment:物流单号" json:"tracking_no"`
+	Status      int8       `gorm:"type:tinyint;not null;default:1;comment:物流状态：1-运输中，2-派送中，3-已签收，4-异常" json:"status"`
+	ShippedAt   time.Time  `gorm:"type:timestamp;not null;comment:发货时间" json:"shipped_at"`
+	LastEventAt *time.Time `gorm:"type:timestamp;null;default:null;comment:最新轨迹时间" json:"last_event_at"`
+	SyncedAt    *time.Time `gorm:"type:timestamp;null;default:null;comment:最近一次主动查询物流公司的时间" json:"synced_at"`
+	DeliveredAt *time.Time `gorm:"type:timestamp;null;default:null;comment:签收时间" json:"delivered_at"`
+	Version     int        `gorm:"type:int;not null;default:0;comment:版本号" json:"version"`
+}
+
+func (Shipment) TableName() string {
+	return "shipments"
+}
+
+// ShipmentEvent 物流轨迹表（同一物流单的同一时间、同一状态的轨迹只记录一次）
+type ShipmentEvent struct {
+	pkg.BaseModel
+
+	ShipmentID  string    `gorm:"type:varchar(26);not null;uniqueIndex:uk_shipment_event,priority:1;comment:物流单ID" json:"shipment_id"`
+	OrderNo     string    `gorm:"type:varchar(32);index;not null;comment:订单号" json:"order_no"`
+	EventTime   time.Time `gorm:"type:timestamp;not null;uniqueIndex:uk_shipment_event,priority:2;comment:轨迹时间" json:"event_time"`
+	Status      int8      `gorm:"type:tinyint;not null;uniqueIndex:uk_shipment_event,priority:3;comment:物流状态" json:"status"`
+	Location    string    `gorm:"type:varchar(128);comment:所在地" json:"location"`
+	Description string    `gorm:"type:varchar(255);comment:轨迹描述" json:"description"`
+}
+
+func (ShipmentEvent) TableName() string {
+	return "shipment_events"
+}
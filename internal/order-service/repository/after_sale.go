@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"zjMall/internal/order-service/model"
+
+	"gorm.io/gorm"
+)
+
+// AfterSaleFilter 售后单查询条件（零值表示不过滤）
+type AfterSaleFilter struct {
+	UserID  string
+	OrderNo string
+	Status  int8
+}
+
+// AfterSaleRepository 售后单仓储接口
+type AfterSaleRepository interface {
+	// CreateAfterSale 创建售后单
+	CreateAfterSale(ctx context.Context, afterSale *model.AfterSale) error
+	// GetAfterSaleByNo 根据售后单号查询售后单（不存在时返回 nil, nil）
+	GetAfterSaleByNo(ctx context.Context, afterSaleNo string) (*model.AfterSale, error)
+	// ListAfterSales 分页查询售后单（按申请时间倒序）
+	ListAfterSales(ctx context.Context, filter AfterSaleFilter, offset, limit int) ([]*model.AfterSale, int64, error)
+	// SumItemQuantity 统计订单明细下未被拒绝的售后单的累计售后数量
+	SumItemQuantity(ctx context.Context, orderItemID string) (int64, error)
+	// UpdateAfterSaleStatus 基于当前状态 + 乐观锁流转售后单状态，状态不在 fromStatuses 内或版本冲突时返回 gorm.ErrRecordNotFound
+	UpdateAfterSaleStatus(ctx context.Context, afterSaleNo string, fromStatuses []int8, toStatus int8, fields map[string]interface{}) error
+}
+
+type afterSaleRepository struct {
+	db *gorm.DB
+}
+
+func NewAfterSaleRepository(db *gorm.DB) AfterSaleRepository {
+	return &afterSaleRepository{db: db}
+}
+
+func (r *afterSaleRepository) CreateAfterSale(ctx context.Context, afterSale *model.AfterSale) error {
+	return r.db.WithContext(ctx).Create(afterSale).Error
+}
+
+func (r *afterSaleRepository) GetAfterSaleByNo(ctx context.Context, afterSaleNo string) (*model.AfterSale, error) {
+	var afterSale model.AfterSale
+	if err := r.db.WithContext(ctx).Where("after_sale_no = ?", afterSaleNo).First(&afterSale).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &afterSale, nil
+}
+
+func (r *afterSaleRepository) ListAfterSales(ctx context.Context, filter AfterSaleFilter, offset, limit int) ([]*model.AfterSale, int64, error) {
+	query := r.db.WithContext(ctx).Model(&model.AfterSale{})
+	if filter.UserID != "" {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.OrderNo != "" {
+		query = query.Where("order_no = ?", filter.OrderNo)
+	}
+	if filter.Status != 0 {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var afterSales []*model.AfterSale
+	if err := query.Order("created_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&afterSales).Error; err != nil {
+		return nil, 0, err
+	}
+	return afterSales, total, nil
+}
+
+func (r *afterSaleRepository) SumItemQuantity(ctx context.Context, orderItemID string) (int64, error) {
+	var total int64
+	if err := r.db.WithContext(ctx).
+		Model(&model.AfterSale{}).
+		Where("order_item_id = ? AND status <> ?", orderItemID, model.AfterSaleStatusRejected).
+		Select("COALESCE(SUM(quantity), 0)").
+		Scan(&total).Error; err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+func (r *afterSaleRepository) UpdateAfterSaleStatus(ctx context.Context, afterSaleNo string, fromStatuses []int8, toStatus int8, fields map[string]interface{}) error {
+	var afterSale model.AfterSale
+	if err := r.db.WithContext(ctx).
+		Where("after_sale_no = ? AND status IN ?", afterSaleNo, fromStatuses).
+		First(&afterSale).Error; err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{
+		"status":  toStatus,
+		"version": gorm.Expr("version + 1"),
+	}
+	for column, value := range fields {
+		updates[column] = value
+	}
+	result := r.db.WithContext(ctx).
+		Model(&model.AfterSale{}).
+		Where("after_sale_no = ? AND version = ?", afterSaleNo, afterSale.Version).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+	"zjMall/internal/order-service/model"
+
+	"gorm.io/gorm"
+)
+
+// ErrDiscountClaimReleased 订单优惠占用已被补偿任务归还，不能再创建使用该优惠的订单
+var ErrDiscountClaimReleased = errors.New("订单优惠已释放")
+
+// CreateDiscountClaim 写入订单优惠占用记录（待绑定）
+func (r *orderRepository) CreateDiscountClaim(ctx context.Context, claim *model.OrderDiscountClaim) error {
+	return r.db.WithContext(ctx).Create(claim).Error
+}
+
+// ListPendingDiscountClaims 查询创建时间早于 before 且仍待绑定的优惠占用记录
+func (r *orderRepository) ListPendingDiscountClaims(ctx context.Context, before time.Time, limit int) ([]*model.OrderDiscountClaim, error) {
+	var claims []*model.OrderDiscountClaim
+	if err := r.db.WithContext(ctx).
+		Where("status = ? AND created_at < ?", model.DiscountClaimStatusPending, before).
+		Order("created_at ASC").
+		Limit(limit).
+		Find(&claims).Error; err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// TransitDiscountClaim 使用乐观锁更新优惠占用状态，返回是否更新成功（false 表示状态已被并发修改）
+func (r *orderRepository) TransitDiscountClaim(ctx context.Context, orderNo string, fromStatus, toStatus int8) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&model.OrderDiscountClaim{}).
+		Where("order_no = ? AND status = ?", orderNo, fromStatus).
+		Update("status", toStatus)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// bindDiscountClaim 在创建订单的事务中绑定优惠占用记录
+// 没有占用记录（未使用优惠）时直接返回；占用已被补偿任务归还时返回 ErrDiscountClaimReleased，回滚订单
+func bindDiscountClaim(tx *gorm.DB, orderNo string) error {
+	result := tx.Model(&model.OrderDiscountClaim{}).
+		Where("order_no = ? AND status = ?", orderNo, model.DiscountClaimStatusPending).
+		Update("status", model.DiscountClaimStatusBound)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+
+	var count int64
+	if err := tx.Model(&model.OrderDiscountClaim{}).
+		Where("order_no = ? AND status = ?", orderNo, model.DiscountClaimStatusReleased).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrDiscountClaimReleased
+	}
+	return nil
+}
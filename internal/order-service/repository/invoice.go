@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"zjMall/internal/order-service/model"
+
+	"gorm.io/gorm"
+)
+
+// InvoiceFilter 发票查询条件（零值表示不过滤）
+type InvoiceFilter struct {
+	OrderNo string // 开票订单号或支付订单号
+	UserID  string
+	Status  int8
+}
+
+// InvoiceRepository 订单发票仓储接口
+// 发票随订单在订单仓储的下单事务中创建，这里只负责查询与状态流转
+type InvoiceRepository interface {
+	// GetInvoiceByID 根据ID查询发票（不存在时返回 nil, nil）
+	GetInvoiceByID(ctx context.Context, id string) (*model.OrderInvoice, error)
+	// ListInvoicesByOrderNos 查询订单的发票（按创建时间升序，包含已红冲、已作废的历史发票）
+	ListInvoicesByOrderNos(ctx context.Context, orderNos []string) ([]*model.OrderInvoice, error)
+	// ListInvoicesByPayOrderNo 查询支付订单下的发票（拆单时包含全部子订单的发票）
+	ListInvoicesByPayOrderNo(ctx context.Context, payOrderNo string) ([]*model.OrderInvoice, error)
+	// SearchInvoices 按 ID 倒序游标分页查询发票（afterID 为上一页最后一条发票的 ID，为空表示第一页）
+	SearchInvoices(ctx context.Context, filter InvoiceFilter, afterID string, limit int) ([]*model.OrderInvoice, error)
+	// UpdateInvoiceStatus 基于当前状态 + 乐观锁流转发票状态，状态不在 fromStatuses 内或版本冲突时返回 gorm.ErrRecordNotFound
+	UpdateInvoiceStatus(ctx context.Context, id string, fromStatuses []int8, toStatus int8, fields map[string]interface{}) error
+	// RedFlushInvoice 在一个事务中红冲已开具的发票，并按剩余金额生成新的发票记录（reissue 为空时不重开）
+	RedFlushInvoice(ctx context.Context, id string, fields map[string]interface{}, reissue *model.OrderInvoice) error
+}
+
+type invoiceRepository struct {
+	db *gorm.DB
+}
+
+func NewInvoiceRepository(db *gorm.DB) InvoiceRepository {
+	return &invoiceRepository{db: db}
+}
+
+func (r *invoiceRepository) GetInvoiceByID(ctx context.Context, id string) (*model.OrderInvoice, error) {
+	var invoice model.OrderInvoice
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&invoice).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+func (r *invoiceRepository) ListInvoicesByOrderNos(ctx context.Context, orderNos []string) ([]*model.OrderInvoice, error) {
+	var invoices []*model.OrderInvoice
+	if len(orderNos) == 0 {
+		return invoices, nil
+	}
+	if err := r.db.WithContext(ctx).
+		Where("order_no IN ?", orderNos).
+		Order("created_at ASC, id ASC").
+		Find(&invoices).Error; err != nil {
+		return nil, err
+	}
+	return invoices, nil
+}
+
+func (r *invoiceRepository) ListInvoicesByPayOrderNo(ctx context.Context, payOrderNo string) ([]*model.OrderInvoice, error) {
+	var invoices []*model.OrderInvoice
+	if err := r.db.WithContext(ctx).
+		Where("pay_order_no = ?", payOrderNo).
+		Order("created_at ASC, id ASC").
+		Find(&invoices).Error; err != nil {
+		return nil, err
+	}
+	return invoices, nil
+}
+
+func (r *invoiceRepository) SearchInvoices(ctx context.Context, filter InvoiceFilter, afterID string, limit int) ([]*model.OrderInvoice, error) {
+	query := r.db.WithContext(ctx).Model(&model.OrderInvoice{})
+	if filter.OrderNo != "" {
+		query = query.Where("order_no = ? OR pay_order_no = ?", filter.OrderNo, filter.OrderNo)
+	}
+	if filter.UserID != "" {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Status != 0 {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if afterID != "" {
+		query = query.Where("id < ?", afterID)
+	}
+
+	var invoices []*model.OrderInvoice
+	if err := query.Order("id DESC").Limit(limit).Find(&invoices).Error; err != nil {
+		return nil, err
+	}
+	return invoices, nil
+}
+
+func (r *invoiceRepository) UpdateInvoiceStatus(ctx context.Context, id string, fromStatuses []int8, toStatus int8, fields map[string]interface{}) error {
+	return updateInvoiceStatus(r.db.WithContext(ctx), id, fromStatuses, toStatus, fields)
+}
+
+func (r *invoiceRepository) RedFlushInvoice(ctx context.Context, id string, fields map[string]interface{}, reissue *model.OrderInvoice) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := updateInvoiceStatus(tx, id, []int8{model.InvoiceStatusIssued}, model.InvoiceStatusRedFlushed, fields); err != nil {
+			return err
+		}
+		if reissue != nil {
+			return tx.Create(reissue).Error
+		}
+		return nil
+	})
+}
+
+// updateInvoiceStatus 先按状态读取版本号，再以版本号为条件更新（与售后单状态流转一致）
+func updateInvoiceStatus(db *gorm.DB, id string, fromStatuses []int8, toStatus int8, fields map[string]interface{}) error {
+	var invoice model.OrderInvoice
+	if err := db.Where("id = ? AND status IN ?", id, fromStatuses).First(&invoice).Error; err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{
+		"status":  toStatus,
+		"version": gorm.Expr("version + 1"),
+	}
+	for column, value := range fields {
+		updates[column] = value
+	}
+	result := db.Model(&model.OrderInvoice{}).
+		Where("id = ? AND version = ?", id, invoice.Version).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -8,16 +8,46 @@ import (
 	"gorm.io/gorm"
 )
 
+// OrderSearchFilter 管理员订单搜索条件（零值表示不过滤）
+type OrderSearchFilter struct {
+	OrderNo       string
+	UserID        string
+	Status        int8
+	CreatedFrom   *time.Time // 下单时间起（含）
+	CreatedTo     *time.Time // 下单时间止（不含）
+	MinPayAmount  *float64
+	MaxPayAmount  *float64
+	ReceiverPhone string
+	SKUID         string // 包含该 SKU 的订单（按订单明细匹配，拆单时命中子订单）
+}
+
 // OrderRepository 订单仓储接口
 type OrderRepository interface {
-	CreateOrder(ctx context.Context, order *model.Order, items []*model.OrderItem) error
+	// CreateOrder 在一个事务中创建订单、明细及发票（invoices 可为空），并绑定订单优惠占用记录
+	CreateOrder(ctx context.Context, order *model.Order, items []*model.OrderItem, invoices []*model.OrderInvoice) error
+	// CreateSplitOrders 在一个事务中创建主订单、子订单、子订单明细及子订单发票（invoices 可为空），并绑定订单优惠占用记录
+	CreateSplitOrders(ctx context.Context, parent *model.Order, children []*model.Order, items []*model.OrderItem, invoices []*model.OrderInvoice) error
+	// GetChildOrders 查询主订单下的子订单及其明细
+	GetChildOrders(ctx context.Context, parentOrderNo string) ([]*model.Order, []*model.OrderItem, error)
 	GetOrderByNo(ctx context.Context, userID, orderNo string) (*model.Order, []*model.OrderItem, error)
 	GetOrderByNoNoUser(ctx context.Context, orderNo string) (*model.Order, []*model.OrderItem, error) // 不校验用户ID，用于支付回调等场景
 	ListUserOrders(ctx context.Context, userID string, status int8, offset, limit int) ([]*model.Order, int64, error)
-	UpdateOrderStatus(ctx context.Context, orderNo string, fromStatus, toStatus int8) error
-	UpdateOrderPaid(ctx context.Context, orderNo string, fromStatus, toStatus int8, payChannel, payTradeNo string, paidAt time.Time) error
-	// GetTimeoutOrders 查询超时的订单（待支付状态，创建时间超过指定时间）
-	GetTimeoutOrders(ctx context.Context, status int8, timeoutDuration time.Duration, limit int) ([]*model.Order, error)
+	// SearchOrders 跨用户搜索订单（按 ID 倒序游标分页，afterID 为上一页最后一条订单的 ID，为空表示第一页）
+	SearchOrders(ctx context.Context, filter OrderSearchFilter, afterID string, limit int) ([]*model.Order, error)
+	// TransitOrderStatus 使用乐观锁更新订单状态（同时更新 fields 中的字段，如支付信息、发货时间），并在同一事务中写入状态流转历史
+	TransitOrderStatus(ctx context.Context, orderNo string, fromStatus, toStatus int8, fields map[string]interface{}, history *model.OrderStatusHistory) error
+	// ListStatusHistory 查询订单状态流转历史（按流转先后排序）
+	ListStatusHistory(ctx context.Context, orderNo string) ([]*model.OrderStatusHistory, error)
+	// GetTimeoutOrders 查询已过支付截止时间的订单（未记录截止时间的历史订单按创建时间超过 legacyTimeout 判断）
+	GetTimeoutOrders(ctx context.Context, status int8, now time.Time, legacyTimeout time.Duration, limit int) ([]*model.Order, error)
+	// GetShippedOrdersBefore 查询发货时间早于 shippedBefore 且仍为已发货状态的订单（用于自动确认收货）
+	GetShippedOrdersBefore(ctx context.Context, status int8, shippedBefore time.Time, limit int) ([]*model.Order, error)
+	// CreateDiscountClaim 写入订单优惠占用记录（在向促销服务锁定优惠之前调用，创建订单时在同一事务中绑定）
+	CreateDiscountClaim(ctx context.Context, claim *model.OrderDiscountClaim) error
+	// ListPendingDiscountClaims 查询创建时间早于 before 且仍待绑定的优惠占用记录（用于补偿归还优惠）
+	ListPendingDiscountClaims(ctx context.Context, before time.Time, limit int) ([]*model.OrderDiscountClaim, error)
+	// TransitDiscountClaim 使用乐观锁更新优惠占用状态，返回是否更新成功
+	TransitDiscountClaim(ctx context.Context, orderNo string, fromStatus, toStatus int8) (bool, error)
 }
 
 type orderRepository struct {
@@ -28,21 +58,84 @@ func NewOrderRepository(db *gorm.DB) OrderRepository {
 	return &orderRepository{db: db}
 }
 
-// CreateOrder 在事务中创建订单主表和明细
-func (r *orderRepository) CreateOrder(ctx context.Context, order *model.Order, items []*model.OrderItem) error {
+// CreateOrder 在事务中创建订单主表、明细和发票
+func (r *orderRepository) CreateOrder(ctx context.Context, order *model.Order, items []*model.OrderItem, invoices []*model.OrderInvoice) error {
 	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		if err := tx.Create(order).Error; err != nil {
 			return err
 		}
+		if err := bindDiscountClaim(tx, order.OrderNo); err != nil {
+			return err
+		}
 		if len(items) > 0 {
 			if err := tx.Create(&items).Error; err != nil {
 				return err
 			}
 		}
+		if len(invoices) > 0 {
+			if err := tx.Create(&invoices).Error; err != nil {
+				return err
+			}
+		}
 		return nil
 	})
 }
 
+// CreateSplitOrders 在事务中创建主订单、子订单、子订单明细和发票
+func (r *orderRepository) CreateSplitOrders(ctx context.Context, parent *model.Order, children []*model.Order, items []*model.OrderItem, invoices []*model.OrderInvoice) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(parent).Error; err != nil {
+			return err
+		}
+		if err := bindDiscountClaim(tx, parent.OrderNo); err != nil {
+			return err
+		}
+		if len(children) > 0 {
+			if err := tx.Create(&children).Error; err != nil {
+				return err
+			}
+		}
+		if len(items) > 0 {
+			if err := tx.Create(&items).Error; err != nil {
+				return err
+			}
+		}
+		if len(invoices) > 0 {
+			if err := tx.Create(&invoices).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetChildOrders 查询主订单下的子订单及其明细（按子订单号排序）
+func (r *orderRepository) GetChildOrders(ctx context.Context, parentOrderNo string) ([]*model.Order, []*model.OrderItem, error) {
+	var orders []*model.Order
+	if err := r.db.WithContext(ctx).
+		Where("parent_order_no = ?", parentOrderNo).
+		Order("order_no ASC").
+		Find(&orders).Error; err != nil {
+		return nil, nil, err
+	}
+	if len(orders) == 0 {
+		return nil, nil, nil
+	}
+
+	orderNos := make([]string, 0, len(orders))
+	for _, o := range orders {
+		orderNos = append(orderNos, o.OrderNo)
+	}
+	var items []*model.OrderItem
+	if err := r.db.WithContext(ctx).
+		Where("order_no IN ?", orderNos).
+		Order("order_no ASC").
+		Find(&items).Error; err != nil {
+		return nil, nil, err
+	}
+	return orders, items, nil
+}
+
 func (r *orderRepository) GetOrderByNo(ctx context.Context, userID, orderNo string) (*model.Order, []*model.OrderItem, error) {
 	var order model.Order
 	if err := r.db.WithContext(ctx).
@@ -82,7 +175,8 @@ func (r *orderRepository) GetOrderByNoNoUser(ctx context.Context, orderNo string
 
 func (r *orderRepository) ListUserOrders(ctx context.Context, userID string, status int8, offset, limit int) ([]*model.Order, int64, error) {
 	var orders []*model.Order
-	tx := r.db.WithContext(ctx).Model(&model.Order{}).Where("user_id = ?", userID)
+	// 子订单随主订单展示（订单详情中返回），列表中不重复出现
+	tx := r.db.WithContext(ctx).Model(&model.Order{}).Where("user_id = ? AND split_type <> ?", userID, model.OrderSplitTypeChild)
 	if status > 0 {
 		tx = tx.Where("status = ?", status)
 	}
@@ -96,42 +190,105 @@ func (r *orderRepository) ListUserOrders(ctx context.Context, userID string, sta
 	return orders, total, nil
 }
 
-func (r *orderRepository) UpdateOrderStatus(ctx context.Context, orderNo string, fromStatus, toStatus int8) error {
-	// 先查询订单获取当前version
-	var order model.Order
-	if err := r.db.WithContext(ctx).
-		Where("order_no = ? AND status = ?", orderNo, fromStatus).
-		First(&order).Error; err != nil {
-		return err
+// SearchOrders 跨用户搜索订单
+// 订单 ID 为 ULID（按创建时间递增），按 ID 倒序即按下单时间倒序，游标翻页不受新订单写入影响
+func (r *orderRepository) SearchOrders(ctx context.Context, filter OrderSearchFilter, afterID string, limit int) ([]*model.Order, error) {
+	query := r.db.WithContext(ctx).Model(&model.Order{})
+	if filter.OrderNo != "" {
+		query = query.Where("order_no = ?", filter.OrderNo)
+	}
+	if filter.UserID != "" {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Status != 0 {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.CreatedFrom != nil {
+		query = query.Where("created_at >= ?", *filter.CreatedFrom)
 	}
+	if filter.CreatedTo != nil {
+		query = query.Where("created_at < ?", *filter.CreatedTo)
+	}
+	if filter.MinPayAmount != nil {
+		query = query.Where("pay_amount >= ?", *filter.MinPayAmount)
+	}
+	if filter.MaxPayAmount != nil {
+		query = query.Where("pay_amount <= ?", *filter.MaxPayAmount)
+	}
+	if filter.ReceiverPhone != "" {
+		query = query.Where("receiver_phone = ?", filter.ReceiverPhone)
+	}
+	if filter.SKUID != "" {
+		query = query.Where("order_no IN (?)", r.db.Model(&model.OrderItem{}).Select("order_no").Where("sku_id = ?", filter.SKUID))
+	}
+	if afterID != "" {
+		query = query.Where("id < ?", afterID)
+	}
+
+	var orders []*model.Order
+	if err := query.Order("id DESC").Limit(limit).Find(&orders).Error; err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
 
-	// 使用乐观锁更新：WHERE条件包含version，更新时version+1
-	result := r.db.WithContext(ctx).
-		Model(&model.Order{}).
-		Where("order_no = ? AND status = ? AND version = ?", orderNo, fromStatus, order.Version).
-		Updates(map[string]interface{}{
+// TransitOrderStatus 在事务中使用乐观锁更新订单状态并写入状态流转历史
+func (r *orderRepository) TransitOrderStatus(ctx context.Context, orderNo string, fromStatus, toStatus int8, fields map[string]interface{}, history *model.OrderStatusHistory) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 先查询订单获取当前version
+		var order model.Order
+		if err := tx.Where("order_no = ? AND status = ?", orderNo, fromStatus).
+			First(&order).Error; err != nil {
+			return err
+		}
+
+		// 使用乐观锁更新：WHERE条件包含version，更新时version+1
+		updates := map[string]interface{}{
 			"status":  toStatus,
 			"version": gorm.Expr("version + 1"),
-		})
+		}
+		for column, value := range fields {
+			updates[column] = value
+		}
+		result := tx.Model(&model.Order{}).
+			Where("order_no = ? AND status = ? AND version = ?", orderNo, fromStatus, order.Version).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
 
-	if result.Error != nil {
-		return result.Error
-	}
+		// 检查是否更新成功（RowsAffected=0表示version不匹配，可能是并发修改）
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound // 或者返回自定义错误：订单已被其他请求修改
+		}
 
-	// 检查是否更新成功（RowsAffected=0表示version不匹配，可能是并发修改）
-	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound // 或者返回自定义错误：订单已被其他请求修改
-	}
+		if history == nil {
+			return nil
+		}
+		return tx.Create(history).Error
+	})
+}
 
-	return nil
+// ListStatusHistory 查询订单状态流转历史
+func (r *orderRepository) ListStatusHistory(ctx context.Context, orderNo string) ([]*model.OrderStatusHistory, error) {
+	var history []*model.OrderStatusHistory
+	err := r.db.WithContext(ctx).
+		Where("order_no = ?", orderNo).
+		Order("created_at ASC, id ASC").
+		Find(&history).Error
+	if err != nil {
+		return nil, err
+	}
+	return history, nil
 }
 
-// GetTimeoutOrders 查询超时的订单（待支付状态，创建时间超过指定时间）
-func (r *orderRepository) GetTimeoutOrders(ctx context.Context, status int8, timeoutDuration time.Duration, limit int) ([]*model.Order, error) {
+// GetTimeoutOrders 查询已过支付截止时间的订单
+// 子订单随主订单一起关闭，不单独返回
+func (r *orderRepository) GetTimeoutOrders(ctx context.Context, status int8, now time.Time, legacyTimeout time.Duration, limit int) ([]*model.Order, error) {
 	var orders []*model.Order
-	timeoutTime := time.Now().Add(-timeoutDuration)
 	err := r.db.WithContext(ctx).
-		Where("status = ? AND created_at < ?", status, timeoutTime).
+		Where("status = ? AND split_type <> ?", status, model.OrderSplitTypeChild).
+		Where("pay_deadline < ? OR (pay_deadline IS NULL AND created_at < ?)", now, now.Add(-legacyTimeout)).
 		Order("created_at ASC").
 		Limit(limit).
 		Find(&orders).Error
@@ -141,38 +298,16 @@ func (r *orderRepository) GetTimeoutOrders(ctx context.Context, status int8, tim
 	return orders, nil
 }
 
-// UpdateOrderPaid 更新订单支付信息和状态（使用乐观锁）
-func (r *orderRepository) UpdateOrderPaid(ctx context.Context, orderNo string, fromStatus, toStatus int8, payChannel, payTradeNo string, paidAt time.Time) error {
-	// 先查询订单获取当前version
-	var order model.Order
-	if err := r.db.WithContext(ctx).
-		Where("order_no = ? AND status = ?", orderNo, fromStatus).
-		First(&order).Error; err != nil {
-		return err
-	}
-
-	// 使用乐观锁更新：WHERE条件包含version，更新时version+1
-	// 将 paidAt 转换为指针类型（因为模型中使用的是 *time.Time）
-	paidAtPtr := &paidAt
-	result := r.db.WithContext(ctx).
-		Model(&model.Order{}).
-		Where("order_no = ? AND status = ? AND version = ?", orderNo, fromStatus, order.Version).
-		Updates(map[string]interface{}{
-			"status":       toStatus,
-			"pay_channel":  payChannel,
-			"pay_trade_no": payTradeNo,
-			"paid_at":      paidAtPtr,
-			"version":      gorm.Expr("version + 1"),
-		})
-
-	if result.Error != nil {
-		return result.Error
-	}
-
-	// 检查是否更新成功（RowsAffected=0表示version不匹配，可能是并发修改）
-	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound // 或者返回自定义错误：订单已被其他请求修改
+// GetShippedOrdersBefore 查询发货时间早于 shippedBefore 的订单
+func (r *orderRepository) GetShippedOrdersBefore(ctx context.Context, status int8, shippedBefore time.Time, limit int) ([]*model.Order, error) {
+	var orders []*model.Order
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND shipped_at < ?", status, shippedBefore).
+		Order("shipped_at ASC").
+		Limit(limit).
+		Find(&orders).Error
+	if err != nil {
+		return nil, err
 	}
-
-	return nil
+	return orders, nil
 }
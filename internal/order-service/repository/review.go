@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"zjMall/internal/order-service/model"
+
+	"gorm.io/gorm"
+)
+
+// ErrReviewExists 订单明细已评价（order_item_id 唯一索引冲突）
+var ErrReviewExists = errors.New("该商品已评价")
+
+// ReviewFilter 评价查询条件（零值表示不过滤）
+type ReviewFilter struct {
+	ProductID string
+	OrderNo   string
+	Status    int8
+	Rating    int8
+}
+
+// ReviewRepository 商品评价仓储接口
+type ReviewRepository interface {
+	// CreateReview 创建评价，订单明细已评价时返回 ErrReviewExists
+	CreateReview(ctx context.Context, review *model.ProductReview) error
+	// GetReviewByID 根据ID查询评价（不存在时返回 nil, nil）
+	GetReviewByID(ctx context.Context, id string) (*model.ProductReview, error)
+	// SearchReviews 按 ID 倒序游标分页查询评价（afterID 为上一页最后一条评价的 ID，为空表示第一页）
+	SearchReviews(ctx context.Context, filter ReviewFilter, afterID string, limit int) ([]*model.ProductReview, error)
+	// UpdateReviewStatus 基于当前状态 + 乐观锁流转审核状态，状态不在 fromStatuses 内或版本冲突时返回 gorm.ErrRecordNotFound
+	UpdateReviewStatus(ctx context.Context, id string, fromStatuses []int8, toStatus int8, fields map[string]interface{}) error
+	// SaveReply 保存商家回复（每条评价只能回复一次），已回复时返回 gorm.ErrRecordNotFound
+	SaveReply(ctx context.Context, id, reply, replierID string, repliedAt time.Time) error
+	// CountApprovedRatings 统计商品审核通过的评价在各星级的数量，下标 0~4 依次为 1~5 星
+	CountApprovedRatings(ctx context.Context, productID string) ([]int64, error)
+}
+
+type reviewRepository struct {
+	db *gorm.DB
+}
+
+func NewReviewRepository(db *gorm.DB) ReviewRepository {
+	return &reviewRepository{db: db}
+}
+
+func (r *reviewRepository) CreateReview(ctx context.Context, review *model.ProductReview) error {
+	if err := r.db.WithContext(ctx).Create(review).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) ||
+			strings.Contains(err.Error(), "Duplicate entry") {
+			return ErrReviewExists
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *reviewRepository) GetReviewByID(ctx context.Context, id string) (*model.ProductReview, error) {
+	var review model.ProductReview
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&review).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &review, nil
+}
+
+func (r *reviewRepository) SearchReviews(ctx context.Context, filter ReviewFilter, afterID string, limit int) ([]*model.ProductReview, error) {
+	query := r.db.WithContext(ctx).Model(&model.ProductReview{})
+	if filter.ProductID != "" {
+		query = query.Where("product_id = ?", filter.ProductID)
+	}
+	if filter.OrderNo != "" {
+		query = query.Where("order_no = ?", filter.OrderNo)
+	}
+	if filter.Status != 0 {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Rating != 0 {
+		query = query.Where("rating = ?", filter.Rating)
+	}
+	if afterID != "" {
+		query = query.Where("id < ?", afterID)
+	}
+
+	var reviews []*model.ProductReview
+	if err := query.Order("id DESC").Limit(limit).Find(&reviews).Error; err != nil {
+		return nil, err
+	}
+	return reviews, nil
+}
+
+func (r *reviewRepository) UpdateReviewStatus(ctx context.Context, id string, fromStatuses []int8, toStatus int8, fields map[string]interface{}) error {
+	db := r.db.WithContext(ctx)
+	var review model.ProductReview
+	if err := db.Where("id = ? AND status IN ?", id, fromStatuses).First(&review).Error; err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{
+		"status":  toStatus,
+		"version": gorm.Expr("version + 1"),
+	}
+	for column, value := range fields {
+		updates[column] = value
+	}
+	result := db.Model(&model.ProductReview{}).
+		Where("id = ? AND version = ?", id, review.Version).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *reviewRepository) SaveReply(ctx context.Context, id, reply, replierID string, repliedAt time.Time) error {
+	result := r.db.WithContext(ctx).Model(&model.ProductReview{}).
+		Where("id = ? AND replied_at IS NULL", id).
+		Updates(map[string]interface{}{
+			"reply":      reply,
+			"replier_id": replierID,
+			"replied_at": &repliedAt,
+			"version":    gorm.Expr("version + 1"),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *reviewRepository) CountApprovedRatings(ctx context.Context, productID string) ([]int64, error) {
+	var rows []struct {
+		Rating int8
+		Total  int64
+	}
+	if err := r.db.WithContext(ctx).Model(&model.ProductReview{}).
+		Select("rating, COUNT(*) AS total").
+		Where("product_id = ? AND status = ?", productID, model.ReviewStatusApproved).
+		Group("rating").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	counts := make([]int64, 5)
+	for _, row := range rows {
+		if row.Rating >= 1 && row.Rating <= 5 {
+			counts[row.Rating-1] = row.Total
+		}
+	}
+	return counts, nil
+}
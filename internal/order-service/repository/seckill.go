@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+	"zjMall/internal/order-service/model"
+
+	"gorm.io/gorm"
+)
+
+// SeckillRepository 秒杀活动仓储接口
+type SeckillRepository interface {
+	// CreateActivity 创建秒杀活动
+	CreateActivity(ctx context.Context, activity *model.SeckillActivity) error
+	// GetActivityByNo 根据活动编号查询秒杀活动（不存在时返回 nil, nil）
+	GetActivityByNo(ctx context.Context, activityNo string) (*model.SeckillActivity, error)
+	// ListEndedActivities 查询结束时间早于 endedBefore 且尚未对账的活动（用于库存对账）
+	ListEndedActivities(ctx context.Context, endedBefore time.Time, limit int) ([]*model.SeckillActivity, error)
+	// MarkActivitySettled 将已上线的活动标记为已对账并记录已售数量，活动已对账时返回 gorm.ErrRecordNotFound
+	MarkActivitySettled(ctx context.Context, activityNo string, soldCount int32, settledAt time.Time) error
+}
+
+type seckillRepository struct {
+	db *gorm.DB
+}
+
+func NewSeckillRepository(db *gorm.DB) SeckillRepository {
+	return &seckillRepository{db: db}
+}
+
+func (r *seckillRepository) CreateActivity(ctx context.Context, activity *model.SeckillActivity) error {
+	return r.db.WithContext(ctx).Create(activity).Error
+}
+
+func (r *seckillRepository) GetActivityByNo(ctx context.Context, activityNo string) (*model.SeckillActivity, error) {
+	var activity model.SeckillActivity
+	if err := r.db.WithContext(ctx).Where("activity_no = ?", activityNo).First(&activity).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &activity, nil
+}
+
+func (r *seckillRepository) ListEndedActivities(ctx context.Context, endedBefore time.Time, limit int) ([]*model.SeckillActivity, error) {
+	var activities []*model.SeckillActivity
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND end_time < ?", model.SeckillActivityStatusOnline, endedBefore).
+		Order("end_time ASC").
+		Limit(limit).
+		Find(&activities).Error
+	if err != nil {
+		return nil, err
+	}
+	return activities, nil
+}
+
+func (r *seckillRepository) MarkActivitySettled(ctx context.Context, activityNo string, soldCount int32, settledAt time.Time) error {
+	result := r.db.WithContext(ctx).
+		Model(&model.SeckillActivity{}).
+		Where("activity_no = ? AND status = ?", activityNo, model.SeckillActivityStatusOnline).
+		Updates(map[string]interface{}{
+			"status":     model.SeckillActivityStatusSettled,
+			"sold_count": soldCount,
+			"settled_at": &settledAt,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
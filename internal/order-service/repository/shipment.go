@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"zjMall/internal/order-service/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ShipmentRepository 物流单仓储接口
+type ShipmentRepository interface {
+	// CreateShipment 创建物流单，订单已存在物流单时不重复创建（返回是否新建）
+	CreateShipment(ctx context.Context, shipment *model.Shipment) (bool, error)
+	// GetShipmentByOrderNo 根据订单号查询物流单（不存在时返回 nil, nil）
+	GetShipmentByOrderNo(ctx context.Context, orderNo string) (*model.Shipment, error)
+	// GetShipmentByTrackingNo 根据物流公司与物流单号查询物流单（不存在时返回 nil, nil）
+	GetShipmentByTrackingNo(ctx context.Context, carrier, trackingNo string) (*model.Shipment, error)
+	// UpdateShipment 更新物流单（使用乐观锁，版本冲突时返回 gorm.ErrRecordNotFound）
+	UpdateShipment(ctx context.Context, shipment *model.Shipment) error
+	// AddShipmentEvents 写入物流轨迹，已存在的轨迹自动忽略，返回新增条数
+	AddShipmentEvents(ctx context.Context, events []*model.ShipmentEvent) (int64, error)
+	// ListShipmentEvents 查询物流单轨迹（按时间升序）
+	ListShipmentEvents(ctx context.Context, shipmentID string) ([]*model.ShipmentEvent, error)
+}
+
+type shipmentRepository struct {
+	db *gorm.DB
+}
+
+func NewShipmentRepository(db *gorm.DB) ShipmentRepository {
+	return &shipmentRepository{db: db}
+}
+
+func (r *shipmentRepository) CreateShipment(ctx context.Context, shipment *model.Shipment) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(shipment)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *shipmentRepository) GetShipmentByOrderNo(ctx context.Context, orderNo string) (*model.Shipment, error) {
+	var shipment model.Shipment
+	if err := r.db.WithContext(ctx).Where("order_no = ?", orderNo).First(&shipment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &shipment, nil
+}
+
+func (r *shipmentRepository) GetShipmentByTrackingNo(ctx context.Context, carrier, trackingNo string) (*model.Shipment, error) {
+	var shipment model.Shipment
+	if err := r.db.WithContext(ctx).
+		Where("carrier = ? AND tracking_no = ?", carrier, trackingNo).
+		First(&shipment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &shipment, nil
+}
+
+func (r *shipmentRepository) UpdateShipment(ctx context.Context, shipment *model.Shipment) error {
+	result := r.db.WithContext(ctx).
+		Model(&model.Shipment{}).
+		Where("id = ? AND version = ?", shipment.ID, shipment.Version).
+		Updates(map[string]interface{}{
+			"status":        shipment.Status,
+			"last_event_at": shipment.LastEventAt,
+			"synced_at":     shipment.SyncedAt,
+			"delivered_at":  shipment.DeliveredAt,
+			"version":       gorm.Expr("version + 1"),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	shipment.Version++
+	return nil
+}
+
+func (r *shipmentRepository) AddShipmentEvents(ctx context.Context, events []*model.ShipmentEvent) (int64, error) {
+	if len(events) == 0 {
+		return 0, nil
+	}
+	result := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&events)
+	return result.RowsAffected, result.Error
+}
+
+func (r *shipmentRepository) ListShipmentEvents(ctx context.Context, shipmentID string) ([]*model.ShipmentEvent, error) {
+	var events []*model.ShipmentEvent
+	if err := r.db.WithContext(ctx).
+		Where("shipment_id = ?", shipmentID).
+		Order("event_time ASC").
+		Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
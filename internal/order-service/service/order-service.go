@@ -11,12 +11,16 @@ import (
 	"time"
 	inventoryv1 "zjMall/gen/go/api/proto/inventory"
 	orderv1 "zjMall/gen/go/api/proto/order"
+	promotionv1 "zjMall/gen/go/api/proto/promotion"
+	userv1 "zjMall/gen/go/api/proto/user"
 	"zjMall/internal/common/client"
 	"zjMall/internal/common/lock"
 	"zjMall/internal/common/middleware"
 	"zjMall/internal/common/mq"
+	"zjMall/internal/order-service/carrier"
 	"zjMall/internal/order-service/model"
 	"zjMall/internal/order-service/repository"
+	"zjMall/internal/order-service/statemachine"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
@@ -44,27 +48,66 @@ const (
 
 // OrderService 订单服务（业务逻辑层）
 type OrderService struct {
-	orderRepo         repository.OrderRepository
-	productClient     client.ProductClient
-	inventoryClient   client.InventoryClient
-	userClient        client.UserClient
-	cartClient        client.CartClient
-	redisClient       *redis.Client
-	delayedProducer   mq.MessageProducer // 延迟消息生产者
-	orderTimeoutDelay time.Duration      // 订单超时时间
+	orderRepo       repository.OrderRepository
+	productClient   client.ProductClient
+	inventoryClient client.InventoryClient
+	userClient      client.UserClient
+	cartClient      client.CartClient
+	promotionClient client.PromotionClient // 促销服务客户端（可为空，为空时不计算优惠）
+	redisClient     *redis.Client
+	delayedProducer mq.MessageProducer       // 延迟消息生产者
+	payTimeouts     map[string]time.Duration // 按订单类型（model.OrderType*）的支付时限
+
+	orderAutoCompleteDelay time.Duration // 发货后自动确认收货时间
+
+	shipmentRepo repository.ShipmentRepository
+	carriers     *carrier.Registry // 物流公司适配器注册表
+
+	afterSaleRepo repository.AfterSaleRepository
+	paymentClient client.PaymentClient // 支付服务客户端（可为空，为空时售后无法发起退款）
+
+	seckillRepo     repository.SeckillRepository
+	seckillProducer mq.MessageProducer // 秒杀异步下单消息生产者（可为空，为空时不能参与秒杀）
+
+	invoiceRepo repository.InvoiceRepository
+
+	reviewRepo repository.ReviewRepository
+
+	stateMachine *statemachine.Machine // 订单状态机，所有订单状态变更都经由状态机并记录流转历史
 }
 
-func NewOrderService(orderRepo repository.OrderRepository, productClient client.ProductClient, inventoryClient client.InventoryClient, userClient client.UserClient, cartClient client.CartClient, redisClient *redis.Client, delayedProducer mq.MessageProducer) *OrderService {
-	return &OrderService{
-		orderRepo:         orderRepo,
-		productClient:     productClient,
-		inventoryClient:   inventoryClient,
-		userClient:        userClient,
-		cartClient:        cartClient,
-		redisClient:       redisClient,
-		delayedProducer:   delayedProducer,
-		orderTimeoutDelay: 30 * time.Minute, // 默认30分钟超时
+func NewOrderService(orderRepo repository.OrderRepository, productClient client.ProductClient, inventoryClient client.InventoryClient, userClient client.UserClient, cartClient client.CartClient, promotionClient client.PromotionClient, redisClient *redis.Client, delayedProducer mq.MessageProducer, autoCompleteDelay time.Duration, payTimeouts map[string]time.Duration, shipmentRepo repository.ShipmentRepository, carriers *carrier.Registry, afterSaleRepo repository.AfterSaleRepository, paymentClient client.PaymentClient, seckillRepo repository.SeckillRepository, seckillProducer mq.MessageProducer, invoiceRepo repository.InvoiceRepository, reviewRepo repository.ReviewRepository) *OrderService {
+	if autoCompleteDelay <= 0 {
+		autoCompleteDelay = DefaultOrderAutoCompleteDelay
+	}
+	s := &OrderService{
+		orderRepo:       orderRepo,
+		productClient:   productClient,
+		inventoryClient: inventoryClient,
+		userClient:      userClient,
+		cartClient:      cartClient,
+		promotionClient: promotionClient,
+		redisClient:     redisClient,
+		delayedProducer: delayedProducer,
+		payTimeouts:     normalizePayTimeouts(payTimeouts),
+
+		orderAutoCompleteDelay: autoCompleteDelay,
+
+		shipmentRepo: shipmentRepo,
+		carriers:     carriers,
+
+		afterSaleRepo: afterSaleRepo,
+		paymentClient: paymentClient,
+
+		seckillRepo:     seckillRepo,
+		seckillProducer: seckillProducer,
+
+		invoiceRepo: invoiceRepo,
+
+		reviewRepo: reviewRepo,
 	}
+	s.stateMachine = newOrderStateMachine(s)
+	return s
 }
 
 // 防止重复生成订单，前端提交token，后端消费并删除，然后获取分布式锁
@@ -195,6 +238,7 @@ func (s *OrderService) CreateOrder(ctx context.Context, req *orderv1.CreateOrder
 		productImage string
 		skuName      string
 		price        float64
+		merchantID   string
 	}
 
 	itemSnapshots := make(map[string]*itemSnapshot) // key: skuId
@@ -208,7 +252,7 @@ func (s *OrderService) CreateOrder(ctx context.Context, req *orderv1.CreateOrder
 				Message: fmt.Sprintf("商品%s不存在或SKU不存在", item.ProductId),
 			}, nil
 		}
-		// 注意：不再提前检查库存，因为 DeductStock 会使用乐观锁和 WHERE 条件检查库存
+		// 注意：不再提前检查库存，因为 ReserveStock 会使用乐观锁和 WHERE 条件检查库存
 		// 这样可以避免时间窗口问题，并且减少一次网络调用
 		// 查找对应的SKU并保存快照信息
 		found := false
@@ -220,6 +264,7 @@ func (s *OrderService) CreateOrder(ctx context.Context, req *orderv1.CreateOrder
 					productImage: product.MainImage,
 					skuName:      sku.Name,
 					price:        sku.Price,
+					merchantID:   product.MerchantId,
 				}
 				found = true
 				break
@@ -233,12 +278,23 @@ func (s *OrderService) CreateOrder(ctx context.Context, req *orderv1.CreateOrder
 		}
 	}
 
-	// 计算订单金额
-	discountAmount := 0.0 // TODO:待完成优惠计算
-	shippingAmount := 0.0 // TODO:需要根据订单类型和收货地址计算运费
-	payAmount := totalAmount - discountAmount + shippingAmount
-	if payAmount < 0 {
-		payAmount = 0
+	// 计算订单优惠（促销活动 + 优惠券），优惠按商品行分摊
+	discountLines := make([]*promotionv1.CartItem, 0, len(req.Items))
+	for _, it := range req.Items {
+		discountLines = append(discountLines, &promotionv1.CartItem{
+			ProductId: it.ProductId,
+			SkuId:     it.SkuId,
+			Quantity:  it.Quantity,
+			Price:     itemSnapshots[it.SkuId].price,
+		})
+	}
+	discount, err := s.calculateOrderDiscount(ctx, userID, req.CouponId, req.PromotionIds, discountLines)
+	if err != nil {
+		log.Printf("❌ [OrderService] CreateOrder: 计算优惠失败: %v", err)
+		return &orderv1.CreateOrderResponse{
+			Code:    1,
+			Message: fmt.Sprintf("优惠计算失败: %v", err),
+		}, nil
 	}
 
 	// 获取用户地址
@@ -251,12 +307,58 @@ func (s *OrderService) CreateOrder(ctx context.Context, req *orderv1.CreateOrder
 	}
 	receiverAddress := fmt.Sprintf("%s%s%s%s", userAddress.Province, userAddress.City, userAddress.District, userAddress.Detail)
 
+	// 获取发票抬头（可选），下单时保存抬头快照
+	var invoiceTitle *userv1.InvoiceTitle
+	if req.InvoiceTitleId != "" {
+		invoiceTitle, err = s.userClient.GetInvoiceTitle(ctx, req.InvoiceTitleId)
+		if err != nil {
+			return &orderv1.CreateOrderResponse{
+				Code:    1,
+				Message: fmt.Sprintf("获取发票抬头失败: %v", err),
+			}, nil
+		}
+	}
+
+	// 按商家分组计算运费（与购物车结算预览共用同一分组与运费模板计算，保证报价与实收一致）
+	// 多个商家的商品拆分为子订单，由主订单统一支付，子订单各自计算运费、独立发货
+	freightLines := make([]client.FreightLine, 0, len(req.Items))
+	for _, it := range req.Items {
+		snapshot := itemSnapshots[it.SkuId]
+		freightLines = append(freightLines, client.FreightLine{
+			MerchantID: snapshot.merchantID,
+			ProductID:  it.ProductId,
+			SKUID:      it.SkuId,
+			Quantity:   it.Quantity,
+		})
+	}
+	groups, shippingAmount, err := client.CalculateMerchantFreight(ctx, s.productClient, userAddress.Province, userAddress.City, freightLines)
+	if err != nil {
+		log.Printf("❌ [OrderService] CreateOrder: 计算运费失败: %v", err)
+		return &orderv1.CreateOrderResponse{
+			Code:    1,
+			Message: fmt.Sprintf("运费计算失败: %v", err),
+		}, nil
+	}
+	if len(groups) > maxSubOrders {
+		return &orderv1.CreateOrderResponse{
+			Code:    1,
+			Message: fmt.Sprintf("订单商家数量过多，最多支持%d个商家", maxSubOrders),
+		}, nil
+	}
+
+	// 计算订单金额
+	discountAmount := discount.TotalDiscount
+	payAmount := totalAmount - discountAmount + shippingAmount
+	if payAmount < 0 {
+		payAmount = 0
+	}
+
 	// 创建订单明细（填充商品快照信息）
 	var items []*model.OrderItem
-	var deductItems []*inventoryv1.SkuQuantity // 用于库存扣减
+	var deductItems []*inventoryv1.SkuQuantity // 用于库存锁定
 	var itemsSnapshotList []ItemBasicSnapshot  // 用于生成订单表的精简快照
 
-	for _, it := range req.Items {
+	for i, it := range req.Items {
 		snapshot := itemSnapshots[it.SkuId]
 		if snapshot == nil {
 			return &orderv1.CreateOrderResponse{
@@ -266,7 +368,9 @@ func (s *OrderService) CreateOrder(ctx context.Context, req *orderv1.CreateOrder
 		}
 
 		// 生成商品详细快照（JSON格式，保存到order_item表）
-		itemSnapshotJSON, err := s.generateItemDetailSnapshot(it, snapshot.productTitle, snapshot.productImage, snapshot.skuName, snapshot.price, receiverAddress)
+		lineDiscount := discount.lineDiscount(i)
+		subtotal := float64(it.Quantity)*snapshot.price - lineDiscount
+		itemSnapshotJSON, err := s.generateItemDetailSnapshot(it, snapshot.productTitle, snapshot.productImage, snapshot.skuName, snapshot.price, lineDiscount, receiverAddress)
 		if err != nil {
 			log.Printf("⚠️ [OrderService] CreateOrder: 生成商品快照失败: %v", err)
 			// 快照生成失败不影响主流程，继续创建订单
@@ -274,29 +378,31 @@ func (s *OrderService) CreateOrder(ctx context.Context, req *orderv1.CreateOrder
 		}
 
 		item := &model.OrderItem{
-			OrderNo:      orderNo,
-			UserID:       userID,
-			ProductID:    it.ProductId,
-			SKUID:        it.SkuId,
-			ProductTitle: snapshot.productTitle,
-			ProductImage: snapshot.productImage,
-			SKUName:      snapshot.skuName,
-			Price:        snapshot.price,
-			Quantity:     it.Quantity,
-			Subtotal:     float64(it.Quantity) * snapshot.price,
-			ItemSnapshot: itemSnapshotJSON, // 商品详细快照
+			OrderNo:        orderNo,
+			UserID:         userID,
+			ProductID:      it.ProductId,
+			SKUID:          it.SkuId,
+			ProductTitle:   snapshot.productTitle,
+			ProductImage:   snapshot.productImage,
+			SKUName:        snapshot.skuName,
+			Price:          snapshot.price,
+			Quantity:       it.Quantity,
+			DiscountAmount: lineDiscount,     // 分摊优惠
+			Subtotal:       subtotal,         // 扣除分摊优惠后的小计
+			ItemSnapshot:   itemSnapshotJSON, // 商品详细快照
 		}
 		items = append(items, item)
 
 		// 收集精简快照信息（用于订单表）
 		itemsSnapshotList = append(itemsSnapshotList, ItemBasicSnapshot{
-			ProductID:    it.ProductId,
-			SKUID:        it.SkuId,
-			ProductTitle: snapshot.productTitle,
-			SKUName:      snapshot.skuName,
-			Price:        fmt.Sprintf("%.2f", snapshot.price),
-			Quantity:     it.Quantity,
-			Subtotal:     fmt.Sprintf("%.2f", float64(it.Quantity)*snapshot.price),
+			ProductID:      it.ProductId,
+			SKUID:          it.SkuId,
+			ProductTitle:   snapshot.productTitle,
+			SKUName:        snapshot.skuName,
+			Price:          fmt.Sprintf("%.2f", snapshot.price),
+			Quantity:       it.Quantity,
+			DiscountAmount: fmt.Sprintf("%.2f", lineDiscount),
+			Subtotal:       fmt.Sprintf("%.2f", subtotal),
 		})
 
 		deductItems = append(deductItems, &inventoryv1.SkuQuantity{
@@ -313,6 +419,7 @@ func (s *OrderService) CreateOrder(ctx context.Context, req *orderv1.CreateOrder
 		itemsSnapshotJSON = ""
 	}
 
+	payDeadline := s.payDeadlineFor(model.OrderTypeNormal, time.Now())
 	order := &model.Order{
 		OrderNo:         orderNo,
 		UserID:          userID,
@@ -321,21 +428,68 @@ func (s *OrderService) CreateOrder(ctx context.Context, req *orderv1.CreateOrder
 		DiscountAmount:  discountAmount,
 		ShippingAmount:  shippingAmount,
 		PayAmount:       payAmount,
+		CouponID:        discount.CouponID,
 		BuyerRemark:     req.BuyerRemark,
 		ReceiverName:    userAddress.ReceiverName,
 		ReceiverPhone:   userAddress.ReceiverPhone,
 		ReceiverAddress: receiverAddress,
 		ItemsSnapshot:   itemsSnapshotJSON, // 商品列表精简快照
-		Version:         1,                 // 初始化版本号为1
+		PayDeadline:     &payDeadline,
+		Version:         1, // 初始化版本号为1
+	}
+
+	// 拆单：主订单仅用于支付（不含明细），明细归属到各子订单
+	var subOrders []*model.Order
+	var subOrderNos []string
+	if len(groups) > 1 {
+		subOrders = s.buildSubOrders(order, groups, items, itemsSnapshotList)
+		payAmount = order.PayAmount
+		for _, sub := range subOrders {
+			subOrderNos = append(subOrderNos, sub.OrderNo)
+		}
+	} else {
+		order.MerchantID = groups[0].MerchantID
 	}
 
-	// 先扣减库存（在创建订单之前，防止超卖）
-	// 注意：这里使用订单号作为幂等键，如果订单创建失败，会回滚库存
-	if err := s.inventoryClient.DeductStock(ctx, orderNo, deductItems); err != nil {
-		log.Printf("❌ [OrderService] CreateOrder: 扣减库存失败: %v", err)
+	// 发票按开票订单生成：拆单时每个子订单一张，否则为订单本身
+	var invoices []*model.OrderInvoice
+	if invoiceTitle != nil {
+		invoiceOrders := subOrders
+		if len(invoiceOrders) == 0 {
+			invoiceOrders = []*model.Order{order}
+		}
+		invoices = buildOrderInvoices(invoiceTitle, order, invoiceOrders)
+	}
+
+	// 先锁定库存（在创建订单之前，防止超卖），支付成功后确认扣减，取消或超时关闭后释放
+	// 注意：这里使用订单号作为幂等键，如果订单创建失败，会释放锁定的库存
+	// 库存服务按收货省份就近分仓，分仓结果记录到订单明细用于发货
+	allocations, err := s.inventoryClient.ReserveStock(ctx, orderNo, userAddress.Province, deductItems)
+	if err != nil {
+		log.Printf("❌ [OrderService] CreateOrder: 锁定库存失败: %v", err)
 		return &orderv1.CreateOrderResponse{
 			Code:    1,
-			Message: fmt.Sprintf("库存扣减失败: %v", err),
+			Message: fmt.Sprintf("库存锁定失败: %v", err),
+		}, nil
+	}
+	warehouseBySKU := make(map[string]string, len(allocations))
+	for _, a := range allocations {
+		warehouseBySKU[a.SkuId] = a.WarehouseId
+	}
+	for _, item := range items {
+		item.WarehouseID = warehouseBySKU[item.SKUID]
+	}
+
+	// 锁定订单优惠（核销优惠券 + 占用促销配额），与库存一样以订单号作为幂等键
+	// 锁定前写入优惠占用记录并在创建订单的事务中绑定，订单未创建时由补偿任务归还优惠，保证优惠券与订单同生共死
+	if err := s.applyOrderDiscount(ctx, userID, orderNo, totalAmount, discount); err != nil {
+		log.Printf("❌ [OrderService] CreateOrder: 锁定订单优惠失败，释放库存: %v", err)
+		if releaseErr := s.inventoryClient.ReleaseStock(ctx, orderNo); releaseErr != nil {
+			log.Printf("❌ [OrderService] CreateOrder: 释放库存失败: %v", releaseErr)
+		}
+		return &orderv1.CreateOrderResponse{
+			Code:    1,
+			Message: fmt.Sprintf("优惠券核销失败: %v", err),
 		}, nil
 	}
 
@@ -348,25 +502,25 @@ func (s *OrderService) CreateOrder(ctx context.Context, req *orderv1.CreateOrder
 	}
 
 	// 创建订单
-	if err := s.orderRepo.CreateOrder(ctx, order, items); err != nil {
-		log.Printf("❌ [OrderService] CreateOrder: 创建订单失败，回滚库存: %v", err)
+	if len(subOrders) > 0 {
+		err = s.orderRepo.CreateSplitOrders(ctx, order, subOrders, items, invoices)
+	} else {
+		err = s.orderRepo.CreateOrder(ctx, order, items, invoices)
+	}
+	if err != nil {
+		log.Printf("❌ [OrderService] CreateOrder: 创建订单失败，释放库存: %v", err)
 
 		// 检查是否是订单号冲突错误（唯一索引冲突）
-		isDuplicateOrderNo := false
-		if errors.Is(err, gorm.ErrDuplicatedKey) {
-			isDuplicateOrderNo = true
-		} else if strings.Contains(err.Error(), "Duplicate entry") ||
-			strings.Contains(err.Error(), "UNIQUE constraint") ||
-			strings.Contains(err.Error(), "duplicate key") {
-			isDuplicateOrderNo = true
-		}
-
-		// 订单创建失败，回滚库存
-		// 注意：如果是订单号冲突，库存服务会幂等返回（因为订单号已存在），但为了安全还是尝试回滚
-		if rollbackErr := s.inventoryClient.RollbackStock(ctx, orderNo, deductItems); rollbackErr != nil {
-			log.Printf("❌ [OrderService] CreateOrder: 回滚库存失败: %v", rollbackErr)
+		isDuplicateOrderNo := isDuplicateKeyError(err)
+
+		// 订单创建失败，释放锁定的库存
+		// 注意：库存服务按订单号幂等，重复释放不会多加库存
+		if releaseErr := s.inventoryClient.ReleaseStock(ctx, orderNo); releaseErr != nil {
+			log.Printf("❌ [OrderService] CreateOrder: 释放库存失败: %v", releaseErr)
 			// 记录告警，需要人工介入
 		}
+		// 订单创建失败，立即释放已锁定的优惠（失败时由优惠归还补偿任务兜底）
+		s.releaseOrderDiscount(ctx, order)
 
 		if isDuplicateOrderNo {
 			// 订单号冲突，建议用户重试
@@ -375,6 +529,13 @@ func (s *OrderService) CreateOrder(ctx context.Context, req *orderv1.CreateOrder
 				Message: "订单号冲突，请重试",
 			}, nil
 		}
+		if errors.Is(err, repository.ErrDiscountClaimReleased) {
+			// 下单耗时过长，优惠已被补偿任务归还
+			return &orderv1.CreateOrderResponse{
+				Code:    1,
+				Message: "订单优惠已失效，请重新下单",
+			}, nil
+		}
 
 		return &orderv1.CreateOrderResponse{
 			Code:    1,
@@ -382,7 +543,7 @@ func (s *OrderService) CreateOrder(ctx context.Context, req *orderv1.CreateOrder
 		}, nil
 	}
 
-	log.Printf("✅ [OrderService] CreateOrder: 订单创建成功: orderNo=%s", orderNo)
+	log.Printf("✅ [OrderService] CreateOrder: 订单创建成功: orderNo=%s, subOrderNos=%v", orderNo, subOrderNos)
 
 	// 订单创建成功后，直接删除购物车中的商品
 	if len(cartItemIDs) > 0 {
@@ -394,34 +555,45 @@ func (s *OrderService) CreateOrder(ctx context.Context, req *orderv1.CreateOrder
 		}
 	}
 
-	// 发送延迟消息，用于订单超时检查（使用 RabbitMQ 延迟消息插件）
-	if s.delayedProducer != nil {
-		timeoutPayload := map[string]interface{}{
-			"order_no":    orderNo,
-			"user_id":     userID,
-			"pay_amount":  payAmount,
-			"created_at":  time.Now().Format(time.RFC3339),
-			"retry_count": 0, // 消费者重试时递增，达到上限后放弃
-		}
-		delayMs := int64(s.orderTimeoutDelay.Milliseconds())
-		if err := s.delayedProducer.SendDelayedMessage(ctx, "order.timeout.delayed", "order.timeout.queue", timeoutPayload, delayMs); err != nil {
-			// 延迟消息发送失败不影响订单创建成功，但记录警告日志（补偿机制会处理超时订单）
-			log.Printf("⚠️ [OrderService] CreateOrder: 发送订单超时延迟消息失败: orderNo=%s, err=%v (补偿机制将定期扫描超时订单)", orderNo, err)
-		} else {
-			log.Printf("✅ [OrderService] CreateOrder: 订单超时延迟消息已发送: orderNo=%s, delay=%dms", orderNo, delayMs)
-		}
-	} else {
-		log.Printf("⚠️ [OrderService] CreateOrder: 延迟消息生产者未初始化，订单超时将依赖补偿机制: orderNo=%s", orderNo)
-	}
+	// 发送延迟消息，用于订单超时检查
+	s.scheduleOrderTimeout(ctx, orderNo, userID, payAmount, payDeadline)
 
 	return &orderv1.CreateOrderResponse{
-		Code:      0,
-		Message:   "创建成功",
-		OrderNo:   orderNo,
-		PayAmount: fmt.Sprintf("%.2f", payAmount),
+		Code:        0,
+		Message:     "创建成功",
+		OrderNo:     orderNo,
+		PayAmount:   fmt.Sprintf("%.2f", payAmount),
+		SubOrderNos: subOrderNos,
 	}, nil
 }
 
+// scheduleOrderTimeout 发送订单超时延迟消息（使用 RabbitMQ 延迟消息插件）
+// 发送失败不影响下单，补偿机制会定期扫描超时订单
+func (s *OrderService) scheduleOrderTimeout(ctx context.Context, orderNo, userID string, payAmount float64, payDeadline time.Time) {
+	if s.delayedProducer == nil {
+		log.Printf("⚠️ [OrderService] scheduleOrderTimeout: 延迟消息生产者未初始化，订单超时将依赖补偿机制: orderNo=%s", orderNo)
+		return
+	}
+	timeoutPayload := map[string]interface{}{
+		"order_no":     orderNo,
+		"user_id":      userID,
+		"pay_amount":   payAmount,
+		"created_at":   time.Now().Format(time.RFC3339),
+		"pay_deadline": payDeadline.Format(time.RFC3339),
+		"retry_count":  0, // 消费者重试时递增，达到上限后放弃
+	}
+	// 延迟到支付截止时间（已过截止时间时立即投递）
+	delayMs := time.Until(payDeadline).Milliseconds()
+	if delayMs < 0 {
+		delayMs = 0
+	}
+	if err := s.delayedProducer.SendDelayedMessage(ctx, "order.timeout.delayed", "order.timeout.queue", timeoutPayload, delayMs); err != nil {
+		log.Printf("⚠️ [OrderService] scheduleOrderTimeout: 发送订单超时延迟消息失败: orderNo=%s, err=%v (补偿机制将定期扫描超时订单)", orderNo, err)
+		return
+	}
+	log.Printf("✅ [OrderService] scheduleOrderTimeout: 订单超时延迟消息已发送: orderNo=%s, delay=%dms", orderNo, delayMs)
+}
+
 // GetOrder 获取订单详情（根据订单号查询订单主表和明细表）
 func (s *OrderService) GetOrder(ctx context.Context, req *orderv1.GetOrderRequest) (*orderv1.GetOrderResponse, error) {
 	// 1. 校验用户登录状态
@@ -443,8 +615,17 @@ func (s *OrderService) GetOrder(ctx context.Context, req *orderv1.GetOrderReques
 		}, nil
 	}
 
-	// 3. 查询订单主表和明细表
-	order, items, err := s.orderRepo.GetOrderByNo(ctx, userID, req.OrderNo)
+	// 3. 查询订单主表和明细表（管理员可查看任意用户的订单，用于客服排查）
+	var (
+		order *model.Order
+		items []*model.OrderItem
+		err   error
+	)
+	if middleware.CheckRole(ctx, "admin") {
+		order, items, err = s.orderRepo.GetOrderByNoNoUser(ctx, req.OrderNo)
+	} else {
+		order, items, err = s.orderRepo.GetOrderByNo(ctx, userID, req.OrderNo)
+	}
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			log.Printf("⚠️ [OrderService] GetOrder: 订单不存在, orderNo=%s, userID=%s", req.OrderNo, userID)
@@ -460,13 +641,46 @@ func (s *OrderService) GetOrder(ctx context.Context, req *orderv1.GetOrderReques
 		}, nil
 	}
 
-	// 4. 转换并返回数据
+	// 4. 主订单不含明细，返回子订单及其明细
+	var subOrders []*orderv1.Order
+	invoiceOrderNos := []string{order.OrderNo}
+	if order.SplitType == model.OrderSplitTypeParent {
+		children, childItems, err := s.orderRepo.GetChildOrders(ctx, order.OrderNo)
+		if err != nil {
+			log.Printf("❌ [OrderService] GetOrder: 查询子订单失败, orderNo=%s, error=%v", req.OrderNo, err)
+			return &orderv1.GetOrderResponse{
+				Code:    1,
+				Message: "查询订单失败，请稍后重试",
+			}, nil
+		}
+		for _, child := range children {
+			subOrders = append(subOrders, convertOrderToProto(child))
+			invoiceOrderNos = append(invoiceOrderNos, child.OrderNo)
+		}
+		items = childItems
+	}
+
+	// 5. 状态流转历史（查询失败不影响订单详情）
+	history, err := s.orderRepo.ListStatusHistory(ctx, order.OrderNo)
+	if err != nil {
+		log.Printf("⚠️ [OrderService] GetOrder: 查询订单状态流转历史失败, orderNo=%s, error=%v", req.OrderNo, err)
+	}
+	// 发票（查询失败不影响订单详情）
+	invoices, err := s.invoiceRepo.ListInvoicesByOrderNos(ctx, invoiceOrderNos)
+	if err != nil {
+		log.Printf("⚠️ [OrderService] GetOrder: 查询订单发票失败, orderNo=%s, error=%v", req.OrderNo, err)
+	}
+
+	// 6. 转换并返回数据
 	log.Printf("✅ [OrderService] GetOrder: 查询成功, orderNo=%s, userID=%s, itemCount=%d", req.OrderNo, userID, len(items))
 	return &orderv1.GetOrderResponse{
-		Code:    0,
-		Message: "查询成功",
-		Order:   convertOrderToProto(order),
-		Items:   convertOrderItemsToProto(items),
+		Code:          0,
+		Message:       "查询成功",
+		Order:         convertOrderToProto(order),
+		Items:         convertOrderItemsToProto(items),
+		SubOrders:     subOrders,
+		StatusHistory: convertStatusHistoryToProto(history),
+		Invoices:      convertInvoicesToProto(invoices),
 	}, nil
 }
 
@@ -524,40 +738,49 @@ func (s *OrderService) CancelOrder(ctx context.Context, req *orderv1.CancelOrder
 		}, nil
 	}
 
-	// 更新订单状态（使用乐观锁）
-	if err := s.orderRepo.UpdateOrderStatus(ctx, req.OrderNo,
-		int8(OrderStatusPendingPay),
-		int8(OrderStatusCancelled)); err != nil {
+	order, orderItems, err := s.orderRepo.GetOrderByNo(ctx, userID, req.OrderNo)
+	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			log.Printf("⚠️ [OrderService] CancelOrder: 订单状态已被其他请求修改: %v", err)
 			return &orderv1.CancelOrderResponse{
 				Code:    1,
-				Message: "订单状态已变更，请刷新后重试",
+				Message: "订单不存在或无权访问",
 			}, nil
 		}
-		log.Printf("❌ [OrderService] CancelOrder: 取消订单失败: %v", err)
+		log.Printf("❌ [OrderService] CancelOrder: 查询订单失败: %v", err)
 		return &orderv1.CancelOrderResponse{
 			Code:    1,
 			Message: "取消订单失败",
 		}, nil
 	}
 
-	// 回滚库存（订单取消时释放库存）
-	_, orderItems, err := s.orderRepo.GetOrderByNo(ctx, userID, req.OrderNo)
-	if err == nil {
-		var rollbackItems []*inventoryv1.SkuQuantity
-		for _, item := range orderItems {
-			rollbackItems = append(rollbackItems, &inventoryv1.SkuQuantity{
-				SkuId:    item.SKUID,
-				Quantity: int64(item.Quantity),
-			})
-		}
-		if len(rollbackItems) > 0 {
-			if rollbackErr := s.inventoryClient.RollbackStock(ctx, req.OrderNo, rollbackItems); rollbackErr != nil {
-				log.Printf("❌ [OrderService] CancelOrder: 回滚库存失败: %v", rollbackErr)
-				// 记录告警，但不影响订单取消流程
-			}
+	// 更新订单状态（使用乐观锁），取消后释放库存、优惠券与促销配额
+	err = s.stateMachine.Fire(ctx, &statemachine.Transition{
+		Event:  OrderEventCancel,
+		From:   OrderStatusPendingPay,
+		To:     OrderStatusCancelled,
+		Reason: "用户取消订单",
+		Order:  order,
+		Items:  orderItems,
+	})
+	if err != nil {
+		if reason, ok := rejectReason(err); ok {
+			return &orderv1.CancelOrderResponse{
+				Code:    1,
+				Message: reason,
+			}, nil
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("⚠️ [OrderService] CancelOrder: 订单状态已被其他请求修改: %v", err)
+			return &orderv1.CancelOrderResponse{
+				Code:    1,
+				Message: "订单状态已变更，请刷新后重试",
+			}, nil
 		}
+		log.Printf("❌ [OrderService] CancelOrder: 取消订单失败: %v", err)
+		return &orderv1.CancelOrderResponse{
+			Code:    1,
+			Message: "取消订单失败",
+		}, nil
 	}
 
 	return &orderv1.CancelOrderResponse{
@@ -570,9 +793,34 @@ func (s *OrderService) CancelOrder(ctx context.Context, req *orderv1.CancelOrder
 func (s *OrderService) MarkOrderPaid(ctx context.Context, req *orderv1.MarkOrderPaidRequest) (*orderv1.MarkOrderPaidResponse, error) {
 
 	now := time.Now()
-	if err := s.orderRepo.UpdateOrderPaid(ctx, req.OrderNo, OrderStatusPendingPay, OrderStatusPaid, req.PayChannel, req.PayTradeNo, now); err != nil {
+	err := s.stateMachine.Fire(ctx, &statemachine.Transition{
+		Event:   OrderEventPay,
+		OrderNo: req.OrderNo,
+		From:    OrderStatusPendingPay,
+		To:      OrderStatusPaid,
+		Fields:  orderPaidFields(req.PayChannel, req.PayTradeNo, now),
+		Reason:  fmt.Sprintf("支付成功，渠道=%s，流水号=%s", req.PayChannel, req.PayTradeNo),
+	})
+	if err != nil {
+		if reason, ok := rejectReason(err); ok {
+			return &orderv1.MarkOrderPaidResponse{
+				Code:    1,
+				Message: reason,
+			}, nil
+		}
+		if errors.Is(err, statemachine.ErrHookFailed) {
+			log.Printf("❌ [OrderService] MarkOrderPaid: 同步子订单支付状态失败: %v", err)
+			return &orderv1.MarkOrderPaidResponse{
+				Code:    1,
+				Message: "更新子订单状态失败",
+			}, nil
+		}
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			log.Printf("⚠️ [OrderService] MarkOrderPaid: 订单状态已被其他请求修改（可能是重复回调）: %v", err)
+			// 主订单已支付但子订单同步中断时，重复回调可补齐子订单状态
+			if err := s.paySubOrders(ctx, req.OrderNo); err != nil {
+				log.Printf("❌ [OrderService] MarkOrderPaid: 同步子订单支付状态失败: %v", err)
+			}
 			return &orderv1.MarkOrderPaidResponse{
 				Code:    0, // 幂等返回成功
 				Message: "订单已处理",
@@ -603,6 +851,7 @@ type PaymentSucceededEvent struct {
 }
 
 // HandlePaymentSucceededEvent 处理支付成功事件，幂等更新订单状态为已支付
+// 拆单的主订单支付成功后同步将子订单标记为已支付，子订单同步失败时返回错误由 MQ 重试
 func (s *OrderService) HandlePaymentSucceededEvent(ctx context.Context, evt *PaymentSucceededEvent) error {
 	if evt == nil {
 		return fmt.Errorf("支付成功事件为空")
@@ -627,12 +876,24 @@ func (s *OrderService) HandlePaymentSucceededEvent(ctx context.Context, evt *Pay
 		payChannel = "alipay"
 	}
 
-	// 使用已存在的仓储方法，基于 fromStatus + 乐观锁保证幂等
-	if err := s.orderRepo.UpdateOrderPaid(ctx, evt.OrderNo, OrderStatusPendingPay, OrderStatusPaid, payChannel, evt.TradeNo, paidAt); err != nil {
+	// 基于 fromStatus + 乐观锁保证幂等，主订单流转成功后由状态机同步子订单
+	err := s.stateMachine.Fire(ctx, &statemachine.Transition{
+		Event:   OrderEventPay,
+		OrderNo: evt.OrderNo,
+		From:    OrderStatusPendingPay,
+		To:      OrderStatusPaid,
+		Fields:  orderPaidFields(payChannel, evt.TradeNo, paidAt),
+		Reason:  fmt.Sprintf("支付成功，支付单号=%s，流水号=%s", evt.PaymentNo, evt.TradeNo),
+	})
+	if err != nil {
+		if reason, ok := rejectReason(err); ok {
+			log.Printf("⚠️ [OrderService] HandlePaymentSucceededEvent: 订单不允许支付，忽略本次事件: orderNo=%s, reason=%s", evt.OrderNo, reason)
+			return nil
+		}
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			// 可能已被其他流程更新为已支付 / 已取消，作为幂等成功处理
+			// 可能已被其他流程更新为已支付 / 已取消，作为幂等成功处理（主订单已支付时补齐子订单状态）
 			log.Printf("⚠️ [OrderService] HandlePaymentSucceededEvent: 订单状态已变更，忽略本次事件: orderNo=%s", evt.OrderNo)
-			return nil
+			return s.paySubOrders(ctx, evt.OrderNo)
 		}
 		return fmt.Errorf("更新订单支付状态失败: %w", err)
 	}
@@ -641,6 +902,15 @@ func (s *OrderService) HandlePaymentSucceededEvent(ctx context.Context, evt *Pay
 	return nil
 }
 
+// orderPaidFields 支付成功时随状态一起更新的订单字段
+func orderPaidFields(payChannel, payTradeNo string, paidAt time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"pay_channel":  payChannel,
+		"pay_trade_no": payTradeNo,
+		"paid_at":      &paidAt,
+	}
+}
+
 // ======== 辅助转换函数 ========
 
 func convertOrderToProto(o *model.Order) *orderv1.Order {
@@ -657,6 +927,12 @@ func convertOrderToProto(o *model.Order) *orderv1.Order {
 		ReceiverPhone:   o.ReceiverPhone,
 		ReceiverAddress: o.ReceiverAddress,
 		BuyerRemark:     o.BuyerRemark,
+		CouponId:        o.CouponID,
+		ShippingCarrier: o.ShippingCarrier,
+		TrackingNo:      o.TrackingNo,
+		SplitType:       orderv1.OrderSplitType(o.SplitType),
+		ParentOrderNo:   o.ParentOrderNo,
+		MerchantId:      o.MerchantID,
 		CreatedAt:       timestamppb.New(o.CreatedAt),
 		PaidAt:          nil,
 		ShippedAt:       nil,
@@ -672,6 +948,9 @@ func convertOrderToProto(o *model.Order) *orderv1.Order {
 	if o.CompletedAt != nil {
 		res.CompletedAt = timestamppb.New(*o.CompletedAt)
 	}
+	if o.PayDeadline != nil {
+		res.PayDeadline = timestamppb.New(*o.PayDeadline)
+	}
 	return res
 }
 
@@ -689,10 +968,27 @@ func convertOrderItemsToProto(items []*model.OrderItem) []*orderv1.OrderItem {
 			Price:          fmt.Sprintf("%.2f", it.Price),
 			Quantity:       it.Quantity,
 			SubtotalAmount: fmt.Sprintf("%.2f", it.Subtotal),
+			DiscountAmount: fmt.Sprintf("%.2f", it.DiscountAmount),
+			WarehouseId:    it.WarehouseID,
 		})
 	}
 	return res
 }
+
+// isDuplicateKeyError 检查是否是唯一索引冲突错误
+func isDuplicateKeyError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return true
+	}
+	errStr := err.Error()
+	return strings.Contains(errStr, "Duplicate entry") ||
+		strings.Contains(errStr, "UNIQUE constraint") ||
+		strings.Contains(errStr, "duplicate key")
+}
+
 func orderNoGenerator(orderType string) string {
 	var orderNo string
 	date := time.Now().Format("200601021504") //12位
@@ -743,28 +1039,30 @@ func (s *OrderService) GenerateOrderToken(ctx context.Context, req *orderv1.Gene
 
 // ItemBasicSnapshot 商品基本信息快照（用于订单表的精简快照）
 type ItemBasicSnapshot struct {
-	ProductID    string `json:"product_id"`
-	SKUID        string `json:"sku_id"`
-	ProductTitle string `json:"product_title"`
-	SKUName      string `json:"sku_name"`
-	Price        string `json:"price"`
-	Quantity     int32  `json:"quantity"`
-	Subtotal     string `json:"subtotal"`
+	ProductID      string `json:"product_id"`
+	SKUID          string `json:"sku_id"`
+	ProductTitle   string `json:"product_title"`
+	SKUName        string `json:"sku_name"`
+	Price          string `json:"price"`
+	Quantity       int32  `json:"quantity"`
+	DiscountAmount string `json:"discount_amount"`
+	Subtotal       string `json:"subtotal"`
 }
 
 // ItemDetailSnapshot 商品详细快照（用于订单明细表的详细快照）
 type ItemDetailSnapshot struct {
-	ProductID    string            `json:"product_id"`
-	SKUID        string            `json:"sku_id"`
-	ProductTitle string            `json:"product_title"`
-	ProductImage string            `json:"product_image"`
-	SKUName      string            `json:"sku_name"`
-	Price        string            `json:"price"`
-	Quantity     int32             `json:"quantity"`
-	Subtotal     string            `json:"subtotal"`
-	Address      string            `json:"address"`
-	ProductAttrs map[string]string `json:"product_attrs,omitempty"` // 商品属性（可选，扩展用）
-	SnapshotTime string            `json:"snapshot_time"`
+	ProductID      string            `json:"product_id"`
+	SKUID          string            `json:"sku_id"`
+	ProductTitle   string            `json:"product_title"`
+	ProductImage   string            `json:"product_image"`
+	SKUName        string            `json:"sku_name"`
+	Price          string            `json:"price"`
+	Quantity       int32             `json:"quantity"`
+	DiscountAmount string            `json:"discount_amount"` // 分摊优惠金额
+	Subtotal       string            `json:"subtotal"`        // 扣除分摊优惠后的小计
+	Address        string            `json:"address"`
+	ProductAttrs   map[string]string `json:"product_attrs,omitempty"` // 商品属性（可选，扩展用）
+	SnapshotTime   string            `json:"snapshot_time"`
 }
 
 // generateItemsSnapshot 生成订单表的精简快照（商品列表摘要）
@@ -791,19 +1089,21 @@ func (s *OrderService) generateItemDetailSnapshot(
 	itemInput *orderv1.CreateOrderItemInput,
 	productTitle, productImage, skuName string,
 	price float64,
+	discountAmount float64,
 	receiverAddress string,
 ) (string, error) {
 	itemDetailSnapshot := ItemDetailSnapshot{
-		ProductID:    itemInput.ProductId,
-		SKUID:        itemInput.SkuId,
-		ProductTitle: productTitle,
-		ProductImage: productImage,
-		SKUName:      skuName,
-		Price:        fmt.Sprintf("%.2f", price),
-		Quantity:     itemInput.Quantity,
-		Subtotal:     fmt.Sprintf("%.2f", float64(itemInput.Quantity)*price),
-		Address:      receiverAddress,
-		SnapshotTime: time.Now().Format("2006-01-02 15:04:05"),
+		ProductID:      itemInput.ProductId,
+		SKUID:          itemInput.SkuId,
+		ProductTitle:   productTitle,
+		ProductImage:   productImage,
+		SKUName:        skuName,
+		Price:          fmt.Sprintf("%.2f", price),
+		Quantity:       itemInput.Quantity,
+		DiscountAmount: fmt.Sprintf("%.2f", discountAmount),
+		Subtotal:       fmt.Sprintf("%.2f", float64(itemInput.Quantity)*price-discountAmount),
+		Address:        receiverAddress,
+		SnapshotTime:   time.Now().Format("2006-01-02 15:04:05"),
 		// ProductAttrs 可以后续扩展，保存商品的其他属性（如颜色、尺寸等）
 	}
 
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+	orderv1 "zjMall/gen/go/api/proto/order"
+	"zjMall/internal/order-service/model"
+	"zjMall/internal/order-service/repository"
+)
+
+const (
+	adminOrderSearchDefaultPageSize = 20
+	adminOrderSearchMaxPageSize     = 100
+	orderExportBatchSize            = 500 // CSV 导出每批查询的订单数
+)
+
+// OrderCSVHeader 订单导出 CSV 表头（与 OrderCSVRecord 字段顺序一致）
+var OrderCSVHeader = []string{
+	"订单号", "用户ID", "订单状态", "商品金额", "优惠金额", "运费", "应付金额",
+	"支付渠道", "支付流水号", "收货人", "收货电话", "收货地址",
+	"拆单类型", "主订单号", "商家ID", "物流公司", "物流单号",
+	"下单时间", "支付时间", "发货时间", "完成时间",
+}
+
+// NewOrderSearchFilter 校验管理员搜索条件并转换为仓储查询条件
+func NewOrderSearchFilter(req *orderv1.AdminSearchOrdersRequest) (repository.OrderSearchFilter, error) {
+	filter := repository.OrderSearchFilter{
+		OrderNo:       req.OrderNo,
+		UserID:        req.UserId,
+		Status:        int8(req.Status),
+		ReceiverPhone: req.ReceiverPhone,
+		SKUID:         req.SkuId,
+	}
+	if req.CreatedFrom != nil {
+		t := req.CreatedFrom.AsTime()
+		filter.CreatedFrom = &t
+	}
+	if req.CreatedTo != nil {
+		t := req.CreatedTo.AsTime()
+		filter.CreatedTo = &t
+	}
+	if filter.CreatedFrom != nil && filter.CreatedTo != nil && !filter.CreatedFrom.Before(*filter.CreatedTo) {
+		return filter, fmt.Errorf("下单开始时间必须早于结束时间")
+	}
+
+	var err error
+	if filter.MinPayAmount, err = parseAmountFilter(req.MinPayAmount, "应付金额下限"); err != nil {
+		return filter, err
+	}
+	if filter.MaxPayAmount, err = parseAmountFilter(req.MaxPayAmount, "应付金额上限"); err != nil {
+		return filter, err
+	}
+	if filter.MinPayAmount != nil && filter.MaxPayAmount != nil && *filter.MinPayAmount > *filter.MaxPayAmount {
+		return filter, fmt.Errorf("应付金额下限不能大于上限")
+	}
+	return filter, nil
+}
+
+// parseAmountFilter 解析金额条件，为空时返回 nil（不过滤）
+func parseAmountFilter(value, name string) (*float64, error) {
+	if value == "" {
+		return nil, nil
+	}
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil || amount < 0 {
+		return nil, fmt.Errorf("%s格式错误", name)
+	}
+	return &amount, nil
+}
+
+// AdminSearchOrders 管理员跨用户搜索订单（游标分页）
+func (s *OrderService) AdminSearchOrders(ctx context.Context, req *orderv1.AdminSearchOrdersRequest) (*orderv1.AdminSearchOrdersResponse, error) {
+	filter, err := NewOrderSearchFilter(req)
+	if err != nil {
+		return &orderv1.AdminSearchOrdersResponse{
+			Code:    1,
+			Message: err.Error(),
+		}, nil
+	}
+
+	pageSize := int(req.PageSize)
+	if pageSize <= 0 {
+		pageSize = adminOrderSearchDefaultPageSize
+	}
+	if pageSize > adminOrderSearchMaxPageSize {
+		pageSize = adminOrderSearchMaxPageSize
+	}
+
+	// 多查一条用于判断是否还有下一页
+	orders, err := s.orderRepo.SearchOrders(ctx, filter, req.Cursor, pageSize+1)
+	if err != nil {
+		log.Printf("❌ [OrderService] AdminSearchOrders: 搜索订单失败: err=%v", err)
+		return &orderv1.AdminSearchOrdersResponse{
+			Code:    1,
+			Message: "搜索订单失败",
+		}, nil
+	}
+
+	var nextCursor string
+	if len(orders) > pageSize {
+		orders = orders[:pageSize]
+		nextCursor = orders[pageSize-1].ID
+	}
+
+	protoOrders := make([]*orderv1.Order, 0, len(orders))
+	for _, o := range orders {
+		protoOrders = append(protoOrders, convertOrderToProto(o))
+	}
+	return &orderv1.AdminSearchOrdersResponse{
+		Code:       0,
+		Message:    "查询成功",
+		Orders:     protoOrders,
+		NextCursor: nextCursor,
+	}, nil
+}
+
+// ExportOrders 按搜索条件分批读取全部订单，每批交给 write 写出（用于流式 CSV 导出）
+// write 返回错误（如客户端断开）时停止导出
+func (s *OrderService) ExportOrders(ctx context.Context, filter repository.OrderSearchFilter, write func(orders []*model.Order) error) error {
+	cursor := ""
+	total := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		orders, err := s.orderRepo.SearchOrders(ctx, filter, cursor, orderExportBatchSize)
+		if err != nil {
+			return fmt.Errorf("查询订单失败: %w", err)
+		}
+		if len(orders) == 0 {
+			break
+		}
+		if err := write(orders); err != nil {
+			return err
+		}
+		total += len(orders)
+		if len(orders) < orderExportBatchSize {
+			break
+		}
+		cursor = orders[len(orders)-1].ID
+	}
+	log.Printf("✅ [OrderService] ExportOrders: 订单导出完成: total=%d", total)
+	return nil
+}
+
+// OrderCSVRecord 订单导出 CSV 行（单元格已做公式注入转义）
+func OrderCSVRecord(o *model.Order) []string {
+	record := []string{
+		o.OrderNo,
+		o.UserID,
+		orderStatusText(o.Status),
+		fmt.Sprintf("%.2f", o.TotalAmount),
+		fmt.Sprintf("%.2f", o.DiscountAmount),
+		fmt.Sprintf("%.2f", o.ShippingAmount),
+		fmt.Sprintf("%.2f", o.PayAmount),
+		o.PayChannel,
+		o.PayTradeNo,
+		o.ReceiverName,
+		o.ReceiverPhone,
+		o.ReceiverAddress,
+		orderSplitTypeText(o.SplitType),
+		o.ParentOrderNo,
+		o.MerchantID,
+		o.ShippingCarrier,
+		o.TrackingNo,
+		o.CreatedAt.Format(time.DateTime),
+		formatOptionalTime(o.PaidAt),
+		formatOptionalTime(o.ShippedAt),
+		formatOptionalTime(o.CompletedAt),
+	}
+	for i, cell := range record {
+		record[i] = escapeCSVFormula(cell)
+	}
+	return record
+}
+
+// escapeCSVFormula 收货人等字段由买家填写，以 = + - @ 制表符或回车开头的单元格会被 Excel 当作公式执行，前面加 ' 转为文本
+func escapeCSVFormula(cell string) string {
+	if cell == "" {
+		return cell
+	}
+	switch cell[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		return "'" + cell
+	}
+	return cell
+}
+
+// orderSplitTypeText 拆单类型描述
+func orderSplitTypeText(splitType int8) string {
+	switch splitType {
+	case model.OrderSplitTypeParent:
+		return "主订单"
+	case model.OrderSplitTypeChild:
+		return "子订单"
+	default:
+		return "未拆单"
+	}
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.DateTime)
+}
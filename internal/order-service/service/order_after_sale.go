@@ -0,0 +1,636 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"strconv"
+	"time"
+	inventoryv1 "zjMall/gen/go/api/proto/inventory"
+	orderv1 "zjMall/gen/go/api/proto/order"
+	paymentv1 "zjMall/gen/go/api/proto/payment"
+	"zjMall/internal/common/lock"
+	"zjMall/internal/common/middleware"
+	"zjMall/internal/order-service/model"
+	"zjMall/internal/order-service/repository"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+)
+
+// AfterSaleLockCacheKeyPrefix 售后申请锁前缀（按订单明细加锁，保证累计售后数量校验的一致性）
+const AfterSaleLockCacheKeyPrefix = "order:aftersale:lock"
+
+// CreateAfterSale 买家申请售后
+// 仅退款可在已支付、已发货、已完成时申请；退货退款与换货需在发货后申请
+// 同一订单明细可多次申请，未被拒绝的售后单累计数量不能超过购买数量
+func (s *OrderService) CreateAfterSale(ctx context.Context, req *orderv1.CreateAfterSaleRequest) (*orderv1.CreateAfterSaleResponse, error) {
+	userID := middleware.GetUserIDFromContext(ctx)
+	if userID == "" {
+		return &orderv1.CreateAfterSaleResponse{
+			Code:    1,
+			Message: "用户未登录",
+		}, nil
+	}
+
+	order, items, err := s.orderRepo.GetOrderByNo(ctx, userID, req.OrderNo)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("❌ [OrderService] CreateAfterSale: 查询订单失败: orderNo=%s, err=%v", req.OrderNo, err)
+		}
+		return &orderv1.CreateAfterSaleResponse{
+			Code:    1,
+			Message: "订单不存在或无权访问",
+		}, nil
+	}
+
+	afterSaleType := int8(req.Type)
+	switch order.Status {
+	case OrderStatusShipped, OrderStatusCompleted:
+	case OrderStatusPaid:
+		if afterSaleType != model.AfterSaleTypeRefundOnly {
+			return &orderv1.CreateAfterSaleResponse{
+				Code:    1,
+				Message: "订单未发货，仅支持申请仅退款",
+			}, nil
+		}
+	default:
+		return &orderv1.CreateAfterSaleResponse{
+			Code:    1,
+			Message: "当前订单状态不允许申请售后",
+		}, nil
+	}
+
+	var item *model.OrderItem
+	for _, it := range items {
+		if it.ID == req.OrderItemId {
+			item = it
+			break
+		}
+	}
+	if item == nil {
+		return &orderv1.CreateAfterSaleResponse{
+			Code:    1,
+			Message: "订单明细不存在",
+		}, nil
+	}
+	if req.Quantity > item.Quantity {
+		return &orderv1.CreateAfterSaleResponse{
+			Code:    1,
+			Message: fmt.Sprintf("售后数量不能超过购买数量%d", item.Quantity),
+		}, nil
+	}
+
+	// 退款金额上限：按售后数量分摊明细实付金额
+	maxRefundCents := int64(math.Round(item.Subtotal*100)) * int64(req.Quantity) / int64(item.Quantity)
+	var refundCents int64
+	if afterSaleType != model.AfterSaleTypeExchange {
+		refundCents = maxRefundCents
+		if req.RefundAmount != "" {
+			amount, err := strconv.ParseFloat(req.RefundAmount, 64)
+			if err != nil {
+				return &orderv1.CreateAfterSaleResponse{
+					Code:    1,
+					Message: "退款金额格式错误",
+				}, nil
+			}
+			refundCents = int64(math.Round(amount * 100))
+		}
+		if refundCents <= 0 || refundCents > maxRefundCents {
+			return &orderv1.CreateAfterSaleResponse{
+				Code:    1,
+				Message: fmt.Sprintf("退款金额必须大于0且不超过%.2f", float64(maxRefundCents)/100),
+			}, nil
+		}
+	}
+
+	evidenceImages, _ := json.Marshal(req.EvidenceImages)
+
+	// 按订单明细加锁，避免并发申请超出购买数量
+	lockKey := fmt.Sprintf("%s:%s", AfterSaleLockCacheKeyPrefix, item.ID)
+	lockService := lock.NewRedisLockService(s.redisClient)
+	acquired, err := lockService.AcquireLock(ctx, lockKey, 10*time.Second)
+	if err != nil || !acquired {
+		log.Printf("⚠️ [OrderService] CreateAfterSale: 获取售后锁失败: orderItemID=%s, err=%v", item.ID, err)
+		return &orderv1.CreateAfterSaleResponse{
+			Code:    1,
+			Message: "系统繁忙，请稍后重试",
+		}, nil
+	}
+	defer lockService.ReleaseLock(ctx, lockKey)
+
+	used, err := s.afterSaleRepo.SumItemQuantity(ctx, item.ID)
+	if err != nil {
+		log.Printf("❌ [OrderService] CreateAfterSale: 统计售后数量失败: orderItemID=%s, err=%v", item.ID, err)
+		return &orderv1.CreateAfterSaleResponse{
+			Code:    1,
+			Message: "申请售后失败，请稍后重试",
+		}, nil
+	}
+	if used+int64(req.Quantity) > int64(item.Quantity) {
+		return &orderv1.CreateAfterSaleResponse{
+			Code:    1,
+			Message: fmt.Sprintf("可申请售后数量不足，剩余可申请数量=%d", int64(item.Quantity)-used),
+		}, nil
+	}
+
+	afterSale := &model.AfterSale{
+		AfterSaleNo:    afterSaleNoGenerator(),
+		OrderNo:        order.OrderNo,
+		OrderItemID:    item.ID,
+		UserID:         userID,
+		SKUID:          item.SKUID,
+		Type:           afterSaleType,
+		Status:         model.AfterSaleStatusSubmitted,
+		Quantity:       req.Quantity,
+		Reason:         req.Reason,
+		EvidenceImages: string(evidenceImages),
+		RefundAmount:   float64(refundCents) / 100,
+	}
+	if err := s.afterSaleRepo.CreateAfterSale(ctx, afterSale); err != nil {
+		log.Printf("❌ [OrderService] CreateAfterSale: 创建售后单失败: orderNo=%s, err=%v", req.OrderNo, err)
+		return &orderv1.CreateAfterSaleResponse{
+			Code:    1,
+			Message: "申请售后失败，请稍后重试",
+		}, nil
+	}
+
+	log.Printf("✅ [OrderService] CreateAfterSale: 售后单已创建: afterSaleNo=%s, orderNo=%s, type=%d, quantity=%d, amount=%.2f",
+		afterSale.AfterSaleNo, afterSale.OrderNo, afterSale.Type, afterSale.Quantity, afterSale.RefundAmount)
+	return &orderv1.CreateAfterSaleResponse{
+		Code:      0,
+		Message:   "申请成功",
+		AfterSale: convertAfterSaleToProto(afterSale),
+	}, nil
+}
+
+// ListAfterSales 查询售后单列表（买家只能查询自己的售后单，管理员可查询全部）
+func (s *OrderService) ListAfterSales(ctx context.Context, req *orderv1.ListAfterSalesRequest) (*orderv1.ListAfterSalesResponse, error) {
+	userID := middleware.GetUserIDFromContext(ctx)
+	if userID == "" {
+		return &orderv1.ListAfterSalesResponse{
+			Code:    1,
+			Message: "用户未登录",
+		}, nil
+	}
+
+	filter := repository.AfterSaleFilter{
+		OrderNo: req.OrderNo,
+		Status:  int8(req.Status),
+	}
+	if !middleware.CheckRole(ctx, "admin") {
+		filter.UserID = userID
+	}
+
+	page := int(req.Page)
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := int(req.PageSize)
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	afterSales, total, err := s.afterSaleRepo.ListAfterSales(ctx, filter, (page-1)*pageSize, pageSize)
+	if err != nil {
+		log.Printf("❌ [OrderService] ListAfterSales: 查询售后单失败: err=%v", err)
+		return &orderv1.ListAfterSalesResponse{
+			Code:    1,
+			Message: "查询售后单失败",
+		}, nil
+	}
+
+	data := make([]*orderv1.AfterSale, 0, len(afterSales))
+	for _, afterSale := range afterSales {
+		data = append(data, convertAfterSaleToProto(afterSale))
+	}
+	return &orderv1.ListAfterSalesResponse{
+		Code:       0,
+		Message:    "查询成功",
+		AfterSales: data,
+		Total:      total,
+	}, nil
+}
+
+// GetAfterSale 查询售后单详情（买家查询自己的售后单，管理员可查询任意售后单）
+func (s *OrderService) GetAfterSale(ctx context.Context, req *orderv1.GetAfterSaleRequest) (*orderv1.GetAfterSaleResponse, error) {
+	userID := middleware.GetUserIDFromContext(ctx)
+	if userID == "" {
+		return &orderv1.GetAfterSaleResponse{
+			Code:    1,
+			Message: "用户未登录",
+		}, nil
+	}
+
+	afterSale, err := s.afterSaleRepo.GetAfterSaleByNo(ctx, req.AfterSaleNo)
+	if err != nil {
+		log.Printf("❌ [OrderService] GetAfterSale: 查询售后单失败: afterSaleNo=%s, err=%v", req.AfterSaleNo, err)
+		return &orderv1.GetAfterSaleResponse{
+			Code:    1,
+			Message: "查询售后单失败",
+		}, nil
+	}
+	if afterSale == nil || (afterSale.UserID != userID && !middleware.CheckRole(ctx, "admin")) {
+		return &orderv1.GetAfterSaleResponse{
+			Code:    1,
+			Message: "售后单不存在",
+		}, nil
+	}
+
+	return &orderv1.GetAfterSaleResponse{
+		Code:      0,
+		Message:   "查询成功",
+		AfterSale: convertAfterSaleToProto(afterSale),
+	}, nil
+}
+
+// SubmitAfterSaleReturn 买家填写退货物流：已同意 -> 买家已寄回（仅退货退款、换货）
+func (s *OrderService) SubmitAfterSaleReturn(ctx context.Context, req *orderv1.SubmitAfterSaleReturnRequest) (*orderv1.SubmitAfterSaleReturnResponse, error) {
+	userID := middleware.GetUserIDFromContext(ctx)
+	if userID == "" {
+		return &orderv1.SubmitAfterSaleReturnResponse{
+			Code:    1,
+			Message: "用户未登录",
+		}, nil
+	}
+
+	afterSale, err := s.afterSaleRepo.GetAfterSaleByNo(ctx, req.AfterSaleNo)
+	if err != nil || afterSale == nil || afterSale.UserID != userID {
+		if err != nil {
+			log.Printf("❌ [OrderService] SubmitAfterSaleReturn: 查询售后单失败: afterSaleNo=%s, err=%v", req.AfterSaleNo, err)
+		}
+		return &orderv1.SubmitAfterSaleReturnResponse{
+			Code:    1,
+			Message: "售后单不存在",
+		}, nil
+	}
+	if afterSale.Type == model.AfterSaleTypeRefundOnly {
+		return &orderv1.SubmitAfterSaleReturnResponse{
+			Code:    1,
+			Message: "仅退款售后无需寄回商品",
+		}, nil
+	}
+
+	now := time.Now()
+	err = s.afterSaleRepo.UpdateAfterSaleStatus(ctx, afterSale.AfterSaleNo, []int8{model.AfterSaleStatusApproved}, model.AfterSaleStatusReturning, map[string]interface{}{
+		"return_carrier":     req.Carrier,
+		"return_tracking_no": req.TrackingNo,
+		"returned_at":        &now,
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &orderv1.SubmitAfterSaleReturnResponse{
+				Code:    1,
+				Message: "售后单状态已变更，仅已同意的售后单可填写退货物流",
+			}, nil
+		}
+		log.Printf("❌ [OrderService] SubmitAfterSaleReturn: 更新售后单失败: afterSaleNo=%s, err=%v", afterSale.AfterSaleNo, err)
+		return &orderv1.SubmitAfterSaleReturnResponse{
+			Code:    1,
+			Message: "提交退货物流失败",
+		}, nil
+	}
+
+	log.Printf("✅ [OrderService] SubmitAfterSaleReturn: 买家已寄回: afterSaleNo=%s, carrier=%s, trackingNo=%s", afterSale.AfterSaleNo, req.Carrier, req.TrackingNo)
+	return &orderv1.SubmitAfterSaleReturnResponse{
+		Code:      0,
+		Message:   "提交成功",
+		AfterSale: s.latestAfterSaleProto(ctx, afterSale.AfterSaleNo),
+	}, nil
+}
+
+// ApproveAfterSale 管理员同意售后：已提交 -> 已同意
+// 仅退款在同意后立即退款（订单未发货时同时回补库存）；退货退款、换货等待买家寄回商品
+// 仅退款的售后单已同意但退款未发起成功时，再次调用会重试退款（退款与回补库存均按售后单号幂等）
+func (s *OrderService) ApproveAfterSale(ctx context.Context, req *orderv1.ApproveAfterSaleRequest) (*orderv1.ApproveAfterSaleResponse, error) {
+	afterSale, err := s.afterSaleRepo.GetAfterSaleByNo(ctx, req.AfterSaleNo)
+	if err != nil || afterSale == nil {
+		if err != nil {
+			log.Printf("❌ [OrderService] ApproveAfterSale: 查询售后单失败: afterSaleNo=%s, err=%v", req.AfterSaleNo, err)
+		}
+		return &orderv1.ApproveAfterSaleResponse{
+			Code:    1,
+			Message: "售后单不存在",
+		}, nil
+	}
+
+	switch {
+	case afterSale.Status == model.AfterSaleStatusSubmitted:
+		now := time.Now()
+		err := s.afterSaleRepo.UpdateAfterSaleStatus(ctx, afterSale.AfterSaleNo, []int8{model.AfterSaleStatusSubmitted}, model.AfterSaleStatusApproved, map[string]interface{}{
+			"audit_remark": req.Remark,
+			"operator_id":  middleware.GetUserIDFromContext(ctx),
+			"approved_at":  &now,
+		})
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return &orderv1.ApproveAfterSaleResponse{
+					Code:    1,
+					Message: "售后单状态已变更，请刷新后重试",
+				}, nil
+			}
+			log.Printf("❌ [OrderService] ApproveAfterSale: 更新售后单失败: afterSaleNo=%s, err=%v", afterSale.AfterSaleNo, err)
+			return &orderv1.ApproveAfterSaleResponse{
+				Code:    1,
+				Message: "审核售后单失败",
+			}, nil
+		}
+		log.Printf("✅ [OrderService] ApproveAfterSale: 售后单已同意: afterSaleNo=%s, type=%d", afterSale.AfterSaleNo, afterSale.Type)
+	case afterSale.Status == model.AfterSaleStatusApproved && afterSale.Type == model.AfterSaleTypeRefundOnly:
+		log.Printf("ℹ️ [OrderService] ApproveAfterSale: 售后单已同意，重试退款: afterSaleNo=%s", afterSale.AfterSaleNo)
+	default:
+		return &orderv1.ApproveAfterSaleResponse{
+			Code:    1,
+			Message: "当前售后状态不允许审核",
+		}, nil
+	}
+
+	if afterSale.Type == model.AfterSaleTypeRefundOnly {
+		if err := s.settleAfterSale(ctx, afterSale, model.AfterSaleStatusApproved); err != nil {
+			log.Printf("❌ [OrderService] ApproveAfterSale: 售后退款失败: afterSaleNo=%s, err=%v", afterSale.AfterSaleNo, err)
+			return &orderv1.ApproveAfterSaleResponse{
+				Code:      1,
+				Message:   fmt.Sprintf("售后单已同意，但退款发起失败，请稍后重试: %v", err),
+				AfterSale: s.latestAfterSaleProto(ctx, afterSale.AfterSaleNo),
+			}, nil
+		}
+	}
+
+	return &orderv1.ApproveAfterSaleResponse{
+		Code:      0,
+		Message:   "审核通过",
+		AfterSale: s.latestAfterSaleProto(ctx, afterSale.AfterSaleNo),
+	}, nil
+}
+
+// RejectAfterSale 管理员拒绝售后：已提交/买家已寄回（验货不通过） -> 已拒绝
+func (s *OrderService) RejectAfterSale(ctx context.Context, req *orderv1.RejectAfterSaleRequest) (*orderv1.RejectAfterSaleResponse, error) {
+	err := s.afterSaleRepo.UpdateAfterSaleStatus(ctx, req.AfterSaleNo, []int8{model.AfterSaleStatusSubmitted, model.AfterSaleStatusReturning}, model.AfterSaleStatusRejected, map[string]interface{}{
+		"audit_remark": req.Reason,
+		"operator_id":  middleware.GetUserIDFromContext(ctx),
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &orderv1.RejectAfterSaleResponse{
+				Code:    1,
+				Message: "售后单不存在或当前状态不允许拒绝",
+			}, nil
+		}
+		log.Printf("❌ [OrderService] RejectAfterSale: 更新售后单失败: afterSaleNo=%s, err=%v", req.AfterSaleNo, err)
+		return &orderv1.RejectAfterSaleResponse{
+			Code:    1,
+			Message: "拒绝售后单失败",
+		}, nil
+	}
+
+	log.Printf("✅ [OrderService] RejectAfterSale: 售后单已拒绝: afterSaleNo=%s, reason=%s", req.AfterSaleNo, req.Reason)
+	return &orderv1.RejectAfterSaleResponse{
+		Code:      0,
+		Message:   "已拒绝",
+		AfterSale: s.latestAfterSaleProto(ctx, req.AfterSaleNo),
+	}, nil
+}
+
+// ConfirmAfterSaleReceived 管理员确认收到退货：买家已寄回 -> 商家已收货
+// 退货退款在收货后回补库存并退款；换货由商家补发商品，售后单直接完成
+// 退货退款的售后单已收货但退款未发起成功时，再次调用会重试退款
+func (s *OrderService) ConfirmAfterSaleReceived(ctx context.Context, req *orderv1.ConfirmAfterSaleReceivedRequest) (*orderv1.ConfirmAfterSaleReceivedResponse, error) {
+	afterSale, err := s.afterSaleRepo.GetAfterSaleByNo(ctx, req.AfterSaleNo)
+	if err != nil || afterSale == nil {
+		if err != nil {
+			log.Printf("❌ [OrderService] ConfirmAfterSaleReceived: 查询售后单失败: afterSaleNo=%s, err=%v", req.AfterSaleNo, err)
+		}
+		return &orderv1.ConfirmAfterSaleReceivedResponse{
+			Code:    1,
+			Message: "售后单不存在",
+		}, nil
+	}
+
+	switch {
+	case afterSale.Status == model.AfterSaleStatusReturning:
+		toStatus := model.AfterSaleStatusReceived
+		if afterSale.Type == model.AfterSaleTypeExchange {
+			toStatus = model.AfterSaleStatusCompleted
+		}
+		now := time.Now()
+		fields := map[string]interface{}{
+			"operator_id": middleware.GetUserIDFromContext(ctx),
+			"received_at": &now,
+		}
+		if req.Remark != "" {
+			fields["audit_remark"] = req.Remark
+		}
+		err := s.afterSaleRepo.UpdateAfterSaleStatus(ctx, afterSale.AfterSaleNo, []int8{model.AfterSaleStatusReturning}, toStatus, fields)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return &orderv1.ConfirmAfterSaleReceivedResponse{
+					Code:    1,
+					Message: "售后单状态已变更，请刷新后重试",
+				}, nil
+			}
+			log.Printf("❌ [OrderService] ConfirmAfterSaleReceived: 更新售后单失败: afterSaleNo=%s, err=%v", afterSale.AfterSaleNo, err)
+			return &orderv1.ConfirmAfterSaleReceivedResponse{
+				Code:    1,
+				Message: "确认收货失败",
+			}, nil
+		}
+		log.Printf("✅ [OrderService] ConfirmAfterSaleReceived: 已收到退货: afterSaleNo=%s, type=%d", afterSale.AfterSaleNo, afterSale.Type)
+	case afterSale.Status == model.AfterSaleStatusReceived && afterSale.Type == model.AfterSaleTypeReturnRefund:
+		log.Printf("ℹ️ [OrderService] ConfirmAfterSaleReceived: 售后单已收货，重试退款: afterSaleNo=%s", afterSale.AfterSaleNo)
+	default:
+		return &orderv1.ConfirmAfterSaleReceivedResponse{
+			Code:    1,
+			Message: "当前售后状态不允许确认收货",
+		}, nil
+	}
+
+	if afterSale.Type == model.AfterSaleTypeReturnRefund {
+		if err := s.settleAfterSale(ctx, afterSale, model.AfterSaleStatusReceived); err != nil {
+			log.Printf("❌ [OrderService] ConfirmAfterSaleReceived: 售后退款失败: afterSaleNo=%s, err=%v", afterSale.AfterSaleNo, err)
+			return &orderv1.ConfirmAfterSaleReceivedResponse{
+				Code:      1,
+				Message:   fmt.Sprintf("已确认收货，但退款发起失败，请稍后重试: %v", err),
+				AfterSale: s.latestAfterSaleProto(ctx, afterSale.AfterSaleNo),
+			}, nil
+		}
+	}
+
+	return &orderv1.ConfirmAfterSaleReceivedResponse{
+		Code:      0,
+		Message:   "确认收货成功",
+		AfterSale: s.latestAfterSaleProto(ctx, afterSale.AfterSaleNo),
+	}, nil
+}
+
+// settleAfterSale 售后结算：回补库存并向支付服务申请部分退款，售后单 fromStatus -> 退款中（余额退款同步成功时直接置为已退款）
+// 回补库存与退款均以售后单号作为幂等键，失败后可安全重试
+func (s *OrderService) settleAfterSale(ctx context.Context, afterSale *model.AfterSale, fromStatus int8) error {
+	if s.paymentClient == nil {
+		return fmt.Errorf("支付服务不可用")
+	}
+
+	order, orderItems, err := s.orderRepo.GetOrderByNoNoUser(ctx, afterSale.OrderNo)
+	if err != nil {
+		return fmt.Errorf("查询订单失败: %w", err)
+	}
+
+	// 1. 回补库存：退货退款收到退货后回补；仅退款仅在订单未发货时回补（商品未出库）
+	restock := afterSale.Type == model.AfterSaleTypeReturnRefund
+	if afterSale.Type == model.AfterSaleTypeRefundOnly {
+		restock = order.Status == OrderStatusPaid
+	}
+	if restock {
+		// 退货回到原发货仓库
+		var warehouseID string
+		for _, item := range orderItems {
+			if item.ID == afterSale.OrderItemID {
+				warehouseID = item.WarehouseID
+				break
+			}
+		}
+		items := []*inventoryv1.SkuQuantity{{SkuId: afterSale.SKUID, Quantity: int64(afterSale.Quantity), WarehouseId: warehouseID}}
+		if err := s.inventoryClient.RollbackStock(ctx, afterSale.AfterSaleNo, items); err != nil {
+			return fmt.Errorf("回补库存失败: %w", err)
+		}
+	}
+
+	// 2. 申请退款（以订单所属用户身份，按售后单号幂等）；子订单的支付单挂在主订单上
+	payOrderNo := afterSale.OrderNo
+	if order.ParentOrderNo != "" {
+		payOrderNo = order.ParentOrderNo
+	}
+	reason := fmt.Sprintf("售后单%s：%s", afterSale.AfterSaleNo, afterSale.Reason)
+	refund, err := s.paymentClient.CreateRefund(ctx, afterSale.UserID, payOrderNo, fmt.Sprintf("%.2f", afterSale.RefundAmount), reason, afterSale.AfterSaleNo)
+	if err != nil {
+		return err
+	}
+
+	toStatus := model.AfterSaleStatusRefunding
+	fields := map[string]interface{}{"refund_no": refund.RefundNo}
+	switch refund.Status {
+	case paymentv1.RefundStatus_REFUND_STATUS_SUCCESS:
+		toStatus = model.AfterSaleStatusRefunded
+		now := time.Now()
+		fields["refunded_at"] = &now
+	case paymentv1.RefundStatus_REFUND_STATUS_PROCESSING:
+	default:
+		return fmt.Errorf("退款失败: refund_no=%s, %s", refund.RefundNo, refund.ErrorMessage)
+	}
+
+	if err := s.afterSaleRepo.UpdateAfterSaleStatus(ctx, afterSale.AfterSaleNo, []int8{fromStatus}, toStatus, fields); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// 退款事件先于本次更新到达，售后单已被推进
+			log.Printf("ℹ️ [OrderService] settleAfterSale: 售后单状态已变更，忽略: afterSaleNo=%s", afterSale.AfterSaleNo)
+			return nil
+		}
+		return fmt.Errorf("更新售后单状态失败: %w", err)
+	}
+
+	if toStatus == model.AfterSaleStatusRefunded {
+		s.adjustInvoiceForRefund(ctx, afterSale.OrderNo, afterSale.AfterSaleNo, afterSale.RefundAmount)
+	}
+
+	log.Printf("✅ [OrderService] settleAfterSale: 售后退款已发起: afterSaleNo=%s, refundNo=%s, amount=%.2f, restock=%v",
+		afterSale.AfterSaleNo, refund.RefundNo, afterSale.RefundAmount, restock)
+	return nil
+}
+
+// handleAfterSaleRefundEvent 根据退款事件推进售后单状态（退款单的业务请求号为售后单号）
+// 退款成功：退款中 -> 已退款；退款失败：退款中 -> 退款前的状态，由管理员重试
+func (s *OrderService) handleAfterSaleRefundEvent(ctx context.Context, evt *RefundEvent) error {
+	if evt.RequestNo == "" || (evt.EventType != RefundEventSucceeded && evt.EventType != RefundEventFailed) {
+		return nil
+	}
+	afterSale, err := s.afterSaleRepo.GetAfterSaleByNo(ctx, evt.RequestNo)
+	if err != nil {
+		return fmt.Errorf("查询售后单失败: %w", err)
+	}
+	if afterSale == nil {
+		return nil
+	}
+
+	settleStatus := model.AfterSaleStatusApproved
+	if afterSale.Type == model.AfterSaleTypeReturnRefund {
+		settleStatus = model.AfterSaleStatusReceived
+	}
+
+	if evt.EventType == RefundEventSucceeded {
+		now := time.Now()
+		err = s.afterSaleRepo.UpdateAfterSaleStatus(ctx, afterSale.AfterSaleNo, []int8{settleStatus, model.AfterSaleStatusRefunding}, model.AfterSaleStatusRefunded, map[string]interface{}{
+			"refund_no":   evt.RefundNo,
+			"refunded_at": &now,
+		})
+	} else {
+		err = s.afterSaleRepo.UpdateAfterSaleStatus(ctx, afterSale.AfterSaleNo, []int8{model.AfterSaleStatusRefunding}, settleStatus, nil)
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("ℹ️ [OrderService] HandleRefundEvent: 售后单状态已变更，忽略本次事件: afterSaleNo=%s, event=%s", afterSale.AfterSaleNo, evt.EventType)
+			return nil
+		}
+		return fmt.Errorf("更新售后单退款状态失败: %w", err)
+	}
+	if evt.EventType == RefundEventSucceeded {
+		s.adjustInvoiceForRefund(ctx, afterSale.OrderNo, afterSale.AfterSaleNo, afterSale.RefundAmount)
+	}
+	log.Printf("✅ [OrderService] HandleRefundEvent: 售后单退款状态已更新: afterSaleNo=%s, refundNo=%s, event=%s", afterSale.AfterSaleNo, evt.RefundNo, evt.EventType)
+	return nil
+}
+
+// latestAfterSaleProto 重新读取售后单用于返回，读取失败时返回 nil
+func (s *OrderService) latestAfterSaleProto(ctx context.Context, afterSaleNo string) *orderv1.AfterSale {
+	afterSale, err := s.afterSaleRepo.GetAfterSaleByNo(ctx, afterSaleNo)
+	if err != nil || afterSale == nil {
+		return nil
+	}
+	return convertAfterSaleToProto(afterSale)
+}
+
+// afterSaleNoGenerator 生成售后单号，格式与订单号一致：{前缀(2位)}{日期时间(12位)}{随机数(6位)}{扩展位(2位)}
+func afterSaleNoGenerator() string {
+	date := time.Now().Format("200601021504")
+	return fmt.Sprintf("%s%s%06d00", model.AfterSaleNoPrefix, date, rand.Intn(1000000))
+}
+
+func convertAfterSaleToProto(a *model.AfterSale) *orderv1.AfterSale {
+	res := &orderv1.AfterSale{
+		AfterSaleNo:      a.AfterSaleNo,
+		OrderNo:          a.OrderNo,
+		OrderItemId:      a.OrderItemID,
+		UserId:           a.UserID,
+		SkuId:            a.SKUID,
+		Type:             orderv1.AfterSaleType(a.Type),
+		Status:           orderv1.AfterSaleStatus(a.Status),
+		Quantity:         a.Quantity,
+		Reason:           a.Reason,
+		RefundAmount:     fmt.Sprintf("%.2f", a.RefundAmount),
+		RefundNo:         a.RefundNo,
+		ReturnCarrier:    a.ReturnCarrier,
+		ReturnTrackingNo: a.ReturnTrackingNo,
+		AuditRemark:      a.AuditRemark,
+		CreatedAt:        timestamppb.New(a.CreatedAt),
+	}
+	if a.EvidenceImages != "" {
+		_ = json.Unmarshal([]byte(a.EvidenceImages), &res.EvidenceImages)
+	}
+	if a.ApprovedAt != nil {
+		res.ApprovedAt = timestamppb.New(*a.ApprovedAt)
+	}
+	if a.ReturnedAt != nil {
+		res.ReturnedAt = timestamppb.New(*a.ReturnedAt)
+	}
+	if a.ReceivedAt != nil {
+		res.ReceivedAt = timestamppb.New(*a.ReceivedAt)
+	}
+	if a.RefundedAt != nil {
+		res.RefundedAt = timestamppb.New(*a.RefundedAt)
+	}
+	return res
+}
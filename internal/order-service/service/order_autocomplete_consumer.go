@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// StartOrderAutoCompleteConsumer 启动自动确认收货消息消费者（处理发货后的延迟消息）
+func StartOrderAutoCompleteConsumer(ctx context.Context, orderService *OrderService, ch *amqp.Channel, queueName string) {
+	if ch == nil {
+		log.Println("⚠️ [OrderAutoCompleteConsumer] RabbitMQ Channel 为 nil，跳过消费者启动（将依赖补偿机制）")
+		return
+	}
+
+	log.Printf("✅ [OrderAutoCompleteConsumer] 启动自动确认收货消息消费者，队列=%s", queueName)
+
+	msgs, err := ch.Consume(
+		queueName, // queue
+		"",        // consumer
+		false,     // autoAck（手动确认）
+		false,     // exclusive
+		false,     // noLocal
+		false,     // noWait
+		nil,       // args
+	)
+	if err != nil {
+		log.Printf("❌ [OrderAutoCompleteConsumer] 注册消费者失败: %v", err)
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("ℹ️ [OrderAutoCompleteConsumer] 自动确认收货消费者退出")
+			return
+		case msg, ok := <-msgs:
+			if !ok {
+				log.Println("⚠️ [OrderAutoCompleteConsumer] 消息通道已关闭")
+				return
+			}
+
+			// 内部处理重试与放弃，始终 Ack 避免死循环
+			if err := handleOrderAutoCompleteMessage(ctx, orderService, ch, queueName, msg); err != nil {
+				log.Printf("❌ [OrderAutoCompleteConsumer] 处理自动确认收货消息失败: %v", err)
+			}
+			_ = msg.Ack(false)
+		}
+	}
+}
+
+// handleOrderAutoCompleteMessage 处理自动确认收货消息
+// 失败时：未达重试上限则 Republish 后返回；达到上限则记录日志后返回（补偿扫描兜底）
+func handleOrderAutoCompleteMessage(ctx context.Context, orderService *OrderService, ch *amqp.Channel, queueName string, msg amqp.Delivery) error {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(msg.Body, &payload); err != nil {
+		return fmt.Errorf("解析消息失败: %w", err)
+	}
+
+	orderNo, ok := payload["order_no"].(string)
+	if !ok || orderNo == "" {
+		return fmt.Errorf("消息中缺少 order_no 字段")
+	}
+
+	retryCount := 0
+	if v, ok := payload["retry_count"].(float64); ok {
+		retryCount = int(v)
+	}
+
+	log.Printf("ℹ️ [OrderAutoCompleteConsumer] 收到自动确认收货消息: orderNo=%s, retryCount=%d", orderNo, retryCount)
+
+	if err := orderService.HandleOrderAutoComplete(ctx, orderNo); err != nil {
+		if retryCount >= orderTimeoutConsumerMaxRetries {
+			return fmt.Errorf("自动确认收货失败，已达最大重试次数 %d，放弃: orderNo=%s: %w", orderTimeoutConsumerMaxRetries, orderNo, err)
+		}
+		// Republish 并递增 retry_count
+		payload["retry_count"] = retryCount + 1
+		body, _ := json.Marshal(payload)
+		if pubErr := ch.PublishWithContext(ctx, "", queueName, false, false, amqp.Publishing{
+			DeliveryMode: amqp.Persistent,
+			ContentType:  "application/json",
+			Body:         body,
+		}); pubErr != nil {
+			return fmt.Errorf("Republish 失败: %w (原错误: %v)", pubErr, err)
+		}
+		log.Printf("⚠️ [OrderAutoCompleteConsumer] 处理失败，已 Republish 重试: orderNo=%s, retryCount=%d->%d, err=%v", orderNo, retryCount, retryCount+1, err)
+		return fmt.Errorf("处理失败已重试: %w", err)
+	}
+
+	return nil
+}
+
+// StartOrderAutoCompleteCompensation 启动自动确认收货补偿机制（定期扫描发货超时未确认收货的订单）
+// 作为延迟消息的兜底方案
+func StartOrderAutoCompleteCompensation(ctx context.Context, orderService *OrderService, scanInterval time.Duration) {
+	log.Printf("✅ [OrderAutoCompleteCompensation] 启动自动确认收货补偿机制，扫描间隔=%v", scanInterval)
+
+	ticker := time.NewTicker(scanInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("ℹ️ [OrderAutoCompleteCompensation] 自动确认收货补偿机制退出")
+			return
+		case <-ticker.C:
+			if err := scanAndCompleteShippedOrders(ctx, orderService); err != nil {
+				log.Printf("⚠️ [OrderAutoCompleteCompensation] 扫描待自动确认收货订单失败: %v", err)
+			}
+		}
+	}
+}
+
+// scanAndCompleteShippedOrders 扫描并自动完成发货超时的订单
+func scanAndCompleteShippedOrders(ctx context.Context, orderService *OrderService) error {
+	shippedBefore := time.Now().Add(-orderService.orderAutoCompleteDelay)
+	orders, err := orderService.orderRepo.GetShippedOrdersBefore(ctx, OrderStatusShipped, shippedBefore, 100)
+	if err != nil {
+		return fmt.Errorf("查询待自动确认收货订单失败: %w", err)
+	}
+	if len(orders) == 0 {
+		return nil
+	}
+
+	log.Printf("ℹ️ [OrderAutoCompleteCompensation] 发现 %d 个待自动确认收货订单，开始处理", len(orders))
+
+	successCount := 0
+	failCount := 0
+	for _, order := range orders {
+		if err := orderService.HandleOrderAutoComplete(ctx, order.OrderNo); err != nil {
+			log.Printf("⚠️ [OrderAutoCompleteCompensation] 自动确认收货失败: orderNo=%s, err=%v", order.OrderNo, err)
+			failCount++
+		} else {
+			successCount++
+		}
+	}
+
+	log.Printf("✅ [OrderAutoCompleteCompensation] 扫描完成: 成功=%d, 失败=%d, 总计=%d", successCount, failCount, len(orders))
+	return nil
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+	promotionv1 "zjMall/gen/go/api/proto/promotion"
+	"zjMall/internal/order-service/model"
+)
+
+// orderDiscount 下单时的优惠计算结果
+type orderDiscount struct {
+	PromotionDiscount float64
+	CouponDiscount    float64
+	TotalDiscount     float64
+	CouponID          string                          // 实际使用的优惠券ID（未使用则为空）
+	LineDiscounts     []float64                       // 每个商品行分摊的优惠，与请求 items 顺序一致
+	Promotions        []*promotionv1.AppliedPromotion // 实际生效的促销活动及优惠金额
+}
+
+// hasDiscount 是否需要向促销服务锁定优惠（核销优惠券/占用促销配额）
+func (d *orderDiscount) hasDiscount() bool {
+	return d != nil && (d.CouponID != "" || len(d.Promotions) > 0)
+}
+
+// lineDiscount 获取第 i 个商品行分摊的优惠金额
+func (d *orderDiscount) lineDiscount(i int) float64 {
+	if d == nil || i >= len(d.LineDiscounts) {
+		return 0
+	}
+	return d.LineDiscounts[i]
+}
+
+// calculateOrderDiscount 调用促销服务计算订单优惠
+// lines 需与 CreateOrderRequest.Items 顺序一致，返回的行级分摊按相同顺序对应
+func (s *OrderService) calculateOrderDiscount(ctx context.Context, userID, couponID string, promotionIDs []string, lines []*promotionv1.CartItem) (*orderDiscount, error) {
+	if s.promotionClient == nil {
+		if couponID != "" || len(promotionIDs) > 0 {
+			return nil, fmt.Errorf("促销服务不可用，暂不支持使用优惠")
+		}
+		// 未接入促销服务时，不享受任何优惠
+		return &orderDiscount{LineDiscounts: make([]float64, len(lines))}, nil
+	}
+
+	detail, err := s.promotionClient.CalculateDiscount(ctx, userID, couponID, promotionIDs, lines)
+	if err != nil {
+		return nil, err
+	}
+	if len(detail.LineDiscounts) != len(lines) {
+		return nil, fmt.Errorf("优惠分摊明细与商品行不一致: expected=%d, got=%d", len(lines), len(detail.LineDiscounts))
+	}
+
+	result := &orderDiscount{
+		PromotionDiscount: detail.PromotionDiscount,
+		CouponDiscount:    detail.CouponDiscount,
+		TotalDiscount:     detail.TotalDiscount,
+		LineDiscounts:     make([]float64, len(lines)),
+		Promotions:        detail.PromotionDiscounts,
+	}
+	if detail.AppliedCoupon != nil {
+		result.CouponID = detail.AppliedCoupon.Id
+	}
+	for i, line := range detail.LineDiscounts {
+		result.LineDiscounts[i] = line.DiscountAmount
+	}
+	return result, nil
+}
+
+// applyOrderDiscount 锁定订单优惠（核销优惠券并占用促销配额），以订单号作为幂等键
+// 锁定前先写入待绑定的优惠占用记录，创建订单时在同一事务中绑定；
+// 订单最终未创建时由 StartDiscountClaimCompensation 按订单号归还优惠
+// totalAmount 为促销前的商品总金额，促销服务按促销后金额校验优惠券使用条件（与优惠计算口径一致）
+func (s *OrderService) applyOrderDiscount(ctx context.Context, userID, orderNo string, totalAmount float64, discount *orderDiscount) error {
+	if !discount.hasDiscount() {
+		return nil
+	}
+	claim := &model.OrderDiscountClaim{
+		OrderNo:  orderNo,
+		UserID:   userID,
+		CouponID: discount.CouponID,
+		Status:   model.DiscountClaimStatusPending,
+	}
+	if err := s.orderRepo.CreateDiscountClaim(ctx, claim); err != nil {
+		return fmt.Errorf("记录订单优惠占用失败: %w", err)
+	}
+	return s.promotionClient.ApplyOrderDiscount(ctx, userID, orderNo, discount.CouponID, totalAmount-discount.PromotionDiscount, discount.Promotions)
+}
+
+// releaseOrderDiscount 释放订单占用的优惠（归还优惠券、回退促销配额）
+// 订单取消、超时关闭或创建失败时调用，促销服务按订单号幂等处理
+func (s *OrderService) releaseOrderDiscount(ctx context.Context, order *model.Order) {
+	if s.promotionClient == nil || order == nil {
+		return
+	}
+	if order.CouponID == "" && order.DiscountAmount <= 0 {
+		return
+	}
+	if err := s.promotionClient.ReleaseOrderDiscount(ctx, order.UserID, order.OrderNo); err != nil {
+		log.Printf("❌ [OrderService] releaseOrderDiscount: 释放订单优惠失败: orderNo=%s, err=%v", order.OrderNo, err)
+		// 记录告警，促销服务按订单号幂等，可人工或补偿任务重试
+		return
+	}
+	log.Printf("✅ [OrderService] releaseOrderDiscount: 订单优惠已释放: orderNo=%s, couponID=%s", order.OrderNo, order.CouponID)
+}
+
+// discountClaimOrphanAge 优惠占用记录超过该时长仍未绑定订单，视为下单失败，归还优惠
+const discountClaimOrphanAge = 5 * time.Minute
+
+// StartDiscountClaimCompensation 启动订单优惠归还补偿机制（定期扫描未绑定订单的优惠占用记录）
+// 下单锁定优惠后订单创建失败、进程崩溃或即时释放失败时，确保优惠券和促销配额最终被归还
+func StartDiscountClaimCompensation(ctx context.Context, orderService *OrderService, scanInterval time.Duration) {
+	log.Printf("✅ [DiscountClaimCompensation] 启动订单优惠归还补偿机制，扫描间隔=%v", scanInterval)
+
+	ticker := time.NewTicker(scanInterval)
+	defer ticker.Stop()
+
+	// 启动时立即执行一次扫描
+	if err := scanAndReleaseDiscountClaims(ctx, orderService); err != nil {
+		log.Printf("⚠️ [DiscountClaimCompensation] 首次扫描失败: %v", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("ℹ️ [DiscountClaimCompensation] 订单优惠归还补偿机制退出")
+			return
+		case <-ticker.C:
+			if err := scanAndReleaseDiscountClaims(ctx, orderService); err != nil {
+				log.Printf("⚠️ [DiscountClaimCompensation] 扫描优惠占用记录失败: %v", err)
+			}
+		}
+	}
+}
+
+// scanAndReleaseDiscountClaims 扫描长时间未绑定订单的优惠占用记录并归还优惠
+func scanAndReleaseDiscountClaims(ctx context.Context, orderService *OrderService) error {
+	if orderService.promotionClient == nil {
+		return nil
+	}
+
+	claims, err := orderService.orderRepo.ListPendingDiscountClaims(ctx, time.Now().Add(-discountClaimOrphanAge), 100)
+	if err != nil {
+		return fmt.Errorf("查询待绑定的优惠占用记录失败: %w", err)
+	}
+	if len(claims) == 0 {
+		return nil
+	}
+
+	log.Printf("ℹ️ [DiscountClaimCompensation] 发现 %d 条未绑定订单的优惠占用记录，开始归还", len(claims))
+
+	successCount := 0
+	failCount := 0
+
+	for _, claim := range claims {
+		if err := orderService.releaseDiscountClaim(ctx, claim); err != nil {
+			log.Printf("⚠️ [DiscountClaimCompensation] 归还订单优惠失败: orderNo=%s, err=%v", claim.OrderNo, err)
+			failCount++
+		} else {
+			successCount++
+		}
+	}
+
+	log.Printf("✅ [DiscountClaimCompensation] 扫描完成: 成功=%d, 失败=%d, 总计=%d", successCount, failCount, len(claims))
+	return nil
+}
+
+// releaseDiscountClaim 归还未绑定订单的优惠占用
+// 先将记录置为已归还，使仍在进行中的下单事务绑定失败并回滚，再调用促销服务释放；
+// 释放失败时恢复为待绑定，等待下次扫描重试
+func (s *OrderService) releaseDiscountClaim(ctx context.Context, claim *model.OrderDiscountClaim) error {
+	ok, err := s.orderRepo.TransitDiscountClaim(ctx, claim.OrderNo, model.DiscountClaimStatusPending, model.DiscountClaimStatusReleased)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		// 订单已在事务中绑定（或已被其他实例处理），无需归还
+		return nil
+	}
+
+	if err := s.promotionClient.ReleaseOrderDiscount(ctx, claim.UserID, claim.OrderNo); err != nil {
+		if _, revertErr := s.orderRepo.TransitDiscountClaim(ctx, claim.OrderNo, model.DiscountClaimStatusReleased, model.DiscountClaimStatusPending); revertErr != nil {
+			log.Printf("❌ [OrderService] releaseDiscountClaim: 恢复优惠占用记录失败: orderNo=%s, err=%v", claim.OrderNo, revertErr)
+		}
+		return err
+	}
+	log.Printf("✅ [OrderService] releaseDiscountClaim: 未创建订单的优惠已归还: orderNo=%s, couponID=%s", claim.OrderNo, claim.CouponID)
+	return nil
+}
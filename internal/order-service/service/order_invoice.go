@@ -0,0 +1,348 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+	orderv1 "zjMall/gen/go/api/proto/order"
+	userv1 "zjMall/gen/go/api/proto/user"
+	"zjMall/internal/common/middleware"
+	"zjMall/internal/order-service/model"
+	"zjMall/internal/order-service/repository"
+	"zjMall/internal/order-service/statemachine"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+)
+
+const (
+	invoiceListDefaultPageSize = 20
+	invoiceListMaxPageSize     = 100
+)
+
+// buildOrderInvoices 按开票订单生成发票（拆单时按子订单开具，由各商家分别开票），应付金额为 0 的订单不开票
+func buildOrderInvoices(title *userv1.InvoiceTitle, payOrder *model.Order, orders []*model.Order) []*model.OrderInvoice {
+	invoices := make([]*model.OrderInvoice, 0, len(orders))
+	for _, o := range orders {
+		if o.PayAmount <= 0 {
+			continue
+		}
+		invoices = append(invoices, &model.OrderInvoice{
+			OrderNo:    o.OrderNo,
+			PayOrderNo: payOrder.OrderNo,
+			UserID:     payOrder.UserID,
+			TitleType:  int8(title.TitleType),
+			Title:      title.Title,
+			TaxNo:      title.TaxNo,
+			Email:      title.Email,
+			Amount:     o.PayAmount,
+			Status:     model.InvoiceStatusPending,
+		})
+	}
+	return invoices
+}
+
+// markInvoicesIssuable 订单完成后发票 待开票 -> 可开票
+func (s *OrderService) markInvoicesIssuable(ctx context.Context, t *statemachine.Transition) error {
+	invoices, err := s.invoiceRepo.ListInvoicesByOrderNos(ctx, []string{t.OrderNo})
+	if err != nil {
+		log.Printf("⚠️ [OrderService] markInvoicesIssuable: 查询订单发票失败: orderNo=%s, err=%v", t.OrderNo, err)
+		return nil
+	}
+	for _, invoice := range invoices {
+		if invoice.Status != model.InvoiceStatusPending {
+			continue
+		}
+		s.transitInvoice(ctx, invoice, []int8{model.InvoiceStatusPending}, model.InvoiceStatusIssuable, map[string]interface{}{
+			"remark": "订单已完成，可开票",
+		})
+	}
+	return nil
+}
+
+// voidOrderInvoices 订单取消或超时关闭后作废支付订单下的全部发票（此时尚未支付，发票不可能已开具）
+func (s *OrderService) voidOrderInvoices(ctx context.Context, t *statemachine.Transition) error {
+	invoices, err := s.invoiceRepo.ListInvoicesByPayOrderNo(ctx, t.OrderNo)
+	if err != nil {
+		log.Printf("⚠️ [OrderService] voidOrderInvoices: 查询订单发票失败: orderNo=%s, err=%v", t.OrderNo, err)
+		return nil
+	}
+	now := time.Now()
+	for _, invoice := range invoices {
+		if invoice.Status != model.InvoiceStatusPending {
+			continue
+		}
+		s.transitInvoice(ctx, invoice, []int8{model.InvoiceStatusPending}, model.InvoiceStatusVoided, map[string]interface{}{
+			"voided_at": &now,
+			"remark":    fmt.Sprintf("订单%s", orderStatusText(t.To)),
+		})
+	}
+	return nil
+}
+
+// settleInvoicesForRefund 订单全额退款后：未开具的发票作废，已开具的发票红冲
+func (s *OrderService) settleInvoicesForRefund(ctx context.Context, t *statemachine.Transition) error {
+	invoices, err := s.invoiceRepo.ListInvoicesByPayOrderNo(ctx, t.OrderNo)
+	if err != nil {
+		log.Printf("⚠️ [OrderService] settleInvoicesForRefund: 查询订单发票失败: orderNo=%s, err=%v", t.OrderNo, err)
+		return nil
+	}
+	now := time.Now()
+	for _, invoice := range invoices {
+		switch invoice.Status {
+		case model.InvoiceStatusPending, model.InvoiceStatusIssuable:
+			s.transitInvoice(ctx, invoice, []int8{model.InvoiceStatusPending, model.InvoiceStatusIssuable}, model.InvoiceStatusVoided, map[string]interface{}{
+				"voided_at": &now,
+				"remark":    "订单已全额退款",
+			})
+		case model.InvoiceStatusIssued:
+			s.redFlushInvoice(ctx, invoice, "订单已全额退款", nil)
+		}
+	}
+	return nil
+}
+
+// adjustInvoiceForRefund 售后部分退款成功后调整开票订单的有效发票：
+// 未开具时扣减开票金额（扣减至 0 时作废）；已开具时红冲原发票，并按剩余金额生成新的可开票发票
+// 售后单状态已推进后调用，失败只记录告警，由财务人工处理
+func (s *OrderService) adjustInvoiceForRefund(ctx context.Context, orderNo, afterSaleNo string, refundAmount float64) {
+	invoices, err := s.invoiceRepo.ListInvoicesByOrderNos(ctx, []string{orderNo})
+	if err != nil {
+		log.Printf("⚠️ [OrderService] adjustInvoiceForRefund: 查询订单发票失败: orderNo=%s, err=%v", orderNo, err)
+		return
+	}
+	// 同一开票订单同一时间只有一张有效发票（红冲重开后旧发票为已红冲）
+	var invoice *model.OrderInvoice
+	for _, inv := range invoices {
+		if inv.Status == model.InvoiceStatusPending || inv.Status == model.InvoiceStatusIssuable || inv.Status == model.InvoiceStatusIssued {
+			invoice = inv
+		}
+	}
+	if invoice == nil {
+		return
+	}
+
+	remark := fmt.Sprintf("售后单%s退款%.2f", afterSaleNo, refundAmount)
+	remaining := float64(int64(math.Round(invoice.Amount*100))-int64(math.Round(refundAmount*100))) / 100
+	if invoice.Status == model.InvoiceStatusIssued {
+		var reissue *model.OrderInvoice
+		if remaining > 0 {
+			reissue = &model.OrderInvoice{
+				OrderNo:    invoice.OrderNo,
+				PayOrderNo: invoice.PayOrderNo,
+				UserID:     invoice.UserID,
+				TitleType:  invoice.TitleType,
+				Title:      invoice.Title,
+				TaxNo:      invoice.TaxNo,
+				Email:      invoice.Email,
+				Amount:     remaining,
+				Status:     model.InvoiceStatusIssuable,
+				Remark:     fmt.Sprintf("原发票%s红冲后按剩余金额重开", invoice.InvoiceNumber),
+			}
+		}
+		s.redFlushInvoice(ctx, invoice, remark, reissue)
+		return
+	}
+
+	fromStatuses := []int8{invoice.Status}
+	if remaining <= 0 {
+		now := time.Now()
+		s.transitInvoice(ctx, invoice, fromStatuses, model.InvoiceStatusVoided, map[string]interface{}{
+			"voided_at": &now,
+			"remark":    remark,
+		})
+		return
+	}
+	s.transitInvoice(ctx, invoice, fromStatuses, invoice.Status, map[string]interface{}{
+		"amount": remaining,
+		"remark": remark,
+	})
+}
+
+// transitInvoice 流转发票状态，状态已变更时忽略，失败只记录告警
+func (s *OrderService) transitInvoice(ctx context.Context, invoice *model.OrderInvoice, fromStatuses []int8, toStatus int8, fields map[string]interface{}) {
+	if err := s.invoiceRepo.UpdateInvoiceStatus(ctx, invoice.ID, fromStatuses, toStatus, fields); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("ℹ️ [OrderService] 发票状态已变更，忽略: invoiceID=%s, orderNo=%s", invoice.ID, invoice.OrderNo)
+			return
+		}
+		log.Printf("⚠️ [OrderService] 更新发票状态失败: invoiceID=%s, orderNo=%s, %d -> %d, err=%v", invoice.ID, invoice.OrderNo, invoice.Status, toStatus, err)
+		return
+	}
+	log.Printf("✅ [OrderService] 发票状态已更新: invoiceID=%s, orderNo=%s, %d -> %d", invoice.ID, invoice.OrderNo, invoice.Status, toStatus)
+}
+
+// redFlushInvoice 红冲已开具的发票（reissue 不为空时同时生成重开的发票记录）
+func (s *OrderService) redFlushInvoice(ctx context.Context, invoice *model.OrderInvoice, remark string, reissue *model.OrderInvoice) {
+	now := time.Now()
+	err := s.invoiceRepo.RedFlushInvoice(ctx, invoice.ID, map[string]interface{}{
+		"red_flushed_at": &now,
+		"remark":         remark,
+	}, reissue)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("ℹ️ [OrderService] 发票状态已变更，忽略红冲: invoiceID=%s, orderNo=%s", invoice.ID, invoice.OrderNo)
+			return
+		}
+		log.Printf("⚠️ [OrderService] 发票红冲失败: invoiceID=%s, orderNo=%s, err=%v", invoice.ID, invoice.OrderNo, err)
+		return
+	}
+	log.Printf("✅ [OrderService] 发票已红冲: invoiceID=%s, invoiceNumber=%s, orderNo=%s, reissue=%v", invoice.ID, invoice.InvoiceNumber, invoice.OrderNo, reissue != nil)
+}
+
+// AdminListInvoices 管理员查询发票（游标分页）
+func (s *OrderService) AdminListInvoices(ctx context.Context, req *orderv1.AdminListInvoicesRequest) (*orderv1.AdminListInvoicesResponse, error) {
+	pageSize := int(req.PageSize)
+	if pageSize <= 0 {
+		pageSize = invoiceListDefaultPageSize
+	}
+	if pageSize > invoiceListMaxPageSize {
+		pageSize = invoiceListMaxPageSize
+	}
+
+	filter := repository.InvoiceFilter{
+		OrderNo: req.OrderNo,
+		UserID:  req.UserId,
+		Status:  int8(req.Status),
+	}
+	// 多查一条用于判断是否还有下一页
+	invoices, err := s.invoiceRepo.SearchInvoices(ctx, filter, req.Cursor, pageSize+1)
+	if err != nil {
+		log.Printf("❌ [OrderService] AdminListInvoices: 查询发票失败: err=%v", err)
+		return &orderv1.AdminListInvoicesResponse{
+			Code:    1,
+			Message: "查询发票失败",
+		}, nil
+	}
+
+	var nextCursor string
+	if len(invoices) > pageSize {
+		invoices = invoices[:pageSize]
+		nextCursor = invoices[pageSize-1].ID
+	}
+	return &orderv1.AdminListInvoicesResponse{
+		Code:       0,
+		Message:    "查询成功",
+		Invoices:   convertInvoicesToProto(invoices),
+		NextCursor: nextCursor,
+	}, nil
+}
+
+// IssueInvoice 管理员登记发票号码：可开票 -> 已开票
+func (s *OrderService) IssueInvoice(ctx context.Context, req *orderv1.IssueInvoiceRequest) (*orderv1.IssueInvoiceResponse, error) {
+	invoice, err := s.invoiceRepo.GetInvoiceByID(ctx, req.InvoiceId)
+	if err != nil {
+		log.Printf("❌ [OrderService] IssueInvoice: 查询发票失败: invoiceID=%s, err=%v", req.InvoiceId, err)
+		return &orderv1.IssueInvoiceResponse{
+			Code:    1,
+			Message: "开票失败",
+		}, nil
+	}
+	if invoice == nil {
+		return &orderv1.IssueInvoiceResponse{
+			Code:    1,
+			Message: "发票不存在",
+		}, nil
+	}
+	switch invoice.Status {
+	case model.InvoiceStatusIssuable:
+	case model.InvoiceStatusPending:
+		return &orderv1.IssueInvoiceResponse{
+			Code:    1,
+			Message: "订单尚未完成，暂不能开票",
+		}, nil
+	default:
+		return &orderv1.IssueInvoiceResponse{
+			Code:    1,
+			Message: fmt.Sprintf("发票当前状态为%s，不能开票", invoiceStatusText(invoice.Status)),
+		}, nil
+	}
+
+	now := time.Now()
+	err = s.invoiceRepo.UpdateInvoiceStatus(ctx, invoice.ID, []int8{model.InvoiceStatusIssuable}, model.InvoiceStatusIssued, map[string]interface{}{
+		"invoice_number": req.InvoiceNumber,
+		"operator_id":    middleware.GetUserIDFromContext(ctx),
+		"issued_at":      &now,
+		"remark":         "已开票",
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &orderv1.IssueInvoiceResponse{
+				Code:    1,
+				Message: "发票状态已变更，请刷新后重试",
+			}, nil
+		}
+		log.Printf("❌ [OrderService] IssueInvoice: 更新发票失败: invoiceID=%s, err=%v", req.InvoiceId, err)
+		return &orderv1.IssueInvoiceResponse{
+			Code:    1,
+			Message: "开票失败",
+		}, nil
+	}
+	log.Printf("✅ [OrderService] IssueInvoice: 发票已开具: invoiceID=%s, orderNo=%s, invoiceNumber=%s", invoice.ID, invoice.OrderNo, req.InvoiceNumber)
+
+	if latest, err := s.invoiceRepo.GetInvoiceByID(ctx, invoice.ID); err == nil && latest != nil {
+		invoice = latest
+	}
+	return &orderv1.IssueInvoiceResponse{
+		Code:    0,
+		Message: "开票成功",
+		Invoice: convertInvoiceToProto(invoice),
+	}, nil
+}
+
+// invoiceStatusText 发票状态描述
+func invoiceStatusText(status int8) string {
+	switch status {
+	case model.InvoiceStatusPending:
+		return "待开票"
+	case model.InvoiceStatusIssuable:
+		return "可开票"
+	case model.InvoiceStatusIssued:
+		return "已开票"
+	case model.InvoiceStatusRedFlushed:
+		return "已红冲"
+	case model.InvoiceStatusVoided:
+		return "已作废"
+	default:
+		return fmt.Sprintf("状态%d", status)
+	}
+}
+
+func convertInvoicesToProto(invoices []*model.OrderInvoice) []*orderv1.OrderInvoice {
+	res := make([]*orderv1.OrderInvoice, 0, len(invoices))
+	for _, invoice := range invoices {
+		res = append(res, convertInvoiceToProto(invoice))
+	}
+	return res
+}
+
+func convertInvoiceToProto(invoice *model.OrderInvoice) *orderv1.OrderInvoice {
+	res := &orderv1.OrderInvoice{
+		Id:            invoice.ID,
+		OrderNo:       invoice.OrderNo,
+		PayOrderNo:    invoice.PayOrderNo,
+		UserId:        invoice.UserID,
+		TitleType:     int32(invoice.TitleType),
+		Title:         invoice.Title,
+		TaxNo:         invoice.TaxNo,
+		Email:         invoice.Email,
+		Amount:        fmt.Sprintf("%.2f", invoice.Amount),
+		Status:        orderv1.InvoiceStatus(invoice.Status),
+		InvoiceNumber: invoice.InvoiceNumber,
+		Remark:        invoice.Remark,
+		CreatedAt:     timestamppb.New(invoice.CreatedAt),
+	}
+	if invoice.IssuedAt != nil {
+		res.IssuedAt = timestamppb.New(*invoice.IssuedAt)
+	}
+	if invoice.RedFlushedAt != nil {
+		res.RedFlushedAt = timestamppb.New(*invoice.RedFlushedAt)
+	}
+	if invoice.VoidedAt != nil {
+		res.VoidedAt = timestamppb.New(*invoice.VoidedAt)
+	}
+	return res
+}
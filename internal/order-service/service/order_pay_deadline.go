@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	orderv1 "zjMall/gen/go/api/proto/order"
+	"zjMall/internal/order-service/model"
+	"zjMall/internal/order-service/statemachine"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultOrderPayTimeout   = 30 * time.Minute // 普通订单默认支付时限
+	DefaultSeckillPayTimeout = 10 * time.Minute // 秒杀订单默认支付时限（名额紧张，未支付的名额尽快释放）
+)
+
+// normalizePayTimeouts 补齐未配置的订单类型支付时限
+func normalizePayTimeouts(payTimeouts map[string]time.Duration) map[string]time.Duration {
+	res := map[string]time.Duration{
+		model.OrderTypeNormal:  DefaultOrderPayTimeout,
+		model.OrderTypeSeckill: DefaultSeckillPayTimeout,
+	}
+	for orderType, timeout := range payTimeouts {
+		if timeout > 0 {
+			res[orderType] = timeout
+		}
+	}
+	return res
+}
+
+// payDeadlineFor 按订单类型计算支付截止时间
+func (s *OrderService) payDeadlineFor(orderType string, from time.Time) time.Time {
+	timeout, ok := s.payTimeouts[orderType]
+	if !ok {
+		timeout = s.payTimeouts[model.OrderTypeNormal]
+	}
+	return from.Add(timeout)
+}
+
+// orderPayDeadline 订单的支付截止时间（未记录截止时间的历史订单按创建时间 + 普通订单支付时限计算）
+func (s *OrderService) orderPayDeadline(order *model.Order) time.Time {
+	if order.PayDeadline != nil {
+		return *order.PayDeadline
+	}
+	return s.payDeadlineFor(model.OrderTypeNormal, order.CreatedAt)
+}
+
+// ExtendPayDeadline 管理员延长待支付订单的支付截止时间，并重新投递超时关闭消息
+// 已投递的旧超时消息到期时，HandleOrderTimeout 会因未到新的截止时间而跳过
+func (s *OrderService) ExtendPayDeadline(ctx context.Context, req *orderv1.ExtendPayDeadlineRequest) (*orderv1.ExtendPayDeadlineResponse, error) {
+	order, _, err := s.orderRepo.GetOrderByNoNoUser(ctx, req.OrderNo)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &orderv1.ExtendPayDeadlineResponse{
+				Code:    1,
+				Message: "订单不存在",
+			}, nil
+		}
+		log.Printf("❌ [OrderService] ExtendPayDeadline: 查询订单失败: orderNo=%s, err=%v", req.OrderNo, err)
+		return &orderv1.ExtendPayDeadlineResponse{
+			Code:    1,
+			Message: "延长支付时间失败",
+		}, nil
+	}
+
+	// 从当前截止时间起算；已过截止时间（超时关闭尚未执行）时从当前时间起算
+	base := s.orderPayDeadline(order)
+	if now := time.Now(); base.Before(now) {
+		base = now
+	}
+	deadline := base.Add(time.Duration(req.ExtendMinutes) * time.Minute)
+
+	reason := fmt.Sprintf("支付截止时间延长至%s", deadline.Format(time.DateTime))
+	if req.Reason != "" {
+		reason = fmt.Sprintf("%s：%s", reason, req.Reason)
+	}
+	err = s.stateMachine.Fire(ctx, &statemachine.Transition{
+		Event: OrderEventExtendPayDeadline,
+		From:  OrderStatusPendingPay,
+		To:    OrderStatusPendingPay,
+		Fields: map[string]interface{}{
+			"pay_deadline": &deadline,
+		},
+		Reason: reason,
+		Order:  order,
+	})
+	if err != nil {
+		if reason, ok := rejectReason(err); ok {
+			return &orderv1.ExtendPayDeadlineResponse{
+				Code:    1,
+				Message: reason,
+			}, nil
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &orderv1.ExtendPayDeadlineResponse{
+				Code:    1,
+				Message: "订单状态已变更，仅待支付订单可延长支付时间",
+			}, nil
+		}
+		log.Printf("❌ [OrderService] ExtendPayDeadline: 更新支付截止时间失败: orderNo=%s, err=%v", req.OrderNo, err)
+		return &orderv1.ExtendPayDeadlineResponse{
+			Code:    1,
+			Message: "延长支付时间失败",
+		}, nil
+	}
+
+	log.Printf("✅ [OrderService] ExtendPayDeadline: 支付截止时间已延长: orderNo=%s, payDeadline=%s", req.OrderNo, deadline.Format(time.RFC3339))
+	return &orderv1.ExtendPayDeadlineResponse{
+		Code:        0,
+		Message:     "延长成功",
+		PayDeadline: timestamppb.New(deadline),
+	}, nil
+}
+
+// afterPayDeadlineExtended 支付截止时间延长后按新的截止时间重新投递超时关闭消息
+func (s *OrderService) afterPayDeadlineExtended(ctx context.Context, t *statemachine.Transition) error {
+	if deadline, ok := t.Fields["pay_deadline"].(*time.Time); ok && deadline != nil {
+		s.scheduleOrderTimeout(ctx, t.OrderNo, t.Order.UserID, t.Order.PayAmount, *deadline)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"zjMall/internal/order-service/statemachine"
+
+	"gorm.io/gorm"
+)
+
+// 退款事件类型（与支付服务 Outbox 事件类型保持一致）
+const (
+	RefundEventCreated   = "refund.created"
+	RefundEventSucceeded = "refund.succeeded"
+	RefundEventFailed    = "refund.failed"
+)
+
+// RefundEvent 退款事件（从支付服务的 MQ 消息反序列化而来）
+type RefundEvent struct {
+	EventType     string  `json:"event_type"`
+	RefundNo      string  `json:"refund_no"`
+	PaymentNo     string  `json:"payment_no"`
+	OrderNo       string  `json:"order_no"`
+	UserID        string  `json:"user_id"`
+	RequestNo     string  `json:"request_no"` // 业务请求号（售后退款时为售后单号）
+	RefundAmount  float64 `json:"refund_amount"`
+	OrderStatus   int8    `json:"order_status"`   // 申请退款时的订单状态
+	FullRefund    bool    `json:"full_refund"`    // 本次退款完成后支付单无剩余可退金额
+	FullyRefunded bool    `json:"fully_refunded"` // 支付单已全额退款
+}
+
+// HandleRefundEvent 处理退款事件，幂等流转订单状态
+// 整单退款：申请时 已支付/已发货/已完成 -> 退款中，成功后 退款中 -> 已退款，失败后恢复为申请前的状态
+// 部分退款不改变订单状态；携带售后单号的退款同时推进售后单状态
+// 支付与退款均针对主订单，拆单时子订单随主订单同步退款状态（见 refundSubOrders）
+func (s *OrderService) HandleRefundEvent(ctx context.Context, evt *RefundEvent) error {
+	if evt == nil {
+		return fmt.Errorf("退款事件为空")
+	}
+	if evt.OrderNo == "" || evt.RefundNo == "" {
+		return fmt.Errorf("退款事件缺少关键字段: order_no=%s, refund_no=%s", evt.OrderNo, evt.RefundNo)
+	}
+	if err := s.handleAfterSaleRefundEvent(ctx, evt); err != nil {
+		return err
+	}
+
+	switch evt.EventType {
+	case RefundEventCreated:
+		if !evt.FullRefund {
+			return nil
+		}
+		if err := s.transitRefundStatus(ctx, evt, OrderEventRefundApply, evt.OrderStatus, OrderStatusRefunding); err != nil {
+			return err
+		}
+		return s.refundSubOrders(ctx, evt)
+	case RefundEventSucceeded:
+		if !evt.FullyRefunded {
+			return nil
+		}
+		err := s.stateMachine.Fire(ctx, &statemachine.Transition{
+			Event:   OrderEventRefundSucceed,
+			OrderNo: evt.OrderNo,
+			From:    OrderStatusRefunding,
+			To:      OrderStatusRefunded,
+			Reason:  refundReason(evt),
+		})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// 退款中事件尚未到达（或已被跳过），直接从申请前的状态流转为已退款
+			if err := s.transitRefundStatus(ctx, evt, OrderEventRefundSucceed, evt.OrderStatus, OrderStatusRefunded); err != nil {
+				return err
+			}
+			return s.refundSubOrders(ctx, evt)
+		}
+		if err != nil {
+			return fmt.Errorf("更新订单退款状态失败: %w", err)
+		}
+		log.Printf("✅ [OrderService] HandleRefundEvent: 订单已退款: orderNo=%s, refundNo=%s", evt.OrderNo, evt.RefundNo)
+		return s.refundSubOrders(ctx, evt)
+	case RefundEventFailed:
+		if !evt.FullRefund {
+			return nil
+		}
+		if err := s.transitRefundStatus(ctx, evt, OrderEventRefundFail, OrderStatusRefunding, evt.OrderStatus); err != nil {
+			return err
+		}
+		return s.refundSubOrders(ctx, evt)
+	default:
+		log.Printf("⚠️ [OrderService] HandleRefundEvent: 未知的退款事件类型，忽略: %s", evt.EventType)
+		return nil
+	}
+}
+
+// transitRefundStatus 基于 fromStatus + 乐观锁流转订单状态，状态已变更或流转表不允许时作为幂等成功处理
+func (s *OrderService) transitRefundStatus(ctx context.Context, evt *RefundEvent, event statemachine.Event, fromStatus, toStatus int8) error {
+	if fromStatus == 0 {
+		log.Printf("⚠️ [OrderService] HandleRefundEvent: 退款事件缺少订单状态，忽略: refundNo=%s", evt.RefundNo)
+		return nil
+	}
+	err := s.stateMachine.Fire(ctx, &statemachine.Transition{
+		Event:   event,
+		OrderNo: evt.OrderNo,
+		From:    fromStatus,
+		To:      toStatus,
+		Reason:  refundReason(evt),
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, statemachine.ErrTransitionNotAllowed) {
+			log.Printf("⚠️ [OrderService] HandleRefundEvent: 订单状态已变更，忽略本次事件: orderNo=%s, event=%s", evt.OrderNo, evt.EventType)
+			return nil
+		}
+		return fmt.Errorf("更新订单退款状态失败: %w", err)
+	}
+	log.Printf("✅ [OrderService] HandleRefundEvent: 订单状态已更新: orderNo=%s, %d -> %d, event=%s", evt.OrderNo, fromStatus, toStatus, evt.EventType)
+	return nil
+}
+
+// refundReason 退款流转原因：退款单号，售后退款时附带售后单号
+func refundReason(evt *RefundEvent) string {
+	if evt.RequestNo != "" {
+		return fmt.Sprintf("退款单%s（%s），请求号=%s", evt.RefundNo, evt.EventType, evt.RequestNo)
+	}
+	return fmt.Sprintf("退款单%s（%s）", evt.RefundNo, evt.EventType)
+}
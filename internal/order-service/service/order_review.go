@@ -0,0 +1,400 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+	orderv1 "zjMall/gen/go/api/proto/order"
+	"zjMall/internal/common/middleware"
+	"zjMall/internal/order-service/model"
+	"zjMall/internal/order-service/repository"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+)
+
+const (
+	reviewListDefaultPageSize = 20
+	reviewListMaxPageSize     = 100
+)
+
+// CreateReview 买家评价订单商品：订单完成后每个订单明细评价一次，提交后待审核
+func (s *OrderService) CreateReview(ctx context.Context, req *orderv1.CreateReviewRequest) (*orderv1.CreateReviewResponse, error) {
+	userID := middleware.GetUserIDFromContext(ctx)
+	if userID == "" {
+		return &orderv1.CreateReviewResponse{
+			Code:    1,
+			Message: "用户未登录",
+		}, nil
+	}
+
+	order, items, err := s.orderRepo.GetOrderByNo(ctx, userID, req.OrderNo)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &orderv1.CreateReviewResponse{
+				Code:    1,
+				Message: "订单不存在或无权访问",
+			}, nil
+		}
+		log.Printf("❌ [OrderService] CreateReview: 查询订单失败: orderNo=%s, userID=%s, err=%v", req.OrderNo, userID, err)
+		return &orderv1.CreateReviewResponse{
+			Code:    1,
+			Message: "评价失败，请稍后重试",
+		}, nil
+	}
+	// 拆单时商品明细挂在子订单上，主订单不会完成，需按子订单评价
+	if order.Status != OrderStatusCompleted {
+		return &orderv1.CreateReviewResponse{
+			Code:    1,
+			Message: fmt.Sprintf("订单当前状态为%s，完成后才能评价", orderStatusText(order.Status)),
+		}, nil
+	}
+
+	var item *model.OrderItem
+	for _, it := range items {
+		if it.ID == req.OrderItemId {
+			item = it
+			break
+		}
+	}
+	if item == nil {
+		return &orderv1.CreateReviewResponse{
+			Code:    1,
+			Message: "订单商品不存在",
+		}, nil
+	}
+
+	var imagesJSON string
+	if len(req.Images) > 0 {
+		data, _ := json.Marshal(req.Images)
+		imagesJSON = string(data)
+	}
+
+	review := &model.ProductReview{
+		OrderNo:     order.OrderNo,
+		OrderItemID: item.ID,
+		UserID:      userID,
+		ProductID:   item.ProductID,
+		SKUID:       item.SKUID,
+		SKUName:     item.SKUName,
+		Rating:      int8(req.Rating),
+		Content:     req.Content,
+		Images:      imagesJSON,
+		IsAnonymous: req.IsAnonymous,
+		Status:      model.ReviewStatusPending,
+	}
+	if err := s.reviewRepo.CreateReview(ctx, review); err != nil {
+		if errors.Is(err, repository.ErrReviewExists) {
+			return &orderv1.CreateReviewResponse{
+				Code:    1,
+				Message: err.Error(),
+			}, nil
+		}
+		log.Printf("❌ [OrderService] CreateReview: 创建评价失败: orderNo=%s, orderItemID=%s, err=%v", order.OrderNo, item.ID, err)
+		return &orderv1.CreateReviewResponse{
+			Code:    1,
+			Message: "评价失败，请稍后重试",
+		}, nil
+	}
+	log.Printf("✅ [OrderService] CreateReview: 评价已提交待审核: reviewID=%s, orderNo=%s, productID=%s, rating=%d", review.ID, order.OrderNo, item.ProductID, review.Rating)
+
+	return &orderv1.CreateReviewResponse{
+		Code:    0,
+		Message: "评价已提交，审核通过后展示",
+		Review:  convertReviewToProto(review, false),
+	}, nil
+}
+
+// ListProductReviews 查询商品审核通过的评价（匿名评价不返回用户ID），附带评分汇总
+func (s *OrderService) ListProductReviews(ctx context.Context, req *orderv1.ListProductReviewsRequest) (*orderv1.ListProductReviewsResponse, error) {
+	pageSize := reviewPageSize(req.PageSize)
+	filter := repository.ReviewFilter{
+		ProductID: req.ProductId,
+		Status:    model.ReviewStatusApproved,
+		Rating:    int8(req.Rating),
+	}
+	// 多查一条用于判断是否还有下一页
+	reviews, err := s.reviewRepo.SearchReviews(ctx, filter, req.Cursor, pageSize+1)
+	if err != nil {
+		log.Printf("❌ [OrderService] ListProductReviews: 查询评价失败: productID=%s, err=%v", req.ProductId, err)
+		return &orderv1.ListProductReviewsResponse{
+			Code:    1,
+			Message: "查询评价失败",
+		}, nil
+	}
+	counts, err := s.reviewRepo.CountApprovedRatings(ctx, req.ProductId)
+	if err != nil {
+		log.Printf("❌ [OrderService] ListProductReviews: 统计评分失败: productID=%s, err=%v", req.ProductId, err)
+		return &orderv1.ListProductReviewsResponse{
+			Code:    1,
+			Message: "查询评价失败",
+		}, nil
+	}
+
+	var nextCursor string
+	if len(reviews) > pageSize {
+		reviews = reviews[:pageSize]
+		nextCursor = reviews[pageSize-1].ID
+	}
+	return &orderv1.ListProductReviewsResponse{
+		Code:       0,
+		Message:    "查询成功",
+		Reviews:    convertReviewsToProto(reviews, true),
+		NextCursor: nextCursor,
+		Summary:    buildReviewSummary(req.ProductId, counts),
+	}, nil
+}
+
+// AdminListReviews 管理员查询评价（游标分页）
+func (s *OrderService) AdminListReviews(ctx context.Context, req *orderv1.AdminListReviewsRequest) (*orderv1.AdminListReviewsResponse, error) {
+	pageSize := reviewPageSize(req.PageSize)
+	filter := repository.ReviewFilter{
+		ProductID: req.ProductId,
+		OrderNo:   req.OrderNo,
+		Status:    int8(req.Status),
+	}
+	reviews, err := s.reviewRepo.SearchReviews(ctx, filter, req.Cursor, pageSize+1)
+	if err != nil {
+		log.Printf("❌ [OrderService] AdminListReviews: 查询评价失败: err=%v", err)
+		return &orderv1.AdminListReviewsResponse{
+			Code:    1,
+			Message: "查询评价失败",
+		}, nil
+	}
+
+	var nextCursor string
+	if len(reviews) > pageSize {
+		reviews = reviews[:pageSize]
+		nextCursor = reviews[pageSize-1].ID
+	}
+	return &orderv1.AdminListReviewsResponse{
+		Code:       0,
+		Message:    "查询成功",
+		Reviews:    convertReviewsToProto(reviews, false),
+		NextCursor: nextCursor,
+	}, nil
+}
+
+// ModerateReview 管理员审核评价：待审核可通过或驳回，已通过的评价可下架（驳回），已驳回的评价可重新通过
+// 审核通过的评价集合变化后重新计算商品评分并推送到商品服务
+func (s *OrderService) ModerateReview(ctx context.Context, req *orderv1.ModerateReviewRequest) (*orderv1.ModerateReviewResponse, error) {
+	review, err := s.reviewRepo.GetReviewByID(ctx, req.ReviewId)
+	if err != nil {
+		log.Printf("❌ [OrderService] ModerateReview: 查询评价失败: reviewID=%s, err=%v", req.ReviewId, err)
+		return &orderv1.ModerateReviewResponse{
+			Code:    1,
+			Message: "审核失败",
+		}, nil
+	}
+	if review == nil {
+		return &orderv1.ModerateReviewResponse{
+			Code:    1,
+			Message: "评价不存在",
+		}, nil
+	}
+
+	toStatus := model.ReviewStatusRejected
+	fromStatuses := []int8{model.ReviewStatusPending, model.ReviewStatusApproved}
+	if req.Approve {
+		toStatus = model.ReviewStatusApproved
+		fromStatuses = []int8{model.ReviewStatusPending, model.ReviewStatusRejected}
+	}
+	if review.Status == toStatus {
+		return &orderv1.ModerateReviewResponse{
+			Code:    1,
+			Message: fmt.Sprintf("评价已是%s状态", reviewStatusText(toStatus)),
+		}, nil
+	}
+
+	now := time.Now()
+	err = s.reviewRepo.UpdateReviewStatus(ctx, review.ID, fromStatuses, toStatus, map[string]interface{}{
+		"moderation_remark": req.Remark,
+		"moderator_id":      middleware.GetUserIDFromContext(ctx),
+		"moderated_at":      &now,
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &orderv1.ModerateReviewResponse{
+				Code:    1,
+				Message: "评价状态已变更，请刷新后重试",
+			}, nil
+		}
+		log.Printf("❌ [OrderService] ModerateReview: 更新评价失败: reviewID=%s, err=%v", review.ID, err)
+		return &orderv1.ModerateReviewResponse{
+			Code:    1,
+			Message: "审核失败",
+		}, nil
+	}
+	log.Printf("✅ [OrderService] ModerateReview: 评价已审核: reviewID=%s, productID=%s, %s -> %s", review.ID, review.ProductID, reviewStatusText(review.Status), reviewStatusText(toStatus))
+
+	// 待审核 -> 驳回不影响已通过的评价，无需重新计算评分
+	if review.Status == model.ReviewStatusApproved || toStatus == model.ReviewStatusApproved {
+		s.syncProductRating(ctx, review.ProductID)
+	}
+
+	if latest, err := s.reviewRepo.GetReviewByID(ctx, review.ID); err == nil && latest != nil {
+		review = latest
+	}
+	return &orderv1.ModerateReviewResponse{
+		Code:    0,
+		Message: "审核成功",
+		Review:  convertReviewToProto(review, false),
+	}, nil
+}
+
+// ReplyReview 管理员以商家身份回复评价（驳回的评价不展示，不能回复）
+func (s *OrderService) ReplyReview(ctx context.Context, req *orderv1.ReplyReviewRequest) (*orderv1.ReplyReviewResponse, error) {
+	review, err := s.reviewRepo.GetReviewByID(ctx, req.ReviewId)
+	if err != nil {
+		log.Printf("❌ [OrderService] ReplyReview: 查询评价失败: reviewID=%s, err=%v", req.ReviewId, err)
+		return &orderv1.ReplyReviewResponse{
+			Code:    1,
+			Message: "回复失败",
+		}, nil
+	}
+	if review == nil {
+		return &orderv1.ReplyReviewResponse{
+			Code:    1,
+			Message: "评价不存在",
+		}, nil
+	}
+	if review.Status == model.ReviewStatusRejected {
+		return &orderv1.ReplyReviewResponse{
+			Code:    1,
+			Message: "评价已被驳回，不能回复",
+		}, nil
+	}
+
+	if err := s.reviewRepo.SaveReply(ctx, review.ID, req.Content, middleware.GetUserIDFromContext(ctx), time.Now()); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &orderv1.ReplyReviewResponse{
+				Code:    1,
+				Message: "该评价已回复",
+			}, nil
+		}
+		log.Printf("❌ [OrderService] ReplyReview: 保存回复失败: reviewID=%s, err=%v", review.ID, err)
+		return &orderv1.ReplyReviewResponse{
+			Code:    1,
+			Message: "回复失败",
+		}, nil
+	}
+	log.Printf("✅ [OrderService] ReplyReview: 评价已回复: reviewID=%s, productID=%s", review.ID, review.ProductID)
+
+	if latest, err := s.reviewRepo.GetReviewByID(ctx, review.ID); err == nil && latest != nil {
+		review = latest
+	}
+	return &orderv1.ReplyReviewResponse{
+		Code:    0,
+		Message: "回复成功",
+		Review:  convertReviewToProto(review, false),
+	}, nil
+}
+
+// syncProductRating 按审核通过的评价重新计算商品评分并推送到商品服务（写入搜索索引）
+// 评价审核状态已更新后调用，失败只记录告警，下次审核该商品的评价时会再次全量推送
+func (s *OrderService) syncProductRating(ctx context.Context, productID string) {
+	counts, err := s.reviewRepo.CountApprovedRatings(ctx, productID)
+	if err != nil {
+		log.Printf("⚠️ [OrderService] syncProductRating: 统计评分失败: productID=%s, err=%v", productID, err)
+		return
+	}
+	summary := buildReviewSummary(productID, counts)
+	if err := s.productClient.UpdateProductRating(ctx, productID, summary.RatingAvg, summary.ReviewCount, summary.RatingCounts); err != nil {
+		log.Printf("⚠️ [OrderService] syncProductRating: 推送商品评分失败: productID=%s, err=%v", productID, err)
+		return
+	}
+	log.Printf("✅ [OrderService] syncProductRating: 商品评分已同步: productID=%s, avg=%.1f, count=%d", productID, summary.RatingAvg, summary.ReviewCount)
+}
+
+// buildReviewSummary 由各星级评价数计算平均评分（保留 1 位小数）
+func buildReviewSummary(productID string, counts []int64) *orderv1.ReviewSummary {
+	var total, score int64
+	for i, count := range counts {
+		total += count
+		score += int64(i+1) * count
+	}
+	var avg float64
+	if total > 0 {
+		avg = math.Round(float64(score)/float64(total)*10) / 10
+	}
+	return &orderv1.ReviewSummary{
+		ProductId:    productID,
+		RatingAvg:    avg,
+		ReviewCount:  total,
+		RatingCounts: counts,
+	}
+}
+
+func reviewPageSize(pageSize int32) int {
+	size := int(pageSize)
+	if size <= 0 {
+		size = reviewListDefaultPageSize
+	}
+	if size > reviewListMaxPageSize {
+		size = reviewListMaxPageSize
+	}
+	return size
+}
+
+// reviewStatusText 评价审核状态描述
+func reviewStatusText(status int8) string {
+	switch status {
+	case model.ReviewStatusPending:
+		return "待审核"
+	case model.ReviewStatusApproved:
+		return "审核通过"
+	case model.ReviewStatusRejected:
+		return "审核驳回"
+	default:
+		return fmt.Sprintf("状态%d", status)
+	}
+}
+
+func convertReviewsToProto(reviews []*model.ProductReview, public bool) []*orderv1.ProductReview {
+	res := make([]*orderv1.ProductReview, 0, len(reviews))
+	for _, review := range reviews {
+		res = append(res, convertReviewToProto(review, public))
+	}
+	return res
+}
+
+// convertReviewToProto 转换评价（public 为 true 时对外展示：不返回订单与审核信息，匿名评价同时隐藏用户ID）
+func convertReviewToProto(review *model.ProductReview, public bool) *orderv1.ProductReview {
+	var images []string
+	if review.Images != "" {
+		_ = json.Unmarshal([]byte(review.Images), &images)
+	}
+	res := &orderv1.ProductReview{
+		Id:               review.ID,
+		OrderNo:          review.OrderNo,
+		OrderItemId:      review.OrderItemID,
+		UserId:           review.UserID,
+		ProductId:        review.ProductID,
+		SkuId:            review.SKUID,
+		SkuName:          review.SKUName,
+		Rating:           int32(review.Rating),
+		Content:          review.Content,
+		Images:           images,
+		IsAnonymous:      review.IsAnonymous,
+		Status:           orderv1.ReviewStatus(review.Status),
+		ModerationRemark: review.ModerationRemark,
+		Reply:            review.Reply,
+		CreatedAt:        timestamppb.New(review.CreatedAt),
+	}
+	if review.RepliedAt != nil {
+		res.RepliedAt = timestamppb.New(*review.RepliedAt)
+	}
+	if public {
+		res.OrderNo = ""
+		res.OrderItemId = ""
+		res.ModerationRemark = ""
+		if review.IsAnonymous {
+			res.UserId = ""
+		}
+	}
+	return res
+}
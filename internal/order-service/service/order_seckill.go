@@ -0,0 +1,698 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"strconv"
+	"time"
+	inventoryv1 "zjMall/gen/go/api/proto/inventory"
+	orderv1 "zjMall/gen/go/api/proto/order"
+	productv1 "zjMall/gen/go/api/proto/product"
+	"zjMall/internal/common/middleware"
+	"zjMall/internal/order-service/model"
+
+	"github.com/go-redis/redis/v8"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+)
+
+const (
+	SeckillOrderQueue = "order.seckill.queue" // 秒杀异步下单队列
+
+	seckillActivityCacheTTL = 10 * time.Minute   // 活动信息缓存时间
+	seckillResultTTL        = 24 * time.Hour     // 秒杀结果保留时间
+	seckillKeyRetention     = 7 * 24 * time.Hour // 活动结束后 Redis 库存键保留时间（对账后仍需判断活动已结束）
+)
+
+// 秒杀 Redis 键，使用 {活动编号} 作为 hash tag，保证 Lua 脚本涉及的键落在同一 slot
+func seckillActivityKey(activityNo string) string {
+	return fmt.Sprintf("seckill:{%s}:activity", activityNo)
+}
+
+func seckillStockKey(activityNo string) string {
+	return fmt.Sprintf("seckill:{%s}:stock", activityNo)
+}
+
+func seckillUsersKey(activityNo string) string {
+	return fmt.Sprintf("seckill:{%s}:users", activityNo)
+}
+
+func seckillEndedKey(activityNo string) string {
+	return fmt.Sprintf("seckill:{%s}:ended", activityNo)
+}
+
+func seckillResultKey(activityNo, userID string) string {
+	return fmt.Sprintf("seckill:{%s}:result:%s", activityNo, userID)
+}
+
+// seckillDeductScript 原子扣减秒杀名额：校验活动未结束、用户未参与、名额充足，扣减后记录用户并写入排队结果
+// 返回：1-成功，-1-已参与，-2-已抢光，-3-活动已结束，-4-库存未预热
+var seckillDeductScript = redis.NewScript(`
+	if redis.call('EXISTS', KEYS[3]) == 1 then
+		return -3
+	end
+	local stock = tonumber(redis.call('GET', KEYS[1]))
+	if stock == nil then
+		return -4
+	end
+	if redis.call('SISMEMBER', KEYS[2], ARGV[1]) == 1 then
+		return -1
+	end
+	if stock <= 0 then
+		return -2
+	end
+	redis.call('DECR', KEYS[1])
+	redis.call('SADD', KEYS[2], ARGV[1])
+	redis.call('HSET', KEYS[4], 'status', ARGV[3], 'order_no', ARGV[2])
+	redis.call('EXPIRE', KEYS[4], ARGV[4])
+	return 1
+`)
+
+// seckillReleaseScript 归还秒杀名额：活动未结束时名额回到 Redis 库存池（返回 1），已结束时返回 0 由调用方归还 MySQL 库存
+// ARGV[1] 非空时同时移除用户参与记录并写入失败结果（下单失败，允许用户重新抢购）
+var seckillReleaseScript = redis.NewScript(`
+	local released = 0
+	if redis.call('EXISTS', KEYS[2]) == 0 and redis.call('EXISTS', KEYS[1]) == 1 then
+		redis.call('INCR', KEYS[1])
+		released = 1
+	end
+	if ARGV[1] ~= '' then
+		redis.call('SREM', KEYS[3], ARGV[1])
+		redis.call('HSET', KEYS[4], 'status', ARGV[2], 'reason', ARGV[3])
+		redis.call('EXPIRE', KEYS[4], ARGV[4])
+	end
+	return released
+`)
+
+// seckillSettleScript 标记活动已结束并返回剩余名额（库存键不存在时返回 -1）
+// 标记后释放的名额不再回到 Redis，保证对账时读取的剩余名额不再变化
+var seckillSettleScript = redis.NewScript(`
+	redis.call('SET', KEYS[2], 1, 'EX', ARGV[1])
+	local stock = redis.call('GET', KEYS[1])
+	if not stock then
+		return -1
+	end
+	redis.call('EXPIRE', KEYS[1], ARGV[1])
+	redis.call('EXPIRE', KEYS[3], ARGV[1])
+	return tonumber(stock)
+`)
+
+// SeckillOrderMessage 秒杀异步下单消息
+type SeckillOrderMessage struct {
+	ActivityNo  string `json:"activity_no"`
+	SKUID       string `json:"sku_id"`
+	UserID      string `json:"user_id"`
+	OrderNo     string `json:"order_no"` // 抢到名额时预分配的订单号，作为下单幂等键
+	AddressID   string `json:"address_id"`
+	BuyerRemark string `json:"buyer_remark"`
+	RetryCount  int    `json:"retry_count"` // 消费者重试时递增，达到上限后放弃并释放名额
+}
+
+// CreateSeckillActivity 创建秒杀活动：从 MySQL 预扣活动库存（以活动编号为幂等键），预热 Redis 库存后落库
+func (s *OrderService) CreateSeckillActivity(ctx context.Context, req *orderv1.CreateSeckillActivityRequest) (*orderv1.CreateSeckillActivityResponse, error) {
+	price, err := strconv.ParseFloat(req.SeckillPrice, 64)
+	if err != nil || price <= 0 {
+		return &orderv1.CreateSeckillActivityResponse{
+			Code:    1,
+			Message: "秒杀价格式错误",
+		}, nil
+	}
+	startTime := req.StartTime.AsTime()
+	endTime := req.EndTime.AsTime()
+	if !endTime.After(startTime) || !endTime.After(time.Now()) {
+		return &orderv1.CreateSeckillActivityResponse{
+			Code:    1,
+			Message: "活动结束时间必须晚于开始时间和当前时间",
+		}, nil
+	}
+
+	_, skus, err := s.productClient.GetProduct(ctx, req.ProductId)
+	if err != nil {
+		log.Printf("❌ [OrderService] CreateSeckillActivity: 查询商品失败: productID=%s, err=%v", req.ProductId, err)
+		return &orderv1.CreateSeckillActivityResponse{
+			Code:    1,
+			Message: "商品不存在",
+		}, nil
+	}
+	var sku *productv1.SkuInfo
+	for _, it := range skus {
+		if it.Id == req.SkuId {
+			sku = it
+			break
+		}
+	}
+	if sku == nil {
+		return &orderv1.CreateSeckillActivityResponse{
+			Code:    1,
+			Message: fmt.Sprintf("SKU %s 不存在", req.SkuId),
+		}, nil
+	}
+	if price > sku.Price {
+		return &orderv1.CreateSeckillActivityResponse{
+			Code:    1,
+			Message: fmt.Sprintf("秒杀价不能高于商品售价%.2f", sku.Price),
+		}, nil
+	}
+
+	activity := &model.SeckillActivity{
+		ActivityNo:   seckillActivityNoGenerator(),
+		Title:        req.Title,
+		ProductID:    req.ProductId,
+		SKUID:        req.SkuId,
+		SeckillPrice: math.Round(price*100) / 100,
+		Stock:        req.Stock,
+		Status:       model.SeckillActivityStatusOnline,
+		StartTime:    startTime,
+		EndTime:      endTime,
+		OperatorID:   middleware.GetUserIDFromContext(ctx),
+	}
+
+	// 1. 预扣 MySQL 库存，活动期间普通订单不会占用秒杀库存
+	reserveItems := []*inventoryv1.SkuQuantity{{SkuId: activity.SKUID, Quantity: int64(activity.Stock)}}
+	allocations, err := s.inventoryClient.DeductStock(ctx, activity.ActivityNo, reserveItems)
+	if err != nil {
+		log.Printf("❌ [OrderService] CreateSeckillActivity: 预扣库存失败: skuID=%s, err=%v", activity.SKUID, err)
+		return &orderv1.CreateSeckillActivityResponse{
+			Code:    1,
+			Message: fmt.Sprintf("预扣库存失败: %v", err),
+		}, nil
+	}
+	if len(allocations) > 0 {
+		activity.WarehouseID = allocations[0].WarehouseId
+	}
+
+	// 2. 预热 Redis 库存，保留到活动结束后一段时间，供对账与名额归还判断
+	stockTTL := time.Until(endTime) + seckillKeyRetention
+	if err := s.redisClient.SetNX(ctx, seckillStockKey(activity.ActivityNo), activity.Stock, stockTTL).Err(); err != nil {
+		log.Printf("❌ [OrderService] CreateSeckillActivity: 预热秒杀库存失败，归还库存: activityNo=%s, err=%v", activity.ActivityNo, err)
+		s.rollbackSeckillReserve(ctx, activity)
+		return &orderv1.CreateSeckillActivityResponse{
+			Code:    1,
+			Message: "系统繁忙，请稍后重试",
+		}, nil
+	}
+
+	// 3. 落库
+	if err := s.seckillRepo.CreateActivity(ctx, activity); err != nil {
+		log.Printf("❌ [OrderService] CreateSeckillActivity: 创建秒杀活动失败，归还库存: activityNo=%s, err=%v", activity.ActivityNo, err)
+		s.redisClient.Del(ctx, seckillStockKey(activity.ActivityNo))
+		s.rollbackSeckillReserve(ctx, activity)
+		return &orderv1.CreateSeckillActivityResponse{
+			Code:    1,
+			Message: "创建秒杀活动失败",
+		}, nil
+	}
+
+	log.Printf("✅ [OrderService] CreateSeckillActivity: 秒杀活动创建成功: activityNo=%s, skuID=%s, stock=%d, %s ~ %s",
+		activity.ActivityNo, activity.SKUID, activity.Stock, startTime.Format(time.RFC3339), endTime.Format(time.RFC3339))
+	return &orderv1.CreateSeckillActivityResponse{
+		Code:     0,
+		Message:  "创建成功",
+		Activity: s.convertSeckillActivityToProto(ctx, activity),
+	}, nil
+}
+
+// GetSeckillActivity 查询秒杀活动（进行中的活动从 Redis 读取剩余名额）
+func (s *OrderService) GetSeckillActivity(ctx context.Context, req *orderv1.GetSeckillActivityRequest) (*orderv1.GetSeckillActivityResponse, error) {
+	activity, err := s.seckillRepo.GetActivityByNo(ctx, req.ActivityNo)
+	if err != nil {
+		log.Printf("❌ [OrderService] GetSeckillActivity: 查询秒杀活动失败: activityNo=%s, err=%v", req.ActivityNo, err)
+		return &orderv1.GetSeckillActivityResponse{
+			Code:    1,
+			Message: "查询秒杀活动失败",
+		}, nil
+	}
+	if activity == nil {
+		return &orderv1.GetSeckillActivityResponse{
+			Code:    1,
+			Message: "秒杀活动不存在",
+		}, nil
+	}
+	return &orderv1.GetSeckillActivityResponse{
+		Code:     0,
+		Message:  "查询成功",
+		Activity: s.convertSeckillActivityToProto(ctx, activity),
+	}, nil
+}
+
+// Seckill 参与秒杀：Lua 原子扣减名额（每人限购 1 件），抢到名额后投递异步下单消息
+func (s *OrderService) Seckill(ctx context.Context, req *orderv1.SeckillRequest) (*orderv1.SeckillResponse, error) {
+	userID := middleware.GetUserIDFromContext(ctx)
+	if userID == "" {
+		return &orderv1.SeckillResponse{
+			Code:    1,
+			Message: "用户未登录",
+		}, nil
+	}
+	if s.seckillProducer == nil {
+		return &orderv1.SeckillResponse{
+			Code:    1,
+			Message: "秒杀服务暂不可用",
+		}, nil
+	}
+
+	activity, err := s.getSeckillActivity(ctx, req.ActivityNo)
+	if err != nil {
+		log.Printf("❌ [OrderService] Seckill: 查询秒杀活动失败: activityNo=%s, err=%v", req.ActivityNo, err)
+		return &orderv1.SeckillResponse{
+			Code:    1,
+			Message: "系统繁忙，请稍后重试",
+		}, nil
+	}
+	if activity == nil {
+		return &orderv1.SeckillResponse{
+			Code:    1,
+			Message: "秒杀活动不存在",
+		}, nil
+	}
+	now := time.Now()
+	if now.Before(activity.StartTime) {
+		return &orderv1.SeckillResponse{
+			Code:    1,
+			Message: "秒杀活动尚未开始",
+		}, nil
+	}
+	if !now.Before(activity.EndTime) || activity.Status != model.SeckillActivityStatusOnline {
+		return &orderv1.SeckillResponse{
+			Code:    1,
+			Message: "秒杀活动已结束",
+		}, nil
+	}
+
+	orderNo := orderNoGenerator(model.OrderTypeSeckill)
+	keys := []string{
+		seckillStockKey(activity.ActivityNo),
+		seckillUsersKey(activity.ActivityNo),
+		seckillEndedKey(activity.ActivityNo),
+		seckillResultKey(activity.ActivityNo, userID),
+	}
+	result, err := seckillDeductScript.Run(ctx, s.redisClient, keys,
+		userID, orderNo, int(orderv1.SeckillResultStatus_SECKILL_RESULT_STATUS_QUEUING), int(seckillResultTTL.Seconds())).Int64()
+	if err != nil {
+		log.Printf("❌ [OrderService] Seckill: 扣减秒杀名额失败: activityNo=%s, userID=%s, err=%v", activity.ActivityNo, userID, err)
+		return &orderv1.SeckillResponse{
+			Code:    1,
+			Message: "系统繁忙，请稍后重试",
+		}, nil
+	}
+	switch result {
+	case 1:
+	case -1:
+		return &orderv1.SeckillResponse{
+			Code:    1,
+			Message: "每人限购1件，请勿重复抢购",
+		}, nil
+	case -2:
+		return &orderv1.SeckillResponse{
+			Code:    1,
+			Message: "已抢光",
+		}, nil
+	case -3:
+		return &orderv1.SeckillResponse{
+			Code:    1,
+			Message: "秒杀活动已结束",
+		}, nil
+	default:
+		log.Printf("❌ [OrderService] Seckill: 秒杀库存未预热: activityNo=%s, result=%d", activity.ActivityNo, result)
+		return &orderv1.SeckillResponse{
+			Code:    1,
+			Message: "秒杀活动未就绪，请稍后重试",
+		}, nil
+	}
+
+	msg := &SeckillOrderMessage{
+		ActivityNo:  activity.ActivityNo,
+		SKUID:       activity.SKUID,
+		UserID:      userID,
+		OrderNo:     orderNo,
+		AddressID:   req.AddressId,
+		BuyerRemark: req.BuyerRemark,
+	}
+	if err := s.seckillProducer.SendMessage(ctx, SeckillOrderQueue, msg); err != nil {
+		log.Printf("❌ [OrderService] Seckill: 投递秒杀下单消息失败，释放名额: orderNo=%s, err=%v", orderNo, err)
+		s.failSeckillOrder(ctx, msg, "系统繁忙，请稍后重试")
+		return &orderv1.SeckillResponse{
+			Code:    1,
+			Message: "系统繁忙，请稍后重试",
+		}, nil
+	}
+
+	log.Printf("✅ [OrderService] Seckill: 抢购成功，订单排队创建中: activityNo=%s, userID=%s, orderNo=%s", activity.ActivityNo, userID, orderNo)
+	return &orderv1.SeckillResponse{
+		Code:    0,
+		Message: "抢购成功，订单创建中",
+		OrderNo: orderNo,
+		Status:  orderv1.SeckillResultStatus_SECKILL_RESULT_STATUS_QUEUING,
+	}, nil
+}
+
+// GetSeckillResult 查询当前用户的秒杀结果
+func (s *OrderService) GetSeckillResult(ctx context.Context, req *orderv1.GetSeckillResultRequest) (*orderv1.GetSeckillResultResponse, error) {
+	userID := middleware.GetUserIDFromContext(ctx)
+	if userID == "" {
+		return &orderv1.GetSeckillResultResponse{
+			Code:    1,
+			Message: "用户未登录",
+		}, nil
+	}
+	fields, err := s.redisClient.HGetAll(ctx, seckillResultKey(req.ActivityNo, userID)).Result()
+	if err != nil {
+		log.Printf("❌ [OrderService] GetSeckillResult: 查询秒杀结果失败: activityNo=%s, userID=%s, err=%v", req.ActivityNo, userID, err)
+		return &orderv1.GetSeckillResultResponse{
+			Code:    1,
+			Message: "系统繁忙，请稍后重试",
+		}, nil
+	}
+	if len(fields) == 0 {
+		return &orderv1.GetSeckillResultResponse{
+			Code:    0,
+			Message: "未参与该秒杀活动或结果已过期",
+			Status:  orderv1.SeckillResultStatus_SECKILL_RESULT_STATUS_UNSPECIFIED,
+		}, nil
+	}
+	status, _ := strconv.Atoi(fields["status"])
+	resp := &orderv1.GetSeckillResultResponse{
+		Code:    0,
+		Message: "查询成功",
+		Status:  orderv1.SeckillResultStatus(status),
+		Reason:  fields["reason"],
+	}
+	if resp.Status != orderv1.SeckillResultStatus_SECKILL_RESULT_STATUS_FAILED {
+		resp.OrderNo = fields["order_no"]
+	}
+	return resp, nil
+}
+
+// HandleSeckillOrder 异步创建秒杀订单（按预分配的订单号幂等）
+// 秒杀库存已在活动创建时从 MySQL 预扣，这里不再扣减库存；返回错误时由消费者重试，重试耗尽后释放名额
+func (s *OrderService) HandleSeckillOrder(ctx context.Context, msg *SeckillOrderMessage) error {
+	if _, _, err := s.orderRepo.GetOrderByNoNoUser(ctx, msg.OrderNo); err == nil {
+		s.markSeckillOrderCreated(ctx, msg)
+		return nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("查询订单失败: %w", err)
+	}
+
+	activity, err := s.seckillRepo.GetActivityByNo(ctx, msg.ActivityNo)
+	if err != nil {
+		return fmt.Errorf("查询秒杀活动失败: %w", err)
+	}
+	if activity == nil {
+		s.failSeckillOrder(ctx, msg, "秒杀活动不存在")
+		return nil
+	}
+
+	// 以下单用户身份查询收货地址
+	userCtx := context.WithValue(ctx, middleware.UserIDKey, msg.UserID)
+	userAddress, err := s.userClient.GetUserAddress(userCtx, msg.AddressID)
+	if err != nil {
+		return fmt.Errorf("获取用户地址失败: %w", err)
+	}
+	receiverAddress := fmt.Sprintf("%s%s%s%s", userAddress.Province, userAddress.City, userAddress.District, userAddress.Detail)
+
+	product, skus, err := s.productClient.GetProduct(ctx, activity.ProductID)
+	if err != nil {
+		return fmt.Errorf("查询商品失败: %w", err)
+	}
+	var sku *productv1.SkuInfo
+	for _, it := range skus {
+		if it.Id == activity.SKUID {
+			sku = it
+			break
+		}
+	}
+	if sku == nil {
+		s.failSeckillOrder(ctx, msg, "商品已下架")
+		return nil
+	}
+
+	shippingAmount, err := s.productClient.CalculateFreight(ctx, userAddress.Province, userAddress.City, []*productv1.FreightItem{
+		{SkuId: activity.SKUID, Quantity: 1},
+	})
+	if err != nil {
+		return fmt.Errorf("计算运费失败: %w", err)
+	}
+
+	itemInput := &orderv1.CreateOrderItemInput{ProductId: activity.ProductID, SkuId: activity.SKUID, Quantity: 1}
+	itemSnapshotJSON, err := s.generateItemDetailSnapshot(itemInput, product.Title, product.MainImage, sku.Name, activity.SeckillPrice, 0, receiverAddress)
+	if err != nil {
+		log.Printf("⚠️ [OrderService] HandleSeckillOrder: 生成商品快照失败: %v", err)
+		itemSnapshotJSON = ""
+	}
+	itemsSnapshotJSON, err := s.generateItemsSnapshot([]ItemBasicSnapshot{{
+		ProductID:      activity.ProductID,
+		SKUID:          activity.SKUID,
+		ProductTitle:   product.Title,
+		SKUName:        sku.Name,
+		Price:          fmt.Sprintf("%.2f", activity.SeckillPrice),
+		Quantity:       1,
+		DiscountAmount: "0.00",
+		Subtotal:       fmt.Sprintf("%.2f", activity.SeckillPrice),
+	}})
+	if err != nil {
+		log.Printf("⚠️ [OrderService] HandleSeckillOrder: 生成订单快照失败: %v", err)
+		itemsSnapshotJSON = ""
+	}
+
+	payAmount := activity.SeckillPrice + shippingAmount
+	payDeadline := s.payDeadlineFor(model.OrderTypeSeckill, time.Now())
+	order := &model.Order{
+		OrderNo:           msg.OrderNo,
+		UserID:            msg.UserID,
+		Status:            OrderStatusPendingPay,
+		TotalAmount:       activity.SeckillPrice,
+		ShippingAmount:    shippingAmount,
+		PayAmount:         payAmount,
+		MerchantID:        product.MerchantId,
+		SeckillActivityNo: activity.ActivityNo,
+		BuyerRemark:       msg.BuyerRemark,
+		ReceiverName:      userAddress.ReceiverName,
+		ReceiverPhone:     userAddress.ReceiverPhone,
+		ReceiverAddress:   receiverAddress,
+		ItemsSnapshot:     itemsSnapshotJSON,
+		PayDeadline:       &payDeadline,
+		Version:           1,
+	}
+	items := []*model.OrderItem{{
+		OrderNo:      msg.OrderNo,
+		UserID:       msg.UserID,
+		ProductID:    activity.ProductID,
+		SKUID:        activity.SKUID,
+		ProductTitle: product.Title,
+		ProductImage: product.MainImage,
+		SKUName:      sku.Name,
+		Price:        activity.SeckillPrice,
+		Quantity:     1,
+		Subtotal:     activity.SeckillPrice,
+		WarehouseID:  activity.WarehouseID,
+		ItemSnapshot: itemSnapshotJSON,
+	}}
+	if err := s.orderRepo.CreateOrder(ctx, order, items, nil); err != nil {
+		if isDuplicateKeyError(err) {
+			// 重复投递的消息已被并发处理
+			s.markSeckillOrderCreated(ctx, msg)
+			return nil
+		}
+		return fmt.Errorf("创建秒杀订单失败: %w", err)
+	}
+
+	log.Printf("✅ [OrderService] HandleSeckillOrder: 秒杀订单创建成功: activityNo=%s, orderNo=%s, userID=%s", msg.ActivityNo, msg.OrderNo, msg.UserID)
+	s.markSeckillOrderCreated(ctx, msg)
+	s.scheduleOrderTimeout(ctx, msg.OrderNo, msg.UserID, payAmount, payDeadline)
+	return nil
+}
+
+// markSeckillOrderCreated 记录秒杀下单成功结果
+func (s *OrderService) markSeckillOrderCreated(ctx context.Context, msg *SeckillOrderMessage) {
+	key := seckillResultKey(msg.ActivityNo, msg.UserID)
+	pipe := s.redisClient.TxPipeline()
+	pipe.HSet(ctx, key, "status", int(orderv1.SeckillResultStatus_SECKILL_RESULT_STATUS_SUCCESS), "order_no", msg.OrderNo)
+	pipe.Expire(ctx, key, seckillResultTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("⚠️ [OrderService] markSeckillOrderCreated: 写入秒杀结果失败: orderNo=%s, err=%v", msg.OrderNo, err)
+	}
+}
+
+// failSeckillOrder 秒杀下单失败：释放名额并记录失败结果（用户可重新抢购）
+// 活动已结束（已对账）时名额无法回到 Redis，按订单号将库存归还 MySQL
+func (s *OrderService) failSeckillOrder(ctx context.Context, msg *SeckillOrderMessage, reason string) {
+	keys := []string{
+		seckillStockKey(msg.ActivityNo),
+		seckillEndedKey(msg.ActivityNo),
+		seckillUsersKey(msg.ActivityNo),
+		seckillResultKey(msg.ActivityNo, msg.UserID),
+	}
+	released, err := seckillReleaseScript.Run(ctx, s.redisClient, keys,
+		msg.UserID, int(orderv1.SeckillResultStatus_SECKILL_RESULT_STATUS_FAILED), reason, int(seckillResultTTL.Seconds())).Int64()
+	if err != nil {
+		log.Printf("❌ [OrderService] failSeckillOrder: 释放秒杀名额失败: orderNo=%s, err=%v", msg.OrderNo, err)
+		return
+	}
+	if released == 0 {
+		// 按订单号回补时没有该订单的扣减记录，需显式指定活动预扣库存的仓库
+		var warehouseID string
+		if activity, err := s.getSeckillActivity(ctx, msg.ActivityNo); err != nil {
+			log.Printf("⚠️ [OrderService] failSeckillOrder: 查询秒杀活动失败，回补到默认仓: activityNo=%s, err=%v", msg.ActivityNo, err)
+		} else if activity != nil {
+			warehouseID = activity.WarehouseID
+		}
+		items := []*inventoryv1.SkuQuantity{{SkuId: msg.SKUID, Quantity: 1, WarehouseId: warehouseID}}
+		if err := s.inventoryClient.RollbackStock(ctx, msg.OrderNo, items); err != nil {
+			log.Printf("❌ [OrderService] failSeckillOrder: 归还库存失败: orderNo=%s, err=%v", msg.OrderNo, err)
+		}
+	}
+	log.Printf("⚠️ [OrderService] failSeckillOrder: 秒杀下单失败，名额已释放: activityNo=%s, orderNo=%s, reason=%s", msg.ActivityNo, msg.OrderNo, reason)
+}
+
+// returnSeckillStock 秒杀订单取消或超时关闭时，活动未结束则将名额归还 Redis 库存池并返回 true
+// 返回 false 时（非秒杀订单或活动已对账）由调用方按订单号回滚 MySQL 库存；用户参与记录保留，不可再次抢购
+func (s *OrderService) returnSeckillStock(ctx context.Context, order *model.Order) bool {
+	if order == nil || order.SeckillActivityNo == "" {
+		return false
+	}
+	keys := []string{
+		seckillStockKey(order.SeckillActivityNo),
+		seckillEndedKey(order.SeckillActivityNo),
+		seckillUsersKey(order.SeckillActivityNo),
+		seckillResultKey(order.SeckillActivityNo, order.UserID),
+	}
+	released, err := seckillReleaseScript.Run(ctx, s.redisClient, keys, "", 0, "", 0).Int64()
+	if err != nil {
+		log.Printf("⚠️ [OrderService] returnSeckillStock: 归还秒杀名额失败，改为回滚库存: orderNo=%s, err=%v", order.OrderNo, err)
+		return false
+	}
+	if released == 1 {
+		log.Printf("✅ [OrderService] returnSeckillStock: 秒杀名额已归还: activityNo=%s, orderNo=%s", order.SeckillActivityNo, order.OrderNo)
+	}
+	return released == 1
+}
+
+// settleSeckillActivity 活动结束后库存对账：标记活动结束，按 Redis 剩余名额将未售出的库存归还 MySQL
+// 归还库存以活动编号为幂等键，失败后可安全重试
+func (s *OrderService) settleSeckillActivity(ctx context.Context, activity *model.SeckillActivity) error {
+	keys := []string{
+		seckillStockKey(activity.ActivityNo),
+		seckillEndedKey(activity.ActivityNo),
+		seckillUsersKey(activity.ActivityNo),
+	}
+	remaining, err := seckillSettleScript.Run(ctx, s.redisClient, keys, int(seckillKeyRetention.Seconds())).Int64()
+	if err != nil {
+		return fmt.Errorf("读取秒杀剩余名额失败: %w", err)
+	}
+	if remaining < 0 {
+		return fmt.Errorf("秒杀库存键不存在，需人工对账: activityNo=%s", activity.ActivityNo)
+	}
+	if remaining > int64(activity.Stock) {
+		remaining = int64(activity.Stock)
+	}
+
+	if remaining > 0 {
+		items := []*inventoryv1.SkuQuantity{{SkuId: activity.SKUID, Quantity: remaining}}
+		if err := s.inventoryClient.RollbackStock(ctx, activity.ActivityNo, items); err != nil {
+			return fmt.Errorf("归还未售出库存失败: %w", err)
+		}
+	}
+
+	soldCount := activity.Stock - int32(remaining)
+	if err := s.seckillRepo.MarkActivitySettled(ctx, activity.ActivityNo, soldCount, time.Now()); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return fmt.Errorf("更新活动对账状态失败: %w", err)
+	}
+	s.redisClient.Del(ctx, seckillActivityKey(activity.ActivityNo))
+	log.Printf("✅ [OrderService] settleSeckillActivity: 秒杀活动对账完成: activityNo=%s, stock=%d, sold=%d, returned=%d", activity.ActivityNo, activity.Stock, soldCount, remaining)
+	return nil
+}
+
+// StartSeckillSettlement 启动秒杀活动库存对账任务（定期扫描已结束未对账的活动）
+func StartSeckillSettlement(ctx context.Context, orderService *OrderService, scanInterval time.Duration) {
+	log.Printf("✅ [SeckillSettlement] 启动秒杀活动库存对账任务，扫描间隔=%v", scanInterval)
+
+	ticker := time.NewTicker(scanInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("ℹ️ [SeckillSettlement] 秒杀活动库存对账任务退出")
+			return
+		case <-ticker.C:
+			activities, err := orderService.seckillRepo.ListEndedActivities(ctx, time.Now(), 100)
+			if err != nil {
+				log.Printf("⚠️ [SeckillSettlement] 查询待对账活动失败: %v", err)
+				continue
+			}
+			for _, activity := range activities {
+				if err := orderService.settleSeckillActivity(ctx, activity); err != nil {
+					log.Printf("❌ [SeckillSettlement] 秒杀活动对账失败: activityNo=%s, err=%v", activity.ActivityNo, err)
+				}
+			}
+		}
+	}
+}
+
+// getSeckillActivity 查询秒杀活动（优先读取 Redis 缓存，不存在时返回 nil, nil）
+func (s *OrderService) getSeckillActivity(ctx context.Context, activityNo string) (*model.SeckillActivity, error) {
+	key := seckillActivityKey(activityNo)
+	if data, err := s.redisClient.Get(ctx, key).Bytes(); err == nil {
+		var activity model.SeckillActivity
+		if err := json.Unmarshal(data, &activity); err == nil {
+			return &activity, nil
+		}
+	} else if !errors.Is(err, redis.Nil) {
+		log.Printf("⚠️ [OrderService] getSeckillActivity: 读取活动缓存失败，回源数据库: activityNo=%s, err=%v", activityNo, err)
+	}
+
+	activity, err := s.seckillRepo.GetActivityByNo(ctx, activityNo)
+	if err != nil || activity == nil {
+		return activity, err
+	}
+	if data, err := json.Marshal(activity); err == nil {
+		s.redisClient.Set(ctx, key, data, seckillActivityCacheTTL)
+	}
+	return activity, nil
+}
+
+// rollbackSeckillReserve 活动创建失败时归还预扣的库存
+func (s *OrderService) rollbackSeckillReserve(ctx context.Context, activity *model.SeckillActivity) {
+	items := []*inventoryv1.SkuQuantity{{SkuId: activity.SKUID, Quantity: int64(activity.Stock)}}
+	if err := s.inventoryClient.RollbackStock(ctx, activity.ActivityNo, items); err != nil {
+		log.Printf("❌ [OrderService] rollbackSeckillReserve: 归还预扣库存失败: activityNo=%s, err=%v", activity.ActivityNo, err)
+	}
+}
+
+func (s *OrderService) convertSeckillActivityToProto(ctx context.Context, a *model.SeckillActivity) *orderv1.SeckillActivity {
+	res := &orderv1.SeckillActivity{
+		ActivityNo:   a.ActivityNo,
+		Title:        a.Title,
+		ProductId:    a.ProductID,
+		SkuId:        a.SKUID,
+		SeckillPrice: fmt.Sprintf("%.2f", a.SeckillPrice),
+		Stock:        a.Stock,
+		SoldCount:    a.SoldCount,
+		Status:       orderv1.SeckillActivityStatus(a.Status),
+		StartTime:    timestamppb.New(a.StartTime),
+		EndTime:      timestamppb.New(a.EndTime),
+	}
+	if a.Status == model.SeckillActivityStatusSettled {
+		res.RemainingStock = a.Stock - a.SoldCount
+	} else if remaining, err := s.redisClient.Get(ctx, seckillStockKey(a.ActivityNo)).Int64(); err == nil {
+		res.RemainingStock = int32(remaining)
+	}
+	if a.SettledAt != nil {
+		res.SettledAt = timestamppb.New(*a.SettledAt)
+	}
+	return res
+}
+
+// seckillActivityNoGenerator 生成秒杀活动编号，格式与订单号一致：{前缀(2位)}{日期时间(12位)}{随机数(6位)}{扩展位(2位)}
+func seckillActivityNoGenerator() string {
+	date := time.Now().Format("200601021504")
+	return fmt.Sprintf("%s%s%06d00", model.SeckillActivityNoPrefix, date, rand.Intn(1000000))
+}
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+	orderv1 "zjMall/gen/go/api/proto/order"
+	"zjMall/internal/common/middleware"
+	"zjMall/internal/order-service/carrier"
+	"zjMall/internal/order-service/model"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+)
+
+// shipmentSyncInterval 查询物流轨迹时，距上次主动查询物流公司超过该时间才重新查询
+const shipmentSyncInterval = 10 * time.Minute
+
+// GetShipmentTracking 查询订单物流轨迹（买家查询自己的订单，管理员可查询任意订单）
+func (s *OrderService) GetShipmentTracking(ctx context.Context, req *orderv1.GetShipmentTrackingRequest) (*orderv1.GetShipmentTrackingResponse, error) {
+	userID := middleware.GetUserIDFromContext(ctx)
+	if userID == "" {
+		return &orderv1.GetShipmentTrackingResponse{
+			Code:    1,
+			Message: "用户未登录",
+		}, nil
+	}
+
+	order, _, err := s.orderRepo.GetOrderByNoNoUser(ctx, req.OrderNo)
+	if err != nil || (order.UserID != userID && !middleware.CheckRole(ctx, "admin")) {
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("❌ [OrderService] GetShipmentTracking: 查询订单失败: orderNo=%s, err=%v", req.OrderNo, err)
+		}
+		return &orderv1.GetShipmentTrackingResponse{
+			Code:    1,
+			Message: "订单不存在",
+		}, nil
+	}
+	if order.TrackingNo == "" {
+		return &orderv1.GetShipmentTrackingResponse{
+			Code:    1,
+			Message: "订单尚未发货",
+		}, nil
+	}
+
+	shipment, err := s.ensureShipment(ctx, order)
+	if err != nil {
+		log.Printf("❌ [OrderService] GetShipmentTracking: 查询物流单失败: orderNo=%s, err=%v", req.OrderNo, err)
+		return &orderv1.GetShipmentTrackingResponse{
+			Code:    1,
+			Message: "查询物流信息失败",
+		}, nil
+	}
+
+	// 未签收且轨迹较旧时主动查询物流公司，查询失败时返回已有轨迹
+	if shipment.Status != model.ShipmentStatusDelivered &&
+		(shipment.SyncedAt == nil || time.Since(*shipment.SyncedAt) > shipmentSyncInterval) {
+		if err := s.syncShipment(ctx, shipment); err != nil {
+			log.Printf("⚠️ [OrderService] GetShipmentTracking: 查询物流公司轨迹失败，返回已有轨迹: orderNo=%s, err=%v", req.OrderNo, err)
+		}
+	}
+
+	events, err := s.shipmentRepo.ListShipmentEvents(ctx, shipment.ID)
+	if err != nil {
+		log.Printf("❌ [OrderService] GetShipmentTracking: 查询物流轨迹失败: orderNo=%s, err=%v", req.OrderNo, err)
+		return &orderv1.GetShipmentTrackingResponse{
+			Code:    1,
+			Message: "查询物流信息失败",
+		}, nil
+	}
+
+	return &orderv1.GetShipmentTrackingResponse{
+		Code:     0,
+		Message:  "success",
+		Shipment: convertShipmentToProto(shipment, events),
+	}, nil
+}
+
+// HandleCarrierWebhook 处理物流公司推送：校验签名、记录轨迹，签收时自动完成订单
+func (s *OrderService) HandleCarrierWebhook(ctx context.Context, carrierCode string, header http.Header, body []byte) error {
+	adapter, ok := s.carriers.Get(carrierCode)
+	if !ok {
+		return fmt.Errorf("不支持的物流公司: %s", carrierCode)
+	}
+	info, err := adapter.ParseWebhook(header, body)
+	if err != nil {
+		return err
+	}
+
+	shipment, err := s.shipmentRepo.GetShipmentByTrackingNo(ctx, carrierCode, info.TrackingNo)
+	if err != nil {
+		return fmt.Errorf("查询物流单失败: %w", err)
+	}
+	if shipment == nil {
+		return fmt.Errorf("物流单不存在: carrier=%s, trackingNo=%s", carrierCode, info.TrackingNo)
+	}
+
+	log.Printf("ℹ️ [OrderService] HandleCarrierWebhook: 收到物流推送: orderNo=%s, trackingNo=%s, status=%d, events=%d",
+		shipment.OrderNo, info.TrackingNo, info.Status, len(info.Events))
+	return s.applyTracking(ctx, shipment, info, false)
+}
+
+// recordShipment 订单发货后创建物流单（失败时在查询物流时按订单上的物流信息补建）
+func (s *OrderService) recordShipment(ctx context.Context, orderNo string) {
+	order, _, err := s.orderRepo.GetOrderByNoNoUser(ctx, orderNo)
+	if err == nil {
+		_, err = s.ensureShipment(ctx, order)
+	}
+	if err != nil {
+		log.Printf("⚠️ [OrderService] ShipOrder: 创建物流单失败: orderNo=%s, err=%v", orderNo, err)
+	}
+}
+
+// ensureShipment 查询订单的物流单，不存在时按订单上的物流信息创建
+func (s *OrderService) ensureShipment(ctx context.Context, order *model.Order) (*model.Shipment, error) {
+	shipment, err := s.shipmentRepo.GetShipmentByOrderNo(ctx, order.OrderNo)
+	if err != nil || shipment != nil {
+		return shipment, err
+	}
+
+	shippedAt := time.Now()
+	if order.ShippedAt != nil {
+		shippedAt = *order.ShippedAt
+	}
+	shipment = &model.Shipment{
+		OrderNo:    order.OrderNo,
+		UserID:     order.UserID,
+		Carrier:    order.ShippingCarrier,
+		TrackingNo: order.TrackingNo,
+		Status:     model.ShipmentStatusInTransit,
+		ShippedAt:  shippedAt,
+	}
+	created, err := s.shipmentRepo.CreateShipment(ctx, shipment)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		// 并发创建，以已存在的物流单为准
+		return s.shipmentRepo.GetShipmentByOrderNo(ctx, order.OrderNo)
+	}
+	return shipment, nil
+}
+
+// syncShipment 主动查询物流公司并更新轨迹
+func (s *OrderService) syncShipment(ctx context.Context, shipment *model.Shipment) error {
+	adapter, ok := s.carriers.Get(shipment.Carrier)
+	if !ok {
+		return nil
+	}
+	info, err := adapter.Track(ctx, shipment.TrackingNo)
+	if errors.Is(err, carrier.ErrTrackingNotFound) {
+		// 物流公司尚未揽收，记录查询时间，避免频繁查询
+		info, err = &carrier.TrackingInfo{TrackingNo: shipment.TrackingNo}, nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.applyTracking(ctx, shipment, info, true)
+}
+
+// applyTracking 记录物流轨迹并更新物流单状态；签收时走与确认收货相同的完成路径
+func (s *OrderService) applyTracking(ctx context.Context, shipment *model.Shipment, info *carrier.TrackingInfo, synced bool) error {
+	events := make([]*model.ShipmentEvent, 0, len(info.Events))
+	for _, e := range info.Events {
+		events = append(events, &model.ShipmentEvent{
+			ShipmentID:  shipment.ID,
+			OrderNo:     shipment.OrderNo,
+			EventTime:   e.Time,
+			Status:      e.Status,
+			Location:    e.Location,
+			Description: e.Description,
+		})
+	}
+	if _, err := s.shipmentRepo.AddShipmentEvents(ctx, events); err != nil {
+		return fmt.Errorf("记录物流轨迹失败: %w", err)
+	}
+
+	// 乐观锁冲突时重新读取物流单后重试
+	for attempt := 0; attempt < 3; attempt++ {
+		applyTrackingInfo(shipment, info, synced)
+		err := s.shipmentRepo.UpdateShipment(ctx, shipment)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) || attempt == 2 {
+			return fmt.Errorf("更新物流单失败: %w", err)
+		}
+		latest, getErr := s.shipmentRepo.GetShipmentByOrderNo(ctx, shipment.OrderNo)
+		if getErr != nil || latest == nil {
+			return fmt.Errorf("更新物流单失败: %w", err)
+		}
+		*shipment = *latest
+	}
+
+	if shipment.Status != model.ShipmentStatusDelivered {
+		return nil
+	}
+	if err := s.completeOrder(ctx, shipment.OrderNo, fmt.Sprintf("物流已签收，物流单号=%s", shipment.TrackingNo)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// 买家已确认收货或订单已进入其他流程
+			return nil
+		}
+		return fmt.Errorf("签收后完成订单失败: %w", err)
+	}
+	log.Printf("✅ [OrderService] 物流已签收，订单自动完成: orderNo=%s", shipment.OrderNo)
+	return nil
+}
+
+// applyTrackingInfo 将物流信息合并到物流单（已签收状态不会回退）
+func applyTrackingInfo(shipment *model.Shipment, info *carrier.TrackingInfo, synced bool) {
+	now := time.Now()
+	if synced {
+		shipment.SyncedAt = &now
+	}
+	for i := range info.Events {
+		eventTime := info.Events[i].Time
+		if shipment.LastEventAt == nil || eventTime.After(*shipment.LastEventAt) {
+			shipment.LastEventAt = &eventTime
+		}
+		if info.Events[i].Status == model.ShipmentStatusDelivered && shipment.DeliveredAt == nil {
+			shipment.DeliveredAt = &eventTime
+		}
+	}
+	if shipment.Status == model.ShipmentStatusDelivered || info.Status == 0 {
+		return
+	}
+	shipment.Status = info.Status
+	if shipment.Status == model.ShipmentStatusDelivered && shipment.DeliveredAt == nil {
+		shipment.DeliveredAt = &now
+	}
+}
+
+func convertShipmentToProto(shipment *model.Shipment, events []*model.ShipmentEvent) *orderv1.Shipment {
+	res := &orderv1.Shipment{
+		OrderNo:    shipment.OrderNo,
+		Carrier:    shipment.Carrier,
+		TrackingNo: shipment.TrackingNo,
+		Status:     orderv1.ShipmentStatus(shipment.Status),
+		ShippedAt:  timestamppb.New(shipment.ShippedAt),
+	}
+	if shipment.DeliveredAt != nil {
+		res.DeliveredAt = timestamppb.New(*shipment.DeliveredAt)
+	}
+	for _, e := range events {
+		res.Events = append(res.Events, &orderv1.ShipmentEvent{
+			Time:        timestamppb.New(e.EventTime),
+			Status:      orderv1.ShipmentStatus(e.Status),
+			Location:    e.Location,
+			Description: e.Description,
+		})
+	}
+	return res
+}
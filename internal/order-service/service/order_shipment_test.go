@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"testing"
+	"time"
+
+	"zjMall/internal/order-service/carrier"
+	"zjMall/internal/order-service/model"
+	"zjMall/internal/order-service/repository"
+
+	"gorm.io/gorm"
+)
+
+// fakeOrderRepo 仅实现状态机用到的查询与状态流转，其余方法调用时 panic
+type fakeOrderRepo struct {
+	repository.OrderRepository
+	orders  map[string]*model.Order
+	history []*model.OrderStatusHistory
+}
+
+func (r *fakeOrderRepo) GetOrderByNoNoUser(ctx context.Context, orderNo string) (*model.Order, []*model.OrderItem, error) {
+	order, ok := r.orders[orderNo]
+	if !ok {
+		return nil, nil, gorm.ErrRecordNotFound
+	}
+	snapshot := *order
+	return &snapshot, nil, nil
+}
+
+func (r *fakeOrderRepo) TransitOrderStatus(ctx context.Context, orderNo string, fromStatus, toStatus int8, fields map[string]interface{}, history *model.OrderStatusHistory) error {
+	order, ok := r.orders[orderNo]
+	if !ok || order.Status != fromStatus {
+		return gorm.ErrRecordNotFound
+	}
+	order.Status = toStatus
+	if completedAt, ok := fields["completed_at"].(*time.Time); ok {
+		order.CompletedAt = completedAt
+	}
+	r.history = append(r.history, history)
+	return nil
+}
+
+type fakeShipmentRepo struct {
+	repository.ShipmentRepository
+	events []*model.ShipmentEvent
+}
+
+func (r *fakeShipmentRepo) AddShipmentEvents(ctx context.Context, events []*model.ShipmentEvent) (int64, error) {
+	r.events = append(r.events, events...)
+	return int64(len(events)), nil
+}
+
+func (r *fakeShipmentRepo) UpdateShipment(ctx context.Context, shipment *model.Shipment) error {
+	return nil
+}
+
+type fakeInvoiceRepo struct {
+	repository.InvoiceRepository
+}
+
+func (r *fakeInvoiceRepo) ListInvoicesByOrderNos(ctx context.Context, orderNos []string) ([]*model.OrderInvoice, error) {
+	return nil, nil
+}
+
+func TestApplyTrackingDeliveredCompletesOrder(t *testing.T) {
+	const secret = "carrier-secret"
+	orderRepo := &fakeOrderRepo{orders: map[string]*model.Order{
+		"ORD202601010001": {OrderNo: "ORD202601010001", UserID: "user-1", Status: OrderStatusShipped},
+	}}
+	shipmentRepo := &fakeShipmentRepo{}
+	s := &OrderService{orderRepo: orderRepo, shipmentRepo: shipmentRepo, invoiceRepo: &fakeInvoiceRepo{}}
+	s.stateMachine = newOrderStateMachine(s)
+
+	// 通过模拟物流公司解析一条签名正确的签收推送
+	body := []byte(`{"tracking_no":"SF0001","events":[{"time":"2026-01-01T10:00:00Z","status":1,"description":"已揽收"},{"time":"2026-01-02T10:00:00Z","status":3,"description":"已签收"}]}`)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	header := http.Header{}
+	header.Set(carrier.FakeSignatureHeader, hex.EncodeToString(mac.Sum(nil)))
+	info, err := carrier.NewFakeCarrier(carrier.AnyCarrier, "", secret).ParseWebhook(header, body)
+	if err != nil {
+		t.Fatalf("解析物流推送失败: %v", err)
+	}
+
+	shipment := &model.Shipment{
+		OrderNo:    "ORD202601010001",
+		UserID:     "user-1",
+		Carrier:    "SF",
+		TrackingNo: "SF0001",
+		Status:     model.ShipmentStatusInTransit,
+		ShippedAt:  time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC),
+	}
+	shipment.ID = "shipment-1"
+	if err := s.applyTracking(context.Background(), shipment, info, false); err != nil {
+		t.Fatalf("处理物流推送失败: %v", err)
+	}
+
+	if shipment.Status != model.ShipmentStatusDelivered || shipment.DeliveredAt == nil {
+		t.Errorf("物流单未标记为已签收: status=%d, delivered_at=%v", shipment.Status, shipment.DeliveredAt)
+	}
+	if len(shipmentRepo.events) != 2 {
+		t.Errorf("记录的物流轨迹数 = %d, 期望 2", len(shipmentRepo.events))
+	}
+	order := orderRepo.orders["ORD202601010001"]
+	if order.Status != OrderStatusCompleted || order.CompletedAt == nil {
+		t.Fatalf("签收后订单未完成: status=%d, completed_at=%v", order.Status, order.CompletedAt)
+	}
+	if len(orderRepo.history) != 1 || orderRepo.history[0].Event != string(OrderEventComplete) {
+		t.Errorf("状态流转历史不正确: %+v", orderRepo.history)
+	}
+
+	// 重复推送时订单已完成，不应报错也不应再次流转
+	if err := s.applyTracking(context.Background(), shipment, info, false); err != nil {
+		t.Fatalf("重复处理签收推送失败: %v", err)
+	}
+	if len(orderRepo.history) != 1 {
+		t.Errorf("重复推送不应再次流转订单状态: %+v", orderRepo.history)
+	}
+}
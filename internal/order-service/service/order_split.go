@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+	"zjMall/internal/common/client"
+	"zjMall/internal/order-service/model"
+	"zjMall/internal/order-service/statemachine"
+
+	"gorm.io/gorm"
+)
+
+// maxSubOrders 单个主订单最多拆分的子订单数（子订单号使用订单号末两位扩展位作为序号）
+const maxSubOrders = 99
+
+// subOrderNo 生成子订单号：将主订单号末两位扩展位替换为子订单序号（01~99）
+func subOrderNo(parentOrderNo string, seq int) string {
+	return fmt.Sprintf("%s%02d", parentOrderNo[:len(parentOrderNo)-2], seq)
+}
+
+// buildSubOrders 按商家分组生成子订单，并将商品明细归属到对应子订单
+// 子订单金额：商品金额与优惠按明细汇总，运费为该商家单独计算的运费；主订单应付金额为子订单应付金额之和
+func (s *OrderService) buildSubOrders(parent *model.Order, groups []*client.MerchantFreight, items []*model.OrderItem, snapshots []ItemBasicSnapshot) []*model.Order {
+	children := make([]*model.Order, 0, len(groups))
+	remainingDiscount := int64(math.Round(parent.DiscountAmount * 100))
+	var payCents int64
+
+	for gi, group := range groups {
+		orderNo := subOrderNo(parent.OrderNo, gi+1)
+		var totalCents, discountCents int64
+		groupSnapshots := make([]ItemBasicSnapshot, 0, len(group.Lines))
+		for _, i := range group.Lines {
+			items[i].OrderNo = orderNo
+			totalCents += int64(math.Round(items[i].Price * float64(items[i].Quantity) * 100))
+			discountCents += int64(math.Round(items[i].DiscountAmount * 100))
+			groupSnapshots = append(groupSnapshots, snapshots[i])
+		}
+		// 最后一个子订单承担分摊舍入差额，保证子订单优惠之和等于主订单优惠
+		if gi == len(groups)-1 {
+			discountCents = remainingDiscount
+		}
+		remainingDiscount -= discountCents
+
+		shippingCents := int64(math.Round(group.ShippingFee * 100))
+		childPayCents := totalCents - discountCents + shippingCents
+		if childPayCents < 0 {
+			childPayCents = 0
+		}
+		payCents += childPayCents
+
+		itemsSnapshotJSON, err := s.generateItemsSnapshot(groupSnapshots)
+		if err != nil {
+			log.Printf("⚠️ [OrderService] buildSubOrders: 生成子订单快照失败: orderNo=%s, err=%v", orderNo, err)
+			itemsSnapshotJSON = ""
+		}
+
+		children = append(children, &model.Order{
+			OrderNo:         orderNo,
+			UserID:          parent.UserID,
+			Status:          parent.Status,
+			TotalAmount:     float64(totalCents) / 100,
+			DiscountAmount:  float64(discountCents) / 100,
+			ShippingAmount:  float64(shippingCents) / 100,
+			PayAmount:       float64(childPayCents) / 100,
+			SplitType:       model.OrderSplitTypeChild,
+			ParentOrderNo:   parent.OrderNo,
+			MerchantID:      group.MerchantID,
+			BuyerRemark:     parent.BuyerRemark,
+			ReceiverName:    parent.ReceiverName,
+			ReceiverPhone:   parent.ReceiverPhone,
+			ReceiverAddress: parent.ReceiverAddress,
+			ItemsSnapshot:   itemsSnapshotJSON,
+			Version:         1,
+		})
+	}
+
+	parent.SplitType = model.OrderSplitTypeParent
+	parent.PayAmount = float64(payCents) / 100
+	return children
+}
+
+// paySubOrders 主订单支付成功后将待支付的子订单标记为已支付（基于 fromStatus + 乐观锁，可重复调用）
+// 仅在主订单为已支付状态时处理，支付渠道、流水号与支付时间沿用主订单
+func (s *OrderService) paySubOrders(ctx context.Context, parentOrderNo string) error {
+	parent, _, err := s.orderRepo.GetOrderByNoNoUser(ctx, parentOrderNo)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return fmt.Errorf("查询主订单失败: %w", err)
+	}
+	if parent.SplitType != model.OrderSplitTypeParent || parent.Status != OrderStatusPaid {
+		return nil
+	}
+
+	children, _, err := s.orderRepo.GetChildOrders(ctx, parentOrderNo)
+	if err != nil {
+		return fmt.Errorf("查询子订单失败: %w", err)
+	}
+	paidAt := time.Now()
+	if parent.PaidAt != nil {
+		paidAt = *parent.PaidAt
+	}
+	for _, child := range children {
+		if child.Status != OrderStatusPendingPay {
+			continue
+		}
+		err := s.stateMachine.Fire(ctx, &statemachine.Transition{
+			Event:  OrderEventFollowParent,
+			From:   OrderStatusPendingPay,
+			To:     OrderStatusPaid,
+			Fields: orderPaidFields(parent.PayChannel, parent.PayTradeNo, paidAt),
+			Reason: fmt.Sprintf("主订单%s已支付", parentOrderNo),
+			Order:  child,
+		})
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
+			}
+			return fmt.Errorf("更新子订单支付状态失败: orderNo=%s, err=%w", child.OrderNo, err)
+		}
+		log.Printf("✅ [OrderService] paySubOrders: 子订单标记为已支付: orderNo=%s, parentOrderNo=%s", child.OrderNo, parentOrderNo)
+	}
+	return nil
+}
+
+// refundSubOrders 主订单整单退款状态变更后，将子订单同步到与主订单一致的退款状态（基于 fromStatus + 乐观锁，可重复调用）
+// 主订单退款中：已支付/已发货/已完成的子订单 -> 退款中，阻止继续发货；
+// 主订单已退款：未退款的子订单 -> 已退款；主订单退款失败恢复后：退款中的子订单恢复为各自申请前的状态
+func (s *OrderService) refundSubOrders(ctx context.Context, evt *RefundEvent) error {
+	parent, _, err := s.orderRepo.GetOrderByNoNoUser(ctx, evt.OrderNo)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return fmt.Errorf("查询主订单失败: %w", err)
+	}
+	if parent.SplitType != model.OrderSplitTypeParent {
+		return nil
+	}
+
+	children, _, err := s.orderRepo.GetChildOrders(ctx, parent.OrderNo)
+	if err != nil {
+		return fmt.Errorf("查询子订单失败: %w", err)
+	}
+	for _, child := range children {
+		var event statemachine.Event
+		toStatus := parent.Status
+		switch {
+		case parent.Status == OrderStatusRefunding && isRefundableStatus(child.Status):
+			event = OrderEventRefundApply
+		case parent.Status == OrderStatusRefunded && (child.Status == OrderStatusRefunding || isRefundableStatus(child.Status)):
+			event = OrderEventRefundSucceed
+		case isRefundableStatus(parent.Status) && child.Status == OrderStatusRefunding:
+			event = OrderEventRefundFail
+			toStatus, err = s.statusBeforeRefund(ctx, child.OrderNo)
+			if err != nil {
+				return err
+			}
+			if toStatus == 0 {
+				log.Printf("⚠️ [OrderService] refundSubOrders: 未找到子订单退款前的状态，跳过: orderNo=%s", child.OrderNo)
+				continue
+			}
+		default:
+			continue
+		}
+
+		err := s.stateMachine.Fire(ctx, &statemachine.Transition{
+			Event:  event,
+			From:   child.Status,
+			To:     toStatus,
+			Reason: fmt.Sprintf("主订单%s%s", parent.OrderNo, refundReason(evt)),
+			Order:  child,
+		})
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
+			}
+			return fmt.Errorf("更新子订单退款状态失败: orderNo=%s, err=%w", child.OrderNo, err)
+		}
+		log.Printf("✅ [OrderService] refundSubOrders: 子订单退款状态已同步: orderNo=%s, %d -> %d, parentOrderNo=%s", child.OrderNo, child.Status, toStatus, parent.OrderNo)
+	}
+	return nil
+}
+
+// isRefundableStatus 可申请整单退款的订单状态
+func isRefundableStatus(status int8) bool {
+	return status == OrderStatusPaid || status == OrderStatusShipped || status == OrderStatusCompleted
+}
+
+// statusBeforeRefund 从状态流转历史中查询订单最近一次进入退款中之前的状态，未找到时返回 0
+func (s *OrderService) statusBeforeRefund(ctx context.Context, orderNo string) (int8, error) {
+	histories, err := s.orderRepo.ListStatusHistory(ctx, orderNo)
+	if err != nil {
+		return 0, fmt.Errorf("查询订单状态流转历史失败: %w", err)
+	}
+	for i := len(histories) - 1; i >= 0; i-- {
+		if histories[i].ToStatus == OrderStatusRefunding {
+			return histories[i].FromStatus, nil
+		}
+	}
+	return 0, nil
+}
+
+// closeSubOrders 主订单取消或超时关闭时，将待支付的子订单同步置为 toStatus
+// 返回全部子订单明细：库存按主订单号整体扣减，需由调用方按主订单号整体回滚
+func (s *OrderService) closeSubOrders(ctx context.Context, parent *model.Order, toStatus int8) []*model.OrderItem {
+	if parent == nil || parent.SplitType != model.OrderSplitTypeParent {
+		return nil
+	}
+	children, items, err := s.orderRepo.GetChildOrders(ctx, parent.OrderNo)
+	if err != nil {
+		log.Printf("❌ [OrderService] closeSubOrders: 查询子订单失败: parentOrderNo=%s, err=%v", parent.OrderNo, err)
+		return nil
+	}
+	for _, child := range children {
+		err := s.stateMachine.Fire(ctx, &statemachine.Transition{
+			Event:  OrderEventFollowParent,
+			From:   OrderStatusPendingPay,
+			To:     toStatus,
+			Reason: fmt.Sprintf("主订单%s%s", parent.OrderNo, orderStatusText(toStatus)),
+			Order:  child,
+		})
+		if err != nil {
+			log.Printf("⚠️ [OrderService] closeSubOrders: 更新子订单状态失败: orderNo=%s, err=%v", child.OrderNo, err)
+		}
+	}
+	return items
+}
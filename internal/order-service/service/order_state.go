@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	inventoryv1 "zjMall/gen/go/api/proto/inventory"
+	orderv1 "zjMall/gen/go/api/proto/order"
+	"zjMall/internal/order-service/model"
+	"zjMall/internal/order-service/statemachine"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// 订单状态流转事件
+const (
+	OrderEventPay           statemachine.Event = "pay"            // 支付成功：待支付 -> 已支付
+	OrderEventCancel        statemachine.Event = "cancel"         // 用户取消：待支付 -> 已取消
+	OrderEventTimeout       statemachine.Event = "timeout"        // 超时关闭：待支付 -> 已关闭
+	OrderEventFollowParent  statemachine.Event = "follow_parent"  // 子订单随主订单支付 / 取消 / 关闭
+	OrderEventShip          statemachine.Event = "ship"           // 发货：已支付 -> 已发货
+	OrderEventComplete      statemachine.Event = "complete"       // 确认收货（买家确认、超时自动确认、物流签收）：已发货 -> 已完成
+	OrderEventRefundApply   statemachine.Event = "refund_apply"   // 整单退款申请：已支付/已发货/已完成 -> 退款中
+	OrderEventRefundSucceed statemachine.Event = "refund_succeed" // 整单退款成功：-> 已退款
+	OrderEventRefundFail    statemachine.Event = "refund_fail"    // 整单退款失败：退款中 -> 申请前的状态
+
+	OrderEventExtendPayDeadline statemachine.Event = "extend_pay_deadline" // 管理员延长支付截止时间（状态不变，记录历史）
+)
+
+// newOrderStateMachine 订单状态流转表：允许的流转、守卫与流转后的副作用
+func newOrderStateMachine(s *OrderService) *statemachine.Machine {
+	m := statemachine.New(s.orderRepo)
+
+	m.Allow(OrderEventPay, OrderStatusPaid, OrderStatusPendingPay).
+		Guard(OrderEventPay, rejectSplitType(model.OrderSplitTypeChild, "子订单随主订单支付")).
+		After(OrderEventPay, s.afterOrderPaid, s.confirmOrderStock)
+
+	m.Allow(OrderEventCancel, OrderStatusCancelled, OrderStatusPendingPay).
+		Guard(OrderEventCancel, func(ctx context.Context, t *statemachine.Transition) error {
+			// 子订单随主订单一起支付，待支付时只能取消主订单
+			if t.Order.SplitType == model.OrderSplitTypeChild {
+				return statemachine.Reject("请取消主订单%s", t.Order.ParentOrderNo)
+			}
+			return nil
+		}).
+		After(OrderEventCancel, s.releaseOrderResources, s.voidOrderInvoices)
+
+	m.Allow(OrderEventTimeout, OrderStatusClosed, OrderStatusPendingPay).
+		Guard(OrderEventTimeout, rejectSplitType(model.OrderSplitTypeChild, "子订单随主订单关闭")).
+		After(OrderEventTimeout, s.releaseOrderResources, s.voidOrderInvoices)
+
+	m.Allow(OrderEventFollowParent, OrderStatusPaid, OrderStatusPendingPay).
+		Allow(OrderEventFollowParent, OrderStatusCancelled, OrderStatusPendingPay).
+		Allow(OrderEventFollowParent, OrderStatusClosed, OrderStatusPendingPay).
+		Guard(OrderEventFollowParent, func(ctx context.Context, t *statemachine.Transition) error {
+			if t.Order.SplitType != model.OrderSplitTypeChild {
+				return statemachine.Reject("仅子订单可随主订单流转")
+			}
+			return nil
+		})
+
+	m.Allow(OrderEventExtendPayDeadline, OrderStatusPendingPay, OrderStatusPendingPay).
+		Guard(OrderEventExtendPayDeadline, func(ctx context.Context, t *statemachine.Transition) error {
+			if t.Order.SplitType == model.OrderSplitTypeChild {
+				return statemachine.Reject("子订单随主订单支付，请延长主订单%s的支付时间", t.Order.ParentOrderNo)
+			}
+			return nil
+		}).
+		After(OrderEventExtendPayDeadline, s.afterPayDeadlineExtended)
+
+	m.Allow(OrderEventShip, OrderStatusShipped, OrderStatusPaid).
+		Guard(OrderEventShip, rejectSplitType(model.OrderSplitTypeParent, "拆单主订单不能发货，请按子订单发货")).
+		After(OrderEventShip, s.afterOrderShipped)
+
+	m.Allow(OrderEventComplete, OrderStatusCompleted, OrderStatusShipped).
+		After(OrderEventComplete, s.markInvoicesIssuable)
+
+	m.Allow(OrderEventRefundApply, OrderStatusRefunding, OrderStatusPaid, OrderStatusShipped, OrderStatusCompleted).
+		Allow(OrderEventRefundSucceed, OrderStatusRefunded, OrderStatusRefunding, OrderStatusPaid, OrderStatusShipped, OrderStatusCompleted).
+		Allow(OrderEventRefundFail, OrderStatusPaid, OrderStatusRefunding).
+		Allow(OrderEventRefundFail, OrderStatusShipped, OrderStatusRefunding).
+		Allow(OrderEventRefundFail, OrderStatusCompleted, OrderStatusRefunding).
+		After(OrderEventRefundSucceed, s.settleInvoicesForRefund)
+
+	return m
+}
+
+// rejectSplitType 守卫：拒绝指定拆单类型的订单
+func rejectSplitType(splitType int8, reason string) statemachine.Guard {
+	return func(ctx context.Context, t *statemachine.Transition) error {
+		if t.Order.SplitType == splitType {
+			return statemachine.Reject("%s", reason)
+		}
+		return nil
+	}
+}
+
+// rejectReason 守卫拒绝流转时返回拒绝原因
+func rejectReason(err error) (string, bool) {
+	var reject *statemachine.RejectError
+	if errors.As(err, &reject) {
+		return reject.Reason, true
+	}
+	return "", false
+}
+
+// afterOrderPaid 主订单支付成功后同步将子订单标记为已支付，失败时返回错误由调用方重试
+func (s *OrderService) afterOrderPaid(ctx context.Context, t *statemachine.Transition) error {
+	if t.Order.SplitType != model.OrderSplitTypeParent {
+		return nil
+	}
+	return s.paySubOrders(ctx, t.OrderNo)
+}
+
+// confirmOrderStock 支付成功后确认下单时锁定的库存（拆单时库存按主订单号整体锁定）
+// 秒杀订单的库存在活动创建时已预扣，无需确认；确认失败只记录告警，库存服务按订单号幂等，可后续补偿
+func (s *OrderService) confirmOrderStock(ctx context.Context, t *statemachine.Transition) error {
+	if t.Order.SplitType == model.OrderSplitTypeChild || t.Order.SeckillActivityNo != "" {
+		return nil
+	}
+	if err := s.inventoryClient.ConfirmStock(ctx, t.OrderNo); err != nil {
+		log.Printf("⚠️ [OrderService] confirmOrderStock: 确认库存失败: orderNo=%s, err=%v", t.OrderNo, err)
+		return nil
+	}
+	log.Printf("✅ [OrderService] confirmOrderStock: 库存确认成功: orderNo=%s", t.OrderNo)
+	return nil
+}
+
+// releaseOrderResources 订单取消或超时关闭后：同步关闭子订单、释放锁定库存、归还优惠券与促销配额
+// 资源释放失败只记录告警，不影响订单关闭（库存服务按订单号幂等，可后续补偿）
+func (s *OrderService) releaseOrderResources(ctx context.Context, t *statemachine.Transition) error {
+	order := t.Order
+
+	// 主订单关闭时同步关闭子订单，库存按主订单号整体释放
+	orderItems := append([]*model.OrderItem{}, t.Items...)
+	orderItems = append(orderItems, s.closeSubOrders(ctx, order, t.To)...)
+
+	if order.SeckillActivityNo == "" {
+		if err := s.inventoryClient.ReleaseStock(ctx, order.OrderNo); err != nil {
+			log.Printf("❌ [OrderService] releaseOrderResources: 释放库存失败: orderNo=%s, err=%v", order.OrderNo, err)
+		} else {
+			log.Printf("✅ [OrderService] releaseOrderResources: 库存释放成功: orderNo=%s", order.OrderNo)
+		}
+		s.releaseOrderDiscount(ctx, order)
+		return nil
+	}
+
+	var rollbackItems []*inventoryv1.SkuQuantity
+	for _, item := range orderItems {
+		rollbackItems = append(rollbackItems, &inventoryv1.SkuQuantity{
+			SkuId:       item.SKUID,
+			Quantity:    int64(item.Quantity),
+			WarehouseId: item.WarehouseID,
+		})
+	}
+	// 秒杀订单在活动进行中时名额回到秒杀库存池，否则回滚 MySQL 库存
+	if len(rollbackItems) > 0 && !s.returnSeckillStock(ctx, order) {
+		if err := s.inventoryClient.RollbackStock(ctx, order.OrderNo, rollbackItems); err != nil {
+			log.Printf("❌ [OrderService] releaseOrderResources: 回滚库存失败: orderNo=%s, err=%v", order.OrderNo, err)
+		} else {
+			log.Printf("✅ [OrderService] releaseOrderResources: 库存回滚成功: orderNo=%s", order.OrderNo)
+		}
+	}
+
+	s.releaseOrderDiscount(ctx, order)
+	return nil
+}
+
+// afterOrderShipped 发货后记录物流单并投递自动确认收货延迟消息
+func (s *OrderService) afterOrderShipped(ctx context.Context, t *statemachine.Transition) error {
+	shippedAt := time.Now()
+	if v, ok := t.Fields["shipped_at"].(*time.Time); ok && v != nil {
+		shippedAt = *v
+	}
+	s.recordShipment(ctx, t.OrderNo)
+	s.scheduleOrderAutoComplete(ctx, t.OrderNo, shippedAt)
+	return nil
+}
+
+// convertStatusHistoryToProto 转换订单状态流转历史
+func convertStatusHistoryToProto(history []*model.OrderStatusHistory) []*orderv1.OrderStatusHistory {
+	res := make([]*orderv1.OrderStatusHistory, 0, len(history))
+	for _, h := range history {
+		res = append(res, &orderv1.OrderStatusHistory{
+			FromStatus: orderv1.OrderStatus(h.FromStatus),
+			ToStatus:   orderv1.OrderStatus(h.ToStatus),
+			Event:      h.Event,
+			Operator:   h.Operator,
+			Reason:     h.Reason,
+			TraceId:    h.TraceID,
+			CreatedAt:  timestamppb.New(h.CreatedAt),
+		})
+	}
+	return res
+}
+
+// orderStatusText 订单状态描述（用于流转原因、订单导出）
+func orderStatusText(status int8) string {
+	switch status {
+	case OrderStatusPendingPay:
+		return "待支付"
+	case OrderStatusPaid:
+		return "已支付"
+	case OrderStatusShipped:
+		return "已发货"
+	case OrderStatusCompleted:
+		return "已完成"
+	case OrderStatusCancelled:
+		return "已取消"
+	case OrderStatusRefunding:
+		return "退款中"
+	case OrderStatusRefunded:
+		return "已退款"
+	case OrderStatusClosed:
+		return "已关闭"
+	default:
+		return fmt.Sprintf("状态%d", status)
+	}
+}
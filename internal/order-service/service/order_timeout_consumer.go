@@ -6,7 +6,8 @@ import (
 	"fmt"
 	"log"
 	"time"
-	inventoryv1 "zjMall/gen/go/api/proto/inventory"
+	"zjMall/internal/order-service/model"
+	"zjMall/internal/order-service/statemachine"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 )
@@ -98,7 +99,7 @@ func handleOrderTimeoutMessage(ctx context.Context, orderService *OrderService,
 	return nil
 }
 
-// HandleOrderTimeout 处理订单超时（回滚库存并关闭订单）
+// HandleOrderTimeout 处理订单超时（释放库存并关闭订单）
 func (s *OrderService) HandleOrderTimeout(ctx context.Context, orderNo string) error {
 	// 查询订单（不校验用户ID，因为可能是超时自动处理）
 	order, orderItems, err := s.orderRepo.GetOrderByNoNoUser(ctx, orderNo)
@@ -112,33 +113,29 @@ func (s *OrderService) HandleOrderTimeout(ctx context.Context, orderNo string) e
 		return nil
 	}
 
-	// 更新订单状态为已关闭（使用乐观锁）
-	err = s.orderRepo.UpdateOrderStatus(ctx, orderNo, OrderStatusPendingPay, OrderStatusClosed)
+	// 支付截止时间被延长后，旧的超时消息会提前到达，按新的截止时间重新投递的消息处理
+	payDeadline := s.orderPayDeadline(order)
+	if time.Now().Before(payDeadline) {
+		log.Printf("ℹ️ [OrderService] HandleOrderTimeout: 未到支付截止时间，跳过处理: orderNo=%s, payDeadline=%s", orderNo, payDeadline.Format(time.RFC3339))
+		return nil
+	}
+
+	// 更新订单状态为已关闭（使用乐观锁），关闭后释放库存（与用户取消订单逻辑一致）并归还优惠
+	// 子订单随主订单关闭（库存与优惠均以主订单号锁定），由状态机守卫拒绝
+	err = s.stateMachine.Fire(ctx, &statemachine.Transition{
+		Event:  OrderEventTimeout,
+		From:   OrderStatusPendingPay,
+		To:     OrderStatusClosed,
+		Reason: fmt.Sprintf("超过支付截止时间%s未支付，系统自动关闭", payDeadline.Format(time.DateTime)),
+		Order:  order,
+		Items:  orderItems,
+	})
 	if err != nil {
-		// 如果更新失败（可能是订单已被支付或取消），跳过
+		// 如果更新失败（可能是订单已被支付或取消、子订单），跳过
 		log.Printf("⚠️ [OrderService] HandleOrderTimeout: 更新订单状态失败: orderNo=%s, err=%v", orderNo, err)
 		return nil // 不返回错误，避免消息重复处理
 	}
 
-	// 回滚库存（订单超时时释放库存，与用户取消订单逻辑一致）
-	if len(orderItems) > 0 {
-		var rollbackItems []*inventoryv1.SkuQuantity
-		for _, item := range orderItems {
-			rollbackItems = append(rollbackItems, &inventoryv1.SkuQuantity{
-				SkuId:    item.SKUID,
-				Quantity: int64(item.Quantity),
-			})
-		}
-		if len(rollbackItems) > 0 {
-			if rollbackErr := s.inventoryClient.RollbackStock(ctx, orderNo, rollbackItems); rollbackErr != nil {
-				log.Printf("❌ [OrderService] HandleOrderTimeout: 回滚库存失败: orderNo=%s, err=%v", orderNo, rollbackErr)
-				// 记录告警，但不影响订单超时处理流程（库存服务应该是幂等的，可以后续补偿）
-			} else {
-				log.Printf("✅ [OrderService] HandleOrderTimeout: 库存回滚成功: orderNo=%s", orderNo)
-			}
-		}
-	}
-
 	log.Printf("✅ [OrderService] HandleOrderTimeout: 订单超时处理成功: orderNo=%s", orderNo)
 	return nil
 }
@@ -171,8 +168,8 @@ func StartOrderTimeoutCompensation(ctx context.Context, orderService *OrderServi
 
 // scanAndCloseTimeoutOrders 扫描并关闭超时订单
 func scanAndCloseTimeoutOrders(ctx context.Context, orderService *OrderService) error {
-	// 查询超时的待支付订单（创建时间超过超时时间）
-	timeoutOrders, err := orderService.orderRepo.GetTimeoutOrders(ctx, OrderStatusPendingPay, orderService.orderTimeoutDelay, 100)
+	// 查询已过支付截止时间的待支付订单
+	timeoutOrders, err := orderService.orderRepo.GetTimeoutOrders(ctx, OrderStatusPendingPay, time.Now(), orderService.payTimeouts[model.OrderTypeNormal], 100)
 	if err != nil {
 		return fmt.Errorf("查询超时订单失败: %w", err)
 	}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// StartRefundEventConsumer 启动退款事件消费者，从 MQ 中消费 RefundEvent 并流转订单退款状态
+func StartRefundEventConsumer(ctx context.Context, svc *OrderService, ch *amqp.Channel, queue string) {
+	if ch == nil {
+		log.Println("⚠️ [OrderRefundConsumer] RabbitMQ Channel 为 nil，跳过消费者启动")
+		return
+	}
+	if svc == nil {
+		log.Println("⚠️ [OrderRefundConsumer] OrderService 为 nil，跳过消费者启动")
+		return
+	}
+
+	// 确保队列存在（与生产端队列名保持一致）
+	_, err := ch.QueueDeclare(
+		queue,
+		true,  // durable
+		false, // autoDelete
+		false, // exclusive
+		false, // noWait
+		nil,
+	)
+	if err != nil {
+		log.Printf("❌ [OrderRefundConsumer] 声明队列失败: %v", err)
+		return
+	}
+
+	// 公平分发，一次只投递一条未确认的消息给当前消费者
+	if err := ch.Qos(1, 0, false); err != nil {
+		log.Printf("⚠️ [OrderRefundConsumer] 设置 Qos 失败: %v", err)
+	}
+
+	msgs, err := ch.Consume(
+		queue,
+		"order-service-refund-consumer", // consumer
+		false,                           // autoAck
+		false,                           // exclusive
+		false,                           // noLocal
+		false,                           // noWait
+		nil,                             // args
+	)
+	if err != nil {
+		log.Printf("❌ [OrderRefundConsumer] 启动消费者失败: %v", err)
+		return
+	}
+
+	log.Printf("✅ [OrderRefundConsumer] 已启动，正在消费退款事件队列: %s", queue)
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				log.Println("ℹ️ [OrderRefundConsumer] 上下文已取消，退出消费者循环")
+				return
+			case msg, ok := <-msgs:
+				if !ok {
+					log.Println("⚠️ [OrderRefundConsumer] 消息通道已关闭，退出消费者循环")
+					return
+				}
+
+				start := time.Now()
+				var evt RefundEvent
+				if err := json.Unmarshal(msg.Body, &evt); err != nil {
+					log.Printf("❌ [OrderRefundConsumer] 解析 RefundEvent 失败，丢弃消息: %v, body=%s", err, string(msg.Body))
+					_ = msg.Nack(false, false)
+					continue
+				}
+
+				if err := svc.HandleRefundEvent(ctx, &evt); err != nil {
+					log.Printf("❌ [OrderRefundConsumer] 处理退款事件失败，将重回队列: %v", err)
+					_ = msg.Nack(false, true)
+					time.Sleep(100 * time.Millisecond)
+					continue
+				}
+
+				_ = msg.Ack(false)
+				log.Printf("✅ [OrderRefundConsumer] 退款事件处理完成，event=%s，orderNo=%s，耗时=%s", evt.EventType, evt.OrderNo, time.Since(start))
+			}
+		}
+	}()
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const seckillOrderConsumerMaxRetries = 3
+
+// StartSeckillOrderConsumer 启动秒杀异步下单消费者
+// 处理失败时递增 retry_count 重新投递，达到上限后释放名额并记录失败结果；消息始终 Ack，避免无限重试
+func StartSeckillOrderConsumer(ctx context.Context, svc *OrderService, ch *amqp.Channel, queue string) {
+	if ch == nil {
+		log.Println("⚠️ [SeckillOrderConsumer] RabbitMQ Channel 为 nil，跳过消费者启动")
+		return
+	}
+
+	// 确保队列存在（与生产端队列名保持一致）
+	_, err := ch.QueueDeclare(
+		queue,
+		true,  // durable
+		false, // autoDelete
+		false, // exclusive
+		false, // noWait
+		nil,
+	)
+	if err != nil {
+		log.Printf("❌ [SeckillOrderConsumer] 声明队列失败: %v", err)
+		return
+	}
+
+	// 秒杀下单消息量大，适当增加预取数量
+	if err := ch.Qos(16, 0, false); err != nil {
+		log.Printf("⚠️ [SeckillOrderConsumer] 设置 Qos 失败: %v", err)
+	}
+
+	msgs, err := ch.Consume(
+		queue,
+		"order-service-seckill-consumer", // consumer
+		false,                            // autoAck
+		false,                            // exclusive
+		false,                            // noLocal
+		false,                            // noWait
+		nil,                              // args
+	)
+	if err != nil {
+		log.Printf("❌ [SeckillOrderConsumer] 启动消费者失败: %v", err)
+		return
+	}
+
+	log.Printf("✅ [SeckillOrderConsumer] 已启动，正在消费秒杀下单队列: %s", queue)
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				log.Println("ℹ️ [SeckillOrderConsumer] 上下文已取消，退出消费者循环")
+				return
+			case msg, ok := <-msgs:
+				if !ok {
+					log.Println("⚠️ [SeckillOrderConsumer] 消息通道已关闭，退出消费者循环")
+					return
+				}
+
+				start := time.Now()
+				var m SeckillOrderMessage
+				if err := json.Unmarshal(msg.Body, &m); err != nil {
+					log.Printf("❌ [SeckillOrderConsumer] 解析秒杀下单消息失败，丢弃消息: %v, body=%s", err, string(msg.Body))
+					_ = msg.Nack(false, false)
+					continue
+				}
+
+				if err := svc.HandleSeckillOrder(ctx, &m); err != nil {
+					retrySeckillOrder(ctx, svc, ch, queue, &m, err)
+				} else {
+					log.Printf("✅ [SeckillOrderConsumer] 秒杀下单消息处理完成，orderNo=%s，耗时=%s", m.OrderNo, time.Since(start))
+				}
+				_ = msg.Ack(false)
+			}
+		}
+	}()
+}
+
+// retrySeckillOrder 未达重试上限时递增 retry_count 重新投递，否则释放名额
+func retrySeckillOrder(ctx context.Context, svc *OrderService, ch *amqp.Channel, queue string, m *SeckillOrderMessage, cause error) {
+	if m.RetryCount >= seckillOrderConsumerMaxRetries {
+		log.Printf("❌ [SeckillOrderConsumer] 秒杀下单失败，已达最大重试次数 %d，放弃: orderNo=%s, err=%v", seckillOrderConsumerMaxRetries, m.OrderNo, cause)
+		svc.failSeckillOrder(ctx, m, "订单创建失败，请重新抢购")
+		return
+	}
+
+	m.RetryCount++
+	body, _ := json.Marshal(m)
+	if err := ch.PublishWithContext(ctx, "", queue, false, false, amqp.Publishing{
+		DeliveryMode: amqp.Persistent,
+		ContentType:  "application/json",
+		Body:         body,
+	}); err != nil {
+		log.Printf("❌ [SeckillOrderConsumer] Republish 失败，释放名额: orderNo=%s, err=%v (原错误: %v)", m.OrderNo, err, cause)
+		svc.failSeckillOrder(ctx, m, "订单创建失败，请重新抢购")
+		return
+	}
+	log.Printf("⚠️ [SeckillOrderConsumer] 处理失败，已 Republish 重试: orderNo=%s, retryCount=%d, err=%v", m.OrderNo, m.RetryCount, cause)
+}
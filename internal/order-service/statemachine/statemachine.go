@@ -0,0 +1,168 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"zjMall/internal/common/middleware"
+	"zjMall/internal/order-service/model"
+)
+
+// Event 触发订单状态流转的事件
+type Event string
+
+var (
+	// ErrTransitionNotAllowed 流转表中没有该事件对应的 from -> to 流转
+	ErrTransitionNotAllowed = errors.New("订单状态流转不允许")
+	// ErrHookFailed 状态已流转成功，但流转后的副作用处理失败（调用方可按需重试副作用）
+	ErrHookFailed = errors.New("订单状态流转后处理失败")
+)
+
+// RejectError 守卫拒绝流转，Reason 可直接返回给调用方
+type RejectError struct {
+	Reason string
+}
+
+func (e *RejectError) Error() string {
+	return e.Reason
+}
+
+// Reject 守卫拒绝流转时使用
+func Reject(format string, args ...interface{}) error {
+	return &RejectError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// Transition 一次状态流转
+type Transition struct {
+	Event    Event
+	OrderNo  string
+	From     int8                   // 流转前状态（为 0 时取订单当前状态），作为乐观锁条件
+	To       int8                   // 流转后状态
+	Fields   map[string]interface{} // 随状态一起更新的订单字段（如支付信息、发货时间）
+	Operator string                 // 操作人，为空时取上下文中的用户ID，仍为空时为 system
+	Reason   string                 // 流转原因，写入状态流转历史
+
+	Order *model.Order       // 流转前的订单（为空时由状态机查询）
+	Items []*model.OrderItem // 订单明细（由状态机查询订单时填充）
+}
+
+// Guard 守卫：流转前校验，返回错误时拒绝流转
+type Guard func(ctx context.Context, t *Transition) error
+
+// Hook 副作用：状态流转成功（事务提交）后执行
+type Hook func(ctx context.Context, t *Transition) error
+
+// Store 订单状态持久化（由订单仓储实现）
+type Store interface {
+	GetOrderByNoNoUser(ctx context.Context, orderNo string) (*model.Order, []*model.OrderItem, error)
+	TransitOrderStatus(ctx context.Context, orderNo string, fromStatus, toStatus int8, fields map[string]interface{}, history *model.OrderStatusHistory) error
+}
+
+type edge struct {
+	from int8
+	to   int8
+}
+
+// Machine 订单状态机：声明允许的流转、守卫与副作用，所有订单状态变更都通过 Fire 执行并记录历史
+type Machine struct {
+	store  Store
+	edges  map[Event][]edge
+	guards map[Event][]Guard
+	hooks  map[Event][]Hook
+}
+
+// New 创建订单状态机
+func New(store Store) *Machine {
+	return &Machine{
+		store:  store,
+		edges:  make(map[Event][]edge),
+		guards: make(map[Event][]Guard),
+		hooks:  make(map[Event][]Hook),
+	}
+}
+
+// Allow 声明 event 可将订单从 from 中任一状态流转到 to
+func (m *Machine) Allow(event Event, to int8, from ...int8) *Machine {
+	for _, f := range from {
+		m.edges[event] = append(m.edges[event], edge{from: f, to: to})
+	}
+	return m
+}
+
+// Guard 为 event 注册守卫，按注册顺序执行
+func (m *Machine) Guard(event Event, guards ...Guard) *Machine {
+	m.guards[event] = append(m.guards[event], guards...)
+	return m
+}
+
+// After 为 event 注册副作用，按注册顺序执行
+func (m *Machine) After(event Event, hooks ...Hook) *Machine {
+	m.hooks[event] = append(m.hooks[event], hooks...)
+	return m
+}
+
+// Can 流转表中是否允许 event 将订单从 from 流转到 to
+func (m *Machine) Can(event Event, from, to int8) bool {
+	for _, e := range m.edges[event] {
+		if e.from == from && e.to == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Fire 执行一次状态流转：校验流转表 -> 执行守卫 -> 乐观锁更新状态并写入历史 -> 执行副作用
+// 订单不存在或状态已被其他请求修改时返回 Store 的错误（gorm.ErrRecordNotFound），调用方据此做幂等处理
+func (m *Machine) Fire(ctx context.Context, t *Transition) error {
+	if t.Order == nil {
+		order, items, err := m.store.GetOrderByNoNoUser(ctx, t.OrderNo)
+		if err != nil {
+			return err
+		}
+		t.Order, t.Items = order, items
+	}
+	if t.OrderNo == "" {
+		t.OrderNo = t.Order.OrderNo
+	}
+	if t.From == 0 {
+		t.From = t.Order.Status
+	}
+	if !m.Can(t.Event, t.From, t.To) {
+		return fmt.Errorf("%w: orderNo=%s, event=%s, %d -> %d", ErrTransitionNotAllowed, t.OrderNo, t.Event, t.From, t.To)
+	}
+
+	for _, guard := range m.guards[t.Event] {
+		if err := guard(ctx, t); err != nil {
+			return err
+		}
+	}
+
+	operator := t.Operator
+	if operator == "" {
+		operator = middleware.GetUserIDFromContext(ctx)
+	}
+	if operator == "" {
+		operator = model.OperatorSystem
+	}
+	history := &model.OrderStatusHistory{
+		OrderNo:    t.OrderNo,
+		FromStatus: t.From,
+		ToStatus:   t.To,
+		Event:      string(t.Event),
+		Operator:   operator,
+		Reason:     t.Reason,
+		TraceID:    middleware.GetTraceID(ctx),
+	}
+	if err := m.store.TransitOrderStatus(ctx, t.OrderNo, t.From, t.To, t.Fields, history); err != nil {
+		return err
+	}
+	log.Printf("✅ [OrderStateMachine] 订单状态流转: orderNo=%s, event=%s, %d -> %d, operator=%s", t.OrderNo, t.Event, t.From, t.To, operator)
+
+	for _, hook := range m.hooks[t.Event] {
+		if err := hook(ctx, t); err != nil {
+			return fmt.Errorf("%w: orderNo=%s, event=%s, err=%v", ErrHookFailed, t.OrderNo, t.Event, err)
+		}
+	}
+	return nil
+}
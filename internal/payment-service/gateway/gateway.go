@@ -0,0 +1,245 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"zjMall/internal/payment-service/model"
+)
+
+var (
+	// ErrGatewayNotFound 渠道未配置或未启用
+	ErrGatewayNotFound = errors.New("支付渠道不可用")
+	// ErrTradeNotFound 第三方平台不存在该交易
+	ErrTradeNotFound = errors.New("第三方交易不存在")
+	// ErrTradeAlreadyPaid 交易已支付，不能关闭
+	ErrTradeAlreadyPaid = errors.New("第三方交易已支付")
+)
+
+// 第三方交易状态
+const (
+	TradeStatusWaitPay = "WAIT_PAY" // 待支付
+	TradeStatusSuccess = "SUCCESS"  // 支付成功
+	TradeStatusFailed  = "FAILED"   // 支付失败
+	TradeStatusClosed  = "CLOSED"   // 已关闭
+)
+
+// 第三方退款状态
+const (
+	RefundStatusProcessing = "PROCESSING" // 退款处理中
+	RefundStatusSuccess    = "SUCCESS"    // 退款成功
+	RefundStatusFailed     = "FAILED"     // 退款失败
+)
+
+// CreatePaymentRequest 下单请求
+type CreatePaymentRequest struct {
+	PaymentNo string
+	OrderNo   string
+	Amount    float64
+	Subject   string
+	NotifyURL string
+	ReturnURL string
+	ExpiredAt *time.Time
+}
+
+// CreatePaymentResult 下单结果（前端调起支付所需参数）
+type CreatePaymentResult struct {
+	PayURL    string            // 支付跳转URL（H5/PC）
+	QRCode    string            // 支付二维码（移动端）
+	PayParams map[string]string // 支付参数（前端调起支付用）
+}
+
+// QueryPaymentResult 查询交易结果
+type QueryPaymentResult struct {
+	Status  string // 交易状态，见 TradeStatus* 常量
+	TradeNo string // 第三方交易号
+	Amount  float64
+	PaidAt  *time.Time
+}
+
+// RefundRequest 退款请求
+type RefundRequest struct {
+	RefundNo     string
+	PaymentNo    string
+	TradeNo      string
+	RefundAmount float64
+	TotalAmount  float64
+	Reason       string
+}
+
+// RefundResult 退款受理结果（最终结果通过退款回调通知）
+type RefundResult struct {
+	Status        string // 退款状态，见 RefundStatus* 常量
+	RefundTradeNo string // 第三方退款交易号
+}
+
+// PaymentGateway 支付渠道网关，屏蔽各第三方平台的接口差异
+type PaymentGateway interface {
+	// Channel 返回网关对应的渠道配置
+	Channel() *model.PaymentChannel
+	// CreatePayment 在第三方平台下单
+	CreatePayment(ctx context.Context, req *CreatePaymentRequest) (*CreatePaymentResult, error)
+	// QueryPayment 查询第三方交易状态
+	QueryPayment(ctx context.Context, paymentNo string) (*QueryPaymentResult, error)
+	// ClosePayment 关闭第三方交易，已支付时返回 ErrTradeAlreadyPaid
+	ClosePayment(ctx context.Context, paymentNo string) error
+	// Refund 发起退款
+	Refund(ctx context.Context, req *RefundRequest) (*RefundResult, error)
+}
+
+// PaymentNotification 第三方支付结果通知
+type PaymentNotification struct {
+	PayChannel  string
+	PaymentNo   string
+	TradeNo     string
+	Amount      string
+	Status      string
+	Sign        string
+	ExtraParams map[string]string
+}
+
+// RefundNotification 第三方退款结果通知
+type RefundNotification struct {
+	PayChannel    string
+	RefundNo      string
+	RefundTradeNo string
+	Amount        string
+	Status        string
+	Sign          string
+	ExtraParams   map[string]string
+}
+
+// Notifier 接收网关的异步通知（沙箱网关在进程内直接投递回调）
+type Notifier interface {
+	NotifyPayment(ctx context.Context, n *PaymentNotification) error
+	NotifyRefund(ctx context.Context, n *RefundNotification) error
+}
+
+// Factory 根据渠道配置创建网关
+type Factory func(channel *model.PaymentChannel, notifier Notifier) (PaymentGateway, error)
+
+// AnyChannel 通配渠道代码，用于注册对所有渠道生效的工厂（如沙箱网关）
+const AnyChannel = "*"
+
+// Registry 支付网关注册表，按渠道代码管理已启用的网关
+type Registry struct {
+	mu          sync.RWMutex
+	environment string
+	explicit    bool               // 是否显式配置了环境（未配置时按沙箱加载渠道，但不视为沙箱放开的能力）
+	factories   map[string]Factory // key: environment/channelCode
+	gateways    map[string]PaymentGateway
+	defaultCode string
+}
+
+// NewRegistry 创建网关注册表，仅加载与 environment 一致的渠道配置
+// 沙箱环境默认对所有渠道使用本地沙箱网关
+func NewRegistry(environment string) *Registry {
+	explicit := environment != ""
+	if !explicit {
+		environment = model.EnvironmentSandbox
+	}
+	r := &Registry{
+		environment: environment,
+		explicit:    explicit,
+		factories:   make(map[string]Factory),
+		gateways:    make(map[string]PaymentGateway),
+	}
+	r.RegisterFactory(model.EnvironmentSandbox, AnyChannel, NewSandboxGateway)
+	return r
+}
+
+// RegisterFactory 注册网关工厂，channelCode 为 AnyChannel 时对该环境下所有渠道生效
+func (r *Registry) RegisterFactory(environment, channelCode string, factory Factory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.factories[environment+"/"+channelCode] = factory
+}
+
+// Load 根据渠道配置（重新）构建网关，忽略未启用、环境不匹配及站内余额渠道
+func (r *Registry) Load(channels []*model.PaymentChannel, notifier Notifier) {
+	gateways := make(map[string]PaymentGateway)
+	defaultCode := ""
+
+	r.mu.RLock()
+	factories := make(map[string]Factory, len(r.factories))
+	for k, f := range r.factories {
+		factories[k] = f
+	}
+	r.mu.RUnlock()
+
+	for _, channel := range channels {
+		if channel == nil || !channel.IsEnabled || channel.Environment != r.environment {
+			continue
+		}
+		if channel.IsDefault && defaultCode == "" {
+			defaultCode = channel.ChannelCode
+		}
+		// 余额支付为站内渠道，不经过第三方网关
+		if channel.ChannelCode == model.PayChannelBalance {
+			continue
+		}
+		if _, exists := gateways[channel.ChannelCode]; exists {
+			log.Printf("⚠️ [GatewayRegistry] 渠道配置重复，忽略: channel=%s, id=%s", channel.ChannelCode, channel.ID)
+			continue
+		}
+
+		factory, ok := factories[r.environment+"/"+channel.ChannelCode]
+		if !ok {
+			factory, ok = factories[r.environment+"/"+AnyChannel]
+		}
+		if !ok {
+			log.Printf("⚠️ [GatewayRegistry] 渠道未实现网关，跳过: channel=%s, environment=%s", channel.ChannelCode, r.environment)
+			continue
+		}
+
+		gw, err := factory(channel, notifier)
+		if err != nil {
+			log.Printf("⚠️ [GatewayRegistry] 创建网关失败，跳过: channel=%s, err=%v", channel.ChannelCode, err)
+			continue
+		}
+		gateways[channel.ChannelCode] = gw
+	}
+
+	r.mu.Lock()
+	r.gateways = gateways
+	r.defaultCode = defaultCode
+	r.mu.Unlock()
+
+	log.Printf("✅ [GatewayRegistry] 支付网关加载完成: environment=%s, count=%d, default=%s", r.environment, len(gateways), defaultCode)
+}
+
+// Get 获取渠道网关，channelCode 为空时返回默认渠道网关
+func (r *Registry) Get(channelCode string) (PaymentGateway, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if channelCode == "" {
+		channelCode = r.defaultCode
+	}
+	gw, ok := r.gateways[channelCode]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrGatewayNotFound, channelCode)
+	}
+	return gw, nil
+}
+
+// Environment 返回注册表加载的渠道环境
+func (r *Registry) Environment() string {
+	return r.environment
+}
+
+// ExplicitSandbox 是否显式配置为沙箱环境（未配置环境时视为生产，用于自助充值等仅限沙箱的能力）
+func (r *Registry) ExplicitSandbox() bool {
+	return r.explicit && r.environment == model.EnvironmentSandbox
+}
+
+// DefaultChannel 返回默认渠道代码（未配置时为空，可能为不经过网关的余额渠道）
+func (r *Registry) DefaultChannel() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.defaultCode
+}
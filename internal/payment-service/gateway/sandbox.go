@@ -0,0 +1,304 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"zjMall/internal/payment-service/model"
+	"zjMall/internal/payment-service/signature"
+)
+
+// 沙箱回调结果
+const (
+	SandboxResultSuccess = "success" // 模拟支付/退款成功
+	SandboxResultFailed  = "failed"  // 模拟支付/退款失败
+	SandboxResultNone    = "none"    // 不发送回调（模拟用户未支付或回调丢失）
+)
+
+// SandboxScenario 沙箱场景配置
+type SandboxScenario struct {
+	Result     string        // 回调结果，见 SandboxResult* 常量
+	Delay      time.Duration // 回调延迟
+	Duplicates int           // 额外重复投递回调的次数（模拟第三方重复通知）
+}
+
+// DefaultSandboxScenario 默认场景：2 秒后回调成功，不重复投递
+var DefaultSandboxScenario = SandboxScenario{
+	Result: SandboxResultSuccess,
+	Delay:  2 * time.Second,
+}
+
+type sandboxTrade struct {
+	paymentNo string
+	tradeNo   string
+	amount    float64
+	status    string
+	paidAt    *time.Time
+}
+
+// SandboxGateway 进程内沙箱网关：在内存中记录交易，并按场景用沙箱签名器签名后投递回调
+type SandboxGateway struct {
+	channel  *model.PaymentChannel
+	signer   signature.Signer
+	notifier Notifier
+
+	mu        sync.Mutex
+	scenario  SandboxScenario
+	overrides map[string]SandboxScenario // key: 支付单号或退款单号
+	trades    map[string]*sandboxTrade
+}
+
+// NewSandboxGateway 创建沙箱网关，渠道必须为沙箱环境且已配置签名密钥
+func NewSandboxGateway(channel *model.PaymentChannel, notifier Notifier) (PaymentGateway, error) {
+	signer, err := signature.NewSandboxSigner(channel)
+	if err != nil {
+		return nil, err
+	}
+	return &SandboxGateway{
+		channel:   channel,
+		signer:    signer,
+		notifier:  notifier,
+		scenario:  DefaultSandboxScenario,
+		overrides: make(map[string]SandboxScenario),
+		trades:    make(map[string]*sandboxTrade),
+	}, nil
+}
+
+// SetScenario 设置默认场景
+func (g *SandboxGateway) SetScenario(scenario SandboxScenario) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.scenario = scenario
+}
+
+// SetScenarioFor 为指定支付单号或退款单号设置场景（优先于默认场景）
+func (g *SandboxGateway) SetScenarioFor(no string, scenario SandboxScenario) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.overrides[no] = scenario
+}
+
+func (g *SandboxGateway) scenarioFor(no string) SandboxScenario {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if s, ok := g.overrides[no]; ok {
+		delete(g.overrides, no)
+		return s
+	}
+	return g.scenario
+}
+
+// Channel 返回渠道配置
+func (g *SandboxGateway) Channel() *model.PaymentChannel {
+	return g.channel
+}
+
+// CreatePayment 沙箱下单，按场景异步投递支付回调
+func (g *SandboxGateway) CreatePayment(ctx context.Context, req *CreatePaymentRequest) (*CreatePaymentResult, error) {
+	g.mu.Lock()
+	if trade, ok := g.trades[req.PaymentNo]; ok && trade.status != TradeStatusWaitPay {
+		g.mu.Unlock()
+		return nil, fmt.Errorf("沙箱交易状态不允许重复下单: payment_no=%s, status=%s", req.PaymentNo, trade.status)
+	}
+	g.trades[req.PaymentNo] = &sandboxTrade{
+		paymentNo: req.PaymentNo,
+		amount:    req.Amount,
+		status:    TradeStatusWaitPay,
+	}
+	g.mu.Unlock()
+
+	scenario := g.scenarioFor(req.PaymentNo)
+	if scenario.Result != SandboxResultNone {
+		time.AfterFunc(scenario.Delay, func() { g.settlePayment(req.PaymentNo, scenario) })
+	}
+
+	log.Printf("📚 [SandboxGateway] 沙箱下单: channel=%s, payment_no=%s, amount=%.2f, result=%s, delay=%s, duplicates=%d",
+		g.channel.ChannelCode, req.PaymentNo, req.Amount, scenario.Result, scenario.Delay, scenario.Duplicates)
+
+	return g.buildPayResult(req), nil
+}
+
+// QueryPayment 查询沙箱交易
+func (g *SandboxGateway) QueryPayment(ctx context.Context, paymentNo string) (*QueryPaymentResult, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	trade, ok := g.trades[paymentNo]
+	if !ok {
+		return nil, ErrTradeNotFound
+	}
+	return &QueryPaymentResult{
+		Status:  trade.status,
+		TradeNo: trade.tradeNo,
+		Amount:  trade.amount,
+		PaidAt:  trade.paidAt,
+	}, nil
+}
+
+// ClosePayment 关闭沙箱交易，关闭后不再投递支付回调
+func (g *SandboxGateway) ClosePayment(ctx context.Context, paymentNo string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	trade, ok := g.trades[paymentNo]
+	if !ok {
+		return ErrTradeNotFound
+	}
+	switch trade.status {
+	case TradeStatusSuccess:
+		return ErrTradeAlreadyPaid
+	case TradeStatusWaitPay:
+		trade.status = TradeStatusClosed
+	}
+	return nil
+}
+
+// Refund 沙箱退款，按场景异步投递退款回调
+func (g *SandboxGateway) Refund(ctx context.Context, req *RefundRequest) (*RefundResult, error) {
+	g.mu.Lock()
+	trade, ok := g.trades[req.PaymentNo]
+	g.mu.Unlock()
+	// 进程重启后内存交易丢失，沙箱下以请求中的原交易号为准继续受理
+	if ok && trade.status != TradeStatusSuccess {
+		return nil, fmt.Errorf("沙箱交易未支付成功，无法退款: payment_no=%s, status=%s", req.PaymentNo, trade.status)
+	}
+
+	refundTradeNo := fmt.Sprintf("SANDBOX_R_%s", req.RefundNo)
+	scenario := g.scenarioFor(req.RefundNo)
+	if scenario.Result != SandboxResultNone {
+		time.AfterFunc(scenario.Delay, func() { g.notifyRefund(req, refundTradeNo, scenario) })
+	}
+
+	log.Printf("📚 [SandboxGateway] 沙箱退款受理: channel=%s, refund_no=%s, amount=%.2f, result=%s",
+		g.channel.ChannelCode, req.RefundNo, req.RefundAmount, scenario.Result)
+
+	return &RefundResult{
+		Status:        RefundStatusProcessing,
+		RefundTradeNo: refundTradeNo,
+	}, nil
+}
+
+// settlePayment 按场景更新沙箱交易状态并投递支付回调
+func (g *SandboxGateway) settlePayment(paymentNo string, scenario SandboxScenario) {
+	g.mu.Lock()
+	trade, ok := g.trades[paymentNo]
+	if !ok || trade.status != TradeStatusWaitPay {
+		// 交易已关闭（超时关单）或已结算，不再回调
+		g.mu.Unlock()
+		return
+	}
+	trade.tradeNo = fmt.Sprintf("SANDBOX_%s", paymentNo)
+	if scenario.Result == SandboxResultSuccess {
+		now := time.Now()
+		trade.status = TradeStatusSuccess
+		trade.paidAt = &now
+	} else {
+		trade.status = TradeStatusFailed
+	}
+	n := &PaymentNotification{
+		PayChannel: g.channel.ChannelCode,
+		PaymentNo:  trade.paymentNo,
+		TradeNo:    trade.tradeNo,
+		Amount:     fmt.Sprintf("%.2f", trade.amount),
+		Status:     trade.status,
+	}
+	g.mu.Unlock()
+
+	sign, err := g.signer.Sign(signature.PaymentCallbackParams(n.PaymentNo, n.TradeNo, n.Amount, n.Status, n.ExtraParams))
+	if err != nil {
+		log.Printf("❌ [SandboxGateway] 支付回调签名失败: payment_no=%s, err=%v", paymentNo, err)
+		return
+	}
+	n.Sign = sign
+
+	for i := 0; i <= scenario.Duplicates; i++ {
+		if g.notifier == nil {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err := g.notifier.NotifyPayment(ctx, n)
+		cancel()
+		if err != nil {
+			log.Printf("⚠️ [SandboxGateway] 支付回调投递失败: payment_no=%s, attempt=%d, err=%v", paymentNo, i+1, err)
+		}
+	}
+}
+
+// notifyRefund 按场景投递退款回调
+func (g *SandboxGateway) notifyRefund(req *RefundRequest, refundTradeNo string, scenario SandboxScenario) {
+	status := RefundStatusSuccess
+	if scenario.Result != SandboxResultSuccess {
+		status = RefundStatusFailed
+	}
+	n := &RefundNotification{
+		PayChannel:    g.channel.ChannelCode,
+		RefundNo:      req.RefundNo,
+		RefundTradeNo: refundTradeNo,
+		Amount:        fmt.Sprintf("%.2f", req.RefundAmount),
+		Status:        status,
+	}
+	if status == RefundStatusFailed {
+		n.ExtraParams = map[string]string{"error_message": "沙箱模拟退款失败"}
+	}
+
+	sign, err := g.signer.Sign(signature.RefundCallbackParams(n.RefundNo, n.RefundTradeNo, n.Amount, n.Status, n.ExtraParams))
+	if err != nil {
+		log.Printf("❌ [SandboxGateway] 退款回调签名失败: refund_no=%s, err=%v", req.RefundNo, err)
+		return
+	}
+	n.Sign = sign
+
+	for i := 0; i <= scenario.Duplicates; i++ {
+		if g.notifier == nil {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err := g.notifier.NotifyRefund(ctx, n)
+		cancel()
+		if err != nil {
+			log.Printf("⚠️ [SandboxGateway] 退款回调投递失败: refund_no=%s, attempt=%d, err=%v", req.RefundNo, i+1, err)
+		}
+	}
+}
+
+// buildPayResult 生成沙箱支付参数（与真实渠道返回的字段保持一致，便于前端联调）
+func (g *SandboxGateway) buildPayResult(req *CreatePaymentRequest) *CreatePaymentResult {
+	result := &CreatePaymentResult{PayParams: make(map[string]string)}
+
+	switch g.channel.ChannelCode {
+	case model.PayChannelWeChat:
+		result.PayURL = fmt.Sprintf("https://pay.weixin.qq.com/sandbox?payment_no=%s&amount=%.2f&order_no=%s",
+			req.PaymentNo, req.Amount, req.OrderNo)
+		result.QRCode = fmt.Sprintf("weixin://wxpay/bizpayurl?pr=SANDBOX_%s", req.PaymentNo)
+		result.PayParams = map[string]string{
+			"appId":     g.channel.AppID,
+			"timeStamp": fmt.Sprintf("%d", time.Now().Unix()),
+			"nonceStr":  fmt.Sprintf("sandbox_%s", req.PaymentNo),
+			"package":   fmt.Sprintf("prepay_id=SANDBOX_%s", req.PaymentNo),
+			"signType":  "HMAC-SHA256",
+		}
+	case model.PayChannelAlipay:
+		result.PayURL = fmt.Sprintf("https://openapi.alipaydev.com/gateway.do?payment_no=%s&amount=%.2f&order_no=%s",
+			req.PaymentNo, req.Amount, req.OrderNo)
+		result.QRCode = fmt.Sprintf("https://qr.alipay.com/sandbox_%s", req.PaymentNo)
+		result.PayParams = map[string]string{
+			"app_id":    g.channel.AppID,
+			"method":    "alipay.trade.app.pay",
+			"charset":   "utf-8",
+			"sign_type": "RSA2",
+			"timestamp": time.Now().Format("2006-01-02 15:04:05"),
+			"version":   "1.0",
+			"biz_content": fmt.Sprintf(`{"out_trade_no":"%s","total_amount":"%.2f","subject":"%s"}`,
+				req.PaymentNo, req.Amount, req.Subject),
+		}
+	default:
+		result.PayURL = fmt.Sprintf("sandbox://%s/pay?payment_no=%s&amount=%.2f", g.channel.ChannelCode, req.PaymentNo, req.Amount)
+	}
+
+	// 对支付参数签名，前端可原样透传给收银台
+	if sign, err := g.signer.Sign(result.PayParams); err == nil {
+		result.PayParams["sign"] = sign
+	}
+	return result
+}
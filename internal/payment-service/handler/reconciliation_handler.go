@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	paymentv1 "zjMall/gen/go/api/proto/payment"
+	"zjMall/internal/common/middleware"
+	"zjMall/internal/payment-service/model"
+	"zjMall/internal/payment-service/repository"
+	"zjMall/internal/payment-service/service"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ImportReconciliationStatement 导入渠道对账单并比对（管理员）
+func (h *PaymentHandler) ImportReconciliationStatement(ctx context.Context, req *paymentv1.ImportReconciliationStatementRequest) (*paymentv1.ImportReconciliationStatementResponse, error) {
+	if !middleware.CheckRole(ctx, "admin") {
+		return &paymentv1.ImportReconciliationStatementResponse{
+			Code:    403,
+			Message: "权限不足：需要管理员权限",
+		}, nil
+	}
+
+	validator := service.NewImportReconciliationStatementRequestValidator(req)
+	if err := validator.Validate(); err != nil {
+		return &paymentv1.ImportReconciliationStatementResponse{
+			Code:    1,
+			Message: err.Error(),
+		}, nil
+	}
+
+	summary, err := h.svc.ImportStatement(ctx, req.PayChannel, req.BillDate, strings.NewReader(req.Content))
+	if err != nil {
+		log.Printf("❌ [PaymentHandler] ImportReconciliationStatement: 对账失败 channel=%s, bill_date=%s, err=%v", req.PayChannel, req.BillDate, err)
+		return &paymentv1.ImportReconciliationStatementResponse{
+			Code:    1,
+			Message: fmt.Sprintf("对账失败: %v", err),
+		}, nil
+	}
+
+	return &paymentv1.ImportReconciliationStatementResponse{
+		Code:            0,
+		Message:         "success",
+		TotalRows:       int32(summary.TotalRows),
+		MatchedRows:     int32(summary.MatchedRows),
+		MismatchRows:    int32(summary.MismatchRows),
+		NewMismatchRows: int32(summary.NewMismatchRows),
+	}, nil
+}
+
+// ListReconciliationResults 查询对账差异列表（管理员）
+func (h *PaymentHandler) ListReconciliationResults(ctx context.Context, req *paymentv1.ListReconciliationResultsRequest) (*paymentv1.ListReconciliationResultsResponse, error) {
+	if !middleware.CheckRole(ctx, "admin") {
+		return &paymentv1.ListReconciliationResultsResponse{
+			Code:    403,
+			Message: "权限不足：需要管理员权限",
+		}, nil
+	}
+
+	results, total, err := h.svc.ListReconciliationResults(ctx, repository.ReconciliationFilter{
+		PayChannel:   req.PayChannel,
+		BillDate:     req.BillDate,
+		MismatchType: int8(req.MismatchType),
+		Status:       int8(req.Status),
+	}, int(req.Page), int(req.PageSize))
+	if err != nil {
+		log.Printf("❌ [PaymentHandler] ListReconciliationResults: 查询对账差异失败 err=%v", err)
+		return &paymentv1.ListReconciliationResultsResponse{
+			Code:    1,
+			Message: fmt.Sprintf("查询对账差异失败: %v", err),
+		}, nil
+	}
+
+	data := make([]*paymentv1.ReconciliationResult, 0, len(results))
+	for _, result := range results {
+		data = append(data, h.convertReconciliationToProto(result))
+	}
+	return &paymentv1.ListReconciliationResultsResponse{
+		Code:    0,
+		Message: "success",
+		Results: data,
+		Total:   total,
+	}, nil
+}
+
+// ResolveReconciliationResult 处理对账差异（管理员）
+func (h *PaymentHandler) ResolveReconciliationResult(ctx context.Context, req *paymentv1.ResolveReconciliationResultRequest) (*paymentv1.ResolveReconciliationResultResponse, error) {
+	if !middleware.CheckRole(ctx, "admin") {
+		return &paymentv1.ResolveReconciliationResultResponse{
+			Code:    403,
+			Message: "权限不足：需要管理员权限",
+		}, nil
+	}
+
+	validator := service.NewResolveReconciliationResultRequestValidator(req)
+	if err := validator.Validate(); err != nil {
+		return &paymentv1.ResolveReconciliationResultResponse{
+			Code:    1,
+			Message: err.Error(),
+		}, nil
+	}
+
+	result, err := h.svc.ResolveReconciliationResult(ctx, req.Id, int8(req.Status), req.Remark, middleware.GetUserIDFromContext(ctx))
+	if err != nil {
+		log.Printf("❌ [PaymentHandler] ResolveReconciliationResult: 处理对账差异失败 id=%s, err=%v", req.Id, err)
+		return &paymentv1.ResolveReconciliationResultResponse{
+			Code:    1,
+			Message: fmt.Sprintf("处理对账差异失败: %v", err),
+		}, nil
+	}
+
+	return &paymentv1.ResolveReconciliationResultResponse{
+		Code:    0,
+		Message: "success",
+		Result:  h.convertReconciliationToProto(result),
+	}, nil
+}
+
+// convertReconciliationToProto 转换 ReconciliationResult 模型为 proto 消息
+func (h *PaymentHandler) convertReconciliationToProto(result *model.ReconciliationResult) *paymentv1.ReconciliationResult {
+	if result == nil {
+		return nil
+	}
+
+	protoResult := &paymentv1.ReconciliationResult{
+		Id:            result.ID,
+		PayChannel:    result.PayChannel,
+		BillDate:      result.BillDate,
+		MismatchType:  paymentv1.ReconciliationMismatchType(result.MismatchType),
+		TradeNo:       result.TradeNo,
+		PaymentNo:     result.PaymentNo,
+		OrderNo:       result.OrderNo,
+		LocalAmount:   fmt.Sprintf("%.2f", result.LocalAmount),
+		LocalStatus:   paymentv1.PaymentStatus(result.LocalStatus),
+		ChannelAmount: fmt.Sprintf("%.2f", result.ChannelAmount),
+		ChannelStatus: result.ChannelStatus,
+		Status:        paymentv1.ReconciliationStatus(result.Status),
+		ResolveRemark: result.ResolveRemark,
+		ResolvedBy:    result.ResolvedBy,
+		CreatedAt:     timestamppb.New(result.CreatedAt),
+	}
+	if result.ChannelTradeTime != nil {
+		protoResult.ChannelTradeTime = timestamppb.New(*result.ChannelTradeTime)
+	}
+	if result.ResolvedAt != nil {
+		protoResult.ResolvedAt = timestamppb.New(*result.ResolvedAt)
+	}
+	return protoResult
+}
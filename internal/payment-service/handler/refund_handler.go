@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	paymentv1 "zjMall/gen/go/api/proto/payment"
+	"zjMall/internal/common/middleware"
+	"zjMall/internal/payment-service/model"
+	"zjMall/internal/payment-service/service"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// CreateRefund 申请退款（仅管理员或售后审核通过后的服务间调用，买家退款走售后申请）
+func (h *PaymentHandler) CreateRefund(ctx context.Context, req *paymentv1.CreateRefundRequest) (*paymentv1.CreateRefundResponse, error) {
+	if !middleware.CheckRole(ctx, "admin", middleware.RoleInternalService) {
+		return &paymentv1.CreateRefundResponse{
+			Code:    403,
+			Message: "权限不足：需要管理员权限",
+		}, nil
+	}
+
+	// 1. 参数校验（使用 validator）
+	validator := service.NewCreateRefundRequestValidator(req)
+	if err := validator.Validate(); err != nil {
+		return &paymentv1.CreateRefundResponse{
+			Code:    1,
+			Message: err.Error(),
+		}, nil
+	}
+
+	// 2. 调用服务层创建退款单
+	refund, err := h.svc.CreateRefund(ctx, &service.CreateRefundRequest{
+		OrderNo:      req.OrderNo,
+		UserID:       middleware.GetUserIDFromContext(ctx),
+		RefundAmount: req.RefundAmount,
+		RefundReason: req.RefundReason,
+		RequestNo:    req.RequestNo,
+	})
+	if err != nil {
+		log.Printf("❌ [PaymentHandler] CreateRefund: 申请退款失败 order_no=%s, err=%v", req.OrderNo, err)
+		return &paymentv1.CreateRefundResponse{
+			Code:    1,
+			Message: fmt.Sprintf("申请退款失败: %v", err),
+		}, nil
+	}
+
+	return &paymentv1.CreateRefundResponse{
+		Code:    0,
+		Message: "success",
+		Refund:  h.convertRefundToProto(refund),
+	}, nil
+}
+
+// GetRefund 查询退款单
+func (h *PaymentHandler) GetRefund(ctx context.Context, req *paymentv1.GetRefundRequest) (*paymentv1.GetRefundResponse, error) {
+	// 1. 参数校验
+	if req.RefundNo == "" {
+		return &paymentv1.GetRefundResponse{
+			Code:    1,
+			Message: "退款单号不能为空",
+		}, nil
+	}
+
+	// 2. 调用服务层查询退款单
+	refund, err := h.svc.GetRefund(ctx, req.RefundNo)
+	if err != nil {
+		log.Printf("❌ [PaymentHandler] GetRefund: 查询退款单失败 refund_no=%s, err=%v", req.RefundNo, err)
+		return &paymentv1.GetRefundResponse{
+			Code:    1,
+			Message: fmt.Sprintf("查询退款单失败: %v", err),
+		}, nil
+	}
+
+	// 非管理员只能查询自己的退款单
+	if refund == nil || (refund.UserID != middleware.GetUserIDFromContext(ctx) && !middleware.CheckRole(ctx, "admin")) {
+		return &paymentv1.GetRefundResponse{
+			Code:    1,
+			Message: "退款单不存在",
+		}, nil
+	}
+
+	return &paymentv1.GetRefundResponse{
+		Code:    0,
+		Message: "success",
+		Refund:  h.convertRefundToProto(refund),
+	}, nil
+}
+
+// RefundCallback 退款回调（第三方支付平台回调）
+func (h *PaymentHandler) RefundCallback(ctx context.Context, req *paymentv1.RefundCallbackRequest) (*paymentv1.RefundCallbackResponse, error) {
+	// 1. 参数校验（使用 validator）
+	validator := service.NewRefundCallbackRequestValidator(req)
+	if err := validator.Validate(); err != nil {
+		return &paymentv1.RefundCallbackResponse{
+			Code:    1,
+			Message: err.Error(),
+		}, nil
+	}
+
+	// 2. 调用服务层处理回调
+	serviceReq := &service.RefundCallbackRequest{
+		PayChannel:    req.PayChannel,
+		RefundNo:      req.RefundNo,
+		RefundTradeNo: req.RefundTradeNo,
+		Amount:        req.Amount,
+		Status:        req.Status,
+		Sign:          req.Sign,
+		ExtraParams:   req.ExtraParams,
+	}
+	if err := h.svc.HandleRefundCallback(ctx, serviceReq); err != nil {
+		log.Printf("❌ [PaymentHandler] RefundCallback: 处理退款回调失败 refund_no=%s, err=%v", req.RefundNo, err)
+		return &paymentv1.RefundCallbackResponse{
+			Code:    1,
+			Message: fmt.Sprintf("处理退款回调失败: %v", err),
+		}, nil
+	}
+
+	// 3. 返回成功（第三方平台会重试直到收到成功响应）
+	return &paymentv1.RefundCallbackResponse{
+		Code:    0,
+		Message: "success",
+	}, nil
+}
+
+// convertRefundToProto 转换 Refund 模型为 proto 消息
+func (h *PaymentHandler) convertRefundToProto(refund *model.Refund) *paymentv1.Refund {
+	if refund == nil {
+		return nil
+	}
+
+	protoRefund := &paymentv1.Refund{
+		Id:            refund.ID,
+		RefundNo:      refund.RefundNo,
+		PaymentNo:     refund.PaymentNo,
+		OrderNo:       refund.OrderNo,
+		UserId:        refund.UserID,
+		RefundAmount:  fmt.Sprintf("%.2f", refund.RefundAmount),
+		RefundReason:  refund.RefundReason,
+		RefundType:    paymentv1.RefundType(refund.RefundType),
+		PayChannel:    refund.PayChannel,
+		Status:        paymentv1.RefundStatus(refund.Status),
+		TradeNo:       refund.TradeNo,
+		RefundTradeNo: refund.RefundTradeNo,
+		ErrorMessage:  refund.ErrorMessage,
+		CreatedAt:     timestamppb.New(refund.CreatedAt),
+		RequestNo:     refund.RequestNo,
+	}
+
+	if refund.RefundedAt != nil {
+		protoRefund.RefundedAt = timestamppb.New(*refund.RefundedAt)
+	}
+
+	return protoRefund
+}
@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	paymentv1 "zjMall/gen/go/api/proto/payment"
+	"zjMall/internal/common/middleware"
+	"zjMall/internal/payment-service/model"
+	"zjMall/internal/payment-service/service"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// GetWallet 查询钱包余额
+func (h *PaymentHandler) GetWallet(ctx context.Context, req *paymentv1.GetWalletRequest) (*paymentv1.GetWalletResponse, error) {
+	userID, code, message := resolveWalletUser(ctx, req.UserId)
+	if code != 0 {
+		return &paymentv1.GetWalletResponse{
+			Code:    code,
+			Message: message,
+		}, nil
+	}
+
+	wallet, err := h.svc.GetWallet(ctx, userID)
+	if err != nil {
+		log.Printf("❌ [PaymentHandler] GetWallet: 查询钱包失败 user_id=%s, err=%v", userID, err)
+		return &paymentv1.GetWalletResponse{
+			Code:    1,
+			Message: fmt.Sprintf("查询钱包失败: %v", err),
+		}, nil
+	}
+
+	return &paymentv1.GetWalletResponse{
+		Code:    0,
+		Message: "success",
+		Wallet:  h.convertWalletToProto(wallet),
+	}, nil
+}
+
+// TopUpWallet 钱包充值（管理员；显式配置为沙箱环境时允许用户为自己充值）
+func (h *PaymentHandler) TopUpWallet(ctx context.Context, req *paymentv1.TopUpWalletRequest) (*paymentv1.TopUpWalletResponse, error) {
+	userID, code, message := resolveWalletUser(ctx, req.UserId)
+	if code != 0 {
+		return &paymentv1.TopUpWalletResponse{
+			Code:    code,
+			Message: message,
+		}, nil
+	}
+	if !middleware.CheckRole(ctx, "admin") && !h.svc.SelfTopUpAllowed() {
+		return &paymentv1.TopUpWalletResponse{
+			Code:    403,
+			Message: "权限不足：需要管理员权限",
+		}, nil
+	}
+
+	validator := service.NewTopUpWalletRequestValidator(req)
+	if err := validator.Validate(); err != nil {
+		return &paymentv1.TopUpWalletResponse{
+			Code:    1,
+			Message: err.Error(),
+		}, nil
+	}
+
+	wallet, err := h.svc.TopUpWallet(ctx, &service.TopUpWalletRequest{
+		UserID:     userID,
+		TopUpNo:    req.TopUpNo,
+		Amount:     req.Amount,
+		Remark:     req.Remark,
+		OperatorID: middleware.GetUserIDFromContext(ctx),
+	})
+	if err != nil {
+		log.Printf("❌ [PaymentHandler] TopUpWallet: 充值失败 user_id=%s, top_up_no=%s, err=%v", userID, req.TopUpNo, err)
+		return &paymentv1.TopUpWalletResponse{
+			Code:    1,
+			Message: fmt.Sprintf("充值失败: %v", err),
+		}, nil
+	}
+
+	return &paymentv1.TopUpWalletResponse{
+		Code:    0,
+		Message: "success",
+		Wallet:  h.convertWalletToProto(wallet),
+	}, nil
+}
+
+// ListWalletLedger 查询钱包流水
+func (h *PaymentHandler) ListWalletLedger(ctx context.Context, req *paymentv1.ListWalletLedgerRequest) (*paymentv1.ListWalletLedgerResponse, error) {
+	userID, code, message := resolveWalletUser(ctx, req.UserId)
+	if code != 0 {
+		return &paymentv1.ListWalletLedgerResponse{
+			Code:    code,
+			Message: message,
+		}, nil
+	}
+
+	entries, total, err := h.svc.ListWalletLedger(ctx, userID, int(req.Page), int(req.PageSize))
+	if err != nil {
+		log.Printf("❌ [PaymentHandler] ListWalletLedger: 查询钱包流水失败 user_id=%s, err=%v", userID, err)
+		return &paymentv1.ListWalletLedgerResponse{
+			Code:    1,
+			Message: fmt.Sprintf("查询钱包流水失败: %v", err),
+		}, nil
+	}
+
+	data := make([]*paymentv1.WalletLedgerEntry, 0, len(entries))
+	for _, entry := range entries {
+		data = append(data, &paymentv1.WalletLedgerEntry{
+			Id:           entry.ID,
+			TxNo:         entry.TxNo,
+			BizType:      entry.BizType,
+			BizNo:        entry.BizNo,
+			Direction:    int32(entry.Direction),
+			Amount:       fmt.Sprintf("%.2f", entry.Amount),
+			BalanceAfter: fmt.Sprintf("%.2f", entry.BalanceAfter),
+			Remark:       entry.Remark,
+			CreatedAt:    timestamppb.New(entry.CreatedAt),
+		})
+	}
+	return &paymentv1.ListWalletLedgerResponse{
+		Code:    0,
+		Message: "success",
+		Entries: data,
+		Total:   total,
+	}, nil
+}
+
+// resolveWalletUser 确定操作的钱包用户：默认当前用户，仅管理员可指定其他用户
+func resolveWalletUser(ctx context.Context, requestUserID string) (string, int32, string) {
+	userID := middleware.GetUserIDFromContext(ctx)
+	if userID == "" {
+		return "", 1, "用户未登录"
+	}
+	if requestUserID == "" || requestUserID == userID {
+		return userID, 0, ""
+	}
+	if !middleware.CheckRole(ctx, "admin") {
+		return "", 403, "权限不足：只能操作自己的钱包"
+	}
+	return requestUserID, 0, ""
+}
+
+// convertWalletToProto 转换 Wallet 模型为 proto 消息
+func (h *PaymentHandler) convertWalletToProto(wallet *model.Wallet) *paymentv1.Wallet {
+	if wallet == nil {
+		return nil
+	}
+
+	protoWallet := &paymentv1.Wallet{
+		UserId:  wallet.UserID,
+		Balance: fmt.Sprintf("%.2f", wallet.Balance),
+	}
+	if !wallet.UpdatedAt.IsZero() {
+		protoWallet.UpdatedAt = timestamppb.New(wallet.UpdatedAt)
+	}
+	return protoWallet
+}
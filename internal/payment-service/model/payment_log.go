@@ -41,3 +41,25 @@ const (
 	PaymentLogActionClose        = "close"         // 关闭支付单
 	PaymentLogActionQuery        = "query"         // 查询支付状态
 )
+
+// 退款相关操作类型常量
+const (
+	PaymentLogActionRefund         = "refund"          // 申请退款
+	PaymentLogActionRefundCallback = "refund_callback" // 退款回调
+)
+
+// 回调验签操作类型常量
+const (
+	PaymentLogActionCallbackRejected = "callback_rejected" // 回调验签失败被拒绝
+)
+
+// 主动对账操作类型常量
+const (
+	PaymentLogActionReconcile = "reconcile" // 主动查询渠道发现状态不一致
+)
+
+// 对账单比对操作类型常量
+const (
+	PaymentLogActionStatementMismatch = "statement_mismatch" // 对账单比对发现差异
+	PaymentLogActionStatementResolve  = "statement_resolve"  // 对账差异处理
+)
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"time"
+	"zjMall/pkg"
+)
+
+// ReconciliationResult 对账差异表
+// 导入渠道对账单后与本地支付单逐笔比对，记录不一致的交易，供运营处理
+type ReconciliationResult struct {
+	pkg.BaseModel
+
+	PayChannel   string `gorm:"type:varchar(20);not null;uniqueIndex:uk_reconciliation_item,priority:1;comment:支付渠道" json:"pay_channel"`
+	BillDate     string `gorm:"type:varchar(10);not null;uniqueIndex:uk_reconciliation_item,priority:2;comment:账单日期（YYYY-MM-DD）" json:"bill_date"`
+	MismatchType int8   `gorm:"type:tinyint;not null;uniqueIndex:uk_reconciliation_item,priority:3;comment:差异类型：1-本地缺失，2-渠道缺失，3-金额不一致，4-状态不一致" json:"mismatch_type"`
+	TradeNo      string `gorm:"type:varchar(64);not null;default:'';uniqueIndex:uk_reconciliation_item,priority:4;comment:第三方交易号" json:"trade_no"`
+	PaymentNo    string `gorm:"type:varchar(32);not null;default:'';uniqueIndex:uk_reconciliation_item,priority:5;comment:支付单号（本地缺失时为空）" json:"payment_no"`
+	OrderNo      string `gorm:"type:varchar(32);not null;default:'';comment:订单号" json:"order_no"`
+
+	LocalAmount      float64    `gorm:"type:decimal(10,2);not null;default:0;comment:本地支付金额" json:"local_amount"`
+	LocalStatus      int8       `gorm:"type:tinyint;not null;default:0;comment:本地支付状态（本地缺失时为0）" json:"local_status"`
+	ChannelAmount    float64    `gorm:"type:decimal(10,2);not null;default:0;comment:渠道账单金额" json:"channel_amount"`
+	ChannelStatus    string     `gorm:"type:varchar(32);not null;default:'';comment:渠道账单交易状态（渠道缺失时为空）" json:"channel_status"`
+	ChannelTradeTime *time.Time `gorm:"type:timestamp;null;default:null;comment:渠道账单交易时间" json:"channel_trade_time"`
+
+	Status        int8       `gorm:"type:tinyint;not null;default:1;comment:处理状态：1-待处理，2-已处理，3-已忽略" json:"status"`
+	ResolveRemark string     `gorm:"type:varchar(255);comment:处理说明" json:"resolve_remark"`
+	ResolvedBy    string     `gorm:"type:varchar(26);comment:处理人ID" json:"resolved_by"`
+	ResolvedAt    *time.Time `gorm:"type:timestamp;null;default:null;comment:处理时间" json:"resolved_at"`
+	Version       int        `gorm:"type:int;not null;default:0;comment:版本号（乐观锁）" json:"version"`
+}
+
+func (ReconciliationResult) TableName() string {
+	return "reconciliation_results"
+}
+
+// 对账差异类型常量
+const (
+	ReconciliationMismatchMissingLocal   = int8(1) // 渠道有交易，本地无对应支付单
+	ReconciliationMismatchMissingChannel = int8(2) // 本地已支付，渠道账单无该交易
+	ReconciliationMismatchAmount         = int8(3) // 金额不一致
+	ReconciliationMismatchStatus         = int8(4) // 状态不一致
+)
+
+// 对账差异处理状态常量
+const (
+	ReconciliationStatusPending  = int8(1) // 待处理
+	ReconciliationStatusResolved = int8(2) // 已处理
+	ReconciliationStatusIgnored  = int8(3) // 已忽略
+)
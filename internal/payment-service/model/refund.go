@@ -12,8 +12,10 @@ type Refund struct {
 
 	RefundNo  string `gorm:"type:varchar(32);uniqueIndex;not null;comment:退款单号" json:"refund_no"`
 	PaymentNo string `gorm:"type:varchar(32);index;not null;comment:原支付单号" json:"payment_no"`
-	OrderNo   string `gorm:"type:varchar(32);index;not null;comment:订单号" json:"order_no"`
+	OrderNo   string `gorm:"type:varchar(32);index;index:idx_order_request,priority:1;not null;comment:订单号" json:"order_no"`
 	UserID    string `gorm:"type:varchar(26);index;not null;comment:用户ID" json:"user_id"`
+	// RequestNo 业务请求号（如售后单号），同一订单同一请求号只会存在一笔退款中或退款成功的退款单
+	RequestNo string `gorm:"type:varchar(64);index:idx_order_request,priority:2;comment:业务请求号（幂等键）" json:"request_no"`
 
 	RefundAmount float64 `gorm:"type:decimal(10,2);not null;default:0;comment:退款金额" json:"refund_amount"`
 	RefundReason string  `gorm:"type:varchar(255);comment:退款原因" json:"refund_reason"`
@@ -37,6 +39,11 @@ func (Refund) TableName() string {
 	return "refunds"
 }
 
+// 退款单号前缀常量
+const (
+	RefundNoPrefix = "20" // 退款单号前缀，用于区分支付单号(10)和退款单号(20)
+)
+
 // 退款类型常量
 const (
 	RefundTypeFull    = int8(1) // 全额退款
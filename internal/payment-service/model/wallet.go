@@ -0,0 +1,66 @@
+package model
+
+import (
+	"zjMall/pkg"
+)
+
+// Wallet 用户钱包表
+// 余额为平台对用户的负债，所有变动必须伴随钱包流水
+type Wallet struct {
+	pkg.BaseModel
+
+	UserID  string  `gorm:"type:varchar(26);uniqueIndex;not null;comment:用户ID" json:"user_id"`
+	Balance float64 `gorm:"type:decimal(12,2);not null;default:0;comment:可用余额" json:"balance"`
+	Version int     `gorm:"type:int;not null;default:0;comment:版本号" json:"version"`
+}
+
+func (Wallet) TableName() string {
+	return "wallets"
+}
+
+// WalletLedger 钱包流水表（复式记账）
+// 每笔业务产生借、贷两行金额相等的流水，同一业务单号的同一方向只允许记账一次
+type WalletLedger struct {
+	pkg.BaseModel
+
+	TxNo      string  `gorm:"type:varchar(32);index;not null;comment:记账流水号（同一笔业务的借贷两行相同）" json:"tx_no"`
+	BizType   string  `gorm:"type:varchar(20);not null;uniqueIndex:uk_wallet_ledger_entry,priority:1;comment:业务类型：topup-充值，payment-余额支付，refund-退款到余额" json:"biz_type"`
+	BizNo     string  `gorm:"type:varchar(64);not null;uniqueIndex:uk_wallet_ledger_entry,priority:2;comment:业务单号（充值单号/支付单号/退款单号）" json:"biz_no"`
+	Direction int8    `gorm:"type:tinyint;not null;uniqueIndex:uk_wallet_ledger_entry,priority:3;comment:记账方向：1-借，2-贷" json:"direction"`
+	Account   string  `gorm:"type:varchar(40);index;not null;comment:记账账户" json:"account"`
+	UserID    string  `gorm:"type:varchar(26);index;not null;comment:用户ID" json:"user_id"`
+	Amount    float64 `gorm:"type:decimal(12,2);not null;comment:金额" json:"amount"`
+	// BalanceAfter 用户钱包账户行记录变动后的余额，系统账户行为0
+	BalanceAfter float64 `gorm:"type:decimal(12,2);not null;default:0;comment:变动后余额（仅用户钱包账户）" json:"balance_after"`
+	Remark       string  `gorm:"type:varchar(255);comment:备注" json:"remark"`
+}
+
+func (WalletLedger) TableName() string {
+	return "wallet_ledgers"
+}
+
+// 钱包流水业务类型常量
+const (
+	WalletBizTopUp   = "topup"   // 充值
+	WalletBizPayment = "payment" // 余额支付
+	WalletBizRefund  = "refund"  // 退款到余额
+)
+
+// 钱包流水记账方向常量
+const (
+	WalletDirectionDebit  = int8(1) // 借
+	WalletDirectionCredit = int8(2) // 贷
+)
+
+// 钱包记账账户常量
+// 用户钱包为负债账户：贷方增加余额，借方减少余额
+const (
+	WalletAccountUserPrefix = "user:"             // 用户钱包账户前缀，完整账户为 user:{用户ID}
+	WalletAccountTopUp      = "system:topup"      // 充值资金清算账户
+	WalletAccountSettlement = "system:settlement" // 余额支付待结算账户（支付时入账，退款时出账）
+)
+
+// WalletUserAccount 返回用户钱包记账账户
+func WalletUserAccount(userID string) string {
+	return WalletAccountUserPrefix + userID
+}
@@ -20,6 +20,10 @@ type PaymentRepository interface {
 	UpdatePayment(ctx context.Context, payment *model.Payment) error
 	// GetExpiredPayments 查询超时的待支付支付单（用于定时任务）
 	GetExpiredPayments(ctx context.Context, limit int) ([]*model.Payment, error)
+	// GetStuckPayments 查询创建时间早于 before 且仍处于待支付/支付中的支付单（用于主动对账）
+	GetStuckPayments(ctx context.Context, before time.Time, limit int) ([]*model.Payment, error)
+	// ListPaidPayments 查询渠道在 [start, end) 内支付成功（含已退款）的支付单（用于对账单比对）
+	ListPaidPayments(ctx context.Context, payChannel string, start, end time.Time) ([]*model.Payment, error)
 	// GetPaymentByTradeNo 根据交易号查询支付单
 	GetPaymentByTradeNo(ctx context.Context, tradeNo string) (*model.Payment, error)
 	// WithTransaction 在事务中执行回调，提供事务内的 PaymentRepository
@@ -83,10 +87,19 @@ func (r *paymentRepository) UpdatePayment(ctx context.Context, payment *model.Pa
 		updateFields["return_url"] = payment.ReturnURL
 	}
 
-	return r.db.WithContext(ctx).
+	tx := r.db.WithContext(ctx).
 		Model(&model.Payment{}).
 		Where("id = ? AND version = ?", payment.ID, payment.Version).
-		Updates(updateFields).Error
+		Updates(updateFields)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	// 版本号不匹配说明支付单已被并发修改
+	if tx.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	payment.Version++
+	return nil
 }
 
 func (r *paymentRepository) GetExpiredPayments(ctx context.Context, limit int) ([]*model.Payment, error) {
@@ -104,6 +117,31 @@ func (r *paymentRepository) GetExpiredPayments(ctx context.Context, limit int) (
 	return payments, nil
 }
 
+func (r *paymentRepository) GetStuckPayments(ctx context.Context, before time.Time, limit int) ([]*model.Payment, error) {
+	var payments []*model.Payment
+	err := r.db.WithContext(ctx).
+		Where("status IN ? AND created_at < ?", []int8{model.PaymentStatusPending, model.PaymentStatusProcessing}, before).
+		Order("created_at ASC").
+		Limit(limit).
+		Find(&payments).Error
+	if err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+func (r *paymentRepository) ListPaidPayments(ctx context.Context, payChannel string, start, end time.Time) ([]*model.Payment, error) {
+	var payments []*model.Payment
+	err := r.db.WithContext(ctx).
+		Where("pay_channel = ? AND paid_at >= ? AND paid_at < ? AND status IN ?",
+			payChannel, start, end, []int8{model.PaymentStatusSuccess, model.PaymentStatusRefunded}).
+		Find(&payments).Error
+	if err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
 func (r *paymentRepository) GetPaymentByTradeNo(ctx context.Context, tradeNo string) (*model.Payment, error) {
 	var payment model.Payment
 	if err := r.db.WithContext(ctx).Where("trade_no = ?", tradeNo).First(&payment).Error; err != nil {
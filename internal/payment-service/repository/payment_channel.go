@@ -13,6 +13,8 @@ type PaymentChannelRepository interface {
 	GetPaymentChannelByChannelCode(ctx context.Context, channelCode string) (*model.PaymentChannel, error)
 	CreatePaymentChannel(ctx context.Context, paymentChannel *model.PaymentChannel) error
 	UpdatePaymentChannel(ctx context.Context, paymentChannel *model.PaymentChannel) error
+	// ListPaymentChannels 查询全部渠道配置（用于加载支付网关）
+	ListPaymentChannels(ctx context.Context) ([]*model.PaymentChannel, error)
 }
 
 type paymentChannelRepository struct {
@@ -41,3 +43,11 @@ func (r *paymentChannelRepository) CreatePaymentChannel(ctx context.Context, pay
 func (r *paymentChannelRepository) UpdatePaymentChannel(ctx context.Context, paymentChannel *model.PaymentChannel) error {
 	return r.db.WithContext(ctx).Model(&model.PaymentChannel{}).Where("id = ?", paymentChannel.ID).Updates(paymentChannel).Error
 }
+
+func (r *paymentChannelRepository) ListPaymentChannels(ctx context.Context) ([]*model.PaymentChannel, error) {
+	var channels []*model.PaymentChannel
+	if err := r.db.WithContext(ctx).Order("is_default DESC, created_at ASC").Find(&channels).Error; err != nil {
+		return nil, err
+	}
+	return channels, nil
+}
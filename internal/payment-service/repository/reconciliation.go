@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"zjMall/internal/payment-service/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReconciliationFilter 对账差异列表查询条件（零值表示不过滤）
+type ReconciliationFilter struct {
+	PayChannel   string
+	BillDate     string
+	MismatchType int8
+	Status       int8
+}
+
+type ReconciliationRepository interface {
+	// CreateReconciliationResult 记录对账差异，同一账单的同一差异已存在时忽略并返回 false
+	CreateReconciliationResult(ctx context.Context, result *model.ReconciliationResult) (bool, error)
+	// GetReconciliationResultByID 根据ID查询对账差异
+	GetReconciliationResultByID(ctx context.Context, id string) (*model.ReconciliationResult, error)
+	// ListReconciliationResults 分页查询对账差异
+	ListReconciliationResults(ctx context.Context, filter ReconciliationFilter, offset, limit int) ([]*model.ReconciliationResult, int64, error)
+	// UpdateReconciliationResult 更新对账差异处理结果（使用乐观锁）
+	UpdateReconciliationResult(ctx context.Context, result *model.ReconciliationResult) error
+}
+
+type reconciliationRepository struct {
+	db *gorm.DB
+}
+
+func NewReconciliationRepository(db *gorm.DB) ReconciliationRepository {
+	return &reconciliationRepository{db: db}
+}
+
+func (r *reconciliationRepository) CreateReconciliationResult(ctx context.Context, result *model.ReconciliationResult) (bool, error) {
+	tx := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(result)
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+	return tx.RowsAffected > 0, nil
+}
+
+func (r *reconciliationRepository) GetReconciliationResultByID(ctx context.Context, id string) (*model.ReconciliationResult, error) {
+	var result model.ReconciliationResult
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&result).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (r *reconciliationRepository) ListReconciliationResults(ctx context.Context, filter ReconciliationFilter, offset, limit int) ([]*model.ReconciliationResult, int64, error) {
+	query := r.db.WithContext(ctx).Model(&model.ReconciliationResult{})
+	if filter.PayChannel != "" {
+		query = query.Where("pay_channel = ?", filter.PayChannel)
+	}
+	if filter.BillDate != "" {
+		query = query.Where("bill_date = ?", filter.BillDate)
+	}
+	if filter.MismatchType > 0 {
+		query = query.Where("mismatch_type = ?", filter.MismatchType)
+	}
+	if filter.Status > 0 {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var results []*model.ReconciliationResult
+	if err := query.Order("bill_date DESC, created_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&results).Error; err != nil {
+		return nil, 0, err
+	}
+	return results, total, nil
+}
+
+func (r *reconciliationRepository) UpdateReconciliationResult(ctx context.Context, result *model.ReconciliationResult) error {
+	tx := r.db.WithContext(ctx).
+		Model(&model.ReconciliationResult{}).
+		Where("id = ? AND version = ?", result.ID, result.Version).
+		Updates(map[string]interface{}{
+			"status":         result.Status,
+			"resolve_remark": result.ResolveRemark,
+			"resolved_by":    result.ResolvedBy,
+			"resolved_at":    result.ResolvedAt,
+			"version":        gorm.Expr("version + 1"),
+		})
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	result.Version++
+	return nil
+}
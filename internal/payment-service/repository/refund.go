@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"zjMall/internal/payment-service/model"
+
+	"gorm.io/gorm"
+)
+
+type RefundRepository interface {
+	// CreateRefund 创建退款单
+	CreateRefund(ctx context.Context, refund *model.Refund) error
+	// GetRefundByRefundNo 根据退款单号查询退款单
+	GetRefundByRefundNo(ctx context.Context, refundNo string) (*model.Refund, error)
+	// GetRefundByRequestNo 查询订单下指定业务请求号、指定状态的最近一笔退款单（不存在时返回 nil, nil）
+	GetRefundByRequestNo(ctx context.Context, orderNo, requestNo string, statuses ...int8) (*model.Refund, error)
+	// SumRefundAmount 统计支付单下指定状态退款单的退款总额
+	SumRefundAmount(ctx context.Context, paymentNo string, statuses ...int8) (float64, error)
+	// UpdateRefund 更新退款单（使用乐观锁）
+	UpdateRefund(ctx context.Context, refund *model.Refund) error
+	// WithTransaction 在事务中执行回调，提供事务内的退款、支付单与 Outbox 仓库
+	WithTransaction(ctx context.Context, fn func(txCtx context.Context, txRepo RefundRepository, txPaymentRepo PaymentRepository, txOutboxRepo PaymentOutboxRepository) error) error
+}
+
+type refundRepository struct {
+	db *gorm.DB
+}
+
+func NewRefundRepository(db *gorm.DB) RefundRepository {
+	return &refundRepository{db: db}
+}
+
+func (r *refundRepository) CreateRefund(ctx context.Context, refund *model.Refund) error {
+	return r.db.WithContext(ctx).Create(refund).Error
+}
+
+func (r *refundRepository) GetRefundByRefundNo(ctx context.Context, refundNo string) (*model.Refund, error) {
+	var refund model.Refund
+	if err := r.db.WithContext(ctx).Where("refund_no = ?", refundNo).First(&refund).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &refund, nil
+}
+
+func (r *refundRepository) GetRefundByRequestNo(ctx context.Context, orderNo, requestNo string, statuses ...int8) (*model.Refund, error) {
+	query := r.db.WithContext(ctx).Where("order_no = ? AND request_no = ?", orderNo, requestNo)
+	if len(statuses) > 0 {
+		query = query.Where("status IN ?", statuses)
+	}
+	var refund model.Refund
+	if err := query.Order("created_at DESC").First(&refund).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &refund, nil
+}
+
+func (r *refundRepository) SumRefundAmount(ctx context.Context, paymentNo string, statuses ...int8) (float64, error) {
+	var total float64
+	query := r.db.WithContext(ctx).
+		Model(&model.Refund{}).
+		Where("payment_no = ?", paymentNo)
+	if len(statuses) > 0 {
+		query = query.Where("status IN ?", statuses)
+	}
+	if err := query.Select("COALESCE(SUM(refund_amount), 0)").Scan(&total).Error; err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+func (r *refundRepository) UpdateRefund(ctx context.Context, refund *model.Refund) error {
+	updateFields := map[string]interface{}{
+		"status":  refund.Status,
+		"version": gorm.Expr("version + 1"),
+	}
+
+	// 只更新非空字段
+	if refund.RefundTradeNo != "" {
+		updateFields["refund_trade_no"] = refund.RefundTradeNo
+	}
+	if refund.ResponseData != "" {
+		updateFields["response_data"] = refund.ResponseData
+	}
+	if refund.ErrorMessage != "" {
+		updateFields["error_message"] = refund.ErrorMessage
+	}
+	if refund.RefundedAt != nil {
+		updateFields["refunded_at"] = refund.RefundedAt
+	}
+
+	result := r.db.WithContext(ctx).
+		Model(&model.Refund{}).
+		Where("id = ? AND version = ?", refund.ID, refund.Version).
+		Updates(updateFields)
+	if result.Error != nil {
+		return result.Error
+	}
+	// RowsAffected=0 表示 version 不匹配，退款单已被并发修改
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// WithTransaction 在事务中执行回调
+func (r *refundRepository) WithTransaction(ctx context.Context, fn func(txCtx context.Context, txRepo RefundRepository, txPaymentRepo PaymentRepository, txOutboxRepo PaymentOutboxRepository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(ctx, &refundRepository{db: tx}, &paymentRepository{db: tx}, &paymentOutboxRepository{db: tx})
+	})
+}
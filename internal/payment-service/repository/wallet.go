@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"zjMall/internal/payment-service/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInsufficientBalance 钱包余额不足
+var ErrInsufficientBalance = errors.New("钱包余额不足")
+
+type WalletRepository interface {
+	// GetWalletByUserID 查询用户钱包（不存在时返回 nil, nil）
+	GetWalletByUserID(ctx context.Context, userID string) (*model.Wallet, error)
+	// Credit 增加用户余额（钱包不存在时自动开户），返回变动后余额
+	Credit(ctx context.Context, userID string, amount float64) (float64, error)
+	// Debit 扣减用户余额，余额不足时返回 ErrInsufficientBalance，返回变动后余额
+	// 通过条件更新 balance >= amount 保证并发扣款不会透支
+	Debit(ctx context.Context, userID string, amount float64) (float64, error)
+	// CreateLedgerEntries 写入钱包流水，同一业务单号重复记账时返回唯一索引冲突错误
+	CreateLedgerEntries(ctx context.Context, entries ...*model.WalletLedger) error
+	// GetLedgerEntries 查询业务单号对应的钱包流水
+	GetLedgerEntries(ctx context.Context, bizType, bizNo string) ([]*model.WalletLedger, error)
+	// ListUserLedger 分页查询用户钱包账户的流水
+	ListUserLedger(ctx context.Context, userID string, offset, limit int) ([]*model.WalletLedger, int64, error)
+	// WithTransaction 在事务中执行回调，提供事务内的钱包、支付单与 Outbox 仓库
+	WithTransaction(ctx context.Context, fn func(txCtx context.Context, txRepo WalletRepository, txPaymentRepo PaymentRepository, txOutboxRepo PaymentOutboxRepository) error) error
+}
+
+type walletRepository struct {
+	db *gorm.DB
+}
+
+func NewWalletRepository(db *gorm.DB) WalletRepository {
+	return &walletRepository{db: db}
+}
+
+func (r *walletRepository) GetWalletByUserID(ctx context.Context, userID string) (*model.Wallet, error) {
+	var wallet model.Wallet
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&wallet).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+func (r *walletRepository) Credit(ctx context.Context, userID string, amount float64) (float64, error) {
+	wallet := &model.Wallet{UserID: userID, Balance: amount}
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"balance": gorm.Expr("balance + ?", amount),
+			"version": gorm.Expr("version + 1"),
+		}),
+	}).Create(wallet).Error
+	if err != nil {
+		return 0, err
+	}
+	return r.balanceOf(ctx, userID)
+}
+
+func (r *walletRepository) Debit(ctx context.Context, userID string, amount float64) (float64, error) {
+	tx := r.db.WithContext(ctx).
+		Model(&model.Wallet{}).
+		Where("user_id = ? AND balance >= ?", userID, amount).
+		Updates(map[string]interface{}{
+			"balance": gorm.Expr("balance - ?", amount),
+			"version": gorm.Expr("version + 1"),
+		})
+	if tx.Error != nil {
+		return 0, tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return 0, ErrInsufficientBalance
+	}
+	return r.balanceOf(ctx, userID)
+}
+
+func (r *walletRepository) balanceOf(ctx context.Context, userID string) (float64, error) {
+	var balance float64
+	if err := r.db.WithContext(ctx).
+		Model(&model.Wallet{}).
+		Where("user_id = ?", userID).
+		Select("balance").
+		Scan(&balance).Error; err != nil {
+		return 0, err
+	}
+	return balance, nil
+}
+
+func (r *walletRepository) CreateLedgerEntries(ctx context.Context, entries ...*model.WalletLedger) error {
+	return r.db.WithContext(ctx).Create(entries).Error
+}
+
+func (r *walletRepository) GetLedgerEntries(ctx context.Context, bizType, bizNo string) ([]*model.WalletLedger, error) {
+	var entries []*model.WalletLedger
+	if err := r.db.WithContext(ctx).
+		Where("biz_type = ? AND biz_no = ?", bizType, bizNo).
+		Order("direction ASC").
+		Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (r *walletRepository) ListUserLedger(ctx context.Context, userID string, offset, limit int) ([]*model.WalletLedger, int64, error) {
+	query := r.db.WithContext(ctx).
+		Model(&model.WalletLedger{}).
+		Where("account = ?", model.WalletUserAccount(userID))
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var entries []*model.WalletLedger
+	if err := query.Order("created_at DESC, id DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&entries).Error; err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+// WithTransaction 在事务中执行回调
+func (r *walletRepository) WithTransaction(ctx context.Context, fn func(txCtx context.Context, txRepo WalletRepository, txPaymentRepo PaymentRepository, txOutboxRepo PaymentOutboxRepository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(ctx, &walletRepository{db: tx}, &paymentRepository{db: tx}, &paymentOutboxRepository{db: tx})
+	})
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"zjMall/internal/payment-service/model"
+	"zjMall/internal/payment-service/signature"
+)
+
+// SignParams 支付回调参与签名的参数
+func (r *PaymentCallbackRequest) SignParams() map[string]string {
+	return signature.PaymentCallbackParams(r.PaymentNo, r.TradeNo, r.Amount, r.Status, r.ExtraParams)
+}
+
+// SignParams 退款回调参与签名的参数
+func (r *RefundCallbackRequest) SignParams() map[string]string {
+	return signature.RefundCallbackParams(r.RefundNo, r.RefundTradeNo, r.Amount, r.Status, r.ExtraParams)
+}
+
+// verifyPaymentCallback 使用支付单所属渠道的配置校验支付回调签名，校验失败记录支付日志
+func (s *PaymentService) verifyPaymentCallback(ctx context.Context, req *PaymentCallbackRequest) error {
+	payment, err := s.paymentRepo.GetPaymentByPaymentNo(ctx, req.PaymentNo)
+	if err != nil {
+		return fmt.Errorf("查询支付单失败: %w", err)
+	}
+
+	rejectLog := &model.PaymentLog{
+		PaymentNo: req.PaymentNo,
+		Action:    model.PaymentLogActionCallbackRejected,
+		Channel:   req.PayChannel,
+		TradeNo:   req.TradeNo,
+	}
+	if payment != nil {
+		rejectLog.OrderNo = payment.OrderNo
+		rejectLog.UserID = payment.UserID
+		rejectLog.Amount = payment.Amount
+	}
+
+	reason := ""
+	switch {
+	case payment == nil:
+		reason = "支付单不存在"
+	case payment.PayChannel != req.PayChannel:
+		reason = fmt.Sprintf("支付渠道不匹配: 支付单渠道=%s, 回调渠道=%s", payment.PayChannel, req.PayChannel)
+	default:
+		reason = s.verifyCallbackSign(ctx, payment.PayChannel, req.SignParams(), req.Sign)
+	}
+	if reason == "" {
+		return nil
+	}
+
+	s.logRejectedCallback(ctx, rejectLog, req, reason)
+	return fmt.Errorf("支付回调校验失败: %s", reason)
+}
+
+// verifyRefundCallback 使用退款单原支付渠道的配置校验退款回调签名，校验失败记录支付日志
+func (s *PaymentService) verifyRefundCallback(ctx context.Context, req *RefundCallbackRequest) error {
+	refund, err := s.refundRepo.GetRefundByRefundNo(ctx, req.RefundNo)
+	if err != nil {
+		return fmt.Errorf("查询退款单失败: %w", err)
+	}
+
+	rejectLog := &model.PaymentLog{
+		Action:  model.PaymentLogActionCallbackRejected,
+		Channel: req.PayChannel,
+		TradeNo: req.RefundTradeNo,
+	}
+	if refund != nil {
+		rejectLog.PaymentNo = refund.PaymentNo
+		rejectLog.OrderNo = refund.OrderNo
+		rejectLog.UserID = refund.UserID
+		rejectLog.Amount = refund.RefundAmount
+	}
+
+	reason := ""
+	switch {
+	case refund == nil:
+		reason = fmt.Sprintf("退款单不存在: %s", req.RefundNo)
+	case refund.PayChannel != req.PayChannel:
+		reason = fmt.Sprintf("支付渠道不匹配: 退款单渠道=%s, 回调渠道=%s", refund.PayChannel, req.PayChannel)
+	default:
+		reason = s.verifyCallbackSign(ctx, refund.PayChannel, req.SignParams(), req.Sign)
+	}
+	if reason == "" {
+		return nil
+	}
+
+	s.logRejectedCallback(ctx, rejectLog, req, reason)
+	return fmt.Errorf("退款回调校验失败: %s", reason)
+}
+
+// verifyCallbackSign 按渠道代码选择校验器验签，返回拒绝原因（为空表示通过）
+func (s *PaymentService) verifyCallbackSign(ctx context.Context, channelCode string, params map[string]string, sign string) string {
+	if sign == "" {
+		return "签名为空"
+	}
+	// 优先使用网关已加载的渠道配置（与下单时的环境一致），未加载时回退到数据库配置
+	var channel *model.PaymentChannel
+	if gw, err := s.paymentGateway(channelCode); err == nil {
+		channel = gw.Channel()
+	} else {
+		channel, err = s.paymentChannelRepo.GetPaymentChannelByChannelCode(ctx, channelCode)
+		if err != nil {
+			return fmt.Sprintf("查询支付渠道失败: %v", err)
+		}
+	}
+	if !channel.IsEnabled {
+		return fmt.Sprintf("支付渠道已禁用: %s", channelCode)
+	}
+	verifier, err := signature.NewVerifier(channel)
+	if err != nil {
+		return err.Error()
+	}
+	if err := verifier.Verify(params, sign); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// logRejectedCallback 记录被拒绝的回调，便于排查伪造或配置错误
+func (s *PaymentService) logRejectedCallback(ctx context.Context, rejectLog *model.PaymentLog, req interface{}, reason string) {
+	log.Printf("⚠️ 回调被拒绝: payment_no=%s, channel=%s, reason=%s", rejectLog.PaymentNo, rejectLog.Channel, reason)
+
+	requestData, _ := json.Marshal(req)
+	rejectLog.RequestData = string(requestData)
+	rejectLog.ErrorMessage = reason
+	if r := []rune(reason); len(r) > 500 {
+		rejectLog.ErrorMessage = string(r[:500])
+	}
+	if err := s.paymentLogRepo.CreatePaymentLog(ctx, rejectLog); err != nil {
+		log.Printf("⚠️ 记录回调拒绝日志失败: %v\n", err)
+	}
+}
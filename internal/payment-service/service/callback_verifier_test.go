@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"zjMall/internal/payment-service/model"
+	"zjMall/internal/payment-service/repository"
+	"zjMall/internal/payment-service/signature"
+)
+
+// fakePaymentRepo 仅实现验签用到的查询，其余方法调用时 panic
+type fakePaymentRepo struct {
+	repository.PaymentRepository
+	payments map[string]*model.Payment
+}
+
+func (r *fakePaymentRepo) GetPaymentByPaymentNo(ctx context.Context, paymentNo string) (*model.Payment, error) {
+	return r.payments[paymentNo], nil
+}
+
+type fakePaymentChannelRepo struct {
+	repository.PaymentChannelRepository
+	channels map[string]*model.PaymentChannel
+}
+
+func (r *fakePaymentChannelRepo) GetPaymentChannelByChannelCode(ctx context.Context, channelCode string) (*model.PaymentChannel, error) {
+	return r.channels[channelCode], nil
+}
+
+type fakePaymentLogRepo struct {
+	repository.PaymentLogRepository
+	logs []*model.PaymentLog
+}
+
+func (r *fakePaymentLogRepo) CreatePaymentLog(ctx context.Context, paymentLog *model.PaymentLog) error {
+	r.logs = append(r.logs, paymentLog)
+	return nil
+}
+
+func TestVerifyPaymentCallback(t *testing.T) {
+	channel := &model.PaymentChannel{
+		ChannelCode: model.PayChannelWeChat,
+		APIKey:      "sandbox-api-key",
+		IsEnabled:   true,
+		Environment: model.EnvironmentSandbox,
+	}
+	payment := &model.Payment{
+		PaymentNo:  "PAY202601010001",
+		OrderNo:    "ORD202601010001",
+		UserID:     "user-1",
+		Amount:     99.9,
+		PayChannel: model.PayChannelWeChat,
+	}
+	signer, err := signature.NewSandboxSigner(channel)
+	if err != nil {
+		t.Fatalf("创建签名器失败: %v", err)
+	}
+
+	// signedCallback 生成沙箱签名的支付回调
+	signedCallback := func(t *testing.T) *PaymentCallbackRequest {
+		req := &PaymentCallbackRequest{
+			PayChannel: model.PayChannelWeChat,
+			PaymentNo:  payment.PaymentNo,
+			TradeNo:    "TRADE0001",
+			Amount:     "99.90",
+			Status:     "SUCCESS",
+		}
+		sign, err := signer.Sign(req.SignParams())
+		if err != nil {
+			t.Fatalf("签名失败: %v", err)
+		}
+		req.Sign = sign
+		return req
+	}
+
+	tests := []struct {
+		name       string
+		mutate     func(req *PaymentCallbackRequest)
+		wantReject bool
+	}{
+		{name: "valid sign"},
+		{name: "tampered amount", mutate: func(req *PaymentCallbackRequest) { req.Amount = "0.01" }, wantReject: true},
+		{name: "missing sign", mutate: func(req *PaymentCallbackRequest) { req.Sign = "" }, wantReject: true},
+		{name: "channel mismatch", mutate: func(req *PaymentCallbackRequest) { req.PayChannel = model.PayChannelAlipay }, wantReject: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logRepo := &fakePaymentLogRepo{}
+			svc := &PaymentService{
+				paymentRepo:        &fakePaymentRepo{payments: map[string]*model.Payment{payment.PaymentNo: payment}},
+				paymentLogRepo:     logRepo,
+				paymentChannelRepo: &fakePaymentChannelRepo{channels: map[string]*model.PaymentChannel{channel.ChannelCode: channel}},
+			}
+
+			req := signedCallback(t)
+			if tt.mutate != nil {
+				tt.mutate(req)
+			}
+			err := svc.verifyPaymentCallback(context.Background(), req)
+
+			if !tt.wantReject {
+				if err != nil {
+					t.Fatalf("验签失败: %v", err)
+				}
+				if len(logRepo.logs) != 0 {
+					t.Fatalf("验签通过不应记录拒绝日志，实际 %d 条", len(logRepo.logs))
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatal("期望回调被拒绝")
+			}
+			if len(logRepo.logs) != 1 {
+				t.Fatalf("期望记录 1 条拒绝日志，实际 %d 条", len(logRepo.logs))
+			}
+			got := logRepo.logs[0]
+			if got.Action != model.PaymentLogActionCallbackRejected {
+				t.Fatalf("日志操作类型 = %s, want %s", got.Action, model.PaymentLogActionCallbackRejected)
+			}
+			if got.PaymentNo != payment.PaymentNo || got.OrderNo != payment.OrderNo {
+				t.Fatalf("拒绝日志未关联支付单: payment_no=%s, order_no=%s", got.PaymentNo, got.OrderNo)
+			}
+			if got.ErrorMessage == "" {
+				t.Fatal("拒绝日志缺少拒绝原因")
+			}
+		})
+	}
+}
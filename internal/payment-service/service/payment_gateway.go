@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"zjMall/internal/payment-service/gateway"
+	"zjMall/internal/payment-service/model"
+)
+
+// paymentGateway 获取渠道网关，余额等站内渠道或未加载的渠道返回错误
+func (s *PaymentService) paymentGateway(channelCode string) (gateway.PaymentGateway, error) {
+	if s.gateways == nil {
+		return nil, fmt.Errorf("%w: 未配置支付网关", gateway.ErrGatewayNotFound)
+	}
+	return s.gateways.Get(channelCode)
+}
+
+// resolvePaymentChannel 解析本次支付使用的渠道：未指定时使用默认渠道
+// 余额支付为站内渠道，返回的网关为 nil
+func (s *PaymentService) resolvePaymentChannel(ctx context.Context, channelCode string) (*model.PaymentChannel, gateway.PaymentGateway, error) {
+	if channelCode == "" && s.gateways != nil {
+		channelCode = s.gateways.DefaultChannel()
+	}
+	if channelCode == "" {
+		return nil, nil, fmt.Errorf("未指定支付渠道且未配置默认渠道")
+	}
+
+	if channelCode == model.PayChannelBalance {
+		channel, err := s.paymentChannelRepo.GetPaymentChannelByChannelCode(ctx, channelCode)
+		if err != nil {
+			return nil, nil, fmt.Errorf("查询支付渠道失败: %w", err)
+		}
+		if !channel.IsEnabled {
+			return nil, nil, fmt.Errorf("支付渠道已禁用: %s", channelCode)
+		}
+		return channel, nil, nil
+	}
+
+	gw, err := s.paymentGateway(channelCode)
+	if err != nil {
+		return nil, nil, err
+	}
+	return gw.Channel(), gw, nil
+}
+
+// requestPayParams 向渠道网关下单，获取前端调起支付所需参数
+func (s *PaymentService) requestPayParams(ctx context.Context, gw gateway.PaymentGateway, payment *model.Payment) (*gateway.CreatePaymentResult, error) {
+	return gw.CreatePayment(ctx, &gateway.CreatePaymentRequest{
+		PaymentNo: payment.PaymentNo,
+		OrderNo:   payment.OrderNo,
+		Amount:    payment.Amount,
+		Subject:   fmt.Sprintf("订单%s", payment.OrderNo),
+		NotifyURL: payment.NotifyURL,
+		ReturnURL: payment.ReturnURL,
+		ExpiredAt: payment.ExpiredAt,
+	})
+}
+
+// requestGatewayRefund 向原支付渠道网关发起退款，受理成功后等待退款回调
+func (s *PaymentService) requestGatewayRefund(ctx context.Context, refund *model.Refund) error {
+	gw, err := s.paymentGateway(refund.PayChannel)
+	if err != nil {
+		return err
+	}
+	payment, err := s.paymentRepo.GetPaymentByPaymentNo(ctx, refund.PaymentNo)
+	if err != nil {
+		return fmt.Errorf("查询支付单失败: %w", err)
+	}
+	if payment == nil {
+		return fmt.Errorf("支付单不存在: %s", refund.PaymentNo)
+	}
+
+	result, err := gw.Refund(ctx, &gateway.RefundRequest{
+		RefundNo:     refund.RefundNo,
+		PaymentNo:    refund.PaymentNo,
+		TradeNo:      refund.TradeNo,
+		RefundAmount: refund.RefundAmount,
+		TotalAmount:  payment.Amount,
+		Reason:       refund.RefundReason,
+	})
+	if err != nil {
+		return err
+	}
+	if result.Status == gateway.RefundStatusFailed {
+		return fmt.Errorf("渠道拒绝退款: refund_no=%s", refund.RefundNo)
+	}
+	return nil
+}
+
+// NotifyPayment 接收网关投递的支付通知，与 HTTP 回调走同一套验签与处理流程
+func (s *PaymentService) NotifyPayment(ctx context.Context, n *gateway.PaymentNotification) error {
+	return s.HandlePaymentCallback(ctx, &PaymentCallbackRequest{
+		PayChannel:  n.PayChannel,
+		PaymentNo:   n.PaymentNo,
+		TradeNo:     n.TradeNo,
+		Amount:      n.Amount,
+		Status:      n.Status,
+		Sign:        n.Sign,
+		ExtraParams: n.ExtraParams,
+	})
+}
+
+// NotifyRefund 接收网关投递的退款通知，与 HTTP 回调走同一套验签与处理流程
+func (s *PaymentService) NotifyRefund(ctx context.Context, n *gateway.RefundNotification) error {
+	return s.HandleRefundCallback(ctx, &RefundCallbackRequest{
+		PayChannel:    n.PayChannel,
+		RefundNo:      n.RefundNo,
+		RefundTradeNo: n.RefundTradeNo,
+		Amount:        n.Amount,
+		Status:        n.Status,
+		Sign:          n.Sign,
+		ExtraParams:   n.ExtraParams,
+	})
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"zjMall/internal/payment-service/gateway"
+	"zjMall/internal/payment-service/model"
+)
+
+const (
+	PaymentReconcileAfter     = 5 * time.Minute // 支付单创建超过该时长仍未完成，主动查询渠道
+	PaymentReconcileBatchSize = 100             // 每次对账处理的支付单数量
+)
+
+// ReconcileStuckPayments 主动对账（定时任务调用）：查询长时间处于待支付/支付中的支付单在渠道侧的真实状态，
+// 渠道已支付/失败的按回调流程推进，渠道已关闭的关闭本地支付单，防止回调丢失导致状态停滞
+func (s *PaymentService) ReconcileStuckPayments(ctx context.Context, olderThan time.Duration, limit int) error {
+	if olderThan <= 0 {
+		olderThan = PaymentReconcileAfter
+	}
+	if limit <= 0 {
+		limit = PaymentReconcileBatchSize
+	}
+
+	payments, err := s.paymentRepo.GetStuckPayments(ctx, time.Now().Add(-olderThan), limit)
+	if err != nil {
+		return fmt.Errorf("查询待对账支付单失败: %w", err)
+	}
+
+	for _, payment := range payments {
+		if err := s.reconcilePayment(ctx, payment); err != nil {
+			log.Printf("⚠️ 支付单对账失败: payment_no=%s, err=%v\n", payment.PaymentNo, err)
+		}
+	}
+	return nil
+}
+
+// reconcilePayment 查询单笔支付单的渠道状态，发现不一致时推进本地状态并记录对账日志
+func (s *PaymentService) reconcilePayment(ctx context.Context, payment *model.Payment) error {
+	// 余额支付为站内渠道，没有第三方交易可查询
+	if payment.PayChannel == model.PayChannelBalance {
+		return nil
+	}
+	gw, err := s.paymentGateway(payment.PayChannel)
+	if err != nil {
+		return err
+	}
+
+	result, err := gw.QueryPayment(ctx, payment.PaymentNo)
+	if err != nil {
+		if !errors.Is(err, gateway.ErrTradeNotFound) {
+			return fmt.Errorf("查询渠道交易失败: %w", err)
+		}
+		// 待支付且渠道无交易属于用户未支付，交由超时关单处理；支付中却查不到交易需要人工介入
+		if payment.Status == model.PaymentStatusProcessing {
+			s.logReconcile(ctx, payment, payment.Status, nil, "本地支付中，渠道不存在该交易", nil, nil)
+		}
+		return nil
+	}
+	if result.Status == gateway.TradeStatusWaitPay {
+		return nil
+	}
+
+	// 查询渠道期间可能已收到回调，重新读取后再判断
+	latest, err := s.paymentRepo.GetPaymentByPaymentNo(ctx, payment.PaymentNo)
+	if err != nil {
+		return fmt.Errorf("查询支付单失败: %w", err)
+	}
+	if latest == nil {
+		return fmt.Errorf("支付单不存在: %s", payment.PaymentNo)
+	}
+	if latest.Status != model.PaymentStatusPending && latest.Status != model.PaymentStatusProcessing {
+		return nil
+	}
+
+	fromStatus := latest.Status
+	discrepancy := fmt.Sprintf("本地状态=%d, 渠道状态=%s", fromStatus, result.Status)
+	var applyErr error
+	switch result.Status {
+	case gateway.TradeStatusSuccess, gateway.TradeStatusFailed:
+		// 与支付回调走同一套状态推进与 Outbox 写入逻辑
+		applyErr = s.handlePaymentCallback(ctx, &PaymentCallbackRequest{
+			PayChannel: latest.PayChannel,
+			PaymentNo:  latest.PaymentNo,
+			TradeNo:    result.TradeNo,
+			Amount:     fmt.Sprintf("%.2f", result.Amount),
+			Status:     result.Status,
+		})
+	case gateway.TradeStatusClosed:
+		applyErr = s.closeReconciledPayment(ctx, latest.PaymentNo)
+	default:
+		applyErr = fmt.Errorf("未知的渠道交易状态: %s", result.Status)
+	}
+
+	toStatus := fromStatus
+	if current, err := s.paymentRepo.GetPaymentByPaymentNo(ctx, latest.PaymentNo); err == nil && current != nil {
+		toStatus = current.Status
+	}
+	s.logReconcile(ctx, latest, fromStatus, &toStatus, discrepancy, result, applyErr)
+
+	if applyErr != nil {
+		return fmt.Errorf("对账推进支付单状态失败: %w", applyErr)
+	}
+	log.Printf("✅ 支付单对账完成: payment_no=%s, %s, 当前状态=%d\n", latest.PaymentNo, discrepancy, toStatus)
+	return nil
+}
+
+// closeReconciledPayment 渠道侧交易已关闭时关闭本地支付单
+func (s *PaymentService) closeReconciledPayment(ctx context.Context, paymentNo string) error {
+	lockKey := fmt.Sprintf("%s:%s", PaymentLockKeyPrefix, paymentNo)
+	acquired, err := s.lockService.AcquireLock(ctx, lockKey, time.Duration(PaymentLockExpireSeconds)*time.Second)
+	if err != nil || !acquired {
+		return fmt.Errorf("系统繁忙，请稍后重试")
+	}
+	defer s.lockService.ReleaseLock(ctx, lockKey)
+
+	payment, err := s.paymentRepo.GetPaymentByPaymentNo(ctx, paymentNo)
+	if err != nil {
+		return fmt.Errorf("查询支付单失败: %w", err)
+	}
+	if payment == nil {
+		return fmt.Errorf("支付单不存在: %s", paymentNo)
+	}
+	if payment.Status != model.PaymentStatusPending && payment.Status != model.PaymentStatusProcessing {
+		return nil
+	}
+	payment.Status = model.PaymentStatusClosed
+	if err := s.paymentRepo.UpdatePayment(ctx, payment); err != nil {
+		return fmt.Errorf("关闭支付单失败: %w", err)
+	}
+	return nil
+}
+
+// logReconcile 记录对账发现的不一致及处理结果
+func (s *PaymentService) logReconcile(ctx context.Context, payment *model.Payment, fromStatus int8, toStatus *int8, discrepancy string, result *gateway.QueryPaymentResult, applyErr error) {
+	reconcileLog := &model.PaymentLog{
+		PaymentNo:  payment.PaymentNo,
+		OrderNo:    payment.OrderNo,
+		UserID:     payment.UserID,
+		Action:     model.PaymentLogActionReconcile,
+		FromStatus: &fromStatus,
+		ToStatus:   toStatus,
+		Channel:    payment.PayChannel,
+		Amount:     payment.Amount,
+	}
+	if result != nil {
+		reconcileLog.TradeNo = result.TradeNo
+		responseData, _ := json.Marshal(result)
+		reconcileLog.ResponseData = string(responseData)
+	}
+
+	message := discrepancy
+	if applyErr != nil {
+		message = fmt.Sprintf("%s; 处理失败: %v", message, applyErr)
+	}
+	reconcileLog.ErrorMessage = message
+	if r := []rune(message); len(r) > 500 {
+		reconcileLog.ErrorMessage = string(r[:500])
+	}
+
+	log.Printf("⚠️ 对账发现支付单状态不一致: payment_no=%s, %s\n", payment.PaymentNo, message)
+	if err := s.paymentLogRepo.CreatePaymentLog(ctx, reconcileLog); err != nil {
+		log.Printf("⚠️ 记录对账日志失败: %v\n", err)
+	}
+}
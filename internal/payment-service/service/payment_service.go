@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
@@ -10,12 +11,13 @@ import (
 	"strings"
 	"time"
 
+	orderv1 "zjMall/gen/go/api/proto/order"
 	paymentv1 "zjMall/gen/go/api/proto/payment"
 	"zjMall/internal/common/cache"
 	"zjMall/internal/common/client"
 	"zjMall/internal/common/lock"
 	"zjMall/internal/common/middleware"
-	"zjMall/internal/common/mq"
+	"zjMall/internal/payment-service/gateway"
 	"zjMall/internal/payment-service/model"
 	"zjMall/internal/payment-service/repository"
 
@@ -41,8 +43,11 @@ type PaymentService struct {
 	orderClient        client.OrderClient
 	cacheRepo          cache.CacheRepository
 	lockService        lock.DistributedLockService
-	paymentMQ          mq.MessageProducer
 	outboxRepo         repository.PaymentOutboxRepository
+	refundRepo         repository.RefundRepository
+	gateways           *gateway.Registry // 支付渠道网关注册表
+	reconciliationRepo repository.ReconciliationRepository
+	walletRepo         repository.WalletRepository
 }
 
 // NewPaymentService 创建支付服务
@@ -54,8 +59,11 @@ func NewPaymentService(
 	cacheRepo cache.CacheRepository,
 	paymentTimeout time.Duration,
 	lockService lock.DistributedLockService,
-	paymentMQ mq.MessageProducer,
 	outboxRepo repository.PaymentOutboxRepository,
+	refundRepo repository.RefundRepository,
+	gateways *gateway.Registry,
+	reconciliationRepo repository.ReconciliationRepository,
+	walletRepo repository.WalletRepository,
 ) *PaymentService {
 	return &PaymentService{
 		paymentRepo:        paymentRepo,
@@ -65,8 +73,11 @@ func NewPaymentService(
 		orderClient:        orderClient,
 		cacheRepo:          cacheRepo,
 		lockService:        lockService,
-		paymentMQ:          paymentMQ,
 		outboxRepo:         outboxRepo,
+		refundRepo:         refundRepo,
+		gateways:           gateways,
+		reconciliationRepo: reconciliationRepo,
+		walletRepo:         walletRepo,
 	}
 }
 
@@ -141,11 +152,22 @@ func (s *PaymentService) CreatePayment(ctx context.Context, req *paymentv1.Creat
 		log.Printf("⚠️ 订单不存在: %s", req.OrderNo)
 		return nil, fmt.Errorf("订单不存在: %s", req.OrderNo)
 	}
+	//拆单的子订单随主订单支付
+	if order.ParentOrderNo != "" {
+		log.Printf("⚠️ 子订单不能单独支付: %s, 主订单号: %s", req.OrderNo, order.ParentOrderNo)
+		return nil, fmt.Errorf("子订单不能单独支付，请支付主订单: %s", order.ParentOrderNo)
+	}
 	//检查订单是否为待支付状态
 	if int8(order.Status) != model.PaymentStatusPending {
 		log.Printf("⚠️ 订单状态不正确: %s", req.OrderNo)
 		return nil, fmt.Errorf("订单状态不正确: %s", req.OrderNo)
 	}
+	//检查订单是否已过支付截止时间（等待超时关闭）
+	expiredAt := s.paymentExpiredAt(order)
+	if !expiredAt.After(time.Now()) {
+		log.Printf("⚠️ 订单已过支付截止时间: %s", req.OrderNo)
+		return nil, fmt.Errorf("订单已超过支付截止时间: %s", req.OrderNo)
+	}
 	//检查订单支付金额是否大于0
 	payAmount, err := strconv.ParseFloat(order.PayAmount, 64)
 	if err != nil {
@@ -166,29 +188,49 @@ func (s *PaymentService) CreatePayment(ctx context.Context, req *paymentv1.Creat
 		// 如果已存在且状态为待支付，直接返回（幂等处理）
 		if existingPayment.Status == model.PaymentStatusPending {
 			log.Printf("⚠️ 订单已存在支付单，状态为待支付，直接返回")
-			return &paymentv1.CreatePaymentResponse{
+			// 订单支付截止时间被延长时同步延长支付单过期时间
+			if existingPayment.ExpiredAt == nil || expiredAt.After(*existingPayment.ExpiredAt) {
+				existingPayment.ExpiredAt = &expiredAt
+				if err := s.paymentRepo.UpdatePayment(ctx, existingPayment); err != nil {
+					log.Printf("⚠️ 延长支付单过期时间失败: payment_no=%s, err=%v\n", existingPayment.PaymentNo, err)
+				}
+			}
+			// 余额支付单重新尝试扣款（如充值后再次支付）
+			if existingPayment.PayChannel == model.PayChannelBalance {
+				if err := s.payWithBalance(ctx, existingPayment); err != nil {
+					return nil, fmt.Errorf("余额支付失败: %w", err)
+				}
+			}
+			resp := &paymentv1.CreatePaymentResponse{
 				Code:    0,
 				Message: "success",
 				Payment: s.convertPaymentToProto(existingPayment),
-			}, nil
+			}
+			// 重新向网关获取支付参数，便于用户继续支付
+			if gw, err := s.paymentGateway(existingPayment.PayChannel); err == nil {
+				if result, err := s.requestPayParams(ctx, gw, existingPayment); err == nil {
+					resp.PayUrl, resp.QrCode, resp.PayParams = result.PayURL, result.QRCode, result.PayParams
+				}
+			}
+			return resp, nil
 		}
 		// 如果已存在但状态不是待支付，返回错误
 		log.Printf("⚠️ 订单已存在支付单，状态为: %d", existingPayment.Status)
 		return nil, fmt.Errorf("订单已存在支付单，状态为: %d", existingPayment.Status)
 	}
-	// 检验支付渠道是否有效（req.PayChannel 是字符串，直接使用）
-	paymentChannel, err := s.paymentChannelRepo.GetPaymentChannelByChannelCode(ctx, req.PayChannel)
+	// 检验支付渠道是否有效（未指定时使用默认渠道，第三方渠道必须已加载网关）
+	paymentChannel, gw, err := s.resolvePaymentChannel(ctx, req.PayChannel)
 	if err != nil {
-		log.Printf("⚠️ 查询支付渠道失败: %v\n", err)
-		return nil, fmt.Errorf("查询支付渠道失败: %w", err)
+		log.Printf("⚠️ 支付渠道不可用: %v\n", err)
+		return nil, err
 	}
-	if paymentChannel == nil {
-		log.Printf("⚠️ 支付渠道不存在: %s", req.PayChannel)
-		return nil, fmt.Errorf("支付渠道不存在: %s", req.PayChannel)
+	if paymentChannel.ChannelCode == model.PayChannelBalance {
+		if err := s.checkBalanceSufficient(ctx, userId, payAmount); err != nil {
+			return nil, err
+		}
 	}
 	//生成支付单号
 	paymentNo := s.generatePaymentNo()
-	expiredAt := time.Now().Add(s.paymentTimeout)
 	// 创建支付单
 	payment := &model.Payment{
 		PaymentNo:  paymentNo,
@@ -199,7 +241,7 @@ func (s *PaymentService) CreatePayment(ctx context.Context, req *paymentv1.Creat
 		Status:     model.PaymentStatusPending,
 		NotifyURL:  paymentChannel.NotifyURL,
 		ReturnURL:  req.ReturnUrl, // 使用请求中的返回地址，如果没有则使用渠道配置的
-		ExpiredAt:  &expiredAt,    //TODO:定时任务处理吗？
+		ExpiredAt:  &expiredAt,    // 与订单支付截止时间一致，过期后由定时任务关闭
 		Version:    1,
 	}
 
@@ -233,9 +275,25 @@ func (s *PaymentService) CreatePayment(ctx context.Context, req *paymentv1.Creat
 		log.Printf("⚠️ 记录支付日志失败: %v\n", err)
 	}
 
-	// 生成支付参数（学习模式：模拟支付参数，便于开发和测试）
-	// 在实际生产环境中，这里应该调用第三方支付SDK（如支付宝、微信支付）
-	payURL, qrCode, payParams := s.generatePaymentParamsForLearning(payment, paymentChannel)
+	// 向第三方渠道网关下单，获取支付参数；余额支付不需要跳转，直接扣款
+	var payURL, qrCode string
+	var payParams map[string]string
+	if gw != nil {
+		result, err := s.requestPayParams(ctx, gw, payment)
+		if err != nil {
+			// 支付单保持待支付，超时后由定时任务关闭
+			log.Printf("⚠️ 渠道下单失败: payment_no=%s, err=%v\n", payment.PaymentNo, err)
+			return nil, fmt.Errorf("渠道下单失败: %w", err)
+		}
+		payURL, qrCode, payParams = result.PayURL, result.QRCode, result.PayParams
+	} else {
+		payParams = map[string]string{
+			"payment_no": payment.PaymentNo,
+			"amount":     fmt.Sprintf("%.2f", payment.Amount),
+			"channel":    model.PayChannelBalance,
+			"note":       "余额支付，无需跳转",
+		}
+	}
 
 	// 设置幂等性key（存储支付单号，有效期5分钟）
 	// 这样后续相同请求可以直接返回已创建的支付单
@@ -247,22 +305,11 @@ func (s *PaymentService) CreatePayment(ctx context.Context, req *paymentv1.Creat
 	// 	//TODO:异步调用支付网关
 
 	// }()
-	// 在学习/演示环境下，如果是余额支付，可以在创建支付单后直接模拟成功回调，
-	// 这样可以自动完成：更新支付单状态 -> 写支付日志 -> 写 Outbox -> 订单服务消费更新订单状态。
+	// 余额支付同步结算：扣减余额 -> 更新支付单 -> 写 Outbox -> 订单服务消费更新订单状态。
+	// 扣款失败时支付单保持待支付，充值后再次发起支付即可
 	if paymentChannel.ChannelCode == model.PayChannelBalance {
-		callbackReq := &PaymentCallbackRequest{
-			PayChannel: paymentChannel.ChannelCode,
-			PaymentNo:  payment.PaymentNo,
-			// 学习模式下生成一个虚拟的第三方交易号
-			TradeNo: fmt.Sprintf("BALANCE_%s", payment.PaymentNo),
-			// 金额必须与支付单一致，否则 HandlePaymentCallback 会金额校验失败
-			Amount: fmt.Sprintf("%.2f", payment.Amount),
-			Status: "SUCCESS",
-			Sign:   "",
-		}
-
-		if err := s.HandlePaymentCallback(ctx, callbackReq); err != nil {
-			log.Printf("⚠️ 余额支付模拟回调失败: payment_no=%s, err=%v\n", payment.PaymentNo, err)
+		if err := s.payWithBalance(ctx, payment); err != nil {
+			return nil, fmt.Errorf("余额支付失败: %w", err)
 		}
 	}
 
@@ -303,6 +350,18 @@ type PaymentCallbackRequest struct {
 
 // HandlePaymentCallback 处理支付回调
 func (s *PaymentService) HandlePaymentCallback(ctx context.Context, req *PaymentCallbackRequest) error {
+	if req.PaymentNo == "" {
+		return fmt.Errorf("支付单号不能为空")
+	}
+	// 签名校验：校验是否是平台发来的回调，防止伪造回调
+	if err := s.verifyPaymentCallback(ctx, req); err != nil {
+		return err
+	}
+	return s.handlePaymentCallback(ctx, req)
+}
+
+// handlePaymentCallback 处理已通过验签（或内部模拟）的支付回调
+func (s *PaymentService) handlePaymentCallback(ctx context.Context, req *PaymentCallbackRequest) error {
 	log.Printf("⚠️ 处理支付回调: %v\n", req)
 	// 1. 参数校验
 	if req.PaymentNo == "" {
@@ -367,11 +426,6 @@ func (s *PaymentService) HandlePaymentCallback(ctx context.Context, req *Payment
 		log.Printf("⚠️ 交易号已存在: %s, 支付单号: %s", req.TradeNo, otherPayment.PaymentNo)
 		return fmt.Errorf("交易号已存在: %s, 支付单号: %s", req.TradeNo, otherPayment.PaymentNo)
 	}
-	// 4. 签名校验（TODO: 后续实现），校验是否是平台发来的回调，防止伪造回调 采用支付宝公钥对签名进行校验
-	// if !s.verifySign(req) {
-	//     return fmt.Errorf("签名校验失败")
-	// }
-
 	// 5. 金额校验
 	callbackAmount, err := strconv.ParseFloat(req.Amount, 64)
 	if err != nil {
@@ -429,26 +483,10 @@ func (s *PaymentService) HandlePaymentCallback(ctx context.Context, req *Payment
 
 		// 7.3 仅在支付成功时写入 Outbox 事件
 		if newStatus == model.PaymentStatusSuccess {
-			payload := map[string]interface{}{
-				"payment_no": payment.PaymentNo,
-				"order_no":   payment.OrderNo,
-				"user_id":    payment.UserID,
-				"amount":     payment.Amount,
-				"trade_no":   payment.TradeNo,
-				"paid_at":    payment.PaidAt,
-			}
-			payloadBytes, err := json.Marshal(payload)
+			event, err := buildPaymentSucceededEvent(payment)
 			if err != nil {
 				log.Printf("⚠️ 序列化支付成功事件失败: %v\n", err)
-				return fmt.Errorf("序列化支付成功事件失败: %w", err)
-			}
-
-			event := &model.PaymentOutbox{
-				EventType:   "payment.succeeded",
-				AggregateID: payment.PaymentNo,
-				Payload:     string(payloadBytes),
-				Status:      repository.OutboxStatusPending,
-				RetryCount:  0,
+				return err
 			}
 
 			if err := s.outboxRepo.Create(txCtx, event); err != nil {
@@ -471,6 +509,29 @@ func (s *PaymentService) HandlePaymentCallback(ctx context.Context, req *Payment
 	return nil
 }
 
+// buildPaymentSucceededEvent 构建支付成功 Outbox 事件（订单服务据此将订单置为已支付）
+func buildPaymentSucceededEvent(payment *model.Payment) (*model.PaymentOutbox, error) {
+	payload := map[string]interface{}{
+		"payment_no": payment.PaymentNo,
+		"order_no":   payment.OrderNo,
+		"user_id":    payment.UserID,
+		"amount":     payment.Amount,
+		"trade_no":   payment.TradeNo,
+		"paid_at":    payment.PaidAt,
+	}
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("序列化支付成功事件失败: %w", err)
+	}
+	return &model.PaymentOutbox{
+		EventType:   "payment.succeeded",
+		AggregateID: payment.PaymentNo,
+		Payload:     string(payloadBytes),
+		Status:      repository.OutboxStatusPending,
+		RetryCount:  0,
+	}, nil
+}
+
 // QueryPaymentStatus 查询支付状态
 func (s *PaymentService) QueryPaymentStatus(ctx context.Context, paymentNo string) (int8, string, error) {
 	if paymentNo == "" {
@@ -484,7 +545,15 @@ func (s *PaymentService) QueryPaymentStatus(ctx context.Context, paymentNo strin
 	if payment == nil {
 		return 0, "", fmt.Errorf("支付单不存在: %s", paymentNo)
 	}
-	//TODO:超过一定时间需要对账和异常处理
+	// 长时间未完成的支付单主动查询渠道，避免回调丢失导致状态停滞
+	if (payment.Status == model.PaymentStatusPending || payment.Status == model.PaymentStatusProcessing) &&
+		time.Since(payment.CreatedAt) > PaymentReconcileAfter {
+		if err := s.reconcilePayment(ctx, payment); err != nil {
+			log.Printf("⚠️ 支付单对账失败: payment_no=%s, err=%v\n", paymentNo, err)
+		} else if latest, err := s.paymentRepo.GetPaymentByPaymentNo(ctx, paymentNo); err == nil && latest != nil {
+			payment = latest
+		}
+	}
 	return payment.Status, payment.TradeNo, nil
 }
 
@@ -501,6 +570,26 @@ func (s *PaymentService) CloseExpiredPayments(ctx context.Context, limit int) er
 	}
 
 	for _, payment := range expiredPayments {
+		// 订单支付截止时间被延长时，延长支付单过期时间而不关闭
+		if s.extendPaymentExpiry(ctx, payment) {
+			continue
+		}
+
+		// 先关闭第三方交易，防止关单后用户仍能完成支付
+		if gw, err := s.paymentGateway(payment.PayChannel); err == nil {
+			if err := gw.ClosePayment(ctx, payment.PaymentNo); err != nil {
+				if errors.Is(err, gateway.ErrTradeAlreadyPaid) {
+					// 第三方已支付，等待支付回调或对账处理，不关闭支付单
+					log.Printf("⚠️ 第三方交易已支付，跳过关单: payment_no=%s\n", payment.PaymentNo)
+					continue
+				}
+				if !errors.Is(err, gateway.ErrTradeNotFound) {
+					log.Printf("⚠️ 关闭第三方交易失败: payment_no=%s, err=%v\n", payment.PaymentNo, err)
+					continue
+				}
+			}
+		}
+
 		// 更新支付单状态为已关闭
 		oldStatus := payment.Status
 		payment.Status = model.PaymentStatusClosed
@@ -529,6 +618,39 @@ func (s *PaymentService) CloseExpiredPayments(ctx context.Context, limit int) er
 	return nil
 }
 
+// paymentExpiredAt 支付单过期时间：取订单支付截止时间，订单未返回截止时间时按支付超时时间计算
+func (s *PaymentService) paymentExpiredAt(order *orderv1.Order) time.Time {
+	if order.PayDeadline != nil {
+		return order.PayDeadline.AsTime()
+	}
+	return time.Now().Add(s.paymentTimeout)
+}
+
+// extendPaymentExpiry 订单仍待支付且支付截止时间晚于支付单过期时间时，延长支付单过期时间并返回 true
+// 查询订单失败时返回 false，按原过期时间关闭支付单
+func (s *PaymentService) extendPaymentExpiry(ctx context.Context, payment *model.Payment) bool {
+	// 定时任务没有用户上下文，使用支付单的用户ID查询订单
+	orderCtx := context.WithValue(ctx, middleware.UserIDKey, payment.UserID)
+	order, err := s.orderClient.GetOrderByNo(orderCtx, payment.OrderNo)
+	if err != nil || order == nil || order.PayDeadline == nil {
+		return false
+	}
+	if int8(order.Status) != model.PaymentStatusPending {
+		return false
+	}
+	deadline := order.PayDeadline.AsTime()
+	if !deadline.After(time.Now()) {
+		return false
+	}
+	payment.ExpiredAt = &deadline
+	if err := s.paymentRepo.UpdatePayment(ctx, payment); err != nil {
+		log.Printf("⚠️ 延长支付单过期时间失败: payment_no=%s, err=%v\n", payment.PaymentNo, err)
+		return true
+	}
+	log.Printf("ℹ️ 订单支付截止时间已延长，支付单过期时间同步延长: payment_no=%s, expired_at=%s\n", payment.PaymentNo, deadline.Format(time.RFC3339))
+	return true
+}
+
 // generatePaymentNo 生成支付单号
 // 格式：{前缀(2位)}{日期时间(12位)}{随机数(6位)}{扩展位(2位)} = 总共22位
 func (s *PaymentService) generatePaymentNo() string {
@@ -647,79 +769,3 @@ func isDuplicateKeyError(err error) bool {
 		strings.Contains(errStr, "UNIQUE constraint") ||
 		strings.Contains(errStr, "duplicate key")
 }
-
-// generatePaymentParamsForLearning 生成支付参数（学习模式：模拟支付参数）
-// 在实际生产环境中，这里应该调用第三方支付SDK（如支付宝、微信支付）
-// 学习模式下返回模拟的支付URL、二维码和支付参数，便于前端开发和测试
-func (s *PaymentService) generatePaymentParamsForLearning(payment *model.Payment, channel *model.PaymentChannel) (payURL, qrCode string, payParams map[string]string) {
-	payParams = make(map[string]string)
-
-	// 根据支付渠道生成不同的模拟参数
-	switch channel.ChannelCode {
-	case model.PayChannelWeChat:
-		// 微信支付 - 学习模式模拟
-		// H5/PC支付URL（模拟）
-		payURL = fmt.Sprintf("https://pay.weixin.qq.com/learning?payment_no=%s&amount=%.2f&order_no=%s",
-			payment.PaymentNo, payment.Amount, payment.OrderNo)
-
-		// 二维码（模拟微信支付码串，前端可以生成二维码）
-		qrCode = fmt.Sprintf("weixin://wxpay/bizpayurl?pr=LEARNING_%s", payment.PaymentNo)
-
-		// APP支付参数（模拟）
-		payParams = map[string]string{
-			"appId":     channel.AppID,
-			"timeStamp": fmt.Sprintf("%d", time.Now().Unix()),
-			"nonceStr":  fmt.Sprintf("learning_%s", payment.PaymentNo),
-			"package":   fmt.Sprintf("prepay_id=LEARNING_%s", payment.PaymentNo),
-			"signType":  "RSA",
-			"paySign":   "LEARNING_MODE_SIGNATURE", // 学习模式签名（非真实签名）
-		}
-
-	case model.PayChannelAlipay:
-		// 支付宝支付 - 学习模式模拟
-		// H5/PC支付URL（模拟支付宝沙箱环境）
-		payURL = fmt.Sprintf("https://openapi.alipaydev.com/gateway.do?payment_no=%s&amount=%.2f&order_no=%s",
-			payment.PaymentNo, payment.Amount, payment.OrderNo)
-
-		// 二维码（模拟支付宝二维码URL）
-		qrCode = fmt.Sprintf("https://qr.alipay.com/learning_%s", payment.PaymentNo)
-
-		// APP支付参数（模拟）
-		payParams = map[string]string{
-			"app_id":    channel.AppID,
-			"method":    "alipay.trade.app.pay",
-			"charset":   "utf-8",
-			"sign_type": "RSA2",
-			"timestamp": time.Now().Format("2006-01-02 15:04:05"),
-			"version":   "1.0",
-			"biz_content": fmt.Sprintf(`{"out_trade_no":"%s","total_amount":"%.2f","subject":"学习模式订单-%s"}`,
-				payment.PaymentNo, payment.Amount, payment.OrderNo),
-			"sign": "LEARNING_MODE_SIGNATURE", // 学习模式签名（非真实签名）
-		}
-
-	case model.PayChannelBalance:
-		// 余额支付 - 不需要跳转，直接扣款
-		payURL = ""
-		qrCode = ""
-		payParams = map[string]string{
-			"payment_no": payment.PaymentNo,
-			"amount":     fmt.Sprintf("%.2f", payment.Amount),
-			"channel":    "balance",
-			"note":       "余额支付，无需跳转",
-		}
-
-	default:
-		// 未知渠道，返回空参数
-		fmt.Printf("⚠️ 未知支付渠道: %s\n", channel.ChannelCode)
-		return "", "", make(map[string]string)
-	}
-
-	// 学习模式提示日志
-	fmt.Printf("📚 [学习模式] 支付参数已生成 - 支付单号: %s, 渠道: %s, 金额: %.2f\n",
-		payment.PaymentNo, channel.ChannelCode, payment.Amount)
-	fmt.Printf("   - PayURL: %s\n", payURL)
-	fmt.Printf("   - QRCode: %s\n", qrCode)
-	fmt.Printf("   - PayParams: %v\n", payParams)
-
-	return payURL, qrCode, payParams
-}
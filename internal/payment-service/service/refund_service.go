@@ -0,0 +1,491 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	orderv1 "zjMall/gen/go/api/proto/order"
+	"zjMall/internal/payment-service/model"
+	"zjMall/internal/payment-service/repository"
+)
+
+const (
+	RefundLockKeyPrefix     = "payment:refund:lock" // 退款锁key前缀（按支付单加锁，保证可退金额计算的一致性）
+	RefundLockExpireSeconds = 30                    // 退款锁有效期30秒
+)
+
+// 退款 Outbox 事件类型
+const (
+	RefundEventCreated   = "refund.created"   // 退款申请已受理
+	RefundEventSucceeded = "refund.succeeded" // 退款成功
+	RefundEventFailed    = "refund.failed"    // 退款失败
+)
+
+// CreateRefundRequest 申请退款请求
+type CreateRefundRequest struct {
+	OrderNo      string
+	UserID       string // 操作人（管理员或售后审核通过后发起调用的服务），调用方需已校验权限
+	RefundAmount string // 为空时退还剩余可退金额
+	RefundReason string
+	RequestNo    string // 业务请求号（可选），同一订单同一请求号重复申请时返回已有的退款单
+}
+
+// RefundCallbackRequest 退款回调请求
+type RefundCallbackRequest struct {
+	PayChannel    string
+	RefundNo      string
+	RefundTradeNo string
+	Amount        string
+	Status        string
+	Sign          string
+	ExtraParams   map[string]string
+}
+
+// refundRequestData 退款申请快照，保存在 refunds.request_data 中
+// OrderStatus 记录申请退款时的订单状态，退款失败时订单服务据此恢复订单状态
+type refundRequestData struct {
+	OrderStatus  int32   `json:"order_status"`
+	RefundAmount float64 `json:"refund_amount"`
+	RefundReason string  `json:"refund_reason"`
+	OperatorID   string  `json:"operator_id"`
+	FullRefund   bool    `json:"full_refund"`
+}
+
+// CreateRefund 申请退款
+// 累计退款金额（退款中 + 退款成功）不能超过支付金额，本次退款后若已无剩余可退金额则视为整单退款
+// 携带业务请求号时按请求号幂等：已有退款中或退款成功的退款单直接返回，退款失败后可用同一请求号重新申请
+func (s *PaymentService) CreateRefund(ctx context.Context, req *CreateRefundRequest) (*model.Refund, error) {
+	refund, created, err := s.createRefund(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		log.Printf("ℹ️ 业务请求号已存在有效退款单，忽略重复申请: request_no=%s, refund_no=%s", req.RequestNo, refund.RefundNo)
+		return refund, nil
+	}
+
+	// 第三方渠道通过网关发起退款，退款结果通过 RefundCallback 异步回传；
+	// 余额支付直接退回用户钱包，与 CreatePayment 中的余额支付保持一致
+	if refund.PayChannel != model.PayChannelBalance {
+		if err := s.requestGatewayRefund(ctx, refund); err != nil {
+			// 渠道未受理，按退款失败处理，释放可退金额并恢复订单状态
+			log.Printf("⚠️ 渠道退款请求失败: refund_no=%s, err=%v\n", refund.RefundNo, err)
+			callbackReq := &RefundCallbackRequest{
+				PayChannel:  refund.PayChannel,
+				RefundNo:    refund.RefundNo,
+				Amount:      fmt.Sprintf("%.2f", refund.RefundAmount),
+				Status:      "FAILED",
+				ExtraParams: map[string]string{"error_message": fmt.Sprintf("渠道退款请求失败: %v", err)},
+			}
+			if err := s.handleRefundCallback(ctx, callbackReq); err != nil {
+				log.Printf("⚠️ 标记退款失败出错: refund_no=%s, err=%v\n", refund.RefundNo, err)
+			}
+			if latest, err := s.refundRepo.GetRefundByRefundNo(ctx, refund.RefundNo); err == nil && latest != nil {
+				refund = latest
+			}
+		}
+	} else {
+		// 余额退款同步入账到用户钱包，再按退款成功回调推进退款单与订单状态
+		callbackReq := &RefundCallbackRequest{
+			PayChannel:    refund.PayChannel,
+			RefundNo:      refund.RefundNo,
+			RefundTradeNo: fmt.Sprintf("BALANCE_%s", refund.RefundNo),
+			Amount:        fmt.Sprintf("%.2f", refund.RefundAmount),
+			Status:        "SUCCESS",
+		}
+		if err := s.refundToBalance(ctx, refund); err != nil {
+			log.Printf("⚠️ 退款到余额失败: refund_no=%s, err=%v\n", refund.RefundNo, err)
+			callbackReq.Status = "FAILED"
+			callbackReq.RefundTradeNo = ""
+			callbackReq.ExtraParams = map[string]string{"error_message": fmt.Sprintf("退款到余额失败: %v", err)}
+		}
+		if err := s.handleRefundCallback(ctx, callbackReq); err != nil {
+			log.Printf("⚠️ 余额退款回调处理失败: refund_no=%s, err=%v\n", refund.RefundNo, err)
+		} else if latest, err := s.refundRepo.GetRefundByRefundNo(ctx, refund.RefundNo); err == nil && latest != nil {
+			refund = latest
+		}
+	}
+
+	return refund, nil
+}
+
+// createRefund 在退款锁内校验可退金额并创建退款单，created 为 false 表示返回的是业务请求号已关联的退款单
+func (s *PaymentService) createRefund(ctx context.Context, req *CreateRefundRequest) (refund *model.Refund, created bool, err error) {
+	if req.OrderNo == "" {
+		return nil, false, fmt.Errorf("订单号不能为空")
+	}
+	if req.UserID == "" {
+		return nil, false, fmt.Errorf("用户未登录")
+	}
+	if s.orderClient == nil {
+		return nil, false, fmt.Errorf("订单服务不可用，暂时无法退款")
+	}
+
+	// 1. 查询支付单
+	payment, err := s.paymentRepo.GetPaymentByOrderNo(ctx, req.OrderNo)
+	if err != nil {
+		log.Printf("⚠️ 查询支付单失败: %v\n", err)
+		return nil, false, fmt.Errorf("查询支付单失败: %w", err)
+	}
+	if payment == nil {
+		return nil, false, fmt.Errorf("订单未找到支付记录: %s", req.OrderNo)
+	}
+	// 2. 按支付单加锁，避免并发退款超出可退金额
+	lockKey := fmt.Sprintf("%s:%s", RefundLockKeyPrefix, payment.PaymentNo)
+	acquired, err := s.lockService.AcquireLock(ctx, lockKey, time.Duration(RefundLockExpireSeconds)*time.Second)
+	if err != nil || !acquired {
+		log.Printf("⚠️ 获取退款锁失败: %v\n", err)
+		return nil, false, fmt.Errorf("系统繁忙，请稍后重试")
+	}
+	defer s.lockService.ReleaseLock(ctx, lockKey)
+
+	// 加锁后重新读取支付单，保证状态最新
+	payment, err = s.paymentRepo.GetPaymentByPaymentNo(ctx, payment.PaymentNo)
+	if err != nil {
+		return nil, false, fmt.Errorf("查询支付单失败: %w", err)
+	}
+	if payment == nil {
+		return nil, false, fmt.Errorf("支付单不存在")
+	}
+	// 业务请求号幂等：在锁内检查，保证同一请求号并发申请只会创建一笔退款单
+	if req.RequestNo != "" {
+		existing, err := s.refundRepo.GetRefundByRequestNo(ctx, req.OrderNo, req.RequestNo, model.RefundStatusProcessing, model.RefundStatusSuccess)
+		if err != nil {
+			return nil, false, fmt.Errorf("查询退款单失败: %w", err)
+		}
+		if existing != nil {
+			return existing, false, nil
+		}
+	}
+
+	switch payment.Status {
+	case model.PaymentStatusSuccess:
+	case model.PaymentStatusRefunded:
+		return nil, false, fmt.Errorf("订单已全额退款")
+	default:
+		return nil, false, fmt.Errorf("支付单未支付成功，无法退款")
+	}
+
+	// 3. 校验订单状态：仅已支付、已发货、已完成的订单可以退款（买家退款统一走售后申请，审核通过后由订单服务发起）
+	order, err := s.orderClient.GetOrderByNo(ctx, req.OrderNo)
+	if err != nil {
+		log.Printf("⚠️ 查询订单失败: %v\n", err)
+		return nil, false, fmt.Errorf("查询订单失败: %w", err)
+	}
+	switch order.Status {
+	case orderv1.OrderStatus_ORDER_STATUS_PAID, orderv1.OrderStatus_ORDER_STATUS_SHIPPED, orderv1.OrderStatus_ORDER_STATUS_COMPLETED:
+	default:
+		return nil, false, fmt.Errorf("当前订单状态不允许退款")
+	}
+
+	// 4. 计算剩余可退金额（退款中的金额同样占用额度）
+	refunded, err := s.refundRepo.SumRefundAmount(ctx, payment.PaymentNo, model.RefundStatusProcessing, model.RefundStatusSuccess)
+	if err != nil {
+		return nil, false, fmt.Errorf("查询已退款金额失败: %w", err)
+	}
+	remainingCents := toCents(payment.Amount) - toCents(refunded)
+	if remainingCents <= 0 {
+		return nil, false, fmt.Errorf("订单已无可退金额")
+	}
+
+	refundCents := remainingCents
+	if req.RefundAmount != "" {
+		amount, err := strconv.ParseFloat(req.RefundAmount, 64)
+		if err != nil {
+			return nil, false, fmt.Errorf("退款金额格式错误: %w", err)
+		}
+		refundCents = toCents(amount)
+		if refundCents <= 0 {
+			return nil, false, fmt.Errorf("退款金额必须大于0")
+		}
+		if refundCents > remainingCents {
+			return nil, false, fmt.Errorf("退款金额超出可退金额: 可退金额=%.2f", fromCents(remainingCents))
+		}
+	}
+
+	refundType := model.RefundTypePartial
+	if refundCents == toCents(payment.Amount) {
+		refundType = model.RefundTypeFull
+	}
+	fullRefund := refundCents == remainingCents
+
+	requestData, _ := json.Marshal(&refundRequestData{
+		OrderStatus:  int32(order.Status),
+		RefundAmount: fromCents(refundCents),
+		RefundReason: req.RefundReason,
+		OperatorID:   req.UserID,
+		FullRefund:   fullRefund,
+	})
+
+	refund = &model.Refund{
+		RefundNo:     s.generateRefundNo(),
+		PaymentNo:    payment.PaymentNo,
+		OrderNo:      payment.OrderNo,
+		UserID:       payment.UserID,
+		RequestNo:    req.RequestNo,
+		RefundAmount: fromCents(refundCents),
+		RefundReason: req.RefundReason,
+		RefundType:   refundType,
+		PayChannel:   payment.PayChannel,
+		Status:       model.RefundStatusProcessing,
+		TradeNo:      payment.TradeNo,
+		RequestData:  string(requestData),
+		Version:      1,
+	}
+
+	// 5. 在一个本地事务中创建退款单并写入 Outbox 事件，订单服务消费后将订单置为退款中
+	if err := s.refundRepo.WithTransaction(ctx, func(txCtx context.Context, txRepo repository.RefundRepository, _ repository.PaymentRepository, txOutboxRepo repository.PaymentOutboxRepository) error {
+		if err := txRepo.CreateRefund(txCtx, refund); err != nil {
+			return fmt.Errorf("创建退款单失败: %w", err)
+		}
+		event, err := buildRefundOutboxEvent(RefundEventCreated, refund, int32(order.Status), fullRefund, false)
+		if err != nil {
+			return err
+		}
+		if err := txOutboxRepo.Create(txCtx, event); err != nil {
+			return fmt.Errorf("写入退款 Outbox 事件失败: %w", err)
+		}
+		return nil
+	}); err != nil {
+		log.Printf("⚠️ 创建退款单失败: order_no=%s, err=%v\n", req.OrderNo, err)
+		return nil, false, err
+	}
+
+	// 记录支付日志
+	paymentLog := &model.PaymentLog{
+		PaymentNo:   payment.PaymentNo,
+		OrderNo:     payment.OrderNo,
+		UserID:      payment.UserID,
+		Action:      model.PaymentLogActionRefund,
+		Channel:     payment.PayChannel,
+		Amount:      refund.RefundAmount,
+		TradeNo:     payment.TradeNo,
+		RequestData: refund.RequestData,
+	}
+	if err := s.paymentLogRepo.CreatePaymentLog(ctx, paymentLog); err != nil {
+		log.Printf("⚠️ 记录退款日志失败: %v\n", err)
+	}
+
+	log.Printf("✅ 退款单创建成功: refund_no=%s, order_no=%s, amount=%.2f", refund.RefundNo, refund.OrderNo, refund.RefundAmount)
+	return refund, true, nil
+}
+
+// GetRefund 查询退款单
+func (s *PaymentService) GetRefund(ctx context.Context, refundNo string) (*model.Refund, error) {
+	if refundNo == "" {
+		return nil, fmt.Errorf("退款单号不能为空")
+	}
+
+	refund, err := s.refundRepo.GetRefundByRefundNo(ctx, refundNo)
+	if err != nil {
+		return nil, fmt.Errorf("查询退款单失败: %w", err)
+	}
+
+	return refund, nil
+}
+
+// HandleRefundCallback 处理退款回调
+// 退款成功后若累计退款金额达到支付金额，则将支付单置为已退款
+func (s *PaymentService) HandleRefundCallback(ctx context.Context, req *RefundCallbackRequest) error {
+	if req.RefundNo == "" {
+		return fmt.Errorf("退款单号不能为空")
+	}
+	if err := s.verifyRefundCallback(ctx, req); err != nil {
+		return err
+	}
+	return s.handleRefundCallback(ctx, req)
+}
+
+// handleRefundCallback 处理已通过验签（或内部模拟）的退款回调
+func (s *PaymentService) handleRefundCallback(ctx context.Context, req *RefundCallbackRequest) error {
+
+	refund, err := s.refundRepo.GetRefundByRefundNo(ctx, req.RefundNo)
+	if err != nil {
+		return fmt.Errorf("查询退款单失败: %w", err)
+	}
+	if refund == nil {
+		return fmt.Errorf("退款单不存在: %s", req.RefundNo)
+	}
+
+	// 与申请退款共用同一把锁，保证累计退款金额计算一致
+	lockKey := fmt.Sprintf("%s:%s", RefundLockKeyPrefix, refund.PaymentNo)
+	acquired, err := s.lockService.AcquireLock(ctx, lockKey, time.Duration(RefundLockExpireSeconds)*time.Second)
+	if err != nil || !acquired {
+		log.Printf("⚠️ 获取退款锁失败: %v\n", err)
+		return fmt.Errorf("系统繁忙，请稍后重试")
+	}
+	defer s.lockService.ReleaseLock(ctx, lockKey)
+
+	// 加锁后重新读取退款单
+	refund, err = s.refundRepo.GetRefundByRefundNo(ctx, req.RefundNo)
+	if err != nil {
+		return fmt.Errorf("查询退款单失败: %w", err)
+	}
+
+	newStatus := model.RefundStatusFailed
+	if strings.EqualFold(req.Status, "success") {
+		newStatus = model.RefundStatusSuccess
+	}
+
+	// 幂等处理：退款单已是终态时，相同结果直接返回成功
+	if refund.Status != model.RefundStatusProcessing {
+		if refund.Status == newStatus {
+			return nil
+		}
+		log.Printf("⚠️ 退款单已处于终态: refund_no=%s, status=%d, callback_status=%s", refund.RefundNo, refund.Status, req.Status)
+		return fmt.Errorf("退款单已处于终态，状态=%d", refund.Status)
+	}
+
+	// 金额校验
+	callbackAmount, err := strconv.ParseFloat(req.Amount, 64)
+	if err != nil {
+		return fmt.Errorf("退款金额格式错误: %w", err)
+	}
+	if toCents(callbackAmount) != toCents(refund.RefundAmount) {
+		log.Printf("⚠️ 退款金额不一致: refund_no=%s, 退款单金额=%.2f, 回调金额=%.2f\n", refund.RefundNo, refund.RefundAmount, callbackAmount)
+		return fmt.Errorf("退款金额不一致: 退款单金额=%.2f, 回调金额=%.2f", refund.RefundAmount, callbackAmount)
+	}
+
+	payment, err := s.paymentRepo.GetPaymentByPaymentNo(ctx, refund.PaymentNo)
+	if err != nil {
+		return fmt.Errorf("查询支付单失败: %w", err)
+	}
+	if payment == nil {
+		return fmt.Errorf("支付单不存在: %s", refund.PaymentNo)
+	}
+
+	var snapshot refundRequestData
+	if refund.RequestData != "" {
+		if err := json.Unmarshal([]byte(refund.RequestData), &snapshot); err != nil {
+			log.Printf("⚠️ 解析退款申请快照失败: refund_no=%s, err=%v", refund.RefundNo, err)
+		}
+	}
+
+	responseData, _ := json.Marshal(req)
+	refund.Status = newStatus
+	refund.RefundTradeNo = req.RefundTradeNo
+	refund.ResponseData = string(responseData)
+	if newStatus == model.RefundStatusSuccess {
+		now := time.Now()
+		refund.RefundedAt = &now
+	} else {
+		refund.ErrorMessage = req.ExtraParams["error_message"]
+		if refund.ErrorMessage == "" {
+			refund.ErrorMessage = fmt.Sprintf("第三方退款状态: %s", req.Status)
+		}
+	}
+
+	oldPaymentStatus := payment.Status
+	fullyRefunded := false
+	if err := s.refundRepo.WithTransaction(ctx, func(txCtx context.Context, txRepo repository.RefundRepository, txPaymentRepo repository.PaymentRepository, txOutboxRepo repository.PaymentOutboxRepository) error {
+		if err := txRepo.UpdateRefund(txCtx, refund); err != nil {
+			return fmt.Errorf("更新退款单状态失败: %w", err)
+		}
+
+		eventType := RefundEventFailed
+		if newStatus == model.RefundStatusSuccess {
+			eventType = RefundEventSucceeded
+
+			// 累计退款成功金额达到支付金额时，支付单置为已退款
+			refunded, err := txRepo.SumRefundAmount(txCtx, refund.PaymentNo, model.RefundStatusSuccess)
+			if err != nil {
+				return fmt.Errorf("查询已退款金额失败: %w", err)
+			}
+			if toCents(refunded) >= toCents(payment.Amount) {
+				fullyRefunded = true
+				payment.Status = model.PaymentStatusRefunded
+				if err := txPaymentRepo.UpdatePayment(txCtx, payment); err != nil {
+					return fmt.Errorf("更新支付单状态失败: %w", err)
+				}
+			}
+		}
+
+		event, err := buildRefundOutboxEvent(eventType, refund, snapshot.OrderStatus, snapshot.FullRefund, fullyRefunded)
+		if err != nil {
+			return err
+		}
+		if err := txOutboxRepo.Create(txCtx, event); err != nil {
+			return fmt.Errorf("写入退款 Outbox 事件失败: %w", err)
+		}
+		return nil
+	}); err != nil {
+		log.Printf("⚠️ 处理退款回调失败: refund_no=%s, err=%v\n", refund.RefundNo, err)
+		return err
+	}
+
+	// 记录支付日志
+	paymentLog := &model.PaymentLog{
+		PaymentNo:    refund.PaymentNo,
+		OrderNo:      refund.OrderNo,
+		UserID:       refund.UserID,
+		Action:       model.PaymentLogActionRefundCallback,
+		Channel:      req.PayChannel,
+		Amount:       refund.RefundAmount,
+		TradeNo:      req.RefundTradeNo,
+		RequestData:  refund.ResponseData,
+		ErrorMessage: refund.ErrorMessage,
+	}
+	if fullyRefunded {
+		paymentLog.FromStatus = &oldPaymentStatus
+		paymentLog.ToStatus = &payment.Status
+	}
+	if err := s.paymentLogRepo.CreatePaymentLog(ctx, paymentLog); err != nil {
+		log.Printf("⚠️ 记录退款日志失败: %v\n", err)
+	}
+
+	log.Printf("✅ 退款回调处理完成: refund_no=%s, status=%d, fully_refunded=%v", refund.RefundNo, refund.Status, fullyRefunded)
+	return nil
+}
+
+// buildRefundOutboxEvent 构造退款 Outbox 事件
+// fullRefund 表示本次退款完成后支付单无剩余可退金额，订单服务据此决定是否流转订单状态
+func buildRefundOutboxEvent(eventType string, refund *model.Refund, orderStatus int32, fullRefund, fullyRefunded bool) (*model.PaymentOutbox, error) {
+	payload := map[string]interface{}{
+		"event_type":     eventType,
+		"refund_no":      refund.RefundNo,
+		"payment_no":     refund.PaymentNo,
+		"order_no":       refund.OrderNo,
+		"user_id":        refund.UserID,
+		"request_no":     refund.RequestNo,
+		"refund_amount":  refund.RefundAmount,
+		"order_status":   orderStatus,
+		"full_refund":    fullRefund,
+		"fully_refunded": fullyRefunded,
+		"refunded_at":    refund.RefundedAt,
+	}
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("序列化退款事件失败: %w", err)
+	}
+	return &model.PaymentOutbox{
+		EventType:   eventType,
+		AggregateID: refund.RefundNo,
+		Payload:     string(payloadBytes),
+		Status:      repository.OutboxStatusPending,
+	}, nil
+}
+
+// generateRefundNo 生成退款单号
+// 格式：{前缀(2位)}{日期时间(12位)}{随机数(6位)}{扩展位(2位)} = 总共22位
+func (s *PaymentService) generateRefundNo() string {
+	dateTime := time.Now().Format("200601021504")
+	randomNum := rand.Intn(1000000)
+	return fmt.Sprintf("%s%s%06d00", model.RefundNoPrefix, dateTime, randomNum)
+}
+
+// toCents 金额转换为分，避免浮点数比较误差
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// fromCents 分转换为金额
+func fromCents(cents int64) float64 {
+	return float64(cents) / 100
+}
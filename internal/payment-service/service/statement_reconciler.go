@@ -0,0 +1,396 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"zjMall/internal/payment-service/model"
+	"zjMall/internal/payment-service/repository"
+
+	"gorm.io/gorm"
+)
+
+const (
+	StatementBillDateLayout = "2006-01-02" // 账单日期格式
+	StatementFileDateLayout = "20060102"   // 对账单文件名中的日期格式：{渠道}_{YYYYMMDD}.csv
+)
+
+// statementTimeLayouts 对账单交易时间支持的格式
+var statementTimeLayouts = []string{"2006-01-02 15:04:05", time.RFC3339, "2006/01/02 15:04:05"}
+
+// StatementRecord 渠道对账单中的一笔交易
+type StatementRecord struct {
+	TradeNo   string     `json:"trade_no"`
+	Amount    float64    `json:"amount"`
+	Status    string     `json:"status"`
+	TradeTime *time.Time `json:"trade_time"`
+}
+
+// StatementReconcileSummary 对账单比对结果汇总
+type StatementReconcileSummary struct {
+	PayChannel      string
+	BillDate        string
+	TotalRows       int // 账单交易笔数
+	MatchedRows     int // 比对一致笔数
+	MismatchRows    int // 差异笔数（含渠道缺失）
+	NewMismatchRows int // 本次新增差异笔数
+}
+
+// ParseStatementCSV 解析渠道对账单 CSV（列：trade_no,amount,status,time；首行可为表头）
+// 任意一行格式错误时整体失败，避免按不完整的账单比对
+func ParseStatementCSV(r io.Reader) ([]*StatementRecord, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var records []*StatementRecord
+	line := 0
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			return nil, fmt.Errorf("对账单第%d行解析失败: %w", line, err)
+		}
+		if line == 1 && len(fields) > 0 && strings.EqualFold(strings.TrimSpace(strings.TrimPrefix(fields[0], "\ufeff")), "trade_no") {
+			continue // 表头
+		}
+		if len(fields) == 1 && strings.TrimSpace(fields[0]) == "" {
+			continue // 空行
+		}
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("对账单第%d行字段不足: 需要 trade_no,amount,status[,time]", line)
+		}
+
+		record := &StatementRecord{
+			TradeNo: strings.TrimSpace(fields[0]),
+			Status:  strings.ToUpper(strings.TrimSpace(fields[2])),
+		}
+		if record.TradeNo == "" {
+			return nil, fmt.Errorf("对账单第%d行交易号为空", line)
+		}
+		amount, err := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("对账单第%d行金额格式错误: %w", line, err)
+		}
+		record.Amount = amount
+		if len(fields) > 3 && strings.TrimSpace(fields[3]) != "" {
+			tradeTime, err := parseStatementTime(strings.TrimSpace(fields[3]))
+			if err != nil {
+				return nil, fmt.Errorf("对账单第%d行交易时间格式错误: %w", line, err)
+			}
+			record.TradeTime = &tradeTime
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func parseStatementTime(value string) (time.Time, error) {
+	var lastErr error
+	for _, layout := range statementTimeLayouts {
+		t, err := time.ParseInLocation(layout, value, time.Local)
+		if err == nil {
+			return t, nil
+		}
+		lastErr = err
+	}
+	return time.Time{}, lastErr
+}
+
+// isStatementPaid 渠道账单中表示已收款的交易状态（退款不影响原交易的收款事实）
+func isStatementPaid(status string) bool {
+	switch strings.ToUpper(status) {
+	case "SUCCESS", "TRADE_SUCCESS", "TRADE_FINISHED", "REFUND", "REFUNDED":
+		return true
+	}
+	return false
+}
+
+// isLocalPaid 本地支付单是否已收款
+func isLocalPaid(status int8) bool {
+	return status == model.PaymentStatusSuccess || status == model.PaymentStatusRefunded
+}
+
+// ImportStatement 导入渠道对账单并与本地支付单比对
+func (s *PaymentService) ImportStatement(ctx context.Context, payChannel, billDate string, r io.Reader) (*StatementReconcileSummary, error) {
+	records, err := ParseStatementCSV(r)
+	if err != nil {
+		return nil, err
+	}
+	return s.ReconcileStatement(ctx, payChannel, billDate, records)
+}
+
+// ReconcileStatement 将对账单与本地支付单逐笔比对，差异写入对账差异表
+// 1. 账单中的每笔交易按交易号查找本地支付单，区分本地缺失、金额不一致、状态不一致
+// 2. 本地在账单日已支付但账单中没有的交易记为渠道缺失
+// 注意：跨零点支付的交易可能出现在相邻日期的账单中，需结合前后一天的差异人工确认
+func (s *PaymentService) ReconcileStatement(ctx context.Context, payChannel, billDate string, records []*StatementRecord) (*StatementReconcileSummary, error) {
+	if s.reconciliationRepo == nil {
+		return nil, fmt.Errorf("对账功能未启用")
+	}
+	dayStart, err := time.ParseInLocation(StatementBillDateLayout, billDate, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("账单日期格式错误: %w", err)
+	}
+	dayEnd := dayStart.AddDate(0, 0, 1)
+
+	summary := &StatementReconcileSummary{
+		PayChannel: payChannel,
+		BillDate:   billDate,
+		TotalRows:  len(records),
+	}
+
+	// 同一交易号可能同时出现收款与退款记录，优先以收款记录参与比对
+	byTradeNo := make(map[string]*StatementRecord, len(records))
+	for _, record := range records {
+		if existing, ok := byTradeNo[record.TradeNo]; ok && isStatementPaid(existing.Status) {
+			continue
+		}
+		byTradeNo[record.TradeNo] = record
+	}
+
+	for _, record := range byTradeNo {
+		payment, err := s.paymentRepo.GetPaymentByTradeNo(ctx, record.TradeNo)
+		if err != nil {
+			return nil, fmt.Errorf("查询支付单失败: trade_no=%s, err=%w", record.TradeNo, err)
+		}
+		if payment != nil && payment.PayChannel != payChannel {
+			payment = nil
+		}
+
+		channelPaid := isStatementPaid(record.Status)
+		result := &model.ReconciliationResult{
+			PayChannel:       payChannel,
+			BillDate:         billDate,
+			TradeNo:          record.TradeNo,
+			ChannelAmount:    record.Amount,
+			ChannelStatus:    record.Status,
+			ChannelTradeTime: record.TradeTime,
+			Status:           model.ReconciliationStatusPending,
+		}
+		switch {
+		case payment == nil:
+			if !channelPaid {
+				// 渠道未收款且本地无记录（如未支付关闭的交易），无需对账
+				summary.MatchedRows++
+				continue
+			}
+			result.MismatchType = model.ReconciliationMismatchMissingLocal
+		case channelPaid != isLocalPaid(payment.Status):
+			result.MismatchType = model.ReconciliationMismatchStatus
+		case channelPaid && toCents(record.Amount) != toCents(payment.Amount):
+			result.MismatchType = model.ReconciliationMismatchAmount
+		default:
+			summary.MatchedRows++
+			continue
+		}
+		if payment != nil {
+			result.PaymentNo = payment.PaymentNo
+			result.OrderNo = payment.OrderNo
+			result.LocalAmount = payment.Amount
+			result.LocalStatus = payment.Status
+		}
+		if err := s.recordMismatch(ctx, result, payment, summary); err != nil {
+			return nil, err
+		}
+	}
+
+	// 本地已支付但账单中不存在的交易
+	paidPayments, err := s.paymentRepo.ListPaidPayments(ctx, payChannel, dayStart, dayEnd)
+	if err != nil {
+		return nil, fmt.Errorf("查询本地已支付支付单失败: %w", err)
+	}
+	for _, payment := range paidPayments {
+		if _, ok := byTradeNo[payment.TradeNo]; ok {
+			continue
+		}
+		result := &model.ReconciliationResult{
+			PayChannel:   payChannel,
+			BillDate:     billDate,
+			MismatchType: model.ReconciliationMismatchMissingChannel,
+			TradeNo:      payment.TradeNo,
+			PaymentNo:    payment.PaymentNo,
+			OrderNo:      payment.OrderNo,
+			LocalAmount:  payment.Amount,
+			LocalStatus:  payment.Status,
+			Status:       model.ReconciliationStatusPending,
+		}
+		if err := s.recordMismatch(ctx, result, payment, summary); err != nil {
+			return nil, err
+		}
+	}
+
+	log.Printf("✅ 对账单比对完成: channel=%s, bill_date=%s, total=%d, matched=%d, mismatch=%d, new=%d",
+		payChannel, billDate, summary.TotalRows, summary.MatchedRows, summary.MismatchRows, summary.NewMismatchRows)
+	return summary, nil
+}
+
+// recordMismatch 记录对账差异，首次发现时同时写入支付日志
+func (s *PaymentService) recordMismatch(ctx context.Context, result *model.ReconciliationResult, payment *model.Payment, summary *StatementReconcileSummary) error {
+	summary.MismatchRows++
+	created, err := s.reconciliationRepo.CreateReconciliationResult(ctx, result)
+	if err != nil {
+		return fmt.Errorf("记录对账差异失败: trade_no=%s, err=%w", result.TradeNo, err)
+	}
+	if !created {
+		return nil // 重复导入，差异已存在
+	}
+	summary.NewMismatchRows++
+
+	log.Printf("⚠️ 对账差异: channel=%s, bill_date=%s, type=%d, trade_no=%s, payment_no=%s",
+		result.PayChannel, result.BillDate, result.MismatchType, result.TradeNo, result.PaymentNo)
+	if payment == nil {
+		return nil
+	}
+
+	requestData, _ := json.Marshal(result)
+	mismatchLog := &model.PaymentLog{
+		PaymentNo:    payment.PaymentNo,
+		OrderNo:      payment.OrderNo,
+		UserID:       payment.UserID,
+		Action:       model.PaymentLogActionStatementMismatch,
+		Channel:      payment.PayChannel,
+		Amount:       payment.Amount,
+		TradeNo:      result.TradeNo,
+		RequestData:  string(requestData),
+		ErrorMessage: describeMismatch(result),
+	}
+	if err := s.paymentLogRepo.CreatePaymentLog(ctx, mismatchLog); err != nil {
+		log.Printf("⚠️ 记录支付日志失败: %v\n", err)
+	}
+	return nil
+}
+
+func describeMismatch(result *model.ReconciliationResult) string {
+	switch result.MismatchType {
+	case model.ReconciliationMismatchMissingLocal:
+		return fmt.Sprintf("本地缺失: 渠道金额=%.2f, 渠道状态=%s", result.ChannelAmount, result.ChannelStatus)
+	case model.ReconciliationMismatchMissingChannel:
+		return fmt.Sprintf("渠道缺失: 本地金额=%.2f, 本地状态=%d", result.LocalAmount, result.LocalStatus)
+	case model.ReconciliationMismatchAmount:
+		return fmt.Sprintf("金额不一致: 本地金额=%.2f, 渠道金额=%.2f", result.LocalAmount, result.ChannelAmount)
+	case model.ReconciliationMismatchStatus:
+		return fmt.Sprintf("状态不一致: 本地状态=%d, 渠道状态=%s", result.LocalStatus, result.ChannelStatus)
+	}
+	return fmt.Sprintf("未知差异类型: %d", result.MismatchType)
+}
+
+// ReconcileStatementDir 比对目录下指定账单日的全部对账单文件（文件名：{渠道}_{YYYYMMDD}.csv，定时任务调用）
+// 返回找到的对账单文件数，账单尚未下载到目录时为 0
+func (s *PaymentService) ReconcileStatementDir(ctx context.Context, dir string, billDate time.Time) (int, error) {
+	suffix := "_" + billDate.Format(StatementFileDateLayout) + ".csv"
+	files, err := filepath.Glob(filepath.Join(dir, "*"+suffix))
+	if err != nil {
+		return 0, fmt.Errorf("查找对账单文件失败: %w", err)
+	}
+	if len(files) == 0 {
+		log.Printf("ℹ️ 未找到对账单文件: dir=%s, bill_date=%s", dir, billDate.Format(StatementBillDateLayout))
+		return 0, nil
+	}
+
+	var errs []error
+	for _, file := range files {
+		payChannel := strings.TrimSuffix(filepath.Base(file), suffix)
+		if err := s.importStatementFile(ctx, payChannel, billDate.Format(StatementBillDateLayout), file); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", filepath.Base(file), err))
+		}
+	}
+	return len(files), errors.Join(errs...)
+}
+
+func (s *PaymentService) importStatementFile(ctx context.Context, payChannel, billDate, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = s.ImportStatement(ctx, payChannel, billDate, f)
+	return err
+}
+
+// ListReconciliationResults 分页查询对账差异
+func (s *PaymentService) ListReconciliationResults(ctx context.Context, filter repository.ReconciliationFilter, page, pageSize int) ([]*model.ReconciliationResult, int64, error) {
+	if s.reconciliationRepo == nil {
+		return nil, 0, fmt.Errorf("对账功能未启用")
+	}
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+	return s.reconciliationRepo.ListReconciliationResults(ctx, filter, (page-1)*pageSize, pageSize)
+}
+
+// ResolveReconciliationResult 处理对账差异（标记为已处理或已忽略），并写入支付日志留痕
+func (s *PaymentService) ResolveReconciliationResult(ctx context.Context, id string, status int8, remark, operatorID string) (*model.ReconciliationResult, error) {
+	if s.reconciliationRepo == nil {
+		return nil, fmt.Errorf("对账功能未启用")
+	}
+	if status != model.ReconciliationStatusResolved && status != model.ReconciliationStatusIgnored {
+		return nil, fmt.Errorf("处理结果只能为已处理或已忽略")
+	}
+
+	result, err := s.reconciliationRepo.GetReconciliationResultByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("查询对账差异失败: %w", err)
+	}
+	if result == nil {
+		return nil, fmt.Errorf("对账差异不存在: %s", id)
+	}
+	if result.Status != model.ReconciliationStatusPending {
+		return nil, fmt.Errorf("对账差异已处理，状态=%d", result.Status)
+	}
+
+	now := time.Now()
+	result.Status = status
+	result.ResolveRemark = remark
+	result.ResolvedBy = operatorID
+	result.ResolvedAt = &now
+	if err := s.reconciliationRepo.UpdateReconciliationResult(ctx, result); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("对账差异已被其他人处理，请刷新后重试")
+		}
+		return nil, fmt.Errorf("更新对账差异失败: %w", err)
+	}
+
+	if result.PaymentNo != "" {
+		requestData, _ := json.Marshal(map[string]interface{}{
+			"reconciliation_id": result.ID,
+			"bill_date":         result.BillDate,
+			"mismatch_type":     result.MismatchType,
+			"status":            result.Status,
+			"remark":            remark,
+			"operator_id":       operatorID,
+		})
+		resolveLog := &model.PaymentLog{
+			PaymentNo:   result.PaymentNo,
+			OrderNo:     result.OrderNo,
+			Action:      model.PaymentLogActionStatementResolve,
+			Channel:     result.PayChannel,
+			Amount:      result.LocalAmount,
+			TradeNo:     result.TradeNo,
+			RequestData: string(requestData),
+		}
+		if err := s.paymentLogRepo.CreatePaymentLog(ctx, resolveLog); err != nil {
+			log.Printf("⚠️ 记录支付日志失败: %v\n", err)
+		}
+	}
+	return result, nil
+}
@@ -10,7 +10,7 @@ import (
 // CreatePaymentRequestValidator 创建支付单请求校验器
 type CreatePaymentRequestValidator struct {
 	OrderNo    string `validate:"required" label:"订单号"`
-	PayChannel string `validate:"required,oneof=wechat alipay balance" label:"支付渠道"` // wechat-微信, alipay-支付宝, balance-余额
+	PayChannel string `validate:"omitempty,oneof=wechat alipay balance" label:"支付渠道"` // wechat-微信, alipay-支付宝, balance-余额，为空时使用默认渠道
 	ReturnURL  string `validate:"omitempty,url" label:"返回地址"`
 	Token      string `validate:"required" label:"幂等性Token"` // Token 可选，但建议使用
 }
@@ -64,3 +64,133 @@ func (v *PaymentCallbackRequestValidator) Validate() error {
 	}
 	return nil
 }
+
+// CreateRefundRequestValidator 申请退款请求校验器
+type CreateRefundRequestValidator struct {
+	OrderNo      string `validate:"required" label:"订单号"`
+	RefundAmount string `validate:"omitempty,numeric" label:"退款金额"` // 为空时退还剩余可退金额
+	RefundReason string `validate:"required,max=255" label:"退款原因"`
+	RequestNo    string `validate:"omitempty,max=64" label:"业务请求号"`
+}
+
+// NewCreateRefundRequestValidator 创建申请退款请求校验器
+func NewCreateRefundRequestValidator(req *paymentv1.CreateRefundRequest) *CreateRefundRequestValidator {
+	return &CreateRefundRequestValidator{
+		OrderNo:      req.OrderNo,
+		RefundAmount: req.RefundAmount,
+		RefundReason: req.RefundReason,
+		RequestNo:    req.RequestNo,
+	}
+}
+
+// Validate 校验请求参数
+func (v *CreateRefundRequestValidator) Validate() error {
+	if err := validator.ValidateStruct(v); err != nil {
+		return errors.New(validator.FormatError(err))
+	}
+	return nil
+}
+
+// RefundCallbackRequestValidator 退款回调请求校验器
+type RefundCallbackRequestValidator struct {
+	PayChannel    string            `validate:"required,oneof=wechat alipay balance" label:"支付渠道"`
+	RefundNo      string            `validate:"required" label:"退款单号"`
+	RefundTradeNo string            `validate:"required" label:"第三方退款交易号"`
+	Amount        string            `validate:"required,numeric" label:"退款金额"`
+	Status        string            `validate:"required" label:"退款状态"`
+	Sign          string            `validate:"required" label:"签名"`
+	ExtraParams   map[string]string `validate:"-" label:"扩展参数"`
+}
+
+// NewRefundCallbackRequestValidator 创建退款回调请求校验器
+func NewRefundCallbackRequestValidator(req *paymentv1.RefundCallbackRequest) *RefundCallbackRequestValidator {
+	return &RefundCallbackRequestValidator{
+		PayChannel:    req.PayChannel,
+		RefundNo:      req.RefundNo,
+		RefundTradeNo: req.RefundTradeNo,
+		Amount:        req.Amount,
+		Status:        req.Status,
+		Sign:          req.Sign,
+		ExtraParams:   req.ExtraParams,
+	}
+}
+
+// Validate 校验请求参数
+func (v *RefundCallbackRequestValidator) Validate() error {
+	if err := validator.ValidateStruct(v); err != nil {
+		return errors.New(validator.FormatError(err))
+	}
+	return nil
+}
+
+// ImportReconciliationStatementRequestValidator 导入对账单请求校验器
+type ImportReconciliationStatementRequestValidator struct {
+	PayChannel string `validate:"required,oneof=wechat alipay" label:"支付渠道"` // 余额支付为站内渠道，无渠道对账单
+	BillDate   string `validate:"required,datetime=2006-01-02" label:"账单日期"`
+	Content    string `validate:"required" label:"对账单内容"`
+}
+
+// NewImportReconciliationStatementRequestValidator 创建导入对账单请求校验器
+func NewImportReconciliationStatementRequestValidator(req *paymentv1.ImportReconciliationStatementRequest) *ImportReconciliationStatementRequestValidator {
+	return &ImportReconciliationStatementRequestValidator{
+		PayChannel: req.PayChannel,
+		BillDate:   req.BillDate,
+		Content:    req.Content,
+	}
+}
+
+// Validate 校验请求参数
+func (v *ImportReconciliationStatementRequestValidator) Validate() error {
+	if err := validator.ValidateStruct(v); err != nil {
+		return errors.New(validator.FormatError(err))
+	}
+	return nil
+}
+
+// ResolveReconciliationResultRequestValidator 处理对账差异请求校验器
+type ResolveReconciliationResultRequestValidator struct {
+	ID     string `validate:"required" label:"差异ID"`
+	Status int32  `validate:"oneof=2 3" label:"处理结果"` // 2-已处理，3-已忽略
+	Remark string `validate:"required,max=255" label:"处理说明"`
+}
+
+// NewResolveReconciliationResultRequestValidator 创建处理对账差异请求校验器
+func NewResolveReconciliationResultRequestValidator(req *paymentv1.ResolveReconciliationResultRequest) *ResolveReconciliationResultRequestValidator {
+	return &ResolveReconciliationResultRequestValidator{
+		ID:     req.Id,
+		Status: int32(req.Status),
+		Remark: req.Remark,
+	}
+}
+
+// Validate 校验请求参数
+func (v *ResolveReconciliationResultRequestValidator) Validate() error {
+	if err := validator.ValidateStruct(v); err != nil {
+		return errors.New(validator.FormatError(err))
+	}
+	return nil
+}
+
+// TopUpWalletRequestValidator 钱包充值请求校验器
+type TopUpWalletRequestValidator struct {
+	Amount  string `validate:"required,numeric" label:"充值金额"`
+	TopUpNo string `validate:"required,max=64" label:"充值单号"`
+	Remark  string `validate:"max=255" label:"备注"`
+}
+
+// NewTopUpWalletRequestValidator 创建钱包充值请求校验器
+func NewTopUpWalletRequestValidator(req *paymentv1.TopUpWalletRequest) *TopUpWalletRequestValidator {
+	return &TopUpWalletRequestValidator{
+		Amount:  req.Amount,
+		TopUpNo: req.TopUpNo,
+		Remark:  req.Remark,
+	}
+}
+
+// Validate 校验请求参数
+func (v *TopUpWalletRequestValidator) Validate() error {
+	if err := validator.ValidateStruct(v); err != nil {
+		return errors.New(validator.FormatError(err))
+	}
+	return nil
+}
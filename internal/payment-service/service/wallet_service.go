@@ -0,0 +1,261 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"zjMall/internal/payment-service/model"
+	"zjMall/internal/payment-service/repository"
+	"zjMall/pkg"
+
+	"gorm.io/gorm"
+)
+
+// errLedgerEntryExists 业务单号已记账（用于事务内回滚后按幂等处理）
+var errLedgerEntryExists = errors.New("钱包流水已存在")
+
+// TopUpWalletRequest 钱包充值请求
+type TopUpWalletRequest struct {
+	UserID     string
+	TopUpNo    string // 充值单号，同一充值单号只入账一次
+	Amount     string
+	Remark     string
+	OperatorID string
+}
+
+// GetWallet 查询用户钱包，未开户时返回余额为 0 的钱包
+func (s *PaymentService) GetWallet(ctx context.Context, userID string) (*model.Wallet, error) {
+	if s.walletRepo == nil {
+		return nil, fmt.Errorf("余额支付未启用")
+	}
+	wallet, err := s.walletRepo.GetWalletByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("查询钱包失败: %w", err)
+	}
+	if wallet == nil {
+		wallet = &model.Wallet{UserID: userID}
+	}
+	return wallet, nil
+}
+
+// SelfTopUpAllowed 是否允许用户自助充值（仅显式配置 payment.environment: sandbox 时，
+// 未配置环境按生产处理；生产环境充值需经第三方支付或由管理员操作）
+func (s *PaymentService) SelfTopUpAllowed() bool {
+	return s.gateways != nil && s.gateways.ExplicitSandbox()
+}
+
+// TopUpWallet 钱包充值：借记充值清算账户，贷记用户钱包，按充值单号幂等
+func (s *PaymentService) TopUpWallet(ctx context.Context, req *TopUpWalletRequest) (*model.Wallet, error) {
+	if s.walletRepo == nil {
+		return nil, fmt.Errorf("余额支付未启用")
+	}
+	amount, err := strconv.ParseFloat(req.Amount, 64)
+	if err != nil {
+		return nil, fmt.Errorf("充值金额格式错误: %w", err)
+	}
+	cents := toCents(amount)
+	if cents <= 0 {
+		return nil, fmt.Errorf("充值金额必须大于0")
+	}
+	amount = fromCents(cents)
+
+	remark := req.Remark
+	if remark == "" {
+		remark = fmt.Sprintf("钱包充值，操作人=%s", req.OperatorID)
+	}
+	err = s.walletRepo.WithTransaction(ctx, func(txCtx context.Context, txRepo repository.WalletRepository, _ repository.PaymentRepository, _ repository.PaymentOutboxRepository) error {
+		balance, err := txRepo.Credit(txCtx, req.UserID, amount)
+		if err != nil {
+			return fmt.Errorf("增加余额失败: %w", err)
+		}
+		entries := newLedgerPair(model.WalletBizTopUp, req.TopUpNo, req.UserID,
+			model.WalletAccountTopUp, model.WalletUserAccount(req.UserID), amount, balance, remark)
+		return createLedgerEntries(txCtx, txRepo, entries)
+	})
+	if errors.Is(err, errLedgerEntryExists) {
+		// 重复充值请求：确认是同一笔充值后直接返回当前余额
+		if err := s.checkLedgerEntries(ctx, model.WalletBizTopUp, req.TopUpNo, req.UserID, amount); err != nil {
+			return nil, fmt.Errorf("充值单号已被使用: %w", err)
+		}
+		log.Printf("ℹ️ 充值单已入账，忽略重复请求: top_up_no=%s, user_id=%s", req.TopUpNo, req.UserID)
+	} else if err != nil {
+		log.Printf("⚠️ 钱包充值失败: top_up_no=%s, user_id=%s, err=%v\n", req.TopUpNo, req.UserID, err)
+		return nil, err
+	}
+
+	return s.GetWallet(ctx, req.UserID)
+}
+
+// ListWalletLedger 分页查询用户钱包流水
+func (s *PaymentService) ListWalletLedger(ctx context.Context, userID string, page, pageSize int) ([]*model.WalletLedger, int64, error) {
+	if s.walletRepo == nil {
+		return nil, 0, fmt.Errorf("余额支付未启用")
+	}
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+	return s.walletRepo.ListUserLedger(ctx, userID, (page-1)*pageSize, pageSize)
+}
+
+// checkBalanceSufficient 下单前检查余额是否足够（快速失败，最终以扣款时的条件更新为准）
+func (s *PaymentService) checkBalanceSufficient(ctx context.Context, userID string, amount float64) error {
+	wallet, err := s.GetWallet(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if toCents(wallet.Balance) < toCents(amount) {
+		return fmt.Errorf("%w: 可用余额=%.2f, 需支付=%.2f", repository.ErrInsufficientBalance, wallet.Balance, amount)
+	}
+	return nil
+}
+
+// payWithBalance 余额支付同步结算：在一个本地事务中扣减余额、记账、更新支付单并写入支付成功 Outbox 事件
+// 按支付单号幂等，重复调用不会重复扣款
+func (s *PaymentService) payWithBalance(ctx context.Context, payment *model.Payment) error {
+	if s.walletRepo == nil {
+		return fmt.Errorf("余额支付未启用")
+	}
+	if payment.Status == model.PaymentStatusSuccess {
+		return nil
+	}
+	if payment.Status != model.PaymentStatusPending {
+		return fmt.Errorf("支付单状态不允许支付: %d", payment.Status)
+	}
+
+	now := time.Now()
+	paid := *payment
+	paid.Status = model.PaymentStatusSuccess
+	paid.TradeNo = fmt.Sprintf("BALANCE_%s", payment.PaymentNo)
+	paid.PaidAt = &now
+
+	err := s.walletRepo.WithTransaction(ctx, func(txCtx context.Context, txRepo repository.WalletRepository, txPaymentRepo repository.PaymentRepository, txOutboxRepo repository.PaymentOutboxRepository) error {
+		balance, err := txRepo.Debit(txCtx, payment.UserID, payment.Amount)
+		if err != nil {
+			return err
+		}
+		entries := newLedgerPair(model.WalletBizPayment, payment.PaymentNo, payment.UserID,
+			model.WalletUserAccount(payment.UserID), model.WalletAccountSettlement, payment.Amount, balance,
+			fmt.Sprintf("订单%s余额支付", payment.OrderNo))
+		if err := createLedgerEntries(txCtx, txRepo, entries); err != nil {
+			return err
+		}
+
+		if err := txPaymentRepo.UpdatePayment(txCtx, &paid); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("支付单状态已变更，请刷新后重试")
+			}
+			return fmt.Errorf("更新支付单状态失败: %w", err)
+		}
+
+		event, err := buildPaymentSucceededEvent(&paid)
+		if err != nil {
+			return err
+		}
+		if err := txOutboxRepo.Create(txCtx, event); err != nil {
+			return fmt.Errorf("写入支付 Outbox 事件失败: %w", err)
+		}
+		return nil
+	})
+	if errors.Is(err, errLedgerEntryExists) {
+		// 该支付单已扣款（并发或重复请求），以数据库中的支付单为准
+		log.Printf("ℹ️ 支付单已完成余额扣款，忽略重复请求: payment_no=%s", payment.PaymentNo)
+		if latest, err := s.paymentRepo.GetPaymentByPaymentNo(ctx, payment.PaymentNo); err == nil && latest != nil {
+			*payment = *latest
+		}
+		return nil
+	}
+	if err != nil {
+		log.Printf("⚠️ 余额支付失败: payment_no=%s, err=%v\n", payment.PaymentNo, err)
+		return err
+	}
+
+	oldStatus := payment.Status
+	*payment = paid
+	paymentLog := &model.PaymentLog{
+		PaymentNo:  payment.PaymentNo,
+		OrderNo:    payment.OrderNo,
+		UserID:     payment.UserID,
+		Action:     model.PaymentLogActionStatusChange,
+		FromStatus: &oldStatus,
+		ToStatus:   &payment.Status,
+		Channel:    model.PayChannelBalance,
+		Amount:     payment.Amount,
+		TradeNo:    payment.TradeNo,
+	}
+	if err := s.paymentLogRepo.CreatePaymentLog(ctx, paymentLog); err != nil {
+		log.Printf("⚠️ 记录支付日志失败: %v\n", err)
+	}
+	return nil
+}
+
+// refundToBalance 退款到余额：借记待结算账户，贷记用户钱包，按退款单号幂等（失败后可安全重试）
+func (s *PaymentService) refundToBalance(ctx context.Context, refund *model.Refund) error {
+	if s.walletRepo == nil {
+		return fmt.Errorf("余额支付未启用")
+	}
+	err := s.walletRepo.WithTransaction(ctx, func(txCtx context.Context, txRepo repository.WalletRepository, _ repository.PaymentRepository, _ repository.PaymentOutboxRepository) error {
+		balance, err := txRepo.Credit(txCtx, refund.UserID, refund.RefundAmount)
+		if err != nil {
+			return fmt.Errorf("增加余额失败: %w", err)
+		}
+		entries := newLedgerPair(model.WalletBizRefund, refund.RefundNo, refund.UserID,
+			model.WalletAccountSettlement, model.WalletUserAccount(refund.UserID), refund.RefundAmount, balance,
+			fmt.Sprintf("订单%s退款到余额", refund.OrderNo))
+		return createLedgerEntries(txCtx, txRepo, entries)
+	})
+	if errors.Is(err, errLedgerEntryExists) {
+		log.Printf("ℹ️ 退款单已退回余额，忽略重复请求: refund_no=%s", refund.RefundNo)
+		return nil
+	}
+	return err
+}
+
+// newLedgerPair 生成一笔业务的借贷两行流水（金额相等），变动后余额记录在用户钱包账户行
+func newLedgerPair(bizType, bizNo, userID, debitAccount, creditAccount string, amount, balanceAfter float64, remark string) []*model.WalletLedger {
+	txNo := pkg.GenerateULID()
+	entries := []*model.WalletLedger{
+		{TxNo: txNo, BizType: bizType, BizNo: bizNo, Direction: model.WalletDirectionDebit, Account: debitAccount, UserID: userID, Amount: amount, Remark: remark},
+		{TxNo: txNo, BizType: bizType, BizNo: bizNo, Direction: model.WalletDirectionCredit, Account: creditAccount, UserID: userID, Amount: amount, Remark: remark},
+	}
+	for _, entry := range entries {
+		if entry.Account == model.WalletUserAccount(userID) {
+			entry.BalanceAfter = balanceAfter
+		}
+	}
+	return entries
+}
+
+// createLedgerEntries 写入流水，业务单号重复时返回 errLedgerEntryExists
+func createLedgerEntries(ctx context.Context, repo repository.WalletRepository, entries []*model.WalletLedger) error {
+	if err := repo.CreateLedgerEntries(ctx, entries...); err != nil {
+		if isDuplicateKeyError(err) {
+			return errLedgerEntryExists
+		}
+		return fmt.Errorf("写入钱包流水失败: %w", err)
+	}
+	return nil
+}
+
+// checkLedgerEntries 校验已存在的流水与本次请求一致（防止不同请求复用同一业务单号）
+func (s *PaymentService) checkLedgerEntries(ctx context.Context, bizType, bizNo, userID string, amount float64) error {
+	entries, err := s.walletRepo.GetLedgerEntries(ctx, bizType, bizNo)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.UserID != userID || toCents(entry.Amount) != toCents(amount) {
+			return fmt.Errorf("业务单号 %s 已用于用户 %s 金额 %.2f", bizNo, entry.UserID, entry.Amount)
+		}
+	}
+	return nil
+}
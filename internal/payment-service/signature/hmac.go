@@ -0,0 +1,72 @@
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"zjMall/internal/payment-service/model"
+)
+
+// HMACSHA256Verifier HMAC-SHA256 签名校验器，适用于微信类渠道
+// 待签名串为 CanonicalString(params) + "&key=" + APIKey，签名为大写十六进制
+type HMACSHA256Verifier struct {
+	key []byte
+}
+
+// NewHMACSHA256Verifier 创建 HMAC-SHA256 校验器
+func NewHMACSHA256Verifier(apiKey string) (*HMACSHA256Verifier, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("API 密钥为空")
+	}
+	return &HMACSHA256Verifier{key: []byte(apiKey)}, nil
+}
+
+// Verify 校验签名（使用常量时间比较，防止时序攻击）
+func (v *HMACSHA256Verifier) Verify(params map[string]string, sign string) error {
+	expected := hmacSHA256Sign(v.key, params)
+	if !hmac.Equal([]byte(expected), []byte(strings.ToUpper(sign))) {
+		return fmt.Errorf("%w: HMAC-SHA256 验签不通过", ErrInvalidSign)
+	}
+	return nil
+}
+
+// HMACSHA256Signer HMAC-SHA256 签名器
+type HMACSHA256Signer struct {
+	key []byte
+}
+
+// NewHMACSHA256Signer 创建 HMAC-SHA256 签名器
+func NewHMACSHA256Signer(apiKey string) (*HMACSHA256Signer, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("API 密钥为空")
+	}
+	return &HMACSHA256Signer{key: []byte(apiKey)}, nil
+}
+
+// Sign 对参数签名，返回大写十六进制签名
+func (s *HMACSHA256Signer) Sign(params map[string]string) (string, error) {
+	return hmacSHA256Sign(s.key, params), nil
+}
+
+func hmacSHA256Sign(key []byte, params map[string]string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(CanonicalString(params) + "&key=" + string(key)))
+	return strings.ToUpper(hex.EncodeToString(mac.Sum(nil)))
+}
+
+func newHMACSHA256VerifierFromChannel(channel *model.PaymentChannel) (Verifier, error) {
+	if channel.APIKey == "" {
+		return nil, fmt.Errorf("支付渠道未配置 API 密钥: %s", channel.ChannelCode)
+	}
+	return NewHMACSHA256Verifier(channel.APIKey)
+}
+
+func newHMACSHA256SignerFromChannel(channel *model.PaymentChannel) (Signer, error) {
+	if channel.APIKey == "" {
+		return nil, fmt.Errorf("支付渠道未配置 API 密钥: %s", channel.ChannelCode)
+	}
+	return NewHMACSHA256Signer(channel.APIKey)
+}
@@ -0,0 +1,141 @@
+package signature
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"zjMall/internal/payment-service/model"
+)
+
+// RSA2Verifier RSA2（SHA256WithRSA）签名校验器，适用于支付宝类渠道
+type RSA2Verifier struct {
+	publicKey *rsa.PublicKey
+}
+
+// NewRSA2Verifier 创建 RSA2 校验器，publicKey 支持 PEM 或去掉头尾的 Base64 格式
+func NewRSA2Verifier(publicKey string) (*RSA2Verifier, error) {
+	der, err := decodeKey(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("解析 RSA 公钥失败: %w", err)
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		// 兼容 PKCS#1 格式公钥
+		rsaPub, pkcs1Err := x509.ParsePKCS1PublicKey(der)
+		if pkcs1Err != nil {
+			return nil, fmt.Errorf("解析 RSA 公钥失败: %w", err)
+		}
+		return &RSA2Verifier{publicKey: rsaPub}, nil
+	}
+	rsaPub, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("公钥不是 RSA 类型")
+	}
+	return &RSA2Verifier{publicKey: rsaPub}, nil
+}
+
+// Verify 校验签名（签名为 Base64 编码）
+func (v *RSA2Verifier) Verify(params map[string]string, sign string) error {
+	sig, err := base64.StdEncoding.DecodeString(sign)
+	if err != nil {
+		return fmt.Errorf("%w: 签名不是合法的 Base64", ErrInvalidSign)
+	}
+	digest := sha256.Sum256([]byte(CanonicalString(params)))
+	if err := rsa.VerifyPKCS1v15(v.publicKey, crypto.SHA256, digest[:], sig); err != nil {
+		return fmt.Errorf("%w: RSA2 验签不通过", ErrInvalidSign)
+	}
+	return nil
+}
+
+// RSA2Signer RSA2（SHA256WithRSA）签名器
+type RSA2Signer struct {
+	privateKey *rsa.PrivateKey
+}
+
+// NewRSA2Signer 创建 RSA2 签名器，privateKey 支持 PKCS#1/PKCS#8 的 PEM 或 Base64 格式
+func NewRSA2Signer(privateKey string) (*RSA2Signer, error) {
+	der, err := decodeKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("解析 RSA 私钥失败: %w", err)
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return &RSA2Signer{privateKey: key}, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("解析 RSA 私钥失败: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("私钥不是 RSA 类型")
+	}
+	return &RSA2Signer{privateKey: rsaKey}, nil
+}
+
+// Sign 对参数签名，返回 Base64 编码的签名
+func (s *RSA2Signer) Sign(params map[string]string) (string, error) {
+	digest := sha256.Sum256([]byte(CanonicalString(params)))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.privateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("RSA2 签名失败: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// GenerateRSA2KeyPair 生成沙箱使用的 RSA 密钥对（PKCS#8 私钥 PEM、PKIX 公钥 PEM）
+func GenerateRSA2KeyPair() (privateKeyPEM, publicKeyPEM string, err error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return "", "", fmt.Errorf("生成 RSA 密钥失败: %w", err)
+	}
+	privDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", "", fmt.Errorf("编码 RSA 私钥失败: %w", err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return "", "", fmt.Errorf("编码 RSA 公钥失败: %w", err)
+	}
+	privateKeyPEM = string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}))
+	publicKeyPEM = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}))
+	return privateKeyPEM, publicKeyPEM, nil
+}
+
+// decodeKey 解析 PEM 或裸 Base64 格式的密钥，返回 DER 字节
+func decodeKey(key string) ([]byte, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return nil, fmt.Errorf("密钥为空")
+	}
+	if block, _ := pem.Decode([]byte(key)); block != nil {
+		return block.Bytes, nil
+	}
+	der, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("密钥既不是 PEM 也不是合法的 Base64")
+	}
+	return der, nil
+}
+
+func newRSA2VerifierFromChannel(channel *model.PaymentChannel) (Verifier, error) {
+	if channel.PublicKey == "" {
+		return nil, fmt.Errorf("支付渠道未配置公钥: %s", channel.ChannelCode)
+	}
+	return NewRSA2Verifier(channel.PublicKey)
+}
+
+func newRSA2SignerFromChannel(channel *model.PaymentChannel) (Signer, error) {
+	if channel.PrivateKey == "" {
+		return nil, fmt.Errorf("支付渠道未配置私钥: %s", channel.ChannelCode)
+	}
+	return NewRSA2Signer(channel.PrivateKey)
+}
@@ -0,0 +1,137 @@
+package signature
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"zjMall/internal/payment-service/model"
+)
+
+// ErrInvalidSign 签名校验不通过
+var ErrInvalidSign = errors.New("签名校验失败")
+
+// Verifier 回调签名校验器
+type Verifier interface {
+	// Verify 校验回调参数的签名，params 不包含 sign 字段
+	Verify(params map[string]string, sign string) error
+}
+
+// Signer 签名器（与 Verifier 配对，用于沙箱环境模拟第三方平台签名）
+type Signer interface {
+	// Sign 对参数进行签名，返回签名串
+	Sign(params map[string]string) (string, error)
+}
+
+// VerifierFactory 根据渠道配置创建校验器
+type VerifierFactory func(channel *model.PaymentChannel) (Verifier, error)
+
+// SignerFactory 根据渠道配置创建签名器
+type SignerFactory func(channel *model.PaymentChannel) (Signer, error)
+
+type provider struct {
+	verifier VerifierFactory
+	signer   SignerFactory
+}
+
+var (
+	mu        sync.RWMutex
+	providers = make(map[string]provider)
+)
+
+func init() {
+	// 支付宝类渠道：RSA2（SHA256WithRSA），使用渠道配置的平台公钥验签
+	Register(model.PayChannelAlipay, newRSA2VerifierFromChannel, newRSA2SignerFromChannel)
+	// 微信类渠道：HMAC-SHA256，使用渠道配置的 API 密钥
+	Register(model.PayChannelWeChat, newHMACSHA256VerifierFromChannel, newHMACSHA256SignerFromChannel)
+}
+
+// Register 注册渠道的签名校验器与配对的签名器（同一渠道重复注册时覆盖）
+func Register(channelCode string, verifier VerifierFactory, signer SignerFactory) {
+	mu.Lock()
+	defer mu.Unlock()
+	providers[channelCode] = provider{verifier: verifier, signer: signer}
+}
+
+// NewVerifier 根据渠道代码创建校验器，未注册的渠道不接受外部回调
+func NewVerifier(channel *model.PaymentChannel) (Verifier, error) {
+	if channel == nil {
+		return nil, fmt.Errorf("支付渠道配置为空")
+	}
+	mu.RLock()
+	p, ok := providers[channel.ChannelCode]
+	mu.RUnlock()
+	if !ok || p.verifier == nil {
+		return nil, fmt.Errorf("支付渠道不支持回调验签: %s", channel.ChannelCode)
+	}
+	return p.verifier(channel)
+}
+
+// NewSandboxSigner 创建与渠道校验器配对的沙箱签名器，仅允许沙箱环境使用
+// 沙箱环境下 RSA2 渠道的 PrivateKey 与 PublicKey 需为同一密钥对，用于在本地模拟平台签名
+func NewSandboxSigner(channel *model.PaymentChannel) (Signer, error) {
+	if channel == nil {
+		return nil, fmt.Errorf("支付渠道配置为空")
+	}
+	if channel.Environment != model.EnvironmentSandbox {
+		return nil, fmt.Errorf("仅沙箱环境允许本地签名: channel=%s, environment=%s", channel.ChannelCode, channel.Environment)
+	}
+	mu.RLock()
+	p, ok := providers[channel.ChannelCode]
+	mu.RUnlock()
+	if !ok || p.signer == nil {
+		return nil, fmt.Errorf("支付渠道不支持沙箱签名: %s", channel.ChannelCode)
+	}
+	return p.signer(channel)
+}
+
+// CanonicalString 生成待签名字符串：剔除 sign/sign_type 与空值参数，按参数名 ASCII 升序以 k=v&k=v 拼接
+func CanonicalString(params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for k, v := range params {
+		if k == "sign" || k == "sign_type" || v == "" {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for i, k := range keys {
+		if i > 0 {
+			sb.WriteByte('&')
+		}
+		sb.WriteString(k)
+		sb.WriteByte('=')
+		sb.WriteString(params[k])
+	}
+	return sb.String()
+}
+
+// PaymentCallbackParams 支付回调参与签名的参数（extra 与核心字段合并，核心字段优先）
+func PaymentCallbackParams(paymentNo, tradeNo, amount, status string, extra map[string]string) map[string]string {
+	params := make(map[string]string, len(extra)+4)
+	for k, v := range extra {
+		params[k] = v
+	}
+	params["payment_no"] = paymentNo
+	params["trade_no"] = tradeNo
+	params["amount"] = amount
+	params["status"] = status
+	return params
+}
+
+// RefundCallbackParams 退款回调参与签名的参数（extra 与核心字段合并，核心字段优先）
+func RefundCallbackParams(refundNo, refundTradeNo, amount, status string, extra map[string]string) map[string]string {
+	params := make(map[string]string, len(extra)+4)
+	for k, v := range extra {
+		params[k] = v
+	}
+	params["refund_no"] = refundNo
+	params["refund_trade_no"] = refundTradeNo
+	params["amount"] = amount
+	params["status"] = status
+	return params
+}
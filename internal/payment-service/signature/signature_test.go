@@ -0,0 +1,138 @@
+package signature
+
+import (
+	"errors"
+	"testing"
+
+	"zjMall/internal/payment-service/model"
+)
+
+// sandboxChannels 生成一对使用不同密钥的沙箱渠道配置（用于验证错误密钥）
+func sandboxChannels(t *testing.T, channelCode string) (channel, other *model.PaymentChannel) {
+	t.Helper()
+	switch channelCode {
+	case model.PayChannelAlipay:
+		priv, pub, err := GenerateRSA2KeyPair()
+		if err != nil {
+			t.Fatalf("生成密钥对失败: %v", err)
+		}
+		otherPriv, otherPub, err := GenerateRSA2KeyPair()
+		if err != nil {
+			t.Fatalf("生成密钥对失败: %v", err)
+		}
+		channel = &model.PaymentChannel{ChannelCode: channelCode, PrivateKey: priv, PublicKey: pub}
+		other = &model.PaymentChannel{ChannelCode: channelCode, PrivateKey: otherPriv, PublicKey: otherPub}
+	case model.PayChannelWeChat:
+		channel = &model.PaymentChannel{ChannelCode: channelCode, APIKey: "sandbox-api-key"}
+		other = &model.PaymentChannel{ChannelCode: channelCode, APIKey: "other-api-key"}
+	default:
+		t.Fatalf("未知渠道: %s", channelCode)
+	}
+	channel.Environment = model.EnvironmentSandbox
+	other.Environment = model.EnvironmentSandbox
+	return channel, other
+}
+
+func TestSandboxSignerVerify(t *testing.T) {
+	params := PaymentCallbackParams("PAY202601010001", "TRADE0001", "99.90", "SUCCESS", map[string]string{"nonce": "abc"})
+
+	tests := []struct {
+		name    string
+		mutate  func(params map[string]string) map[string]string // 签名后对回调参数的篡改
+		signer  func(channel, other *model.PaymentChannel) *model.PaymentChannel
+		noSign  bool
+		wantErr bool
+	}{
+		{name: "round trip"},
+		{
+			name: "tampered body",
+			mutate: func(p map[string]string) map[string]string {
+				p["amount"] = "0.01"
+				return p
+			},
+			wantErr: true,
+		},
+		{
+			name:    "wrong key",
+			signer:  func(_, other *model.PaymentChannel) *model.PaymentChannel { return other },
+			wantErr: true,
+		},
+		{name: "missing sign", noSign: true, wantErr: true},
+	}
+
+	for _, channelCode := range []string{model.PayChannelAlipay, model.PayChannelWeChat} {
+		channel, other := sandboxChannels(t, channelCode)
+		verifier, err := NewVerifier(channel)
+		if err != nil {
+			t.Fatalf("%s: 创建校验器失败: %v", channelCode, err)
+		}
+
+		for _, tt := range tests {
+			t.Run(channelCode+"/"+tt.name, func(t *testing.T) {
+				signChannel := channel
+				if tt.signer != nil {
+					signChannel = tt.signer(channel, other)
+				}
+				signer, err := NewSandboxSigner(signChannel)
+				if err != nil {
+					t.Fatalf("创建签名器失败: %v", err)
+				}
+
+				callback := copyParams(params)
+				sign, err := signer.Sign(callback)
+				if err != nil {
+					t.Fatalf("签名失败: %v", err)
+				}
+				if tt.mutate != nil {
+					callback = tt.mutate(callback)
+				}
+				if tt.noSign {
+					sign = ""
+				}
+
+				err = verifier.Verify(callback, sign)
+				if tt.wantErr {
+					if !errors.Is(err, ErrInvalidSign) {
+						t.Fatalf("期望 ErrInvalidSign，实际: %v", err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("验签失败: %v", err)
+				}
+			})
+		}
+	}
+}
+
+func TestNewSandboxSignerRejectsProduction(t *testing.T) {
+	channel := &model.PaymentChannel{
+		ChannelCode: model.PayChannelWeChat,
+		APIKey:      "api-key",
+		Environment: model.EnvironmentProduction,
+	}
+	if _, err := NewSandboxSigner(channel); err == nil {
+		t.Fatal("生产环境不应允许创建沙箱签名器")
+	}
+}
+
+func TestCanonicalString(t *testing.T) {
+	got := CanonicalString(map[string]string{
+		"b":         "2",
+		"a":         "1",
+		"empty":     "",
+		"sign":      "xxx",
+		"sign_type": "RSA2",
+	})
+	if want := "a=1&b=2"; got != want {
+		t.Fatalf("CanonicalString = %q, want %q", got, want)
+	}
+}
+
+func copyParams(params map[string]string) map[string]string {
+	out := make(map[string]string, len(params))
+	for k, v := range params {
+		out[k] = v
+	}
+	return out
+}
@@ -725,5 +725,125 @@ func (h *ProductServiceHandler) SearchProducts(ctx context.Context, req *product
 		req.PageSize = 100
 	}
 
+	if req.MinRating < 0 || req.MinRating > 5 {
+		return &productv1.SearchProductsResponse{
+			Code:    1,
+			Message: "评分筛选范围为 0~5",
+		}, nil
+	}
+
 	return h.productService.SearchProducts(ctx, req)
 }
+
+func (h *ProductServiceHandler) UpdateProductRating(ctx context.Context, req *productv1.UpdateProductRatingRequest) (*productv1.UpdateProductRatingResponse, error) {
+	// 权限检查：评分汇总只由订单服务推送，管理员可用于修复数据
+	if !middleware.CheckRole(ctx, "admin", middleware.RoleInternalService) {
+		return &productv1.UpdateProductRatingResponse{
+			Code:    403,
+			Message: "权限不足：需要管理员权限",
+		}, nil
+	}
+	if req.ProductId == "" {
+		return &productv1.UpdateProductRatingResponse{
+			Code:    1,
+			Message: "商品ID不能为空",
+		}, nil
+	}
+	// 各星级评价数固定为 5 项（1~5 星）
+	if req.RatingAvg < 0 || req.RatingAvg > 5 || req.ReviewCount < 0 || len(req.RatingCounts) != 5 {
+		return &productv1.UpdateProductRatingResponse{
+			Code:    1,
+			Message: "评分数据不合法",
+		}, nil
+	}
+	return h.productService.UpdateProductRating(ctx, req)
+}
+
+// ============================================
+// 运费模板接口
+// ============================================
+
+func (h *ProductServiceHandler) CreateFreightTemplate(ctx context.Context, req *productv1.CreateFreightTemplateRequest) (*productv1.CreateFreightTemplateResponse, error) {
+	// 权限检查：只有商家运营和管理员可以维护运费模板
+	if !middleware.CheckRole(ctx, "merchant", "admin") {
+		return &productv1.CreateFreightTemplateResponse{
+			Code:    403,
+			Message: "权限不足：需要商家运营或管理员权限",
+		}, nil
+	}
+
+	validator := service.NewCreateFreightTemplateRequestValidator(req)
+	if err := validator.Validate(); err != nil {
+		return &productv1.CreateFreightTemplateResponse{
+			Code:    1,
+			Message: err.Error(),
+		}, nil
+	}
+	return h.productService.CreateFreightTemplate(ctx, req)
+}
+
+func (h *ProductServiceHandler) GetFreightTemplate(ctx context.Context, req *productv1.GetFreightTemplateRequest) (*productv1.GetFreightTemplateResponse, error) {
+	if req.TemplateId == "" {
+		return &productv1.GetFreightTemplateResponse{
+			Code:    1,
+			Message: "运费模板ID不能为空",
+		}, nil
+	}
+	return h.productService.GetFreightTemplate(ctx, req)
+}
+
+func (h *ProductServiceHandler) UpdateFreightTemplate(ctx context.Context, req *productv1.UpdateFreightTemplateRequest) (*productv1.UpdateFreightTemplateResponse, error) {
+	if !middleware.CheckRole(ctx, "merchant", "admin") {
+		return &productv1.UpdateFreightTemplateResponse{
+			Code:    403,
+			Message: "权限不足：需要商家运营或管理员权限",
+		}, nil
+	}
+
+	validator := service.NewUpdateFreightTemplateRequestValidator(req)
+	if err := validator.Validate(); err != nil {
+		return &productv1.UpdateFreightTemplateResponse{
+			Code:    1,
+			Message: err.Error(),
+		}, nil
+	}
+	return h.productService.UpdateFreightTemplate(ctx, req)
+}
+
+func (h *ProductServiceHandler) DeleteFreightTemplate(ctx context.Context, req *productv1.DeleteFreightTemplateRequest) (*productv1.DeleteFreightTemplateResponse, error) {
+	if !middleware.CheckRole(ctx, "merchant", "admin") {
+		return &productv1.DeleteFreightTemplateResponse{
+			Code:    403,
+			Message: "权限不足：需要商家运营或管理员权限",
+		}, nil
+	}
+
+	if req.TemplateId == "" {
+		return &productv1.DeleteFreightTemplateResponse{
+			Code:    1,
+			Message: "运费模板ID不能为空",
+		}, nil
+	}
+	return h.productService.DeleteFreightTemplate(ctx, req)
+}
+
+func (h *ProductServiceHandler) ListFreightTemplates(ctx context.Context, req *productv1.ListFreightTemplatesRequest) (*productv1.ListFreightTemplatesResponse, error) {
+	validator := service.NewListFreightTemplatesRequestValidator(req)
+	if err := validator.Validate(); err != nil {
+		return &productv1.ListFreightTemplatesResponse{
+			Code:    1,
+			Message: err.Error(),
+		}, nil
+	}
+	return h.productService.ListFreightTemplates(ctx, req)
+}
+
+func (h *ProductServiceHandler) CalculateFreight(ctx context.Context, req *productv1.CalculateFreightRequest) (*productv1.CalculateFreightResponse, error) {
+	if len(req.Items) == 0 {
+		return &productv1.CalculateFreightResponse{
+			Code:    1,
+			Message: "商品列表不能为空",
+		}, nil
+	}
+	return h.productService.CalculateFreight(ctx, req)
+}
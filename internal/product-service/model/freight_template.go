@@ -0,0 +1,91 @@
+package model
+
+import (
+	"encoding/json"
+	"strings"
+
+	"zjMall/pkg"
+
+	"gorm.io/gorm"
+)
+
+const (
+	FreightChargeByPiece  = 1 // 按件计费
+	FreightChargeByWeight = 2 // 按重量计费（kg）
+	FreightChargeByVolume = 3 // 按体积计费（m³）
+
+	FreightTemplateStatusEnabled  = 1
+	FreightTemplateStatusDisabled = 2
+)
+
+// FreightTemplate 运费模板模型
+// 对应数据库表：freight_templates
+type FreightTemplate struct {
+	pkg.BaseModel
+
+	Name       string `gorm:"type:varchar(100);not null;comment:模板名称" json:"name"`
+	ChargeType int8   `gorm:"type:tinyint;not null;default:1;comment:计费方式：1-按件，2-按重量，3-按体积" json:"charge_type"`
+	IsDefault  bool   `gorm:"type:tinyint(1);not null;default:0;comment:是否默认模板" json:"is_default"`
+	Status     int8   `gorm:"type:tinyint;not null;default:1;comment:状态：1-启用，2-停用" json:"status"`
+
+	// 软删除
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+}
+
+// TableName 指定表名
+func (FreightTemplate) TableName() string {
+	return "freight_templates"
+}
+
+// FreightRule 运费计费规则模型（按地区配置首件/续件价格和包邮门槛）
+// 对应数据库表：freight_rules
+type FreightRule struct {
+	pkg.BaseModel
+
+	TemplateID    string  `gorm:"type:varchar(26);not null;index;comment:运费模板ID" json:"template_id"`
+	Regions       string  `gorm:"type:text;comment:适用地区（JSON数组，\"省\" 或 \"省/市\"，为空表示默认规则）" json:"regions,omitempty"`
+	FirstUnit     float64 `gorm:"type:decimal(10,2);not null;default:1;comment:首件/首重/首体积" json:"first_unit"`
+	FirstFee      float64 `gorm:"type:decimal(10,2);not null;default:0;comment:首费" json:"first_fee"`
+	ExtraUnit     float64 `gorm:"type:decimal(10,2);not null;default:1;comment:续件/续重/续体积" json:"extra_unit"`
+	ExtraFee      float64 `gorm:"type:decimal(10,2);not null;default:0;comment:续费" json:"extra_fee"`
+	FreeThreshold float64 `gorm:"type:decimal(10,2);not null;default:0;comment:包邮门槛（0表示不包邮）" json:"free_threshold"`
+}
+
+// TableName 指定表名
+func (FreightRule) TableName() string {
+	return "freight_rules"
+}
+
+// GetRegions 解析适用地区列表
+func (r *FreightRule) GetRegions() []string {
+	if r.Regions == "" {
+		return nil
+	}
+	var regions []string
+	if err := json.Unmarshal([]byte(r.Regions), &regions); err != nil {
+		return nil
+	}
+	return regions
+}
+
+// SetRegions 设置适用地区列表（去除空白项）
+func (r *FreightRule) SetRegions(regions []string) {
+	cleaned := make([]string, 0, len(regions))
+	for _, region := range regions {
+		region = strings.TrimSpace(region)
+		if region != "" {
+			cleaned = append(cleaned, region)
+		}
+	}
+	if len(cleaned) == 0 {
+		r.Regions = ""
+		return
+	}
+	data, _ := json.Marshal(cleaned)
+	r.Regions = string(data)
+}
+
+// IsDefaultRule 是否为默认规则（未配置地区，适用于所有未单独配置的地区）
+func (r *FreightRule) IsDefaultRule() bool {
+	return len(r.GetRegions()) == 0
+}
@@ -17,6 +17,12 @@ type Product struct {
 	CategoryID string `gorm:"type:varchar(26);not null;comment:所属类目ID" json:"category_id"`
 	BrandID    string `gorm:"type:varchar(26);comment:品牌ID" json:"brand_id,omitempty"`
 
+	// 所属商家（为空表示平台自营），下单时不同商家的商品拆分为不同的子订单
+	MerchantID string `gorm:"type:varchar(26);index;comment:所属商家ID" json:"merchant_id,omitempty"`
+
+	// 运费模板（为空时使用默认运费模板）
+	FreightTemplateID string `gorm:"type:varchar(26);comment:运费模板ID" json:"freight_template_id,omitempty"`
+
 	// 基本信息
 	Title       string `gorm:"type:varchar(200);not null;comment:商品标题" json:"title"`
 	Subtitle    string `gorm:"type:varchar(200);comment:商品副标题/卖点" json:"subtitle,omitempty"`
@@ -15,11 +15,17 @@ type ProductIndex struct {
 	AttributeValues []string    `json:"attribute_values"`        // 属性列表
 	Status          int8        `json:"status"`                  // 状态：3-已上架
 	OnShelfTime     *string     `json:"on_shelf_time,omitempty"` // 上架时间，可能为空
+	RatingAvg       float64     `json:"rating_avg"`              // 平均评分（只统计审核通过的评价）
+	ReviewCount     int64       `json:"review_count"`            // 评价总数
+	RatingCounts    []int64     `json:"rating_counts"`           // 各星级评价数，下标 0~4 依次为 1~5 星
+	SoldOut         bool        `json:"sold_out"`                // 是否售罄（全部上架 SKU 都已售罄）
 	CreatedAt       string      `json:"created_at"`
 	UpdatedAt       string      `json:"updated_at"`
 }
 
 type SKUIndex struct {
+	SKUID   string  `json:"sku_id"`
 	SKUName string  `json:"sku_name"` // 如：红色、XL
 	Price   float64 `json:"price"`    // 价格
+	SoldOut bool    `json:"sold_out"` // 是否售罄
 }
@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+// ProductRating 商品评分汇总模型（由订单服务按审核通过的评价计算后推送）
+// 对应数据库表：product_ratings
+type ProductRating struct {
+	ProductID   string  `gorm:"type:varchar(26);primaryKey;comment:商品ID" json:"product_id"`
+	RatingAvg   float64 `gorm:"type:decimal(3,1);not null;default:0;comment:平均评分" json:"rating_avg"`
+	ReviewCount int64   `gorm:"type:int;not null;default:0;comment:评价总数" json:"review_count"`
+	Star1Count  int64   `gorm:"column:star1_count;type:int;not null;default:0;comment:1星评价数" json:"star1_count"`
+	Star2Count  int64   `gorm:"column:star2_count;type:int;not null;default:0;comment:2星评价数" json:"star2_count"`
+	Star3Count  int64   `gorm:"column:star3_count;type:int;not null;default:0;comment:3星评价数" json:"star3_count"`
+	Star4Count  int64   `gorm:"column:star4_count;type:int;not null;default:0;comment:4星评价数" json:"star4_count"`
+	Star5Count  int64   `gorm:"column:star5_count;type:int;not null;default:0;comment:5星评价数" json:"star5_count"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (ProductRating) TableName() string {
+	return "product_ratings"
+}
+
+// RatingCounts 各星级评价数，下标 0~4 依次为 1~5 星
+func (r *ProductRating) RatingCounts() []int64 {
+	return []int64{r.Star1Count, r.Star2Count, r.Star3Count, r.Star4Count, r.Star5Count}
+}
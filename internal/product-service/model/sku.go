@@ -27,6 +27,8 @@ type Sku struct {
 
 	// 状态
 	Status int8 `gorm:"type:tinyint;not null;default:1;comment:状态：1-上架，2-下架，3-禁用" json:"status"`
+	// 是否售罄（由库存服务的 stock.out / stock.restocked 事件维护）
+	SoldOut bool `gorm:"not null;default:false;comment:是否售罄" json:"sold_out"`
 
 	// 软删除
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at"`
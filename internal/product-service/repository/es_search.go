@@ -18,6 +18,12 @@ const (
 	ProductIndexName = "products"
 )
 
+// 搜索排序方式（为空时按相关性排序）
+const (
+	SearchSortByRating      = "rating"       // 按平均评分倒序
+	SearchSortByReviewCount = "review_count" // 按评价数倒序
+)
+
 type SearchRepository interface {
 	// 索引操作
 	CreateIndex(ctx context.Context) error
@@ -37,6 +43,8 @@ type SearchFilters struct {
 	MinPrice   float64
 	MaxPrice   float64
 	Tags       []string
+	MinRating  float64 // 最低平均评分
+	SortBy     string  // 排序方式：SearchSortByRating / SearchSortByReviewCount，为空按相关性
 }
 
 type SearchResult struct {
@@ -131,11 +139,17 @@ func (r *searchRepository) CreateIndex(ctx context.Context) error {
       "skus": {
         "type": "nested",
         "properties": {
+          "sku_id": {
+            "type": "keyword"
+          },
           "sku_name": {
             "type": "keyword"
           },
           "price": {
             "type": "float"
+          },
+          "sold_out": {
+            "type": "boolean"
           }
         }
       },
@@ -161,6 +175,18 @@ func (r *searchRepository) CreateIndex(ctx context.Context) error {
       "status": {
         "type": "byte"
       },
+      "rating_avg": {
+        "type": "float"
+      },
+      "review_count": {
+        "type": "long"
+      },
+      "rating_counts": {
+        "type": "long"
+      },
+      "sold_out": {
+        "type": "boolean"
+      },
       "on_shelf_time": {
         "type": "date",
         "format": "strict_date_optional_time||epoch_millis"
@@ -547,6 +573,36 @@ func (r *searchRepository) SearchProducts(ctx context.Context, keyword string, p
 				},
 			})
 		}
+
+		// 评分过滤
+		if filters.MinRating > 0 {
+			must = append(must, map[string]interface{}{
+				"range": map[string]interface{}{
+					"rating_avg": map[string]interface{}{
+						"gte": filters.MinRating,
+					},
+				},
+			})
+		}
+	}
+
+	sort := []map[string]interface{}{ //排序
+		{"_score": map[string]interface{}{"order": "desc"}},        //按相关性分数排序
+		{"on_shelf_time": map[string]interface{}{"order": "desc"}}, //按上架时间排序
+	}
+	if filters != nil {
+		switch filters.SortBy {
+		case SearchSortByRating:
+			// 评分相同时评价数多的在前，未被评价的商品排在最后
+			sort = append([]map[string]interface{}{
+				{"rating_avg": map[string]interface{}{"order": "desc", "missing": "_last"}},
+				{"review_count": map[string]interface{}{"order": "desc", "missing": "_last"}},
+			}, sort...)
+		case SearchSortByReviewCount:
+			sort = append([]map[string]interface{}{
+				{"review_count": map[string]interface{}{"order": "desc", "missing": "_last"}},
+			}, sort...)
+		}
 	}
 
 	// 构建完整查询
@@ -558,10 +614,7 @@ func (r *searchRepository) SearchProducts(ctx context.Context, keyword string, p
 		},
 		"from": (page - 1) * pageSize,
 		"size": pageSize,
-		"sort": []map[string]interface{}{ //排序
-			{"_score": map[string]interface{}{"order": "desc"}},        //按相关性分数排序
-			{"on_shelf_time": map[string]interface{}{"order": "desc"}}, //按上架时间排序
-		},
+		"sort": sort,
 	}
 
 	body, err := json.Marshal(searchQuery)
@@ -663,9 +716,15 @@ func (r *searchRepository) SearchProducts(ctx context.Context, keyword string, p
 				for _, skuVal := range skusArray {
 					if skuMap, ok := skuVal.(map[string]interface{}); ok {
 						sku := &model.SKUIndex{}
+						if skuID, ok := skuMap["sku_id"].(string); ok {
+							sku.SKUID = skuID
+						}
 						if skuName, ok := skuMap["sku_name"].(string); ok {
 							sku.SKUName = skuName
 						}
+						if soldOut, ok := skuMap["sold_out"].(bool); ok {
+							sku.SoldOut = soldOut
+						}
 						if priceVal, ok := skuMap["price"]; ok {
 							switch v := priceVal.(type) {
 							case float64:
@@ -694,6 +753,26 @@ func (r *searchRepository) SearchProducts(ctx context.Context, keyword string, p
 			}
 		}
 
+		// 评分字段
+		if ratingAvg, ok := source["rating_avg"].(float64); ok {
+			product.RatingAvg = ratingAvg
+		}
+		if reviewCount, ok := source["review_count"].(float64); ok {
+			product.ReviewCount = int64(reviewCount)
+		}
+		if countsVal, ok := source["rating_counts"].([]interface{}); ok {
+			counts := make([]int64, 0, len(countsVal))
+			for _, count := range countsVal {
+				if v, ok := count.(float64); ok {
+					counts = append(counts, int64(v))
+				}
+			}
+			product.RatingCounts = counts
+		}
+		if soldOut, ok := source["sold_out"].(bool); ok {
+			product.SoldOut = soldOut
+		}
+
 		// 时间字段
 		if onShelfTime, ok := source["on_shelf_time"].(string); ok {
 			product.OnShelfTime = &onShelfTime
@@ -0,0 +1,250 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"zjMall/internal/product-service/model"
+
+	"gorm.io/gorm"
+)
+
+var ErrFreightTemplateInUse = errors.New("运费模板已被商品使用，无法删除")
+
+type FreightTemplateListFilter struct {
+	Status  int32
+	Keyword string
+	Offset  int
+	Limit   int
+}
+
+// FreightSku 运费计算所需的 SKU 信息（含所属商品的运费模板）
+type FreightSku struct {
+	SkuID             string  `gorm:"column:sku_id"`
+	ProductID         string  `gorm:"column:product_id"`
+	Price             float64 `gorm:"column:price"`
+	Weight            float64 `gorm:"column:weight"`
+	Volume            float64 `gorm:"column:volume"`
+	FreightTemplateID string  `gorm:"column:freight_template_id"`
+}
+
+type FreightTemplateRepository interface {
+	CreateTemplate(ctx context.Context, template *model.FreightTemplate, rules []*model.FreightRule) error
+	GetTemplateByID(ctx context.Context, id string) (*model.FreightTemplate, error)
+	GetTemplatesByIDs(ctx context.Context, ids []string) ([]*model.FreightTemplate, error)
+	GetDefaultTemplate(ctx context.Context) (*model.FreightTemplate, error)
+	GetRulesByTemplateIDs(ctx context.Context, templateIDs []string) (map[string][]*model.FreightRule, error)
+	UpdateTemplate(ctx context.Context, template *model.FreightTemplate, rules []*model.FreightRule) error
+	DeleteTemplate(ctx context.Context, id string) error
+	ListTemplates(ctx context.Context, filter *FreightTemplateListFilter) ([]*model.FreightTemplate, int64, error)
+	// GetFreightSkus 批量查询 SKU 的重量、体积、价格及所属商品的运费模板
+	GetFreightSkus(ctx context.Context, skuIDs []string) ([]*FreightSku, error)
+}
+
+type freightTemplateRepository struct {
+	db *gorm.DB
+}
+
+func NewFreightTemplateRepository(db *gorm.DB) FreightTemplateRepository {
+	return &freightTemplateRepository{
+		db: db,
+	}
+}
+
+// CreateTemplate 创建运费模板及其计费规则
+func (r *freightTemplateRepository) CreateTemplate(ctx context.Context, template *model.FreightTemplate, rules []*model.FreightRule) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 默认模板全局唯一，设置新的默认模板时取消其他模板的默认标记
+		if template.IsDefault {
+			if err := tx.Model(&model.FreightTemplate{}).
+				Where("is_default = ?", true).
+				Update("is_default", false).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(template).Error; err != nil {
+			return err
+		}
+		for _, rule := range rules {
+			rule.TemplateID = template.ID
+		}
+		if len(rules) > 0 {
+			if err := tx.Create(&rules).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *freightTemplateRepository) GetTemplateByID(ctx context.Context, id string) (*model.FreightTemplate, error) {
+	var template model.FreightTemplate
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&template).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &template, nil
+}
+
+func (r *freightTemplateRepository) GetTemplatesByIDs(ctx context.Context, ids []string) ([]*model.FreightTemplate, error) {
+	if len(ids) == 0 {
+		return []*model.FreightTemplate{}, nil
+	}
+	var templates []*model.FreightTemplate
+	if err := r.db.WithContext(ctx).Where("id IN ?", ids).Find(&templates).Error; err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// GetDefaultTemplate 获取启用中的默认运费模板，不存在时返回 nil
+func (r *freightTemplateRepository) GetDefaultTemplate(ctx context.Context) (*model.FreightTemplate, error) {
+	var template model.FreightTemplate
+	err := r.db.WithContext(ctx).
+		Where("is_default = ? AND status = ?", true, model.FreightTemplateStatusEnabled).
+		Order("updated_at DESC").
+		First(&template).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &template, nil
+}
+
+// GetRulesByTemplateIDs 批量查询计费规则，返回 template_id -> rules
+func (r *freightTemplateRepository) GetRulesByTemplateIDs(ctx context.Context, templateIDs []string) (map[string][]*model.FreightRule, error) {
+	result := make(map[string][]*model.FreightRule, len(templateIDs))
+	if len(templateIDs) == 0 {
+		return result, nil
+	}
+	var rules []*model.FreightRule
+	if err := r.db.WithContext(ctx).
+		Where("template_id IN ?", templateIDs).
+		Order("created_at ASC, id ASC").
+		Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	for _, rule := range rules {
+		result[rule.TemplateID] = append(result[rule.TemplateID], rule)
+	}
+	return result, nil
+}
+
+// UpdateTemplate 更新运费模板，并全量替换计费规则
+func (r *freightTemplateRepository) UpdateTemplate(ctx context.Context, template *model.FreightTemplate, rules []*model.FreightRule) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if template.IsDefault {
+			if err := tx.Model(&model.FreightTemplate{}).
+				Where("is_default = ? AND id <> ?", true, template.ID).
+				Update("is_default", false).Error; err != nil {
+				return err
+			}
+		}
+
+		result := tx.Model(&model.FreightTemplate{}).
+			Where("id = ?", template.ID).
+			Updates(map[string]interface{}{
+				"name":        template.Name,
+				"charge_type": template.ChargeType,
+				"is_default":  template.IsDefault,
+				"status":      template.Status,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		if err := tx.Where("template_id = ?", template.ID).Delete(&model.FreightRule{}).Error; err != nil {
+			return err
+		}
+		for _, rule := range rules {
+			rule.TemplateID = template.ID
+		}
+		if len(rules) > 0 {
+			if err := tx.Create(&rules).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteTemplate 删除运费模板（仍被商品引用时禁止删除）
+func (r *freightTemplateRepository) DeleteTemplate(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&model.Product{}).Where("freight_template_id = ?", id).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrFreightTemplateInUse
+		}
+
+		result := tx.Where("id = ?", id).Delete(&model.FreightTemplate{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Where("template_id = ?", id).Delete(&model.FreightRule{}).Error
+	})
+}
+
+func (r *freightTemplateRepository) ListTemplates(ctx context.Context, filter *FreightTemplateListFilter) ([]*model.FreightTemplate, int64, error) {
+	query := r.db.WithContext(ctx).Model(&model.FreightTemplate{})
+
+	if filter.Status > 0 {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Keyword != "" {
+		safeKeyword := strings.ReplaceAll(filter.Keyword, `\`, `\\`)
+		safeKeyword = strings.ReplaceAll(safeKeyword, "%", "\\%")
+		safeKeyword = strings.ReplaceAll(safeKeyword, "_", "\\_")
+		query = query.Where("name LIKE ?", "%"+safeKeyword+"%")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var templates []*model.FreightTemplate
+	err := query.
+		Order("is_default DESC, created_at DESC").
+		Offset(filter.Offset).
+		Limit(filter.Limit).
+		Find(&templates).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return templates, total, nil
+}
+
+// GetFreightSkus 批量查询运费计算所需的 SKU 信息（仅未删除的 SKU）
+func (r *freightTemplateRepository) GetFreightSkus(ctx context.Context, skuIDs []string) ([]*FreightSku, error) {
+	if len(skuIDs) == 0 {
+		return []*FreightSku{}, nil
+	}
+	var rows []*FreightSku
+	err := r.db.WithContext(ctx).
+		Table("skus").
+		Select("skus.id AS sku_id, skus.product_id, skus.price, "+
+			"COALESCE(skus.weight, 0) AS weight, COALESCE(skus.volume, 0) AS volume, "+
+			"COALESCE(products.freight_template_id, '') AS freight_template_id").
+		Joins("JOIN products ON products.id = skus.product_id").
+		Where("skus.id IN ? AND skus.deleted_at IS NULL", skuIDs).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"zjMall/internal/product-service/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ProductRatingRepository 商品评分汇总仓储接口
+type ProductRatingRepository interface {
+	// GetProductRating 查询商品评分汇总（没有评价时返回 nil, nil）
+	GetProductRating(ctx context.Context, productID string) (*model.ProductRating, error)
+	// SaveProductRating 写入商品评分汇总（已存在时整体覆盖）
+	SaveProductRating(ctx context.Context, rating *model.ProductRating) error
+}
+
+type productRatingRepository struct {
+	db *gorm.DB
+}
+
+func NewProductRatingRepository(db *gorm.DB) ProductRatingRepository {
+	return &productRatingRepository{
+		db: db,
+	}
+}
+
+func (r *productRatingRepository) GetProductRating(ctx context.Context, productID string) (*model.ProductRating, error) {
+	var rating model.ProductRating
+	if err := r.db.WithContext(ctx).Where("product_id = ?", productID).First(&rating).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &rating, nil
+}
+
+func (r *productRatingRepository) SaveProductRating(ctx context.Context, rating *model.ProductRating) error {
+	// 汇总由订单服务全量计算，重复推送直接覆盖即可
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "product_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{
+				"rating_avg",
+				"review_count",
+				"star1_count",
+				"star2_count",
+				"star3_count",
+				"star4_count",
+				"star5_count",
+				"updated_at",
+			}),
+		}).
+		Create(rating).Error
+}
@@ -44,6 +44,8 @@ type SkuRepository interface {
 	BatchSetSkuAttributes(ctx context.Context, skuID string, attributeValueIDs []string) error
 	// GetMinPriceByProductIDs 批量获取商品最低SKU价格，返回 product_id -> min_price
 	GetMinPriceByProductIDs(ctx context.Context, productIDs []string) (map[string]float64, error)
+	// SetSkuSoldOut 设置 SKU 售罄标记（由库存事件驱动）
+	SetSkuSoldOut(ctx context.Context, id string, soldOut bool) error
 }
 
 type skuRepository struct {
@@ -135,6 +137,13 @@ func (r *skuRepository) UpdateSku(ctx context.Context, sku *model.Sku) error {
 		Updates(sku).Error
 }
 
+func (r *skuRepository) SetSkuSoldOut(ctx context.Context, id string, soldOut bool) error {
+	return r.db.WithContext(ctx).
+		Model(&model.Sku{}).
+		Where("id = ?", id).
+		Update("sold_out", soldOut).Error
+}
+
 func (r *skuRepository) DeleteSku(ctx context.Context, id string) error {
 	// 直接软删除 SKU 记录（如有 SKU 属性关联，可在后续属性仓库中处理级联）
 	if err := r.db.WithContext(ctx).Where("id = ?", id).Delete(&model.Sku{}).Error; err != nil {
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"zjMall/internal/product-service/model"
+)
+
+// 地区名称常见后缀，匹配规则时忽略（"广东省" 与 "广东" 视为同一地区）
+var regionSuffixes = []string{"特别行政区", "壮族自治区", "回族自治区", "维吾尔自治区", "自治区", "省", "市"}
+
+// freightLine 运费计算的商品行（同一 SKU 已合并数量）
+type freightLine struct {
+	SkuID    string
+	Quantity int32
+	Price    float64
+	Weight   float64 // 单件重量（kg）
+	Volume   float64 // 单件体积（m³）
+}
+
+// freightQuote 单个运费模板下的运费计算结果
+type freightQuote struct {
+	Template     *model.FreightTemplate
+	Rule         *model.FreightRule
+	GoodsAmount  float64
+	Units        float64
+	Fee          float64
+	FreeShipping bool
+}
+
+// normalizeRegion 规范化地区名称（去除空白和常见后缀）
+func normalizeRegion(name string) string {
+	name = strings.TrimSpace(name)
+	for _, suffix := range regionSuffixes {
+		if trimmed := strings.TrimSuffix(name, suffix); trimmed != name && trimmed != "" {
+			return trimmed
+		}
+	}
+	return name
+}
+
+// matchFreightRule 按收货地址匹配计费规则
+// 优先级：城市规则（"省/市"）> 省份规则（"省"）> 默认规则（未配置地区）
+func matchFreightRule(rules []*model.FreightRule, province, city string) *model.FreightRule {
+	province = normalizeRegion(province)
+	city = normalizeRegion(city)
+
+	var provinceRule, defaultRule *model.FreightRule
+	for _, rule := range rules {
+		regions := rule.GetRegions()
+		if len(regions) == 0 {
+			if defaultRule == nil {
+				defaultRule = rule
+			}
+			continue
+		}
+		if province == "" {
+			continue
+		}
+		for _, region := range regions {
+			parts := strings.SplitN(region, "/", 2)
+			if normalizeRegion(parts[0]) != province {
+				continue
+			}
+			if len(parts) == 2 {
+				if city != "" && normalizeRegion(parts[1]) == city {
+					return rule
+				}
+				continue
+			}
+			if provinceRule == nil {
+				provinceRule = rule
+			}
+		}
+	}
+	if provinceRule != nil {
+		return provinceRule
+	}
+	return defaultRule
+}
+
+// freightUnits 按计费方式计算计费单位数（件数/总重量/总体积）
+func freightUnits(chargeType int8, lines []*freightLine) float64 {
+	var units float64
+	for _, line := range lines {
+		switch chargeType {
+		case model.FreightChargeByWeight:
+			units += line.Weight * float64(line.Quantity)
+		case model.FreightChargeByVolume:
+			units += line.Volume * float64(line.Quantity)
+		default:
+			units += float64(line.Quantity)
+		}
+	}
+	return units
+}
+
+// calcFreightFee 首件/续件计费：首费 + ceil((计费单位 - 首件) / 续件) * 续费
+func calcFreightFee(rule *model.FreightRule, units float64) float64 {
+	fee := rule.FirstFee
+	if units > rule.FirstUnit && rule.ExtraUnit > 0 {
+		// 减去极小值，避免浮点误差导致多计一个续件（如 0.2/0.1 = 2.0000000000000004）
+		extra := math.Ceil((units-rule.FirstUnit)/rule.ExtraUnit - 1e-9)
+		fee += extra * rule.ExtraFee
+	}
+	return roundFreight(fee)
+}
+
+// quoteTemplateFreight 计算单个运费模板下所有商品的运费
+func quoteTemplateFreight(template *model.FreightTemplate, rules []*model.FreightRule, lines []*freightLine, province, city string) (*freightQuote, error) {
+	rule := matchFreightRule(rules, province, city)
+	if rule == nil {
+		return nil, fmt.Errorf("运费模板 %s 未配置适用于该地区的计费规则", template.Name)
+	}
+
+	quote := &freightQuote{
+		Template: template,
+		Rule:     rule,
+		Units:    freightUnits(template.ChargeType, lines),
+	}
+	for _, line := range lines {
+		quote.GoodsAmount += line.Price * float64(line.Quantity)
+	}
+	quote.GoodsAmount = roundFreight(quote.GoodsAmount)
+
+	if rule.FreeThreshold > 0 && quote.GoodsAmount >= rule.FreeThreshold {
+		quote.FreeShipping = true
+		return quote, nil
+	}
+	quote.Fee = calcFreightFee(rule, quote.Units)
+	return quote, nil
+}
+
+// validateFreightRules 校验运费模板的计费方式和计费规则
+func validateFreightRules(chargeType int8, rules []*model.FreightRule) error {
+	if chargeType < model.FreightChargeByPiece || chargeType > model.FreightChargeByVolume {
+		return fmt.Errorf("计费方式不正确")
+	}
+	if len(rules) == 0 {
+		return fmt.Errorf("计费规则不能为空")
+	}
+
+	defaultCount := 0
+	for _, rule := range rules {
+		if rule.IsDefaultRule() {
+			defaultCount++
+		}
+		if rule.FirstUnit <= 0 || rule.ExtraUnit <= 0 {
+			return fmt.Errorf("首件和续件单位必须大于0")
+		}
+		if rule.FirstFee < 0 || rule.ExtraFee < 0 || rule.FreeThreshold < 0 {
+			return fmt.Errorf("运费金额和包邮门槛不能为负数")
+		}
+	}
+	if defaultCount != 1 {
+		return fmt.Errorf("计费规则必须包含且仅包含一条默认规则（不指定地区）")
+	}
+	return nil
+}
+
+// roundFreight 金额保留两位小数
+func roundFreight(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,423 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	productv1 "zjMall/gen/go/api/proto/product"
+	"zjMall/internal/product-service/model"
+	"zjMall/internal/product-service/repository"
+	"zjMall/pkg"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// 运费模板接口
+// ============================================
+
+// convertFreightTemplateToProto 将 model.FreightTemplate 转换为 productv1.FreightTemplateInfo
+func convertFreightTemplateToProto(template *model.FreightTemplate, rules []*model.FreightRule) *productv1.FreightTemplateInfo {
+	ruleList := make([]*productv1.FreightRuleInfo, 0, len(rules))
+	for _, rule := range rules {
+		ruleList = append(ruleList, &productv1.FreightRuleInfo{
+			Id:            rule.ID,
+			Regions:       rule.GetRegions(),
+			FirstUnit:     rule.FirstUnit,
+			FirstFee:      rule.FirstFee,
+			ExtraUnit:     rule.ExtraUnit,
+			ExtraFee:      rule.ExtraFee,
+			FreeThreshold: rule.FreeThreshold,
+		})
+	}
+	return &productv1.FreightTemplateInfo{
+		Id:         template.ID,
+		Name:       template.Name,
+		ChargeType: int32(template.ChargeType),
+		IsDefault:  template.IsDefault,
+		Status:     int32(template.Status),
+		Rules:      ruleList,
+		CreatedAt:  timestamppb.New(template.CreatedAt),
+		UpdatedAt:  timestamppb.New(template.UpdatedAt),
+	}
+}
+
+// buildFreightRules 将请求中的计费规则转换为模型
+func buildFreightRules(reqRules []*productv1.FreightRuleInfo) []*model.FreightRule {
+	rules := make([]*model.FreightRule, 0, len(reqRules))
+	for _, r := range reqRules {
+		rule := &model.FreightRule{
+			FirstUnit:     r.FirstUnit,
+			FirstFee:      r.FirstFee,
+			ExtraUnit:     r.ExtraUnit,
+			ExtraFee:      r.ExtraFee,
+			FreeThreshold: r.FreeThreshold,
+		}
+		rule.SetRegions(r.Regions)
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// CreateFreightTemplate 创建运费模板
+func (s *ProductService) CreateFreightTemplate(ctx context.Context, req *productv1.CreateFreightTemplateRequest) (*productv1.CreateFreightTemplateResponse, error) {
+	rules := buildFreightRules(req.Rules)
+	if err := validateFreightRules(int8(req.ChargeType), rules); err != nil {
+		return &productv1.CreateFreightTemplateResponse{
+			Code:    1,
+			Message: err.Error(),
+		}, nil
+	}
+
+	status := int8(model.FreightTemplateStatusEnabled)
+	if req.Status > 0 {
+		status = int8(req.Status)
+	}
+
+	template := &model.FreightTemplate{
+		Name:       req.Name,
+		ChargeType: int8(req.ChargeType),
+		IsDefault:  req.IsDefault,
+		Status:     status,
+	}
+	if err := s.freightRepo.CreateTemplate(ctx, template, rules); err != nil {
+		return &productv1.CreateFreightTemplateResponse{
+			Code:    1,
+			Message: fmt.Sprintf("创建运费模板失败: %v", err),
+		}, nil
+	}
+
+	return &productv1.CreateFreightTemplateResponse{
+		Code:    0,
+		Message: "创建成功",
+		Data:    template.ID,
+	}, nil
+}
+
+// GetFreightTemplate 查询运费模板详情
+func (s *ProductService) GetFreightTemplate(ctx context.Context, req *productv1.GetFreightTemplateRequest) (*productv1.GetFreightTemplateResponse, error) {
+	template, err := s.freightRepo.GetTemplateByID(ctx, req.TemplateId)
+	if err != nil {
+		return &productv1.GetFreightTemplateResponse{
+			Code:    1,
+			Message: fmt.Sprintf("查询运费模板失败: %v", err),
+		}, nil
+	}
+	if template == nil {
+		return &productv1.GetFreightTemplateResponse{
+			Code:    1,
+			Message: "运费模板不存在",
+		}, nil
+	}
+
+	rulesMap, err := s.freightRepo.GetRulesByTemplateIDs(ctx, []string{template.ID})
+	if err != nil {
+		return &productv1.GetFreightTemplateResponse{
+			Code:    1,
+			Message: fmt.Sprintf("查询计费规则失败: %v", err),
+		}, nil
+	}
+
+	return &productv1.GetFreightTemplateResponse{
+		Code:     0,
+		Message:  "查询成功",
+		Template: convertFreightTemplateToProto(template, rulesMap[template.ID]),
+	}, nil
+}
+
+// UpdateFreightTemplate 更新运费模板（计费规则全量替换）
+func (s *ProductService) UpdateFreightTemplate(ctx context.Context, req *productv1.UpdateFreightTemplateRequest) (*productv1.UpdateFreightTemplateResponse, error) {
+	existing, err := s.freightRepo.GetTemplateByID(ctx, req.TemplateId)
+	if err != nil {
+		return &productv1.UpdateFreightTemplateResponse{
+			Code:    1,
+			Message: fmt.Sprintf("查询运费模板失败: %v", err),
+		}, nil
+	}
+	if existing == nil {
+		return &productv1.UpdateFreightTemplateResponse{
+			Code:    1,
+			Message: "运费模板不存在",
+		}, nil
+	}
+
+	rules := buildFreightRules(req.Rules)
+	if err := validateFreightRules(int8(req.ChargeType), rules); err != nil {
+		return &productv1.UpdateFreightTemplateResponse{
+			Code:    1,
+			Message: err.Error(),
+		}, nil
+	}
+
+	status := existing.Status
+	if req.Status > 0 {
+		status = int8(req.Status)
+	}
+
+	template := &model.FreightTemplate{
+		BaseModel: pkg.BaseModel{
+			ID: req.TemplateId,
+		},
+		Name:       req.Name,
+		ChargeType: int8(req.ChargeType),
+		IsDefault:  req.IsDefault,
+		Status:     status,
+	}
+	if err := s.freightRepo.UpdateTemplate(ctx, template, rules); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &productv1.UpdateFreightTemplateResponse{
+				Code:    1,
+				Message: "运费模板不存在",
+			}, nil
+		}
+		return &productv1.UpdateFreightTemplateResponse{
+			Code:    1,
+			Message: fmt.Sprintf("更新运费模板失败: %v", err),
+		}, nil
+	}
+
+	return &productv1.UpdateFreightTemplateResponse{
+		Code:    0,
+		Message: "更新成功",
+		Data:    req.TemplateId,
+	}, nil
+}
+
+// DeleteFreightTemplate 删除运费模板
+func (s *ProductService) DeleteFreightTemplate(ctx context.Context, req *productv1.DeleteFreightTemplateRequest) (*productv1.DeleteFreightTemplateResponse, error) {
+	err := s.freightRepo.DeleteTemplate(ctx, req.TemplateId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &productv1.DeleteFreightTemplateResponse{
+				Code:    1,
+				Message: "运费模板不存在",
+			}, nil
+		}
+		if errors.Is(err, repository.ErrFreightTemplateInUse) {
+			return &productv1.DeleteFreightTemplateResponse{
+				Code:    1,
+				Message: err.Error(),
+			}, nil
+		}
+		return &productv1.DeleteFreightTemplateResponse{
+			Code:    1,
+			Message: fmt.Sprintf("删除运费模板失败: %v", err),
+		}, nil
+	}
+
+	return &productv1.DeleteFreightTemplateResponse{
+		Code:    0,
+		Message: "删除成功",
+		Data:    req.TemplateId,
+	}, nil
+}
+
+// ListFreightTemplates 查询运费模板列表
+func (s *ProductService) ListFreightTemplates(ctx context.Context, req *productv1.ListFreightTemplatesRequest) (*productv1.ListFreightTemplatesResponse, error) {
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 10
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	templates, total, err := s.freightRepo.ListTemplates(ctx, &repository.FreightTemplateListFilter{
+		Status:  req.Status,
+		Keyword: req.Keyword,
+		Offset:  int((page - 1) * pageSize),
+		Limit:   int(pageSize),
+	})
+	if err != nil {
+		return &productv1.ListFreightTemplatesResponse{
+			Code:    1,
+			Message: fmt.Sprintf("查询运费模板列表失败: %v", err),
+		}, nil
+	}
+
+	templateIDs := make([]string, 0, len(templates))
+	for _, template := range templates {
+		templateIDs = append(templateIDs, template.ID)
+	}
+	rulesMap, err := s.freightRepo.GetRulesByTemplateIDs(ctx, templateIDs)
+	if err != nil {
+		return &productv1.ListFreightTemplatesResponse{
+			Code:    1,
+			Message: fmt.Sprintf("查询计费规则失败: %v", err),
+		}, nil
+	}
+
+	templateList := make([]*productv1.FreightTemplateInfo, 0, len(templates))
+	for _, template := range templates {
+		templateList = append(templateList, convertFreightTemplateToProto(template, rulesMap[template.ID]))
+	}
+
+	return &productv1.ListFreightTemplatesResponse{
+		Code:      0,
+		Message:   "查询成功",
+		Total:     total,
+		Templates: templateList,
+	}, nil
+}
+
+// CalculateFreight 计算运费
+// 商品按运费模板分组，每组按收货地址匹配计费规则后单独计费，总运费为各组之和；
+// 商品未指定模板或模板已停用时使用默认模板，没有默认模板则该部分商品包邮。
+// 购物车结算预览和订单创建都调用此接口，保证预览运费与实际收取的运费一致。
+func (s *ProductService) CalculateFreight(ctx context.Context, req *productv1.CalculateFreightRequest) (*productv1.CalculateFreightResponse, error) {
+	// 合并相同 SKU 的数量，并保持请求中的顺序
+	quantities := make(map[string]int32, len(req.Items))
+	skuIDs := make([]string, 0, len(req.Items))
+	for _, item := range req.Items {
+		if item.SkuId == "" || item.Quantity <= 0 {
+			return &productv1.CalculateFreightResponse{
+				Code:    1,
+				Message: "商品信息不正确",
+			}, nil
+		}
+		if _, ok := quantities[item.SkuId]; !ok {
+			skuIDs = append(skuIDs, item.SkuId)
+		}
+		quantities[item.SkuId] += item.Quantity
+	}
+
+	skus, err := s.freightRepo.GetFreightSkus(ctx, skuIDs)
+	if err != nil {
+		return &productv1.CalculateFreightResponse{
+			Code:    1,
+			Message: fmt.Sprintf("查询SKU信息失败: %v", err),
+		}, nil
+	}
+	skuMap := make(map[string]*repository.FreightSku, len(skus))
+	templateIDs := make([]string, 0)
+	for _, sku := range skus {
+		skuMap[sku.SkuID] = sku
+		if sku.FreightTemplateID != "" {
+			templateIDs = append(templateIDs, sku.FreightTemplateID)
+		}
+	}
+
+	templates, err := s.freightRepo.GetTemplatesByIDs(ctx, templateIDs)
+	if err != nil {
+		return &productv1.CalculateFreightResponse{
+			Code:    1,
+			Message: fmt.Sprintf("查询运费模板失败: %v", err),
+		}, nil
+	}
+	templateMap := make(map[string]*model.FreightTemplate, len(templates))
+	for _, template := range templates {
+		if template.Status == model.FreightTemplateStatusEnabled {
+			templateMap[template.ID] = template
+		}
+	}
+
+	// 按运费模板分组（key 为空表示无可用模板，按包邮处理）
+	var defaultTemplate *model.FreightTemplate
+	defaultLoaded := false
+	groupOrder := make([]string, 0)
+	groups := make(map[string][]*freightLine)
+	for _, skuID := range skuIDs {
+		sku, ok := skuMap[skuID]
+		if !ok {
+			return &productv1.CalculateFreightResponse{
+				Code:    1,
+				Message: fmt.Sprintf("SKU %s 不存在", skuID),
+			}, nil
+		}
+
+		template := templateMap[sku.FreightTemplateID]
+		if template == nil {
+			if !defaultLoaded {
+				defaultTemplate, err = s.freightRepo.GetDefaultTemplate(ctx)
+				if err != nil {
+					return &productv1.CalculateFreightResponse{
+						Code:    1,
+						Message: fmt.Sprintf("查询默认运费模板失败: %v", err),
+					}, nil
+				}
+				if defaultTemplate != nil {
+					templateMap[defaultTemplate.ID] = defaultTemplate
+				}
+				defaultLoaded = true
+			}
+			template = defaultTemplate
+		}
+
+		key := ""
+		if template != nil {
+			key = template.ID
+		}
+		if _, ok := groups[key]; !ok {
+			groupOrder = append(groupOrder, key)
+		}
+		groups[key] = append(groups[key], &freightLine{
+			SkuID:    skuID,
+			Quantity: quantities[skuID],
+			Price:    sku.Price,
+			Weight:   sku.Weight,
+			Volume:   sku.Volume,
+		})
+	}
+
+	rulesMap, err := s.freightRepo.GetRulesByTemplateIDs(ctx, groupOrder)
+	if err != nil {
+		return &productv1.CalculateFreightResponse{
+			Code:    1,
+			Message: fmt.Sprintf("查询计费规则失败: %v", err),
+		}, nil
+	}
+
+	var shippingFee float64
+	details := make([]*productv1.FreightDetail, 0, len(groupOrder))
+	for _, key := range groupOrder {
+		if key == "" {
+			// 没有可用运费模板的商品默认包邮
+			details = append(details, &productv1.FreightDetail{FreeShipping: true})
+			continue
+		}
+		quote, err := quoteTemplateFreight(templateMap[key], rulesMap[key], groups[key], req.Province, req.City)
+		if err != nil {
+			return &productv1.CalculateFreightResponse{
+				Code:    1,
+				Message: err.Error(),
+			}, nil
+		}
+		shippingFee += quote.Fee
+		details = append(details, &productv1.FreightDetail{
+			TemplateId:   quote.Template.ID,
+			TemplateName: quote.Template.Name,
+			RuleId:       quote.Rule.ID,
+			GoodsAmount:  quote.GoodsAmount,
+			Units:        quote.Units,
+			Fee:          quote.Fee,
+			FreeShipping: quote.FreeShipping,
+		})
+	}
+
+	return &productv1.CalculateFreightResponse{
+		Code:        0,
+		Message:     "计算成功",
+		ShippingFee: roundFreight(shippingFee),
+		Details:     details,
+	}, nil
+}
+
+// checkFreightTemplate 校验商品关联的运费模板是否存在
+func (s *ProductService) checkFreightTemplate(ctx context.Context, templateID string) error {
+	if templateID == "" {
+		return nil
+	}
+	template, err := s.freightRepo.GetTemplateByID(ctx, templateID)
+	if err != nil {
+		return fmt.Errorf("查询运费模板失败: %v", err)
+	}
+	if template == nil {
+		return fmt.Errorf("运费模板不存在")
+	}
+	return nil
+}
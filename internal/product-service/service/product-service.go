@@ -26,6 +26,7 @@ type ProductService struct {
 	skuRepo            repository.SkuRepository
 	attributeRepo      repository.AttributeRepository
 	attributeValueRepo repository.AttributeValueRepository
+	freightRepo        repository.FreightTemplateRepository
 	searchService      *SearchService
 }
 
@@ -38,6 +39,7 @@ func NewProductService(
 	skuRepo repository.SkuRepository,
 	attributeRepo repository.AttributeRepository,
 	attributeValueRepo repository.AttributeValueRepository,
+	freightRepo repository.FreightTemplateRepository,
 	searchService *SearchService,
 ) *ProductService {
 	return &ProductService{
@@ -48,6 +50,7 @@ func NewProductService(
 		skuRepo:            skuRepo,
 		attributeRepo:      attributeRepo,
 		attributeValueRepo: attributeValueRepo,
+		freightRepo:        freightRepo,
 		searchService:      searchService,
 	}
 }
@@ -625,6 +628,9 @@ func convertProductToProto(product *model.Product, minPrice float64) *productv1.
 		Status:      int32(product.Status),
 		CreatedAt:   timestamppb.New(product.CreatedAt),
 		UpdatedAt:   timestamppb.New(product.UpdatedAt),
+
+		FreightTemplateId: product.FreightTemplateID,
+		MerchantId:        product.MerchantID,
 	}
 
 	if product.OnShelfTime != nil {
@@ -668,6 +674,14 @@ func (s *ProductService) CreateProduct(ctx context.Context, req *productv1.Creat
 		onShelfTime = &t
 	}
 
+	// 校验运费模板
+	if err := s.checkFreightTemplate(ctx, req.FreightTemplateId); err != nil {
+		return &productv1.CreateProductResponse{
+			Code:    1,
+			Message: err.Error(),
+		}, nil
+	}
+
 	product := &model.Product{
 		CategoryID:  req.CategoryId,
 		BrandID:     req.BrandId,
@@ -678,6 +692,9 @@ func (s *ProductService) CreateProduct(ctx context.Context, req *productv1.Creat
 		Description: req.Description,
 		Status:      status,
 		OnShelfTime: onShelfTime, //TODO:定期上线
+
+		FreightTemplateID: req.FreightTemplateId,
+		MerchantID:        req.MerchantId,
 	}
 
 	err := s.productRepo.CreateProduct(ctx, product)
@@ -852,6 +869,18 @@ func (s *ProductService) UpdateProduct(ctx context.Context, req *productv1.Updat
 	if req.Status > 0 {
 		product.Status = int8(req.Status)
 	}
+	if req.MerchantId != "" {
+		product.MerchantID = req.MerchantId
+	}
+	if req.FreightTemplateId != "" {
+		if err := s.checkFreightTemplate(ctx, req.FreightTemplateId); err != nil {
+			return &productv1.UpdateProductResponse{
+				Code:    1,
+				Message: err.Error(),
+			}, nil
+		}
+		product.FreightTemplateID = req.FreightTemplateId
+	}
 	if len(req.Images) > 0 {
 		imagesBytes, err := json.Marshal(req.Images)
 		if err != nil {
@@ -1216,6 +1245,7 @@ func convertSkuToProto(sku *model.Sku) *productv1.SkuInfo {
 		Status:        int32(sku.Status),
 		CreatedAt:     timestamppb.New(sku.CreatedAt),
 		UpdatedAt:     timestamppb.New(sku.UpdatedAt),
+		SoldOut:       sku.SoldOut,
 	}
 }
 
@@ -2311,12 +2341,23 @@ func (s *ProductService) SearchProducts(ctx context.Context, req *productv1.Sear
 		req.PageSize = 100
 	}
 
+	switch req.SortBy {
+	case "", repository.SearchSortByRating, repository.SearchSortByReviewCount:
+	default:
+		return &productv1.SearchProductsResponse{
+			Code:    1,
+			Message: "不支持的排序方式",
+		}, nil
+	}
+
 	// 构建搜索过滤器
 	filters := &repository.SearchFilters{
 		CategoryID: req.CategoryId,
 		BrandID:    req.BrandId,
 		Tags:       req.Tags,
 		Status:     int8(repository.ProductStatusOnShelf), // 只搜索已上架商品（状态=4）
+		MinRating:  req.MinRating,
+		SortBy:     req.SortBy,
 	}
 
 	// 调用搜索服务
@@ -2347,7 +2388,11 @@ func (s *ProductService) SearchProducts(ctx context.Context, req *productv1.Sear
 			continue
 		}
 		minPrice := priceMap[product.ID]
-		productList = append(productList, convertProductToProto(product, minPrice))
+		productInfo := convertProductToProto(product, minPrice)
+		productInfo.RatingAvg = productIndex.RatingAvg
+		productInfo.ReviewCount = productIndex.ReviewCount
+		productInfo.SoldOut = productIndex.SoldOut
+		productList = append(productList, productInfo)
 	}
 
 	return &productv1.SearchProductsResponse{
@@ -2357,3 +2402,36 @@ func (s *ProductService) SearchProducts(ctx context.Context, req *productv1.Sear
 		Products: productList,
 	}, nil
 }
+
+// UpdateProductRating 更新商品评分汇总（由订单服务在评价审核后调用）
+func (s *ProductService) UpdateProductRating(ctx context.Context, req *productv1.UpdateProductRatingRequest) (*productv1.UpdateProductRatingResponse, error) {
+	product, err := s.productRepo.GetProduct(ctx, req.ProductId)
+	if err != nil || product == nil {
+		return &productv1.UpdateProductRatingResponse{
+			Code:    1,
+			Message: "商品不存在",
+		}, nil
+	}
+
+	rating := &model.ProductRating{
+		ProductID:   req.ProductId,
+		RatingAvg:   req.RatingAvg,
+		ReviewCount: req.ReviewCount,
+	}
+	counts := []*int64{&rating.Star1Count, &rating.Star2Count, &rating.Star3Count, &rating.Star4Count, &rating.Star5Count}
+	for i, count := range req.RatingCounts {
+		*counts[i] = count
+	}
+
+	if err := s.searchService.UpdateProductRating(ctx, rating); err != nil {
+		log.Printf("❌ [ProductService] UpdateProductRating: 更新商品评分失败: productID=%s, err=%v", req.ProductId, err)
+		return &productv1.UpdateProductRatingResponse{
+			Code:    1,
+			Message: "更新商品评分失败",
+		}, nil
+	}
+	return &productv1.UpdateProductRatingResponse{
+		Code:    0,
+		Message: "更新成功",
+	}, nil
+}
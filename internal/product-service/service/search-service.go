@@ -3,7 +3,9 @@ package service
 import (
 	"context"
 	"fmt"
+	"log"
 	"time"
+	"zjMall/internal/common/mq"
 	"zjMall/internal/product-service/model"
 	"zjMall/internal/product-service/repository"
 )
@@ -17,6 +19,7 @@ type SearchService struct {
 	attributeRepo      repository.AttributeRepository
 	attributeValueRepo repository.AttributeValueRepository
 	skuRepo            repository.SkuRepository
+	ratingRepo         repository.ProductRatingRepository
 }
 
 func NewSearchService(
@@ -28,6 +31,7 @@ func NewSearchService(
 	attributeRepo repository.AttributeRepository,
 	attributeValueRepo repository.AttributeValueRepository,
 	skuRepo repository.SkuRepository,
+	ratingRepo repository.ProductRatingRepository,
 ) *SearchService {
 	return &SearchService{
 		searchRepo:         searchRepo,
@@ -38,6 +42,7 @@ func NewSearchService(
 		attributeRepo:      attributeRepo,
 		attributeValueRepo: attributeValueRepo,
 		skuRepo:            skuRepo,
+		ratingRepo:         ratingRepo,
 	}
 }
 
@@ -46,6 +51,45 @@ func (s *SearchService) SearchProducts(ctx context.Context, keyword string, page
 	return s.searchRepo.SearchProducts(ctx, keyword, page, pageSize, filters)
 }
 
+// UpdateProductRating 保存商品评分汇总并同步到 ES（评价审核后由订单服务推送）
+func (s *SearchService) UpdateProductRating(ctx context.Context, rating *model.ProductRating) error {
+	if err := s.ratingRepo.SaveProductRating(ctx, rating); err != nil {
+		return fmt.Errorf("保存商品评分失败: %w", err)
+	}
+	return s.SyncProductToES(ctx, rating.ProductID)
+}
+
+// HandleStockEvent 处理库存服务发布的库存事件：售罄 / 补货时更新 SKU 售罄标记并重新同步商品到 ES
+func (s *SearchService) HandleStockEvent(ctx context.Context, event *mq.StockEvent) error {
+	var soldOut bool
+	switch event.EventType {
+	case mq.StockEventOut:
+		soldOut = true
+	case mq.StockEventRestocked:
+		soldOut = false
+	case mq.StockEventLow:
+		// 低库存不影响搜索展示
+		return nil
+	default:
+		log.Printf("⚠️ [SearchService] 未知的库存事件类型，忽略: event=%s, sku_id=%s", event.EventType, event.SKUID)
+		return nil
+	}
+
+	sku, err := s.skuRepo.GetSkuByID(ctx, event.SKUID)
+	if err != nil {
+		return fmt.Errorf("查询SKU失败: %w", err)
+	}
+	if sku == nil {
+		log.Printf("⚠️ [SearchService] SKU 不存在，忽略库存事件: event=%s, sku_id=%s", event.EventType, event.SKUID)
+		return nil
+	}
+
+	if err := s.skuRepo.SetSkuSoldOut(ctx, sku.ID, soldOut); err != nil {
+		return fmt.Errorf("更新SKU售罄标记失败: %w", err)
+	}
+	return s.SyncProductToES(ctx, sku.ProductID)
+}
+
 // SyncProductToES 同步商品到 ES（商品创建/更新时调用）
 func (s *SearchService) SyncProductToES(ctx context.Context, productID string) error {
 	// 1. 查询商品信息
@@ -96,12 +140,16 @@ func (s *SearchService) SyncProductToES(ctx context.Context, productID string) e
 	if err != nil {
 		return fmt.Errorf("查询SKU列表失败: %w", err)
 	}
+	soldOut := len(res) > 0
 	for _, sku := range res {
 		skus = append(skus, &model.SKUIndex{
+			SKUID:   sku.ID,
 			SKUName: sku.Name,
 			Price:   sku.Price,
+			SoldOut: sku.SoldOut,
 		})
 		skuIDs = append(skuIDs, sku.ID)
+		soldOut = soldOut && sku.SoldOut
 	}
 
 	//查询属性值列表
@@ -115,6 +163,15 @@ func (s *SearchService) SyncProductToES(ctx context.Context, productID string) e
 	for _, attributeValue := range attributeValues {
 		attributeValueIndex = append(attributeValueIndex, attributeValue.Value)
 	}
+	// 查询评分汇总（没有评价时评分为 0）
+	rating, err := s.ratingRepo.GetProductRating(ctx, productID)
+	if err != nil {
+		return fmt.Errorf("查询商品评分失败: %w", err)
+	}
+	if rating == nil {
+		rating = &model.ProductRating{ProductID: productID}
+	}
+
 	// 5. 构建索引文档
 	// 确保日期格式为 RFC3339 (ISO 8601)
 	createdAtStr := product.CreatedAt.Format(time.RFC3339)
@@ -133,6 +190,10 @@ func (s *SearchService) SyncProductToES(ctx context.Context, productID string) e
 		Status:          product.Status,
 		SKUs:            skus,
 		AttributeValues: attributeValueIndex,
+		RatingAvg:       rating.RatingAvg,
+		ReviewCount:     rating.ReviewCount,
+		RatingCounts:    rating.RatingCounts(),
+		SoldOut:         soldOut,
 		CreatedAt:       createdAtStr,
 		UpdatedAt:       updatedAtStr,
 	}
@@ -727,3 +727,76 @@ func (v *ListAttributeValuesRequestValidator) Validate() error {
 	}
 	return nil
 }
+
+// ==============运费模板验证器==============
+
+type CreateFreightTemplateRequestValidator struct {
+	Name       string `validate:"required,min=1,max=100" label:"模板名称"`
+	ChargeType int32  `validate:"required,oneof=1 2 3" label:"计费方式"`
+	Status     int32  `validate:"omitempty,oneof=1 2" label:"状态"`
+	RuleCount  int    `validate:"required,min=1" label:"计费规则"`
+}
+
+func NewCreateFreightTemplateRequestValidator(req *productv1.CreateFreightTemplateRequest) *CreateFreightTemplateRequestValidator {
+	return &CreateFreightTemplateRequestValidator{
+		Name:       req.Name,
+		ChargeType: req.ChargeType,
+		Status:     req.Status,
+		RuleCount:  len(req.Rules),
+	}
+}
+
+func (v *CreateFreightTemplateRequestValidator) Validate() error {
+	if err := validator.ValidateStruct(v); err != nil {
+		return errors.New(validator.FormatError(err))
+	}
+	return nil
+}
+
+type UpdateFreightTemplateRequestValidator struct {
+	TemplateID string `validate:"required" label:"模板ID"`
+	Name       string `validate:"required,min=1,max=100" label:"模板名称"`
+	ChargeType int32  `validate:"required,oneof=1 2 3" label:"计费方式"`
+	Status     int32  `validate:"omitempty,oneof=1 2" label:"状态"`
+	RuleCount  int    `validate:"required,min=1" label:"计费规则"`
+}
+
+func NewUpdateFreightTemplateRequestValidator(req *productv1.UpdateFreightTemplateRequest) *UpdateFreightTemplateRequestValidator {
+	return &UpdateFreightTemplateRequestValidator{
+		TemplateID: req.TemplateId,
+		Name:       req.Name,
+		ChargeType: req.ChargeType,
+		Status:     req.Status,
+		RuleCount:  len(req.Rules),
+	}
+}
+
+func (v *UpdateFreightTemplateRequestValidator) Validate() error {
+	if err := validator.ValidateStruct(v); err != nil {
+		return errors.New(validator.FormatError(err))
+	}
+	return nil
+}
+
+type ListFreightTemplatesRequestValidator struct {
+	Page     int32  `validate:"omitempty,min=1" label:"页码"`
+	PageSize int32  `validate:"omitempty,min=1,max=100" label:"每页数量"`
+	Status   int32  `validate:"omitempty,oneof=1 2" label:"状态"`
+	Keyword  string `validate:"omitempty,min=1,max=50" label:"关键词"`
+}
+
+func NewListFreightTemplatesRequestValidator(req *productv1.ListFreightTemplatesRequest) *ListFreightTemplatesRequestValidator {
+	return &ListFreightTemplatesRequestValidator{
+		Page:     req.Page,
+		PageSize: req.PageSize,
+		Status:   req.Status,
+		Keyword:  req.Keyword,
+	}
+}
+
+func (v *ListFreightTemplatesRequestValidator) Validate() error {
+	if err := validator.ValidateStruct(v); err != nil {
+		return errors.New(validator.FormatError(err))
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"context"
+
+	promotionv1 "zjMall/gen/go/api/proto/promotion"
+	"zjMall/internal/common/middleware"
+	"zjMall/internal/promotion-service/service"
+)
+
+// PromotionHandler 促销服务对外的 gRPC 入口
+type PromotionHandler struct {
+	promotionv1.UnimplementedPromotionServiceServer
+	svc *service.PromotionService
+}
+
+// NewPromotionHandler 创建促销 Handler
+func NewPromotionHandler(svc *service.PromotionService) *PromotionHandler {
+	return &PromotionHandler{svc: svc}
+}
+
+// CreatePromotion 创建促销活动
+func (h *PromotionHandler) CreatePromotion(ctx context.Context, req *promotionv1.CreatePromotionRequest) (*promotionv1.CreatePromotionResponse, error) {
+	return h.svc.CreatePromotion(ctx, req)
+}
+
+// UpdatePromotion 更新促销活动
+func (h *PromotionHandler) UpdatePromotion(ctx context.Context, req *promotionv1.UpdatePromotionRequest) (*promotionv1.UpdatePromotionResponse, error) {
+	if req.PromotionId == "" {
+		return &promotionv1.UpdatePromotionResponse{
+			Code:    1,
+			Message: "promotion_id 不能为空",
+		}, nil
+	}
+	return h.svc.UpdatePromotion(ctx, req)
+}
+
+// GetPromotion 获取促销活动详情
+func (h *PromotionHandler) GetPromotion(ctx context.Context, req *promotionv1.GetPromotionRequest) (*promotionv1.GetPromotionResponse, error) {
+	if req.PromotionId == "" {
+		return &promotionv1.GetPromotionResponse{
+			Code:    1,
+			Message: "promotion_id 不能为空",
+		}, nil
+	}
+	return h.svc.GetPromotion(ctx, req)
+}
+
+// ListPromotions 查询促销活动列表
+func (h *PromotionHandler) ListPromotions(ctx context.Context, req *promotionv1.ListPromotionsRequest) (*promotionv1.ListPromotionsResponse, error) {
+	return h.svc.ListPromotions(ctx, req)
+}
+
+// DeletePromotion 删除促销活动
+func (h *PromotionHandler) DeletePromotion(ctx context.Context, req *promotionv1.DeletePromotionRequest) (*promotionv1.DeletePromotionResponse, error) {
+	if req.PromotionId == "" {
+		return &promotionv1.DeletePromotionResponse{
+			Code:    1,
+			Message: "promotion_id 不能为空",
+		}, nil
+	}
+	return h.svc.DeletePromotion(ctx, req)
+}
+
+// GetAvailablePromotions 查询可用促销活动
+func (h *PromotionHandler) GetAvailablePromotions(ctx context.Context, req *promotionv1.GetAvailablePromotionsRequest) (*promotionv1.GetAvailablePromotionsResponse, error) {
+	return h.svc.GetAvailablePromotions(ctx, req)
+}
+
+// CalculateDiscount 计算优惠金额
+func (h *PromotionHandler) CalculateDiscount(ctx context.Context, req *promotionv1.CalculateDiscountRequest) (*promotionv1.CalculateDiscountResponse, error) {
+	if len(req.Items) == 0 && req.TotalAmount <= 0 {
+		return &promotionv1.CalculateDiscountResponse{
+			Code:    1,
+			Message: "商品列表和订单金额不能同时为空",
+		}, nil
+	}
+	return h.svc.CalculateDiscount(ctx, req)
+}
+
+// CreateCouponTemplate 创建优惠券模板
+func (h *PromotionHandler) CreateCouponTemplate(ctx context.Context, req *promotionv1.CreateCouponTemplateRequest) (*promotionv1.CreateCouponTemplateResponse, error) {
+	return h.svc.CreateCouponTemplate(ctx, req)
+}
+
+// ClaimCoupon 领取优惠券
+func (h *PromotionHandler) ClaimCoupon(ctx context.Context, req *promotionv1.ClaimCouponRequest) (*promotionv1.ClaimCouponResponse, error) {
+	if req.TemplateId == "" {
+		return &promotionv1.ClaimCouponResponse{
+			Code:    1,
+			Message: "template_id 不能为空",
+		}, nil
+	}
+	return h.svc.ClaimCoupon(ctx, req)
+}
+
+// ListUserCoupons 查询用户优惠券列表
+func (h *PromotionHandler) ListUserCoupons(ctx context.Context, req *promotionv1.ListUserCouponsRequest) (*promotionv1.ListUserCouponsResponse, error) {
+	return h.svc.ListUserCoupons(ctx, req)
+}
+
+// UseCoupon 核销优惠券（通常由订单服务调用）
+func (h *PromotionHandler) UseCoupon(ctx context.Context, req *promotionv1.UseCouponRequest) (*promotionv1.UseCouponResponse, error) {
+	if req.CouponId == "" || req.OrderId == "" {
+		return &promotionv1.UseCouponResponse{
+			Code:    1,
+			Message: "coupon_id 和 order_id 不能为空",
+		}, nil
+	}
+	return h.svc.UseCoupon(ctx, req)
+}
+
+// ApplyOrderDiscount 锁定订单优惠（仅限订单服务在下单时调用）
+func (h *PromotionHandler) ApplyOrderDiscount(ctx context.Context, req *promotionv1.ApplyOrderDiscountRequest) (*promotionv1.ApplyOrderDiscountResponse, error) {
+	if !middleware.CheckRole(ctx, middleware.RoleInternalService) {
+		return &promotionv1.ApplyOrderDiscountResponse{
+			Code:    403,
+			Message: "权限不足：仅限内部服务调用",
+		}, nil
+	}
+	if req.OrderId == "" {
+		return &promotionv1.ApplyOrderDiscountResponse{
+			Code:    1,
+			Message: "order_id 不能为空",
+		}, nil
+	}
+	return h.svc.ApplyOrderDiscount(ctx, req)
+}
+
+// ReleaseOrderDiscount 释放订单优惠（仅限订单服务在订单取消/超时关闭时调用）
+func (h *PromotionHandler) ReleaseOrderDiscount(ctx context.Context, req *promotionv1.ReleaseOrderDiscountRequest) (*promotionv1.ReleaseOrderDiscountResponse, error) {
+	if !middleware.CheckRole(ctx, middleware.RoleInternalService) {
+		return &promotionv1.ReleaseOrderDiscountResponse{
+			Code:    403,
+			Message: "权限不足：仅限内部服务调用",
+		}, nil
+	}
+	if req.OrderId == "" {
+		return &promotionv1.ReleaseOrderDiscountResponse{
+			Code:    1,
+			Message: "order_id 不能为空",
+		}, nil
+	}
+	return h.svc.ReleaseOrderDiscount(ctx, req)
+}
@@ -0,0 +1,119 @@
+package model
+
+import (
+	"time"
+
+	"zjMall/pkg"
+
+	"gorm.io/gorm"
+)
+
+// 优惠券类型（与 promotionv1.CouponType 保持一致）
+const (
+	CouponTypeFixed        int8 = 1 // 固定金额券
+	CouponTypePercent      int8 = 2 // 折扣券
+	CouponTypeFreeShipping int8 = 3 // 免运费券
+)
+
+// 优惠券实例状态（与 promotionv1.CouponStatus 保持一致）
+const (
+	CouponStatusUnused  int8 = 1 // 未使用
+	CouponStatusUsed    int8 = 2 // 已使用
+	CouponStatusExpired int8 = 3 // 已过期
+)
+
+// 优惠券模板状态
+const (
+	CouponTemplateStatusEnabled  int8 = 1 // 启用
+	CouponTemplateStatusDisabled int8 = 2 // 停用
+)
+
+// CouponTemplate 优惠券模板
+// 对应表：coupon_templates
+type CouponTemplate struct {
+	pkg.BaseModel
+
+	Name           string         `gorm:"type:varchar(100);not null;comment:优惠券名称" json:"name"`
+	Type           int8           `gorm:"type:tinyint;not null;comment:优惠券类型：1-固定金额，2-折扣，3-免运费" json:"type"`
+	Description    string         `gorm:"type:text;comment:优惠券描述" json:"description"`
+	DiscountValue  string         `gorm:"type:varchar(50);not null;comment:优惠值（固定金额或折扣）" json:"discount_value"`
+	ConditionValue string         `gorm:"type:varchar(50);comment:使用条件（如：满100可用）" json:"condition_value"`
+	TotalCount     int32          `gorm:"type:int;default:0;comment:发放总数（0表示不限制）" json:"total_count"`
+	ClaimedCount   int32          `gorm:"type:int;default:0;comment:已领取数量" json:"claimed_count"`
+	PerUserLimit   int32          `gorm:"type:int;default:1;comment:每人限领数量" json:"per_user_limit"`
+	ValidStartTime time.Time      `gorm:"type:timestamp;not null;comment:有效期开始时间" json:"valid_start_time"`
+	ValidEndTime   time.Time      `gorm:"type:timestamp;not null;comment:有效期结束时间" json:"valid_end_time"`
+	ValidDays      int32          `gorm:"type:int;default:0;comment:领取后有效天数（0表示使用模板有效期）" json:"valid_days"`
+	Status         int8           `gorm:"type:tinyint;default:1;comment:状态：1-启用，2-停用" json:"status"`
+	DeletedAt      gorm.DeletedAt `gorm:"index;comment:软删除时间" json:"-"`
+}
+
+func (CouponTemplate) TableName() string {
+	return "coupon_templates"
+}
+
+// Coupon 用户领取的优惠券实例
+// 对应表：coupons
+type Coupon struct {
+	pkg.BaseModel
+
+	TemplateID     string     `gorm:"type:varchar(26);not null;index;comment:优惠券模板ID" json:"template_id"`
+	UserID         string     `gorm:"type:varchar(26);not null;index:idx_user_status;comment:用户ID" json:"user_id"`
+	Name           string     `gorm:"type:varchar(100);not null;comment:优惠券名称" json:"name"`
+	Type           int8       `gorm:"type:tinyint;not null;comment:优惠券类型" json:"type"`
+	Description    string     `gorm:"type:text;comment:优惠券描述" json:"description"`
+	DiscountValue  string     `gorm:"type:varchar(50);not null;comment:优惠值" json:"discount_value"`
+	ConditionValue string     `gorm:"type:varchar(50);comment:使用条件" json:"condition_value"`
+	Status         int8       `gorm:"type:tinyint;default:1;index:idx_user_status;comment:状态：1-未使用，2-已使用，3-已过期" json:"status"`
+	ValidStartTime time.Time  `gorm:"type:timestamp;not null;comment:有效期开始时间" json:"valid_start_time"`
+	ValidEndTime   time.Time  `gorm:"type:timestamp;not null;comment:有效期结束时间" json:"valid_end_time"`
+	UsedAt         *time.Time `gorm:"type:timestamp;null;default:null;comment:使用时间" json:"used_at"`
+	OrderID        string     `gorm:"type:varchar(26);comment:使用的订单ID" json:"order_id"`
+}
+
+func (Coupon) TableName() string {
+	return "coupons"
+}
+
+// EffectiveStatus 返回考虑有效期后的实际状态（未使用但已过有效期视为已过期）
+func (c *Coupon) EffectiveStatus(now time.Time) int8 {
+	if c.Status == CouponStatusUnused && now.After(c.ValidEndTime) {
+		return CouponStatusExpired
+	}
+	return c.Status
+}
+
+// IsUsableAt 判断优惠券在指定时间是否可用
+func (c *Coupon) IsUsableAt(t time.Time) bool {
+	return c.Status == CouponStatusUnused && !t.Before(c.ValidStartTime) && !t.After(c.ValidEndTime)
+}
+
+// IsClaimableAt 判断模板在指定时间是否可领取（启用且处于模板有效期内）
+func (t *CouponTemplate) IsClaimableAt(now time.Time) bool {
+	return t.Status == CouponTemplateStatusEnabled && !now.Before(t.ValidStartTime) && !now.After(t.ValidEndTime)
+}
+
+// NewCouponFor 根据模板为用户生成一张优惠券实例
+// ValidDays > 0 时有效期为领取后 N 天（不超过模板有效期），否则直接使用模板有效期
+func (t *CouponTemplate) NewCouponFor(userID string, now time.Time) *Coupon {
+	start, end := t.ValidStartTime, t.ValidEndTime
+	if t.ValidDays > 0 {
+		start = now
+		end = now.AddDate(0, 0, int(t.ValidDays))
+		if end.After(t.ValidEndTime) {
+			end = t.ValidEndTime
+		}
+	}
+	return &Coupon{
+		TemplateID:     t.ID,
+		UserID:         userID,
+		Name:           t.Name,
+		Type:           t.Type,
+		Description:    t.Description,
+		DiscountValue:  t.DiscountValue,
+		ConditionValue: t.ConditionValue,
+		Status:         CouponStatusUnused,
+		ValidStartTime: start,
+		ValidEndTime:   end,
+	}
+}
@@ -0,0 +1,110 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"zjMall/pkg"
+
+	"gorm.io/gorm"
+)
+
+// 促销类型（与 promotionv1.PromotionType 保持一致）
+const (
+	PromotionTypeFullReduction   int8 = 1 // 满减：满X减Y
+	PromotionTypeFullDiscount    int8 = 2 // 满折：满X打Y折
+	PromotionTypeDirectReduction int8 = 3 // 直降：每件商品直接降价
+	PromotionTypeTimeLimited     int8 = 4 // 限时折扣
+)
+
+// 促销状态（与 promotionv1.PromotionStatus 保持一致）
+const (
+	PromotionStatusDraft   int8 = 1 // 草稿
+	PromotionStatusActive  int8 = 2 // 进行中
+	PromotionStatusPaused  int8 = 3 // 已暂停
+	PromotionStatusEnded   int8 = 4 // 已结束
+	PromotionStatusDeleted int8 = 5 // 已删除
+)
+
+// Promotion 促销活动
+// 对应表：promotions
+type Promotion struct {
+	pkg.BaseModel
+
+	Name           string         `gorm:"type:varchar(100);not null;comment:促销名称" json:"name"`
+	Type           int8           `gorm:"type:tinyint;not null;comment:促销类型：1-满减，2-满折，3-直降，4-限时折扣" json:"type"`
+	Description    string         `gorm:"type:text;comment:促销描述" json:"description"`
+	ProductIDs     string         `gorm:"column:product_ids;type:text;comment:适用商品ID列表（JSON数组，空表示全平台）" json:"product_ids"`
+	CategoryIDs    string         `gorm:"column:category_ids;type:text;comment:适用类目ID列表（JSON数组）" json:"category_ids"`
+	ConditionValue string         `gorm:"type:varchar(50);comment:条件值（如：满200）" json:"condition_value"`
+	DiscountValue  string         `gorm:"type:varchar(50);comment:优惠值（如：减30 或 打8折）" json:"discount_value"`
+	StartTime      time.Time      `gorm:"type:timestamp;not null;comment:开始时间" json:"start_time"`
+	EndTime        time.Time      `gorm:"type:timestamp;not null;comment:结束时间" json:"end_time"`
+	MaxUseTimes    int32          `gorm:"type:int;default:0;comment:每人限用次数（0表示不限制）" json:"max_use_times"`
+	TotalQuota     int32          `gorm:"type:int;default:0;comment:总配额（0表示不限制）" json:"total_quota"`
+	UsedQuota      int32          `gorm:"type:int;default:0;comment:已使用配额" json:"used_quota"`
+	SortOrder      int32          `gorm:"type:int;default:0;comment:排序权重" json:"sort_order"`
+	Status         int8           `gorm:"type:tinyint;default:1;comment:状态：1-草稿，2-进行中，3-已暂停，4-已结束，5-已删除" json:"status"`
+	DeletedAt      gorm.DeletedAt `gorm:"index;comment:软删除时间" json:"-"`
+}
+
+func (Promotion) TableName() string {
+	return "promotions"
+}
+
+// GetProductIDs 解析适用商品ID列表
+func (p *Promotion) GetProductIDs() []string {
+	return decodeIDList(p.ProductIDs)
+}
+
+// GetCategoryIDs 解析适用类目ID列表
+func (p *Promotion) GetCategoryIDs() []string {
+	return decodeIDList(p.CategoryIDs)
+}
+
+// SetProductIDs 以 JSON 数组形式保存适用商品ID列表
+func (p *Promotion) SetProductIDs(ids []string) {
+	p.ProductIDs = encodeIDList(ids)
+}
+
+// SetCategoryIDs 以 JSON 数组形式保存适用类目ID列表
+func (p *Promotion) SetCategoryIDs(ids []string) {
+	p.CategoryIDs = encodeIDList(ids)
+}
+
+// IsPlatformWide 未指定商品和类目时视为全平台通用
+func (p *Promotion) IsPlatformWide() bool {
+	return len(p.GetProductIDs()) == 0 && len(p.GetCategoryIDs()) == 0
+}
+
+// IsEffectiveAt 判断促销在指定时间是否生效（状态为进行中且处于时间窗口内）
+func (p *Promotion) IsEffectiveAt(t time.Time) bool {
+	return p.Status == PromotionStatusActive && !t.Before(p.StartTime) && t.Before(p.EndTime)
+}
+
+// QuotaExhausted 总配额是否已用完
+func (p *Promotion) QuotaExhausted() bool {
+	return p.TotalQuota > 0 && p.UsedQuota >= p.TotalQuota
+}
+
+func decodeIDList(raw string) []string {
+	if raw == "" {
+		return nil
+	}
+	var ids []string
+	if err := json.Unmarshal([]byte(raw), &ids); err != nil {
+		return nil
+	}
+	return ids
+}
+
+func encodeIDList(ids []string) string {
+	if len(ids) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(ids)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
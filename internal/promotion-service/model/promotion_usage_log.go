@@ -0,0 +1,32 @@
+package model
+
+import (
+	"time"
+
+	"zjMall/pkg"
+
+	"gorm.io/gorm"
+)
+
+// PromotionUsageLog 促销使用记录（用于每人限用次数判断）
+// 对应表：promotion_usage_logs
+type PromotionUsageLog struct {
+	ID             string    `gorm:"type:varchar(26);primaryKey;comment:主键ID" json:"id"`
+	PromotionID    string    `gorm:"type:varchar(26);not null;index:idx_promotion_user;comment:促销活动ID" json:"promotion_id"`
+	UserID         string    `gorm:"type:varchar(26);not null;index:idx_promotion_user;comment:用户ID" json:"user_id"`
+	OrderID        string    `gorm:"type:varchar(26);index;comment:订单ID" json:"order_id"`
+	DiscountAmount float64   `gorm:"type:decimal(10,2);comment:优惠金额" json:"discount_amount"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+func (PromotionUsageLog) TableName() string {
+	return "promotion_usage_logs"
+}
+
+// BeforeCreate GORM 钩子，在插入前自动生成主键 ID
+func (l *PromotionUsageLog) BeforeCreate(tx *gorm.DB) error {
+	if l.ID == "" {
+		l.ID = pkg.GenerateULID()
+	}
+	return nil
+}
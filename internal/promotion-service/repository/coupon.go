@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"zjMall/internal/promotion-service/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrCouponTemplateNotClaimable 模板不存在、已停用或不在领取时间内
+	ErrCouponTemplateNotClaimable = errors.New("优惠券不可领取")
+	// ErrCouponSoldOut 模板发放总数已领完
+	ErrCouponSoldOut = errors.New("优惠券已领完")
+	// ErrCouponClaimLimitExceeded 用户已达到每人限领数量
+	ErrCouponClaimLimitExceeded = errors.New("已达到每人限领数量")
+	// ErrCouponUnavailable 优惠券不存在、不属于该用户、已使用或不在有效期内
+	ErrCouponUnavailable = errors.New("优惠券不可用")
+)
+
+// CouponRepository 优惠券仓储接口（模板 + 用户券实例）
+type CouponRepository interface {
+	// CreateTemplate 创建优惠券模板
+	CreateTemplate(ctx context.Context, template *model.CouponTemplate) error
+	// GetTemplateByID 根据ID查询优惠券模板（不存在时返回 nil, nil）
+	GetTemplateByID(ctx context.Context, id string) (*model.CouponTemplate, error)
+	// ClaimCoupon 领取优惠券（锁定模板行，校验发放总数与每人限领数量后生成券实例）
+	ClaimCoupon(ctx context.Context, templateID, userID string, now time.Time) (*model.Coupon, error)
+	// GetCouponByID 根据ID查询优惠券（不存在时返回 nil, nil）
+	GetCouponByID(ctx context.Context, id string) (*model.Coupon, error)
+	// ListUserCoupons 分页查询用户优惠券，status 为 0 表示不过滤
+	ListUserCoupons(ctx context.Context, userID string, status int8, now time.Time, offset, limit int) ([]*model.Coupon, int64, error)
+	// UseCoupon 核销优惠券（条件更新，保证同一张券只会被使用一次）
+	UseCoupon(ctx context.Context, couponID, userID, orderID string, now time.Time) error
+}
+
+type couponRepository struct {
+	db *gorm.DB
+}
+
+// NewCouponRepository 创建优惠券仓储
+func NewCouponRepository(db *gorm.DB) CouponRepository {
+	return &couponRepository{db: db}
+}
+
+func (r *couponRepository) CreateTemplate(ctx context.Context, template *model.CouponTemplate) error {
+	return r.db.WithContext(ctx).Create(template).Error
+}
+
+func (r *couponRepository) GetTemplateByID(ctx context.Context, id string) (*model.CouponTemplate, error) {
+	var template model.CouponTemplate
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&template).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &template, nil
+}
+
+func (r *couponRepository) ClaimCoupon(ctx context.Context, templateID, userID string, now time.Time) (*model.Coupon, error) {
+	var coupon *model.Coupon
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 1. 锁定模板行，串行化同一模板的领取请求
+		var template model.CouponTemplate
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", templateID).
+			First(&template).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrCouponTemplateNotClaimable
+			}
+			return err
+		}
+		if !template.IsClaimableAt(now) {
+			return ErrCouponTemplateNotClaimable
+		}
+
+		// 2. 校验发放总数
+		if template.TotalCount > 0 && template.ClaimedCount >= template.TotalCount {
+			return ErrCouponSoldOut
+		}
+
+		// 3. 校验每人限领数量
+		if template.PerUserLimit > 0 {
+			var claimed int64
+			if err := tx.Model(&model.Coupon{}).
+				Where("template_id = ? AND user_id = ?", templateID, userID).
+				Count(&claimed).Error; err != nil {
+				return err
+			}
+			if claimed >= int64(template.PerUserLimit) {
+				return ErrCouponClaimLimitExceeded
+			}
+		}
+
+		// 4. 生成券实例并累加已领取数量
+		coupon = template.NewCouponFor(userID, now)
+		if err := tx.Create(coupon).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.CouponTemplate{}).
+			Where("id = ?", templateID).
+			Update("claimed_count", gorm.Expr("claimed_count + 1")).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return coupon, nil
+}
+
+func (r *couponRepository) GetCouponByID(ctx context.Context, id string) (*model.Coupon, error) {
+	var coupon model.Coupon
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&coupon).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+func (r *couponRepository) ListUserCoupons(ctx context.Context, userID string, status int8, now time.Time, offset, limit int) ([]*model.Coupon, int64, error) {
+	query := r.db.WithContext(ctx).Model(&model.Coupon{}).Where("user_id = ?", userID)
+	// 未使用但已过有效期的券按"已过期"处理
+	switch status {
+	case model.CouponStatusUnused:
+		query = query.Where("status = ? AND valid_end_time >= ?", model.CouponStatusUnused, now)
+	case model.CouponStatusExpired:
+		query = query.Where("status = ? OR (status = ? AND valid_end_time < ?)", model.CouponStatusExpired, model.CouponStatusUnused, now)
+	case model.CouponStatusUsed:
+		query = query.Where("status = ?", model.CouponStatusUsed)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var coupons []*model.Coupon
+	if err := query.Order("created_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&coupons).Error; err != nil {
+		return nil, 0, err
+	}
+	return coupons, total, nil
+}
+
+func (r *couponRepository) UseCoupon(ctx context.Context, couponID, userID, orderID string, now time.Time) error {
+	res := r.db.WithContext(ctx).
+		Model(&model.Coupon{}).
+		Where("id = ? AND user_id = ? AND status = ? AND valid_start_time <= ? AND valid_end_time >= ?",
+			couponID, userID, model.CouponStatusUnused, now, now).
+		Updates(map[string]interface{}{
+			"status":   model.CouponStatusUsed,
+			"used_at":  now,
+			"order_id": orderID,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrCouponUnavailable
+	}
+	return nil
+}
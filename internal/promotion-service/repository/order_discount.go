@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"zjMall/internal/promotion-service/model"
+
+	"gorm.io/gorm"
+)
+
+// OrderDiscountRepository 订单优惠仓储接口
+// 负责在一个本地事务中同时处理优惠券核销与促销配额占用，保证订单优惠要么全部生效，要么全部不生效
+type OrderDiscountRepository interface {
+	// ApplyOrderDiscount 锁定订单优惠（核销优惠券 + 记录促销使用），以 orderID 作为幂等键
+	ApplyOrderDiscount(ctx context.Context, orderID, userID, couponID string, usages []*model.PromotionUsageLog, now time.Time) error
+	// ReleaseOrderDiscount 释放订单优惠（退还优惠券 + 归还促销配额），重复调用无副作用
+	ReleaseOrderDiscount(ctx context.Context, orderID, userID string) error
+}
+
+type orderDiscountRepository struct {
+	db *gorm.DB
+}
+
+// NewOrderDiscountRepository 创建订单优惠仓储
+func NewOrderDiscountRepository(db *gorm.DB) OrderDiscountRepository {
+	return &orderDiscountRepository{db: db}
+}
+
+func (r *orderDiscountRepository) ApplyOrderDiscount(ctx context.Context, orderID, userID, couponID string, usages []*model.PromotionUsageLog, now time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 1. 核销优惠券（同一订单重复核销视为成功）
+		if couponID != "" {
+			res := tx.Model(&model.Coupon{}).
+				Where("id = ? AND user_id = ? AND status = ? AND valid_start_time <= ? AND valid_end_time >= ?",
+					couponID, userID, model.CouponStatusUnused, now, now).
+				Updates(map[string]interface{}{
+					"status":   model.CouponStatusUsed,
+					"used_at":  now,
+					"order_id": orderID,
+				})
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				var count int64
+				if err := tx.Model(&model.Coupon{}).
+					Where("id = ? AND user_id = ? AND status = ? AND order_id = ?", couponID, userID, model.CouponStatusUsed, orderID).
+					Count(&count).Error; err != nil {
+					return err
+				}
+				if count == 0 {
+					return ErrCouponUnavailable
+				}
+			}
+		}
+
+		// 2. 记录促销使用（该订单已记录过则跳过，保证幂等）
+		if len(usages) > 0 {
+			var recorded int64
+			if err := tx.Model(&model.PromotionUsageLog{}).
+				Where("order_id = ?", orderID).
+				Count(&recorded).Error; err != nil {
+				return err
+			}
+			if recorded > 0 {
+				return nil
+			}
+			for _, usage := range usages {
+				usage.OrderID = orderID
+				usage.UserID = userID
+				if err := recordUsageTx(tx, usage); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (r *orderDiscountRepository) ReleaseOrderDiscount(ctx context.Context, orderID, userID string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 1. 退还优惠券
+		if err := tx.Model(&model.Coupon{}).
+			Where("order_id = ? AND user_id = ? AND status = ?", orderID, userID, model.CouponStatusUsed).
+			Updates(map[string]interface{}{
+				"status":   model.CouponStatusUnused,
+				"used_at":  nil,
+				"order_id": "",
+			}).Error; err != nil {
+			return err
+		}
+
+		// 2. 归还促销配额并删除使用记录
+		var usages []*model.PromotionUsageLog
+		if err := tx.Where("order_id = ? AND user_id = ?", orderID, userID).Find(&usages).Error; err != nil {
+			return err
+		}
+		for _, usage := range usages {
+			if err := tx.Model(&model.Promotion{}).
+				Where("id = ? AND used_quota > 0", usage.PromotionID).
+				Update("used_quota", gorm.Expr("used_quota - 1")).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(usage).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"zjMall/internal/promotion-service/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrPromotionQuotaExhausted 促销总配额已用完
+	ErrPromotionQuotaExhausted = errors.New("促销活动配额已用完")
+	// ErrPromotionUsageLimitExceeded 用户已达到每人限用次数
+	ErrPromotionUsageLimitExceeded = errors.New("已达到该促销活动的每人限用次数")
+)
+
+// PromotionFilter 促销列表查询条件（零值表示不过滤）
+type PromotionFilter struct {
+	Type    int8
+	Status  int8
+	Keyword string
+}
+
+// PromotionRepository 促销活动仓储接口
+type PromotionRepository interface {
+	// CreatePromotion 创建促销活动
+	CreatePromotion(ctx context.Context, promotion *model.Promotion) error
+	// GetPromotionByID 根据ID查询促销活动（不存在时返回 nil, nil）
+	GetPromotionByID(ctx context.Context, id string) (*model.Promotion, error)
+	// GetPromotionsByIDs 批量查询促销活动
+	GetPromotionsByIDs(ctx context.Context, ids []string) ([]*model.Promotion, error)
+	// UpdatePromotion 更新促销活动
+	UpdatePromotion(ctx context.Context, promotion *model.Promotion) error
+	// DeletePromotion 删除促销活动（状态置为已删除并软删除）
+	DeletePromotion(ctx context.Context, id string) error
+	// ListPromotions 分页查询促销活动
+	ListPromotions(ctx context.Context, filter PromotionFilter, offset, limit int) ([]*model.Promotion, int64, error)
+	// ListEffectivePromotions 查询指定时间点生效中的促销活动（按排序权重倒序）
+	ListEffectivePromotions(ctx context.Context, now time.Time) ([]*model.Promotion, error)
+	// CountUserUsages 统计用户对多个促销活动的已使用次数
+	CountUserUsages(ctx context.Context, userID string, promotionIDs []string) (map[string]int64, error)
+	// RecordUsage 记录促销使用（校验总配额与每人限用次数，占用配额并写入使用记录，事务内完成）
+	RecordUsage(ctx context.Context, usage *model.PromotionUsageLog) error
+}
+
+type promotionRepository struct {
+	db *gorm.DB
+}
+
+// NewPromotionRepository 创建促销活动仓储
+func NewPromotionRepository(db *gorm.DB) PromotionRepository {
+	return &promotionRepository{db: db}
+}
+
+func (r *promotionRepository) CreatePromotion(ctx context.Context, promotion *model.Promotion) error {
+	return r.db.WithContext(ctx).Create(promotion).Error
+}
+
+func (r *promotionRepository) GetPromotionByID(ctx context.Context, id string) (*model.Promotion, error) {
+	var promotion model.Promotion
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&promotion).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &promotion, nil
+}
+
+func (r *promotionRepository) GetPromotionsByIDs(ctx context.Context, ids []string) ([]*model.Promotion, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var promotions []*model.Promotion
+	if err := r.db.WithContext(ctx).Where("id IN ?", ids).Find(&promotions).Error; err != nil {
+		return nil, fmt.Errorf("批量查询促销活动失败: %w", err)
+	}
+	return promotions, nil
+}
+
+func (r *promotionRepository) UpdatePromotion(ctx context.Context, promotion *model.Promotion) error {
+	res := r.db.WithContext(ctx).
+		Model(&model.Promotion{}).
+		Where("id = ?", promotion.ID).
+		Updates(map[string]interface{}{
+			"name":            promotion.Name,
+			"description":     promotion.Description,
+			"product_ids":     promotion.ProductIDs,
+			"category_ids":    promotion.CategoryIDs,
+			"condition_value": promotion.ConditionValue,
+			"discount_value":  promotion.DiscountValue,
+			"start_time":      promotion.StartTime,
+			"end_time":        promotion.EndTime,
+			"max_use_times":   promotion.MaxUseTimes,
+			"total_quota":     promotion.TotalQuota,
+			"sort_order":      promotion.SortOrder,
+			"status":          promotion.Status,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *promotionRepository) DeletePromotion(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&model.Promotion{}).
+			Where("id = ?", id).
+			Update("status", model.PromotionStatusDeleted)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Where("id = ?", id).Delete(&model.Promotion{}).Error
+	})
+}
+
+func (r *promotionRepository) ListPromotions(ctx context.Context, filter PromotionFilter, offset, limit int) ([]*model.Promotion, int64, error) {
+	query := r.db.WithContext(ctx).Model(&model.Promotion{})
+	if filter.Type > 0 {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.Status > 0 {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Keyword != "" {
+		query = query.Where("name LIKE ?", "%"+filter.Keyword+"%")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var promotions []*model.Promotion
+	if err := query.Order("sort_order DESC, created_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&promotions).Error; err != nil {
+		return nil, 0, err
+	}
+	return promotions, total, nil
+}
+
+func (r *promotionRepository) ListEffectivePromotions(ctx context.Context, now time.Time) ([]*model.Promotion, error) {
+	var promotions []*model.Promotion
+	if err := r.db.WithContext(ctx).
+		Where("status = ? AND start_time <= ? AND end_time > ?", model.PromotionStatusActive, now, now).
+		Where("total_quota = 0 OR used_quota < total_quota").
+		Order("sort_order DESC, created_at DESC").
+		Find(&promotions).Error; err != nil {
+		return nil, fmt.Errorf("查询生效中的促销活动失败: %w", err)
+	}
+	return promotions, nil
+}
+
+func (r *promotionRepository) CountUserUsages(ctx context.Context, userID string, promotionIDs []string) (map[string]int64, error) {
+	result := make(map[string]int64, len(promotionIDs))
+	if userID == "" || len(promotionIDs) == 0 {
+		return result, nil
+	}
+
+	var rows []struct {
+		PromotionID string
+		Cnt         int64
+	}
+	if err := r.db.WithContext(ctx).
+		Model(&model.PromotionUsageLog{}).
+		Select("promotion_id, COUNT(*) AS cnt").
+		Where("user_id = ? AND promotion_id IN ?", userID, promotionIDs).
+		Group("promotion_id").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("统计促销使用次数失败: %w", err)
+	}
+	for _, row := range rows {
+		result[row.PromotionID] = row.Cnt
+	}
+	return result, nil
+}
+
+func (r *promotionRepository) RecordUsage(ctx context.Context, usage *model.PromotionUsageLog) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return recordUsageTx(tx, usage)
+	})
+}
+
+// recordUsageTx 在事务内记录一次促销使用
+// 锁定促销行，保证配额和每人限用次数的判断与写入是原子的
+func recordUsageTx(tx *gorm.DB, usage *model.PromotionUsageLog) error {
+	var promotion model.Promotion
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", usage.PromotionID).
+		First(&promotion).Error; err != nil {
+		return err
+	}
+	if promotion.QuotaExhausted() {
+		return ErrPromotionQuotaExhausted
+	}
+	if promotion.MaxUseTimes > 0 {
+		var used int64
+		if err := tx.Model(&model.PromotionUsageLog{}).
+			Where("promotion_id = ? AND user_id = ?", usage.PromotionID, usage.UserID).
+			Count(&used).Error; err != nil {
+			return err
+		}
+		if used >= int64(promotion.MaxUseTimes) {
+			return ErrPromotionUsageLimitExceeded
+		}
+	}
+
+	if err := tx.Model(&model.Promotion{}).
+		Where("id = ?", usage.PromotionID).
+		Update("used_quota", gorm.Expr("used_quota + 1")).Error; err != nil {
+		return err
+	}
+	return tx.Create(usage).Error
+}
@@ -0,0 +1,238 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	promotionv1 "zjMall/gen/go/api/proto/promotion"
+	"zjMall/internal/common/middleware"
+	"zjMall/internal/promotion-service/model"
+	"zjMall/internal/promotion-service/repository"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// CreateCouponTemplate 创建优惠券模板
+func (s *PromotionService) CreateCouponTemplate(ctx context.Context, req *promotionv1.CreateCouponTemplateRequest) (*promotionv1.CreateCouponTemplateResponse, error) {
+	if err := NewCreateCouponTemplateRequestValidator(req).Validate(); err != nil {
+		return &promotionv1.CreateCouponTemplateResponse{
+			Code:    1,
+			Message: err.Error(),
+		}, nil
+	}
+	if err := validateCouponValues(int8(req.Type), req.ConditionValue, req.DiscountValue); err != nil {
+		return &promotionv1.CreateCouponTemplateResponse{
+			Code:    1,
+			Message: err.Error(),
+		}, nil
+	}
+	if !req.ValidEndTime.AsTime().After(req.ValidStartTime.AsTime()) {
+		return &promotionv1.CreateCouponTemplateResponse{
+			Code:    1,
+			Message: "有效期结束时间必须晚于开始时间",
+		}, nil
+	}
+
+	perUserLimit := req.PerUserLimit
+	if perUserLimit <= 0 {
+		perUserLimit = 1
+	}
+
+	template := &model.CouponTemplate{
+		Name:           req.Name,
+		Type:           int8(req.Type),
+		Description:    req.Description,
+		DiscountValue:  req.DiscountValue,
+		ConditionValue: req.ConditionValue,
+		TotalCount:     req.TotalCount,
+		PerUserLimit:   perUserLimit,
+		ValidStartTime: req.ValidStartTime.AsTime(),
+		ValidEndTime:   req.ValidEndTime.AsTime(),
+		ValidDays:      req.ValidDays,
+		Status:         model.CouponTemplateStatusEnabled,
+	}
+	if err := s.couponRepo.CreateTemplate(ctx, template); err != nil {
+		log.Printf("❌ [PromotionService] CreateCouponTemplate: 创建优惠券模板失败: %v", err)
+		return &promotionv1.CreateCouponTemplateResponse{
+			Code:    1,
+			Message: "创建优惠券模板失败",
+		}, nil
+	}
+
+	log.Printf("✅ [PromotionService] CreateCouponTemplate: 创建成功 template_id=%s", template.ID)
+	return &promotionv1.CreateCouponTemplateResponse{
+		Code:       0,
+		Message:    "创建成功",
+		TemplateId: template.ID,
+	}, nil
+}
+
+// ClaimCoupon 领取优惠券（校验领取时间、发放总数和每人限领数量）
+func (s *PromotionService) ClaimCoupon(ctx context.Context, req *promotionv1.ClaimCouponRequest) (*promotionv1.ClaimCouponResponse, error) {
+	userID := resolveUserID(ctx, req.UserId)
+	if userID == "" {
+		return &promotionv1.ClaimCouponResponse{
+			Code:    1,
+			Message: "用户未登录",
+		}, nil
+	}
+
+	coupon, err := s.couponRepo.ClaimCoupon(ctx, req.TemplateId, userID, time.Now())
+	if err != nil {
+		if errors.Is(err, repository.ErrCouponTemplateNotClaimable) ||
+			errors.Is(err, repository.ErrCouponSoldOut) ||
+			errors.Is(err, repository.ErrCouponClaimLimitExceeded) {
+			return &promotionv1.ClaimCouponResponse{
+				Code:    1,
+				Message: err.Error(),
+			}, nil
+		}
+		log.Printf("❌ [PromotionService] ClaimCoupon: 领取失败 template_id=%s, user_id=%s, err=%v", req.TemplateId, userID, err)
+		return &promotionv1.ClaimCouponResponse{
+			Code:    1,
+			Message: "领取优惠券失败",
+		}, nil
+	}
+
+	log.Printf("✅ [PromotionService] ClaimCoupon: 领取成功 template_id=%s, user_id=%s, coupon_id=%s", req.TemplateId, userID, coupon.ID)
+	return &promotionv1.ClaimCouponResponse{
+		Code:     0,
+		Message:  "领取成功",
+		CouponId: coupon.ID,
+	}, nil
+}
+
+// ListUserCoupons 查询用户优惠券列表
+func (s *PromotionService) ListUserCoupons(ctx context.Context, req *promotionv1.ListUserCouponsRequest) (*promotionv1.ListUserCouponsResponse, error) {
+	userID := resolveUserID(ctx, req.UserId)
+	if userID == "" {
+		return &promotionv1.ListUserCouponsResponse{
+			Code:    1,
+			Message: "用户未登录",
+		}, nil
+	}
+
+	offset, limit := normalizePage(req.Page, req.PageSize)
+	now := time.Now()
+	coupons, total, err := s.couponRepo.ListUserCoupons(ctx, userID, int8(req.Status), now, offset, limit)
+	if err != nil {
+		log.Printf("❌ [PromotionService] ListUserCoupons: 查询失败 user_id=%s, err=%v", userID, err)
+		return &promotionv1.ListUserCouponsResponse{
+			Code:    1,
+			Message: "查询失败",
+		}, nil
+	}
+
+	data := make([]*promotionv1.CouponInfo, 0, len(coupons))
+	for _, c := range coupons {
+		data = append(data, convertCouponToProto(c, now))
+	}
+
+	return &promotionv1.ListUserCouponsResponse{
+		Code:    0,
+		Message: "查询成功",
+		Data:    data,
+		Total:   total,
+	}, nil
+}
+
+// UseCoupon 核销优惠券
+func (s *PromotionService) UseCoupon(ctx context.Context, req *promotionv1.UseCouponRequest) (*promotionv1.UseCouponResponse, error) {
+	userID := resolveUserID(ctx, req.UserId)
+	if userID == "" {
+		return &promotionv1.UseCouponResponse{
+			Code:    1,
+			Message: "用户未登录",
+		}, nil
+	}
+
+	now := time.Now()
+	coupon, err := s.couponRepo.GetCouponByID(ctx, req.CouponId)
+	if err != nil {
+		log.Printf("❌ [PromotionService] UseCoupon: 查询优惠券失败 coupon_id=%s, err=%v", req.CouponId, err)
+		return &promotionv1.UseCouponResponse{
+			Code:    1,
+			Message: "查询优惠券失败",
+		}, nil
+	}
+	if coupon == nil || coupon.UserID != userID {
+		return &promotionv1.UseCouponResponse{
+			Code:    1,
+			Message: "优惠券不存在",
+		}, nil
+	}
+	// 幂等：同一订单重复核销直接返回成功
+	if coupon.Status == model.CouponStatusUsed && coupon.OrderID == req.OrderId {
+		return &promotionv1.UseCouponResponse{
+			Code:    0,
+			Message: "核销成功",
+		}, nil
+	}
+	if !coupon.IsUsableAt(now) {
+		return &promotionv1.UseCouponResponse{
+			Code:    1,
+			Message: "优惠券不可用",
+		}, nil
+	}
+	threshold, _ := parseAmount(coupon.ConditionValue)
+	if req.OrderAmount < threshold {
+		return &promotionv1.UseCouponResponse{
+			Code:    1,
+			Message: fmt.Sprintf("未达到优惠券使用条件（满%.2f可用）", threshold),
+		}, nil
+	}
+
+	if err := s.couponRepo.UseCoupon(ctx, req.CouponId, userID, req.OrderId, now); err != nil {
+		if errors.Is(err, repository.ErrCouponUnavailable) {
+			return &promotionv1.UseCouponResponse{
+				Code:    1,
+				Message: err.Error(),
+			}, nil
+		}
+		log.Printf("❌ [PromotionService] UseCoupon: 核销失败 coupon_id=%s, order_id=%s, err=%v", req.CouponId, req.OrderId, err)
+		return &promotionv1.UseCouponResponse{
+			Code:    1,
+			Message: "核销优惠券失败",
+		}, nil
+	}
+
+	log.Printf("✅ [PromotionService] UseCoupon: 核销成功 coupon_id=%s, order_id=%s", req.CouponId, req.OrderId)
+	return &promotionv1.UseCouponResponse{
+		Code:    0,
+		Message: "核销成功",
+	}, nil
+}
+
+// resolveUserID 优先使用认证中间件注入的用户ID，内部服务调用时回退到请求参数
+func resolveUserID(ctx context.Context, reqUserID string) string {
+	if userID := middleware.GetUserIDFromContext(ctx); userID != "" {
+		return userID
+	}
+	return reqUserID
+}
+
+// convertCouponToProto 将优惠券转换为 Proto（状态按有效期换算）
+func convertCouponToProto(c *model.Coupon, now time.Time) *promotionv1.CouponInfo {
+	info := &promotionv1.CouponInfo{
+		Id:             c.ID,
+		TemplateId:     c.TemplateID,
+		Name:           c.Name,
+		Type:           promotionv1.CouponType(c.Type),
+		Description:    c.Description,
+		DiscountValue:  c.DiscountValue,
+		ConditionValue: c.ConditionValue,
+		UserId:         c.UserID,
+		Status:         promotionv1.CouponStatus(c.EffectiveStatus(now)),
+		ValidStartTime: timestamppb.New(c.ValidStartTime),
+		ValidEndTime:   timestamppb.New(c.ValidEndTime),
+		OrderId:        c.OrderID,
+		CreatedAt:      timestamppb.New(c.CreatedAt),
+	}
+	if c.UsedAt != nil {
+		info.UsedAt = timestamppb.New(*c.UsedAt)
+	}
+	return info
+}
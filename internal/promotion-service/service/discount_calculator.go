@@ -0,0 +1,277 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"zjMall/internal/promotion-service/model"
+)
+
+// DiscountLine 参与优惠计算的商品行
+type DiscountLine struct {
+	ProductID string
+	SKUID     string
+	Quantity  int32
+	Price     float64
+}
+
+// Subtotal 行小计（单价 * 数量）
+func (l DiscountLine) Subtotal() float64 {
+	return l.Price * float64(l.Quantity)
+}
+
+// parseAmount 解析金额类配置（如条件值 "200"、优惠值 "30"），空字符串视为 0
+func parseAmount(value string) (float64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("非法金额: %s", value)
+	}
+	return v, nil
+}
+
+// parseDiscountRate 解析折扣配置，返回应付比例
+// 支持两种写法："8" / "8.5" 表示打 8 折 / 8.5 折，"0.85" 表示按 85% 计价
+func parseDiscountRate(value string) (float64, error) {
+	v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return 0, fmt.Errorf("非法折扣: %s", value)
+	}
+	switch {
+	case v > 0 && v < 1:
+		return v, nil
+	case v >= 1 && v < 10:
+		return v / 10, nil
+	default:
+		return 0, fmt.Errorf("非法折扣: %s", value)
+	}
+}
+
+// validatePromotionValues 校验促销条件值与优惠值是否符合促销类型
+func validatePromotionValues(promotionType int8, conditionValue, discountValue string) error {
+	if _, err := parseAmount(conditionValue); err != nil {
+		return fmt.Errorf("条件值%w", err)
+	}
+	switch promotionType {
+	case model.PromotionTypeFullReduction, model.PromotionTypeDirectReduction:
+		v, err := parseAmount(discountValue)
+		if err != nil || v <= 0 {
+			return fmt.Errorf("优惠值必须为大于 0 的金额")
+		}
+	case model.PromotionTypeFullDiscount, model.PromotionTypeTimeLimited:
+		if _, err := parseDiscountRate(discountValue); err != nil {
+			return fmt.Errorf("优惠值必须为合法折扣（如 8 或 0.8）")
+		}
+	default:
+		return fmt.Errorf("不支持的促销类型: %d", promotionType)
+	}
+	return nil
+}
+
+// validateCouponValues 校验优惠券使用条件与优惠值是否符合券类型
+func validateCouponValues(couponType int8, conditionValue, discountValue string) error {
+	if _, err := parseAmount(conditionValue); err != nil {
+		return fmt.Errorf("使用条件%w", err)
+	}
+	switch couponType {
+	case model.CouponTypeFixed:
+		v, err := parseAmount(discountValue)
+		if err != nil || v <= 0 {
+			return fmt.Errorf("优惠值必须为大于 0 的金额")
+		}
+	case model.CouponTypePercent:
+		if _, err := parseDiscountRate(discountValue); err != nil {
+			return fmt.Errorf("优惠值必须为合法折扣（如 8 或 0.8）")
+		}
+	case model.CouponTypeFreeShipping:
+		// 免运费券不依赖优惠值
+	default:
+		return fmt.Errorf("不支持的优惠券类型: %d", couponType)
+	}
+	return nil
+}
+
+// promotionAppliesTo 判断促销是否适用于某商品
+// 商品行不携带类目信息，因此仅限定类目的促销在商品行维度不生效
+func promotionAppliesTo(p *model.Promotion, productID string) bool {
+	if p.IsPlatformWide() {
+		return true
+	}
+	for _, id := range p.GetProductIDs() {
+		if id == productID {
+			return true
+		}
+	}
+	return false
+}
+
+// calcPromotionDiscount 计算单个促销活动在给定商品行上的优惠金额
+func calcPromotionDiscount(p *model.Promotion, lines []DiscountLine) float64 {
+	var subtotal, directReduction float64
+	reduction, _ := parseAmount(p.DiscountValue)
+	for _, line := range lines {
+		if !promotionAppliesTo(p, line.ProductID) {
+			continue
+		}
+		subtotal += line.Subtotal()
+		directReduction += math.Min(reduction, line.Price) * float64(line.Quantity)
+	}
+	if subtotal <= 0 {
+		return 0
+	}
+
+	threshold, _ := parseAmount(p.ConditionValue)
+	if subtotal < threshold {
+		return 0
+	}
+
+	var discount float64
+	switch p.Type {
+	case model.PromotionTypeFullReduction:
+		discount = math.Min(reduction, subtotal)
+	case model.PromotionTypeDirectReduction:
+		discount = directReduction
+	case model.PromotionTypeFullDiscount, model.PromotionTypeTimeLimited:
+		rate, err := parseDiscountRate(p.DiscountValue)
+		if err != nil {
+			return 0
+		}
+		discount = subtotal * (1 - rate)
+	}
+	return roundAmount(discount)
+}
+
+// AppliedPromotion 最终应用的促销活动及其优惠金额
+type AppliedPromotion struct {
+	Promotion *model.Promotion
+	Amount    float64
+}
+
+// selectPromotions 从候选促销中选出最终应用的组合
+// 规则：同一类型只取优惠金额最大的一个，不同类型之间可叠加，总优惠不超过商品总额
+func selectPromotions(candidates []*model.Promotion, lines []DiscountLine, total float64) ([]AppliedPromotion, float64) {
+	bestByType := make(map[int8]*model.Promotion)
+	bestAmount := make(map[int8]float64)
+	for _, p := range candidates {
+		amount := calcPromotionDiscount(p, lines)
+		if amount <= 0 {
+			continue
+		}
+		if amount > bestAmount[p.Type] {
+			bestByType[p.Type] = p
+			bestAmount[p.Type] = amount
+		}
+	}
+
+	var applied []AppliedPromotion
+	remaining := total
+	// 按候选顺序（排序权重）输出，保证结果稳定；超过商品总额的部分从后面的活动中扣除
+	for _, p := range candidates {
+		if best, ok := bestByType[p.Type]; ok && best.ID == p.ID {
+			amount := roundAmount(math.Min(bestAmount[p.Type], remaining))
+			if amount <= 0 {
+				continue
+			}
+			applied = append(applied, AppliedPromotion{Promotion: p, Amount: amount})
+			remaining -= amount
+		}
+	}
+	return applied, roundAmount(total - math.Max(remaining, 0))
+}
+
+// allocateLineDiscounts 将促销优惠和优惠券优惠分摊到每个商品行
+// 直降按行精确计算，其余促销按适用行小计比例分摊；优惠券按促销后的行金额比例分摊
+func allocateLineDiscounts(applied []AppliedPromotion, lines []DiscountLine, couponDiscount float64) (promotionShares, couponShares []float64) {
+	promotionShares = make([]float64, len(lines))
+	for _, ap := range applied {
+		weights := make([]float64, len(lines))
+		for i, line := range lines {
+			if !promotionAppliesTo(ap.Promotion, line.ProductID) {
+				continue
+			}
+			if ap.Promotion.Type == model.PromotionTypeDirectReduction {
+				reduction, _ := parseAmount(ap.Promotion.DiscountValue)
+				weights[i] = math.Min(reduction, line.Price) * float64(line.Quantity)
+			} else {
+				weights[i] = line.Subtotal()
+			}
+		}
+		for i, share := range allocateByWeight(ap.Amount, weights) {
+			promotionShares[i] += share
+		}
+	}
+
+	weights := make([]float64, len(lines))
+	for i, line := range lines {
+		weights[i] = math.Max(line.Subtotal()-promotionShares[i], 0)
+	}
+	couponShares = allocateByWeight(couponDiscount, weights)
+	for i := range promotionShares {
+		promotionShares[i] = roundAmount(promotionShares[i])
+	}
+	return promotionShares, couponShares
+}
+
+// allocateByWeight 按权重比例分摊金额（精确到分），尾差由最后一个权重为正的项承担
+func allocateByWeight(amount float64, weights []float64) []float64 {
+	shares := make([]float64, len(weights))
+	var totalWeight float64
+	last := -1
+	for i, w := range weights {
+		if w > 0 {
+			totalWeight += w
+			last = i
+		}
+	}
+	if amount <= 0 || last < 0 {
+		return shares
+	}
+
+	var allocated float64
+	for i, w := range weights {
+		if w <= 0 {
+			continue
+		}
+		if i == last {
+			shares[i] = roundAmount(amount - allocated)
+			break
+		}
+		shares[i] = roundAmount(amount * w / totalWeight)
+		allocated += shares[i]
+	}
+	return shares
+}
+
+// calcCouponDiscount 计算优惠券在促销后金额上的优惠金额，未达到使用条件时返回 0
+// 免运费券不抵扣商品金额，由运费计算环节处理
+func calcCouponDiscount(coupon *model.Coupon, amount float64) float64 {
+	if amount <= 0 {
+		return 0
+	}
+	threshold, _ := parseAmount(coupon.ConditionValue)
+	if amount < threshold {
+		return 0
+	}
+	switch coupon.Type {
+	case model.CouponTypeFixed:
+		v, _ := parseAmount(coupon.DiscountValue)
+		return roundAmount(math.Min(v, amount))
+	case model.CouponTypePercent:
+		rate, err := parseDiscountRate(coupon.DiscountValue)
+		if err != nil {
+			return 0
+		}
+		return roundAmount(amount * (1 - rate))
+	}
+	return 0
+}
+
+// roundAmount 金额保留两位小数
+func roundAmount(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	promotionv1 "zjMall/gen/go/api/proto/promotion"
+	"zjMall/internal/promotion-service/model"
+	"zjMall/internal/promotion-service/repository"
+)
+
+// ApplyOrderDiscount 锁定订单优惠：在一个本地事务中核销优惠券并占用促销配额
+// 以订单号作为幂等键，订单服务重试时不会重复核销或重复占用配额
+func (s *PromotionService) ApplyOrderDiscount(ctx context.Context, req *promotionv1.ApplyOrderDiscountRequest) (*promotionv1.ApplyOrderDiscountResponse, error) {
+	userID := resolveUserID(ctx, req.UserId)
+	if userID == "" {
+		return &promotionv1.ApplyOrderDiscountResponse{
+			Code:    1,
+			Message: "用户未登录",
+		}, nil
+	}
+
+	// 校验优惠券使用条件（状态和有效期在事务内通过条件更新校验）
+	if req.CouponId != "" {
+		coupon, err := s.couponRepo.GetCouponByID(ctx, req.CouponId)
+		if err != nil {
+			log.Printf("❌ [PromotionService] ApplyOrderDiscount: 查询优惠券失败 coupon_id=%s, err=%v", req.CouponId, err)
+			return &promotionv1.ApplyOrderDiscountResponse{
+				Code:    1,
+				Message: "查询优惠券失败",
+			}, nil
+		}
+		if coupon == nil || coupon.UserID != userID {
+			return &promotionv1.ApplyOrderDiscountResponse{
+				Code:    1,
+				Message: "优惠券不存在",
+			}, nil
+		}
+		threshold, _ := parseAmount(coupon.ConditionValue)
+		if req.OrderAmount < threshold {
+			return &promotionv1.ApplyOrderDiscountResponse{
+				Code:    1,
+				Message: fmt.Sprintf("未达到优惠券使用条件（满%.2f可用）", threshold),
+			}, nil
+		}
+	}
+
+	usages := make([]*model.PromotionUsageLog, 0, len(req.Promotions))
+	for _, p := range req.Promotions {
+		if p.PromotionId == "" {
+			continue
+		}
+		usages = append(usages, &model.PromotionUsageLog{
+			PromotionID:    p.PromotionId,
+			DiscountAmount: roundAmount(p.DiscountAmount),
+		})
+	}
+
+	if err := s.orderDiscountRepo.ApplyOrderDiscount(ctx, req.OrderId, userID, req.CouponId, usages, time.Now()); err != nil {
+		if errors.Is(err, repository.ErrCouponUnavailable) ||
+			errors.Is(err, repository.ErrPromotionQuotaExhausted) ||
+			errors.Is(err, repository.ErrPromotionUsageLimitExceeded) {
+			return &promotionv1.ApplyOrderDiscountResponse{
+				Code:    1,
+				Message: err.Error(),
+			}, nil
+		}
+		log.Printf("❌ [PromotionService] ApplyOrderDiscount: 锁定订单优惠失败 order_id=%s, err=%v", req.OrderId, err)
+		return &promotionv1.ApplyOrderDiscountResponse{
+			Code:    1,
+			Message: "锁定订单优惠失败",
+		}, nil
+	}
+
+	log.Printf("✅ [PromotionService] ApplyOrderDiscount: 锁定成功 order_id=%s, coupon_id=%s, promotions=%d", req.OrderId, req.CouponId, len(usages))
+	return &promotionv1.ApplyOrderDiscountResponse{
+		Code:    0,
+		Message: "锁定成功",
+	}, nil
+}
+
+// ReleaseOrderDiscount 释放订单优惠：退还优惠券并归还促销配额（幂等）
+func (s *PromotionService) ReleaseOrderDiscount(ctx context.Context, req *promotionv1.ReleaseOrderDiscountRequest) (*promotionv1.ReleaseOrderDiscountResponse, error) {
+	userID := resolveUserID(ctx, req.UserId)
+	if userID == "" {
+		return &promotionv1.ReleaseOrderDiscountResponse{
+			Code:    1,
+			Message: "用户ID不能为空",
+		}, nil
+	}
+
+	if err := s.orderDiscountRepo.ReleaseOrderDiscount(ctx, req.OrderId, userID); err != nil {
+		log.Printf("❌ [PromotionService] ReleaseOrderDiscount: 释放订单优惠失败 order_id=%s, err=%v", req.OrderId, err)
+		return &promotionv1.ReleaseOrderDiscountResponse{
+			Code:    1,
+			Message: "释放订单优惠失败",
+		}, nil
+	}
+
+	log.Printf("✅ [PromotionService] ReleaseOrderDiscount: 释放成功 order_id=%s", req.OrderId)
+	return &promotionv1.ReleaseOrderDiscountResponse{
+		Code:    0,
+		Message: "释放成功",
+	}, nil
+}
@@ -0,0 +1,503 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	promotionv1 "zjMall/gen/go/api/proto/promotion"
+	"zjMall/internal/promotion-service/model"
+	"zjMall/internal/promotion-service/repository"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+)
+
+// PromotionService 促销服务（促销活动 + 优惠券）
+type PromotionService struct {
+	promotionRepo     repository.PromotionRepository
+	couponRepo        repository.CouponRepository
+	orderDiscountRepo repository.OrderDiscountRepository
+}
+
+// NewPromotionService 创建促销服务实例
+func NewPromotionService(promotionRepo repository.PromotionRepository, couponRepo repository.CouponRepository, orderDiscountRepo repository.OrderDiscountRepository) *PromotionService {
+	return &PromotionService{
+		promotionRepo:     promotionRepo,
+		couponRepo:        couponRepo,
+		orderDiscountRepo: orderDiscountRepo,
+	}
+}
+
+// CreatePromotion 创建促销活动（初始状态为草稿，需通过 UpdatePromotion 上线）
+func (s *PromotionService) CreatePromotion(ctx context.Context, req *promotionv1.CreatePromotionRequest) (*promotionv1.CreatePromotionResponse, error) {
+	if err := NewCreatePromotionRequestValidator(req).Validate(); err != nil {
+		return &promotionv1.CreatePromotionResponse{
+			Code:    1,
+			Message: err.Error(),
+		}, nil
+	}
+
+	promotion := &model.Promotion{
+		Name:           req.Name,
+		Type:           int8(req.Type),
+		Description:    req.Description,
+		ConditionValue: req.ConditionValue,
+		DiscountValue:  req.DiscountValue,
+		StartTime:      req.StartTime.AsTime(),
+		EndTime:        req.EndTime.AsTime(),
+		MaxUseTimes:    req.MaxUseTimes,
+		TotalQuota:     req.TotalQuota,
+		SortOrder:      req.SortOrder,
+		Status:         model.PromotionStatusDraft,
+	}
+	promotion.SetProductIDs(req.ProductIds)
+	promotion.SetCategoryIDs(req.CategoryIds)
+
+	if err := validatePromotion(promotion); err != nil {
+		return &promotionv1.CreatePromotionResponse{
+			Code:    1,
+			Message: err.Error(),
+		}, nil
+	}
+
+	if err := s.promotionRepo.CreatePromotion(ctx, promotion); err != nil {
+		log.Printf("❌ [PromotionService] CreatePromotion: 创建促销活动失败: %v", err)
+		return &promotionv1.CreatePromotionResponse{
+			Code:    1,
+			Message: "创建促销活动失败",
+		}, nil
+	}
+
+	log.Printf("✅ [PromotionService] CreatePromotion: 创建成功 promotion_id=%s, type=%d", promotion.ID, promotion.Type)
+	return &promotionv1.CreatePromotionResponse{
+		Code:        0,
+		Message:     "创建成功",
+		PromotionId: promotion.ID,
+	}, nil
+}
+
+// UpdatePromotion 更新促销活动（请求中的零值字段表示不修改）
+func (s *PromotionService) UpdatePromotion(ctx context.Context, req *promotionv1.UpdatePromotionRequest) (*promotionv1.UpdatePromotionResponse, error) {
+	promotion, err := s.promotionRepo.GetPromotionByID(ctx, req.PromotionId)
+	if err != nil {
+		log.Printf("❌ [PromotionService] UpdatePromotion: 查询促销活动失败 promotion_id=%s, err=%v", req.PromotionId, err)
+		return &promotionv1.UpdatePromotionResponse{
+			Code:    1,
+			Message: "查询促销活动失败",
+		}, nil
+	}
+	if promotion == nil {
+		return &promotionv1.UpdatePromotionResponse{
+			Code:    1,
+			Message: "促销活动不存在",
+		}, nil
+	}
+
+	if req.Name != "" {
+		promotion.Name = req.Name
+	}
+	if req.Description != "" {
+		promotion.Description = req.Description
+	}
+	if len(req.ProductIds) > 0 {
+		promotion.SetProductIDs(req.ProductIds)
+	}
+	if len(req.CategoryIds) > 0 {
+		promotion.SetCategoryIDs(req.CategoryIds)
+	}
+	if req.ConditionValue != "" {
+		promotion.ConditionValue = req.ConditionValue
+	}
+	if req.DiscountValue != "" {
+		promotion.DiscountValue = req.DiscountValue
+	}
+	if req.StartTime != nil {
+		promotion.StartTime = req.StartTime.AsTime()
+	}
+	if req.EndTime != nil {
+		promotion.EndTime = req.EndTime.AsTime()
+	}
+	if req.MaxUseTimes > 0 {
+		promotion.MaxUseTimes = req.MaxUseTimes
+	}
+	if req.TotalQuota > 0 {
+		promotion.TotalQuota = req.TotalQuota
+	}
+	if req.SortOrder != 0 {
+		promotion.SortOrder = req.SortOrder
+	}
+	if req.Status != promotionv1.PromotionStatus_PROMOTION_STATUS_UNSPECIFIED {
+		if req.Status == promotionv1.PromotionStatus_PROMOTION_STATUS_DELETED {
+			return &promotionv1.UpdatePromotionResponse{
+				Code:    1,
+				Message: "删除促销活动请使用删除接口",
+			}, nil
+		}
+		promotion.Status = int8(req.Status)
+	}
+
+	if err := validatePromotion(promotion); err != nil {
+		return &promotionv1.UpdatePromotionResponse{
+			Code:    1,
+			Message: err.Error(),
+		}, nil
+	}
+
+	if err := s.promotionRepo.UpdatePromotion(ctx, promotion); err != nil {
+		log.Printf("❌ [PromotionService] UpdatePromotion: 更新促销活动失败 promotion_id=%s, err=%v", req.PromotionId, err)
+		return &promotionv1.UpdatePromotionResponse{
+			Code:    1,
+			Message: "更新促销活动失败",
+		}, nil
+	}
+
+	return &promotionv1.UpdatePromotionResponse{
+		Code:    0,
+		Message: "更新成功",
+	}, nil
+}
+
+// GetPromotion 获取促销活动详情
+func (s *PromotionService) GetPromotion(ctx context.Context, req *promotionv1.GetPromotionRequest) (*promotionv1.GetPromotionResponse, error) {
+	promotion, err := s.promotionRepo.GetPromotionByID(ctx, req.PromotionId)
+	if err != nil {
+		log.Printf("❌ [PromotionService] GetPromotion: 查询促销活动失败 promotion_id=%s, err=%v", req.PromotionId, err)
+		return &promotionv1.GetPromotionResponse{
+			Code:    1,
+			Message: "查询促销活动失败",
+		}, nil
+	}
+	if promotion == nil {
+		return &promotionv1.GetPromotionResponse{
+			Code:    1,
+			Message: "促销活动不存在",
+		}, nil
+	}
+
+	return &promotionv1.GetPromotionResponse{
+		Code:    0,
+		Message: "查询成功",
+		Data:    convertPromotionToProto(promotion),
+	}, nil
+}
+
+// ListPromotions 分页查询促销活动
+func (s *PromotionService) ListPromotions(ctx context.Context, req *promotionv1.ListPromotionsRequest) (*promotionv1.ListPromotionsResponse, error) {
+	offset, limit := normalizePage(req.Page, req.PageSize)
+
+	promotions, total, err := s.promotionRepo.ListPromotions(ctx, repository.PromotionFilter{
+		Type:    int8(req.Type),
+		Status:  int8(req.Status),
+		Keyword: req.Keyword,
+	}, offset, limit)
+	if err != nil {
+		log.Printf("❌ [PromotionService] ListPromotions: 查询失败: %v", err)
+		return &promotionv1.ListPromotionsResponse{
+			Code:    1,
+			Message: "查询失败",
+		}, nil
+	}
+
+	data := make([]*promotionv1.PromotionInfo, 0, len(promotions))
+	for _, p := range promotions {
+		data = append(data, convertPromotionToProto(p))
+	}
+
+	return &promotionv1.ListPromotionsResponse{
+		Code:    0,
+		Message: "查询成功",
+		Data:    data,
+		Total:   total,
+	}, nil
+}
+
+// DeletePromotion 删除促销活动
+func (s *PromotionService) DeletePromotion(ctx context.Context, req *promotionv1.DeletePromotionRequest) (*promotionv1.DeletePromotionResponse, error) {
+	if err := s.promotionRepo.DeletePromotion(ctx, req.PromotionId); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &promotionv1.DeletePromotionResponse{
+				Code:    1,
+				Message: "促销活动不存在",
+			}, nil
+		}
+		log.Printf("❌ [PromotionService] DeletePromotion: 删除失败 promotion_id=%s, err=%v", req.PromotionId, err)
+		return &promotionv1.DeletePromotionResponse{
+			Code:    1,
+			Message: "删除促销活动失败",
+		}, nil
+	}
+
+	return &promotionv1.DeletePromotionResponse{
+		Code:    0,
+		Message: "删除成功",
+	}, nil
+}
+
+// GetAvailablePromotions 查询当前可用的促销活动
+// 过滤条件：处于时间窗口内的进行中活动、配额未用完、适用范围命中、用户未超过限用次数
+func (s *PromotionService) GetAvailablePromotions(ctx context.Context, req *promotionv1.GetAvailablePromotionsRequest) (*promotionv1.GetAvailablePromotionsResponse, error) {
+	candidates, err := s.loadUsablePromotions(ctx, req.UserId, time.Now())
+	if err != nil {
+		log.Printf("❌ [PromotionService] GetAvailablePromotions: 查询促销活动失败: %v", err)
+		return &promotionv1.GetAvailablePromotionsResponse{
+			Code:    1,
+			Message: "查询促销活动失败",
+		}, nil
+	}
+
+	data := make([]*promotionv1.PromotionInfo, 0, len(candidates))
+	for _, p := range candidates {
+		if !matchPromotionScope(p, req.ProductIds, req.CategoryId) {
+			continue
+		}
+		// 满减/满折需要达到门槛；未传总金额时不做门槛过滤
+		if req.TotalAmount > 0 && (p.Type == model.PromotionTypeFullReduction || p.Type == model.PromotionTypeFullDiscount) {
+			threshold, _ := parseAmount(p.ConditionValue)
+			if req.TotalAmount < threshold {
+				continue
+			}
+		}
+		data = append(data, convertPromotionToProto(p))
+	}
+
+	return &promotionv1.GetAvailablePromotionsResponse{
+		Code:    0,
+		Message: "查询成功",
+		Data:    data,
+	}, nil
+}
+
+// CalculateDiscount 计算订单优惠金额（促销优惠 + 优惠券优惠）
+// 先计算促销优惠，再以促销后的金额计算优惠券优惠
+func (s *PromotionService) CalculateDiscount(ctx context.Context, req *promotionv1.CalculateDiscountRequest) (*promotionv1.CalculateDiscountResponse, error) {
+	lines := make([]DiscountLine, 0, len(req.Items))
+	var total float64
+	for _, item := range req.Items {
+		if item.Quantity <= 0 || item.Price < 0 {
+			return &promotionv1.CalculateDiscountResponse{
+				Code:    1,
+				Message: fmt.Sprintf("非法商品项: sku_id=%s", item.SkuId),
+			}, nil
+		}
+		line := DiscountLine{
+			ProductID: item.ProductId,
+			SKUID:     item.SkuId,
+			Quantity:  item.Quantity,
+			Price:     item.Price,
+		}
+		lines = append(lines, line)
+		total += line.Subtotal()
+	}
+	if len(lines) == 0 {
+		// 未传商品明细时，仅按总金额参与全平台促销
+		total = req.TotalAmount
+		lines = append(lines, DiscountLine{Quantity: 1, Price: total})
+	}
+	total = roundAmount(total)
+
+	now := time.Now()
+	candidates, err := s.loadUsablePromotions(ctx, req.UserId, now)
+	if err != nil {
+		log.Printf("❌ [PromotionService] CalculateDiscount: 查询促销活动失败: %v", err)
+		return &promotionv1.CalculateDiscountResponse{
+			Code:    1,
+			Message: "查询促销活动失败",
+		}, nil
+	}
+	// 指定了促销活动时只在指定范围内计算，任一活动失效都直接拒绝，避免下单金额与预览不一致
+	if len(req.PromotionIds) > 0 {
+		usable := make(map[string]*model.Promotion, len(candidates))
+		for _, p := range candidates {
+			usable[p.ID] = p
+		}
+		selected := make([]*model.Promotion, 0, len(req.PromotionIds))
+		for _, id := range req.PromotionIds {
+			p, ok := usable[id]
+			if !ok {
+				return &promotionv1.CalculateDiscountResponse{
+					Code:    1,
+					Message: fmt.Sprintf("促销活动 %s 不可用", id),
+				}, nil
+			}
+			selected = append(selected, p)
+		}
+		candidates = selected
+	}
+	applied, promotionDiscount := selectPromotions(candidates, lines, total)
+
+	var couponDiscount float64
+	var appliedCoupon *promotionv1.CouponInfo
+	if req.CouponId != "" {
+		coupon, err := s.couponRepo.GetCouponByID(ctx, req.CouponId)
+		if err != nil {
+			log.Printf("❌ [PromotionService] CalculateDiscount: 查询优惠券失败 coupon_id=%s, err=%v", req.CouponId, err)
+			return &promotionv1.CalculateDiscountResponse{
+				Code:    1,
+				Message: "查询优惠券失败",
+			}, nil
+		}
+		if coupon == nil || coupon.UserID != req.UserId || !coupon.IsUsableAt(now) {
+			return &promotionv1.CalculateDiscountResponse{
+				Code:    1,
+				Message: "优惠券不可用",
+			}, nil
+		}
+		threshold, _ := parseAmount(coupon.ConditionValue)
+		if total-promotionDiscount < threshold {
+			return &promotionv1.CalculateDiscountResponse{
+				Code:    1,
+				Message: fmt.Sprintf("未达到优惠券使用条件（满%.2f可用）", threshold),
+			}, nil
+		}
+		couponDiscount = calcCouponDiscount(coupon, total-promotionDiscount)
+		appliedCoupon = convertCouponToProto(coupon, now)
+	}
+
+	appliedProto := make([]*promotionv1.PromotionInfo, 0, len(applied))
+	promotionDiscounts := make([]*promotionv1.AppliedPromotion, 0, len(applied))
+	for _, ap := range applied {
+		appliedProto = append(appliedProto, convertPromotionToProto(ap.Promotion))
+		promotionDiscounts = append(promotionDiscounts, &promotionv1.AppliedPromotion{
+			PromotionId:    ap.Promotion.ID,
+			DiscountAmount: ap.Amount,
+		})
+	}
+
+	// 仅在传入商品明细时返回行级分摊
+	var lineDiscounts []*promotionv1.LineDiscount
+	if len(req.Items) > 0 {
+		promotionShares, couponShares := allocateLineDiscounts(applied, lines, couponDiscount)
+		lineDiscounts = make([]*promotionv1.LineDiscount, 0, len(lines))
+		for i, line := range lines {
+			lineDiscounts = append(lineDiscounts, &promotionv1.LineDiscount{
+				ProductId:         line.ProductID,
+				SkuId:             line.SKUID,
+				PromotionDiscount: promotionShares[i],
+				CouponDiscount:    couponShares[i],
+				DiscountAmount:    roundAmount(promotionShares[i] + couponShares[i]),
+			})
+		}
+	}
+
+	totalDiscount := roundAmount(promotionDiscount + couponDiscount)
+	return &promotionv1.CalculateDiscountResponse{
+		Code:    0,
+		Message: "计算成功",
+		Data: &promotionv1.DiscountDetail{
+			TotalAmount:        total,
+			PromotionDiscount:  promotionDiscount,
+			CouponDiscount:     couponDiscount,
+			TotalDiscount:      totalDiscount,
+			FinalAmount:        roundAmount(total - totalDiscount),
+			AppliedPromotions:  appliedProto,
+			AppliedCoupon:      appliedCoupon,
+			LineDiscounts:      lineDiscounts,
+			PromotionDiscounts: promotionDiscounts,
+		},
+	}, nil
+}
+
+// loadUsablePromotions 查询生效中的促销活动，并剔除用户已达到限用次数的活动
+func (s *PromotionService) loadUsablePromotions(ctx context.Context, userID string, now time.Time) ([]*model.Promotion, error) {
+	promotions, err := s.promotionRepo.ListEffectivePromotions(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	if userID == "" || len(promotions) == 0 {
+		return promotions, nil
+	}
+
+	limitedIDs := make([]string, 0)
+	for _, p := range promotions {
+		if p.MaxUseTimes > 0 {
+			limitedIDs = append(limitedIDs, p.ID)
+		}
+	}
+	usages, err := s.promotionRepo.CountUserUsages(ctx, userID, limitedIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*model.Promotion, 0, len(promotions))
+	for _, p := range promotions {
+		if p.MaxUseTimes > 0 && usages[p.ID] >= int64(p.MaxUseTimes) {
+			continue
+		}
+		result = append(result, p)
+	}
+	return result, nil
+}
+
+// matchPromotionScope 判断促销适用范围是否命中请求中的商品或类目
+func matchPromotionScope(p *model.Promotion, productIDs []string, categoryID string) bool {
+	if p.IsPlatformWide() {
+		return true
+	}
+	for _, productID := range productIDs {
+		if promotionAppliesTo(p, productID) {
+			return true
+		}
+	}
+	if categoryID != "" {
+		for _, id := range p.GetCategoryIDs() {
+			if id == categoryID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validatePromotion 校验促销活动的时间窗口与优惠配置
+func validatePromotion(p *model.Promotion) error {
+	if !p.EndTime.After(p.StartTime) {
+		return fmt.Errorf("结束时间必须晚于开始时间")
+	}
+	if p.MaxUseTimes < 0 || p.TotalQuota < 0 {
+		return fmt.Errorf("限用次数和总配额不能为负数")
+	}
+	if p.TotalQuota > 0 && p.UsedQuota > p.TotalQuota {
+		return fmt.Errorf("总配额不能小于已使用配额（%d）", p.UsedQuota)
+	}
+	return validatePromotionValues(p.Type, p.ConditionValue, p.DiscountValue)
+}
+
+// normalizePage 规范化分页参数，返回 offset 和 limit
+func normalizePage(page, pageSize int32) (int, int) {
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+	return int((page - 1) * pageSize), int(pageSize)
+}
+
+// convertPromotionToProto 将促销活动转换为 Proto
+func convertPromotionToProto(p *model.Promotion) *promotionv1.PromotionInfo {
+	return &promotionv1.PromotionInfo{
+		Id:             p.ID,
+		Name:           p.Name,
+		Type:           promotionv1.PromotionType(p.Type),
+		Description:    p.Description,
+		ProductIds:     p.GetProductIDs(),
+		CategoryIds:    p.GetCategoryIDs(),
+		ConditionValue: p.ConditionValue,
+		DiscountValue:  p.DiscountValue,
+		StartTime:      timestamppb.New(p.StartTime),
+		EndTime:        timestamppb.New(p.EndTime),
+		MaxUseTimes:    p.MaxUseTimes,
+		TotalQuota:     p.TotalQuota,
+		UsedQuota:      p.UsedQuota,
+		SortOrder:      p.SortOrder,
+		Status:         promotionv1.PromotionStatus(p.Status),
+		CreatedAt:      timestamppb.New(p.CreatedAt),
+		UpdatedAt:      timestamppb.New(p.UpdatedAt),
+	}
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"errors"
+	"zjMall/pkg/validator"
+
+	promotionv1 "zjMall/gen/go/api/proto/promotion"
+)
+
+// CreatePromotionRequestValidator 创建促销活动请求校验器
+type CreatePromotionRequestValidator struct {
+	Name          string `validate:"required,max=100" label:"促销名称"`
+	Type          int32  `validate:"required,oneof=1 2 3 4" label:"促销类型"` // 1-满减, 2-满折, 3-直降, 4-限时折扣
+	DiscountValue string `validate:"required,max=50" label:"优惠值"`
+	HasStartTime  bool   `validate:"required" label:"开始时间"`
+	HasEndTime    bool   `validate:"required" label:"结束时间"`
+	MaxUseTimes   int32  `validate:"gte=0" label:"每人限用次数"`
+	TotalQuota    int32  `validate:"gte=0" label:"总配额"`
+}
+
+// NewCreatePromotionRequestValidator 创建促销活动请求校验器
+func NewCreatePromotionRequestValidator(req *promotionv1.CreatePromotionRequest) *CreatePromotionRequestValidator {
+	return &CreatePromotionRequestValidator{
+		Name:          req.Name,
+		Type:          int32(req.Type),
+		DiscountValue: req.DiscountValue,
+		HasStartTime:  req.StartTime != nil,
+		HasEndTime:    req.EndTime != nil,
+		MaxUseTimes:   req.MaxUseTimes,
+		TotalQuota:    req.TotalQuota,
+	}
+}
+
+// Validate 校验请求参数
+func (v *CreatePromotionRequestValidator) Validate() error {
+	if err := validator.ValidateStruct(v); err != nil {
+		return errors.New(validator.FormatError(err))
+	}
+	return nil
+}
+
+// CreateCouponTemplateRequestValidator 创建优惠券模板请求校验器
+type CreateCouponTemplateRequestValidator struct {
+	Name              string `validate:"required,max=100" label:"优惠券名称"`
+	Type              int32  `validate:"required,oneof=1 2 3" label:"优惠券类型"` // 1-固定金额, 2-折扣, 3-免运费
+	TotalCount        int32  `validate:"gte=0" label:"发放总数"`
+	ValidDays         int32  `validate:"gte=0" label:"领取后有效天数"`
+	HasValidStartTime bool   `validate:"required" label:"有效期开始时间"`
+	HasValidEndTime   bool   `validate:"required" label:"有效期结束时间"`
+}
+
+// NewCreateCouponTemplateRequestValidator 创建优惠券模板请求校验器
+func NewCreateCouponTemplateRequestValidator(req *promotionv1.CreateCouponTemplateRequest) *CreateCouponTemplateRequestValidator {
+	return &CreateCouponTemplateRequestValidator{
+		Name:              req.Name,
+		Type:              int32(req.Type),
+		TotalCount:        req.TotalCount,
+		ValidDays:         req.ValidDays,
+		HasValidStartTime: req.ValidStartTime != nil,
+		HasValidEndTime:   req.ValidEndTime != nil,
+	}
+}
+
+// Validate 校验请求参数
+func (v *CreateCouponTemplateRequestValidator) Validate() error {
+	if err := validator.ValidateStruct(v); err != nil {
+		return errors.New(validator.FormatError(err))
+	}
+	return nil
+}
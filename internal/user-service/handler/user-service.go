@@ -221,6 +221,98 @@ func (h *UserServiceHandler) SetDefaultAddress(ctx context.Context, req *userv1.
 	return h.userService.SetDefaultAddress(ctx, userID, req.AddressId)
 }
 
+func (h *UserServiceHandler) CreateInvoiceTitle(ctx context.Context, req *userv1.CreateInvoiceTitleRequest) (*userv1.CreateInvoiceTitleResponse, error) {
+	validator := service.NewCreateInvoiceTitleRequestValidator(req)
+	if err := validator.Validate(); err != nil {
+		return &userv1.CreateInvoiceTitleResponse{
+			Code:    1,
+			Message: err.Error(),
+		}, nil
+	}
+	// 从 context 中获取用户 ID（由认证中间件设置）
+	userID := middleware.GetUserIDFromContext(ctx)
+	if userID == "" {
+		return &userv1.CreateInvoiceTitleResponse{
+			Code:    1,
+			Message: "未登录或用户ID无效",
+		}, nil
+	}
+	return h.userService.CreateInvoiceTitle(ctx, userID, req)
+}
+
+func (h *UserServiceHandler) ListInvoiceTitles(ctx context.Context, req *userv1.ListInvoiceTitlesRequest) (*userv1.ListInvoiceTitlesResponse, error) {
+	// 从 context 中获取用户 ID（由认证中间件设置）
+	userID := middleware.GetUserIDFromContext(ctx)
+	if userID == "" {
+		return &userv1.ListInvoiceTitlesResponse{
+			Code:    1,
+			Message: "未登录或用户ID无效",
+		}, nil
+	}
+	return h.userService.ListInvoiceTitles(ctx, userID)
+}
+
+func (h *UserServiceHandler) GetInvoiceTitle(ctx context.Context, req *userv1.GetInvoiceTitleRequest) (*userv1.GetInvoiceTitleResponse, error) {
+	if req.TitleId == "" {
+		return &userv1.GetInvoiceTitleResponse{
+			Code:    1,
+			Message: "发票抬头ID不能为空",
+		}, nil
+	}
+	// 从 context 中获取用户 ID（由认证中间件设置，订单服务通过 gRPC metadata 传递）
+	userID := middleware.GetUserIDFromContext(ctx)
+	if userID == "" {
+		return &userv1.GetInvoiceTitleResponse{
+			Code:    1,
+			Message: "未登录或用户ID无效",
+		}, nil
+	}
+	return h.userService.GetInvoiceTitle(ctx, userID, req.TitleId)
+}
+
+func (h *UserServiceHandler) UpdateInvoiceTitle(ctx context.Context, req *userv1.UpdateInvoiceTitleRequest) (*userv1.UpdateInvoiceTitleResponse, error) {
+	if req.TitleId == "" {
+		return &userv1.UpdateInvoiceTitleResponse{
+			Code:    1,
+			Message: "发票抬头ID不能为空",
+		}, nil
+	}
+	validator := service.NewUpdateInvoiceTitleRequestValidator(req)
+	if err := validator.Validate(); err != nil {
+		return &userv1.UpdateInvoiceTitleResponse{
+			Code:    1,
+			Message: err.Error(),
+		}, nil
+	}
+	// 从 context 中获取用户 ID（由认证中间件设置）
+	userID := middleware.GetUserIDFromContext(ctx)
+	if userID == "" {
+		return &userv1.UpdateInvoiceTitleResponse{
+			Code:    1,
+			Message: "未登录或用户ID无效",
+		}, nil
+	}
+	return h.userService.UpdateInvoiceTitle(ctx, userID, req)
+}
+
+func (h *UserServiceHandler) DeleteInvoiceTitle(ctx context.Context, req *userv1.DeleteInvoiceTitleRequest) (*userv1.DeleteInvoiceTitleResponse, error) {
+	if req.TitleId == "" {
+		return &userv1.DeleteInvoiceTitleResponse{
+			Code:    1,
+			Message: "发票抬头ID不能为空",
+		}, nil
+	}
+	// 从 context 中获取用户 ID（由认证中间件设置）
+	userID := middleware.GetUserIDFromContext(ctx)
+	if userID == "" {
+		return &userv1.DeleteInvoiceTitleResponse{
+			Code:    1,
+			Message: "未登录或用户ID无效",
+		}, nil
+	}
+	return h.userService.DeleteInvoiceTitle(ctx, userID, req.TitleId)
+}
+
 func (h *UserServiceHandler) ChangePassword(ctx context.Context, req *userv1.ChangePasswordRequest) (*userv1.ChangePasswordResponse, error) {
 	validator := service.NewChangePasswordRequestValidator(req)
 	if err := validator.Validate(); err != nil {
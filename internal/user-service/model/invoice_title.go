@@ -0,0 +1,32 @@
+package model
+
+import "zjMall/pkg"
+
+// 发票抬头类型常量
+const (
+	InvoiceTitleTypePersonal = int8(1) // 个人
+	InvoiceTitleTypeCompany  = int8(2) // 企业
+)
+
+// InvoiceTitle 发票抬头模型
+// 对应数据库表：invoice_titles
+type InvoiceTitle struct {
+	pkg.BaseModel
+
+	// 关联信息
+	UserID string `gorm:"type:varchar(26);not null;index:idx_user_default;comment:用户ID" json:"user_id"`
+
+	// 抬头信息
+	TitleType int8   `gorm:"type:tinyint;not null;comment:抬头类型：1-个人，2-企业" json:"title_type"`
+	Title     string `gorm:"type:varchar(100);not null;comment:抬头名称" json:"title"`
+	TaxNo     string `gorm:"type:varchar(20);comment:纳税人识别号（企业必填）" json:"tax_no,omitempty"`
+	Email     string `gorm:"type:varchar(100);comment:接收电子发票的邮箱" json:"email,omitempty"`
+
+	// 状态信息
+	IsDefault bool `gorm:"type:tinyint(1);default:0;index:idx_user_default;comment:是否默认：0-否，1-是" json:"is_default"`
+}
+
+// TableName 指定表名
+func (InvoiceTitle) TableName() string {
+	return "invoice_titles"
+}
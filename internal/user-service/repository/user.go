@@ -52,6 +52,13 @@ type UserRepository interface {
 	DeleteAddress(ctx context.Context, userID string, addressID string) error
 	SetDefaultAddress(ctx context.Context, userID string, addressID string) error
 	CreateAddressWithDefault(ctx context.Context, address *model.Address) error
+
+	//发票抬头相关操作
+	CreateInvoiceTitle(ctx context.Context, title *model.InvoiceTitle) error
+	ListInvoiceTitles(ctx context.Context, userID string) ([]*model.InvoiceTitle, error)
+	GetInvoiceTitleByID(ctx context.Context, userID string, titleID string) (*model.InvoiceTitle, error)
+	UpdateInvoiceTitle(ctx context.Context, title *model.InvoiceTitle) error
+	DeleteInvoiceTitle(ctx context.Context, userID string, titleID string) error
 }
 
 type userRepository struct {
@@ -297,6 +304,81 @@ func (r *userRepository) CreateAddressWithDefault(ctx context.Context, address *
 		return nil
 	})
 }
+
+// CreateInvoiceTitle 创建发票抬头，设为默认时在同一事务中取消其他抬头的默认状态
+func (r *userRepository) CreateInvoiceTitle(ctx context.Context, title *model.InvoiceTitle) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if title.IsDefault {
+			if err := tx.Model(&model.InvoiceTitle{}).
+				Where("user_id = ?", title.UserID).
+				Update("is_default", false).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(title).Error
+	})
+}
+
+func (r *userRepository) ListInvoiceTitles(ctx context.Context, userID string) ([]*model.InvoiceTitle, error) {
+	var titles []*model.InvoiceTitle
+	err := r.db.WithContext(ctx).Model(&model.InvoiceTitle{}).
+		Where("user_id = ?", userID).
+		Order("is_default DESC, created_at DESC").
+		Find(&titles).Error
+	if err != nil {
+		return nil, err
+	}
+	return titles, nil
+}
+
+func (r *userRepository) GetInvoiceTitleByID(ctx context.Context, userID string, titleID string) (*model.InvoiceTitle, error) {
+	var title model.InvoiceTitle
+	err := r.db.WithContext(ctx).Model(&model.InvoiceTitle{}).
+		Where("user_id = ? AND id = ?", userID, titleID).
+		First(&title).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil // 抬头不存在
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &title, nil
+}
+
+// UpdateInvoiceTitle 更新发票抬头（全字段更新，便于清空税号、邮箱），设为默认时取消其他抬头的默认状态
+func (r *userRepository) UpdateInvoiceTitle(ctx context.Context, title *model.InvoiceTitle) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if title.IsDefault {
+			if err := tx.Model(&model.InvoiceTitle{}).
+				Where("user_id = ? AND id <> ?", title.UserID, title.ID).
+				Update("is_default", false).Error; err != nil {
+				return err
+			}
+		}
+		// 同时根据user_id和id更新，确保只能更新自己的抬头
+		result := tx.Model(&model.InvoiceTitle{}).
+			Where("user_id = ? AND id = ?", title.UserID, title.ID).
+			Updates(map[string]interface{}{
+				"title_type": title.TitleType,
+				"title":      title.Title,
+				"tax_no":     title.TaxNo,
+				"email":      title.Email,
+				"is_default": title.IsDefault,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+func (r *userRepository) DeleteInvoiceTitle(ctx context.Context, userID string, titleID string) error {
+	return r.db.WithContext(ctx).Where("user_id = ? AND id = ?", userID, titleID).Delete(&model.InvoiceTitle{}).Error
+}
+
 func (r *userRepository) GetUserPasswordByPhone(ctx context.Context, phone string) (*UserAuthInfo, error) {
 	var userAuthInfo UserAuthInfo
 	err := r.db.WithContext(ctx).Model(&model.User{}).Where("phone = ?", phone).Select("id", "phone", "password").First(&userAuthInfo).Error
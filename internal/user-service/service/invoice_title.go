@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	userv1 "zjMall/gen/go/api/proto/user"
+	"zjMall/internal/user-service/model"
+	"zjMall/pkg"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+)
+
+// =============== 发票抬头 ===============
+
+// CreateInvoiceTitle 添加发票抬头（userID 从 context 中获取）
+func (s *UserService) CreateInvoiceTitle(ctx context.Context, userID string, req *userv1.CreateInvoiceTitleRequest) (*userv1.CreateInvoiceTitleResponse, error) {
+	title := &model.InvoiceTitle{
+		UserID:    userID,
+		TitleType: int8(req.TitleType),
+		Title:     req.Title,
+		TaxNo:     invoiceTaxNo(int8(req.TitleType), req.TaxNo),
+		Email:     req.Email,
+		IsDefault: req.IsDefault,
+	}
+	if err := s.userRepo.CreateInvoiceTitle(ctx, title); err != nil {
+		log.Printf("❌ [UserService] CreateInvoiceTitle: 创建发票抬头失败: userID=%s, err=%v", userID, err)
+		return &userv1.CreateInvoiceTitleResponse{
+			Code:    1,
+			Message: "创建发票抬头失败",
+		}, nil
+	}
+	return &userv1.CreateInvoiceTitleResponse{
+		Code:    0,
+		Message: "创建发票抬头成功",
+		Data:    convertInvoiceTitleToProto(title),
+	}, nil
+}
+
+// ListInvoiceTitles 查询发票抬头列表（默认抬头在前）
+func (s *UserService) ListInvoiceTitles(ctx context.Context, userID string) (*userv1.ListInvoiceTitlesResponse, error) {
+	titles, err := s.userRepo.ListInvoiceTitles(ctx, userID)
+	if err != nil {
+		return &userv1.ListInvoiceTitlesResponse{
+			Code:    1,
+			Message: "查询发票抬头列表失败",
+		}, nil
+	}
+	data := make([]*userv1.InvoiceTitle, len(titles))
+	for i, title := range titles {
+		data[i] = convertInvoiceTitleToProto(title)
+	}
+	return &userv1.ListInvoiceTitlesResponse{
+		Code:    0,
+		Message: "查询发票抬头列表成功",
+		Data:    data,
+	}, nil
+}
+
+// GetInvoiceTitle 获取发票抬头
+func (s *UserService) GetInvoiceTitle(ctx context.Context, userID string, titleID string) (*userv1.GetInvoiceTitleResponse, error) {
+	title, err := s.userRepo.GetInvoiceTitleByID(ctx, userID, titleID)
+	if err != nil {
+		return &userv1.GetInvoiceTitleResponse{
+			Code:    1,
+			Message: "获取发票抬头失败",
+		}, nil
+	}
+	if title == nil {
+		return &userv1.GetInvoiceTitleResponse{
+			Code:    1,
+			Message: "发票抬头不存在",
+		}, nil
+	}
+	return &userv1.GetInvoiceTitleResponse{
+		Code:    0,
+		Message: "获取发票抬头成功",
+		Data:    convertInvoiceTitleToProto(title),
+	}, nil
+}
+
+// UpdateInvoiceTitle 更新发票抬头（已下单订单使用的是抬头快照，不受影响）
+func (s *UserService) UpdateInvoiceTitle(ctx context.Context, userID string, req *userv1.UpdateInvoiceTitleRequest) (*userv1.UpdateInvoiceTitleResponse, error) {
+	title := &model.InvoiceTitle{
+		BaseModel: pkg.BaseModel{ID: req.TitleId},
+		UserID:    userID,
+		TitleType: int8(req.TitleType),
+		Title:     req.Title,
+		TaxNo:     invoiceTaxNo(int8(req.TitleType), req.TaxNo),
+		Email:     req.Email,
+		IsDefault: req.IsDefault,
+	}
+	if err := s.userRepo.UpdateInvoiceTitle(ctx, title); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &userv1.UpdateInvoiceTitleResponse{
+				Code:    1,
+				Message: "发票抬头不存在",
+			}, nil
+		}
+		log.Printf("❌ [UserService] UpdateInvoiceTitle: 更新发票抬头失败: userID=%s, titleID=%s, err=%v", userID, req.TitleId, err)
+		return &userv1.UpdateInvoiceTitleResponse{
+			Code:    1,
+			Message: "更新发票抬头失败",
+		}, nil
+	}
+	return &userv1.UpdateInvoiceTitleResponse{
+		Code:    0,
+		Message: "更新发票抬头成功",
+	}, nil
+}
+
+// DeleteInvoiceTitle 删除发票抬头
+func (s *UserService) DeleteInvoiceTitle(ctx context.Context, userID string, titleID string) (*userv1.DeleteInvoiceTitleResponse, error) {
+	if err := s.userRepo.DeleteInvoiceTitle(ctx, userID, titleID); err != nil {
+		return &userv1.DeleteInvoiceTitleResponse{
+			Code:    1,
+			Message: "删除发票抬头失败",
+		}, nil
+	}
+	return &userv1.DeleteInvoiceTitleResponse{
+		Code:    0,
+		Message: "删除发票抬头成功",
+	}, nil
+}
+
+// invoiceTaxNo 个人抬头不保存纳税人识别号
+func invoiceTaxNo(titleType int8, taxNo string) string {
+	if titleType == model.InvoiceTitleTypePersonal {
+		return ""
+	}
+	return taxNo
+}
+
+func convertInvoiceTitleToProto(title *model.InvoiceTitle) *userv1.InvoiceTitle {
+	return &userv1.InvoiceTitle{
+		Id:        title.ID,
+		UserId:    title.UserID,
+		TitleType: int32(title.TitleType),
+		Title:     title.Title,
+		TaxNo:     title.TaxNo,
+		Email:     title.Email,
+		IsDefault: title.IsDefault,
+		CreatedAt: timestamppb.New(title.CreatedAt),
+		UpdatedAt: timestamppb.New(title.UpdatedAt),
+	}
+}
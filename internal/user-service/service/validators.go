@@ -2,7 +2,9 @@ package service
 
 import (
 	"errors"
+	"regexp"
 	userv1 "zjMall/gen/go/api/proto/user"
+	"zjMall/internal/user-service/model"
 	"zjMall/pkg/validator"
 )
 
@@ -129,3 +131,48 @@ func (b *BindPhoneRequestValidator) Validate() error {
 	}
 	return nil
 }
+
+// taxNoPattern 纳税人识别号：15/18/20 位数字或大写字母（统一社会信用代码为18位）
+var taxNoPattern = regexp.MustCompile(`^[0-9A-Z]{15}$|^[0-9A-Z]{18}$|^[0-9A-Z]{20}$`)
+
+// InvoiceTitleRequestValidator 发票抬头请求校验器（添加、更新共用）
+type InvoiceTitleRequestValidator struct {
+	TitleType int32  `validate:"required,oneof=1 2" label:"抬头类型"`
+	Title     string `validate:"required,max=100" label:"抬头名称"`
+	TaxNo     string `validate:"omitempty,max=20" label:"纳税人识别号"`
+	Email     string `validate:"omitempty,email" label:"邮箱"`
+}
+
+func NewCreateInvoiceTitleRequestValidator(req *userv1.CreateInvoiceTitleRequest) *InvoiceTitleRequestValidator {
+	return &InvoiceTitleRequestValidator{
+		TitleType: req.TitleType,
+		Title:     req.Title,
+		TaxNo:     req.TaxNo,
+		Email:     req.Email,
+	}
+}
+
+func NewUpdateInvoiceTitleRequestValidator(req *userv1.UpdateInvoiceTitleRequest) *InvoiceTitleRequestValidator {
+	return &InvoiceTitleRequestValidator{
+		TitleType: req.TitleType,
+		Title:     req.Title,
+		TaxNo:     req.TaxNo,
+		Email:     req.Email,
+	}
+}
+
+// Validate 执行校验：企业抬头必须填写有效的纳税人识别号
+func (i *InvoiceTitleRequestValidator) Validate() error {
+	if err := validator.ValidateStruct(i); err != nil {
+		return errors.New(validator.FormatError(err))
+	}
+	if i.TitleType == int32(model.InvoiceTitleTypeCompany) {
+		if i.TaxNo == "" {
+			return errors.New("企业抬头纳税人识别号不能为空")
+		}
+		if !taxNoPattern.MatchString(i.TaxNo) {
+			return errors.New("纳税人识别号格式错误，应为15、18或20位数字或大写字母")
+		}
+	}
+	return nil
+}